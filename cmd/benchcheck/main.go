@@ -0,0 +1,170 @@
+// Command benchcheck compares two runs of go test -bench and fails when a
+// benchmark got significantly slower or allocates more than before.
+//
+//	benchcheck [-threshold 20] baseline.txt current.txt
+//
+// Each benchmark is summarised by the median of its runs, so run both with
+// -count of 5 or more to keep one noisy run from failing the check.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// metrics the check compares; others go test may print are ignored.
+var metrics = []string{"ns/op", "allocs/op"}
+
+// results maps a benchmark name, without its GOMAXPROCS suffix, to the
+// samples of each metric.
+type results map[string]map[string][]float64
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run is the command with its arguments and output passed in; it returns
+// the exit code: 1 for a regression, 2 for a usage or input error.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("benchcheck", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	threshold := fs.Float64("threshold", 20, "percentage by which a metric may grow before the check fails")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: benchcheck [-threshold pct] baseline.txt current.txt")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	base, err := parseFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	cur, err := parseFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if regressions := compare(stdout, base, cur, *threshold); regressions > 0 {
+		fmt.Fprintf(stderr, "%d benchmark metric(s) regressed by more than %g%%\n", regressions, *threshold)
+		return 1
+	}
+	return 0
+}
+
+func parseFile(path string) (results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return parse(f)
+}
+
+// parse reads the benchmark lines of go test -bench output, such as
+//
+//	BenchmarkGetTool-8   50000   24316 ns/op   4352 B/op   126 allocs/op
+func parse(r io.Reader) (results, error) {
+	res := results{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid %s value %q", name, fields[i+1], fields[i])
+			}
+			if res[name] == nil {
+				res[name] = map[string][]float64{}
+			}
+			res[name][fields[i+1]] = append(res[name][fields[i+1]], v)
+		}
+	}
+	return res, sc.Err()
+}
+
+// compare writes a table of the median of each metric in both runs and
+// returns how many grew by more than threshold percent. Benchmarks only in
+// one run are listed but never fail the check.
+func compare(w io.Writer, base, cur results, threshold float64) int {
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		names = append(names, name)
+	}
+	for name := range base {
+		if _, ok := cur[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tmetric\tbaseline\tcurrent\tdelta\t")
+	regressions := 0
+	for _, name := range names {
+		for _, metric := range metrics {
+			b, inBase := median(base[name][metric])
+			c, inCur := median(cur[name][metric])
+			switch {
+			case !inBase && !inCur:
+				continue
+			case !inBase:
+				fmt.Fprintf(tw, "%s\t%s\t-\t%.0f\tnew\t\n", name, metric, c)
+				continue
+			case !inCur:
+				fmt.Fprintf(tw, "%s\t%s\t%.0f\t-\tremoved\t\n", name, metric, b)
+				continue
+			}
+			delta := 0.0
+			if b > 0 {
+				delta = (c - b) / b * 100
+			} else if c > 0 {
+				delta = 100
+			}
+			mark := ""
+			if delta > threshold {
+				mark = "  REGRESSION"
+				regressions++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.0f\t%.0f\t%+.1f%%%s\t\n", name, metric, b, c, delta, mark)
+		}
+	}
+	_ = tw.Flush()
+	return regressions
+}
+
+func median(samples []float64) (float64, bool) {
+	if len(samples) == 0 {
+		return 0, false
+	}
+	s := append([]float64(nil), samples...)
+	sort.Float64s(s)
+	if n := len(s); n%2 == 0 {
+		return (s[n/2-1] + s[n/2]) / 2, true
+	}
+	return s[len(s)/2], true
+}
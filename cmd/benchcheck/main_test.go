@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseline = `goos: linux
+pkg: github.com/clawinfra/agent-tools/internal/registry
+BenchmarkGetTool-8         	   50000	     24000 ns/op	    4352 B/op	     126 allocs/op
+BenchmarkGetTool-8         	   50000	     25000 ns/op	    4352 B/op	     126 allocs/op
+BenchmarkGetTool-8         	   50000	     90000 ns/op	    4352 B/op	     126 allocs/op
+BenchmarkSearchTools/weather-8	    5000	    260000 ns/op	   41970 B/op	     909 allocs/op
+BenchmarkGone-8            	     100	      1000 ns/op
+PASS
+ok  	github.com/clawinfra/agent-tools/internal/registry	1.141s
+`
+
+func TestParse(t *testing.T) {
+	res, err := parse(strings.NewReader(baseline))
+	require.NoError(t, err)
+	assert.Equal(t, []float64{24000, 25000, 90000}, res["BenchmarkGetTool"]["ns/op"])
+	assert.Equal(t, []float64{909}, res["BenchmarkSearchTools/weather"]["allocs/op"])
+	assert.Len(t, res, 3)
+
+	_, err = parse(strings.NewReader("BenchmarkX-8 10 fast ns/op\n"))
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	base, err := parse(strings.NewReader(baseline))
+	require.NoError(t, err)
+
+	cur, err := parse(strings.NewReader(`
+BenchmarkGetTool-8 50000 26000 ns/op 4352 B/op 126 allocs/op
+BenchmarkSearchTools/weather-8 5000 250000 ns/op 41970 B/op 1200 allocs/op
+BenchmarkNew-8 100 1000 ns/op
+`))
+	require.NoError(t, err)
+
+	var out strings.Builder
+	n := compare(&out, base, cur, 20)
+	assert.Equal(t, 1, n, "only the allocation growth exceeds the threshold:\n%s", out.String())
+	assert.Contains(t, out.String(), "+4.0%", "the median, not the outlier, is the baseline")
+	assert.Contains(t, out.String(), "REGRESSION")
+	assert.Contains(t, out.String(), "new")
+	assert.Contains(t, out.String(), "removed")
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	base := write("base.txt", baseline)
+	same := write("same.txt", "BenchmarkGetTool-8 50000 25000 ns/op 4352 B/op 126 allocs/op\n")
+	slower := write("slower.txt", "BenchmarkGetTool-8 50000 40000 ns/op 4352 B/op 126 allocs/op\n")
+	invalid := write("invalid.txt", "BenchmarkX-8 10 fast ns/op\n")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"unchanged", []string{base, same}, 0, "+0.0%", ""},
+		{"regressed", []string{base, slower}, 1, "REGRESSION", "1 benchmark metric(s) regressed by more than 20%"},
+		{"higher threshold", []string{"-threshold", "70", base, slower}, 0, "+60.0%", ""},
+		{"one file", []string{base}, 2, "", "usage: benchcheck"},
+		{"unknown flag", []string{"-x", base, same}, 2, "", "flag provided but not defined"},
+		{"no baseline", []string{filepath.Join(dir, "missing.txt"), same}, 2, "", "no such file"},
+		{"invalid current", []string{base, invalid}, 2, "", "invalid ns/op value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			assert.Equal(t, tt.code, run(tt.args, &stdout, &stderr), stderr.String())
+			assert.Contains(t, stdout.String(), tt.stdout)
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+}
+
+func TestCompare_ZeroBaseline(t *testing.T) {
+	base := results{"BenchmarkX": {"allocs/op": {0, 0}}}
+	cur := results{"BenchmarkX": {"allocs/op": {1, 3}}}
+	var out strings.Builder
+	assert.Equal(t, 1, compare(&out, base, cur, 20))
+	assert.Contains(t, out.String(), "+100.0%", "any allocation counts as a regression from none")
+	assert.Contains(t, out.String(), "  2  ", "the median of an even run is the mean of the middle two")
+}
@@ -0,0 +1,212 @@
+// Command sdktypes generates the Go SDK's copies of the registry's tool
+// types from their definitions in internal/registry, so that a field the
+// registry adds to tools reaches the SDK with the same change:
+//
+//	sdktypes [-src internal/registry] [-o sdk/go/agenttools/types_gen.go]
+//
+// Only the types of a tool as the API returns it are generated. Requests,
+// whose empty fields the SDK leaves out, are written by hand. The package's
+// test fails while the generated file is out of date; run make sdk-types or
+// go generate ./sdk/... after changing a generated type.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generated are the registry types copied into the SDK, in the order they
+// are written: a tool and every type its fields refer to.
+var generated = []string{
+	"Tool", "ToolSchema", "Pricing", "Advisory", "CachePolicy", "Compatibility",
+	"SLA", "SLACompliance", "Quota", "PricingNotice", "RetryPolicy",
+	"Mock", "MockResponse", "ToolTest", "Conformance", "TestFailure",
+}
+
+// replaced maps registry types the SDK does not have to the types it uses
+// in their place.
+var replaced = map[string]string{"PricingModel": "string"}
+
+// imports are the packages generated types may refer to, by name.
+var imports = map[string]string{"json": "encoding/json", "time": "time"}
+
+const header = `// Code generated by sdktypes from internal/registry. DO NOT EDIT.
+
+package agenttools
+`
+
+func main() {
+	src := flag.String("src", "internal/registry", "directory of the registry package")
+	out := flag.String("o", "sdk/go/agenttools/types_gen.go", "file to write")
+	flag.Parse()
+	code, err := generate(*src)
+	if err == nil {
+		err = os.WriteFile(*out, code, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sdktypes:", err)
+		os.Exit(1)
+	}
+}
+
+// typeDecl is a type declared in a file of the registry package.
+type typeDecl struct {
+	src  []byte
+	doc  *ast.CommentGroup
+	spec *ast.TypeSpec
+}
+
+// generate returns the formatted source of the SDK's copies of the
+// generated types of the registry package in dir.
+func generate(dir string) ([]byte, error) {
+	decls, err := parseTypes(dir)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	used := map[string]bool{}
+	for _, name := range generated {
+		d, ok := decls[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		expr, err := d.rewrite(used)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %w", name, err)
+		}
+		body.WriteString("\n")
+		if d.doc != nil {
+			body.Write(d.src[d.doc.Pos()-1 : d.doc.End()-1])
+			body.WriteString("\n")
+		}
+		fmt.Fprintf(&body, "type %s %s\n", name, expr)
+	}
+
+	var code bytes.Buffer
+	code.WriteString(header)
+	if len(used) > 0 {
+		paths := make([]string, 0, len(used))
+		for pkg := range used {
+			paths = append(paths, imports[pkg])
+		}
+		sort.Strings(paths)
+		code.WriteString("\nimport (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&code, "\t%q\n", path)
+		}
+		code.WriteString(")\n")
+	}
+	code.Write(body.Bytes())
+	return format.Source(code.Bytes())
+}
+
+// parseTypes returns the types declared in the non-test files of the
+// package in dir, by name.
+func parseTypes(dir string) (map[string]typeDecl, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	decls := map[string]typeDecl{}
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// Each file gets its own file set, so that positions are offsets
+		// into src plus one.
+		f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				d := typeDecl{src: src, doc: ts.Doc, spec: ts}
+				if d.doc == nil && len(gen.Specs) == 1 {
+					d.doc = gen.Doc
+				}
+				decls[ts.Name.Name] = d
+			}
+		}
+	}
+	return decls, nil
+}
+
+// rewrite returns the source of the declaration's type with replaced types
+// substituted, adding the packages it refers to to used. It fails when the
+// type refers to a registry type that is neither generated nor replaced.
+func (d typeDecl) rewrite(used map[string]bool) (string, error) {
+	type edit struct {
+		pos  int
+		end  int
+		text string
+	}
+	var (
+		edits []edit
+		err   error
+	)
+	known := map[string]bool{}
+	for _, name := range generated {
+		known[name] = true
+	}
+	ast.Inspect(d.spec.Type, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.Field:
+			// Only the field's type names types; its names do not.
+			ast.Inspect(n.Type, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					pkg, _ := n.X.(*ast.Ident)
+					if pkg == nil || imports[pkg.Name] == "" {
+						err = fmt.Errorf("refers to %s of a package the SDK does not import", n.Sel.Name)
+					} else {
+						used[pkg.Name] = true
+					}
+					return false
+				case *ast.Ident:
+					if to, ok := replaced[n.Name]; ok {
+						edits = append(edits, edit{int(n.Pos()) - 1, int(n.End()) - 1, to})
+					} else if !known[n.Name] && types.Universe.Lookup(n.Name) == nil {
+						err = fmt.Errorf("refers to %s, which is not generated", n.Name)
+					}
+				}
+				return err == nil
+			})
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	// Apply the edits from the last, so that the offsets of the others
+	// still hold.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos > edits[j].pos })
+	pos, end := int(d.spec.Type.Pos())-1, int(d.spec.Type.End())-1
+	text := string(d.src[pos:end])
+	for _, e := range edits {
+		text = text[:e.pos-pos] + e.text + text[e.end-pos:]
+	}
+	return text, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerated(t *testing.T) {
+	code, err := generate("../../internal/registry")
+	require.NoError(t, err)
+	current, err := os.ReadFile("../../sdk/go/agenttools/types_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(code), string(current), "the SDK's tool types are out of date: run make sdk-types")
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	write := func(src string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0o644))
+		return dir
+	}
+
+	_, err := generate(write("package registry\n\ntype Tool struct{ Owner Owner }\n\ntype Owner struct{}\n"))
+	assert.ErrorContains(t, err, "Owner, which is not generated")
+
+	_, err = generate(write("package registry\n\nimport \"net/url\"\n\ntype Tool struct{ URL *url.URL }\n"))
+	assert.ErrorContains(t, err, "does not import")
+
+	_, err = generate(write("package registry\n\ntype Tool struct{}\n"))
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,286 @@
+// Package a2a maps the registry onto the Agent-to-Agent (A2A) protocol: the
+// registry publishes an agent card whose skills are its tools, and each
+// invocation is reported as an A2A task.
+package a2a
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// ProtocolVersion is the A2A revision this bridge implements.
+const ProtocolVersion = "0.2.5"
+
+// MediaJSON is the only input and output mode of registry tools.
+const MediaJSON = "application/json"
+
+// JSON-RPC 2.0 and A2A error codes.
+const (
+	CodeParseError        = -32700
+	CodeInvalidRequest    = -32600
+	CodeMethodNotFound    = -32601
+	CodeInvalidParams     = -32602
+	CodeInternalError     = -32603
+	CodeTaskNotFound      = -32001
+	CodeTaskNotCancelable = -32002
+	CodeUnsupported       = -32004
+)
+
+// ErrNoSkill is returned by ParseSend for a message that names no skill.
+var ErrNoSkill = errors.New("message names no skill")
+
+// TaskState is the lifecycle state of a task.
+type TaskState string
+
+// Task states used by the bridge. Invocations run to completion within
+// message/send, so tasks are never left waiting for input.
+const (
+	TaskWorking   TaskState = "working"
+	TaskCompleted TaskState = "completed"
+	TaskFailed    TaskState = "failed"
+)
+
+// AgentCard describes the registry to A2A clients. It is served at
+// /.well-known/agent.json.
+type AgentCard struct {
+	ProtocolVersion    string       `json:"protocolVersion"`
+	Name               string       `json:"name"`
+	Description        string       `json:"description"`
+	URL                string       `json:"url"`
+	PreferredTransport string       `json:"preferredTransport"`
+	Version            string       `json:"version"`
+	Capabilities       Capabilities `json:"capabilities"`
+	DefaultInputModes  []string     `json:"defaultInputModes"`
+	DefaultOutputModes []string     `json:"defaultOutputModes"`
+	Skills             []Skill      `json:"skills"`
+}
+
+// Capabilities lists the optional A2A features an agent supports.
+type Capabilities struct {
+	Streaming              bool `json:"streaming"`
+	PushNotifications      bool `json:"pushNotifications"`
+	StateTransitionHistory bool `json:"stateTransitionHistory"`
+}
+
+// Skill is one registry tool as an A2A skill. Its ID is the tool ID.
+type Skill struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	InputModes  []string `json:"inputModes"`
+	OutputModes []string `json:"outputModes"`
+}
+
+// Message is one turn of a conversation with an agent.
+type Message struct {
+	Kind      string         `json:"kind"`
+	Role      string         `json:"role"`
+	MessageID string         `json:"messageId"`
+	ContextID string         `json:"contextId,omitempty"`
+	TaskID    string         `json:"taskId,omitempty"`
+	Parts     []Part         `json:"parts"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// Part is a piece of message or artifact content: text or structured data.
+type Part struct {
+	Kind string         `json:"kind"`
+	Text string         `json:"text,omitempty"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Task is an invocation as A2A clients see it. Its ID is the invocation ID.
+type Task struct {
+	Kind      string         `json:"kind"`
+	ID        string         `json:"id"`
+	ContextID string         `json:"contextId"`
+	Status    TaskStatus     `json:"status"`
+	Artifacts []Artifact     `json:"artifacts,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// TaskStatus is the current state of a task.
+type TaskStatus struct {
+	State     TaskState `json:"state"`
+	Message   *Message  `json:"message,omitempty"`
+	Timestamp string    `json:"timestamp"`
+}
+
+// Artifact is an output of a task.
+type Artifact struct {
+	ArtifactID string `json:"artifactId"`
+	Name       string `json:"name,omitempty"`
+	Parts      []Part `json:"parts"`
+}
+
+// SendParams are the params of message/send.
+type SendParams struct {
+	Message  Message        `json:"message"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// TaskQueryParams are the params of tasks/get and tasks/cancel.
+type TaskQueryParams struct {
+	ID string `json:"id"`
+}
+
+// Request is a JSON-RPC 2.0 request.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Card returns the agent card of a registry reachable at url, with tools as
+// its skills.
+func Card(url, version string, tools []*registry.Tool) *AgentCard {
+	skills := make([]Skill, 0, len(tools))
+	for _, t := range tools {
+		tags := t.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		skills = append(skills, Skill{
+			ID:          t.ID,
+			Name:        t.Name + "@" + t.Version,
+			Description: t.Description,
+			Tags:        tags,
+			InputModes:  []string{MediaJSON},
+			OutputModes: []string{MediaJSON},
+		})
+	}
+	return &AgentCard{
+		ProtocolVersion: ProtocolVersion,
+		Name:            "agent-tools registry",
+		Description: "Discover and invoke the tools of an agent-tools registry. " +
+			"Each skill is a tool; send it a data part with the tool input.",
+		URL:                url,
+		PreferredTransport: "JSONRPC",
+		Version:            version,
+		DefaultInputModes:  []string{MediaJSON},
+		DefaultOutputModes: []string{MediaJSON},
+		Skills:             skills,
+	}
+}
+
+// ParseSend extracts the tool and its input from message/send params. The
+// tool is the skill named by "skillId" in the message or request metadata;
+// the input is the message's first data part, or a text part holding a JSON
+// object.
+func ParseSend(p *SendParams) (toolID string, input map[string]any, err error) {
+	toolID, _ = p.Message.Metadata["skillId"].(string)
+	if toolID == "" {
+		toolID, _ = p.Metadata["skillId"].(string)
+	}
+	if toolID == "" {
+		return "", nil, fmt.Errorf("%w: set metadata.skillId to a skill of the agent card", ErrNoSkill)
+	}
+	for _, part := range p.Message.Parts {
+		switch part.Kind {
+		case "data":
+			return toolID, part.Data, nil
+		case "text":
+			if err := json.Unmarshal([]byte(strings.TrimSpace(part.Text)), &input); err == nil && input != nil {
+				return toolID, input, nil
+			}
+		}
+	}
+	return toolID, map[string]any{}, nil
+}
+
+// Completed returns the task of a finished invocation, with its output as
+// the task's artifact.
+func Completed(resp *registry.InvokeResponse, contextID string) *Task {
+	t := newTask(resp.InvocationID, contextID, TaskCompleted, nil)
+	t.Artifacts = []Artifact{{
+		ArtifactID: resp.InvocationID + "-output",
+		Name:       "output",
+		Parts:      []Part{{Kind: "data", Data: resp.Output}},
+	}}
+	t.Metadata = map[string]any{"toolId": resp.ToolID, "durationMs": resp.DurationMS}
+	if resp.CostCLAW != "" {
+		t.Metadata["costClaw"] = resp.CostCLAW
+	}
+	return t
+}
+
+// Failed returns the task of a failed invocation.
+func Failed(invocationID, contextID, reason string) *Task {
+	return newTask(invocationID, contextID, TaskFailed, agentMessage(invocationID, contextID, reason))
+}
+
+// FromInvocation returns the task of a recorded invocation. Outputs are not
+// stored, so completed tasks carry the output hash instead of an artifact.
+func FromInvocation(inv *registry.Invocation) *Task {
+	var t *Task
+	switch inv.Status {
+	case "completed":
+		t = newTask(inv.ID, inv.ID, TaskCompleted, nil)
+	case "queued", "pending":
+		t = newTask(inv.ID, inv.ID, TaskWorking, nil)
+	default:
+		// Failed, and interrupted by a registry shutdown.
+		t = newTask(inv.ID, inv.ID, TaskFailed, agentMessage(inv.ID, inv.ID, inv.Error))
+	}
+	when := inv.StartedAt
+	if inv.CompletedAt != nil {
+		when = *inv.CompletedAt
+	}
+	t.Status.Timestamp = when.UTC().Format(time.RFC3339)
+	t.Metadata = map[string]any{"toolId": inv.ToolID}
+	if inv.OutputHash != "" {
+		t.Metadata["outputHash"] = inv.OutputHash
+	}
+	if inv.CostCLAW != "" {
+		t.Metadata["costClaw"] = inv.CostCLAW
+	}
+	return t
+}
+
+func newTask(id, contextID string, state TaskState, msg *Message) *Task {
+	if contextID == "" {
+		contextID = id
+	}
+	return &Task{
+		Kind:      "task",
+		ID:        id,
+		ContextID: contextID,
+		Status:    TaskStatus{State: state, Message: msg, Timestamp: time.Now().UTC().Format(time.RFC3339)},
+	}
+}
+
+func agentMessage(taskID, contextID, text string) *Message {
+	if contextID == "" {
+		contextID = taskID
+	}
+	return &Message{
+		Kind:      "message",
+		Role:      "agent",
+		MessageID: taskID + "-status",
+		ContextID: contextID,
+		TaskID:    taskID,
+		Parts:     []Part{{Kind: "text", Text: text}},
+	}
+}
@@ -0,0 +1,112 @@
+package a2a_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/a2a"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCard(t *testing.T) {
+	card := a2a.Card("https://registry.example/v1/a2a", "0.1.0", []*registry.Tool{
+		{ID: "did:claw:tool:a", Name: "lint", Version: "1.2.0", Description: "Lint Go", Tags: []string{"go"}},
+		{ID: "did:claw:tool:b", Name: "fmt", Version: "0.1.0"},
+	})
+	assert.Equal(t, a2a.ProtocolVersion, card.ProtocolVersion)
+	assert.Equal(t, "https://registry.example/v1/a2a", card.URL)
+	assert.Equal(t, "JSONRPC", card.PreferredTransport)
+	require.Len(t, card.Skills, 2)
+	assert.Equal(t, a2a.Skill{
+		ID: "did:claw:tool:a", Name: "lint@1.2.0", Description: "Lint Go", Tags: []string{"go"},
+		InputModes: []string{a2a.MediaJSON}, OutputModes: []string{a2a.MediaJSON},
+	}, card.Skills[0])
+	assert.Equal(t, []string{}, card.Skills[1].Tags, "tags are never null")
+
+	card = a2a.Card("https://registry.example/v1/a2a", "0.1.0", nil)
+	assert.Equal(t, []a2a.Skill{}, card.Skills, "skills are never null")
+}
+
+func TestParseSend(t *testing.T) {
+	p := &a2a.SendParams{
+		Message:  a2a.Message{Parts: []a2a.Part{{Kind: "text", Text: "hello"}, {Kind: "text", Text: ` {"q":"go"} `}}},
+		Metadata: map[string]any{"skillId": "tool-1"},
+	}
+	id, input, err := a2a.ParseSend(p)
+	require.NoError(t, err)
+	assert.Equal(t, "tool-1", id)
+	assert.Equal(t, map[string]any{"q": "go"}, input)
+
+	// Message metadata and data parts take precedence.
+	p.Message.Metadata = map[string]any{"skillId": "tool-2"}
+	p.Message.Parts = []a2a.Part{{Kind: "data", Data: map[string]any{"n": 1}}}
+	id, input, err = a2a.ParseSend(p)
+	require.NoError(t, err)
+	assert.Equal(t, "tool-2", id)
+	assert.Equal(t, map[string]any{"n": 1}, input)
+
+	// Text that is not a JSON object is no input.
+	p.Message.Parts = []a2a.Part{{Kind: "text", Text: "lint this"}, {Kind: "text", Text: "[1]"}}
+	_, input, err = a2a.ParseSend(p)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{}, input)
+
+	_, _, err = a2a.ParseSend(&a2a.SendParams{})
+	assert.ErrorIs(t, err, a2a.ErrNoSkill)
+}
+
+func TestCompleted(t *testing.T) {
+	task := a2a.Completed(&registry.InvokeResponse{
+		InvocationID: "inv_1", ToolID: "did:claw:tool:a", Output: map[string]any{"ok": true}, DurationMS: 12, CostCLAW: "0.5",
+	}, "ctx-1")
+	assert.Equal(t, "task", task.Kind)
+	assert.Equal(t, "inv_1", task.ID)
+	assert.Equal(t, "ctx-1", task.ContextID)
+	assert.Equal(t, a2a.TaskCompleted, task.Status.State)
+	assert.Nil(t, task.Status.Message)
+	_, err := time.Parse(time.RFC3339, task.Status.Timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, []a2a.Artifact{{
+		ArtifactID: "inv_1-output", Name: "output", Parts: []a2a.Part{{Kind: "data", Data: map[string]any{"ok": true}}},
+	}}, task.Artifacts)
+	assert.Equal(t, map[string]any{"toolId": "did:claw:tool:a", "durationMs": int64(12), "costClaw": "0.5"}, task.Metadata)
+
+	task = a2a.Completed(&registry.InvokeResponse{InvocationID: "inv_2", ToolID: "did:claw:tool:a"}, "")
+	assert.Equal(t, "inv_2", task.ContextID, "a task without a context is its own")
+	assert.NotContains(t, task.Metadata, "costClaw", "free invocations have no cost")
+}
+
+func TestFailed(t *testing.T) {
+	task := a2a.Failed("inv_1", "", "TIMEOUT: the provider did not answer")
+	assert.Equal(t, a2a.TaskFailed, task.Status.State)
+	assert.Empty(t, task.Artifacts)
+	assert.Equal(t, &a2a.Message{
+		Kind: "message", Role: "agent", MessageID: "inv_1-status", ContextID: "inv_1", TaskID: "inv_1",
+		Parts: []a2a.Part{{Kind: "text", Text: "TIMEOUT: the provider did not answer"}},
+	}, task.Status.Message)
+}
+
+func TestFromInvocation(t *testing.T) {
+	done := time.Unix(1700000000, 0)
+	task := a2a.FromInvocation(&registry.Invocation{ID: "inv_1", ToolID: "t", Status: "interrupted", Error: "shutdown", CompletedAt: &done})
+	assert.Equal(t, a2a.TaskFailed, task.Status.State)
+	assert.Equal(t, "shutdown", task.Status.Message.Parts[0].Text)
+	assert.Equal(t, "2023-11-14T22:13:20Z", task.Status.Timestamp)
+
+	for _, status := range []string{"queued", "pending"} {
+		task = a2a.FromInvocation(&registry.Invocation{ID: "inv_2", Status: status, StartedAt: done})
+		assert.Equal(t, a2a.TaskWorking, task.Status.State, status)
+		assert.Equal(t, "inv_2", task.ContextID)
+		assert.Equal(t, "2023-11-14T22:13:20Z", task.Status.Timestamp, "started, for tasks still working")
+	}
+
+	task = a2a.FromInvocation(&registry.Invocation{
+		ID: "inv_3", ToolID: "t", Status: "completed", OutputHash: "sha256:ab", CostCLAW: "2", CompletedAt: &done,
+	})
+	assert.Equal(t, a2a.TaskCompleted, task.Status.State)
+	assert.Nil(t, task.Status.Message)
+	assert.Empty(t, task.Artifacts, "outputs are not stored")
+	assert.Equal(t, map[string]any{"toolId": "t", "outputHash": "sha256:ab", "costClaw": "2"}, task.Metadata)
+}
@@ -0,0 +1,159 @@
+// Package abuse watches per-consumer traffic for anomalies — spend spikes,
+// catalog scraping and repeated bad requests — and flags offenders for admin
+// review. A flagged consumer is throttled until the flag is cleared.
+package abuse
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"go.uber.org/zap"
+)
+
+// Config holds detection thresholds. A zero threshold disables that check.
+type Config struct {
+	// Window is the period over which catalog reads and bad requests are
+	// counted.
+	Window time.Duration
+	// CatalogReads is the number of distinct catalog URLs one consumer may
+	// fetch per window.
+	CatalogReads int
+	// BadRequests is the number of rejected requests (invalid bodies, inputs
+	// failing a schema, oversized payloads) one consumer may send per window.
+	BadRequests int
+	// SpendFactor is how many times its trailing daily average a consumer's
+	// spend may reach in one day.
+	SpendFactor float64
+	// MinSpend is the daily spend in CLAW below which spikes are ignored.
+	MinSpend float64
+}
+
+// DefaultConfig is used by the serve command unless overridden by flags.
+var DefaultConfig = Config{
+	Window:       time.Hour,
+	CatalogReads: 2000,
+	BadRequests:  200,
+	SpendFactor:  10,
+	MinSpend:     100,
+}
+
+// maxTracked bounds the number of consumers held in memory; expired windows
+// are pruned when it is reached.
+const maxTracked = 10000
+
+// Detector counts per-consumer activity in fixed windows. Counters are kept
+// in memory per replica; flags are stored in the registry and shared.
+type Detector struct {
+	reg *registry.Registry
+	log *zap.Logger
+	cfg Config
+
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	start time.Time
+	urls  map[string]struct{}
+	bad   int
+}
+
+// New creates a Detector. A zero Window defaults to DefaultConfig.Window.
+func New(reg *registry.Registry, log *zap.Logger, cfg Config) *Detector {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultConfig.Window
+	}
+	return &Detector{reg: reg, log: log, cfg: cfg, windows: make(map[string]*window)}
+}
+
+// Standing returns registry.FlagStatusConfirmed for a blocked consumer,
+// registry.FlagStatusOpen for a throttled one and "" otherwise.
+func (d *Detector) Standing(ctx context.Context, consumer string) (string, error) {
+	return d.reg.ConsumerStanding(ctx, consumer)
+}
+
+// CatalogRead records that consumer fetched a catalog URL, flagging it once
+// it has fetched more distinct URLs in the window than allowed.
+func (d *Detector) CatalogRead(ctx context.Context, consumer, url string) {
+	if d.cfg.CatalogReads <= 0 {
+		return
+	}
+	d.mu.Lock()
+	w := d.window(consumer)
+	n := len(w.urls)
+	if n <= d.cfg.CatalogReads {
+		w.urls[url] = struct{}{}
+	}
+	crossed := n == d.cfg.CatalogReads && len(w.urls) > n
+	d.mu.Unlock()
+
+	if crossed {
+		d.flag(ctx, consumer, registry.FlagCatalogScrape,
+			fmt.Sprintf("fetched more than %d distinct catalog URLs within %s", d.cfg.CatalogReads, d.cfg.Window))
+	}
+}
+
+// BadRequest records a rejected request from consumer, flagging it once it
+// has sent more in the window than allowed.
+func (d *Detector) BadRequest(ctx context.Context, consumer string) {
+	if d.cfg.BadRequests <= 0 {
+		return
+	}
+	d.mu.Lock()
+	w := d.window(consumer)
+	w.bad++
+	crossed := w.bad == d.cfg.BadRequests+1
+	d.mu.Unlock()
+
+	if crossed {
+		d.flag(ctx, consumer, registry.FlagBadRequests,
+			fmt.Sprintf("sent more than %d rejected requests within %s", d.cfg.BadRequests, d.cfg.Window))
+	}
+}
+
+// CheckSpend flags consumers whose spend today spiked above their trailing
+// average. It reads rolled-up usage, so it is meant to run periodically after
+// the usage rollup.
+func (d *Detector) CheckSpend(ctx context.Context) error {
+	if d.cfg.SpendFactor <= 0 {
+		return nil
+	}
+	spikes, err := d.reg.SpendSpikes(ctx, time.Now(), d.cfg.SpendFactor, d.cfg.MinSpend)
+	if err != nil {
+		return err
+	}
+	for _, s := range spikes {
+		d.flag(ctx, s.ConsumerID, registry.FlagSpendSpike,
+			fmt.Sprintf("spent %.2f CLAW today against a daily average of %.2f", s.Spend, s.Baseline))
+	}
+	return nil
+}
+
+// window returns the consumer's current window, starting a new one when the
+// previous has expired. d.mu must be held.
+func (d *Detector) window(consumer string) *window {
+	now := time.Now()
+	w, ok := d.windows[consumer]
+	if ok && now.Sub(w.start) < d.cfg.Window {
+		return w
+	}
+	if !ok && len(d.windows) >= maxTracked {
+		for c, old := range d.windows {
+			if now.Sub(old.start) >= d.cfg.Window {
+				delete(d.windows, c)
+			}
+		}
+	}
+	w = &window{start: now, urls: make(map[string]struct{})}
+	d.windows[consumer] = w
+	return w
+}
+
+func (d *Detector) flag(ctx context.Context, consumer, kind, detail string) {
+	if _, err := d.reg.FlagConsumer(ctx, consumer, kind, detail); err != nil {
+		d.log.Error("flag consumer", zap.String("consumer_id", consumer), zap.String("kind", kind), zap.Error(err))
+	}
+}
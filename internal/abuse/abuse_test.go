@@ -0,0 +1,116 @@
+package abuse_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/abuse"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestRegistry(t *testing.T) *registry.Registry {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return registry.New(db, zaptest.NewLogger(t))
+}
+
+func TestDetector_CatalogScrape(t *testing.T) {
+	reg := newTestRegistry(t)
+	d := abuse.New(reg, zaptest.NewLogger(t), abuse.Config{CatalogReads: 3})
+	ctx := context.Background()
+
+	// Repeated fetches of the same URL do not count.
+	for i := 0; i < 10; i++ {
+		d.CatalogRead(ctx, "scraper", "/v1/tools?page=1")
+	}
+	for i := 2; i <= 3; i++ {
+		d.CatalogRead(ctx, "scraper", fmt.Sprintf("/v1/tools?page=%d", i))
+	}
+	standing, err := d.Standing(ctx, "scraper")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+
+	d.CatalogRead(ctx, "scraper", "/v1/tools?page=4")
+	standing, err = d.Standing(ctx, "scraper")
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusOpen, standing)
+
+	flags, err := reg.ListConsumerFlags(ctx, registry.FlagStatusOpen)
+	require.NoError(t, err)
+	require.Len(t, flags, 1)
+	assert.Equal(t, registry.FlagCatalogScrape, flags[0].Kind)
+}
+
+func TestDetector_BadRequests(t *testing.T) {
+	reg := newTestRegistry(t)
+	d := abuse.New(reg, zaptest.NewLogger(t), abuse.Config{BadRequests: 2})
+	ctx := context.Background()
+
+	d.BadRequest(ctx, "fuzzer")
+	d.BadRequest(ctx, "fuzzer")
+	d.BadRequest(ctx, "other")
+	standing, err := d.Standing(ctx, "fuzzer")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+
+	d.BadRequest(ctx, "fuzzer")
+	standing, err = d.Standing(ctx, "fuzzer")
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusOpen, standing)
+	standing, err = d.Standing(ctx, "other")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+}
+
+func TestDetector_WindowExpires(t *testing.T) {
+	reg := newTestRegistry(t)
+	d := abuse.New(reg, zaptest.NewLogger(t), abuse.Config{Window: 20 * time.Millisecond, BadRequests: 1})
+	ctx := context.Background()
+
+	d.BadRequest(ctx, "slow")
+	time.Sleep(30 * time.Millisecond)
+	d.BadRequest(ctx, "slow")
+	standing, err := d.Standing(ctx, "slow")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+}
+
+func TestDetector_CheckSpend(t *testing.T) {
+	reg := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := reg.RegisterTool(ctx, &registry.RegisterToolRequest{
+		Name:       "spendy",
+		Version:    "1.0.0",
+		ProviderID: "did:claw:agent:provider",
+		Endpoint:   "https://example.com/spendy",
+		Schema: registry.ToolSchema{
+			Input:  []byte(`{"type":"object"}`),
+			Output: []byte(`{"type":"object"}`),
+		},
+		Pricing: &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "1"},
+	})
+	require.NoError(t, err)
+	inv, err := reg.RecordInvocation(ctx, tool.ID, "whale", map[string]any{})
+	require.NoError(t, err)
+	require.NoError(t, reg.CompleteInvocation(ctx, inv, "sha256:out", "sig", "500"))
+	require.NoError(t, reg.RollupRecentUsage(ctx))
+
+	require.NoError(t, abuse.New(reg, zaptest.NewLogger(t), abuse.Config{}).CheckSpend(ctx))
+	standing, err := reg.ConsumerStanding(ctx, "whale")
+	require.NoError(t, err)
+	assert.Empty(t, standing, "a zero spend factor disables the check")
+
+	require.NoError(t, abuse.New(reg, zaptest.NewLogger(t), abuse.DefaultConfig).CheckSpend(ctx))
+	standing, err = reg.ConsumerStanding(ctx, "whale")
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusOpen, standing)
+}
@@ -0,0 +1,147 @@
+// Package accesslog provides a rotating file sink for HTTP access logs,
+// kept separate from application logs for retention purposes.
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// backupTimeFormat is appended to rotated file names.
+const backupTimeFormat = "20060102T150405.000"
+
+// Options configures a RotatingFile.
+type Options struct {
+	// MaxBytes rotates the file once it would grow beyond this size (0 = no limit).
+	MaxBytes int64
+	// Interval rotates the file after it has been open this long (0 = never).
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep (0 = keep all).
+	MaxBackups int
+}
+
+// RotatingFile is an io.WriteCloser that rotates by size and age.
+type RotatingFile struct {
+	opened time.Time
+	file   *os.File
+	path   string
+	opts   Options
+	size   int64
+	mu     sync.Mutex
+}
+
+// Open opens (or appends to) path and returns a rotating writer.
+func Open(path string, opts Options) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create access log dir: %w", err)
+	}
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes p, rotating first if a size or age limit would be exceeded.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the current file to disk.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+func (f *RotatingFile) shouldRotate(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxBytes > 0 && f.size+n > f.opts.MaxBytes {
+		return true
+	}
+	return f.opts.Interval > 0 && time.Now().Sub(f.opened) >= f.opts.Interval
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("stat access log: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close access log: %w", err)
+	}
+	backup := f.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// prune removes the oldest backups beyond MaxBackups.
+func (f *RotatingFile) prune() error {
+	if f.opts.MaxBackups <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return err
+	}
+	backups := matches
+	if len(backups) <= f.opts.MaxBackups {
+		return nil
+	}
+	sort.Strings(backups) // timestamp suffix sorts chronologically
+	for _, old := range backups[:len(backups)-f.opts.MaxBackups] {
+		if err := os.Remove(old); err != nil {
+			return fmt.Errorf("prune access log: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewLogger returns a JSON logger that writes every entry to sink.
+func NewLogger(sink zapcore.WriteSyncer) *zap.Logger {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zap.New(zapcore.NewCore(enc, sink, zapcore.InfoLevel))
+}
@@ -0,0 +1,107 @@
+package accesslog_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/accesslog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	m, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	return m
+}
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	f, err := accesslog.Open(path, accesslog.Options{MaxBytes: 10})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	assert.Empty(t, backups(t, path))
+
+	time.Sleep(2 * time.Millisecond) // distinct backup timestamp
+	_, err = f.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.Len(t, backups(t, path), 1)
+
+	cur, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(cur))
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := accesslog.Open(path, accesslog.Options{Interval: time.Millisecond})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+	assert.Len(t, backups(t, path), 1)
+}
+
+func TestRotatingFile_PrunesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := accesslog.Open(path, accesslog.Options{MaxBytes: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	for i := 0; i < 5; i++ {
+		_, err = f.Write([]byte("xx"))
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Len(t, backups(t, path), 2)
+	require.NoError(t, f.Sync())
+}
+
+func TestRotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	f, err := accesslog.Open(path, accesslog.Options{})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old\nnew\n", string(b))
+}
+
+func TestOpen_BadDir(t *testing.T) {
+	blocking := filepath.Join(t.TempDir(), "blocking")
+	require.NoError(t, os.WriteFile(blocking, nil, 0o600))
+	_, err := accesslog.Open(filepath.Join(blocking, "access.log"), accesslog.Options{})
+	assert.Error(t, err)
+}
+
+func TestNewLogger_WritesJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := accesslog.Open(path, accesslog.Options{})
+	require.NoError(t, err)
+
+	log := accesslog.NewLogger(f)
+	log.Info("http")
+	require.NoError(t, log.Sync())
+	require.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "{"))
+	assert.Contains(t, string(b), `"msg":"http"`)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/a2a"
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// maxA2AMessage bounds the body of one A2A request.
+const maxA2AMessage = 4 << 20
+
+// agentCard handles GET /.well-known/agent.json. The card lists the tools
+// of the default namespace as skills and points clients at POST /v1/a2a.
+func (h *Handler) agentCard(w http.ResponseWriter, r *http.Request) {
+	result, err := h.reg.SearchTools(r.Context(), &registry.SearchQuery{Limit: 100})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, a2a.Card(h.externalURL(r, "/v1/a2a"), "0.1.0", result.Tools))
+}
+
+// a2aRPC handles POST /v1/a2a, the A2A JSON-RPC endpoint. message/send
+// invokes the tool named by the message's skill and answers with the
+// finished task; tasks/get reports a past invocation of the caller.
+func (h *Handler) a2aRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxA2AMessage))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	var req a2a.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusOK, a2aError(nil, a2a.CodeParseError, "parse error: "+err.Error()))
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeJSON(w, http.StatusOK, a2aError(req.ID, a2a.CodeInvalidRequest, "invalid request"))
+		return
+	}
+
+	var resp *a2a.Response
+	switch req.Method {
+	case "message/send":
+		resp = h.a2aSend(r, &req)
+	case "tasks/get":
+		resp = h.a2aGetTask(r, &req)
+	case "tasks/cancel":
+		// Invocations finish within message/send, so there is never a
+		// task left to cancel.
+		resp = a2aError(req.ID, a2a.CodeTaskNotCancelable, "task cannot be canceled")
+	case "message/stream", "tasks/resubscribe", "tasks/pushNotificationConfig/set", "tasks/pushNotificationConfig/get":
+		resp = a2aError(req.ID, a2a.CodeUnsupported, req.Method+" is not supported")
+	default:
+		resp = a2aError(req.ID, a2a.CodeMethodNotFound, "method not found: "+req.Method)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) a2aSend(r *http.Request, req *a2a.Request) *a2a.Response {
+	var params a2a.SendParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return a2aError(req.ID, a2a.CodeInvalidParams, "invalid params: "+err.Error())
+	}
+	toolID, input, err := a2a.ParseSend(&params)
+	if err != nil {
+		return a2aError(req.ID, a2a.CodeInvalidParams, err.Error())
+	}
+	if _, err := h.reg.GetTool(r.Context(), toolID); errors.Is(err, registry.ErrNotFound) {
+		return a2aError(req.ID, a2a.CodeInvalidParams, "unknown skill: "+toolID)
+	}
+
+	resp, ierr := h.invoke(r, &registry.InvokeRequest{ToolID: toolID, Input: input})
+	switch {
+	case ierr == nil:
+		return a2aResult(req.ID, a2a.Completed(resp, params.Message.ContextID))
+	case ierr.invocationID != "":
+		return a2aResult(req.ID, a2a.Failed(ierr.invocationID, params.Message.ContextID, ierr.code+": "+ierr.msg))
+	}
+	code := a2a.CodeInvalidParams
+	switch {
+	case ierr.status == http.StatusNotImplemented:
+		code = a2a.CodeUnsupported
+	case ierr.status >= 500:
+		code = a2a.CodeInternalError
+	}
+	return &a2a.Response{
+		JSONRPC: "2.0",
+		ID:      a2aID(req.ID),
+		Error:   &a2a.Error{Code: code, Message: ierr.msg, Data: map[string]string{"code": ierr.code}},
+	}
+}
+
+func (h *Handler) a2aGetTask(r *http.Request, req *a2a.Request) *a2a.Response {
+	var params a2a.TaskQueryParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.ID == "" {
+		return a2aError(req.ID, a2a.CodeInvalidParams, "params.id is required")
+	}
+	inv, err := h.reg.GetInvocation(r.Context(), params.ID)
+	if errors.Is(err, registry.ErrNotFound) || (err == nil && inv.ConsumerID != providerIDFromRequest(r)) {
+		return a2aError(req.ID, a2a.CodeTaskNotFound, "task not found")
+	}
+	if err != nil {
+		return a2aError(req.ID, a2a.CodeInternalError, err.Error())
+	}
+	return a2aResult(req.ID, a2a.FromInvocation(inv))
+}
+
+func a2aResult(id json.RawMessage, result any) *a2a.Response {
+	return &a2a.Response{JSONRPC: "2.0", ID: a2aID(id), Result: result}
+}
+
+func a2aError(id json.RawMessage, code int, msg string) *a2a.Response {
+	return &a2a.Response{JSONRPC: "2.0", ID: a2aID(id), Error: &a2a.Error{Code: code, Message: msg}}
+}
+
+// a2aID answers requests without a usable ID with a null ID, as JSON-RPC
+// requires.
+func a2aID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
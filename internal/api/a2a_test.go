@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/a2a"
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// a2aRuntime echoes its input, or fails when the input asks it to.
+type a2aRuntime struct{}
+
+func (a2aRuntime) Run(_ context.Context, _ sandbox.ContainerSpec, stdin io.Reader, stdout, _ io.Writer) error {
+	b, _ := io.ReadAll(stdin)
+	if strings.Contains(string(b), `"fail"`) {
+		return errors.New("tool crashed")
+	}
+	_, err := stdout.Write(b)
+	return err
+}
+
+func newA2AHandler(t *testing.T) (http.Handler, string) {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	reg := registry.New(db, zaptest.NewLogger(t))
+	h := api.NewHandler(reg, zaptest.NewLogger(t), api.WithContainers(sandbox.NewContainers(a2aRuntime{}, sandbox.ContainerLimits{})))
+
+	payload := validToolPayload()
+	payload["endpoint"] = registry.OCIScheme + "ghcr.io/acme/echo@sha256:" + strings.Repeat("ab", 32)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	return h, tool.ID
+}
+
+func a2aCall(t *testing.T, h http.Handler, method string, params any) map[string]any {
+	t.Helper()
+	rr := doRequest(t, h, http.MethodPost, "/v1/a2a", map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	return resp
+}
+
+func sendParams(skill string, input map[string]any) map[string]any {
+	return map[string]any{"message": map[string]any{
+		"kind": "message", "role": "user", "messageId": "m1", "contextId": "ctx-1",
+		"parts":    []any{map[string]any{"kind": "data", "data": input}},
+		"metadata": map[string]any{"skillId": skill},
+	}}
+}
+
+func TestAgentCard(t *testing.T) {
+	h, toolID := newA2AHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/.well-known/agent.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var card a2a.AgentCard
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&card))
+	assert.Equal(t, a2a.ProtocolVersion, card.ProtocolVersion)
+	assert.Equal(t, "http://example.com/v1/a2a", card.URL)
+	require.Len(t, card.Skills, 1)
+	assert.Equal(t, toolID, card.Skills[0].ID)
+	assert.Equal(t, []string{a2a.MediaJSON}, card.Skills[0].InputModes)
+}
+
+func TestA2A_SendAndGet(t *testing.T) {
+	h, toolID := newA2AHandler(t)
+
+	task := a2aCall(t, h, "message/send", sendParams(toolID, map[string]any{"n": 1}))["result"].(map[string]any)
+	assert.Equal(t, "task", task["kind"])
+	assert.Equal(t, "ctx-1", task["contextId"])
+	assert.Equal(t, "completed", task["status"].(map[string]any)["state"])
+	parts := task["artifacts"].([]any)[0].(map[string]any)["parts"].([]any)
+	assert.Equal(t, map[string]any{"n": float64(1)}, parts[0].(map[string]any)["data"])
+
+	got := a2aCall(t, h, "tasks/get", map[string]any{"id": task["id"]})["result"].(map[string]any)
+	assert.Equal(t, "completed", got["status"].(map[string]any)["state"])
+	assert.Contains(t, got["metadata"], "outputHash")
+
+	failed := a2aCall(t, h, "message/send", sendParams(toolID, map[string]any{"mode": "fail"}))["result"].(map[string]any)
+	status := failed["status"].(map[string]any)
+	assert.Equal(t, "failed", status["state"])
+	assert.Contains(t, status["message"].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"], "TOOL_FAILED")
+
+	got = a2aCall(t, h, "tasks/get", map[string]any{"id": failed["id"]})["result"].(map[string]any)
+	assert.Equal(t, "failed", got["status"].(map[string]any)["state"])
+}
+
+func TestA2A_Errors(t *testing.T) {
+	h, toolID := newA2AHandler(t)
+	code := func(resp map[string]any) float64 { return resp["error"].(map[string]any)["code"].(float64) }
+
+	assert.Equal(t, float64(a2a.CodeInvalidParams), code(a2aCall(t, h, "message/send", sendParams("", nil))))
+	assert.Equal(t, float64(a2a.CodeInvalidParams), code(a2aCall(t, h, "message/send", sendParams("nope", nil))))
+	assert.Equal(t, float64(a2a.CodeTaskNotFound), code(a2aCall(t, h, "tasks/get", map[string]any{"id": "inv_missing"})))
+	assert.Equal(t, float64(a2a.CodeTaskNotCancelable), code(a2aCall(t, h, "tasks/cancel", map[string]any{"id": toolID})))
+	assert.Equal(t, float64(a2a.CodeUnsupported), code(a2aCall(t, h, "message/stream", nil)))
+	assert.Equal(t, float64(a2a.CodeMethodNotFound), code(a2aCall(t, h, "agent/dance", nil)))
+	assert.Equal(t, float64(a2a.CodeInvalidParams), code(a2aCall(t, h, "message/send", "hello")))
+	assert.Equal(t, float64(a2a.CodeInvalidParams), code(a2aCall(t, h, "tasks/get", map[string]any{})))
+
+	payload := validToolPayload()
+	payload["name"] = "grpc-tool"
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var grpcTool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&grpcTool))
+	unsupported := a2aCall(t, h, "message/send", sendParams(grpcTool.ID, map[string]any{}))
+	assert.Equal(t, float64(a2a.CodeUnsupported), code(unsupported), "a tool this server cannot invoke")
+	assert.Equal(t, map[string]any{"code": "NOT_IMPLEMENTED"}, unsupported["error"].(map[string]any)["data"])
+
+	raw := func(body any) map[string]any {
+		rr := doRequest(t, h, http.MethodPost, "/v1/a2a", body)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp map[string]any
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+	resp := raw("not an object")
+	assert.Equal(t, float64(a2a.CodeParseError), code(resp))
+	assert.Nil(t, resp["id"], "unparsable requests are answered with a null id")
+	resp = raw(map[string]any{"jsonrpc": "1.0", "id": 7, "method": "tasks/get"})
+	assert.Equal(t, float64(a2a.CodeInvalidRequest), code(resp))
+	assert.Equal(t, float64(7), resp["id"])
+}
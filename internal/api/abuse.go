@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/clawinfra/agent-tools/internal/abuse"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// anonymousConsumer is the identity of callers without an Authorization
+// header. It is shared by everyone, so it is never flagged for scraping or
+// bad requests.
+const anonymousConsumer = "did:claw:agent:anonymous"
+
+// throttleRetryAfter is the Retry-After, in seconds, sent to a consumer whose
+// flag is awaiting review.
+const throttleRetryAfter = 300
+
+// WithAbuseDetector throttles flagged consumers and feeds /v1 traffic to d.
+func WithAbuseDetector(d *abuse.Detector) Option {
+	return func(h *Handler) { h.abuse = d }
+}
+
+// guardConsumer rejects blocked and throttled consumers, and reports catalog
+// reads and rejected requests to the abuse detector against abuseSubject.
+// A caller is refused when either its claimed DID or the subject its
+// traffic counts against is flagged.
+func (h *Handler) guardConsumer(next http.Handler) http.Handler {
+	if h.abuse == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := abuseSubject(r)
+		for _, id := range []string{providerIDFromRequest(r), subject} {
+			if id == "" || id == anonymousConsumer {
+				continue
+			}
+			standing, err := h.abuse.Standing(r.Context(), id)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+				return
+			}
+			switch standing {
+			case registry.FlagStatusConfirmed:
+				writeError(w, http.StatusForbidden, "CONSUMER_BLOCKED", "consumer is blocked for abuse; contact the operator")
+				return
+			case registry.FlagStatusOpen:
+				w.Header().Set("Retry-After", strconv.Itoa(throttleRetryAfter))
+				writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "consumer is throttled pending abuse review")
+				return
+			}
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if subject == "" {
+			return
+		}
+		switch status := ww.Status(); {
+		case r.Method == http.MethodGet && status == http.StatusOK:
+			h.abuse.CatalogRead(r.Context(), subject, r.URL.RequestURI())
+		case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge ||
+			status == http.StatusUnprocessableEntity:
+			h.abuse.BadRequest(r.Context(), subject)
+		}
+	})
+}
+
+// abuseSubject returns who the traffic of r counts against: the owner of a
+// proven API key, or else the client's IP, as "ip:" and the address, since
+// a DID in the Authorization header proves nothing and counting against it
+// would let anyone get that consumer throttled. Anonymous callers, who are
+// rate limited per IP already, count against no one.
+func abuseSubject(r *http.Request) string {
+	if k := apiKeyFrom(r.Context()); k != nil && k.Proven {
+		return k.OwnerID
+	}
+	if providerIDFromRequest(r) == anonymousConsumer {
+		return ""
+	}
+	return "ip:" + clientIP(r)
+}
+
+// listConsumerFlags handles GET /admin/abuse/flags?status=open.
+func (h *Handler) listConsumerFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.reg.ListConsumerFlags(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"flags": flags})
+}
+
+// reviewConsumerFlag handles POST /admin/abuse/flags/{id}/review.
+func (h *Handler) reviewConsumerFlag(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if !decodeBody(w, r, 0, &req) {
+		return
+	}
+	flag, err := h.reg.ReviewConsumerFlag(r.Context(), chi.URLParam(r, "id"), req.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNotFound):
+			writeError(w, http.StatusNotFound, "FLAG_NOT_FOUND", "flag not found")
+		case req.Status != registry.FlagStatusCleared && req.Status != registry.FlagStatusConfirmed:
+			writeError(w, http.StatusBadRequest, "INVALID_REVIEW", err.Error())
+		default:
+			h.logger(r).Error("review consumer flag", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, flag)
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/abuse"
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func consumerRequest(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	return consumerRequestFrom(t, h, path, "did:claw:agent:scraper", "192.0.2.1:1234")
+}
+
+func consumerRequestFrom(t *testing.T, h http.Handler, path, did, remoteAddr string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+did)
+	req.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAbuse_ThrottleAndReview(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	reg := registry.New(db, zaptest.NewLogger(t))
+	d := abuse.New(reg, zaptest.NewLogger(t), abuse.Config{CatalogReads: 2})
+	h := api.NewHandler(reg, zaptest.NewLogger(t), api.WithAdminToken("s3cret"), api.WithAbuseDetector(d))
+
+	for page := 1; page <= 3; page++ {
+		rr := consumerRequest(t, h, fmt.Sprintf("/v1/tools?page=%d", page))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	rr := consumerRequest(t, h, "/v1/tools?page=4")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "300", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "RATE_LIMITED")
+
+	// Callers from other addresses are not affected by the flag.
+	assert.Equal(t, http.StatusOK, consumerRequestFrom(t, h, "/v1/tools?page=4", testCaller, "198.51.100.7:1234").Code)
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/abuse/flags?status=open", "s3cret", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Flags []registry.ConsumerFlag `json:"flags"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Flags, 1)
+	flag := list.Flags[0]
+	assert.Equal(t, "ip:192.0.2.1", flag.ConsumerID, "a bare DID proves nothing; the client IP is counted")
+	assert.Equal(t, registry.FlagCatalogScrape, flag.Kind)
+
+	rr = adminRequest(t, h, http.MethodPost, "/admin/abuse/flags/"+flag.ID+"/review", "s3cret", `{"status":"confirmed"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = consumerRequest(t, h, "/v1/tools")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "CONSUMER_BLOCKED")
+
+	rr = adminRequest(t, h, http.MethodPost, "/admin/abuse/flags/"+flag.ID+"/review", "s3cret", `{"status":"cleared"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusOK, consumerRequest(t, h, "/v1/tools").Code)
+}
+
+func TestAbuse_SpoofedDIDNotFlagged(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	reg := registry.New(db, zaptest.NewLogger(t))
+	d := abuse.New(reg, zaptest.NewLogger(t), abuse.Config{CatalogReads: 2})
+	h := api.NewHandler(reg, zaptest.NewLogger(t), api.WithAbuseDetector(d))
+	const victim = "did:claw:agent:victim"
+
+	for page := 1; page <= 4; page++ {
+		consumerRequestFrom(t, h, fmt.Sprintf("/v1/tools?page=%d", page), victim, "203.0.113.9:4321")
+	}
+	rr := consumerRequestFrom(t, h, "/v1/tools?page=5", victim, "203.0.113.9:4321")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "the spoofing address is throttled")
+
+	flags, err := reg.ListConsumerFlags(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, flags, 1)
+	assert.Equal(t, "ip:203.0.113.9", flags[0].ConsumerID)
+	rr = consumerRequestFrom(t, h, "/v1/tools", victim, "198.51.100.7:1234")
+	assert.Equal(t, http.StatusOK, rr.Code, "the victim is not flagged")
+}
+
+func TestAbuse_ReviewErrors(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPost, "/admin/abuse/flags/flag_missing/review", "s3cret", `{"status":"cleared"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = adminRequest(t, h, http.MethodPost, "/admin/abuse/flags/flag_missing/review", "s3cret", `{"status":"open"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_REVIEW")
+	rr = adminRequest(t, h, http.MethodGet, "/admin/abuse/flags", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
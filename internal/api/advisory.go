@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// putAdvisory handles PUT /v1/tools/{id}/advisory by the tool's provider.
+func (h *Handler) putAdvisory(w http.ResponseWriter, r *http.Request) {
+	var a registry.Advisory
+	if decodeBody(w, r, 0, &a) {
+		h.setAdvisory(w, r, providerIDFromRequest(r), &a)
+	}
+}
+
+// deleteAdvisory handles DELETE /v1/tools/{id}/advisory.
+func (h *Handler) deleteAdvisory(w http.ResponseWriter, r *http.Request) {
+	h.setAdvisory(w, r, providerIDFromRequest(r), nil)
+}
+
+// adminPutAdvisory handles PUT /admin/tools/{id}/advisory, for any tool.
+func (h *Handler) adminPutAdvisory(w http.ResponseWriter, r *http.Request) {
+	var a registry.Advisory
+	if decodeBody(w, r, 0, &a) {
+		h.setAdvisory(w, r, "", &a)
+	}
+}
+
+// adminDeleteAdvisory handles DELETE /admin/tools/{id}/advisory.
+func (h *Handler) adminDeleteAdvisory(w http.ResponseWriter, r *http.Request) {
+	h.setAdvisory(w, r, "", nil)
+}
+
+func (h *Handler) setAdvisory(w http.ResponseWriter, r *http.Request, owner string, a *registry.Advisory) {
+	err := h.reg.SetAdvisory(r.Context(), chi.URLParam(r, "id"), owner, a)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", err.Error())
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrInvalidAdvisory):
+		writeError(w, http.StatusBadRequest, "INVALID_ADVISORY", err.Error())
+	default:
+		h.logger(r).Error("set advisory", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
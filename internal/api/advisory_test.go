@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisory_API(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tool))
+	id := tool["id"].(string)
+
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+id+"/advisory", map[string]any{"status": "vulnerable", "note": "CVE-1"})
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+id, nil)
+	assert.Contains(t, rr.Body.String(), "CVE-1")
+
+	rr = adminRequest(t, h, http.MethodPut, "/admin/tools/"+id+"/advisory", "s3cret", `{"status":"revoked","note":"exfiltrates input"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{}})
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_REVOKED")
+	assert.Contains(t, rr.Body.String(), "exfiltrates input")
+
+	rr = doRequest(t, h, http.MethodDelete, "/v1/tools/"+id+"/advisory", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "providers cannot lift an admin revocation")
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+id+"/advisory", map[string]any{"status": "gone"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = adminRequest(t, h, http.MethodDelete, "/admin/tools/"+id+"/advisory", "s3cret", "")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{}})
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Anonymous access modes, for callers without an Authorization header.
+const (
+	AnonymousOff      = "off"       // every /v1 request needs auth
+	AnonymousReadOnly = "read-only" // searches and gets only, rate limited per client IP
+	AnonymousFull     = "full"      // anonymous callers may also register tools and invoke
+)
+
+// AnonymousPolicy controls what unauthenticated callers may do.
+type AnonymousPolicy struct {
+	Mode string
+	// PerMinute is the sustained request rate allowed per client IP, with
+	// bursts up to Burst. Zero disables the limit.
+	PerMinute int
+	Burst     int
+}
+
+// DefaultAnonymousPolicy applies unless WithAnonymous is used.
+var DefaultAnonymousPolicy = AnonymousPolicy{Mode: AnonymousReadOnly, PerMinute: 60, Burst: 30}
+
+// WithAnonymous sets the policy for unauthenticated callers.
+func WithAnonymous(p AnonymousPolicy) Option {
+	return func(h *Handler) { h.anonymous.set(p) }
+}
+
+// SetAnonymous replaces the anonymous policy of a running handler. Client
+// IPs keep their buckets unless the rate or burst changes.
+func (h *Handler) SetAnonymous(p AnonymousPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	h.anonymous.set(p)
+	return nil
+}
+
+// anonymousAccess holds the anonymous policy and its limiter so they can be
+// swapped while serving.
+type anonymousAccess struct {
+	mu      sync.Mutex
+	current atomic.Pointer[anonymousState]
+}
+
+type anonymousState struct {
+	AnonymousPolicy
+	limiter *ipLimiter
+}
+
+func (a *anonymousAccess) set(p AnonymousPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := &anonymousState{AnonymousPolicy: p}
+	if old := a.current.Load(); old != nil && old.PerMinute == p.PerMinute && old.Burst == p.Burst {
+		st.limiter = old.limiter
+	} else {
+		st.limiter = newIPLimiter(p.PerMinute, p.Burst)
+	}
+	a.current.Store(st)
+}
+
+// Validate checks the mode.
+func (p AnonymousPolicy) Validate() error {
+	switch p.Mode {
+	case AnonymousOff, AnonymousReadOnly, AnonymousFull:
+		return nil
+	}
+	return fmt.Errorf("unknown anonymous mode %q (want %s, %s or %s)", p.Mode, AnonymousOff, AnonymousReadOnly, AnonymousFull)
+}
+
+// restrictAnonymous applies the anonymous policy to /v1 requests without an
+// identity. Authenticated requests pass untouched.
+func (h *Handler) restrictAnonymous(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if providerIDFromRequest(r) != anonymousConsumer {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := h.anonymous.current.Load()
+		switch policy.Mode {
+		case AnonymousFull:
+		case AnonymousReadOnly:
+			if !isReadOnlyMethod(r.Method) {
+				unauthorized(w, "anonymous callers may only search and read tools; send Authorization")
+				return
+			}
+		default:
+			unauthorized(w, "anonymous access is disabled; send Authorization")
+			return
+		}
+		wait, left := policy.limiter.take(clientIP(r))
+		if left >= 0 || wait > 0 {
+			// Callers see the limit coming before they hit it.
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(policy.Burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(left, 0)))
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "anonymous rate limit exceeded; authenticate for higher limits")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", msg)
+}
+
+// clientIP is the caller's address without the port. RemoteAddr has already
+// been rewritten by middleware.RealIP when a proxy sets X-Real-IP.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// maxBuckets bounds the number of client IPs tracked; idle buckets that have
+// refilled are dropped when it is reached.
+const maxBuckets = 10000
+
+// ipLimiter is a token bucket per client IP.
+type ipLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	rate    float64 // tokens per second
+	burst   float64
+}
+
+type bucket struct {
+	last   time.Time
+	tokens float64
+}
+
+func newIPLimiter(perMinute, burst int) *ipLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &ipLimiter{buckets: make(map[string]*bucket), rate: float64(perMinute) / 60, burst: float64(burst)}
+}
+
+// take consumes a token for ip and returns zero and the whole tokens left,
+// or how long until a token is available when the bucket is empty. A nil
+// limiter allows everything and returns -1 tokens.
+func (l *ipLimiter) take(ip string) (time.Duration, int) {
+	if l == nil {
+		return 0, -1
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &bucket{last: now, tokens: l.burst}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), 0
+	}
+	b.tokens--
+	return 0, int(b.tokens)
+}
+
+func (l *ipLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, ip)
+		}
+	}
+}
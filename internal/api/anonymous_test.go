@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func anonRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAnonymous_ReadOnlyByDefault(t *testing.T) {
+	h := newProxiedHandler(t)
+
+	assert.Equal(t, http.StatusOK, anonRequest(h, http.MethodGet, "/v1/tools", "").Code)
+	assert.Equal(t, http.StatusOK, anonRequest(h, http.MethodGet, "/v1/tools/search?q=x", "").Code)
+
+	rr := anonRequest(h, http.MethodPost, "/v1/tools", mustEncode(t, validToolPayload()).String())
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+	assert.Contains(t, rr.Body.String(), "UNAUTHORIZED")
+	assert.Equal(t, http.StatusUnauthorized, anonRequest(h, http.MethodPost, "/v1/invoke", "{}").Code)
+
+	// Health stays open to everyone.
+	assert.Equal(t, http.StatusOK, anonRequest(h, http.MethodGet, "/healthz", "").Code)
+}
+
+func TestAnonymous_RateLimited(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAnonymous(api.AnonymousPolicy{Mode: api.AnonymousReadOnly, PerMinute: 1, Burst: 2}))
+
+	for i := 0; i < 2; i++ {
+		rr := anonRequest(h, http.MethodGet, "/v1/tools", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(1-i), rr.Header().Get("X-RateLimit-Remaining"))
+	}
+	rr := anonRequest(h, http.MethodGet, "/v1/tools", "")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+
+	// Authenticated callers are not subject to the anonymous limit.
+	assert.Equal(t, http.StatusOK, doRequest(t, h, http.MethodGet, "/v1/tools", nil).Code)
+}
+
+func TestAnonymous_Modes(t *testing.T) {
+	off := newProxiedHandler(t, api.WithAnonymous(api.AnonymousPolicy{Mode: api.AnonymousOff}))
+	assert.Equal(t, http.StatusUnauthorized, anonRequest(off, http.MethodGet, "/v1/tools", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, off, http.MethodGet, "/v1/tools", nil).Code)
+
+	full := newProxiedHandler(t, api.WithAnonymous(api.AnonymousPolicy{Mode: api.AnonymousFull}))
+	rr := anonRequest(full, http.MethodPost, "/v1/tools", mustEncode(t, validToolPayload()).String())
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	assert.Error(t, api.AnonymousPolicy{Mode: "sometimes"}.Validate())
+	assert.NoError(t, api.DefaultAnonymousPolicy.Validate())
+}
+
+func TestSetAnonymous(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAnonymous(api.AnonymousPolicy{Mode: api.AnonymousReadOnly, PerMinute: 1, Burst: 1}))
+	assert.Equal(t, http.StatusOK, anonRequest(h, http.MethodGet, "/v1/tools", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, anonRequest(h, http.MethodGet, "/v1/tools", "").Code)
+
+	require.NoError(t, h.SetAnonymous(api.AnonymousPolicy{Mode: api.AnonymousOff, PerMinute: 1, Burst: 1}))
+	assert.Equal(t, http.StatusUnauthorized, anonRequest(h, http.MethodGet, "/v1/tools", "").Code)
+
+	require.NoError(t, h.SetAnonymous(api.AnonymousPolicy{Mode: api.AnonymousFull}))
+	rr := anonRequest(h, http.MethodPost, "/v1/tools", mustEncode(t, validToolPayload()).String())
+	assert.Equal(t, http.StatusCreated, rr.Code, "the new rate applies at once")
+	assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+
+	assert.Error(t, h.SetAnonymous(api.AnonymousPolicy{Mode: "sometimes"}))
+}
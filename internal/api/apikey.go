@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiKeyHeader carries an API key, for clients that keep Authorization for
+// something else. Keys are also accepted as "Authorization: Bearer atk_...".
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyCtxKey holds the API key a request was authenticated with.
+type apiKeyCtxKey struct{}
+
+// apiKeyFrom returns the API key the request was authenticated with, or nil
+// for requests sent without one.
+func apiKeyFrom(ctx context.Context) *registry.APIKey {
+	k, _ := ctx.Value(apiKeyCtxKey{}).(*registry.APIKey)
+	return k
+}
+
+// apiKeySecret returns the API key secret sent with r, if any.
+func apiKeySecret(r *http.Request) string {
+	if s := r.Header.Get(apiKeyHeader); s != "" {
+		return s
+	}
+	if s, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(s, registry.APIKeyPrefix) {
+		return s
+	}
+	return ""
+}
+
+// authenticateAPIKey resolves the API key of a request, which then acts as
+// the key's owner. Requests without a key pass untouched; those with an
+// unknown or revoked key are refused.
+func (h *Handler) authenticateAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := apiKeySecret(r)
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		k, err := h.reg.AuthenticateAPIKey(r.Context(), secret)
+		switch {
+		case errors.Is(err, registry.ErrInvalidAPIKey):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "INVALID_API_KEY", "unknown or revoked API key")
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, k)))
+	})
+}
+
+// requireScope refuses requests made with an API key that lacks scope.
+// Requests authenticated otherwise pass untouched.
+func (h *Handler) requireScope(scope string) func(http.Handler) http.Handler {
+	return h.scoped(scope, scope)
+}
+
+// scoped is requireScope with read for GET and HEAD requests and write for
+// the others.
+func (h *Handler) scoped(read, write string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := write
+			if isReadOnlyMethod(r.Method) {
+				scope = read
+			}
+			if k := apiKeyFrom(r.Context()); k != nil && !k.HasScope(scope) {
+				writeError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "this API key lacks the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireScopeOrProof is requireScope that also lets proven keys through,
+// which stand for their owner as much as its own signature does.
+func (h *Handler) requireScopeOrProof(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if k := apiKeyFrom(r.Context()); k != nil && !k.Proven && !k.HasScope(scope) {
+				writeError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "this API key lacks the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAdmin reports whether r carries the admin token or an API key with the
+// admin scope.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	if k := apiKeyFrom(r.Context()); k != nil {
+		return k.HasScope(registry.ScopeAdmin)
+	}
+	got := r.Header.Get(adminTokenHeader)
+	return h.adminToken != "" && subtle.ConstantTimeCompare([]byte(got), []byte(h.adminToken)) == 1
+}
+
+// createAPIKey handles POST /v1/keys: a key acting as the caller. Keys with
+// the admin scope are only created for admins.
+func (h *Handler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	owner := providerIDFromRequest(r)
+	if owner == anonymousConsumer {
+		unauthorized(w, "API keys are created for an identity; send Authorization")
+		return
+	}
+	if slices.Contains(req.Scopes, registry.ScopeAdmin) && !h.isAdmin(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only admins may create keys with the admin scope")
+		return
+	}
+	k, err := h.reg.CreateAPIKey(r.Context(), owner, req.Name, req.Scopes)
+	if errors.Is(err, registry.ErrInvalidScope) {
+		writeError(w, http.StatusBadRequest, "INVALID_SCOPE", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, k)
+}
+
+// listAPIKeys handles GET /v1/keys: the caller's keys, without secrets.
+// Proven keys are only listed for callers proving the same identity, or
+// admins.
+func (h *Handler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.reg.ListAPIKeys(r.Context(), providerIDFromRequest(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if !provenCaller(r) && !h.isAdmin(r) {
+		keys = slices.DeleteFunc(keys, func(k *registry.APIKey) bool { return k.Proven })
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+// revokeAPIKey handles DELETE /v1/keys/{id}. Proven keys are only revoked
+// by callers proving the same identity, or admins.
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	owner, id := providerIDFromRequest(r), chi.URLParam(r, "id")
+	k, err := h.reg.GetAPIKey(r.Context(), owner, id)
+	if err == nil && k.Proven && !provenCaller(r) && !h.isAdmin(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "proven keys are revoked with a proven key or by admins")
+		return
+	}
+	if err == nil {
+		err = h.reg.RevokeAPIKey(r.Context(), owner, id)
+	}
+	if errors.Is(err, registry.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "KEY_NOT_FOUND", "no such key of the caller")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doWithKey sends a request authenticated with the API key secret.
+func doWithKey(t *testing.T, h http.Handler, method, path, secret string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, mustEncode(t, body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", secret)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPIKeys(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+	createKey := func(scopes ...string) *registry.APIKey {
+		rr := doRequest(t, h, http.MethodPost, "/v1/keys", map[string]any{"name": "ci", "scopes": scopes})
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var k registry.APIKey
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&k))
+		require.NotEmpty(t, k.Secret)
+		return &k
+	}
+
+	reader := createKey(registry.ScopeToolsRead)
+	writer := createKey(registry.ScopeToolsWrite, registry.ScopeToolsRead)
+
+	rr := doWithKey(t, h, http.MethodPost, "/v1/tools", writer.Secret, validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	assert.Equal(t, testCaller, tool.ProviderID, "a key acts as its owner")
+
+	rr = doWithKey(t, h, http.MethodGet, "/v1/tools/"+tool.ID, reader.Secret, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = doWithKey(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, reader.Secret, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INSUFFICIENT_SCOPE")
+	rr = doWithKey(t, h, http.MethodPost, "/v1/invoke", writer.Secret, map[string]any{"tool_id": tool.ID, "input": map[string]any{}})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doWithKey(t, h, http.MethodGet, "/v1/keys", writer.Secret, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "keys cannot manage keys without the admin scope")
+
+	// Keys are also accepted as bearer tokens.
+	req := httptest.NewRequest(http.MethodGet, "/v1/tools/"+tool.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+reader.Secret)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/keys", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Keys []*registry.APIKey `json:"keys"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Keys, 2)
+	assert.Empty(t, list.Keys[0].Secret)
+
+	rr = doRequest(t, h, http.MethodDelete, "/v1/keys/"+reader.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doWithKey(t, h, http.MethodGet, "/v1/tools/"+tool.ID, reader.Secret, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_API_KEY")
+	rr = doRequest(t, h, http.MethodDelete, "/v1/keys/key_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/keys", map[string]any{"scopes": []string{"root"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_SCOPE")
+}
+
+func TestAPIKeys_Admin(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+	rr := doRequest(t, h, http.MethodPost, "/v1/keys", map[string]any{"scopes": []string{registry.ScopeAdmin}})
+	assert.Equal(t, http.StatusForbidden, rr.Code, "only admins hand out the admin scope")
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/keys", mustEncode(t, map[string]any{"scopes": []string{registry.ScopeAdmin}}))
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	req.Header.Set("X-Admin-Token", "s3cret")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var admin registry.APIKey
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&admin))
+
+	rr = doWithKey(t, h, http.MethodGet, "/admin/maintenance", admin.Secret, nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "admin keys stand in for the admin token")
+	rr = doWithKey(t, h, http.MethodPost, "/v1/keys", admin.Secret, map[string]any{"scopes": []string{registry.ScopeInvoke}})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/keys", map[string]any{"scopes": []string{registry.ScopeInvoke}})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var invoker registry.APIKey
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&invoker))
+	rr = doWithKey(t, h, http.MethodGet, "/admin/maintenance", invoker.Secret, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
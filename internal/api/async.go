@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// errDraining puts a job back in the queue when the server is shutting down.
+var errDraining = errors.New("server is shutting down")
+
+// WithJobQueue enables async invocations (POST /v1/invoke?mode=async),
+// queued in q and run by RunJobs.
+func WithJobQueue(q *jobs.Queue) Option {
+	return func(h *Handler) { h.jobs = q }
+}
+
+// WithWebhooks lets async invocations name a callback_url, to which d
+// delivers their outcome.
+func WithWebhooks(d *webhooks.Dispatcher) Option {
+	return func(h *Handler) { h.webhooks = d }
+}
+
+// asyncJob is the payload of a queued invocation; the job ID is the
+// invocation ID.
+type asyncJob struct {
+	ToolID       string            `json:"tool_id"`
+	ConsumerID   string            `json:"consumer_id"`
+	Input        json.RawMessage   `json:"input"`
+	StorePayload string            `json:"store_payload,omitempty"`
+	BudgetCLAW   string            `json:"budget_claw,omitempty"`
+	Metadata     registry.Metadata `json:"metadata,omitempty"`
+	// ConsumerSignature is the encoded consumer signature checked when the
+	// invocation was queued, for the provider.
+	ConsumerSignature string `json:"consumer_signature,omitempty"`
+	// Callback is set when the outcome is to be delivered to a callback
+	// registered with the webhook dispatcher.
+	Callback bool `json:"callback,omitempty"`
+}
+
+// queuedInvocation answers POST /v1/invoke?mode=async.
+type queuedInvocation struct {
+	InvocationID string `json:"invocation_id"`
+	ToolID       string `json:"tool_id"`
+	Status       string `json:"status"`
+	Priority     string `json:"priority"`
+}
+
+// invocationStatus is an invocation record with, once an async invocation
+// has finished, its output or the code of its error.
+type invocationStatus struct {
+	*registry.Invocation
+	Output     map[string]any `json:"output,omitempty"`
+	DurationMS int64          `json:"duration_ms,omitempty"`
+	ErrorCode  string         `json:"error_code,omitempty"`
+	// Callback is the delivery of the invocation's callback_url.
+	Callback *webhooks.Delivery `json:"callback,omitempty"`
+}
+
+// enqueue records an invocation as queued and adds it to the job queue.
+// Requests a tool could never serve, or over the caller's quota, fail at
+// once as they would synchronously.
+func (h *Handler) enqueue(r *http.Request, req *registry.InvokeRequest) (*queuedInvocation, *invokeError) {
+	if h.jobs == nil {
+		return nil, &invokeError{status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED",
+			msg: "async invocations are not enabled on this server"}
+	}
+	if ierr := h.checkCallback(req); ierr != nil {
+		return nil, ierr
+	}
+	if req.IdempotencyKey != "" {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY",
+			msg: "idempotency_key is not supported by async invocations; poll the queued invocation instead"}
+	}
+	priority, err := jobs.ParsePriority(req.Priority)
+	if err != nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	tool, _, ierr := h.resolve(r, req.ToolID)
+	if ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = withPayloadStorage(r, tool, req); ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = withMetadata(r, req); ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = h.withConsumerSignature(r, tool, req); ierr != nil {
+		return nil, ierr
+	}
+	r = r.WithContext(registry.WithQueued(r.Context()))
+	id, input, ierr := h.startInvocation(r, tool, req)
+	if ierr != nil {
+		return nil, ierr
+	}
+	payload, err := json.Marshal(asyncJob{
+		ToolID:            tool.ID,
+		ConsumerID:        providerIDFromRequest(r),
+		Input:             input,
+		StorePayload:      req.StorePayload,
+		BudgetCLAW:        req.BudgetCLAW,
+		Metadata:          req.Metadata,
+		Callback:          req.CallbackURL != "",
+		ConsumerSignature: consumerSignatureFrom(r.Context()),
+	})
+	if err == nil && req.CallbackURL != "" {
+		err = h.webhooks.Register(r.Context(), id, req.CallbackURL, []byte(req.CallbackSecret))
+	}
+	if err == nil {
+		err = h.jobs.Enqueue(r.Context(), id, registry.NamespaceFrom(r.Context()), priority, payload)
+	}
+	if err != nil {
+		if ferr := h.reg.FailInvocation(r.Context(), id, err.Error()); ferr != nil {
+			h.logger(r).Error("fail invocation", zap.String("invocation_id", id), zap.Error(ferr))
+		}
+		return nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error(), invocationID: id}
+	}
+	return &queuedInvocation{InvocationID: id, ToolID: tool.ID, Status: "queued", Priority: priority.String()}, nil
+}
+
+// checkCallback validates the callback_url of an async invocation, which
+// needs a secret to sign its deliveries with.
+func (h *Handler) checkCallback(req *registry.InvokeRequest) *invokeError {
+	if req.CallbackURL == "" {
+		return nil
+	}
+	if h.webhooks == nil {
+		return &invokeError{status: http.StatusBadRequest, code: "CALLBACK_UNAVAILABLE",
+			msg: "callbacks need a secrets key configured on the registry"}
+	}
+	if req.CallbackSecret == "" {
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "callback_secret is required with callback_url"}
+	}
+	if err := h.reg.CheckCallbackURL(req.CallbackURL); err != nil {
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_ENDPOINT", msg: err.Error()}
+	}
+	return nil
+}
+
+// RunJobs runs queued async invocations on workers goroutines until ctx is
+// done, high priority ones first and each priority within its shares of the
+// workers. It does nothing unless a job queue was configured.
+func (h *Handler) RunJobs(ctx context.Context, workers int, shares jobs.Shares) {
+	if h.jobs == nil {
+		return
+	}
+	h.jobs.Run(ctx, workers, shares, h.runJob)
+}
+
+// runJob runs a queued invocation and returns its outcome, in the shape of
+// a batch result, as the job's result. Like other invocations it holds off
+// Drain; once draining it leaves the job queued for the next server.
+func (h *Handler) runJob(ctx context.Context, job *jobs.Job) ([]byte, error) {
+	if !h.inflight.begin() {
+		return nil, errDraining
+	}
+	defer h.inflight.end()
+	ctx = registry.WithNamespace(ctx, job.Namespace)
+	var aj asyncJob
+	if err := json.Unmarshal(job.Payload, &aj); err != nil {
+		// Running it again would not help.
+		h.log.Error("decode job", zap.String("invocation_id", job.ID), zap.Error(err))
+		if err := h.reg.FailInvocation(ctx, job.ID, "invalid job: "+err.Error()); err != nil {
+			h.log.Error("fail invocation", zap.String("invocation_id", job.ID), zap.Error(err))
+		}
+		return nil, nil
+	}
+	started, err := h.reg.StartQueuedInvocation(ctx, job.ID)
+	if err != nil || !started {
+		return nil, err
+	}
+
+	// The invocation runs as a request from its consumer, which is what
+	// the invocation code reads the caller from.
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/invoke", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+aj.ConsumerID)
+	resp, ierr := h.runQueued(r, job.ID, &aj)
+	if aj.Callback {
+		h.deliver(ctx, job.ID, aj.ToolID, resp, ierr)
+	}
+	return json.Marshal(newBatchResult(aj.ToolID, resp, ierr))
+}
+
+// deliver queues the outcome of a finished async invocation for delivery
+// to its callback: the response a synchronous invocation would have got,
+// or, when it failed, its IDs and error.
+func (h *Handler) deliver(ctx context.Context, id, toolID string, resp *registry.InvokeResponse, ierr *invokeError) {
+	var (
+		body []byte
+		err  error
+	)
+	if ierr != nil {
+		body, err = json.Marshal(newBatchResult(toolID, nil, ierr))
+	} else {
+		body, err = json.Marshal(resp)
+	}
+	if err == nil {
+		err = h.webhooks.Deliver(ctx, id, body)
+	}
+	if err != nil {
+		h.log.Error("deliver callback", zap.String("invocation_id", id), zap.Error(err))
+	}
+}
+
+// runQueued runs the started invocation id. A tool that was deactivated or
+// revoked while the invocation waited fails it.
+func (h *Handler) runQueued(r *http.Request, id string, aj *asyncJob) (*registry.InvokeResponse, *invokeError) {
+	tool, run, ierr := h.resolve(r, aj.ToolID)
+	if ierr == nil {
+		r, ierr = withPayloadStorage(r, tool, &registry.InvokeRequest{StorePayload: aj.StorePayload})
+	}
+	if ierr != nil {
+		ierr.invocationID = id
+		if err := h.reg.FailInvocation(r.Context(), id, ierr.msg); err != nil {
+			h.logger(r).Error("fail invocation", zap.String("invocation_id", id), zap.Error(err))
+		}
+		return nil, ierr
+	}
+	r = withBudget(r, &registry.InvokeRequest{BudgetCLAW: aj.BudgetCLAW})
+	r = r.WithContext(withQueuedConsumerSignature(registry.WithMetadata(r.Context(), aj.Metadata), aj.ConsumerSignature))
+	return h.execute(r, tool, run, id, aj.Input)
+}
+
+// invocationStatuses are the statuses GET /v1/invocations filters by.
+var invocationStatuses = []string{"queued", "pending", "completed", "failed", "interrupted", registry.StatusDeadLetter}
+
+// listInvocations handles GET /v1/invocations: the caller's invocations,
+// newest first, optionally of one tool, in one status or holding metadata
+// (?metadata.<key>=<value>), a page at a time.
+func (h *Handler) listInvocations(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	f := registry.InvocationFilter{ConsumerID: providerIDFromRequest(r), ToolID: q.Get("tool_id"), Status: q.Get("status"),
+		Metadata: q.Prefixed("metadata.")}
+	q.Check(f.Status == "" || slices.Contains(invocationStatuses, f.Status), "status",
+		"status must be one of "+strings.Join(invocationStatuses, ", "))
+	if err := f.Metadata.Validate(); err != nil {
+		q.Check(false, "metadata", err.Error())
+	}
+	limit := q.Int("limit", 0)
+	if !q.valid(w) {
+		return
+	}
+	list, err := h.reg.ListInvocations(r.Context(), f, q.Get("cursor"), limit)
+	if errors.Is(err, registry.ErrInvalidCursor) {
+		q.Check(false, "cursor", err.Error())
+		q.valid(w)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// getInvocation handles GET /v1/invocations/{id} by the consumer of the
+// invocation: its record and, once an async invocation has finished, its
+// output or error.
+func (h *Handler) getInvocation(w http.ResponseWriter, r *http.Request) {
+	inv, err := h.reg.GetInvocation(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "invocation not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if inv.ConsumerID != providerIDFromRequest(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only the consumer of an invocation may read it")
+		return
+	}
+	st := invocationStatus{Invocation: inv}
+	if h.jobs != nil {
+		job, err := h.jobs.Get(r.Context(), inv.ID)
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		case len(job.Result) > 0:
+			var res batchResult
+			if err := json.Unmarshal(job.Result, &res); err != nil {
+				h.logger(r).Error("decode job result", zap.String("invocation_id", inv.ID), zap.Error(err))
+				break
+			}
+			st.Output, st.DurationMS = res.Output, res.DurationMS
+			if res.Error != nil {
+				st.ErrorCode = res.Error.Code
+			}
+		}
+	}
+	if h.webhooks != nil {
+		dl, err := h.webhooks.Get(r.Context(), inv.ID)
+		switch {
+		case errors.Is(err, webhooks.ErrNotFound):
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		default:
+			st.Callback = dl
+		}
+	}
+	writeJSON(w, http.StatusOK, st)
+}
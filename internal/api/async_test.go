@@ -0,0 +1,243 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type invocationStatus struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Output     map[string]any `json:"output"`
+	Error      string         `json:"error"`
+	ErrorCode  string         `json:"error_code"`
+	DurationMS int64          `json:"duration_ms"`
+}
+
+func TestInvokeAsync(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(provider.Close)
+	// Jobs run on their own goroutines, so the store must be one database
+	// across connections, which :memory: is not.
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	log := zaptest.NewLogger(t)
+	h := api.NewHandler(registry.New(db, log), log, api.WithJobQueue(jobs.New(db, log, time.Hour)))
+	ok := registerRPCTool(t, h, "ok", provider.URL+"/ok")
+	fail := registerRPCTool(t, h, "fail", provider.URL+"/fail")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke?mode=later", map[string]any{"tool_id": ok})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_QUERY")
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", map[string]any{"tool_id": "did:claw:tool:missing"})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "unknown tools fail at once")
+
+	enqueue := func(toolID string) string {
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", map[string]any{"tool_id": toolID, "input": map[string]any{}})
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		var resp struct {
+			InvocationID string `json:"invocation_id"`
+			Status       string `json:"status"`
+			Priority     string `json:"priority"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		assert.Equal(t, "queued", resp.Status)
+		assert.Equal(t, "normal", resp.Priority)
+		assert.Equal(t, "http://example.com/v1/invocations/"+resp.InvocationID, rr.Header().Get("Location"))
+		return resp.InvocationID
+	}
+	get := func(id string) invocationStatus {
+		rr := doRequest(t, h, http.MethodGet, "/v1/invocations/"+id, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var st invocationStatus
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&st))
+		return st
+	}
+	okID, failID := enqueue(ok), enqueue(fail)
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", map[string]any{"tool_id": ok, "priority": "urgent"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	for name, body := range map[string]map[string]any{
+		"idempotency key": {"tool_id": ok, "idempotency_key": "k1"},
+		"store payload":   {"tool_id": ok, "store_payload": "nobody"},
+		"metadata":        {"tool_id": ok, "metadata": map[string]any{"bad key!": "x"}},
+	} {
+		rr = doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+		assert.Contains(t, rr.Body.String(), "INVALID_BODY", name)
+	}
+	assert.Equal(t, "queued", get(okID).Status, "no worker is running yet")
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?cursor=bogus", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "cursor")
+
+	rr = doAs(t, h, http.MethodGet, "/v1/invocations/"+okID, "did:claw:agent:someone-else", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations/inv_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.RunJobs(ctx, 2, jobs.DefaultShares)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	var st invocationStatus
+	require.Eventually(t, func() bool {
+		st = get(okID)
+		return st.Status == "completed"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]any{"ok": true}, st.Output)
+
+	require.Eventually(t, func() bool {
+		st = get(failID)
+		return st.Status == "failed"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "TOOL_FAILED", st.ErrorCode)
+	assert.NotEmpty(t, st.Error)
+	assert.Nil(t, st.Output)
+}
+
+func TestInvokeAsync_Disabled(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", map[string]any{"tool_id": "did:claw:tool:x"})
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+
+	// Synchronous invocations can be read back too.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	id := registerRPCTool(t, h, "ok", srv.URL)
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations/"+resp.InvocationID, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"status":"completed"`)
+}
+
+func TestInvokeAsync_Callback(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(provider.Close)
+	type callback struct {
+		body, sig, ts string
+	}
+	got := make(chan callback, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- callback{string(body), r.Header.Get(webhooks.SignatureHeader), r.Header.Get(webhooks.TimestampHeader)}
+	}))
+	t.Cleanup(receiver.Close)
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	box, err := secrets.New(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	log := zaptest.NewLogger(t)
+	hooks := webhooks.New(db, log, http.DefaultClient, box, webhooks.Config{})
+	h := api.NewHandler(registry.New(db, log), log,
+		api.WithJobQueue(jobs.New(db, log, time.Hour)), api.WithWebhooks(hooks))
+	id := registerRPCTool(t, h, "ok", provider.URL)
+
+	body := map[string]any{"tool_id": id, "input": map[string]any{}, "callback_url": receiver.URL}
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "a secret is required")
+	body["callback_secret"] = "s3cret"
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "only async invocations call back")
+	assert.Contains(t, rr.Body.String(), "mode=async")
+	body["callback_url"] = "ftp://example.com/hook"
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", body)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ENDPOINT")
+
+	body["callback_url"] = receiver.URL
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", body)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	var queued struct {
+		InvocationID string `json:"invocation_id"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&queued))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	go func() {
+		h.RunJobs(ctx, 1, jobs.DefaultShares)
+		done <- struct{}{}
+	}()
+	go func() {
+		hooks.Run(ctx, 1)
+		done <- struct{}{}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		<-done
+	})
+
+	var cb callback
+	select {
+	case cb = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback")
+	}
+	var resp registry.InvokeResponse
+	require.NoError(t, json.Unmarshal([]byte(cb.body), &resp))
+	assert.Equal(t, queued.InvocationID, resp.InvocationID)
+	assert.Equal(t, map[string]any{"ok": true}, resp.Output)
+	ts, err := strconv.ParseInt(cb.ts, 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.Sign([]byte("s3cret"), ts, []byte(cb.body)), cb.sig)
+
+	require.Eventually(t, func() bool {
+		rr := doRequest(t, h, http.MethodGet, "/v1/invocations/"+queued.InvocationID, nil)
+		return strings.Contains(rr.Body.String(), `"status":"delivered"`)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvokeAsync_CallbackUnavailable(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	log := zaptest.NewLogger(t)
+	h := api.NewHandler(registry.New(db, log), log, api.WithJobQueue(jobs.New(db, log, time.Hour)))
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke?mode=async", map[string]any{
+		"tool_id": "did:claw:tool:x", "callback_url": "https://example.com/hook", "callback_secret": "s3cret",
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "CALLBACK_UNAVAILABLE")
+}
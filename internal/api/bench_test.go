@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"go.uber.org/zap"
+)
+
+// BenchmarkInvoke measures POST /v1/invoke end to end against a JSON-RPC
+// provider that answers immediately, so the registry's own work dominates:
+// resolving the tool, validating input, recording the invocation and
+// signing its receipt.
+func BenchmarkInvoke(b *testing.B) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"output":"ok"}}`, req.ID)
+	}))
+	b.Cleanup(provider.Close)
+
+	db, err := store.Open(":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zap.NewNop()), zap.NewNop())
+
+	payload := validToolPayload()
+	payload["endpoint"] = registry.JSONRPCScheme + provider.URL + "/rpc#run"
+	rr := benchRequest(b, h, "/v1/tools", payload)
+	if rr.Code != http.StatusCreated {
+		b.Fatalf("register: %d %s", rr.Code, rr.Body)
+	}
+	var tool registry.Tool
+	if err := json.NewDecoder(rr.Body).Decode(&tool); err != nil {
+		b.Fatal(err)
+	}
+
+	body := map[string]any{"tool_id": tool.ID, "input": map[string]any{"input": "x"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rr := benchRequest(b, h, "/v1/invoke", body); rr.Code != http.StatusOK {
+			b.Fatalf("invoke: %d %s", rr.Code, rr.Body)
+		}
+	}
+}
+
+func benchRequest(b *testing.B, h http.Handler, path string, body any) *httptest.ResponseRecorder {
+	b.Helper()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		b.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+)
+
+// WithCircuitBreaker makes invocations of tools served over HTTP fail fast,
+// with 503 CIRCUIT_OPEN, at endpoints whose circuit b opened, and exposes
+// the circuits at GET /admin/circuits.
+func WithCircuitBreaker(b *router.Breaker) Option {
+	return func(h *Handler) { h.breaker = b }
+}
+
+// circuitsOpen reports whether every endpoint of tool has its circuit open.
+// Tools not served over HTTP have none.
+func (h *Handler) circuitsOpen(tool *registry.Tool) bool {
+	if h.breaker == nil || !router.Routable(tool.Endpoint) {
+		return false
+	}
+	for _, e := range tool.AllEndpoints() {
+		if !h.breaker.Open(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// listCircuits handles GET /admin/circuits.
+func (h *Handler) listCircuits(w http.ResponseWriter, _ *http.Request) {
+	circuits := h.breaker.Circuits()
+	if circuits == nil {
+		circuits = []router.Circuit{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"circuits": circuits})
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_CircuitBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dead" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	b := router.NewBreaker(router.BreakerConfig{Failures: 2, Cooldown: time.Minute})
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"), api.WithCircuitBreaker(b))
+
+	var ids []string
+	for _, p := range []struct{ did, path, price string }{
+		{"did:claw:agent:dead", "/dead", "1.0"},
+		{"did:claw:agent:live", "/live", "4.0"},
+	} {
+		payload := validToolPayload()
+		payload["name"], payload["endpoint"] = "lookup", srv.URL+p.path
+		payload["pricing"] = map[string]any{"model": "per_call", "amount_claw": p.price}
+		rr := doAs(t, h, http.MethodPost, "/v1/tools", p.did, "", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		ids = append(ids, tool.ID)
+	}
+	dead, live := ids[0], ids[1]
+
+	invoke := func(body map[string]any) *httptest.ResponseRecorder {
+		body["input"] = map[string]any{}
+		return doRequest(t, h, http.MethodPost, "/v1/invoke", body)
+	}
+	for range 2 {
+		rr := invoke(map[string]any{"tool_id": dead})
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	}
+	rr := invoke(map[string]any{"tool_id": dead})
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "CIRCUIT_OPEN")
+
+	rr = invoke(map[string]any{"capability": "lookup"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, live, resp.ToolID, "the cheapest provider's circuit is open")
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/circuits", "s3cret", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Circuits []router.Circuit `json:"circuits"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Circuits, 1)
+	assert.Equal(t, srv.URL+"/dead", list.Circuits[0].Endpoint)
+	assert.Equal(t, router.CircuitOpen, list.Circuits[0].State)
+	assert.Equal(t, 2, list.Circuits[0].Failures)
+}
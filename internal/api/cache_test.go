@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_Cached(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["name"] = "double"
+	payload["endpoint"] = registry.JSONRPCScheme + srv.URL + "/rpc#double"
+	payload["cache"] = map[string]any{"deterministic": true, "ttl_seconds": 60, "price_claw": "0.5"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	require.NotNil(t, tool.Cache)
+
+	invoke := func(n int) registry.InvokeResponse {
+		t.Helper()
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool.ID, "input": map[string]any{"n": n}})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp registry.InvokeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+
+	first := invoke(21)
+	assert.False(t, first.Cached)
+	assert.Equal(t, "5.0", first.CostCLAW)
+
+	again := invoke(21)
+	assert.True(t, again.Cached)
+	assert.Equal(t, "0.5", again.CostCLAW, "cached results cost the cache price")
+	assert.Equal(t, first.Output, again.Output)
+	assert.NotEqual(t, first.InvocationID, again.InvocationID)
+	assert.Equal(t, int32(1), requests.Load(), "the provider is called once")
+
+	assert.False(t, invoke(22).Cached, "other input")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{
+		map[string]any{"tool_id": tool.ID, "input": map[string]any{"n": 21}},
+		map[string]any{"tool_id": tool.ID, "input": map[string]any{"n": 23}},
+	}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var batch struct {
+		Results []struct {
+			Cached bool           `json:"cached"`
+			Output map[string]any `json:"output"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&batch))
+	require.Len(t, batch.Results, 2)
+	assert.True(t, batch.Results[0].Cached)
+	assert.False(t, batch.Results[1].Cached)
+	assert.Equal(t, float64(46), batch.Results[1].Output["n"])
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestInvoke_Deterministic(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["name"] = "double"
+	payload["endpoint"] = registry.JSONRPCScheme + srv.URL + "/rpc#double"
+	payload["deterministic"] = true
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	assert.True(t, tool.Deterministic)
+	assert.Equal(t, &registry.CachePolicy{Deterministic: true, TTLSeconds: 3600}, tool.Cache)
+
+	var first, again registry.InvokeResponse
+	for _, resp := range []*registry.InvokeResponse{&first, &again} {
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool.ID, "input": map[string]any{"n": 2}})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(resp))
+	}
+	assert.False(t, first.CacheHit)
+	assert.Equal(t, "5.0", first.CostCLAW)
+	assert.True(t, again.CacheHit)
+	assert.True(t, again.Cached)
+	assert.Empty(t, again.CostCLAW, "cache hits are free")
+	assert.Equal(t, int32(1), requests.Load())
+}
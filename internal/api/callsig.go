@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+// WithRegistryKey has the registry sign the calls it sends providers with
+// key, in a receipts.CallSignatureHeader, and publish its public key at
+// GET /.well-known/registry-key.
+func WithRegistryKey(key ed25519.PrivateKey) Option {
+	return func(h *Handler) { h.registryKey = key }
+}
+
+type callKey struct{}
+
+// call is the invocation a runner is running, for its provider call to be
+// signed.
+type call struct {
+	invocationID string
+	consumerID   string
+}
+
+// withCall records on ctx that invocation id of consumer is running.
+func withCall(ctx context.Context, id, consumer string) context.Context {
+	return context.WithValue(ctx, callKey{}, call{invocationID: id, consumerID: consumer})
+}
+
+// setCallSignature adds the registry's signature of the call of toolID
+// with input recorded on ctx, if any, to header and returns it. Calls are
+// only signed when the registry has a key.
+func (h *Handler) setCallSignature(ctx context.Context, header http.Header, toolID string, input []byte) http.Header {
+	c, ok := ctx.Value(callKey{}).(call)
+	if !ok || h.registryKey == nil {
+		return header
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	s := receipts.SignCall(c.invocationID, toolID, c.consumerID, json.RawMessage(input), time.Now(), h.registryKey)
+	header.Set(receipts.CallSignatureHeader, receipts.EncodeCallSignature(s))
+	return header
+}
+
+// registryKeyInfo handles GET /.well-known/registry-key: the key providers
+// verify the registry's call signatures against.
+func (h *Handler) registryKeyInfo(w http.ResponseWriter, _ *http.Request) {
+	if h.registryKey == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "the registry does not sign its calls")
+		return
+	}
+	pub := h.registryKey.Public().(ed25519.PublicKey)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"pubkey":           receipts.SigPrefix + hex.EncodeToString(pub),
+		"signature_header": receipts.CallSignatureHeader,
+	})
+}
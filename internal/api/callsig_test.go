@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_RegistrySignature(t *testing.T) {
+	type received struct {
+		header string
+		body   []byte
+	}
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, received{r.Header.Get(receipts.CallSignatureHeader), body})
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	h := newProxiedHandler(t, api.WithRegistryKey(key))
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	rr = doRequest(t, h, http.MethodGet, "/.well-known/registry-key", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var published struct {
+		PubKey string `json:"pubkey"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&published))
+	registryKey, err := receipts.ParsePubKey(published.PubKey)
+	require.NoError(t, err)
+	assert.True(t, registryKey.Equal(pub))
+
+	const consumer = "did:claw:agent:consumer"
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "",
+		map[string]any{"tool_id": tool.ID, "input": map[string]any{"input": "hello"}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+	// The provider checks the call came through the registry, for this
+	// invocation, consumer and input.
+	require.Len(t, got, 1)
+	s, err := receipts.DecodeCallSignature(got[0].header)
+	require.NoError(t, err)
+	require.NoError(t, receipts.VerifyCall(s, json.RawMessage(got[0].body), registryKey, time.Now(), time.Minute))
+	assert.Equal(t, resp.InvocationID, s.InvocationID)
+	assert.Equal(t, tool.ID, s.ToolID)
+	assert.Equal(t, consumer, s.ConsumerID)
+
+	// Without a key, calls are not signed.
+	h = newProxiedHandler(t)
+	rr = doRequest(t, h, http.MethodGet, "/.well-known/registry-key", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
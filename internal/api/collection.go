@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// collectionBundle is a collection version with its tools, everything an
+// agent needs to install it.
+type collectionBundle struct {
+	*registry.Collection
+	Tools []*registry.Tool `json:"tools"`
+	// Unavailable lists tools of the collection that were deactivated,
+	// revoked or deleted since it was published.
+	Unavailable []string `json:"unavailable"`
+}
+
+// createCollection handles POST /v1/collections: it publishes a version of
+// a collection owned by the caller.
+func (h *Handler) createCollection(w http.ResponseWriter, r *http.Request) {
+	var c registry.Collection
+	if !decodeBody(w, r, registerBodyLimit(h.reg.Limits()), &c) {
+		return
+	}
+	c.OwnerID = providerIDFromRequest(r)
+	created, err := h.reg.CreateCollection(r.Context(), &c)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrInvalidCollection):
+			writeError(w, http.StatusBadRequest, "INVALID_COLLECTION", err.Error())
+		case errors.Is(err, registry.ErrForbidden):
+			writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, registry.ErrDuplicate):
+			writeError(w, http.StatusConflict, "DUPLICATE_COLLECTION", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+	h.setLocation(w, r, "v1", "collections", created.Name)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// searchCollections handles GET /v1/collections: the latest version of the
+// collections matching ?q= and ?tag=.
+func (h *Handler) searchCollections(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	limit := q.Int("limit", 0)
+	if !q.valid(w) {
+		return
+	}
+	collections, err := h.reg.SearchCollections(r.Context(), q.Get("q"), q.Get("tag"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"collections": collections})
+}
+
+// getCollection handles GET /v1/collections/{name}: a version of the
+// collection (?version=, the latest by default) with its tools.
+func (h *Handler) getCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := h.reg.GetCollection(r.Context(), chi.URLParam(r, "name"), r.URL.Query().Get("version"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "collection not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	tools, unavailable, err := h.reg.CollectionTools(r.Context(), c)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &collectionBundle{Collection: c, Tools: tools, Unavailable: unavailable})
+}
+
+// collectionVersions handles GET /v1/collections/{name}/versions.
+func (h *Handler) collectionVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.reg.ListCollectionVersions(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "COLLECTION_NOT_FOUND", "collection not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"versions": versions})
+}
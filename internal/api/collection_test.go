@@ -0,0 +1,89 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollections(t *testing.T) {
+	h := newTestHandler(t)
+	slither := registerRPCTool(t, h, "slither", "http://127.0.0.1:1/slither")
+	mythril := registerRPCTool(t, h, "mythril", "http://127.0.0.1:1/mythril")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/collections", map[string]any{
+		"name": "solidity-audit-suite", "version": "1.0.0", "tool_ids": []string{slither}, "tags": []string{"solidity"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.True(t, strings.HasSuffix(rr.Header().Get("Location"), "/v1/collections/solidity-audit-suite"))
+	rr = doRequest(t, h, http.MethodPost, "/v1/collections", map[string]any{
+		"name": "solidity-audit-suite", "version": "1.1.0", "tool_ids": []string{slither, mythril}, "tags": []string{"solidity"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections/solidity-audit-suite", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var bundle struct {
+		Version     string   `json:"version"`
+		OwnerID     string   `json:"owner_id"`
+		Unavailable []string `json:"unavailable"`
+		Tools       []struct {
+			ID string `json:"id"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&bundle))
+	assert.Equal(t, "1.1.0", bundle.Version)
+	assert.Equal(t, testCaller, bundle.OwnerID)
+	require.Len(t, bundle.Tools, 2)
+	assert.Equal(t, mythril, bundle.Tools[1].ID)
+	assert.Empty(t, bundle.Unavailable)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections/solidity-audit-suite?version=1.0.0", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&bundle))
+	assert.Len(t, bundle.Tools, 1)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections/solidity-audit-suite/versions", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var versions struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&versions))
+	require.Len(t, versions.Versions, 2)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections?q=audit&tag=solidity", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var found struct {
+		Collections []struct {
+			Version string `json:"version"`
+		} `json:"collections"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&found))
+	require.Len(t, found.Collections, 1)
+	assert.Equal(t, "1.1.0", found.Collections[0].Version)
+
+	for name, tc := range map[string]struct {
+		did, name, version string
+		status             int
+		code               string
+	}{
+		"invalid":   {testCaller, "Bad Name", "1", http.StatusBadRequest, "INVALID_COLLECTION"},
+		"duplicate": {testCaller, "solidity-audit-suite", "1.0.0", http.StatusConflict, "DUPLICATE_COLLECTION"},
+		"not owner": {"did:claw:agent:other", "solidity-audit-suite", "2", http.StatusForbidden, "FORBIDDEN"},
+	} {
+		body := map[string]any{"name": tc.name, "version": tc.version, "tool_ids": []string{slither}}
+		rr := doAs(t, h, http.MethodPost, "/v1/collections", tc.did, "", body)
+		assert.Equal(t, tc.status, rr.Code, name)
+		assert.Contains(t, rr.Body.String(), tc.code, name)
+	}
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections/missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/collections/missing/versions", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
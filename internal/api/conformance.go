@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// conformanceTimeout bounds one run of a tool's conformance tests.
+const conformanceTimeout = time.Minute
+
+// runConformance runs the conformance tests of tool and saves their outcome,
+// which it returns. Tools the registry cannot call, such as gRPC tools, are
+// not tested and nil is returned.
+func (h *Handler) runConformance(ctx context.Context, tool *registry.Tool) *registry.Conformance {
+	run := h.runner(tool)
+	if run == nil || len(tool.Tests) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, conformanceTimeout)
+	defer cancel()
+	var failures []registry.TestFailure
+	for i := range tool.Tests {
+		if err := h.runTest(ctx, tool, run, &tool.Tests[i]); err != nil {
+			failures = append(failures, registry.TestFailure{Name: tool.Tests[i].Name, Reason: err.Error()})
+		}
+	}
+	c := registry.NewConformance(len(tool.Tests), failures)
+	if err := h.reg.SaveConformance(context.WithoutCancel(ctx), tool, c); err != nil {
+		h.log.Error("save conformance", zap.String("tool_id", tool.ID), zap.Error(err))
+	}
+	return c
+}
+
+// runTest invokes tool with the input of test and checks its output.
+func (h *Handler) runTest(ctx context.Context, tool *registry.Tool, run runFunc, test *registry.ToolTest) error {
+	input := test.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	in, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	out, err := run(ctx, in, time.Duration(tool.TimeoutMS)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	var output map[string]any
+	if err := json.Unmarshal(out, &output); err != nil || output == nil {
+		return errOutput
+	}
+	if err := checkOutput(tool, output); err != nil {
+		return err
+	}
+	return test.Check(output)
+}
+
+// CheckConformance runs the conformance tests of every active tool that has
+// them. It is intended to be run periodically by the serve command.
+func (h *Handler) CheckConformance(ctx context.Context) error {
+	tools, err := h.reg.ConformanceTools(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tool := range tools {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		h.runConformance(registry.WithNamespace(ctx, tool.Namespace), tool)
+	}
+	return nil
+}
+
+// toolHealth handles GET /v1/tools/{id}/health: 200 while the tool passes
+// its conformance tests and meets its SLA, 503 with the reasons otherwise.
+func (h *Handler) toolHealth(w http.ResponseWriter, r *http.Request) {
+	tool, ok := h.toolOr404(w, r)
+	if !ok {
+		return
+	}
+	health := tool.Health()
+	status := http.StatusOK
+	if health.Status == registry.HealthFailing {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]any{
+		"status":         health.Status,
+		"reasons":        health.Reasons,
+		"conformance":    tool.Conformance,
+		"sla_compliance": tool.SLACompliance,
+	})
+}
+
+// Badge colors by conformance state.
+const (
+	badgePassing = "#4c1"
+	badgeFailing = "#e05d44"
+	badgeUnknown = "#9f9f9f"
+)
+
+// conformanceBadge handles GET /v1/tools/{id}/badge.svg: a badge showing
+// whether the tool passes its conformance tests, for providers to embed in
+// their documentation.
+func (h *Handler) conformanceBadge(w http.ResponseWriter, r *http.Request) {
+	tool, ok := h.toolOr404(w, r)
+	if !ok {
+		return
+	}
+	label, color := "untested", badgeUnknown
+	if c := tool.Conformance; c != nil {
+		label, color = c.Status, badgePassing
+		if c.Status == registry.ConformanceFailing {
+			color = badgeFailing
+		}
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, badgeSVG, label, color, label, label)
+}
+
+// badgeSVG is the conformance badge, formatted with its label, color, and
+// label twice more for the title and text.
+const badgeSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="160" height="20" role="img" aria-label="conformance: %s">` +
+	`<rect width="90" height="20" fill="#555"/><rect x="90" width="70" height="20" fill="%s"/>` +
+	`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,sans-serif" font-size="11">` +
+	`<text x="45" y="14">conformance</text><title>%s</title><text x="125" y="14">%s</text></g></svg>`
+
+// toolOr404 returns the tool named by the request's {id}, or writes the
+// error and returns false.
+func (h *Handler) toolOr404(w http.ResponseWriter, r *http.Request) (*registry.Tool, bool) {
+	tool, err := h.reg.GetTool(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", "tool not found")
+			return nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return nil, false
+	}
+	return tool, true
+}
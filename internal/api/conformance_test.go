@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		status := "ok"
+		if broken.Load() {
+			status = "degraded"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "echo": input["q"]})
+	}))
+	defer srv.Close()
+
+	h := newProxiedHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	payload["tests"] = []any{
+		map[string]any{"name": "status", "input": map[string]any{}, "expect": `output.status == "ok"`},
+		map[string]any{"name": "echo", "input": map[string]any{"q": "hi"}, "expect": `output.echo == "hi"`},
+	}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	require.NotNil(t, tool.Conformance, "the tests are run on registration")
+	assert.Equal(t, registry.ConformancePassing, tool.Conformance.Status)
+	assert.Equal(t, 2, tool.Conformance.Passed)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/health", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/badge.svg", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "passing")
+
+	broken.Store(true)
+	require.NoError(t, h.CheckConformance(context.Background()))
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/health", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+	var health struct {
+		Status      string                `json:"status"`
+		Reasons     []string              `json:"reasons"`
+		Conformance *registry.Conformance `json:"conformance"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&health))
+	assert.Equal(t, registry.HealthFailing, health.Status)
+	require.Len(t, health.Reasons, 1)
+	assert.Contains(t, health.Reasons[0], "test status")
+	assert.Equal(t, 1, health.Conformance.Failed)
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/badge.svg", nil)
+	assert.Contains(t, rr.Body.String(), "failing")
+
+	broken.Store(false)
+	require.NoError(t, h.CheckConformance(context.Background()))
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/health", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "health recovers with the tests")
+}
+
+func TestConformance_Errors(t *testing.T) {
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["tests"] = []any{map[string]any{"name": "bad", "expect": `output.x ==`}}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_TESTS")
+
+	// The registry cannot call grpc:// tools, so their tests are not run.
+	payload["tests"] = []any{map[string]any{"name": "ok", "expect": `true`}}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	assert.Nil(t, tool.Conformance)
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/badge.svg", nil)
+	assert.Contains(t, rr.Body.String(), "untested")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:missing/health", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
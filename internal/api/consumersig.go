@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+type consumerSigKey struct{}
+
+// withConsumerSignature checks the consumer signature of req, if any, and
+// records it on the context of r for the router to pass on to the
+// provider.
+func (h *Handler) withConsumerSignature(r *http.Request, tool *registry.Tool, req *registry.InvokeRequest) (*http.Request, *invokeError) {
+	s := req.ConsumerSignature
+	if s == nil {
+		return r, nil
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	err := h.reg.VerifyConsumerSignature(r.Context(), s, tool.ID, providerIDFromRequest(r), input, req.BudgetCLAW)
+	if errors.Is(err, registry.ErrInvalidConsumerSignature) {
+		return nil, &invokeError{status: http.StatusUnauthorized, code: "INVALID_CONSUMER_SIGNATURE", msg: err.Error()}
+	}
+	if err != nil {
+		return nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	return r.WithContext(context.WithValue(r.Context(), consumerSigKey{}, receipts.EncodeRequestSignature(s))), nil
+}
+
+// claimConsumerNonce claims the nonce of the consumer signature of req, if
+// any. startInvocation runs it after idempotency keys are looked up, so a
+// retry replaying the response of a call is not refused as a replay of its
+// signature.
+func (h *Handler) claimConsumerNonce(r *http.Request, req *registry.InvokeRequest) *invokeError {
+	s := req.ConsumerSignature
+	if s == nil || s.Nonce == "" {
+		return nil
+	}
+	err := h.reg.ClaimNonce(r.Context(), s.ConsumerID, s.Nonce, s.SignedAt)
+	if errors.Is(err, registry.ErrReplayedRequest) {
+		return &invokeError{status: http.StatusConflict, code: "REPLAYED_REQUEST", msg: err.Error()}
+	}
+	if err != nil {
+		return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	return nil
+}
+
+// checkPayer refuses a paid invocation, when payments are settled on chain,
+// unless its caller proved who it is: with a consumer signature checked by
+// withConsumerSignature whose nonce was claimed, so it cannot be replayed,
+// or with a proven API key. The invocation is paid from the escrow account
+// of the caller's DID, which the Authorization header alone only claims.
+func (h *Handler) checkPayer(r *http.Request, tool *registry.Tool, req *registry.InvokeRequest) *invokeError {
+	if !h.reg.SettlesOnChain() || tool.Pricing == nil || tool.Pricing.Model == registry.PricingFree || provenCaller(r) {
+		return nil
+	}
+	if s := req.ConsumerSignature; s != nil && s.Nonce != "" && consumerSignatureFrom(r.Context()) != "" {
+		return nil
+	}
+	return &invokeError{status: http.StatusUnauthorized, code: "UNAUTHORIZED",
+		msg: "paid invocations need a consumer_signature with a nonce or a proven API key to pay from the caller's escrow account"}
+}
+
+// consumerSignatureFrom returns the encoded consumer signature recorded on
+// ctx, or "" when there is none.
+func consumerSignatureFrom(ctx context.Context) string {
+	sig, _ := ctx.Value(consumerSigKey{}).(string)
+	return sig
+}
+
+// withQueuedConsumerSignature records sig, the encoded consumer signature
+// checked when an async invocation was queued, on ctx.
+func withQueuedConsumerSignature(ctx context.Context, sig string) context.Context {
+	if sig == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, consumerSigKey{}, sig)
+}
+
+// setConsumerSignature adds the consumer signature recorded on ctx, if
+// any, to header and returns it.
+func setConsumerSignature(ctx context.Context, header http.Header) http.Header {
+	sig := consumerSignatureFrom(ctx)
+	if sig == "" {
+		return header
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(receipts.RequestSignatureHeader, sig)
+	return header
+}
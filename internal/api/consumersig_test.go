@@ -0,0 +1,224 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestInvoke_ConsumerSignature(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(receipts.RequestSignatureHeader))
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"input": "hello"}
+	invoke := func(sig *receipts.RequestSignature, input map[string]any) *httptest.ResponseRecorder {
+		return doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "",
+			map[string]any{"tool_id": tool.ID, "input": input, "budget_claw": "10", "consumer_signature": sig})
+	}
+
+	sig := receipts.SignRequest(tool.ID, consumer, input, "10", time.Now(), key)
+	rr = invoke(sig, input)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Len(t, got, 1)
+	relayed, err := receipts.DecodeRequestSignature(got[0])
+	require.NoError(t, err)
+	require.NoError(t, receipts.VerifyRequest(relayed, input), "the provider verifies what the consumer signed")
+	assert.Equal(t, consumer, relayed.ConsumerID)
+	assert.Equal(t, "10", relayed.BudgetCLAW)
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	for name, tc := range map[string]struct {
+		sig   *receipts.RequestSignature
+		input map[string]any
+	}{
+		"other input":   {sig, map[string]any{"input": "tampered"}},
+		"other tool":    {receipts.SignRequest("did:claw:tool:other", consumer, input, "10", time.Now(), key), input},
+		"other budget":  {receipts.SignRequest(tool.ID, consumer, input, "1000", time.Now(), key), input},
+		"stale":         {receipts.SignRequest(tool.ID, consumer, input, "10", time.Now().Add(-time.Hour), key), input},
+		"not their key": {receipts.SignRequest(tool.ID, consumer, input, "10", time.Now(), other), input},
+	} {
+		rr = invoke(tc.sig, tc.input)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, name)
+		assert.Contains(t, rr.Body.String(), "INVALID_CONSUMER_SIGNATURE", name)
+	}
+
+	rr = invoke(sig, input)
+	assert.Equal(t, http.StatusConflict, rr.Code, "the signature was used")
+	assert.Contains(t, rr.Body.String(), "REPLAYED_REQUEST")
+
+	rr = invoke(nil, input)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{got[0], ""}, got, "unsigned calls carry no signature")
+}
+
+func TestInvoke_ConsumerSignatureIdempotent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"input": "hello"}
+	body := map[string]any{"tool_id": tool.ID, "input": input, "idempotency_key": "k1",
+		"consumer_signature": receipts.SignRequest(tool.ID, consumer, input, "", time.Now(), key)}
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", body)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", body)
+	require.Equal(t, http.StatusOK, rr.Code, "a retry gets the response of the call it replays")
+	assert.Contains(t, rr.Body.String(), `"replayed":true`)
+}
+
+func TestInvokeBatch_ConsumerSignatureReplay(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	double := registerRPCTool(t, h, "double", registry.JSONRPCScheme+srv.URL+"/rpc#double")
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"n": 1}
+	invocation := map[string]any{"tool_id": double, "input": input,
+		"consumer_signature": receipts.SignRequest(double, consumer, input, "", time.Now(), key)}
+	rr := doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", invocation)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke/batch", consumer, "", map[string]any{"invocations": []any{invocation}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "REPLAYED_REQUEST", "a batch does not let a signed request be replayed")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestInvokeBatch_ConsumerSignature(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(receipts.RequestSignatureHeader))
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{"ok": true}})
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	tool := registerRPCTool(t, h, "echo", registry.JSONRPCScheme+srv.URL+"/rpc#echo")
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"n": 1}
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	rr := doAs(t, h, http.MethodPost, "/v1/invoke/batch", consumer, "", map[string]any{"invocations": []any{
+		map[string]any{"tool_id": tool, "input": input,
+			"consumer_signature": receipts.SignRequest(tool, consumer, input, "", time.Now(), key)},
+		map[string]any{"tool_id": tool, "input": input,
+			"consumer_signature": receipts.SignRequest(tool, consumer, input, "", time.Now(), other)},
+	}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var body struct {
+		Results []struct {
+			Error *struct{ Code string } `json:"error"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	require.Len(t, body.Results, 2)
+	assert.Nil(t, body.Results[0].Error)
+	require.NotNil(t, body.Results[1].Error)
+	assert.Equal(t, "INVALID_CONSUMER_SIGNATURE", body.Results[1].Error.Code, "batched signatures are verified")
+	require.Len(t, got, 1, "the invocation with a bad signature is not sent")
+	relayed, err := receipts.DecodeRequestSignature(got[0])
+	require.NoError(t, err, "the provider receives the signature")
+	require.NoError(t, receipts.VerifyRequest(relayed, input))
+}
+
+func TestInvokeAsync_ConsumerSignature(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(receipts.RequestSignatureHeader)
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	log := zaptest.NewLogger(t)
+	h := api.NewHandler(registry.New(db, log), log, api.WithJobQueue(jobs.New(db, log, time.Hour)))
+	tool := registerRPCTool(t, h, "ok", srv.URL)
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"input": "hello"}
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	rr := doAs(t, h, http.MethodPost, "/v1/invoke?mode=async", consumer, "", map[string]any{"tool_id": tool, "input": input,
+		"consumer_signature": receipts.SignRequest(tool, consumer, input, "", time.Now(), other)})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "async signatures are verified when queued")
+	assert.Contains(t, rr.Body.String(), "INVALID_CONSUMER_SIGNATURE")
+
+	sig := receipts.SignRequest(tool, consumer, input, "", time.Now(), key)
+	body := map[string]any{"tool_id": tool, "input": input, "consumer_signature": sig}
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke?mode=async", consumer, "", body)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke?mode=async", consumer, "", body)
+	assert.Equal(t, http.StatusConflict, rr.Code, "the signature was used")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.RunJobs(ctx, 1, jobs.DefaultShares)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	select {
+	case header := <-got:
+		relayed, err := receipts.DecodeRequestSignature(header)
+		require.NoError(t, err, "the provider receives the signature")
+		require.NoError(t, receipts.VerifyRequest(relayed, input))
+		assert.Equal(t, consumer, relayed.ConsumerID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queued invocation did not run")
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+)
+
+// WithRegistryDID names the registry's own DID, served in its DID document.
+// By default it is the did:web of the URL the registry is reached at.
+func WithRegistryDID(id string) Option {
+	return func(h *Handler) { h.registryDID = id }
+}
+
+// didDocument handles GET /.well-known/did.json and GET /v1/did: the DID
+// document of the registry, listing the key it signs calls with, if any, and
+// the endpoints of its API, federation, A2A card and event stream.
+func (h *Handler) didDocument(w http.ResponseWriter, r *http.Request) {
+	id := h.registryDID
+	if id == "" {
+		id = did.WebID(h.externalBase(r))
+	}
+	var keys []ed25519.PublicKey
+	if h.registryKey != nil {
+		keys = append(keys, h.registryKey.Public().(ed25519.PublicKey))
+	}
+	doc := did.NewDocument(id, keys, []did.Service{
+		{ID: "#registry", Type: "AgentToolsRegistry", ServiceEndpoint: h.externalURL(r, "/v1")},
+		{ID: "#federation", Type: "AgentToolsFederation", ServiceEndpoint: h.externalURL(r, "")},
+		{ID: "#a2a", Type: "A2AAgentCard", ServiceEndpoint: h.externalURL(r, "/.well-known/agent.json")},
+		{ID: "#events", Type: "AgentToolsEvents", ServiceEndpoint: h.externalURL(r, "/v1/events")},
+	})
+	w.Header().Set("Content-Type", "application/did+json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,59 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDDocument(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	h := newProxiedHandler(t, api.WithRegistryKey(key))
+
+	rr := doRequest(t, h, http.MethodGet, "/.well-known/did.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "application/did+json", rr.Header().Get("Content-Type"))
+	var doc did.Document
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&doc))
+	assert.Equal(t, "did:web:example.com", doc.ID, "the did:web of the host the registry is reached at")
+	require.Len(t, doc.VerificationMethod, 1)
+	assert.Equal(t, did.Multibase(pub), doc.VerificationMethod[0].PublicKeyMultibase, "the key calls are signed with")
+	assert.Equal(t, []string{doc.VerificationMethod[0].ID}, doc.AssertionMethod)
+	services := map[string]string{}
+	for _, s := range doc.Service {
+		services[s.Type] = s.ServiceEndpoint
+	}
+	assert.Equal(t, "http://example.com/v1", services["AgentToolsRegistry"])
+	assert.Equal(t, "http://example.com", services["AgentToolsFederation"])
+	assert.Equal(t, "http://example.com/.well-known/agent.json", services["A2AAgentCard"])
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/did", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var same did.Document
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&same))
+	assert.Equal(t, doc, same)
+}
+
+func TestDIDDocument_BasePathAndDID(t *testing.T) {
+	h := newProxiedHandler(t, api.WithBasePath("/agent-tools"))
+	rr := doRequest(t, h, http.MethodGet, "/agent-tools/did.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var doc did.Document
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&doc))
+	assert.Equal(t, "did:web:example.com:agent-tools", doc.ID, "resolved at the path's did.json")
+	assert.Empty(t, doc.VerificationMethod, "the registry has no key")
+
+	h = newProxiedHandler(t, api.WithRegistryDID("did:web:registry.example.org"))
+	rr = doRequest(t, h, http.MethodGet, "/.well-known/did.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&doc))
+	assert.Equal(t, "did:web:registry.example.org", doc.ID)
+	assert.Equal(t, "did:web:registry.example.org#registry", doc.Service[0].ID)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// inflight counts running invocations and blocks new ones once draining.
+type inflight struct {
+	idle     chan struct{}
+	mu       sync.Mutex
+	n        int
+	draining bool
+}
+
+// begin registers a new invocation. It returns false when draining.
+func (f *inflight) begin() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return false
+	}
+	f.n++
+	return true
+}
+
+// end marks an invocation finished.
+func (f *inflight) end() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n--
+	if f.n == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// drain stops new invocations and waits for running ones or ctx expiry.
+func (f *inflight) drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.draining = true
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops accepting new invocations (they get 503 SHUTTING_DOWN) and
+// waits until in-flight ones finish or ctx expires, returning ctx.Err() in
+// the latter case.
+func (h *Handler) Drain(ctx context.Context) error {
+	return h.inflight.drain(ctx)
+}
+
+// trackInflight wraps invocation routes so Drain can wait for them.
+func (h *Handler) trackInflight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.inflight.begin() {
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, "SHUTTING_DOWN", "server is shutting down")
+			return
+		}
+		defer h.inflight.end()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDrain_RejectsNewInvocations(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, h.Drain(ctx))
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": "x"})
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "SHUTTING_DOWN")
+
+	// Non-invoke routes keep working while draining.
+	rr = doRequest(t, h, http.MethodGet, "/healthz", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestDrain_WaitsForInflight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &req))
+		close(started)
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{}})
+	}))
+	t.Cleanup(srv.Close)
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t))
+	tool := registerRPCTool(t, h, "slow", registry.JSONRPCScheme+srv.URL+"/rpc#slow")
+
+	invoked := make(chan int, 1)
+	go func() {
+		invoked <- doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool, "input": map[string]any{}}).Code
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.Drain(ctx), context.DeadlineExceeded, "the invocation is still running")
+
+	drained := make(chan error, 1)
+	go func() { drained <- h.Drain(context.Background()) }()
+	close(release)
+	assert.Equal(t, http.StatusOK, <-invoked)
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not return once the invocation finished")
+	}
+}
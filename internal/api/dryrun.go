@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// dryRun checks an invocation for POST /v1/invoke with "dry_run": true:
+// the errors it returns are those the invocation would fail with.
+func (h *Handler) dryRun(r *http.Request, req *registry.InvokeRequest) (*registry.DryRunResponse, *invokeError) {
+	tool, _, ierr := h.resolve(r, req.ToolID)
+	if ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = withPayloadStorage(r, tool, req); ierr != nil {
+		return nil, ierr
+	}
+	if err := req.Metadata.Validate(); err != nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	resp, err := h.reg.DryRun(r.Context(), tool, providerIDFromRequest(r), b, req.BudgetCLAW)
+	switch {
+	case err == nil:
+		return resp, nil
+	case errors.Is(err, registry.ErrQuotaExceeded):
+		return nil, &invokeError{status: http.StatusTooManyRequests, code: "TOOL_QUOTA_EXCEEDED", msg: err.Error(),
+			retryAfter: time.Until(*resp.QuotaResetAt)}
+	case errors.Is(err, registry.ErrLimitExceeded):
+		return nil, &invokeError{status: http.StatusRequestEntityTooLarge, code: "LIMIT_EXCEEDED", msg: err.Error()}
+	case errors.Is(err, registry.ErrInvalidInput):
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_INPUT", msg: err.Error()}
+	case errors.Is(err, registry.ErrPayloadStorage):
+		return nil, &invokeError{status: http.StatusBadRequest, code: "PAYLOAD_STORAGE_UNAVAILABLE", msg: err.Error()}
+	case errors.Is(err, registry.ErrInvalidBudget), errors.Is(err, registry.ErrBudgetExceeded):
+		return nil, budgetError(err)
+	case errors.Is(err, registry.ErrPolicyDenied):
+		return nil, policyError(err)
+	}
+	return nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// putEndpointAuth handles PUT /v1/tools/{id}/auth.
+func (h *Handler) putEndpointAuth(w http.ResponseWriter, r *http.Request) {
+	var auth registry.EndpointAuth
+	if !decodeBody(w, r, 0, &auth) {
+		return
+	}
+	h.setEndpointAuth(w, r, &auth)
+}
+
+// deleteEndpointAuth handles DELETE /v1/tools/{id}/auth.
+func (h *Handler) deleteEndpointAuth(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointAuth(w, r, nil)
+}
+
+func (h *Handler) setEndpointAuth(w http.ResponseWriter, r *http.Request, auth *registry.EndpointAuth) {
+	err := h.reg.SetEndpointAuth(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r), auth)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", err.Error())
+	case errors.Is(err, registry.ErrInvalidAuth):
+		writeError(w, http.StatusBadRequest, "INVALID_AUTH", err.Error())
+	default:
+		h.logger(r).Error("set endpoint auth", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEndpointAuth_API(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	box, err := secrets.New(bytes.Repeat([]byte{1}, secrets.KeySize))
+	require.NoError(t, err)
+	reg := registry.New(db, zaptest.NewLogger(t), registry.WithSecrets(box))
+	h := api.NewHandler(reg, zaptest.NewLogger(t))
+
+	p := validToolPayload()
+	p["auth"] = map[string]any{"header": "X-Api-Key", "template": "{secret}", "secret": "sk-live-123"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "sk-live-123")
+	var tool map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tool))
+	id := tool["id"].(string)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+id, nil)
+	assert.NotContains(t, rr.Body.String(), "sk-live-123")
+
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+id+"/auth", map[string]any{"secret": "rotated"})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	auth, err := reg.EndpointAuth(context.Background(), id)
+	require.NoError(t, err)
+	_, value := auth.Render()
+	assert.Equal(t, "Bearer rotated", value)
+
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+id+"/auth", map[string]any{"secret": "x", "header": "Host"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_AUTH")
+
+	rr = doRequest(t, h, http.MethodDelete, "/v1/tools/"+id+"/auth", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodDelete, "/v1/tools/did:claw:tool:missing/auth", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestEndpointAuth_NoSecretsKey(t *testing.T) {
+	h := newTestHandler(t)
+	p := validToolPayload()
+	p["auth"] = map[string]any{"secret": "sk-live-123"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "no secrets key")
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// eventKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not close it.
+const eventKeepAlive = 30 * time.Second
+
+// streamEvents handles GET /v1/events, a Server-Sent Events stream of
+// registry events in the caller's namespace as CloudEvents. Invocation
+// events only reach the consumer who made the invocation. Repeated type
+// parameters filter the stream; a type ending in "." matches a prefix.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "streaming is not supported")
+		return
+	}
+	types := r.URL.Query()["type"]
+	ns := registry.NamespaceFrom(r.Context())
+	caller := providerIDFromRequest(r)
+
+	ch, cancel := h.reg.Events().Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	tick := time.NewTicker(eventKeepAlive)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case ev, ok := <-ch:
+			if !ok {
+				// Fell behind or shutting down; the client reconnects.
+				return
+			}
+			if ev.Namespace != ns || !ev.Matches(types) || !visibleTo(ev, caller) {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// visibleTo reports whether caller may see ev: events without an audience
+// are public, others are private to it and never shown to anonymous
+// callers, who all share one identity.
+func visibleTo(ev *events.Event, caller string) bool {
+	return ev.Audience == "" || (ev.Audience == caller && caller != anonymousConsumer)
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribe opens GET /v1/events as caller and returns a function reading
+// the next event.
+func subscribe(t *testing.T, url, caller, query string) func() *events.Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/v1/events"+query, http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+caller)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewReader(resp.Body)
+	return func() *events.Event {
+		t.Helper()
+		var event string
+		for {
+			line, err := lines.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var ev events.Event
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev))
+				assert.Equal(t, event, ev.Type)
+				return &ev
+			}
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	var requests atomic.Int32
+	provider := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close) // after the streams are closed
+
+	mine := subscribe(t, srv.URL, testCaller, "")
+	other := subscribe(t, srv.URL, "did:claw:agent:other", "?type="+events.TypePrefix+"tool.")
+
+	toolID := registerRPCTool(t, h, "double", registry.JSONRPCScheme+provider.URL+"/rpc#double")
+	for _, next := range []func() *events.Event{mine, other} {
+		ev := next()
+		assert.Equal(t, events.ToolRegistered, ev.Type)
+		assert.Equal(t, toolID, ev.Subject)
+		assert.Equal(t, registry.DefaultNamespace, ev.Namespace)
+	}
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": toolID, "input": map[string]any{"n": 1}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	ev := mine()
+	assert.Equal(t, events.InvocationCompleted, ev.Type)
+	var inv registry.Invocation
+	require.NoError(t, json.Unmarshal(ev.Data, &inv))
+	assert.Equal(t, "completed", inv.Status)
+	assert.Equal(t, toolID, inv.ToolID)
+
+	// The other caller never sees the invocation, only the next tool.
+	next := registerRPCTool(t, h, "triple", registry.JSONRPCScheme+provider.URL+"/rpc#triple")
+	assert.Equal(t, next, other().Subject)
+}
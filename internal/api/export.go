@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/export"
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// exportTools handles GET /v1/tools/export. It takes the search filters and
+// renders the matching tools in a model API's tool-definition format.
+func (h *Handler) exportTools(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	limit := q.Int("limit", 0)
+	maxPrice := q.Float("max_price_claw", 0)
+	if !q.valid(w) {
+		return
+	}
+
+	result, err := h.reg.SearchTools(r.Context(), &registry.SearchQuery{
+		Query:     q.Get("q"),
+		Tag:       q.Get("tag"),
+		Provider:  q.Get("provider"),
+		MaxPrice:  maxPrice,
+		Limit:     limit,
+		Federated: q.Get("federated") != "false",
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	out, err := export.Render(q.Get("format"), result.Tools)
+	if errors.Is(err, export.ErrUnknownFormat) {
+		writeError(w, http.StatusBadRequest, "INVALID_FORMAT", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTools_Anthropic(t *testing.T) {
+	h := newTestHandler(t)
+	for _, v := range []string{"1.0.0", "2.0.0"} {
+		p := validToolPayload()
+		p["name"] = "web.search"
+		p["version"] = v
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	p := validToolPayload()
+	p["name"] = "summarize"
+	p["schema"] = map[string]any{"input": map[string]any{"properties": map[string]any{"text": map[string]any{"type": "string"}}}}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/export?format=anthropic", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var out struct {
+		Format string `json:"format"`
+		Tools  []struct {
+			Name        string         `json:"name"`
+			Description string         `json:"description"`
+			InputSchema map[string]any `json:"input_schema"`
+		} `json:"tools"`
+		ToolIDs map[string]string `json:"tool_ids"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &out))
+	assert.Equal(t, "anthropic", out.Format)
+	require.Len(t, out.Tools, 3)
+	require.Len(t, out.ToolIDs, 3)
+
+	byName := map[string]map[string]any{}
+	for _, tool := range out.Tools {
+		assert.Regexp(t, `^[a-zA-Z0-9_-]{1,64}$`, tool.Name)
+		assert.Contains(t, out.ToolIDs, tool.Name)
+		byName[tool.Name] = tool.InputSchema
+	}
+	assert.Equal(t, "object", byName["summarize"]["type"], "missing type defaults to object")
+	for name, id := range out.ToolIDs {
+		if name != "summarize" {
+			assert.Regexp(t, `^web_search_[0-9a-f]{8}$`, name, "versions are told apart by ID")
+			assert.Contains(t, id, name[len("web_search_"):])
+		}
+	}
+}
+
+func TestExportTools_UnknownFormat(t *testing.T) {
+	h := newTestHandler(t)
+	for _, path := range []string{"/v1/tools/export", "/v1/tools/export?format=openapi"} {
+		rr := doRequest(t, h, http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "INVALID_FORMAT")
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// spendForecast handles GET /v1/consumers/{id}/forecast by the consumer
+// itself: its spend so far in the current day or month and where its
+// recent pace takes it, so that it can slow down before a cap.
+func (h *Handler) spendForecast(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	fq := registry.ForecastQuery{ConsumerID: chi.URLParam(r, "id"), Period: q.Get("period"), CapCLAW: q.Get("cap_claw")}
+	q.Check(fq.Period == "" || fq.Period == registry.PeriodDay || fq.Period == registry.PeriodMonth, "period",
+		"period must be day or month")
+	if fq.CapCLAW != "" {
+		c, err := strconv.ParseFloat(fq.CapCLAW, 64)
+		q.Check(err == nil && c >= 0, "cap_claw", "cap_claw must be a non-negative decimal")
+	}
+	if !q.valid(w) {
+		return
+	}
+	if fq.ConsumerID != providerIDFromRequest(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only a consumer may read its own spend forecast")
+		return
+	}
+	f, err := h.reg.ForecastSpend(r.Context(), fq)
+	if err != nil {
+		h.logger(r).Error("forecast spend", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
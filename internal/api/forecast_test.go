@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpendForecast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	const consumer = "did:claw:agent:consumer"
+	for i := 0; i < 2; i++ {
+		rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", map[string]any{"tool_id": tool.ID, "input": map[string]any{"i": i}})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = doAs(t, h, http.MethodGet, "/v1/consumers/"+consumer+"/forecast?period=day&cap_claw=5", consumer, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var f registry.SpendForecast
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&f))
+	assert.Equal(t, registry.PeriodDay, f.Period)
+	assert.Equal(t, "10", f.SpentCLAW)
+	assert.NotNil(t, f.ExhaustedAt, "over the cap already")
+	require.Len(t, f.ByTool, 1)
+	assert.Equal(t, int64(2), f.ByTool[0].Calls)
+
+	rr = doAs(t, h, http.MethodGet, "/v1/consumers/"+consumer+"/forecast", "did:claw:agent:other", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	for _, q := range []string{"period=week", "cap_claw=lots", "cap_claw=-1"} {
+		rr = doAs(t, h, http.MethodGet, "/v1/consumers/"+consumer+"/forecast?"+q, consumer, "", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, q)
+		assert.Contains(t, rr.Body.String(), "INVALID_QUERY", q)
+	}
+}
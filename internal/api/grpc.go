@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// registerGRPCTool handles POST /v1/tools/grpc. The registry introspects the
+// named method through server reflection and derives the tool's schemas.
+func (h *Handler) registerGRPCTool(w http.ResponseWriter, r *http.Request) {
+	var req registry.RegisterGRPCToolRequest
+	if !decodeBody(w, r, registerBodyLimit(h.reg.Limits()), &req) {
+		return
+	}
+	req.ProviderID = providerIDFromRequest(r)
+
+	tool, err := h.reg.RegisterGRPCTool(r.Context(), &req)
+	if err != nil {
+		h.writeRegisterError(w, r, err)
+		return
+	}
+	h.setLocation(w, r, "v1", "tools", tool.ID)
+	writeJSON(w, http.StatusCreated, tool)
+}
@@ -0,0 +1,44 @@
+package api_test
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestRegisterGRPCTool(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools/grpc", map[string]any{
+		"name":    "health-check",
+		"version": "1.0.0",
+		"target":  "grpc://" + lis.Addr().String(),
+		"method":  "grpc.health.v1.Health/Check",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"endpoint":"grpc://`+lis.Addr().String()+`/grpc.health.v1.Health/Check"`)
+	assert.NotEmpty(t, rr.Header().Get("Location"))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools/grpc", map[string]any{
+		"name":    "missing",
+		"version": "1.0.0",
+		"target":  "grpc://" + lis.Addr().String(),
+		"method":  "acme.Missing/Call",
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "REFLECTION_FAILED")
+}
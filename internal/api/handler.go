@@ -2,62 +2,276 @@
 package api
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
-
+	"sync/atomic"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/abuse"
+	"github.com/clawinfra/agent-tools/internal/errreport"
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/jsonrpc"
+	"github.com/clawinfra/agent-tools/internal/metrics"
+	"github.com/clawinfra/agent-tools/internal/push"
 	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 )
 
 // Handler is the HTTP API handler.
 type Handler struct {
-	reg *registry.Registry
-	log *zap.Logger
-	mux *chi.Mux
+	reg         *registry.Registry
+	log         *zap.Logger
+	mux         *chi.Mux
+	reporter    errreport.Reporter
+	accessLog   *zap.Logger
+	basePath    string
+	trustProxy  bool
+	cors        corsPolicy
+	reload      func() error
+	adminToken  string
+	maintenance atomic.Pointer[Maintenance]
+	// maintenanceRead is when maintenance was last read, in Unix nanoseconds.
+	maintenanceRead atomic.Int64
+	abuse           *abuse.Detector
+	anonymous       anonymousAccess
+	sandbox         *sandbox.Executor
+	containers      *sandbox.Containers
+	rpc             *jsonrpc.Client
+	mcp             *http.Client
+	router          *router.Client
+	breaker         *router.Breaker
+	balancer        router.Balancer
+	push            *push.Broker
+	jobs            *jobs.Queue
+	webhooks        *webhooks.Dispatcher
+	inflight        inflight
+	registryKey     ed25519.PrivateKey
+	registryDID     string
+
+	// requireKeyProof refuses provider registrations without a key proof.
+	requireKeyProof bool
+	// rbac enforces the roles of callers; see WithRBAC.
+	rbac bool
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithAccessLog enables or disables the per-request access log (default on).
+func WithAccessLog(enabled bool) Option {
+	return func(h *Handler) {
+		if !enabled {
+			h.accessLog = nil
+		} else if h.accessLog == nil {
+			h.accessLog = h.log
+		}
+	}
+}
+
+// WithAccessLogger sends the per-request access log to log instead of the
+// application logger, e.g. a dedicated rotating file for retention.
+func WithAccessLogger(log *zap.Logger) Option {
+	return func(h *Handler) { h.accessLog = log }
 }
 
 // NewHandler creates a new Handler and registers routes.
-func NewHandler(reg *registry.Registry, log *zap.Logger) http.Handler {
-	h := &Handler{reg: reg, log: log, mux: chi.NewRouter()}
+func NewHandler(reg *registry.Registry, log *zap.Logger, opts ...Option) *Handler {
+	h := &Handler{
+		reg: reg, log: log, mux: chi.NewRouter(), accessLog: log, push: push.NewBroker(),
+	}
+	h.cors.set(nil)
+	h.anonymous.set(DefaultAnonymousPolicy)
+	for _, o := range opts {
+		o(h)
+	}
+	if reg != nil {
+		h.rpc = jsonrpc.NewClient(&http.Client{Transport: reg.EndpointTransport()})
+		h.mcp = &http.Client{Transport: reg.EndpointTransport()}
+		h.router = router.NewClient(&http.Client{Transport: reg.EndpointTransport()}, h.breaker)
+	}
 	h.routes()
 	return h
 }
 
 func (h *Handler) routes() {
-	r := h.mux
+	r := chi.NewRouter()
+	if h.basePath == "" {
+		h.mux = r
+	} else {
+		h.mux.Mount(h.basePath, r)
+	}
 
 	r.Use(middleware.RequestID)
+	r.Use(requestIDHeader)
 	r.Use(middleware.RealIP)
-	r.Use(zapMiddleware(h.log))
-	r.Use(middleware.Recoverer)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type"},
-	}))
+	if h.accessLog != nil {
+		r.Use(zapMiddleware(h.accessLog))
+	}
+	r.Use(h.reportErrors)
+	r.Use(h.cors.handler)
 
 	r.Get("/healthz", h.healthz)
+	r.Method(http.MethodGet, "/metrics", metrics.Default.Handler())
+	r.Get("/.well-known/agent.json", h.agentCard)
+	r.Get("/.well-known/registry-key", h.registryKeyInfo)
+	r.Get("/.well-known/did.json", h.didDocument)
+	if h.basePath != "" {
+		// The did:web of a registry under a path is resolved at that
+		// path's did.json.
+		r.Get("/did.json", h.didDocument)
+	}
+	// Admin routes, POST /admin/reload included, are only served behind
+	// the admin token; without one the config is reloaded on SIGHUP alone.
+	if h.adminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(h.authenticateAPIKey)
+			r.Use(h.requireAdmin)
+			r.Get("/maintenance", h.getMaintenance)
+			r.Put("/maintenance", h.putMaintenance)
+			r.Get("/provider-rules", h.listProviderRules)
+			r.Post("/provider-rules", h.addProviderRule)
+			r.Delete("/provider-rules/{id}", h.deleteProviderRule)
+			r.Get("/policies", h.listPolicies)
+			r.Post("/policies", h.addPolicy)
+			r.Delete("/policies/{id}", h.deletePolicy)
+			r.Get("/policy-decisions", h.listPolicyDecisions)
+			r.Get("/circuits", h.listCircuits)
+			r.Get("/abuse/flags", h.listConsumerFlags)
+			r.Post("/abuse/flags/{id}/review", h.reviewConsumerFlag)
+			r.Put("/tools/{id}/advisory", h.adminPutAdvisory)
+			r.Delete("/tools/{id}/advisory", h.adminDeleteAdvisory)
+			r.With(h.trackInflight).Post("/invocations/{id}/replay", h.adminReplayInvocation)
+			if h.reload != nil {
+				r.Post("/reload", h.adminReload)
+			}
+		})
+	}
 
 	r.Route("/v1", func(r chi.Router) {
-		r.Route("/tools", func(r chi.Router) {
-			r.Get("/", h.listTools)
-			r.Post("/", h.registerTool)
-			r.Get("/search", h.searchTools)
-			r.Get("/{id}", h.getTool)
-			r.Delete("/{id}", h.deactivateTool)
+		r.Use(h.enforceMaintenance)
+		r.Use(h.authenticateAPIKey)
+		r.Use(h.restrictAnonymous)
+		r.Use(h.guardConsumer)
+		r.Get("/did", h.didDocument)
+		r.With(h.requireScope(registry.ScopeToolsWrite), h.requireRole(RoleProvider, false)).Post("/modules", h.uploadModule)
+		r.Group(func(r chi.Router) {
+			r.Use(h.requireScope(registry.ScopeToolsWrite))
+			r.Use(h.requireRole(RoleProvider, false))
+			r.Get("/push/jobs", h.pollJobs)
+			r.Post("/push/jobs/{id}/result", h.pushResult)
+		})
+		r.Route("/keys", func(r chi.Router) {
+			r.With(h.requireScopeOrProof(registry.ScopeAdmin)).Get("/", h.listAPIKeys)
+			r.With(h.requireScope(registry.ScopeAdmin)).Post("/", h.createAPIKey)
+			r.With(h.requireScopeOrProof(registry.ScopeAdmin)).Delete("/{id}", h.revokeAPIKey)
+		})
+		r.Route("/namespaces", func(r chi.Router) {
+			r.Use(h.scoped(registry.ScopeToolsRead, registry.ScopeAdmin))
+			r.Post("/", h.createNamespace)
+			r.Get("/{ns}/members", h.listNamespaceMembers)
+			r.Post("/{ns}/members", h.addNamespaceMember)
 		})
 
-		r.Post("/invoke", h.invokeTool)
+		r.Route("/orgs", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(h.scoped(registry.ScopeToolsRead, registry.ScopeAdmin))
+				r.Get("/", h.listOrgs)
+				r.Post("/", h.createOrg)
+				r.Get("/{org}", h.getOrg)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireScopeOrProof(registry.ScopeAdmin), h.requireProof)
+				r.Post("/{org}/members", h.addOrgMember)
+				r.Delete("/{org}/members/{member}", h.removeOrgMember)
+			})
+		})
 
-		r.Route("/providers", func(r chi.Router) {
-			r.Get("/", h.listProviders)
-			r.Post("/", h.registerProvider)
-			r.Get("/{id}", h.getProvider)
+		r.Group(func(r chi.Router) {
+			r.Use(h.scopeNamespace)
+			r.Use(h.actAsOrg)
+
+			r.Route("/tools", func(r chi.Router) {
+				r.Use(h.scoped(registry.ScopeToolsRead, registry.ScopeToolsWrite))
+				r.Use(h.requireRole(RoleProvider, true))
+				r.Get("/", h.listTools)
+				r.Post("/", h.registerTool)
+				r.Post("/grpc", h.registerGRPCTool)
+				r.Get("/search", h.searchTools)
+				r.Get("/export", h.exportTools)
+				r.Get("/{id}", h.getTool)
+				r.Get("/{id}/usage", h.toolUsage)
+				r.Get("/{id}/diff", h.toolDiff)
+				r.Get("/{id}/versions", h.toolVersions)
+				r.Get("/{id}/pricing-history", h.pricingHistory)
+				r.Get("/{id}/health", h.toolHealth)
+				r.Get("/{id}/badge.svg", h.conformanceBadge)
+				r.Delete("/{id}", h.deactivateTool)
+				r.Put("/{id}/auth", h.putEndpointAuth)
+				r.Delete("/{id}/auth", h.deleteEndpointAuth)
+				r.Put("/{id}/quota", h.putQuota)
+				r.Delete("/{id}/quota", h.deleteQuota)
+				r.Put("/{id}/advisory", h.putAdvisory)
+				r.Delete("/{id}/advisory", h.deleteAdvisory)
+				r.Get("/{id}/icon", h.getIcon)
+				r.Put("/{id}/icon", h.putIcon)
+				r.Delete("/{id}/icon", h.deleteIcon)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.requireScope(registry.ScopeInvoke))
+				r.With(h.trackInflight).Post("/invoke", h.invokeTool)
+				r.With(h.trackInflight).Post("/invoke/batch", h.invokeBatch)
+				r.Get("/invoke/ws", h.invokeWS)
+				r.With(h.trackInflight).Post("/a2a", h.a2aRPC)
+				r.With(h.trackInflight).Post("/invocations/{id}/replay", h.replayInvocation)
+				r.Get("/invocations", h.listInvocations)
+				r.Get("/invocations/{id}", h.getInvocation)
+				r.Get("/invocations/{id}/payload", h.getPayload)
+				r.Delete("/invocations/{id}/payload", h.deletePayload)
+				r.Get("/receipts", h.listReceipts)
+				r.Get("/receipts/{id}", h.getReceipt)
+				r.Get("/receipts/{id}/proof", h.getReceiptProof)
+				r.Post("/receipts/verify", h.verifyReceipt)
+				r.Get("/consumers/{id}/forecast", h.spendForecast)
+				r.Get("/wallet", h.getWallet)
+				r.Post("/wallet/withdraw", h.withdrawFromWallet)
+			})
+			r.With(h.requireScope(registry.ScopeToolsWrite), h.requireRole(RoleProvider, false)).
+				Put("/receipts/{id}/attestation", h.attachAttestation)
+			r.With(h.requireScope(registry.ScopeToolsRead)).Get("/events", h.streamEvents)
+
+			r.Route("/collections", func(r chi.Router) {
+				r.Use(h.scoped(registry.ScopeToolsRead, registry.ScopeToolsWrite))
+				r.Get("/", h.searchCollections)
+				r.Post("/", h.createCollection)
+				r.Get("/{name}", h.getCollection)
+				r.Get("/{name}/versions", h.collectionVersions)
+			})
+
+			r.Route("/pipelines", func(r chi.Router) {
+				r.Use(h.requireScope(registry.ScopeInvoke))
+				r.Post("/", h.createPipeline)
+				r.Get("/{id}", h.getPipeline)
+				r.Delete("/{id}", h.deletePipeline)
+				r.With(h.trackInflight).Post("/{id}/run", h.runPipeline)
+				r.Get("/{id}/runs/{run}", h.getPipelineRun)
+			})
+
+			r.Route("/providers", func(r chi.Router) {
+				r.Use(h.scoped(registry.ScopeToolsRead, registry.ScopeToolsWrite))
+				r.Get("/", h.listProviders)
+				r.Post("/", h.registerProvider)
+				r.Post("/challenge", h.keyChallenge)
+				r.Post("/{id}/keys", h.createProviderKey)
+				r.Get("/{id}", h.getProvider)
+			})
 		})
 	})
 }
@@ -67,31 +281,52 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // healthz returns service health status.
-func (h *Handler) healthz(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]string{
 		"status":  "ok",
 		"version": "0.1.0",
-	})
+	}
+	if m := h.Maintenance(r.Context()); m.Mode != MaintenanceOff {
+		resp["maintenance"] = m.Mode
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // listTools handles GET /v1/tools.
 func (h *Handler) listTools(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	q := queryOf(r)
+	page := q.Int("page", 0)
+	limit := q.Int("limit", 0)
+	if !q.valid(w) {
+		return
+	}
 
-	result, err := h.reg.ListTools(r.Context(), page, limit)
+	var (
+		result *registry.SearchResult
+		err    error
+	)
+	if cursor := q.Get("cursor"); cursor != "" {
+		result, err = h.reg.ListToolsAfter(r.Context(), cursor, limit)
+	} else {
+		result, err = h.reg.ListTools(r.Context(), page, limit)
+	}
+	if errors.Is(err, registry.ErrInvalidCursor) {
+		q.Check(false, "cursor", err.Error())
+		q.valid(w)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
+	localize(w, r, result.Tools...)
 	writeJSON(w, http.StatusOK, result)
 }
 
 // registerTool handles POST /v1/tools.
 func (h *Handler) registerTool(w http.ResponseWriter, r *http.Request) {
 	var req registry.RegisterToolRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "INVALID_BODY", "invalid JSON")
+	if !decodeBody(w, r, registerBodyLimit(h.reg.Limits()), &req) {
 		return
 	}
 
@@ -100,19 +335,60 @@ func (h *Handler) registerTool(w http.ResponseWriter, r *http.Request) {
 
 	tool, err := h.reg.RegisterTool(r.Context(), &req)
 	if err != nil {
-		switch {
-		case errors.Is(err, registry.ErrDuplicate):
-			writeError(w, http.StatusConflict, "DUPLICATE_TOOL", err.Error())
-		default:
-			h.log.Error("register tool", zap.Error(err))
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		}
+		h.writeRegisterError(w, r, err)
 		return
 	}
+	h.runConformance(r.Context(), tool)
+	h.setLocation(w, r, "v1", "tools", tool.ID)
 	writeJSON(w, http.StatusCreated, tool)
 }
 
+// writeRegisterError maps a tool registration error to its API error.
+func (h *Handler) writeRegisterError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, registry.ErrDuplicate):
+		writeError(w, http.StatusConflict, "DUPLICATE_TOOL", err.Error())
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrProviderBlocked):
+		writeError(w, http.StatusForbidden, "PROVIDER_BLOCKED", err.Error())
+	case errors.Is(err, registry.ErrLimitExceeded):
+		writeError(w, http.StatusRequestEntityTooLarge, "LIMIT_EXCEEDED", err.Error())
+	case errors.Is(err, registry.ErrInvalidAuth):
+		writeError(w, http.StatusBadRequest, "INVALID_AUTH", err.Error())
+	case errors.Is(err, registry.ErrInvalidQuota):
+		writeError(w, http.StatusBadRequest, "INVALID_QUOTA", err.Error())
+	case errors.Is(err, registry.ErrInvalidSignature):
+		writeError(w, http.StatusBadRequest, "INVALID_SIGNATURE", err.Error())
+	case errors.Is(err, registry.ErrReplayedRequest):
+		writeError(w, http.StatusConflict, "REPLAYED_REQUEST", err.Error())
+	case errors.Is(err, registry.ErrInvalidEndpoint):
+		writeError(w, http.StatusBadRequest, "INVALID_ENDPOINT", err.Error())
+	case errors.Is(err, registry.ErrInvalidRetryPolicy):
+		writeError(w, http.StatusBadRequest, "INVALID_RETRY_POLICY", err.Error())
+	case errors.Is(err, registry.ErrInvalidFeatures):
+		writeError(w, http.StatusBadRequest, "INVALID_FEATURES", err.Error())
+	case errors.Is(err, registry.ErrInvalidMock):
+		writeError(w, http.StatusBadRequest, "INVALID_MOCK", err.Error())
+	case errors.Is(err, registry.ErrInvalidTests):
+		writeError(w, http.StatusBadRequest, "INVALID_TESTS", err.Error())
+	case errors.Is(err, registry.ErrInvalidLanguage):
+		writeError(w, http.StatusBadRequest, "INVALID_LANGUAGE", err.Error())
+	case errors.Is(err, registry.ErrModuleNotFound):
+		writeError(w, http.StatusBadRequest, "MODULE_NOT_FOUND", err.Error())
+	case errors.Is(err, registry.ErrReflection):
+		writeError(w, http.StatusBadRequest, "REFLECTION_FAILED", err.Error())
+	default:
+		h.logger(r).Error("register tool", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
 // getTool handles GET /v1/tools/{id}.
+// The manifest hash doubles as the ETag, so a consumer pinned to a version
+// can send If-None-Match and learn from a 200 that the content changed. It
+// covers every translation of the description, so it is the same whatever
+// language the request accepts.
 func (h *Handler) getTool(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	tool, err := h.reg.GetTool(r.Context(), id)
@@ -124,15 +400,27 @@ func (h *Handler) getTool(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
+	w.Header().Set("Content-Language", localize(w, r, tool))
+	if tool.ManifestHash != "" {
+		etag := `"` + tool.ManifestHash + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 	writeJSON(w, http.StatusOK, tool)
 }
 
 // searchTools handles GET /v1/tools/search.
 func (h *Handler) searchTools(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	page, _ := strconv.Atoi(q.Get("page"))
-	limit, _ := strconv.Atoi(q.Get("limit"))
-	maxPrice, _ := strconv.ParseFloat(q.Get("max_price_claw"), 64)
+	q := queryOf(r)
+	page := q.Int("page", 0)
+	limit := q.Int("limit", 0)
+	maxPrice := q.Float("max_price_claw", 0)
+	if !q.valid(w) {
+		return
+	}
 
 	result, err := h.reg.SearchTools(r.Context(), &registry.SearchQuery{
 		Query:    q.Get("q"),
@@ -141,14 +429,85 @@ func (h *Handler) searchTools(w http.ResponseWriter, r *http.Request) {
 		MaxPrice: maxPrice,
 		Page:     page,
 		Limit:    limit,
+		// Mirrored peer tools are included unless the caller opts out.
+		Federated: q.Get("federated") != "false",
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
+	localize(w, r, result.Tools...)
 	writeJSON(w, http.StatusOK, result)
 }
 
+// toolUsage handles GET /v1/tools/{id}/usage.
+// from/to are UTC dates (YYYY-MM-DD); the default window is the last 30 days.
+func (h *Handler) toolUsage(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	now := time.Now().UTC()
+	from := q.Date("from", now.AddDate(0, 0, -30))
+	to := q.Date("to", now)
+	if !q.valid(w) {
+		return
+	}
+
+	usage, err := h.reg.ToolUsage(r.Context(), chi.URLParam(r, "id"), from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": usage})
+}
+
+// toolDiff handles GET /v1/tools/{id}/diff.
+func (h *Handler) toolDiff(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	against := r.URL.Query().Get("against")
+	q.Check(against != "", "against", "against is required")
+	if !q.valid(w) {
+		return
+	}
+
+	diff, err := h.reg.DiffSchemas(r.Context(), chi.URLParam(r, "id"), against)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", "tool not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// toolVersions handles GET /v1/tools/{id}/versions.
+func (h *Handler) toolVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.reg.ToolVersions(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", "tool not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"versions": versions})
+}
+
+// pricingHistory handles GET /v1/tools/{id}/pricing-history.
+func (h *Handler) pricingHistory(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.reg.PricingHistory(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", "tool not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"changes": changes})
+}
+
 // deactivateTool handles DELETE /v1/tools/{id}.
 func (h *Handler) deactivateTool(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
@@ -177,18 +536,41 @@ func (h *Handler) listProviders(w http.ResponseWriter, r *http.Request) {
 
 // registerProvider handles POST /v1/providers.
 func (h *Handler) registerProvider(w http.ResponseWriter, r *http.Request) {
-	var req registry.Provider
+	var req registerProviderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "INVALID_BODY", "invalid JSON")
 		return
 	}
+	if !h.proveKey(w, r, &req) {
+		return
+	}
+	if ok, err := h.mayRegisterProvider(r, req.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	} else if !ok {
+		writeError(w, http.StatusForbidden, "INSUFFICIENT_ROLE", "only the provider itself may change a registered provider")
+		return
+	}
 
-	provider, err := h.reg.RegisterProvider(r.Context(), &req)
+	provider, err := h.reg.RegisterProvider(r.Context(), &req.Provider)
 	if err != nil {
-		h.log.Error("register provider", zap.Error(err))
+		if errors.Is(err, registry.ErrForbidden) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		if errors.Is(err, registry.ErrProviderBlocked) {
+			writeError(w, http.StatusForbidden, "PROVIDER_BLOCKED", err.Error())
+			return
+		}
+		if errors.Is(err, registry.ErrInvalidEndpoint) {
+			writeError(w, http.StatusBadRequest, "INVALID_ENDPOINT", err.Error())
+			return
+		}
+		h.logger(r).Error("register provider", zap.Error(err))
 		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 		return
 	}
+	h.setLocation(w, r, "v1", "providers", provider.ID)
 	writeJSON(w, http.StatusCreated, provider)
 }
 
@@ -207,20 +589,21 @@ func (h *Handler) getProvider(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, provider)
 }
 
-// invokeTool handles POST /v1/invoke.
-// v0.1: direct invocation stub — returns 501 until invocation router is implemented.
-func (h *Handler) invokeTool(w http.ResponseWriter, _ *http.Request) {
-	writeError(w, http.StatusNotImplemented, "NOT_IMPLEMENTED",
-		"tool invocation is coming in v0.2 — see ARCHITECTURE.md#roadmap")
-}
-
 // providerIDFromRequest extracts the provider DID from the request.
-// In v0.1, uses the Authorization header as a simple DID.
+// In v0.1, uses the Authorization header as a simple DID. A member acting
+// as an organization with X-Org gets the organization's ID instead, and a
+// request made with an API key its owner's.
 // TODO: replace with proper DID-signed JWT verification.
 func providerIDFromRequest(r *http.Request) string {
+	if org, ok := r.Context().Value(orgKey{}).(string); ok {
+		return org
+	}
+	if k := apiKeyFrom(r.Context()); k != nil {
+		return k.OwnerID
+	}
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
-		return "did:claw:agent:anonymous"
+		return anonymousConsumer
 	}
 	// Strip "Bearer " prefix
 	if len(auth) > 7 && auth[:7] == "Bearer " {
@@ -237,27 +620,63 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 
 type apiError struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		Param     string `json:"param,omitempty"` // the malformed query parameter of INVALID_QUERY
+		RequestID string `json:"request_id,omitempty"`
+		// Provider is what the provider said about a failed invocation.
+		Provider *registry.ProviderError `json:"provider,omitempty"`
 	} `json:"error"`
 }
 
+// writeError writes an error payload. The request ID is taken from the
+// response header set by requestIDHeader so callers don't need the request.
 func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, newAPIError(w, code, message))
+}
+
+func newAPIError(w http.ResponseWriter, code, message string) apiError {
 	var e apiError
 	e.Error.Code = code
 	e.Error.Message = message
-	writeJSON(w, status, e)
+	e.Error.RequestID = w.Header().Get(middleware.RequestIDHeader)
+	return e
+}
+
+// requestIDHeader echoes the chi request ID back to the caller so it can be
+// quoted in support requests and matched against server logs.
+func requestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logger returns the handler logger annotated with the request ID.
+func (h *Handler) logger(r *http.Request) *zap.Logger {
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		return h.log.With(zap.String("request_id", id))
+	}
+	return h.log
 }
 
 func zapMiddleware(log *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 			next.ServeHTTP(ww, r)
 			log.Info("http",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.Int("status", ww.Status()),
+				zap.Int("bytes", ww.BytesWritten()),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("remote", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+				zap.String("request_id", middleware.GetReqID(r.Context())),
 			)
 		})
 	}
@@ -1,7 +1,10 @@
 package api_test
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/clawinfra/agent-tools/internal/api"
@@ -9,7 +12,10 @@ import (
 	"github.com/clawinfra/agent-tools/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // newBrokenHandler returns a handler with a closed DB to trigger 500 errors.
@@ -71,3 +77,216 @@ func TestRegisterTool_InternalError(t *testing.T) {
 	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 }
+
+func TestRequestID_EchoedInHeaderAndError(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:nonexistent", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	id := rr.Header().Get("X-Request-Id")
+	require.NotEmpty(t, id)
+
+	var resp map[string]map[string]string
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, id, resp["error"]["request_id"])
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/metrics", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+}
+
+func TestToolUsage(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:x/usage?from=2026-01-01&to=2026-01-31", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:x/usage?from=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestToolUsage_InternalError(t *testing.T) {
+	h := newBrokenHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools/some-id/usage", nil)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestAccessLog_Disabled(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zap.New(core), api.WithAccessLog(false))
+	rr := doRequest(t, h, http.MethodGet, "/healthz", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Zero(t, logs.FilterMessage("http").Len())
+
+	core2, logs2 := observer.New(zapcore.InfoLevel)
+	h2 := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zap.New(core2))
+	doRequest(t, h2, http.MethodGet, "/healthz", nil)
+	assert.Equal(t, 1, logs2.FilterMessage("http").Len())
+}
+
+func TestWithAccessLogger(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	appCore, appLogs := observer.New(zapcore.InfoLevel)
+	accessCore, accessLogs := observer.New(zapcore.InfoLevel)
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zap.New(appCore),
+		api.WithAccessLogger(zap.New(accessCore)))
+
+	doRequest(t, h, http.MethodGet, "/healthz", nil)
+	assert.Zero(t, appLogs.FilterMessage("http").Len())
+	require.Equal(t, 1, accessLogs.FilterMessage("http").Len())
+	fields := accessLogs.All()[0].ContextMap()
+	assert.Contains(t, fields, "duration")
+	assert.Contains(t, fields, "bytes")
+}
+
+func TestRegisterTool_LimitExceeded(t *testing.T) {
+	h := newTestHandler(t)
+
+	p := validToolPayload()
+	p["description"] = strings.Repeat("x", 5<<10)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "LIMIT_EXCEEDED")
+
+	p = validToolPayload()
+	p["schema"] = map[string]any{"input": map[string]any{"blob": strings.Repeat("x", 1<<20)}}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), "request body exceeds")
+}
+
+func TestGetTool_ManifestETag(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool registry.Tool
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tool))
+	require.NotEmpty(t, tool.ManifestHash)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID, nil)
+	etag := rr.Header().Get("ETag")
+	assert.Equal(t, `"`+tool.ManifestHash+`"`, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tools/"+tool.ID, http.NoBody)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	p := validToolPayload()
+	p["version"] = "9.9.9"
+	p["manifest_hash"] = "sha256:stale"
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_SIGNATURE")
+}
+
+func TestToolDiff(t *testing.T) {
+	h := newTestHandler(t)
+	register := func(version string, input map[string]any) string {
+		p := validToolPayload()
+		p["version"] = version
+		p["schema"] = map[string]any{"input": input, "output": map[string]any{"type": "object"}}
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool map[string]any
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		return tool["id"].(string)
+	}
+	v1 := register("1.0.0", map[string]any{"type": "object", "required": []string{"city"}})
+	v2 := register("2.0.0", map[string]any{"type": "object", "required": []string{"city", "country"}})
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools/"+v2+"/diff?against="+v1, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var diff registry.SchemaDiff
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&diff))
+	assert.Equal(t, v2, diff.ToolID)
+	assert.Equal(t, v1, diff.Against)
+	require.Len(t, diff.Input, 1)
+	assert.Equal(t, "/required", diff.Input[0].Path)
+	assert.Equal(t, "country", diff.Input[0].New)
+	assert.Empty(t, diff.Output)
+	assert.True(t, diff.Breaking)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+v1+"/diff?against="+v2, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&diff))
+	assert.False(t, diff.Breaking, "dropping a required input breaks no one")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+v2+"/diff", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"param":"against"`)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+v2+"/diff?against=did:claw:tool:missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestToolVersions(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var v1 registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&v1))
+
+	p := validToolPayload()
+	p["version"] = "2.0.0"
+	p["check_compat"] = true
+	p["schema"] = map[string]any{"input": map[string]any{"type": "array"}, "output": map[string]any{"type": "object"}}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var v2 registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&v2))
+	require.NotNil(t, v2.Compat)
+	assert.Equal(t, v1.ID, v2.Compat.Against)
+	assert.True(t, v2.Compat.Breaking)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+v1.ID+"/versions", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Versions []registry.Tool `json:"versions"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Versions, 2)
+	assert.Equal(t, v2.ID, resp.Versions[0].ID)
+	assert.Equal(t, v2.Compat, resp.Versions[0].Compat)
+	assert.Nil(t, resp.Versions[1].Compat)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:missing/versions", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPricingHistory(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var v1 registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&v1))
+	p := validToolPayload()
+	p["version"] = "2.0.0"
+	p["pricing"] = map[string]any{"model": "per_call", "amount_claw": "100"}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", p)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+v1.ID+"/pricing-history", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Changes []registry.PricingChange `json:"changes"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Changes, 2)
+	assert.Equal(t, "2.0.0", resp.Changes[0].Version)
+	assert.Equal(t, "100", resp.Changes[0].New.AmountCLAW)
+	assert.Equal(t, v1.Pricing, resp.Changes[0].Old)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:missing/pricing-history", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
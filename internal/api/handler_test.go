@@ -25,6 +25,9 @@ func newTestHandler(t *testing.T) http.Handler {
 	return api.NewHandler(reg, zaptest.NewLogger(t))
 }
 
+// testCaller is the identity doRequest authenticates as.
+const testCaller = "did:claw:agent:test-caller"
+
 func doRequest(t *testing.T, h http.Handler, method, path string, body any) *httptest.ResponseRecorder {
 	t.Helper()
 	var buf bytes.Buffer
@@ -35,6 +38,7 @@ func doRequest(t *testing.T, h http.Handler, method, path string, body any) *htt
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	req.Header.Set("Authorization", "Bearer "+testCaller)
 	rr := httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
 	return rr
@@ -98,6 +102,7 @@ func TestRegisterTool_InvalidJSON(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodPost, "/v1/tools", bytes.NewBufferString("{not json}"))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testCaller)
 	rr := httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
 
@@ -162,13 +167,28 @@ func TestDeleteTool_NotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, rr.Code)
 }
 
-func TestInvokeTool_NotImplemented(t *testing.T) {
+func TestInvokeTool_NotFound(t *testing.T) {
 	h := newTestHandler(t)
 	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{
 		"tool_id": "did:claw:tool:abc",
 		"input":   map[string]any{},
 	})
-	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_NOT_FOUND")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"input": map[string]any{}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestInvokeTool_NotImplemented(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool["id"], "input": map[string]any{}})
+	assert.Equal(t, http.StatusNotImplemented, rr.Code, "grpc:// endpoints are not routed")
 }
 
 func validProviderPayload() map[string]any {
@@ -200,6 +220,7 @@ func TestRegisterProvider_InvalidJSON(t *testing.T) {
 	h := newTestHandler(t)
 	req := httptest.NewRequest(http.MethodPost, "/v1/providers", bytes.NewBufferString("{bad}"))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testCaller)
 	rr := httptest.NewRecorder()
 	h.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
@@ -285,6 +306,34 @@ func TestListTools_WithPagination(t *testing.T) {
 	assert.Len(t, tools, 3)
 }
 
+func TestListTools_WithCursor(t *testing.T) {
+	h := newTestHandler(t)
+	for i := 0; i < 3; i++ {
+		p := validToolPayload()
+		p["name"] = "tool-" + string(rune('a'+i))
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", p)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools?limit=2", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var first map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&first))
+	cursor, _ := first["next_cursor"].(string)
+	require.NotEmpty(t, cursor)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools?limit=2&cursor="+cursor, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var second map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&second))
+	assert.Len(t, second["tools"], 1)
+	assert.NotContains(t, second, "next_cursor")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools?cursor=bogus", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_QUERY")
+}
+
 func TestSearchTools_WithQuery(t *testing.T) {
 	h := newTestHandler(t)
 
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// iconMaxAge is how long, in seconds, clients may cache an icon. Icons are
+// addressed by digest in the tool's icon field, so a changed icon is seen
+// through its tool.
+const iconMaxAge = 86400
+
+// putIcon handles PUT /v1/tools/{id}/icon. The body is the raw image, of
+// the request's Content-Type.
+func (h *Handler) putIcon(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, registry.MaxIconBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "LIMIT_EXCEEDED", fmt.Sprintf("icon exceeds %d bytes", registry.MaxIconBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	icon, err := h.reg.SetIcon(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r), r.Header.Get("Content-Type"), data)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, icon)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", err.Error())
+	case errors.Is(err, registry.ErrInvalidIcon):
+		writeError(w, http.StatusBadRequest, "INVALID_ICON", err.Error())
+	default:
+		h.logger(r).Error("set icon", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
+// deleteIcon handles DELETE /v1/tools/{id}/icon.
+func (h *Handler) deleteIcon(w http.ResponseWriter, r *http.Request) {
+	err := h.reg.DeleteIcon(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", err.Error())
+	default:
+		h.logger(r).Error("delete icon", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
+// getIcon handles GET /v1/tools/{id}/icon. The icon's digest is its ETag.
+func (h *Handler) getIcon(w http.ResponseWriter, r *http.Request) {
+	icon, err := h.reg.GetIcon(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "tool not found or has no icon")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	etag := `"` + icon.Digest + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(iconMaxAge))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", icon.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(icon.Size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(icon.Data)
+}
@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putIcon(t *testing.T, h http.Handler, toolID, contentType string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/v1/tools/"+toolID+"/icon", bytes.NewReader(data))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestToolIcon(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	var buf bytes.Buffer
+	require.NoError(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 2, 2), palette.Plan9), nil))
+	logo := buf.Bytes()
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/icon", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = putIcon(t, h, tool.ID, "image/gif", logo)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var icon registry.Icon
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&icon))
+	assert.Equal(t, len(logo), icon.Size)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID, nil)
+	assert.Contains(t, rr.Body.String(), `"icon":"`+icon.Digest+`"`)
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/icon", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/gif", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, logo, rr.Body.Bytes())
+	etag := rr.Header().Get("ETag")
+	assert.Equal(t, `"`+icon.Digest+`"`, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tools/"+tool.ID+"/icon", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = putIcon(t, h, tool.ID, "image/svg+xml", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ICON")
+	rr = putIcon(t, h, tool.ID, "image/gif", make([]byte, registry.MaxIconBytes+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	rr = doAs(t, h, http.MethodDelete, "/v1/tools/"+tool.ID+"/icon", "did:claw:agent:someone-else", "", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRequest(t, h, http.MethodDelete, "/v1/tools/"+tool.ID+"/icon", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/"+tool.ID+"/icon", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// idempotencyHeader may carry the idempotency key of POST /v1/invoke in
+// place of the body's idempotency_key.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKey bounds the length of idempotency keys.
+const maxIdempotencyKey = 255
+
+// withIdempotencyKey answers req with the earlier invocation of the caller
+// that had the same idempotency key, if any. Otherwise it returns r marked
+// for the invocation to hold the key, or r unchanged for requests without
+// one.
+func (h *Handler) withIdempotencyKey(
+	r *http.Request, tool *registry.Tool, req *registry.InvokeRequest,
+) (*http.Request, *registry.InvokeResponse, *invokeError) {
+	key := req.IdempotencyKey
+	if key == "" {
+		return r, nil, nil
+	}
+	if len(key) > maxIdempotencyKey {
+		return nil, nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY",
+			msg: "idempotency_key must be at most 255 bytes"}
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	inv, out, err := h.reg.IdempotentInvocation(r.Context(), providerIDFromRequest(r), key, tool.ID, b)
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		return r.WithContext(registry.WithIdempotencyKey(r.Context(), key)), nil, nil
+	case err != nil:
+		return nil, nil, idempotencyError(err)
+	}
+	var output map[string]any
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	resp := &registry.InvokeResponse{
+		InvocationID: inv.ID,
+		ToolID:       inv.ToolID,
+		Output:       output,
+		CostCLAW:     inv.CostCLAW,
+		Cached:       inv.Cached,
+		CacheHit:     inv.Cached,
+		Replayed:     true,
+		Metadata:     inv.Metadata,
+	}
+	if inv.CompletedAt != nil {
+		resp.DurationMS = inv.CompletedAt.Sub(inv.StartedAt).Milliseconds()
+	}
+	return r, resp, nil
+}
+
+// idempotencyError maps an error looking up or recording an idempotency
+// key to its API error.
+func idempotencyError(err error) *invokeError {
+	if errors.Is(err, registry.ErrIdempotencyConflict) {
+		return &invokeError{status: http.StatusConflict, code: "IDEMPOTENCY_CONFLICT", msg: err.Error()}
+	}
+	return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+}
@@ -0,0 +1,544 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/jsonrpc"
+	"github.com/clawinfra/agent-tools/internal/jsonschema"
+	"github.com/clawinfra/agent-tools/internal/push"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"go.uber.org/zap"
+)
+
+// errOutput is recorded for a tool whose output is not a JSON object.
+var errOutput = errors.New("tool output is not a JSON object")
+
+// errSchemaViolation is recorded for a tool whose output does not conform
+// to the output schema it declares.
+var errSchemaViolation = errors.New("tool output violates its output schema")
+
+// invokeError is a failed invocation and the API error it maps to.
+// invocationID is set when the failure was recorded as an invocation,
+// retryAfter when the caller may try again after that long, and provider
+// when the provider said why it failed.
+type invokeError struct {
+	status       int
+	code         string
+	msg          string
+	invocationID string
+	retryAfter   time.Duration
+	provider     *registry.ProviderError
+}
+
+// invokeTool handles POST /v1/invoke. With ?mode=async the invocation is
+// queued and answered at once with its ID; a dry run is only checked.
+func (h *Handler) invokeTool(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	mode := q.Get("mode")
+	q.Check(mode == "" || mode == "sync" || mode == "async", "mode", "mode must be sync or async")
+	if !q.valid(w) {
+		return
+	}
+	var req registry.InvokeRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get(idempotencyHeader)
+	}
+	if ierr := h.route(r, &req); ierr != nil {
+		writeInvokeError(w, ierr)
+		return
+	}
+	if req.DryRun {
+		resp, ierr := h.dryRun(r, &req)
+		if ierr != nil {
+			writeInvokeError(w, ierr)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if mode == "async" {
+		queued, ierr := h.enqueue(r, &req)
+		if ierr != nil {
+			writeInvokeError(w, ierr)
+			return
+		}
+		h.setLocation(w, r, "v1", "invocations", queued.InvocationID)
+		writeJSON(w, http.StatusAccepted, queued)
+		return
+	}
+	resp, ierr := h.invoke(r, &req)
+	if ierr != nil {
+		writeInvokeError(w, ierr)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeInvokeError(w http.ResponseWriter, ierr *invokeError) {
+	if ierr.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(ierr.retryAfter.Seconds()))))
+	}
+	e := newAPIError(w, ierr.code, ierr.msg)
+	e.Error.Provider = ierr.provider
+	writeJSON(w, ierr.status, e)
+}
+
+// invoke runs one invocation for the caller of r. It backs POST /v1/invoke
+// and the protocol bridges.
+func (h *Handler) invoke(r *http.Request, req *registry.InvokeRequest) (*registry.InvokeResponse, *invokeError) {
+	if req.Mock {
+		return h.invokeMock(r, req)
+	}
+	tool, run, ierr := h.resolve(r, req.ToolID)
+	if ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = withPayloadStorage(r, tool, req); ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = withMetadata(r, req); ierr != nil {
+		return nil, ierr
+	}
+	if r, ierr = h.withConsumerSignature(r, tool, req); ierr != nil {
+		return nil, ierr
+	}
+	r = withBudget(r, req)
+	r, replayed, ierr := h.withIdempotencyKey(r, tool, req)
+	if replayed != nil || ierr != nil {
+		return replayed, ierr
+	}
+	id, input, ierr := h.startInvocation(r, tool, req)
+	if ierr != nil {
+		return nil, ierr
+	}
+	return h.execute(r, tool, run, id, input)
+}
+
+// execute runs invocation id of tool, recorded by startInvocation, retrying
+// it as the tool's retry policy allows, and records its outcome.
+func (h *Handler) execute(
+	r *http.Request, tool *registry.Tool, run runFunc, id string, input []byte,
+) (*registry.InvokeResponse, *invokeError) {
+	if resp := h.fromCache(r, tool, id, input); resp != nil {
+		return resp, nil
+	}
+	ctx, served := withServed(withCall(r.Context(), id, providerIDFromRequest(r)))
+	ctx, answered := router.WithResponseHeader(ctx)
+	start := time.Now()
+	out, attempts, err := runWithRetry(ctx, tool, run, input)
+	elapsed := time.Since(start)
+	if *served != "" {
+		r = r.WithContext(registry.WithServedEndpoint(r.Context(), *served))
+	}
+	if router.Routable(tool.Endpoint) {
+		r = r.WithContext(withResponseSignature(r.Context(), answered.Get(receipts.ResponseSignatureHeader)))
+	}
+	if attempts > 1 {
+		r = r.WithContext(registry.WithAttempts(r.Context(), attempts))
+	}
+	return h.finishInvocation(r, tool, id, input, out, err, elapsed)
+}
+
+// resolve returns the tool to invoke and how to run it. Tools backed by a
+// wasm:// module or oci:// image run in a sandbox on the registry, push://
+// tools are queued for their provider, jsonrpc+ tools are called as JSON-RPC
+// methods, mcp+ tools with tools/call on their MCP server and http(s):// tools
+// are proxied by the invocation router. Other
+// endpoints, such as gRPC, return 501. Revoked tools are refused with their
+// advisory.
+func (h *Handler) resolve(r *http.Request, toolID string) (*registry.Tool, runFunc, *invokeError) {
+	tool, ierr := h.invocableTool(r, toolID)
+	if ierr != nil {
+		return nil, nil, ierr
+	}
+	if run := h.runner(tool); run != nil {
+		return tool, run, nil
+	}
+	return nil, nil, &invokeError{status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED",
+		msg: "invoking tools at " + tool.Endpoint + " is not supported"}
+}
+
+// invocableTool returns tool toolID, unless it does not exist, was revoked
+// or an operator rule added since its registration blocks its provider or
+// one of its endpoints.
+func (h *Handler) invocableTool(r *http.Request, toolID string) (*registry.Tool, *invokeError) {
+	if toolID == "" {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "tool_id is required"}
+	}
+	tool, err := h.reg.GetTool(r.Context(), toolID)
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			return nil, &invokeError{status: http.StatusNotFound, code: "TOOL_NOT_FOUND", msg: "tool not found"}
+		}
+		return nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	if ierr := h.checkInvocable(r, tool); ierr != nil {
+		return nil, ierr
+	}
+	return tool, nil
+}
+
+// checkInvocable refuses tool when it was revoked or an operator rule added
+// since its registration blocks its provider or one of its endpoints.
+func (h *Handler) checkInvocable(r *http.Request, tool *registry.Tool) *invokeError {
+	if err := tool.Invocable(); err != nil {
+		return &invokeError{status: http.StatusGone, code: "TOOL_REVOKED", msg: err.Error()}
+	}
+	if err := h.reg.CheckProvider(r.Context(), tool.ProviderID, tool.AllEndpoints()...); err != nil {
+		if errors.Is(err, registry.ErrProviderBlocked) {
+			return &invokeError{status: http.StatusForbidden, code: "PROVIDER_BLOCKED", msg: err.Error()}
+		}
+		return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	return nil
+}
+
+// runner returns how to run tool, or nil when the registry cannot run it.
+func (h *Handler) runner(tool *registry.Tool) runFunc {
+	if run := h.sandboxRunner(tool); run != nil {
+		return run
+	}
+	if run := h.pushRunner(tool); run != nil {
+		return run
+	}
+	if run := h.jsonrpcRunner(tool); run != nil {
+		return run
+	}
+	if run := h.mcpRunner(tool); run != nil {
+		return run
+	}
+	return h.httpRunner(tool)
+}
+
+// httpRunner returns how to proxy an invocation to a tool served over
+// plain HTTP, or nil when tool has another kind of endpoint. A tool with
+// several endpoints is failed over from one to the next, starting from the
+// next in turn when it is routed round-robin. Tools that stream are asked
+// for an event stream.
+func (h *Handler) httpRunner(tool *registry.Tool) runFunc {
+	if !router.Routable(tool.Endpoint) {
+		return nil
+	}
+	return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+		header, err := h.endpointHeader(ctx, tool.ID, input)
+		if err != nil {
+			return nil, err
+		}
+		if tool.Has(registry.FeatureStreaming) {
+			if header == nil {
+				header = http.Header{}
+			}
+			header.Set("Accept", router.AcceptStream)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if len(tool.Endpoints) == 0 {
+			return h.router.Invoke(ctx, tool.Endpoint, header, input)
+		}
+		endpoints := tool.AllEndpoints()
+		if tool.Routing == registry.RoutingRoundRobin {
+			endpoints = h.balancer.Order(tool.ID, endpoints)
+		}
+		out, served, err := h.router.InvokeAny(ctx, endpoints, header, input)
+		setServed(ctx, served)
+		return out, err
+	}
+}
+
+type servedKey struct{}
+
+// withServed returns a context in which a runner can report, with
+// setServed, which endpoint of a tool with several served an invocation.
+func withServed(ctx context.Context) (context.Context, *string) {
+	served := new(string)
+	return context.WithValue(ctx, servedKey{}, served), served
+}
+
+func setServed(ctx context.Context, endpoint string) {
+	if served, ok := ctx.Value(servedKey{}).(*string); ok {
+		*served = endpoint
+	}
+}
+
+// withPayloadStorage marks the context of r for storing the payload of req
+// when it asks for it, keyed to the caller or to the tool's provider.
+func withPayloadStorage(r *http.Request, tool *registry.Tool, req *registry.InvokeRequest) (*http.Request, *invokeError) {
+	var owner string
+	switch req.StorePayload {
+	case "":
+		return r, nil
+	case registry.PayloadConsumer:
+		owner = providerIDFromRequest(r)
+	case registry.PayloadProvider:
+		owner = tool.ProviderID
+	default:
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY",
+			msg: "store_payload must be consumer or provider"}
+	}
+	return r.WithContext(registry.WithPayloadStorage(r.Context(), owner)), nil
+}
+
+// withMetadata records the metadata of req on the context of r, for the
+// invocation record and response to hold.
+func withMetadata(r *http.Request, req *registry.InvokeRequest) (*http.Request, *invokeError) {
+	if err := req.Metadata.Validate(); err != nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	return r.WithContext(registry.WithMetadata(r.Context(), req.Metadata)), nil
+}
+
+// withBudget records the budget of req on the context of r, for the
+// invocation's actual cost to be checked against once it is known.
+func withBudget(r *http.Request, req *registry.InvokeRequest) *http.Request {
+	if req.BudgetCLAW == "" {
+		return r
+	}
+	return r.WithContext(registry.WithBudget(r.Context(), req.BudgetCLAW))
+}
+
+// startInvocation claims the nonce of the consumer signature of req, if
+// any, counts an invocation of tool against the caller's quota, records it
+// and returns its ID and the encoded input. Every invocation path passes
+// through it, so none lets a signed request be replayed. Dry runs, which only
+// POST /v1/invoke supports, callbacks, which only async invocations
+// support, mock invocations, which are only answered synchronously,
+// invocations estimated to cost more than their budget, those operator
+// policies do not allow and, when payments are settled on chain, paid ones
+// whose caller did not prove its identity and those whose estimated cost
+// the consumer's escrow account cannot hold are refused.
+func (h *Handler) startInvocation(r *http.Request, tool *registry.Tool, req *registry.InvokeRequest) (string, []byte, *invokeError) {
+	if req.DryRun {
+		return "", nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "dry_run is only supported by POST /v1/invoke"}
+	}
+	if req.Mock {
+		return "", nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "mock is only supported by sync invocations"}
+	}
+	if req.CallbackURL != "" && !registry.IsQueued(r.Context()) {
+		return "", nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY",
+			msg: "callback_url is only supported by POST /v1/invoke?mode=async"}
+	}
+	if ierr := h.claimConsumerNonce(r, req); ierr != nil {
+		return "", nil, ierr
+	}
+	if ierr := h.checkPayer(r, tool, req); ierr != nil {
+		return "", nil, ierr
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return "", nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	estimate := registry.EstimateCost(tool, b)
+	if err := registry.CheckBudget(estimate, req.BudgetCLAW); err != nil {
+		return "", nil, budgetError(err)
+	}
+	if err := h.reg.CheckPolicies(r.Context(), tool, providerIDFromRequest(r), estimate); err != nil {
+		return "", nil, policyError(err)
+	}
+	reset, err := h.reg.TakeQuota(r.Context(), tool, providerIDFromRequest(r))
+	if err != nil {
+		if errors.Is(err, registry.ErrQuotaExceeded) {
+			return "", nil, &invokeError{status: http.StatusTooManyRequests, code: "TOOL_QUOTA_EXCEEDED", msg: err.Error(),
+				retryAfter: time.Until(reset)}
+		}
+		return "", nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	ctx := r.Context()
+	if tool.Pricing != nil && tool.Pricing.Model != registry.PricingFree {
+		// A paid invocation is recorded before the provider runs, so a
+		// crash cannot lose the record of a call that will be billed.
+		ctx = registry.WithSyncWrites(ctx)
+	}
+	id, err := h.reg.RecordInvocation(ctx, tool.ID, providerIDFromRequest(r), input)
+	if err != nil {
+		if errors.Is(err, registry.ErrLimitExceeded) {
+			return "", nil, &invokeError{status: http.StatusRequestEntityTooLarge, code: "LIMIT_EXCEEDED", msg: err.Error()}
+		}
+		if errors.Is(err, registry.ErrPayloadStorage) {
+			return "", nil, &invokeError{status: http.StatusBadRequest, code: "PAYLOAD_STORAGE_UNAVAILABLE", msg: err.Error()}
+		}
+		return "", nil, idempotencyError(err)
+	}
+	if err := h.reg.HoldEscrow(ctx, id, providerIDFromRequest(r), estimate); err != nil {
+		if ferr := h.reg.FailInvocation(context.WithoutCancel(ctx), id, err.Error()); ferr != nil {
+			h.logger(r).Error("fail invocation", zap.String("invocation_id", id), zap.Error(ferr))
+		}
+		ierr := walletError(err)
+		ierr.invocationID = id
+		return "", nil, ierr
+	}
+	return id, b, nil
+}
+
+// fromCache completes invocation id with a cached result of tool for input,
+// or returns nil on a cache miss.
+func (h *Handler) fromCache(r *http.Request, tool *registry.Tool, id string, input []byte) *registry.InvokeResponse {
+	out, ok := h.reg.CachedResult(r.Context(), tool, input)
+	if !ok {
+		return nil
+	}
+	var output map[string]any
+	if err := json.Unmarshal(out, &output); err != nil || output == nil {
+		return nil
+	}
+	cost := tool.CachedPrice()
+	if err := h.reg.CompleteCachedInvocation(r.Context(), id, registry.HashPayload(output), cost); err != nil {
+		h.logger(r).Error("complete invocation", zap.String("invocation_id", id), zap.Error(err))
+	}
+	if err := h.reg.StorePayloadOutput(r.Context(), id, out); err != nil {
+		h.logger(r).Error("store payload output", zap.String("invocation_id", id), zap.Error(err))
+	}
+	if err := h.reg.StoreIdempotentOutput(r.Context(), id, out); err != nil {
+		h.logger(r).Error("store idempotent output", zap.String("invocation_id", id), zap.Error(err))
+	}
+	return &registry.InvokeResponse{
+		InvocationID: id,
+		ToolID:       tool.ID,
+		Output:       output,
+		CostCLAW:     cost,
+		Cached:       true,
+		CacheHit:     true,
+		Metadata:     registry.MetadataFrom(r.Context()),
+		Receipt:      h.receipt(r.Context(), r, id),
+	}
+}
+
+// finishInvocation records the outcome of invocation id, which returned out
+// for input or failed with runErr after elapsed.
+func (h *Handler) finishInvocation(
+	r *http.Request, tool *registry.Tool, id string, input, out []byte, runErr error, elapsed time.Duration,
+) (*registry.InvokeResponse, *invokeError) {
+	// The outcome is recorded even when the caller has gone away or
+	// canceled the invocation.
+	ctx := context.WithoutCancel(r.Context())
+	var (
+		output map[string]any
+		cost   string
+		sig    string
+	)
+	if runErr == nil {
+		if err := json.Unmarshal(out, &output); err != nil || output == nil {
+			runErr = errOutput
+		} else if runErr = checkOutput(tool, output); runErr == nil {
+			// A per-token invocation that ran over its budget is failed
+			// rather than charged for more than the consumer agreed to.
+			cost = registry.InvocationCost(tool, input, out)
+			runErr = registry.CheckBudget(cost, registry.BudgetFrom(r.Context()))
+		}
+	}
+	if runErr == nil {
+		sig, runErr = h.checkResponseSignature(r, tool, id, input, output, cost)
+	}
+	h.reg.ObserveInvocation(ctx, tool, id, elapsed, runErr)
+	if runErr != nil {
+		fail := h.reg.FailInvocation
+		if errors.As(runErr, new(*exhaustedError)) {
+			fail = h.reg.DeadLetterInvocation
+		}
+		ierr := runError(runErr)
+		ierr.invocationID = id
+		if ierr.provider = providerError(runErr); ierr.provider != nil {
+			ctx = registry.WithProviderError(ctx, ierr.provider)
+		}
+		if err := fail(ctx, id, runErr.Error()); err != nil {
+			h.logger(r).Error("fail invocation", zap.String("invocation_id", id), zap.Error(err))
+		}
+		return nil, ierr
+	}
+
+	if err := h.reg.CompleteInvocation(ctx, id, registry.HashPayload(output), sig, cost); err != nil {
+		h.logger(r).Error("complete invocation", zap.String("invocation_id", id), zap.Error(err))
+	}
+	h.reg.WarnBudget(ctx, tool, providerIDFromRequest(r), id, cost, registry.BudgetFrom(r.Context()))
+	if err := h.reg.StorePayloadOutput(ctx, id, out); err != nil {
+		h.logger(r).Error("store payload output", zap.String("invocation_id", id), zap.Error(err))
+	}
+	if err := h.reg.StoreIdempotentOutput(ctx, id, out); err != nil {
+		h.logger(r).Error("store idempotent output", zap.String("invocation_id", id), zap.Error(err))
+	}
+	h.reg.CacheResult(ctx, tool, input, out)
+	return &registry.InvokeResponse{
+		InvocationID: id,
+		ToolID:       tool.ID,
+		Output:       output,
+		CostCLAW:     cost,
+		DurationMS:   elapsed.Milliseconds(),
+		Metadata:     registry.MetadataFrom(r.Context()),
+		Receipt:      h.receipt(ctx, r, id),
+	}, nil
+}
+
+// checkOutput returns an error wrapping errSchemaViolation when output does
+// not conform to the output schema of tool. Tools that declare none, or one
+// that is not JSON, may return any object.
+func checkOutput(tool *registry.Tool, output map[string]any) error {
+	var verr *jsonschema.ValidationError
+	if err := jsonschema.Validate(tool.Schema.Output, output); errors.As(err, &verr) {
+		return fmt.Errorf("%w: %v", errSchemaViolation, verr)
+	}
+	return nil
+}
+
+// runError maps the error a tool failed with to its API error.
+func runError(err error) *invokeError {
+	if errors.Is(err, errSchemaViolation) {
+		return &invokeError{status: http.StatusBadGateway, code: "SCHEMA_VIOLATION", msg: err.Error()}
+	}
+	if errors.Is(err, registry.ErrBudgetExceeded) {
+		return budgetError(err)
+	}
+	if errors.Is(err, registry.ErrInvalidResponseSignature) {
+		return &invokeError{status: http.StatusBadGateway, code: "INVALID_RESPONSE_SIGNATURE", msg: err.Error()}
+	}
+	if errors.Is(err, sandbox.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return &invokeError{status: http.StatusRequestTimeout, code: "INVOKE_TIMEOUT", msg: err.Error()}
+	}
+	if errors.Is(err, push.ErrOffline) {
+		return &invokeError{status: http.StatusServiceUnavailable, code: "PROVIDER_OFFLINE", msg: err.Error()}
+	}
+	if errors.Is(err, router.ErrCircuitOpen) {
+		return &invokeError{status: http.StatusServiceUnavailable, code: "CIRCUIT_OPEN", msg: err.Error()}
+	}
+	return &invokeError{status: http.StatusBadGateway, code: "TOOL_FAILED", msg: err.Error()}
+}
+
+// providerError returns what the provider said about the failure err: the
+// code, message and retryable flag of a JSON error body, or of a JSON-RPC
+// error. It returns nil when the provider did not answer.
+func providerError(err error) *registry.ProviderError {
+	var se *router.StatusError
+	if errors.As(err, &se) {
+		pe := &registry.ProviderError{Code: se.Code, Message: se.Message, Retryable: se.Transient(), Status: se.StatusCode}
+		if pe.Message == "" {
+			pe.Message = se.Detail
+		}
+		return pe
+	}
+	var re *jsonrpc.Error
+	if errors.As(err, &re) {
+		return &registry.ProviderError{Code: strconv.Itoa(re.Code), Message: re.Message}
+	}
+	return nil
+}
+
+// budgetError maps an error of registry.CheckBudget to its API error.
+func budgetError(err error) *invokeError {
+	if errors.Is(err, registry.ErrInvalidBudget) {
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	return &invokeError{status: http.StatusPaymentRequired, code: "BUDGET_EXCEEDED", msg: err.Error()}
+}
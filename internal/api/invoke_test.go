@@ -0,0 +1,551 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/double":
+			var in struct{ N float64 }
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &in))
+			_ = json.NewEncoder(w).Encode(map[string]any{"n": 2 * in.N})
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/list":
+			_, _ = w.Write([]byte(`[1, 2]`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	double := registerRPCTool(t, h, "double", srv.URL+"/double")
+	fail := registerRPCTool(t, h, "fail", srv.URL+"/fail")
+	list := registerRPCTool(t, h, "list", srv.URL+"/list")
+	payload := validToolPayload()
+	payload["name"] = "slow"
+	payload["endpoint"] = srv.URL + "/slow"
+	payload["timeout_ms"] = 50
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var slow registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&slow))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": double, "input": map[string]any{"n": 21}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"n": float64(42)}, resp.Output)
+	assert.Equal(t, "5.0", resp.CostCLAW)
+	assert.Equal(t, double, resp.ToolID)
+	assert.NotEmpty(t, resp.InvocationID)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": fail, "input": map[string]any{}})
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_FAILED")
+	assert.Contains(t, rr.Body.String(), "boom")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": list, "input": map[string]any{}})
+	assert.Equal(t, http.StatusBadGateway, rr.Code, "output must be a JSON object")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": slow.ID, "input": map[string]any{}})
+	assert.Equal(t, http.StatusRequestTimeout, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVOKE_TIMEOUT")
+}
+
+func TestInvoke_Quota(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	tool := registerRPCTool(t, h, "limited", srv.URL)
+
+	rr := doRequest(t, h, http.MethodPut, "/v1/tools/"+tool+"/quota", map[string]any{"calls": 0, "period": "hour"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_QUOTA")
+	quota := map[string]any{"calls": 1, "period": "hour"}
+	rr = doAs(t, h, http.MethodPut, "/v1/tools/"+tool+"/quota", "did:claw:agent:someone-else", "", quota)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "only the provider sets quotas")
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+tool+"/quota", quota)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	invoke := map[string]any{"tool_id": tool, "input": map[string]any{}}
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", invoke)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", invoke)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_QUOTA_EXCEEDED")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{invoke}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_QUOTA_EXCEEDED")
+
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", "did:claw:agent:someone-else", "", invoke)
+	assert.Equal(t, http.StatusOK, rr.Code, "other consumers have their own quota")
+
+	rr = doRequest(t, h, http.MethodDelete, "/v1/tools/"+tool+"/quota", nil)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", invoke)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestInvoke_DryRun(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	payload["schema"] = map[string]any{"input": map[string]any{
+		"type": "object", "required": []string{"city"}, "properties": map[string]any{"city": map[string]any{"type": "string"}},
+	}}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	rr = doRequest(t, h, http.MethodPut, "/v1/tools/"+tool.ID+"/quota", map[string]any{"calls": 1, "period": "day"})
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	dry := map[string]any{"tool_id": tool.ID, "input": map[string]any{"city": "Oslo"}, "dry_run": true}
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", dry)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.DryRunResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.True(t, resp.DryRun)
+	assert.Equal(t, "5.0", resp.CostCLAW)
+	require.NotNil(t, resp.QuotaRemaining)
+	assert.EqualValues(t, 1, *resp.QuotaRemaining, "dry runs do not count against the quota")
+	assert.Zero(t, calls.Load(), "the provider is not called")
+
+	for _, tc := range []struct {
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{map[string]any{"tool_id": tool.ID, "input": map[string]any{"city": 3}, "dry_run": true}, http.StatusBadRequest, "INVALID_INPUT"},
+		{map[string]any{"tool_id": tool.ID, "input": map[string]any{"city": "Oslo"}, "budget_claw": "1", "dry_run": true},
+			http.StatusPaymentRequired, "BUDGET_EXCEEDED"},
+		{map[string]any{"tool_id": tool.ID, "input": map[string]any{"city": "Oslo"}, "budget_claw": "lots", "dry_run": true},
+			http.StatusBadRequest, "INVALID_BODY"},
+		{map[string]any{"tool_id": "did:claw:tool:missing", "dry_run": true}, http.StatusNotFound, "TOOL_NOT_FOUND"},
+	} {
+		rr = doRequest(t, h, http.MethodPost, "/v1/invoke", tc.body)
+		assert.Equal(t, tc.status, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), tc.code)
+	}
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{dry}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "dry_run is only supported by POST /v1/invoke")
+
+	delete(dry, "dry_run")
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", dry)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	dry["dry_run"] = true
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", dry)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "the quota is used up")
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestInvoke_Failover(t *testing.T) {
+	replica := func(name string, status int) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"replica": "` + name + `"}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	down := replica("down", http.StatusBadGateway)
+	eu, us := replica("eu", http.StatusOK), replica("us", http.StatusOK)
+	h := newTestHandler(t)
+
+	register := func(name, routing string, endpoints ...string) string {
+		payload := validToolPayload()
+		payload["name"], payload["endpoint"], payload["endpoints"] = name, endpoints[0], endpoints[1:]
+		if routing != "" {
+			payload["routing"] = routing
+		}
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		return tool.ID
+	}
+	invoke := func(id string) registry.InvokeResponse {
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{}})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp registry.InvokeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+
+	failover := register("failover", "", down.URL, eu.URL)
+	resp := invoke(failover)
+	assert.Equal(t, "eu", resp.Output["replica"])
+	rr := doRequest(t, h, http.MethodGet, "/v1/invocations/"+resp.InvocationID, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var inv registry.Invocation
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&inv))
+	assert.Equal(t, eu.URL, inv.Endpoint, "the endpoint that served it is recorded")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", map[string]any{
+		"name": "bad", "version": "1.0.0", "endpoint": eu.URL, "endpoints": []string{eu.URL},
+		"schema": map[string]any{"input": map[string]any{"type": "object"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ENDPOINT")
+
+	balanced := register("balanced", registry.RoutingRoundRobin, eu.URL, us.URL)
+	served := map[any]int{}
+	for i := 0; i < 4; i++ {
+		served[invoke(balanced).Output["replica"]]++
+	}
+	assert.Equal(t, map[any]int{"eu": 2, "us": 2}, served)
+}
+
+func TestInvoke_Retry(t *testing.T) {
+	var flaky atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" || (r.URL.Path == "/flaky" && flaky.Add(1) < 3) {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/final" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error": {"code": "SUSPENDED", "message": "account suspended", "retryable": false}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+
+	register := func(path string) string {
+		payload := validToolPayload()
+		payload["name"], payload["endpoint"] = "retry"+path[1:], srv.URL+path
+		payload["retry"] = map[string]any{"max_attempts": 3, "backoff_ms": 1}
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		return tool.ID
+	}
+	getInvocation := func(id string) registry.Invocation {
+		rr := doRequest(t, h, http.MethodGet, "/v1/invocations/"+id, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var inv registry.Invocation
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&inv))
+		return inv
+	}
+	invoke := func(id string) (*httptest.ResponseRecorder, string) {
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{}})
+		var resp struct {
+			InvocationID string `json:"invocation_id"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return rr, resp.InvocationID
+	}
+
+	rr, id := invoke(register("/flaky"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	inv := getInvocation(id)
+	assert.Equal(t, "completed", inv.Status)
+	assert.Equal(t, 3, inv.Attempts)
+
+	down := register("/down")
+	rr, _ = invoke(down)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "after 3 attempts")
+
+	rr, _ = invoke(register("/broken"))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "attempts", "500 is not retried by default")
+	rr, _ = invoke(register("/final"))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "attempts", "the provider said retrying is futile")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?status=dead_letter", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var list registry.InvocationList
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Invocations, 1)
+	assert.Equal(t, down, list.Invocations[0].ToolID)
+	assert.Equal(t, registry.StatusDeadLetter, list.Invocations[0].Status)
+	assert.Equal(t, 3, list.Invocations[0].Attempts)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?status=gone", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	payload := validToolPayload()
+	payload["name"], payload["retry"] = "bad-retry", map[string]any{"max_attempts": 0}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_RETRY_POLICY")
+}
+
+func TestInvoke_OutputSchemaViolation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			_, _ = w.Write([]byte(`{"score": "high"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"score": 0.9}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	register := func(path string) string {
+		payload := validToolPayload()
+		payload["name"], payload["endpoint"] = "score"+path[1:], srv.URL+path
+		payload["schema"] = map[string]any{
+			"input": map[string]any{"type": "object"},
+			"output": map[string]any{"type": "object", "required": []string{"score"},
+				"properties": map[string]any{"score": map[string]any{"type": "number"}}},
+		}
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		return tool.ID
+	}
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": register("/good"), "input": map[string]any{}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	bad := register("/bad")
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": bad, "input": map[string]any{}})
+	require.Equal(t, http.StatusBadGateway, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "SCHEMA_VIOLATION")
+	assert.Contains(t, rr.Body.String(), "/score")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?status=failed", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var list registry.InvocationList
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Invocations, 1, "malformed output is not returned to the consumer")
+	assert.Equal(t, bad, list.Invocations[0].ToolID)
+}
+
+func TestInvoke_Budget(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"text": "summary", "usage": {"tokens": 500}}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	perCall := registerRPCTool(t, h, "per-call", srv.URL)
+	payload := validToolPayload()
+	payload["name"], payload["endpoint"] = "per-token", srv.URL
+	payload["pricing"] = map[string]any{"model": "per_token", "amount_claw": "0.01"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var perToken registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&perToken))
+
+	invoke := func(id, budget string) *httptest.ResponseRecorder {
+		return doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{}, "budget_claw": budget})
+	}
+	rr = invoke(perCall, "4.99")
+	assert.Equal(t, http.StatusPaymentRequired, rr.Code)
+	assert.Contains(t, rr.Body.String(), "BUDGET_EXCEEDED")
+	assert.Zero(t, calls.Load(), "refused before dispatch")
+	rr = invoke(perCall, "lots")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = invoke(perCall, "5")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = invoke(perToken.ID, "10")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "5", resp.CostCLAW, "500 tokens at 0.01 CLAW")
+
+	rr = invoke(perToken.ID, "4")
+	assert.Equal(t, http.StatusPaymentRequired, rr.Code, "the tokens used cost more than the budget")
+	assert.Contains(t, rr.Body.String(), "costs 5 CLAW, budget is 4")
+	assert.NotContains(t, rr.Body.String(), "summary", "the output is withheld")
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestInvoke_IdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"call": %d}`, n)
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	toolID := registerRPCTool(t, h, "idempotent", srv.URL)
+	invoke := func(input map[string]any, header string) *httptest.ResponseRecorder {
+		body := mustEncode(t, map[string]any{"tool_id": toolID, "input": input})
+		req := httptest.NewRequest(http.MethodPost, "/v1/invoke", body)
+		req.Header.Set("Authorization", "Bearer "+testCaller)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", header)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := invoke(map[string]any{"q": 1}, "key-1")
+	require.Equal(t, http.StatusBadGateway, rr.Code, "a failed invocation releases its key")
+	rr = invoke(map[string]any{"q": 1}, "key-1")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var first registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&first))
+	assert.False(t, first.Replayed)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{
+		"tool_id": toolID, "input": map[string]any{"q": 1}, "idempotency_key": "key-1",
+	})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var replay registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&replay))
+	assert.True(t, replay.Replayed)
+	assert.Equal(t, first.InvocationID, replay.InvocationID)
+	assert.Equal(t, first.Output, replay.Output)
+	assert.Equal(t, first.CostCLAW, replay.CostCLAW)
+	assert.Equal(t, int32(2), calls.Load(), "replays do not reach the provider")
+
+	rr = invoke(map[string]any{"q": 2}, "key-1")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "IDEMPOTENCY_CONFLICT")
+
+	rr = invoke(map[string]any{"q": 1}, "key-2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestInvoke_ProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"error": {"code": "UNKNOWN_TICKER", "message": "no such ticker: XYZ", "retryable": false}}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	toolID := registerRPCTool(t, h, "ticker", srv.URL)
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": toolID, "input": map[string]any{}})
+	require.Equal(t, http.StatusBadGateway, rr.Code)
+	var body struct {
+		Error struct {
+			Code     string                  `json:"code"`
+			Provider *registry.ProviderError `json:"provider"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.Equal(t, "TOOL_FAILED", body.Error.Code)
+	want := &registry.ProviderError{Code: "UNKNOWN_TICKER", Message: "no such ticker: XYZ", Status: http.StatusUnprocessableEntity}
+	assert.Equal(t, want, body.Error.Provider)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?status=failed", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list registry.InvocationList
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Invocations, 1)
+	assert.Equal(t, want, list.Invocations[0].ProviderError)
+}
+
+func TestInvoke_Streaming(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/json" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"streamed": false})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"progress\": 0.5}\n\ndata: {\"streamed\": true}\n\n")
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+
+	invoke := func(name string, features []string) map[string]any {
+		payload := validToolPayload()
+		payload["name"], payload["endpoint"], payload["features"] = name, srv.URL, features
+		rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool.ID, "input": map[string]any{}})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp registry.InvokeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp.Output
+	}
+	assert.Equal(t, map[string]any{"streamed": true}, invoke("streams", []string{registry.FeatureStreaming}))
+	assert.Equal(t, map[string]any{"streamed": false}, invoke("plain", nil))
+
+	payload := validToolPayload()
+	payload["features"] = []string{"telepathy"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_FEATURES")
+}
+
+func TestInvoke_Metadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	invoke := func(metadata map[string]string) *httptest.ResponseRecorder {
+		return doRequest(t, h, http.MethodPost, "/v1/invoke",
+			map[string]any{"tool_id": tool.ID, "input": map[string]any{}, "metadata": metadata})
+	}
+	rr = invoke(map[string]string{"task_id": "t1", "trace": "abc"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, registry.Metadata{"task_id": "t1", "trace": "abc"}, resp.Metadata)
+	require.Equal(t, http.StatusOK, invoke(map[string]string{"task_id": "t2"}).Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?metadata.task_id=t1", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var list registry.InvocationList
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Invocations, 1)
+	assert.Equal(t, resp.InvocationID, list.Invocations[0].ID)
+	assert.Equal(t, resp.Metadata, list.Invocations[0].Metadata)
+
+	rr = invoke(map[string]string{"bad key": "x"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_BODY")
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations?metadata.bad%20key=x", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_QUERY")
+}
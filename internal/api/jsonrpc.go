@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/jsonrpc"
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// maxBatch bounds the invocations of one POST /v1/invoke/batch.
+const maxBatch = 50
+
+// jsonrpcRunner returns how to call a tool served by a JSON-RPC 2.0
+// provider, or nil when tool has another kind of endpoint.
+func (h *Handler) jsonrpcRunner(tool *registry.Tool) runFunc {
+	target, method, ok := registry.JSONRPCEndpoint(tool.Endpoint)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+		header, err := h.endpointHeader(ctx, tool.ID, input)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return h.rpc.Call(ctx, target, header, method, input)
+	}
+}
+
+// endpointHeader returns the endpoint auth of a tool and the consumer and
+// registry signatures of its call with input, if any, as request headers.
+func (h *Handler) endpointHeader(ctx context.Context, toolID string, input []byte) (http.Header, error) {
+	auth, err := h.reg.EndpointAuth(ctx, toolID)
+	if err != nil {
+		return nil, err
+	}
+	var header http.Header
+	if auth != nil {
+		name, value := auth.Render()
+		header = http.Header{name: {value}}
+	}
+	return h.setCallSignature(ctx, setConsumerSignature(ctx, header), toolID, input), nil
+}
+
+type batchInvokeRequest struct {
+	Invocations []registry.InvokeRequest `json:"invocations"`
+}
+
+// batchResult is the outcome of one invocation of a batch: the response of
+// POST /v1/invoke, or the error it would have returned.
+type batchResult struct {
+	InvocationID string         `json:"invocation_id,omitempty"`
+	ToolID       string         `json:"tool_id"`
+	Output       map[string]any `json:"output,omitempty"`
+	CostCLAW     string         `json:"cost_claw,omitempty"`
+	DurationMS   int64          `json:"duration_ms,omitempty"`
+	Cached       bool           `json:"cached,omitempty"`
+	CacheHit     bool           `json:"cache_hit,omitempty"`
+	Error        *batchError    `json:"error,omitempty"`
+}
+
+type batchError struct {
+	Code     string                  `json:"code"`
+	Message  string                  `json:"message"`
+	Provider *registry.ProviderError `json:"provider,omitempty"`
+}
+
+func newBatchResult(toolID string, resp *registry.InvokeResponse, ierr *invokeError) batchResult {
+	if ierr != nil {
+		return batchResult{InvocationID: ierr.invocationID, ToolID: toolID, Error: &batchError{ierr.code, ierr.msg, ierr.provider}}
+	}
+	return batchResult{
+		InvocationID: resp.InvocationID,
+		ToolID:       resp.ToolID,
+		Output:       resp.Output,
+		CostCLAW:     resp.CostCLAW,
+		DurationMS:   resp.DurationMS,
+		Cached:       resp.Cached,
+		CacheHit:     resp.CacheHit,
+	}
+}
+
+// rpcGroup is the invocations of a batch sent to one JSON-RPC endpoint
+// with the same credentials, which go out as one JSON-RPC batch.
+type rpcGroup struct {
+	target  string
+	header  http.Header
+	items   []int
+	reqs    []*http.Request
+	tools   []*registry.Tool
+	calls   []jsonrpc.Call
+	timeout time.Duration
+}
+
+// invokeBatch handles POST /v1/invoke/batch. Each invocation succeeds or
+// fails on its own; unsigned invocations of JSON-RPC tools with the batch
+// feature that share an endpoint are sent to it as one JSON-RPC batch.
+func (h *Handler) invokeBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchInvokeRequest
+	if !decodeBody(w, r, 0, &req) {
+		return
+	}
+	if len(req.Invocations) == 0 || len(req.Invocations) > maxBatch {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "invocations must hold 1 to 50 requests")
+		return
+	}
+
+	results := make([]batchResult, len(req.Invocations))
+	var groups []*rpcGroup
+	byKey := map[string]*rpcGroup{}
+	for i := range req.Invocations {
+		inv := &req.Invocations[i]
+		if ierr := h.route(r, inv); ierr != nil {
+			results[i] = newBatchResult(inv.ToolID, nil, ierr)
+			continue
+		}
+		tool, _, ierr := h.resolve(r, inv.ToolID)
+		if ierr != nil {
+			results[i] = newBatchResult(inv.ToolID, nil, ierr)
+			continue
+		}
+		// A signed invocation is sent on its own, with its signature.
+		target, method, ok := registry.JSONRPCEndpoint(tool.Endpoint)
+		if !ok || !tool.Has(registry.FeatureBatch) || inv.ConsumerSignature != nil {
+			resp, ierr := h.invoke(r, inv)
+			results[i] = newBatchResult(inv.ToolID, resp, ierr)
+			continue
+		}
+		header, err := h.endpointHeader(r.Context(), tool.ID, nil)
+		if err != nil {
+			results[i] = newBatchResult(inv.ToolID, nil,
+				&invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()})
+			continue
+		}
+		ir, ierr := withPayloadStorage(r, tool, inv)
+		if ierr == nil {
+			ir, ierr = withMetadata(ir, inv)
+		}
+		if ierr != nil {
+			results[i] = newBatchResult(inv.ToolID, nil, ierr)
+			continue
+		}
+		ir = withBudget(ir, inv)
+		ir, replayed, ierr := h.withIdempotencyKey(ir, tool, inv)
+		if replayed != nil || ierr != nil {
+			results[i] = newBatchResult(inv.ToolID, replayed, ierr)
+			continue
+		}
+		id, input, ierr := h.startInvocation(ir, tool, inv)
+		if ierr != nil {
+			results[i] = newBatchResult(inv.ToolID, nil, ierr)
+			continue
+		}
+		if resp := h.fromCache(ir, tool, id, input); resp != nil {
+			results[i] = newBatchResult(inv.ToolID, resp, nil)
+			continue
+		}
+		results[i].InvocationID = id
+		key := target + "\n" + headerKey(header)
+		g := byKey[key]
+		if g == nil {
+			g = &rpcGroup{target: target, header: header}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.items = append(g.items, i)
+		g.reqs = append(g.reqs, ir)
+		g.tools = append(g.tools, tool)
+		g.calls = append(g.calls, jsonrpc.Call{Method: method, Params: input})
+		// The batch gets the most generous timeout of its tools.
+		g.timeout = max(g.timeout, time.Duration(tool.TimeoutMS)*time.Millisecond)
+	}
+
+	for _, g := range groups {
+		h.sendGroup(r, g, results)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// sendGroup sends the calls of g as one JSON-RPC batch and records each
+// invocation's outcome in results.
+func (h *Handler) sendGroup(r *http.Request, g *rpcGroup, results []batchResult) {
+	ctx, cancel := context.WithTimeout(r.Context(), g.timeout)
+	start := time.Now()
+	out, err := h.rpc.Batch(ctx, g.target, g.header, g.calls)
+	cancel()
+	elapsed := time.Since(start)
+	for j, i := range g.items {
+		var value []byte
+		runErr := err
+		if err == nil {
+			value, runErr = out[j].Value, out[j].Err
+		}
+		resp, ierr := h.finishInvocation(g.reqs[j], g.tools[j], results[i].InvocationID, g.calls[j].Params, value, runErr, elapsed)
+		results[i] = newBatchResult(g.tools[j].ID, resp, ierr)
+	}
+}
+
+// headerKey identifies the credentials in header, so calls are only
+// batched with calls that authenticate the same way.
+func headerKey(header http.Header) string {
+	for name, values := range header {
+		return name + ":" + values[0]
+	}
+	return ""
+}
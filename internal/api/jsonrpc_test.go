@@ -0,0 +1,187 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcProvider is a JSON-RPC 2.0 provider: "double" doubles params.n and
+// "fail" returns an error. It counts the HTTP requests it receives.
+func rpcProvider(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	answer := func(req map[string]any) map[string]any {
+		if req["method"] == "fail" {
+			return map[string]any{"jsonrpc": "2.0", "id": req["id"], "error": map[string]any{"code": -32000, "message": "boom"}}
+		}
+		n := req["params"].(map[string]any)["n"].(float64)
+		return map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": map[string]any{"n": 2 * n}}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		var batch []map[string]any
+		if json.Unmarshal(body, &batch) == nil {
+			out := make([]any, len(batch))
+			for i, req := range batch {
+				out[i] = answer(req)
+			}
+			_ = json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req map[string]any
+		require.NoError(t, json.Unmarshal(body, &req))
+		_ = json.NewEncoder(w).Encode(answer(req))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func registerRPCTool(t *testing.T, h http.Handler, name, endpoint string) string {
+	t.Helper()
+	payload := validToolPayload()
+	payload["name"] = name
+	payload["endpoint"] = endpoint
+	if strings.HasPrefix(endpoint, registry.JSONRPCScheme) {
+		payload["features"] = []string{registry.FeatureBatch}
+	}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	return tool.ID
+}
+
+func TestInvoke_JSONRPC(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	double := registerRPCTool(t, h, "double", registry.JSONRPCScheme+srv.URL+"/rpc#double")
+	fail := registerRPCTool(t, h, "fail", registry.JSONRPCScheme+srv.URL+"/rpc#fail")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": double, "input": map[string]any{"n": 21}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"n": float64(42)}, resp.Output)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": fail, "input": map[string]any{}})
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "json-rpc error -32000: boom")
+}
+
+func TestInvokeBatch_JSONRPC(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	double := registerRPCTool(t, h, "double", registry.JSONRPCScheme+srv.URL+"/rpc#double")
+	fail := registerRPCTool(t, h, "fail", registry.JSONRPCScheme+srv.URL+"/rpc#fail")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{
+		map[string]any{"tool_id": double, "input": map[string]any{"n": 1}},
+		map[string]any{"tool_id": "missing"},
+		map[string]any{"tool_id": fail},
+		map[string]any{"tool_id": double, "input": map[string]any{"n": 2}},
+	}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int32(1), requests.Load(), "calls to one endpoint share a JSON-RPC batch")
+
+	var body struct {
+		Results []struct {
+			InvocationID string                 `json:"invocation_id"`
+			ToolID       string                 `json:"tool_id"`
+			Output       map[string]any         `json:"output"`
+			Error        *struct{ Code string } `json:"error"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	require.Len(t, body.Results, 4)
+	assert.Equal(t, map[string]any{"n": float64(2)}, body.Results[0].Output)
+	assert.Equal(t, "TOOL_NOT_FOUND", body.Results[1].Error.Code)
+	assert.Equal(t, "TOOL_FAILED", body.Results[2].Error.Code)
+	assert.NotEmpty(t, body.Results[2].InvocationID)
+	assert.Equal(t, map[string]any{"n": float64(4)}, body.Results[3].Output)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Tools that do not declare the batch feature are called one by one.
+	payload := validToolPayload()
+	payload["name"], payload["endpoint"] = "single", registry.JSONRPCScheme+srv.URL+"/rpc#double"
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var single registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&single))
+	requests.Store(0)
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{
+		map[string]any{"tool_id": single.ID, "input": map[string]any{"n": 1}},
+		map[string]any{"tool_id": single.ID, "input": map[string]any{"n": 2}},
+	}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestInvokeBatch_JSONRPCChecks(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["name"] = "double"
+	payload["endpoint"] = registry.JSONRPCScheme + srv.URL + "/rpc#double"
+	payload["features"] = []string{registry.FeatureBatch}
+	payload["cache"] = map[string]any{"deterministic": true, "ttl_seconds": 60}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	batch := func(invocations ...map[string]any) []map[string]any {
+		t.Helper()
+		for _, inv := range invocations {
+			inv["tool_id"] = tool.ID
+		}
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": invocations})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var body struct {
+			Results []map[string]any `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+		return body.Results
+	}
+	code := func(result map[string]any) any {
+		if e, ok := result["error"].(map[string]any); ok {
+			return e["code"]
+		}
+		return nil
+	}
+
+	results := batch(
+		map[string]any{"input": map[string]any{"n": 1}, "idempotency_key": "k1"},
+		map[string]any{"input": map[string]any{"n": 2}, "metadata": map[string]any{"bad key!": "x"}},
+		map[string]any{"input": map[string]any{"n": 3}, "dry_run": true},
+		map[string]any{"input": map[string]any{"n": 4}, "budget_claw": "1"},
+	)
+	assert.Equal(t, map[string]any{"n": float64(2)}, results[0]["output"])
+	assert.Equal(t, "INVALID_BODY", code(results[1]), "metadata is checked per invocation")
+	assert.Equal(t, "INVALID_BODY", code(results[2]), "dry runs are only supported by POST /v1/invoke")
+	assert.Equal(t, "BUDGET_EXCEEDED", code(results[3]))
+	assert.Equal(t, int32(1), requests.Load())
+	first := results[0]["invocation_id"]
+
+	results = batch(
+		map[string]any{"input": map[string]any{"n": 1}, "idempotency_key": "k1"},
+		map[string]any{"input": map[string]any{"n": 1}},
+	)
+	assert.Equal(t, first, results[0]["invocation_id"], "the keyed invocation is replayed")
+	assert.Equal(t, map[string]any{"n": float64(2)}, results[0]["output"])
+	assert.Equal(t, true, results[1]["cached"], "a deterministic result is served from the cache")
+	assert.Equal(t, int32(1), requests.Load(), "neither reaches the provider")
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// WithKeyProofs makes providers prove, when they register, that they hold
+// the key they register: they sign a challenge from POST
+// /v1/providers/challenge. Proofs that are supplied are always checked.
+func WithKeyProofs(required bool) Option {
+	return func(h *Handler) { h.requireKeyProof = required }
+}
+
+// registerProviderRequest is the body of POST /v1/providers: the provider
+// and, to prove it holds its key, a challenge signed with it.
+type registerProviderRequest struct {
+	registry.Provider
+	Challenge          string `json:"challenge"`
+	ChallengeSignature string `json:"challenge_signature"`
+}
+
+// keyChallenge handles POST /v1/providers/challenge: a challenge for
+// registering the provider ID with the pubkey of the body.
+func (h *Handler) keyChallenge(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string `json:"id"`
+		PubKey string `json:"pubkey"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	c, err := h.reg.IssueKeyChallenge(r.Context(), req.ID, req.PubKey)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// proveKey checks the key proof of req, when one is given or required, and
+// writes the error and returns false when it fails.
+func (h *Handler) proveKey(w http.ResponseWriter, r *http.Request, req *registerProviderRequest) bool {
+	if !h.requireKeyProof && req.Challenge == "" && req.ChallengeSignature == "" {
+		return true
+	}
+	err := h.reg.ProveKey(r.Context(), &req.Provider, req.Challenge, req.ChallengeSignature)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, registry.ErrInvalidKeyProof):
+		writeError(w, http.StatusForbidden, "INVALID_KEY_PROOF", err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyProof(t *testing.T) {
+	h := newProxiedHandler(t, api.WithKeyProofs(true))
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	payload := validProviderPayload()
+	payload["pubkey"] = "ed25519:" + hex.EncodeToString(pub)
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/providers", payload)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "a registration without a proof is refused")
+	assert.Contains(t, rr.Body.String(), "INVALID_KEY_PROOF")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/challenge", map[string]any{"id": payload["id"], "pubkey": payload["pubkey"]})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var c registry.KeyChallenge
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&c))
+	require.NotEmpty(t, c.Challenge)
+
+	// A squatter cannot answer the challenge for a key it does not hold.
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	payload["challenge"] = c.Challenge
+	payload["challenge_signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(other, []byte(c.Challenge)))
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers", payload)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/challenge", map[string]any{"id": payload["id"], "pubkey": payload["pubkey"]})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&c))
+	payload["challenge"] = c.Challenge
+	payload["challenge_signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(c.Challenge)))
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "challenge")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/challenge", map[string]any{"id": payload["id"], "pubkey": "ed25519:zz"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_REQUEST")
+}
+
+func TestKeyProof_Optional(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/providers", validProviderPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// A proof that is given is checked even when none is required.
+	payload := validProviderPayload()
+	payload["id"] = "did:claw:agent:other"
+	payload["challenge"] = "unknown"
+	payload["challenge_signature"] = "c2ln"
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers", payload)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// registerBodyLimit bounds a tool registration body: both schemas, the
+// description and its translations and room for the remaining fields. It is 0 (no limit) when any
+// of those limits is disabled.
+func registerBodyLimit(l registry.Limits) int64 {
+	if l.MaxSchemaBytes == 0 || l.MaxDescriptionBytes == 0 {
+		return 0
+	}
+	return int64(2*l.MaxSchemaBytes + (1+registry.MaxDescriptions)*l.MaxDescriptionBytes + 16<<10)
+}
+
+// decodeBody decodes the JSON request body into v, reading at most limit
+// bytes (0 for no limit). On failure it writes the error response and
+// returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, limit int64, v any) bool {
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	err := json.NewDecoder(body).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "LIMIT_EXCEEDED",
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "INVALID_BODY", "invalid JSON")
+	return false
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// localize sets the descriptions of tools to those in the language the
+// request's Accept-Language prefers, and returns the language of the last
+// one.
+func localize(w http.ResponseWriter, r *http.Request, tools ...*registry.Tool) string {
+	w.Header().Add("Vary", "Accept-Language")
+	accept := r.Header.Get("Accept-Language")
+	var lang string
+	for _, t := range tools {
+		lang = t.Localize(accept)
+	}
+	return lang
+}
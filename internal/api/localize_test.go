@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTool_AcceptLanguage(t *testing.T) {
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["descriptions"] = map[string]string{"es": "Una herramienta", "de": "Ein Werkzeug"}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", accept)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return rr
+	}
+	rr = get("/v1/tools/"+tool.ID, "es-MX, en;q=0.5")
+	assert.Equal(t, "es", rr.Header().Get("Content-Language"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Accept-Language")
+	var got registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, "Una herramienta", got.Description)
+	assert.Equal(t, "es", got.Language)
+
+	rr = get("/v1/tools/"+tool.ID, "")
+	assert.Equal(t, "en", rr.Header().Get("Content-Language"))
+	rr = get("/v1/tools", "de")
+	var list registry.SearchResult
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Tools, 1)
+	assert.Equal(t, "Ein Werkzeug", list.Tools[0].Description)
+
+	payload = validToolPayload()
+	payload["name"], payload["descriptions"] = "bad-language", map[string]string{"??": "x"}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_LANGUAGE")
+}
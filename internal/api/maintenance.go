@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Maintenance modes.
+const (
+	MaintenanceOff      = "off"
+	MaintenanceReadOnly = "read-only" // reads are served; writes and invocations get 503
+	MaintenanceFull     = "full"      // every /v1 request gets 503
+)
+
+// defaultMaintenanceRetry is the Retry-After sent when none is configured.
+const defaultMaintenanceRetry = 60
+
+// adminTokenHeader carries the token guarding /admin endpoints.
+const adminTokenHeader = "X-Admin-Token"
+
+// Maintenance is the registry's maintenance state.
+type Maintenance struct {
+	Since      time.Time `json:"since"`
+	Mode       string    `json:"mode"`
+	Reason     string    `json:"reason,omitempty"`
+	RetryAfter int       `json:"retry_after_seconds,omitempty"`
+}
+
+// WithAdminToken guards the /admin endpoints with a shared secret sent in the
+// X-Admin-Token header, and enables the maintenance toggle there.
+func WithAdminToken(token string) Option {
+	return func(h *Handler) { h.adminToken = token }
+}
+
+// maintenanceSetting is the registry setting holding the maintenance state
+// shared by the replicas.
+const maintenanceSetting = "maintenance"
+
+// maintenanceSync bounds how long a replica serves with a stale maintenance
+// state after another one changed it.
+const maintenanceSync = time.Second
+
+// SetMaintenance switches maintenance mode on every replica sharing the
+// registry database. Health and metrics endpoints stay available in every
+// mode.
+func (h *Handler) SetMaintenance(ctx context.Context, m Maintenance) error {
+	switch m.Mode {
+	case MaintenanceOff:
+	case MaintenanceReadOnly, MaintenanceFull:
+		if m.RetryAfter <= 0 {
+			m.RetryAfter = defaultMaintenanceRetry
+		}
+		m.Since = time.Now().UTC()
+	default:
+		return fmt.Errorf("unknown maintenance mode %q (want %s, %s or %s)",
+			m.Mode, MaintenanceOff, MaintenanceReadOnly, MaintenanceFull)
+	}
+	cur := &m
+	if m.Mode == MaintenanceOff {
+		cur = nil
+	}
+	if h.reg != nil {
+		var v any
+		if cur != nil {
+			v = cur
+		}
+		if err := h.reg.SetSetting(ctx, maintenanceSetting, v); err != nil {
+			return err
+		}
+	}
+	h.maintenance.Store(cur)
+	h.maintenanceRead.Store(time.Now().UnixNano())
+	return nil
+}
+
+// Maintenance returns the current maintenance state.
+func (h *Handler) Maintenance(ctx context.Context) Maintenance {
+	if m := h.currentMaintenance(ctx); m != nil {
+		return *m
+	}
+	return Maintenance{Mode: MaintenanceOff}
+}
+
+// currentMaintenance returns the maintenance in force, or nil. It re-reads
+// the state other replicas may have changed at most every maintenanceSync,
+// and keeps the last one it read when that fails.
+func (h *Handler) currentMaintenance(ctx context.Context) *Maintenance {
+	last, now := h.maintenanceRead.Load(), time.Now().UnixNano()
+	if h.reg == nil || now-last < int64(maintenanceSync) || !h.maintenanceRead.CompareAndSwap(last, now) {
+		return h.maintenance.Load()
+	}
+	var m Maintenance
+	ok, err := h.reg.Setting(ctx, maintenanceSetting, &m)
+	switch {
+	case err != nil:
+		h.log.Warn("read maintenance state", zap.Error(err))
+	case ok && m.Mode != MaintenanceOff:
+		h.maintenance.Store(&m)
+	default:
+		h.maintenance.Store(nil)
+	}
+	return h.maintenance.Load()
+}
+
+// enforceMaintenance rejects the requests the current maintenance mode does
+// not allow.
+func (h *Handler) enforceMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := h.currentMaintenance(r.Context())
+		if m == nil || (m.Mode == MaintenanceReadOnly && isReadOnlyMethod(r.Method)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeMaintenance(w, m)
+	})
+}
+
+// writeMaintenance refuses a request because of maintenance m.
+func writeMaintenance(w http.ResponseWriter, m *Maintenance) {
+	w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+	writeError(w, http.StatusServiceUnavailable, "MAINTENANCE", m.message())
+}
+
+// message describes m to refused callers.
+func (m *Maintenance) message() string {
+	msg := "registry is in " + m.Mode + " maintenance"
+	if m.Reason != "" {
+		msg += ": " + m.Reason
+	}
+	return msg
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// requireAdmin checks the admin token, or the admin scope of an API key.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.isAdmin(r) {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getMaintenance handles GET /admin/maintenance.
+func (h *Handler) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Maintenance(r.Context()))
+}
+
+// putMaintenance handles PUT /admin/maintenance.
+func (h *Handler) putMaintenance(w http.ResponseWriter, r *http.Request) {
+	var m Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "invalid JSON")
+		return
+	}
+	if err := h.SetMaintenance(r.Context(), m); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_MODE", err.Error())
+		return
+	}
+	h.logger(r).Info("maintenance mode changed", zap.String("mode", m.Mode), zap.String("reason", m.Reason))
+	writeJSON(w, http.StatusOK, h.Maintenance(r.Context()))
+}
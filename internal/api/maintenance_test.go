@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func adminRequest(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMaintenance_ReadOnly(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPut, "/admin/maintenance", "s3cret",
+		`{"mode":"read-only","reason":"backup","retry_after_seconds":120}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "reads are served")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "120", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "MAINTENANCE")
+	assert.Contains(t, rr.Body.String(), "backup")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": "x"})
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var health map[string]string
+	rr = doRequest(t, h, http.MethodGet, "/healthz", nil)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &health))
+	assert.Equal(t, api.MaintenanceReadOnly, health["maintenance"])
+}
+
+func TestMaintenance_FullAndOff(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPut, "/admin/maintenance", "s3cret", `{"mode":"full"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, doRequest(t, h, http.MethodGet, "/healthz", nil).Code)
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/maintenance", "s3cret", "")
+	assert.Contains(t, rr.Body.String(), `"mode":"full"`)
+
+	rr = adminRequest(t, h, http.MethodPut, "/admin/maintenance", "s3cret", `{"mode":"off"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, h, http.MethodGet, "/v1/tools", nil).Code)
+}
+
+func TestMaintenance_AdminAuth(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPut, "/admin/maintenance", "", `{"mode":"full"}`)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = adminRequest(t, h, http.MethodPut, "/admin/maintenance", "wrong", `{"mode":"full"}`)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, http.StatusOK, doRequest(t, h, http.MethodGet, "/v1/tools", nil).Code)
+
+	rr = adminRequest(t, h, http.MethodPut, "/admin/maintenance", "s3cret", `{"mode":"sleepy"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_MODE")
+}
+
+func TestMaintenance_NotRegisteredWithoutToken(t *testing.T) {
+	rr := doRequest(t, newTestHandler(t), http.MethodGet, "/admin/maintenance", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestMaintenance_SharedByReplicas(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	replica := func() *api.Handler {
+		return api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t), api.WithAdminToken("s3cret"))
+	}
+	a, b := replica(), replica()
+	assert.Equal(t, http.StatusOK, doRequest(t, b, http.MethodGet, "/v1/tools", nil).Code)
+
+	rr := adminRequest(t, a, http.MethodPut, "/admin/maintenance", "s3cret", `{"mode":"full","reason":"migration"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Eventually(t, func() bool {
+		return doRequest(t, b, http.MethodGet, "/v1/tools", nil).Code == http.StatusServiceUnavailable
+	}, 5*time.Second, 50*time.Millisecond, "the other replica follows")
+	assert.Equal(t, "migration", b.Maintenance(context.Background()).Reason)
+	assert.Equal(t, api.MaintenanceFull, replica().Maintenance(context.Background()).Mode, "so do replicas started later")
+
+	require.NoError(t, b.SetMaintenance(context.Background(), api.Maintenance{Mode: api.MaintenanceOff}))
+	require.Eventually(t, func() bool {
+		return doRequest(t, a, http.MethodGet, "/v1/tools", nil).Code == http.StatusOK
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// mcpRunner returns how to call a tool imported from an MCP server, or nil
+// when tool has another kind of endpoint. Each invocation opens its own
+// connection to the server and calls tools/call on it.
+func (h *Handler) mcpRunner(tool *registry.Tool) runFunc {
+	if _, _, ok := mcp.ParseToolEndpoint(tool.Endpoint); !ok {
+		return nil
+	}
+	return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return mcp.CallEndpoint(ctx, h.mcp, tool.Endpoint, input)
+	}
+}
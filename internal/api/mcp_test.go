@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// mcpBackend serves "echo", which answers its arguments as JSON, "prose",
+// which answers plain text, and "broken", which fails.
+type mcpBackend struct{}
+
+func (mcpBackend) ListTools(context.Context, string) ([]mcp.Tool, string, error) {
+	return []mcp.Tool{{Name: "echo"}, {Name: "prose"}, {Name: "broken"}}, "", nil
+}
+
+func (mcpBackend) CallTool(_ context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	switch name {
+	case "echo":
+		b, _ := json.Marshal(args)
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: string(b)}}}, nil
+	case "prose":
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "hello"}}}, nil
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: "out of order"}}, IsError: true}, nil
+}
+
+func TestInvoke_MCP(t *testing.T) {
+	srv := httptest.NewServer(mcp.NewServer(mcpBackend{}, mcp.Implementation{Name: "test", Version: "1"}, zap.NewNop()).SSEHandler(""))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	register := func(name string) string {
+		return registerRPCTool(t, h, name, mcp.ToolEndpoint(srv.URL+"/sse", name))
+	}
+	echo, prose, broken := register("echo"), register("prose"), register("broken")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": echo, "input": map[string]any{"n": 2}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"n": float64(2)}, resp.Output)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": prose, "input": map[string]any{}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	resp = registry.InvokeResponse{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"content": []any{map[string]any{"type": "text", "text": "hello"}}}, resp.Output,
+		"results that are not a JSON object come back as their content blocks")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": broken, "input": map[string]any{}})
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_FAILED")
+	assert.Contains(t, rr.Body.String(), "out of order")
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"go.uber.org/zap"
+)
+
+// invokeMock answers an invocation with "mock": true from the tool's mock
+// responder instead of its provider, which need not be reachable. Mock
+// invocations are free: they are recorded and get a receipt, marked mock,
+// but are not counted against quotas, budgets or policies, nor toward the
+// tool's reputation.
+func (h *Handler) invokeMock(r *http.Request, req *registry.InvokeRequest) (*registry.InvokeResponse, *invokeError) {
+	tool, ierr := h.invocableTool(r, req.ToolID)
+	if ierr != nil {
+		return nil, ierr
+	}
+	if tool.Mock == nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "NO_MOCK", msg: "tool has no mock responder"}
+	}
+	if req.CallbackURL != "" {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY",
+			msg: "callback_url is only supported by POST /v1/invoke?mode=async"}
+	}
+	r, ierr = withMetadata(r, req)
+	if ierr != nil {
+		return nil, ierr
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	start := time.Now()
+	output, err := tool.Mock.Respond(input)
+	if err != nil {
+		return nil, &invokeError{status: http.StatusBadRequest, code: "NO_MOCK", msg: err.Error()}
+	}
+	id, err := h.reg.RecordInvocation(r.Context(), tool.ID, providerIDFromRequest(r), input)
+	if err != nil {
+		if errors.Is(err, registry.ErrLimitExceeded) {
+			return nil, &invokeError{status: http.StatusRequestEntityTooLarge, code: "LIMIT_EXCEEDED", msg: err.Error()}
+		}
+		return nil, &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	if err := h.reg.CompleteMockInvocation(r.Context(), id, registry.HashPayload(output)); err != nil {
+		h.logger(r).Error("complete invocation", zap.String("invocation_id", id), zap.Error(err))
+	}
+	return &registry.InvokeResponse{
+		InvocationID: id,
+		ToolID:       tool.ID,
+		Output:       output,
+		DurationMS:   time.Since(start).Milliseconds(),
+		Mock:         true,
+		Metadata:     registry.MetadataFrom(r.Context()),
+		Receipt:      h.receipt(r.Context(), r, id),
+	}, nil
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_Mock(t *testing.T) {
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["mock"] = map[string]any{
+		"responses": []any{map[string]any{"when": map[string]any{"q": "ping"}, "output": map[string]any{"a": "pong"}}},
+		"template":  map[string]any{"a": "echo {{input.q}}"},
+	}
+	payload["quota"] = map[string]any{"period": "day", "calls": 1}
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	require.NotNil(t, tool.Mock)
+
+	invoke := func(q string) registry.InvokeResponse {
+		t.Helper()
+		rr := doRequest(t, h, http.MethodPost, "/v1/invoke",
+			map[string]any{"tool_id": tool.ID, "input": map[string]any{"q": q}, "mock": true, "budget_claw": "0.1"})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp registry.InvokeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+	resp := invoke("ping")
+	assert.True(t, resp.Mock)
+	assert.Equal(t, map[string]any{"a": "pong"}, resp.Output)
+	assert.Empty(t, resp.CostCLAW, "mock invocations are free, whatever the budget")
+	require.NotNil(t, resp.Receipt)
+	assert.True(t, resp.Receipt.Mock)
+
+	// The grpc:// endpoint cannot be called, and the quota of one call a
+	// day does not apply.
+	assert.Equal(t, "echo hello", invoke("hello").Output["a"])
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations/"+resp.InvocationID, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var inv registry.Invocation
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&inv))
+	assert.True(t, inv.Mock)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke",
+		map[string]any{"tool_id": tool.ID, "input": map[string]any{}, "mock": true, "callback_url": "https://example.com/cb"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
+
+func TestInvoke_MockErrors(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool.ID, "input": map[string]any{}, "mock": true})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "NO_MOCK")
+
+	payload := validToolPayload()
+	payload["name"] = "bad-mock"
+	payload["mock"] = map[string]any{}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_MOCK")
+
+	payload = validToolPayload()
+	payload["name"] = "canned"
+	payload["mock"] = map[string]any{"responses": []any{map[string]any{"when": map[string]any{"q": "a"}, "output": map[string]any{}}}}
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke",
+		map[string]any{"tool_id": tool.ID, "input": map[string]any{"q": "b"}, "mock": true})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "NO_MOCK", "no response matches and there is no template")
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// namespaceHeader selects the namespace a request operates in.
+const namespaceHeader = "X-Namespace"
+
+// scopeNamespace resolves the request namespace, checks that the caller may
+// act within it, and stores it in the request context for the registry.
+func (h *Handler) scopeNamespace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.Header.Get(namespaceHeader)
+		if ns == "" {
+			ns = registry.DefaultNamespace
+		}
+		if err := h.reg.AuthorizeNamespace(r.Context(), ns, providerIDFromRequest(r)); err != nil {
+			writeNamespaceError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(registry.WithNamespace(r.Context(), ns)))
+	})
+}
+
+// createNamespace handles POST /v1/namespaces.
+func (h *Handler) createNamespace(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "invalid JSON")
+		return
+	}
+	owner, err := h.reg.CreateNamespace(r.Context(), req.Name, providerIDFromRequest(r))
+	if err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+	h.setLocation(w, r, "v1", "namespaces", owner.Namespace, "members")
+	writeJSON(w, http.StatusCreated, owner)
+}
+
+// listNamespaceMembers handles GET /v1/namespaces/{ns}/members.
+func (h *Handler) listNamespaceMembers(w http.ResponseWriter, r *http.Request) {
+	ns := chi.URLParam(r, "ns")
+	if err := h.reg.AuthorizeNamespace(r.Context(), ns, providerIDFromRequest(r)); err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+	members, err := h.reg.ListNamespaceMembers(r.Context(), ns)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"members": members})
+}
+
+// addNamespaceMember handles POST /v1/namespaces/{ns}/members.
+func (h *Handler) addNamespaceMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MemberID string `json:"member_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MemberID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "member_id is required")
+		return
+	}
+	m, err := h.reg.AddNamespaceMember(r.Context(), chi.URLParam(r, "ns"), providerIDFromRequest(r), req.MemberID)
+	if err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func writeNamespaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrDuplicate):
+		writeError(w, http.StatusConflict, "DUPLICATE_NAMESPACE", err.Error())
+	case errors.Is(err, registry.ErrInvalidNamespace):
+		writeError(w, http.StatusBadRequest, "INVALID_NAMESPACE", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
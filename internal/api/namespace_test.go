@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doAs(t *testing.T, h http.Handler, method, path, did, ns string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, mustEncode(t, body))
+	req.Header.Set("Content-Type", "application/json")
+	if did != "" {
+		req.Header.Set("Authorization", "Bearer "+did)
+	}
+	if ns != "" {
+		req.Header.Set("X-Namespace", ns)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNamespaces_EndToEnd(t *testing.T) {
+	h := newTestHandler(t)
+	const alice, bob = "did:claw:agent:alice", "did:claw:agent:bob"
+
+	rr := doAs(t, h, http.MethodPost, "/v1/namespaces", alice, "", map[string]any{"name": "acme"})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doAs(t, h, http.MethodPost, "/v1/namespaces", bob, "", map[string]any{"name": "acme"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Non-members are rejected.
+	rr = doAs(t, h, http.MethodGet, "/v1/tools", bob, "acme", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Members can register; tools are invisible from the default namespace.
+	rr = doAs(t, h, http.MethodPost, "/v1/tools", alice, "acme", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	assert.Equal(t, "acme", tool["namespace"])
+
+	rr = doAs(t, h, http.MethodGet, "/v1/tools/"+tool["id"].(string), alice, "", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doAs(t, h, http.MethodPost, "/v1/namespaces/acme/members", bob, "", map[string]any{"member_id": bob})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doAs(t, h, http.MethodPost, "/v1/namespaces/acme/members", alice, "", map[string]any{"member_id": bob})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doAs(t, h, http.MethodGet, "/v1/tools/"+tool["id"].(string), bob, "acme", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doAs(t, h, http.MethodGet, "/v1/namespaces/acme/members", bob, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var members map[string][]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&members))
+	assert.Len(t, members["members"], 2)
+}
+
+func TestNamespaces_InvalidRequests(t *testing.T) {
+	h := newTestHandler(t)
+
+	rr := doAs(t, h, http.MethodGet, "/v1/tools", "", "Not Valid", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doAs(t, h, http.MethodPost, "/v1/namespaces", testCaller, "", map[string]any{"name": "UPPER"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doAs(t, h, http.MethodPost, "/v1/namespaces/acme/members", testCaller, "", map[string]any{})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doAs(t, h, http.MethodGet, "/v1/namespaces/acme/members", "did:claw:agent:x", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestNamespaces_ProviderCrossNamespaceForbidden(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doAs(t, h, http.MethodPost, "/v1/namespaces", "did:claw:agent:test-provider", "", map[string]any{"name": "acme"})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doAs(t, h, http.MethodPost, "/v1/providers", testCaller, "", validProviderPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	rr = doAs(t, h, http.MethodPost, "/v1/providers", "did:claw:agent:test-provider", "acme", validProviderPayload())
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
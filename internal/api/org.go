@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// orgHeader has a member of an organization act as it.
+const orgHeader = "X-Org"
+
+type orgKey struct{}
+
+// actAsOrg lets the members of the organization named by the X-Org header
+// act as it: for the rest of the request, providerIDFromRequest is the
+// organization's ID, so the tools they register belong to it and they may
+// manage the tools it owns.
+func (h *Handler) actAsOrg(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		org := r.Header.Get(orgHeader)
+		if org == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := h.reg.OrgRole(r.Context(), org, providerIDFromRequest(r)); err != nil {
+			writeOrgError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), orgKey{}, registry.OrgID(org))))
+	})
+}
+
+// createOrg handles POST /v1/orgs; the caller becomes its first owner.
+func (h *Handler) createOrg(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	org, err := h.reg.CreateOrg(r.Context(), req.Name, providerIDFromRequest(r))
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	h.setLocation(w, r, "v1", "orgs", org.Name)
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// listOrgs handles GET /v1/orgs: the caller's memberships.
+func (h *Handler) listOrgs(w http.ResponseWriter, r *http.Request) {
+	memberships, err := h.reg.MemberOrgs(r.Context(), providerIDFromRequest(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"orgs": memberships})
+}
+
+// getOrg handles GET /v1/orgs/{org} by its members.
+func (h *Handler) getOrg(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.reg.OrgRole(r.Context(), chi.URLParam(r, "org"), providerIDFromRequest(r)); err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	org, err := h.reg.GetOrg(r.Context(), chi.URLParam(r, "org"))
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, org)
+}
+
+// addOrgMember handles POST /v1/orgs/{org}/members, which adds a member or
+// changes the role of one.
+func (h *Handler) addOrgMember(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MemberID string `json:"member_id"`
+		Role     string `json:"role"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = registry.OrgRoleMaintainer
+	}
+	m, err := h.reg.SetOrgMember(r.Context(), chi.URLParam(r, "org"), providerIDFromRequest(r), req.MemberID, req.Role)
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// removeOrgMember handles DELETE /v1/orgs/{org}/members/{member}.
+func (h *Handler) removeOrgMember(w http.ResponseWriter, r *http.Request) {
+	err := h.reg.RemoveOrgMember(r.Context(), chi.URLParam(r, "org"), providerIDFromRequest(r), chi.URLParam(r, "member"))
+	if err != nil {
+		writeOrgError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeOrgError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "ORG_NOT_FOUND", "organization or member not found")
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrDuplicate):
+		writeError(w, http.StatusConflict, "DUPLICATE_ORG", err.Error())
+	case errors.Is(err, registry.ErrInvalidOrg):
+		writeError(w, http.StatusBadRequest, "INVALID_ORG", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doAsOrg makes a request as did acting as org.
+func doAsOrg(t *testing.T, h http.Handler, method, path, did, org string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, mustEncode(t, body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+did)
+	req.Header.Set("X-Org", org)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOrgs_EndToEnd(t *testing.T) {
+	h := newTestHandler(t)
+	const alice, bob, carol = "did:claw:agent:alice", "did:claw:agent:bob", "did:claw:agent:carol"
+
+	rr := doAs(t, h, http.MethodPost, "/v1/orgs", alice, "", map[string]any{"name": "audit-team"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = doAs(t, h, http.MethodPost, "/v1/orgs", bob, "", map[string]any{"name": "audit-team"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = doAs(t, h, http.MethodPost, "/v1/orgs/audit-team/members", bob, "", map[string]any{"member_id": bob})
+	assert.Equal(t, http.StatusForbidden, rr.Code, "only owners add members")
+	rr = doAs(t, h, http.MethodPost, "/v1/orgs/audit-team/members", alice, "", map[string]any{"member_id": bob})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"role":"maintainer"`)
+
+	// A maintainer registers a tool for the organization...
+	rr = doAsOrg(t, h, http.MethodPost, "/v1/tools", bob, "audit-team", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool struct {
+		ID         string `json:"id"`
+		ProviderID string `json:"provider_id"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	assert.Equal(t, "did:claw:org:audit-team", tool.ProviderID)
+
+	// ...which neither they alone nor outsiders may manage, but any member
+	// acting as the organization may.
+	rr = doAs(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, bob, "", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doAsOrg(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, carol, "audit-team", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doAsOrg(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, alice, "audit-team", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = doAsOrg(t, h, http.MethodGet, "/v1/tools", alice, "missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doAs(t, h, http.MethodGet, "/v1/orgs/audit-team", bob, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var org struct {
+		Members []struct {
+			MemberID string `json:"member_id"`
+		} `json:"members"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&org))
+	assert.Len(t, org.Members, 2)
+	rr = doAs(t, h, http.MethodGet, "/v1/orgs/audit-team", carol, "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doAs(t, h, http.MethodGet, "/v1/orgs", bob, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "did:claw:org:audit-team")
+
+	rr = doAs(t, h, http.MethodDelete, "/v1/orgs/audit-team/members/"+alice, alice, "", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "the last owner stays")
+	rr = doAs(t, h, http.MethodDelete, "/v1/orgs/audit-team/members/"+bob, alice, "", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doAsOrg(t, h, http.MethodPost, "/v1/tools", bob, "audit-team", validToolPayload())
+	assert.Equal(t, http.StatusForbidden, rr.Code, "removed members no longer act as the organization")
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// getPayload handles GET /v1/invocations/{id}/payload by the party the
+// payload is keyed to.
+func (h *Handler) getPayload(w http.ResponseWriter, r *http.Request) {
+	p, err := h.reg.GetPayload(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r))
+	if err != nil {
+		h.writePayloadError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// deletePayload handles DELETE /v1/invocations/{id}/payload by the party the
+// payload is keyed to.
+func (h *Handler) deletePayload(w http.ResponseWriter, r *http.Request) {
+	if err := h.reg.DeletePayload(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r)); err != nil {
+		h.writePayloadError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writePayloadError maps an error reading a stored payload to its API error.
+func (h *Handler) writePayloadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "invocation or tool not found")
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrInputNotStored):
+		writeError(w, http.StatusConflict, "INPUT_NOT_STORED", "the invocation's payload was not stored or has expired")
+	case errors.Is(err, registry.ErrPayloadStorage):
+		writeError(w, http.StatusBadRequest, "PAYLOAD_STORAGE_UNAVAILABLE", err.Error())
+	default:
+		h.logger(r).Error("read payload", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayload_API(t *testing.T) {
+	var version atomic.Int32
+	version.Store(7)
+	h, toolID := newPayloadHandler(t, &version)
+	const consumer = "did:claw:agent:consumer"
+
+	rr := doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", map[string]any{
+		"tool_id": toolID, "input": map[string]any{"q": "x"}, "store_payload": "consumer",
+	})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	path := "/v1/invocations/" + resp.InvocationID + "/payload"
+
+	rr = doAs(t, h, http.MethodGet, path, consumer, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var p registry.Payload
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	assert.Equal(t, consumer, p.Owner)
+	assert.JSONEq(t, `{"q":"x"}`, string(p.Input))
+	assert.JSONEq(t, `{"version":7}`, string(p.Output))
+
+	rr = doRequest(t, h, http.MethodGet, path, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "the provider cannot read a consumer-keyed payload")
+
+	rr = doAs(t, h, http.MethodDelete, path, consumer, "", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doAs(t, h, http.MethodGet, path, consumer, "", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	id := invokeStoring(t, h, toolID, "provider")
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations/"+id+"/payload", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "testCaller provides the tool")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": toolID, "store_payload": "everyone"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	plain := newTestHandler(t)
+	plainTool := registerRPCTool(t, plain, "plain", "http://127.0.0.1:1")
+	rr = doRequest(t, plain, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": plainTool, "store_payload": "consumer"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "PAYLOAD_STORAGE_UNAVAILABLE")
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// pipelineRunResponse is the answer to POST /v1/pipelines/{id}/run: the
+// run's record, its output and the outputs of its steps.
+type pipelineRunResponse struct {
+	*registry.PipelineRun
+	Output     map[string]any            `json:"output"`
+	Steps      map[string]map[string]any `json:"steps"`
+	DurationMS int64                     `json:"duration_ms"`
+}
+
+// createPipeline handles POST /v1/pipelines; the caller owns the pipeline.
+func (h *Handler) createPipeline(w http.ResponseWriter, r *http.Request) {
+	var p registry.Pipeline
+	if !decodeBody(w, r, registerBodyLimit(h.reg.Limits()), &p) {
+		return
+	}
+	p.OwnerID = providerIDFromRequest(r)
+	created, err := h.reg.CreatePipeline(r.Context(), &p)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrInvalidPipeline):
+			writeError(w, http.StatusBadRequest, "INVALID_PIPELINE", err.Error())
+		case errors.Is(err, registry.ErrDuplicate):
+			writeError(w, http.StatusConflict, "DUPLICATE_PIPELINE", err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+	h.setLocation(w, r, "v1", "pipelines", created.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// getPipeline handles GET /v1/pipelines/{id}.
+func (h *Handler) getPipeline(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.pipeline(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// deletePipeline handles DELETE /v1/pipelines/{id} by its owner.
+func (h *Handler) deletePipeline(w http.ResponseWriter, r *http.Request) {
+	err := h.reg.DeletePipeline(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "PIPELINE_NOT_FOUND", "pipeline not found")
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
+// getPipelineRun handles GET /v1/pipelines/{id}/runs/{run} by the consumer
+// that ran it.
+func (h *Handler) getPipelineRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.reg.GetPipelineRun(r.Context(), chi.URLParam(r, "run"))
+	if errors.Is(err, registry.ErrNotFound) || (err == nil && run.PipelineID != chi.URLParam(r, "id")) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "pipeline run not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if run.ConsumerID != providerIDFromRequest(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only the consumer of a pipeline run may read it")
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// pipeline writes the error response and returns false when the pipeline
+// named by r does not exist.
+func (h *Handler) pipeline(w http.ResponseWriter, r *http.Request) (*registry.Pipeline, bool) {
+	p, err := h.reg.GetPipeline(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "PIPELINE_NOT_FOUND", "pipeline not found")
+			return nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return nil, false
+	}
+	return p, true
+}
+
+// runPipeline handles POST /v1/pipelines/{id}/run: it invokes the steps of
+// the pipeline stage by stage, each as an ordinary invocation by the
+// caller, and records the run with one receipt for all of them. The first
+// failing step fails the run with its error, after the other steps of its
+// stage finish. Either way the Location header names the run's record.
+func (h *Handler) runPipeline(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.pipeline(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Input map[string]any `json:"input"`
+	}
+	if !decodeBody(w, r, int64(h.reg.Limits().MaxInputBytes)+16<<10, &req) {
+		return
+	}
+	if req.Input == nil {
+		req.Input = map[string]any{}
+	}
+	stages, err := p.Stages()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	start := time.Now()
+	run := &registry.PipelineRun{
+		ID:         "run_" + uuid.NewString(),
+		PipelineID: p.ID,
+		ConsumerID: providerIDFromRequest(r),
+		StartedAt:  start.UTC().Truncate(time.Second),
+		Receipt: &registry.PipelineReceipt{
+			PipelineID: p.ID,
+			ConsumerID: providerIDFromRequest(r),
+			InputHash:  registry.HashPayload(req.Input),
+			Steps:      []*registry.StepReceipt{},
+		},
+	}
+	run.Receipt.RunID = run.ID
+	outputs := map[string]map[string]any{}
+	var failed *invokeError
+	for _, stage := range stages {
+		results := make([]*stepResult, len(stage))
+		errs := make([]*invokeError, len(stage))
+		var wg sync.WaitGroup
+		for i, step := range stage {
+			in, err := step.StepInput(req.Input, outputs)
+			if err != nil {
+				errs[i] = &invokeError{status: http.StatusUnprocessableEntity, code: "PIPELINE_MAPPING", msg: err.Error()}
+				continue
+			}
+			wg.Add(1)
+			go func(i int, step *registry.PipelineStep) {
+				defer wg.Done()
+				results[i], errs[i] = h.runStep(r, step, in)
+			}(i, step)
+		}
+		wg.Wait()
+		for i, step := range stage {
+			if results[i] != nil {
+				run.Receipt.Steps = append(run.Receipt.Steps, results[i].StepReceipt)
+				outputs[step.ID] = results[i].output
+			}
+			if errs[i] != nil && failed == nil {
+				failed = errs[i]
+				failed.msg = fmt.Sprintf("step %s: %s", step.ID, failed.msg)
+			}
+		}
+		if failed != nil {
+			break
+		}
+	}
+
+	var output map[string]any
+	if failed == nil {
+		if output, err = p.Result(req.Input, outputs); err != nil {
+			failed = &invokeError{status: http.StatusUnprocessableEntity, code: "PIPELINE_MAPPING", msg: "output: " + err.Error()}
+		}
+	}
+	run.Status = registry.PipelineCompleted
+	if failed != nil {
+		run.Status, run.Error = registry.PipelineFailed, failed.msg
+	} else {
+		run.Receipt.OutputHash = registry.HashPayload(output)
+	}
+	run.CompletedAt = time.Now().UTC().Truncate(time.Second)
+	run.Receipt.ExecutedAt = run.CompletedAt
+	if err := h.recordRun(r.Context(), run); err != nil {
+		h.logger(r).Error("record pipeline run", zap.String("run_id", run.ID), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	h.setLocation(w, r, "v1", "pipelines", p.ID, "runs", run.ID)
+	if failed != nil {
+		writeInvokeError(w, failed)
+		return
+	}
+	writeJSON(w, http.StatusOK, &pipelineRunResponse{
+		PipelineRun: run,
+		Output:      output,
+		Steps:       outputs,
+		DurationMS:  time.Since(start).Milliseconds(),
+	})
+}
+
+// stepResult is a step's part of the receipt and, kept out of it, its
+// output.
+type stepResult struct {
+	*registry.StepReceipt
+	output map[string]any
+}
+
+// runStep invokes one step of a pipeline with input.
+func (h *Handler) runStep(r *http.Request, step *registry.PipelineStep, input map[string]any) (*stepResult, *invokeError) {
+	resp, ierr := h.invoke(r, &registry.InvokeRequest{ToolID: step.ToolID, Input: input})
+	if ierr != nil {
+		return nil, ierr
+	}
+	var providerID string
+	if tool, err := h.reg.GetTool(r.Context(), step.ToolID); err == nil {
+		providerID = tool.ProviderID
+	}
+	return &stepResult{
+		StepReceipt: &registry.StepReceipt{
+			StepID:       step.ID,
+			InvocationID: resp.InvocationID,
+			ToolID:       resp.ToolID,
+			ProviderID:   providerID,
+			InputHash:    registry.HashPayload(input),
+			OutputHash:   registry.HashPayload(resp.Output),
+			CostCLAW:     resp.CostCLAW,
+			Cached:       resp.Cached,
+		},
+		output: resp.Output,
+	}, nil
+}
+
+// recordRun seals the receipt of a finished run and stores the run, even
+// when the caller has gone away.
+func (h *Handler) recordRun(ctx context.Context, run *registry.PipelineRun) error {
+	if err := run.Receipt.Seal(); err != nil {
+		return err
+	}
+	return h.reg.RecordPipelineRun(context.WithoutCancel(ctx), run)
+}
@@ -0,0 +1,159 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelines(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		switch r.URL.Path {
+		case "/upper":
+			_ = json.NewEncoder(w).Encode(map[string]any{"text": strings.ToUpper(in["text"].(string))})
+		case "/length":
+			_ = json.NewEncoder(w).Encode(map[string]any{"n": len(in["text"].(string))})
+		case "/join":
+			_ = json.NewEncoder(w).Encode(map[string]any{"summary": in["text"].(string) + "/" + in["sep"].(string)})
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	upper := registerRPCTool(t, h, "upper", srv.URL+"/upper")
+	length := registerRPCTool(t, h, "length", srv.URL+"/length")
+	join := registerRPCTool(t, h, "join", srv.URL+"/join")
+	broken := registerRPCTool(t, h, "broken", srv.URL+"/broken")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/pipelines", map[string]any{
+		"name": "shout",
+		"steps": []map[string]any{
+			{"id": "upper", "tool_id": upper, "map": map[string]string{"text": "input.text"}},
+			{"id": "length", "tool_id": length, "map": map[string]string{"text": "input.text"}},
+			{"id": "join", "tool_id": join, "input": map[string]any{"sep": "!"}, "map": map[string]string{"text": "steps.upper.text"}},
+		},
+		"output": map[string]string{"summary": "steps.join.summary", "n": "steps.length.n"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var p registry.Pipeline
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	assert.Equal(t, testCaller, p.OwnerID)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines/"+p.ID+"/run", map[string]any{"input": map[string]any{"text": "hi"}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp struct {
+		registry.PipelineRun
+		Output map[string]any            `json:"output"`
+		Steps  map[string]map[string]any `json:"steps"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"summary": "HI/!", "n": float64(2)}, resp.Output)
+	assert.Equal(t, "HI", resp.Steps["upper"]["text"])
+	assert.Equal(t, registry.PipelineCompleted, resp.Status)
+	require.Len(t, resp.Receipt.Steps, 3)
+	assert.Equal(t, "15", resp.Receipt.CostCLAW, "three calls at 5 CLAW")
+	assert.Equal(t, "join", resp.Receipt.Steps[2].StepID, "in the order the steps ran")
+	assert.Equal(t, registry.HashPayload(resp.Output), resp.Receipt.OutputHash)
+	assert.NotEmpty(t, resp.Receipt.Hash)
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/invocations/"+resp.Receipt.Steps[0].InvocationID, nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "each step is an ordinary invocation")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/pipelines/"+p.ID+"/runs/"+resp.ID, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var run registry.PipelineRun
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&run))
+	assert.Equal(t, resp.Receipt.Hash, run.Receipt.Hash)
+	rr = doAs(t, h, http.MethodGet, "/v1/pipelines/"+p.ID+"/runs/"+resp.ID, "did:claw:agent:other", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines", map[string]any{
+		"name": "fails",
+		"steps": []map[string]any{
+			{"id": "upper", "tool_id": upper, "map": map[string]string{"text": "input.text"}},
+			{"id": "broken", "tool_id": broken, "map": map[string]string{"text": "steps.upper.text"}},
+		},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines/"+p.ID+"/run", map[string]any{"input": map[string]any{"text": "hi"}})
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "step broken")
+	rr = doRequest(t, h, http.MethodGet, rr.Header().Get("Location"), nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&run))
+	assert.Equal(t, registry.PipelineFailed, run.Status)
+	assert.Len(t, run.Receipt.Steps, 1, "the step that completed")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines", map[string]any{
+		"name": "cycle",
+		"steps": []map[string]any{
+			{"id": "a", "tool_id": upper, "after": []string{"b"}},
+			{"id": "b", "tool_id": upper, "after": []string{"a"}},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_PIPELINE")
+
+	rr = doAs(t, h, http.MethodDelete, "/v1/pipelines/"+p.ID, "did:claw:agent:other", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doRequest(t, h, http.MethodDelete, "/v1/pipelines/"+p.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/pipelines/"+p.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPipelines_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"text": "ok"})
+	}))
+	t.Cleanup(srv.Close)
+	h := newTestHandler(t)
+	echo := registerRPCTool(t, h, "echo", srv.URL)
+
+	create := func(name string, steps []map[string]any, output map[string]string) string {
+		t.Helper()
+		rr := doRequest(t, h, http.MethodPost, "/v1/pipelines", map[string]any{"name": name, "steps": steps, "output": output})
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var p registry.Pipeline
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+		return p.ID
+	}
+	mapped := create("mapped", []map[string]any{{"id": "echo", "tool_id": echo, "map": map[string]string{"text": "input.text"}}}, nil)
+	output := create("output", []map[string]any{{"id": "echo", "tool_id": echo}}, map[string]string{"n": "steps.echo.missing"})
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/pipelines", map[string]any{
+		"name": "mapped", "steps": []map[string]any{{"id": "echo", "tool_id": echo}},
+	})
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/pipelines/"+mapped, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"name":"mapped"`)
+	rr = doRequest(t, h, http.MethodGet, "/v1/pipelines/pl_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines/pl_missing/run", map[string]any{})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/pipelines/"+mapped+"/runs/run_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = doRequest(t, h, http.MethodDelete, "/v1/pipelines/pl_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Without input, the step's mapping finds nothing to map.
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines/"+mapped+"/run", map[string]any{})
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "PIPELINE_MAPPING")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/pipelines/"+output+"/run", map[string]any{})
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "output: ")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// listPolicies handles GET /admin/policies.
+func (h *Handler) listPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.reg.ListPolicies(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"policies": policies, "attributes": registry.PolicyAttributes})
+}
+
+// addPolicy handles POST /admin/policies.
+func (h *Handler) addPolicy(w http.ResponseWriter, r *http.Request) {
+	var p registry.Policy
+	if !decodeBody(w, r, 0, &p) {
+		return
+	}
+	created, err := h.reg.AddPolicy(r.Context(), &p)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusCreated, created)
+	case errors.Is(err, registry.ErrDuplicate):
+		writeError(w, http.StatusConflict, "DUPLICATE_POLICY", err.Error())
+	case errors.Is(err, registry.ErrInvalidPolicy):
+		writeError(w, http.StatusBadRequest, "INVALID_POLICY", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
+// deletePolicy handles DELETE /admin/policies/{id}.
+func (h *Handler) deletePolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.reg.DeletePolicy(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "POLICY_NOT_FOUND", "policy not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listPolicyDecisions handles GET /admin/policy-decisions, newest first.
+func (h *Handler) listPolicyDecisions(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	f := registry.PolicyDecisionFilter{ConsumerID: q.Get("consumer"), ToolID: q.Get("tool")}
+	switch denied := q.Get("denied"); denied {
+	case "", "false":
+	case "true":
+		f.DeniedOnly = true
+	default:
+		q.Check(false, "denied", "denied must be true or false")
+	}
+	if before := q.Get("before"); before != "" {
+		var err error
+		f.Before, err = strconv.ParseInt(before, 10, 64)
+		q.Check(err == nil && f.Before > 0, "before", "before must be a decision ID")
+	}
+	limit := q.Int("limit", 0)
+	if !q.valid(w) {
+		return
+	}
+	decisions, err := h.reg.ListPolicyDecisions(r.Context(), f, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"decisions": decisions})
+}
+
+// policyError maps an error of CheckPolicies to the invocation's failure.
+func policyError(err error) *invokeError {
+	if errors.Is(err, registry.ErrPolicyDenied) {
+		return &invokeError{status: http.StatusForbidden, code: "POLICY_DENIED", msg: err.Error()}
+	}
+	return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+}
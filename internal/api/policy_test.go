@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies_API(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	rr = adminRequest(t, h, http.MethodPost, "/admin/policies", "s3cret",
+		`{"name":"cheap-calls","when":"consumer.id matches \"did:claw:agent:intern-*\"","require":"cost <= 1",`+
+			`"reason":"interns may only make calls under 1 CLAW"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var p registry.Policy
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+
+	invoke := func(did string, dryRun bool) *httptest.ResponseRecorder {
+		return doAs(t, h, http.MethodPost, "/v1/invoke", did, "",
+			map[string]any{"tool_id": tool.ID, "input": map[string]any{}, "dry_run": dryRun})
+	}
+	for _, dryRun := range []bool{true, false} {
+		rr = invoke("did:claw:agent:intern-1", dryRun)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Contains(t, rr.Body.String(), "POLICY_DENIED")
+		assert.Contains(t, rr.Body.String(), "interns may only make calls under 1 CLAW")
+	}
+	rr = invoke("did:claw:agent:senior", false)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/policy-decisions?denied=true", "s3cret", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list struct {
+		Decisions []*registry.PolicyDecision `json:"decisions"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Decisions, 1, "dry runs are not logged")
+	assert.Equal(t, "did:claw:agent:intern-1", list.Decisions[0].ConsumerID)
+	assert.Equal(t, "cheap-calls", list.Decisions[0].DeniedBy)
+	rr = adminRequest(t, h, http.MethodGet, "/admin/policy-decisions?before=x", "s3cret", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = adminRequest(t, h, http.MethodPost, "/admin/policies", "s3cret", `{"name":"bad","require":"cost <"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_POLICY")
+	rr = adminRequest(t, h, http.MethodPost, "/admin/policies", "s3cret", `{"name":"cheap-calls","require":"true"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = adminRequest(t, h, http.MethodGet, "/admin/policies", "s3cret", "")
+	assert.Contains(t, rr.Body.String(), "cheap-calls")
+	assert.Contains(t, rr.Body.String(), "consumer.orgs")
+
+	rr = adminRequest(t, h, http.MethodDelete, "/admin/policies/"+p.ID, "s3cret", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = adminRequest(t, h, http.MethodDelete, "/admin/policies/"+p.ID, "s3cret", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = invoke("did:claw:agent:intern-1", false)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WithBasePath mounts every route under prefix, for deployments that proxy a
+// sub-path such as /agent-tools to the registry without stripping it.
+func WithBasePath(prefix string) Option {
+	return func(h *Handler) {
+		h.basePath = strings.TrimRight("/"+strings.Trim(prefix, "/"), "/")
+	}
+}
+
+// WithTrustedProxy makes generated URLs honor X-Forwarded-Proto and
+// X-Forwarded-Host. Only enable it behind a proxy that sets or strips those
+// headers; otherwise clients can choose the URLs they are sent.
+func WithTrustedProxy(trusted bool) Option {
+	return func(h *Handler) { h.trustProxy = trusted }
+}
+
+// externalURL returns the absolute URL clients use to reach path.
+func (h *Handler) externalURL(r *http.Request, path string) string {
+	u := h.externalBase(r)
+	u.Path += path
+	return u.String()
+}
+
+// externalBase returns the URL clients reach the registry at.
+func (h *Handler) externalBase(r *http.Request) *url.URL {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if h.trustProxy {
+		if p := firstForwarded(r.Header.Get("X-Forwarded-Proto")); p == "http" || p == "https" {
+			scheme = p
+		}
+		if fh := firstForwarded(r.Header.Get("X-Forwarded-Host")); fh != "" {
+			host = fh
+		}
+	}
+	return &url.URL{Scheme: scheme, Host: host, Path: h.basePath}
+}
+
+// setLocation points the Location header of a 201 response at the new
+// resource.
+func (h *Handler) setLocation(w http.ResponseWriter, r *http.Request, segments ...string) {
+	path := ""
+	for _, s := range segments {
+		path += "/" + url.PathEscape(s)
+	}
+	w.Header().Set("Location", h.externalURL(r, path))
+}
+
+// firstForwarded returns the client-most value of a comma-separated
+// forwarding header.
+func firstForwarded(v string) string {
+	first, _, _ := strings.Cut(v, ",")
+	return strings.TrimSpace(first)
+}
@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newProxiedHandler(t *testing.T, opts ...api.Option) *api.Handler {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t), opts...)
+}
+
+func TestBasePath(t *testing.T) {
+	h := newProxiedHandler(t, api.WithBasePath("/agent-tools/"))
+
+	rr := doRequest(t, h, http.MethodGet, "/agent-tools/healthz", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/agent-tools/v1/tools", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = doRequest(t, h, http.MethodGet, "/healthz", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "routes are only served under the prefix")
+}
+
+func TestLocation_UsesHostAndBasePath(t *testing.T) {
+	h := newProxiedHandler(t, api.WithBasePath("agent-tools"))
+
+	rr := doRequest(t, h, http.MethodPost, "/agent-tools/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tool))
+	assert.Equal(t, "http://example.com/agent-tools/v1/tools/"+tool["id"].(string), rr.Header().Get("Location"))
+}
+
+func TestLocation_ForwardedHeaders(t *testing.T) {
+	post := func(h http.Handler) string {
+		req := httptest.NewRequest(http.MethodPost, "/v1/namespaces", mustEncode(t, map[string]string{"name": "acme"}))
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("Authorization", "Bearer "+testCaller)
+		req.Header.Set("X-Forwarded-Host", "tools.example.org, internal:8433")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusCreated, rr.Code)
+		return rr.Header().Get("Location")
+	}
+
+	assert.Equal(t, "https://tools.example.org/v1/namespaces/acme/members",
+		post(newProxiedHandler(t, api.WithTrustedProxy(true))))
+	assert.Equal(t, "http://example.com/v1/namespaces/acme/members",
+		post(newProxiedHandler(t)), "forwarded headers are ignored unless the proxy is trusted")
+}
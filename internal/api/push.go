@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/push"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultPollWait and maxPollWait bound how long GET /v1/push/jobs
+	// holds a request open; the cap stays under the server write timeout.
+	defaultPollWait = 25 * time.Second
+	maxPollWait     = 50 * time.Second
+	// maxPollJobs bounds the jobs handed out by one poll.
+	maxPollJobs = 50
+	// maxPushResult bounds a result posted by a push provider.
+	maxPushResult = 16 << 20
+)
+
+// pushRunner returns how to call a tool served by a push provider, or nil
+// when tool has another kind of endpoint.
+func (h *Handler) pushRunner(tool *registry.Tool) runFunc {
+	channel, ok := registry.PushChannel(tool.Endpoint)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return h.push.Call(ctx, tool.ProviderID, &push.Job{ToolID: tool.ID, Channel: channel, Input: input})
+	}
+}
+
+// pollJobs handles GET /v1/push/jobs, the long poll of push providers: it
+// returns invocations of the caller's push:// tools as soon as there are
+// any, or an empty list after wait. channel restricts the poll to one
+// channel and max the number of jobs returned.
+func (h *Handler) pollJobs(w http.ResponseWriter, r *http.Request) {
+	provider := providerIDFromRequest(r)
+	if provider == anonymousConsumer {
+		unauthorized(w, "push providers must send Authorization")
+		return
+	}
+	q := queryOf(r)
+	wait := q.Duration("wait", defaultPollWait)
+	q.Check(wait >= 0, "wait", "wait must not be negative")
+	limit := q.Int("max", 10)
+	q.Check(limit >= 1, "max", "max must be a positive integer")
+	if !q.valid(w) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), min(wait, maxPollWait))
+	defer cancel()
+	jobs := h.push.Poll(ctx, provider, q.Get("channel"), min(limit, maxPollJobs))
+	if jobs == nil {
+		jobs = []*push.Job{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+type pushResultRequest struct {
+	Output json.RawMessage `json:"output"`
+	Error  string          `json:"error"`
+}
+
+// pushResult handles POST /v1/push/jobs/{id}/result, a push provider
+// returning the output of a job, or the error it failed with.
+func (h *Handler) pushResult(w http.ResponseWriter, r *http.Request) {
+	provider := providerIDFromRequest(r)
+	if provider == anonymousConsumer {
+		unauthorized(w, "push providers must send Authorization")
+		return
+	}
+	var req pushResultRequest
+	if !decodeBody(w, r, maxPushResult, &req) {
+		return
+	}
+	if req.Error == "" && len(req.Output) == 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "output or error is required")
+		return
+	}
+	if err := h.push.Complete(provider, chi.URLParam(r, "id"), req.Output, req.Error); err != nil {
+		if errors.Is(err, push.ErrUnknownJob) {
+			writeError(w, http.StatusNotFound, "JOB_NOT_FOUND", "job not found, not yours, or already timed out")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/push"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// servePushJob plays a push provider: it polls for one job, doubles its
+// input n and posts the result, reporting the job it handled.
+func servePushJob(h http.Handler, handled chan<- *push.Job) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/push/jobs?wait=5s&channel=workers", nil)
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var resp struct {
+		Jobs []*push.Job `json:"jobs"`
+	}
+	if json.NewDecoder(rr.Body).Decode(&resp) != nil || len(resp.Jobs) != 1 {
+		handled <- nil
+		return
+	}
+	job := resp.Jobs[0]
+	var input struct {
+		N float64 `json:"n"`
+	}
+	_ = json.Unmarshal(job.Input, &input)
+	body, _ := json.Marshal(map[string]any{"output": map[string]any{"n": 2 * input.N}})
+	req = httptest.NewRequest(http.MethodPost, "/v1/push/jobs/"+job.ID+"/result", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	handled <- job
+}
+
+func TestInvoke_Push(t *testing.T) {
+	h := newTestHandler(t)
+	toolID := registerRPCTool(t, h, "double", "push://workers")
+	invoke := func() *httptest.ResponseRecorder {
+		return doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": toolID, "input": map[string]any{"n": 21}})
+	}
+
+	rr := invoke()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "the provider never polled")
+	assert.Contains(t, rr.Body.String(), "PROVIDER_OFFLINE")
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/push/jobs?wait=0s", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"jobs": []}`, rr.Body.String())
+
+	handled := make(chan *push.Job, 1)
+	go servePushJob(h, handled)
+	rr = invoke()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"n":42`)
+	job := <-handled
+	require.NotNil(t, job)
+	assert.Equal(t, toolID, job.ToolID)
+	assert.Equal(t, "workers", job.Channel)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/push/jobs/"+job.ID+"/result", map[string]any{"output": map[string]any{}})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "already answered")
+	rr = doRequest(t, h, http.MethodPost, "/v1/push/jobs/x/result", map[string]any{})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/push/jobs?wait=soon", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/push/jobs?wait=0s", nil)
+	anon := httptest.NewRecorder()
+	h.ServeHTTP(anon, req)
+	assert.Equal(t, http.StatusUnauthorized, anon.Code)
+}
+
+func TestRegisterTool_PushEndpoint(t *testing.T) {
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = "push://bad channel"
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ENDPOINT")
+}
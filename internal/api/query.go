@@ -0,0 +1,121 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryParams parses typed query parameters. A malformed value leaves the
+// default in place and is remembered, so a handler reads every parameter
+// and then rejects the request once, naming the first bad one.
+type queryParams struct {
+	url.Values
+	param string // first malformed parameter
+	msg   string
+}
+
+func queryOf(r *http.Request) *queryParams {
+	return &queryParams{Values: r.URL.Query()}
+}
+
+func (q *queryParams) fail(name, msg string) {
+	if q.param == "" {
+		q.param, q.msg = name, msg
+	}
+}
+
+// Int returns the integer value of name, or def when it is absent.
+func (q *queryParams) Int(name string, def int) int {
+	v := q.Get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		q.fail(name, name+" must be an integer")
+		return def
+	}
+	return n
+}
+
+// Float returns the finite numeric value of name, or def when it is absent.
+func (q *queryParams) Float(name string, def float64) float64 {
+	v := q.Get(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		q.fail(name, name+" must be a number")
+		return def
+	}
+	return f
+}
+
+// Duration returns the value of name as a Go duration such as 25s, or def
+// when it is absent.
+func (q *queryParams) Duration(name string, def time.Duration) time.Duration {
+	v := q.Get(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		q.fail(name, name+" must be a duration such as 25s")
+		return def
+	}
+	return d
+}
+
+// Date returns the value of name as a UTC date (YYYY-MM-DD), or def when it
+// is absent.
+func (q *queryParams) Date(name string, def time.Time) time.Time {
+	v := q.Get(name)
+	if v == "" {
+		return def
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		q.fail(name, name+" must be YYYY-MM-DD")
+		return def
+	}
+	return t
+}
+
+// Prefixed returns the values of the parameters named prefix followed by a
+// key, by key, or nil when there are none.
+func (q *queryParams) Prefixed(prefix string) map[string]string {
+	var out map[string]string
+	for name, values := range q.Values {
+		if key, ok := strings.CutPrefix(name, prefix); ok {
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[key] = values[0]
+		}
+	}
+	return out
+}
+
+// Check rejects the value of name, though well-formed, unless ok.
+func (q *queryParams) Check(ok bool, name, msg string) {
+	if !ok {
+		q.fail(name, msg)
+	}
+}
+
+// valid writes 400 INVALID_QUERY, naming the first malformed parameter,
+// and returns false if there was one.
+func (q *queryParams) valid(w http.ResponseWriter) bool {
+	if q.param == "" {
+		return true
+	}
+	e := newAPIError(w, "INVALID_QUERY", q.msg)
+	e.Error.Param = q.param
+	writeJSON(w, http.StatusBadRequest, e)
+	return false
+}
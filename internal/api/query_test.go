@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryParams_Malformed(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var tool struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	for path, param := range map[string]string{
+		"/v1/tools?page=abc":                          "page",
+		"/v1/tools?limit=10x":                         "limit",
+		"/v1/tools/search?q=x&page=1.5":               "page",
+		"/v1/tools/search?max_price_claw=cheap":       "max_price_claw",
+		"/v1/tools/search?max_price_claw=NaN":         "max_price_claw",
+		"/v1/tools/search?limit=abc&max_price_claw=x": "limit",
+		"/v1/tools/export?format=anthropic&limit=ten": "limit",
+		"/v1/tools/" + tool.ID + "/usage?from=today":  "from",
+		"/v1/push/jobs?wait=soon":                     "wait",
+		"/v1/push/jobs?max=0":                         "max",
+	} {
+		rr := doRequest(t, h, http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+		var resp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Param   string `json:"param"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp), path)
+		assert.Equal(t, "INVALID_QUERY", resp.Error.Code, path)
+		assert.Equal(t, param, resp.Error.Param, path)
+		assert.Contains(t, resp.Error.Message, param, path)
+	}
+
+	rr = doRequest(t, h, http.MethodGet, "/v1/tools/search?q=test&page=1&limit=5&max_price_claw=2.5", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "well-formed parameters are accepted")
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// putQuota handles PUT /v1/tools/{id}/quota.
+func (h *Handler) putQuota(w http.ResponseWriter, r *http.Request) {
+	var q registry.Quota
+	if !decodeBody(w, r, 0, &q) {
+		return
+	}
+	h.setQuota(w, r, &q)
+}
+
+// deleteQuota handles DELETE /v1/tools/{id}/quota.
+func (h *Handler) deleteQuota(w http.ResponseWriter, r *http.Request) {
+	h.setQuota(w, r, nil)
+}
+
+func (h *Handler) setQuota(w http.ResponseWriter, r *http.Request, q *registry.Quota) {
+	err := h.reg.SetQuota(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r), q)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "TOOL_NOT_FOUND", err.Error())
+	case errors.Is(err, registry.ErrInvalidQuota):
+		writeError(w, http.StatusBadRequest, "INVALID_QUOTA", err.Error())
+	default:
+		h.logger(r).Error("set quota", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// Roles of callers. Admins hold the admin token or an API key with the
+// admin scope. Providers send a proven API key, created by proving they
+// hold a registered provider's key, with the tools:write scope. Everyone
+// else, anonymous callers included, is a consumer.
+const (
+	RoleAdmin    = "admin"
+	RoleProvider = "provider"
+	RoleConsumer = "consumer"
+)
+
+// WithRBAC enforces roles: only providers may register and change their
+// tools, upload modules, serve push jobs or re-register themselves, so
+// naming a provider's DID in Authorization no longer acts as it.
+func WithRBAC(enforce bool) Option {
+	return func(h *Handler) { h.rbac = enforce }
+}
+
+// hasRole reports whether the caller of r holds role.
+func (h *Handler) hasRole(r *http.Request, role string) (bool, error) {
+	switch role {
+	case RoleAdmin:
+		return h.isAdmin(r), nil
+	case RoleProvider:
+		k := apiKeyFrom(r.Context())
+		if k == nil || !k.Proven || !k.HasScope(registry.ScopeToolsWrite) {
+			return false, nil
+		}
+		_, err := h.reg.GetProvider(r.Context(), k.OwnerID)
+		if errors.Is(err, registry.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return true, nil
+}
+
+// requireRole refuses requests from callers without role when roles are
+// enforced. Reads pass untouched when writesOnly is set.
+func (h *Handler) requireRole(role string, writesOnly bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.rbac || (writesOnly && isReadOnlyMethod(r.Method)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, err := h.hasRole(r, role)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+				return
+			}
+			if !ok {
+				writeError(w, http.StatusForbidden, "INSUFFICIENT_ROLE", "this route needs the "+role+
+					" role; providers send an API key from POST /v1/providers/{id}/keys")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireProof refuses requests from callers that neither send a proven
+// API key nor are admins when roles are enforced.
+func (h *Handler) requireProof(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.rbac && !provenCaller(r) && !h.isAdmin(r) {
+			writeError(w, http.StatusForbidden, "INSUFFICIENT_ROLE",
+				"this route needs a proven API key from POST /v1/providers/{id}/keys")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mayRegisterProvider reports whether the caller may register provider id:
+// anyone may register a new provider, but only the provider itself may
+// change a registered one when roles are enforced.
+func (h *Handler) mayRegisterProvider(r *http.Request, id string) (bool, error) {
+	if !h.rbac {
+		return true, nil
+	}
+	_, err := h.reg.GetProvider(r.Context(), id)
+	if errors.Is(err, registry.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ok, err := h.hasRole(r, RoleProvider)
+	return ok && apiKeyFrom(r.Context()).OwnerID == id, err
+}
+
+// createProviderKey handles POST /v1/providers/{id}/keys: a proven API key
+// acting as the provider, for a challenge from POST
+// /v1/providers/challenge signed with the provider's registered key.
+func (h *Handler) createProviderKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name               string   `json:"name"`
+		Scopes             []string `json:"scopes"`
+		Challenge          string   `json:"challenge"`
+		ChallengeSignature string   `json:"challenge_signature"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	if slices.Contains(req.Scopes, registry.ScopeAdmin) && !h.isAdmin(r) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only admins may create keys with the admin scope")
+		return
+	}
+	k, err := h.reg.CreateProviderAPIKey(r.Context(), chi.URLParam(r, "id"), req.Name, req.Scopes, req.Challenge, req.ChallengeSignature)
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "provider not found")
+	case errors.Is(err, registry.ErrInvalidKeyProof):
+		writeError(w, http.StatusForbidden, "INVALID_KEY_PROOF", err.Error())
+	case errors.Is(err, registry.ErrInvalidScope):
+		writeError(w, http.StatusBadRequest, "INVALID_SCOPE", err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, k)
+	}
+}
@@ -0,0 +1,138 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBAC(t *testing.T) {
+	h := newProxiedHandler(t, api.WithRBAC(true))
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	provider := validProviderPayload()
+	provider["pubkey"] = "ed25519:" + hex.EncodeToString(pub)
+	id := provider["id"].(string)
+	rr := doRequest(t, h, http.MethodPost, "/v1/providers", provider)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Naming the provider's DID is not enough to act as it.
+	rr = doAs(t, h, http.MethodPost, "/v1/tools", id, "", validToolPayload())
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INSUFFICIENT_ROLE")
+	rr = doAs(t, h, http.MethodPost, "/v1/providers", id, "", provider)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "a registered provider is only changed by itself")
+	rr = doAs(t, h, http.MethodPut, "/v1/receipts/rcpt_x/attestation", id, "", map[string]any{"format": "sgx-dcap", "quote": "cQ=="})
+	assert.Equal(t, http.StatusForbidden, rr.Code, "attestations are attached by providers")
+	assert.Contains(t, rr.Body.String(), "INSUFFICIENT_ROLE")
+	rr = doAs(t, h, http.MethodGet, "/v1/tools", id, "", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "consumers still read the catalog")
+
+	createKey := func(signer ed25519.PrivateKey) *httptest.ResponseRecorder {
+		rr := doRequest(t, h, http.MethodPost, "/v1/providers/challenge", map[string]any{"id": id, "pubkey": provider["pubkey"]})
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var c registry.KeyChallenge
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&c))
+		return doRequest(t, h, http.MethodPost, "/v1/providers/"+id+"/keys", map[string]any{
+			"name": "deploy", "scopes": []string{registry.ScopeToolsWrite}, "challenge": c.Challenge,
+			"challenge_signature": base64.StdEncoding.EncodeToString(ed25519.Sign(signer, []byte(c.Challenge))),
+		})
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	rr = createKey(other)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_KEY_PROOF")
+
+	rr = createKey(key)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var k registry.APIKey
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&k))
+	assert.True(t, k.Proven)
+	assert.Equal(t, id, k.OwnerID)
+
+	rr = doWithKey(t, h, http.MethodPost, "/v1/tools", k.Secret, validToolPayload())
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	rr = doWithKey(t, h, http.MethodPut, "/v1/receipts/rcpt_x/attestation", k.Secret, map[string]any{"format": "sgx-dcap", "quote": "cQ=="})
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the provider gets past the role check")
+	rr = doAs(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, id, "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doWithKey(t, h, http.MethodPost, "/v1/providers", k.Secret, provider)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = doWithKey(t, h, http.MethodDelete, "/v1/tools/"+tool.ID, k.Secret, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	// A key created without a proof is a consumer's, whatever its scopes.
+	rr = doAs(t, h, http.MethodPost, "/v1/keys", id, "", map[string]any{"scopes": []string{registry.ScopeToolsWrite}})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var unproven registry.APIKey
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&unproven))
+	assert.False(t, unproven.Proven)
+	rr = doWithKey(t, h, http.MethodPost, "/v1/tools", unproven.Secret, validToolPayload())
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/did:claw:agent:nobody/keys", map[string]any{"scopes": []string{"invoke"}})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Organization members are only changed with a proof too.
+	rr = doAs(t, h, http.MethodPost, "/v1/orgs", id, "", map[string]any{"name": "deployers"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	member := map[string]any{"member_id": "did:claw:agent:mallory"}
+	rr = doAs(t, h, http.MethodPost, "/v1/orgs/deployers/members", id, "", member)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INSUFFICIENT_ROLE")
+	rr = doWithKey(t, h, http.MethodPost, "/v1/orgs/deployers/members", k.Secret, member)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = doAs(t, h, http.MethodDelete, "/v1/orgs/deployers/members/did:claw:agent:mallory", id, "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doWithKey(t, h, http.MethodDelete, "/v1/orgs/deployers/members/did:claw:agent:mallory", k.Secret, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
+
+func TestAPIKeys_Proven(t *testing.T) {
+	h := newTestHandler(t)
+	const id = "did:claw:agent:prover"
+	secret := provenKey(t, h, id)
+	rr := doAs(t, h, http.MethodPost, "/v1/keys", id, "", map[string]any{"scopes": []string{registry.ScopeInvoke}})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	list := func(rr *httptest.ResponseRecorder) []*registry.APIKey {
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var list struct {
+			Keys []*registry.APIKey `json:"keys"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		return list.Keys
+	}
+	keys := list(doAs(t, h, http.MethodGet, "/v1/keys", id, "", nil))
+	require.Len(t, keys, 1, "naming the DID does not reveal proven keys")
+	assert.False(t, keys[0].Proven)
+	keys = list(doWithKey(t, h, http.MethodGet, "/v1/keys", secret, nil))
+	require.Len(t, keys, 2)
+	proven := keys[0]
+	if !proven.Proven {
+		proven = keys[1]
+	}
+
+	rr = doAs(t, h, http.MethodDelete, "/v1/keys/"+proven.ID, id, "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "nor revokes them")
+	rr = doWithKey(t, h, http.MethodGet, "/v1/tools", secret, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = doWithKey(t, h, http.MethodPost, "/v1/keys", secret, map[string]any{"scopes": []string{registry.ScopeInvoke}})
+	assert.Equal(t, http.StatusForbidden, rr.Code, "proven keys do not create keys")
+	rr = doWithKey(t, h, http.MethodDelete, "/v1/keys/"+proven.ID, secret, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = doWithKey(t, h, http.MethodGet, "/v1/tools", secret, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
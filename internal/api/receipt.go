@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// receipt returns the receipt of completed invocation id, or nil when it
+// cannot be read; the invocation has succeeded either way.
+func (h *Handler) receipt(ctx context.Context, r *http.Request, id string) *registry.Receipt {
+	rcpt, err := h.reg.InvocationReceipt(ctx, id)
+	if err != nil {
+		h.logger(r).Error("read receipt", zap.String("invocation_id", id), zap.Error(err))
+		return nil
+	}
+	return rcpt
+}
+
+// listReceipts handles GET /v1/receipts: the receipts of the caller's
+// invocations and of invocations of its tools, newest first. from and to
+// are UTC dates (YYYY-MM-DD), to inclusive.
+func (h *Handler) listReceipts(w http.ResponseWriter, r *http.Request) {
+	q := queryOf(r)
+	f := registry.ReceiptFilter{Party: providerIDFromRequest(r), ConsumerID: q.Get("consumer"), ToolID: q.Get("tool"),
+		From: q.Date("from", time.Time{}), To: q.Date("to", time.Time{})}
+	if !f.To.IsZero() {
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+	q.Check(f.From.IsZero() || f.To.IsZero() || f.From.Before(f.To), "to", "to must not be before from")
+	limit := q.Int("limit", 0)
+	if !q.valid(w) {
+		return
+	}
+	list, err := h.reg.ListReceipts(r.Context(), f, q.Get("cursor"), limit)
+	if errors.Is(err, registry.ErrInvalidCursor) {
+		q.Check(false, "cursor", err.Error())
+		q.valid(w)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// getReceipt handles GET /v1/receipts/{id} by the consumer of the
+// invocation or the provider of its tool.
+func (h *Handler) getReceipt(w http.ResponseWriter, r *http.Request) {
+	rcpt, err := h.reg.GetReceipt(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "receipt not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if caller := providerIDFromRequest(r); caller != rcpt.ConsumerID && caller != rcpt.ProviderID {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only the consumer or provider of an invocation may read its receipt")
+		return
+	}
+	writeJSON(w, http.StatusOK, rcpt)
+}
+
+// getReceiptProof handles GET /v1/receipts/{id}/proof: the inclusion proof
+// of the receipt in its anchored batch, for the consumer of the invocation
+// or the provider of its tool.
+func (h *Handler) getReceiptProof(w http.ResponseWriter, r *http.Request) {
+	rcpt, err := h.reg.GetReceipt(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, registry.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "receipt not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if caller := providerIDFromRequest(r); caller != rcpt.ConsumerID && caller != rcpt.ProviderID {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "only the consumer or provider of an invocation may read its receipt")
+		return
+	}
+	proof, err := h.reg.ReceiptProof(r.Context(), rcpt.ID)
+	switch {
+	case errors.Is(err, registry.ErrNotAnchored):
+		writeError(w, http.StatusNotFound, "NOT_ANCHORED", "the receipt has not been anchored yet")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	default:
+		writeJSON(w, http.StatusOK, proof)
+	}
+}
+
+// attachAttestation handles PUT /v1/receipts/{id}/attestation: the quote of
+// the trusted execution environment the provider of the tool ran the
+// invocation in.
+func (h *Handler) attachAttestation(w http.ResponseWriter, r *http.Request) {
+	var a registry.Attestation
+	if !decodeBody(w, r, 96<<10, &a) {
+		return
+	}
+	rcpt, err := h.reg.AttachAttestation(r.Context(), chi.URLParam(r, "id"), providerIDFromRequest(r), &a)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, rcpt)
+	case errors.Is(err, registry.ErrNotFound):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "receipt not found")
+	case errors.Is(err, registry.ErrForbidden):
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, registry.ErrInvalidAttestation):
+		writeError(w, http.StatusBadRequest, "INVALID_ATTESTATION", err.Error())
+	case errors.Is(err, registry.ErrReceiptSealed):
+		writeError(w, http.StatusConflict, "RECEIPT_SEALED", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+}
+
+// verifyReceiptRequest is the body of POST /v1/receipts/verify.
+type verifyReceiptRequest struct {
+	Receipt *registry.Receipt `json:"receipt"`
+	// Input and Output, when given, are checked against the receipt's
+	// hashes.
+	Input  any `json:"input"`
+	Output any `json:"output"`
+}
+
+// verifyReceipt handles POST /v1/receipts/verify: the verdict on a receipt,
+// which need not have been issued by this registry, against the registered
+// keys of its provider.
+func (h *Handler) verifyReceipt(w http.ResponseWriter, r *http.Request) {
+	// The body holds an input, an output and the receipt itself.
+	l := h.reg.Limits()
+	var req verifyReceiptRequest
+	if !decodeBody(w, r, int64(l.MaxInputBytes+l.MaxPayloadBytes)+64<<10, &req) {
+		return
+	}
+	if req.Receipt == nil {
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", "receipt is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.reg.VerifyReceipt(r.Context(), req.Receipt, req.Input, req.Output))
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceipts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer srv.Close()
+	h := newTestHandler(t)
+	payload := validToolPayload()
+	payload["endpoint"] = srv.URL
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	const consumer = "did:claw:agent:consumer"
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", map[string]any{"tool_id": tool.ID, "input": map[string]any{}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Receipt)
+	assert.Equal(t, resp.InvocationID, resp.Receipt.InvocationID)
+	assert.Equal(t, consumer, resp.Receipt.ConsumerID)
+	assert.Equal(t, tool.ProviderID, resp.Receipt.ProviderID)
+	assert.Equal(t, "5.0", resp.Receipt.CostCLAW)
+
+	// The consumer and the provider may both read the receipt, others not.
+	for _, did := range []string{consumer, tool.ProviderID} {
+		rr = doAs(t, h, http.MethodGet, "/v1/receipts/"+resp.Receipt.ID, did, "", nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var got registry.Receipt
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, resp.Receipt.ID, got.ID)
+
+		rr = doAs(t, h, http.MethodGet, "/v1/receipts?consumer="+consumer+"&tool="+tool.ID, did, "", nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var list registry.ReceiptList
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		require.Len(t, list.Receipts, 1)
+		assert.Equal(t, resp.Receipt.ID, list.Receipts[0].ID)
+	}
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts/"+resp.Receipt.ID, "did:claw:agent:other", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts", "did:claw:agent:other", "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"receipts": []}`, rr.Body.String())
+	rr = doRequest(t, h, http.MethodGet, "/v1/receipts/rcpt_missing", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Without an anchorer no receipt has an inclusion proof.
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts/"+resp.Receipt.ID+"/proof", consumer, "", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "NOT_ANCHORED")
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts/"+resp.Receipt.ID+"/proof", "did:claw:agent:other", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doRequest(t, h, http.MethodGet, "/v1/receipts/rcpt_missing/proof", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"NOT_FOUND"`)
+	rr = doRequest(t, h, http.MethodGet, "/v1/receipts?cursor=bogus", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "cursor")
+
+	// The provider may attach the attestation of its enclave, once.
+	attestation := map[string]any{"format": "sgx-dcap", "quote": "cXVvdGU="}
+	rr = doAs(t, h, http.MethodPut, "/v1/receipts/"+resp.Receipt.ID+"/attestation", consumer, "", attestation)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = doAs(t, h, http.MethodPut, "/v1/receipts/"+resp.Receipt.ID+"/attestation", tool.ProviderID, "",
+		map[string]any{"format": "sgx-dcap"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ATTESTATION")
+	rr = doAs(t, h, http.MethodPut, "/v1/receipts/"+resp.Receipt.ID+"/attestation", tool.ProviderID, "", attestation)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var attested registry.Receipt
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&attested))
+	require.NotNil(t, attested.Attestation)
+	assert.Equal(t, []byte("quote"), attested.Attestation.Quote)
+	rr = doAs(t, h, http.MethodPut, "/v1/receipts/"+resp.Receipt.ID+"/attestation", tool.ProviderID, "", attestation)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "RECEIPT_SEALED")
+	rr = doRequest(t, h, http.MethodPost, "/v1/receipts/verify", map[string]any{"receipt": attested})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"attestation":"unchecked"`, "no verifier for the format")
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts?from="+tomorrow, consumer, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"receipts": []}`, rr.Body.String())
+	today := time.Now().UTC().Format("2006-01-02")
+	rr = doAs(t, h, http.MethodGet, "/v1/receipts?from="+today+"&to="+today, consumer, "", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), resp.Receipt.ID)
+
+	for _, q := range []string{"from=yesterday", "from=" + tomorrow + "&to=" + today, "cursor=bogus"} {
+		rr = doAs(t, h, http.MethodGet, "/v1/receipts?"+q, consumer, "", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, q)
+		assert.Contains(t, rr.Body.String(), "INVALID_QUERY", q)
+	}
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	h := newTestHandler(t)
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	input, output := map[string]any{"q": "audit"}, map[string]any{"severity": "low"}
+	rcpt := &registry.Receipt{
+		InvocationID: "inv_1",
+		ToolID:       "did:claw:tool:abc",
+		ConsumerID:   testCaller,
+		ProviderID:   did.DIDKey(pub),
+		InputHash:    registry.HashPayload(input),
+		OutputHash:   registry.HashPayload(output),
+		CostCLAW:     "5.0",
+	}
+	receipts.Sign(rcpt, key)
+	verify := func(body map[string]any) receipts.Verdict {
+		rr := doRequest(t, h, http.MethodPost, "/v1/receipts/verify", body)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var v receipts.Verdict
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&v))
+		return v
+	}
+
+	v := verify(map[string]any{"receipt": rcpt, "input": input, "output": output})
+	assert.True(t, v.Valid, v.Reasons)
+	assert.Equal(t, receipts.CheckMatch, v.InputHash)
+	assert.Equal(t, receipts.CheckMatch, v.OutputHash)
+
+	v = verify(map[string]any{"receipt": rcpt, "output": map[string]any{"severity": "high"}})
+	assert.False(t, v.Valid)
+	assert.Equal(t, receipts.CheckValid, v.Signature)
+	assert.Equal(t, receipts.CheckUnchecked, v.InputHash)
+	assert.Equal(t, receipts.CheckMismatch, v.OutputHash)
+
+	forged := *rcpt
+	forged.CostCLAW = "0.5"
+	v = verify(map[string]any{"receipt": forged})
+	assert.False(t, v.Valid)
+	assert.Equal(t, receipts.CheckInvalid, v.Signature)
+
+	// A provider with no key cannot have signed it.
+	forged = *rcpt
+	forged.ProviderID = "did:claw:agent:unknown"
+	v = verify(map[string]any{"receipt": forged})
+	assert.False(t, v.Valid)
+	assert.Equal(t, receipts.CheckInvalid, v.Signature)
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/receipts/verify", map[string]any{})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_BODY")
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/cors"
+	"go.uber.org/zap"
+)
+
+// corsPolicy holds the CORS configuration so it can be swapped while serving.
+type corsPolicy struct {
+	current atomic.Pointer[cors.Cors]
+}
+
+func (p *corsPolicy) set(origins []string) {
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	p.current.Store(cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", namespaceHeader, apiKeyHeader},
+	}))
+}
+
+func (p *corsPolicy) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.current.Load().Handler(next).ServeHTTP(w, r)
+	})
+}
+
+// WithCORSOrigins restricts cross-origin requests to origins (default "*").
+func WithCORSOrigins(origins []string) Option {
+	return func(h *Handler) { h.cors.set(origins) }
+}
+
+// SetCORSOrigins replaces the allowed CORS origins of a running handler.
+func (h *Handler) SetCORSOrigins(origins []string) {
+	h.cors.set(origins)
+}
+
+// WithReload exposes POST /admin/reload, which calls reload to re-read the
+// runtime settings. Without it, or without an admin token to guard it, the
+// endpoint is not registered.
+func WithReload(reload func() error) Option {
+	return func(h *Handler) { h.reload = reload }
+}
+
+// adminReload handles POST /admin/reload.
+func (h *Handler) adminReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.reload(); err != nil {
+		h.logger(r).Warn("config reload failed", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "RELOAD_FAILED", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAdminReload(t *testing.T) {
+	calls := 0
+	var fail error
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"), api.WithReload(func() error {
+		calls++
+		return fail
+	}))
+
+	rr := adminRequest(t, h, http.MethodPost, "/admin/reload", "s3cret", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, calls)
+
+	fail = errors.New("bad config")
+	rr = adminRequest(t, h, http.MethodPost, "/admin/reload", "s3cret", "")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "RELOAD_FAILED")
+
+	rr = doRequest(t, h, http.MethodPost, "/admin/reload", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, 2, calls, "only admins reload")
+}
+
+func TestAdminReload_NotRegisteredByDefault(t *testing.T) {
+	rr := doRequest(t, newTestHandler(t), http.MethodPost, "/admin/reload", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	h := newProxiedHandler(t, api.WithReload(func() error { return nil }))
+	rr = doRequest(t, h, http.MethodPost, "/admin/reload", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "not served without an admin token")
+}
+
+func TestSetCORSOrigins(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t),
+		api.WithCORSOrigins([]string{"https://a.example.com"}))
+
+	allowed := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Equal(t, "https://a.example.com", allowed("https://a.example.com"))
+	assert.Empty(t, allowed("https://b.example.com"))
+
+	h.SetCORSOrigins([]string{"https://b.example.com"})
+	assert.Empty(t, allowed("https://a.example.com"))
+	assert.Equal(t, "https://b.example.com", allowed("https://b.example.com"))
+}
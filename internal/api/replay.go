@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// replayResult compares a replay of an invocation with the original.
+type replayResult struct {
+	InvocationID       string         `json:"invocation_id"`
+	ToolID             string         `json:"tool_id"`
+	OriginalOutputHash string         `json:"original_output_hash,omitempty"`
+	OutputHash         string         `json:"output_hash,omitempty"`
+	Match              bool           `json:"match"`
+	Output             map[string]any `json:"output,omitempty"`
+	DurationMS         int64          `json:"duration_ms"`
+	Error              string         `json:"error,omitempty"`
+}
+
+// replayInvocation handles POST /v1/invocations/{id}/replay by the provider
+// of the invoked tool.
+func (h *Handler) replayInvocation(w http.ResponseWriter, r *http.Request) {
+	h.replay(w, r, providerIDFromRequest(r))
+}
+
+// adminReplayInvocation handles POST /admin/invocations/{id}/replay, for
+// any invocation.
+func (h *Handler) adminReplayInvocation(w http.ResponseWriter, r *http.Request) {
+	h.replay(w, r, "")
+}
+
+// replay runs an invocation again with its stored input and compares the
+// output hash with the recorded one. The replay is not recorded or billed,
+// but revoked tools and blocked providers are refused as for invocations.
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request, owner string) {
+	rp, err := h.reg.ReplayInvocation(r.Context(), chi.URLParam(r, "id"), owner)
+	if err != nil {
+		h.writePayloadError(w, r, err)
+		return
+	}
+	if ierr := h.checkInvocable(r, rp.Tool); ierr != nil {
+		writeInvokeError(w, ierr)
+		return
+	}
+	run := h.runner(rp.Tool)
+	if run == nil {
+		writeError(w, http.StatusNotImplemented, "NOT_IMPLEMENTED", "invoking tools at "+rp.Tool.Endpoint+" is not supported")
+		return
+	}
+
+	res := replayResult{
+		InvocationID:       rp.Invocation.ID,
+		ToolID:             rp.Tool.ID,
+		OriginalOutputHash: rp.Invocation.OutputHash,
+	}
+	start := time.Now()
+	out, err := run(r.Context(), rp.Input, time.Duration(rp.Tool.TimeoutMS)*time.Millisecond)
+	res.DurationMS = time.Since(start).Milliseconds()
+	if err == nil {
+		if jerr := json.Unmarshal(out, &res.Output); jerr != nil || res.Output == nil {
+			err = errOutput
+		}
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.OutputHash = registry.HashPayload(res.Output)
+		res.Match = res.OutputHash == res.OriginalOutputHash
+	}
+	writeJSON(w, http.StatusOK, res)
+}
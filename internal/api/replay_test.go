@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// newPayloadHandler returns a handler whose registry can store payloads,
+// with admin token s3cret, and a tool of testCaller answering with the
+// current value of version.
+func newPayloadHandler(t *testing.T, version *atomic.Int32) (http.Handler, string) {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	box, err := secrets.New(bytes.Repeat([]byte{1}, secrets.KeySize))
+	require.NoError(t, err)
+	reg := registry.New(db, zaptest.NewLogger(t), registry.WithSecrets(box))
+	h := api.NewHandler(reg, zaptest.NewLogger(t), api.WithAdminToken("s3cret"))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"version": version.Load()})
+	}))
+	t.Cleanup(srv.Close)
+	return h, registerRPCTool(t, h, "versioned", srv.URL)
+}
+
+func invokeStoring(t *testing.T, h http.Handler, toolID, store string) string {
+	t.Helper()
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{
+		"tool_id": toolID, "input": map[string]any{"q": "x"}, "store_payload": store,
+	})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	return resp.InvocationID
+}
+
+func TestReplayInvocation(t *testing.T) {
+	var version atomic.Int32
+	h, toolID := newPayloadHandler(t, &version)
+	replay := func(rr *httptest.ResponseRecorder) map[string]any {
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var res map[string]any
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&res))
+		return res
+	}
+
+	stored := invokeStoring(t, h, toolID, "consumer")
+	res := replay(doRequest(t, h, http.MethodPost, "/v1/invocations/"+stored+"/replay", nil))
+	assert.Equal(t, true, res["match"])
+	assert.Equal(t, res["original_output_hash"], res["output_hash"])
+
+	version.Store(2)
+	res = replay(adminRequest(t, h, http.MethodPost, "/admin/invocations/"+stored+"/replay", "s3cret", ""))
+	assert.Equal(t, false, res["match"], "the provider now answers differently")
+	assert.Equal(t, map[string]any{"version": float64(2)}, res["output"])
+
+	rr := doAs(t, h, http.MethodPost, "/v1/invocations/"+stored+"/replay", "did:claw:agent:someone-else", "", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "only the tool's provider may replay")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invocations/"+invokeStoring(t, h, toolID, "")+"/replay", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INPUT_NOT_STORED")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invocations/inv_missing/replay", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret",
+		`{"action":"deny","field":"did","pattern":"`+testCaller+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = adminRequest(t, h, http.MethodPost, "/admin/invocations/"+stored+"/replay", "s3cret", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "replays obey the provider rules")
+	assert.Contains(t, rr.Body.String(), "PROVIDER_BLOCKED")
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/errreport"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// reportBodyLimit caps how much of a response body is kept to extract the
+// error code and message of a 5xx response.
+const reportBodyLimit = 4096
+
+// WithErrorReporter sets the reporter notified of 5xx responses and panics.
+func WithErrorReporter(rep errreport.Reporter) Option {
+	return func(h *Handler) { h.reporter = rep }
+}
+
+// reportErrors recovers panics (replacing chi's Recoverer) and forwards
+// panics and 5xx responses to the configured error reporter.
+func (h *Handler) reportErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		body := &limitedBuffer{limit: reportBodyLimit}
+		ww.Tee(body)
+
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				stack := debug.Stack()
+				h.logger(r).Error("panic", zap.Any("panic", rec), zap.ByteString("stack", stack))
+				h.report(r, &errreport.Event{Status: http.StatusInternalServerError, Panic: rec, Stack: stack})
+				if ww.Status() == 0 {
+					writeError(ww, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+				}
+				return
+			}
+			// Deliberate unavailability (draining, maintenance) carries
+			// Retry-After and is not an error worth reporting.
+			if ww.Status() >= http.StatusInternalServerError && ww.Header().Get("Retry-After") == "" {
+				ev := &errreport.Event{Status: ww.Status()}
+				var e apiError
+				if json.Unmarshal(body.Bytes(), &e) == nil {
+					ev.Code = e.Error.Code
+					ev.Message = e.Error.Message
+				}
+				h.report(r, ev)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+func (h *Handler) report(r *http.Request, ev *errreport.Event) {
+	if h.reporter == nil {
+		return
+	}
+	ev.Time = time.Now()
+	ev.RequestID = middleware.GetReqID(r.Context())
+	ev.Method = r.Method
+	ev.Path = r.URL.Path
+	h.reporter.Report(r.Context(), ev)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			_, _ = b.Buffer.Write(p[:room])
+		} else {
+			_, _ = b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/errreport"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []*errreport.Event
+}
+
+func (r *recordingReporter) Report(_ context.Context, ev *errreport.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func TestErrorReporter_5xx(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	rep := &recordingReporter{}
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t), api.WithErrorReporter(rep))
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools", nil)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Len(t, rep.events, 1)
+	ev := rep.events[0]
+	assert.Equal(t, http.StatusInternalServerError, ev.Status)
+	assert.Equal(t, "INTERNAL_ERROR", ev.Code)
+	assert.NotEmpty(t, ev.Message)
+	assert.Equal(t, "/v1/tools", ev.Path)
+	assert.Equal(t, rr.Header().Get("X-Request-Id"), ev.RequestID)
+}
+
+func TestErrorReporter_IgnoresClientErrors(t *testing.T) {
+	rep := &recordingReporter{}
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t), api.WithErrorReporter(rep))
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools/did:claw:tool:missing", nil)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rep.events)
+}
+
+func TestErrorReporter_IgnoresMaintenance(t *testing.T) {
+	rep := &recordingReporter{}
+	h := newProxiedHandler(t, api.WithErrorReporter(rep))
+	require.NoError(t, h.SetMaintenance(context.Background(), api.Maintenance{Mode: api.MaintenanceFull}))
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools", nil)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Empty(t, rep.events)
+}
+
+func TestErrorReporter_Panic(t *testing.T) {
+	rep := &recordingReporter{}
+	// A nil registry makes every data route panic.
+	h := api.NewHandler(nil, zaptest.NewLogger(t), api.WithErrorReporter(rep))
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/tools", nil)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INTERNAL_ERROR")
+	require.Len(t, rep.events, 1)
+	assert.NotNil(t, rep.events[0].Panic)
+	assert.NotEmpty(t, rep.events[0].Stack)
+}
+
+func TestRecoverer_WithoutReporter(t *testing.T) {
+	h := api.NewHandler(nil, zaptest.NewLogger(t))
+	rr := doRequest(t, h, http.MethodGet, "/v1/providers", nil)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
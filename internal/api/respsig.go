@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+type responseSigKey struct{}
+
+// withResponseSignature records on ctx that the invocation was proxied by
+// the router and what its provider signed the answer with, empty when it
+// did not.
+func withResponseSignature(ctx context.Context, sig string) context.Context {
+	return context.WithValue(ctx, responseSigKey{}, sig)
+}
+
+// checkResponseSignature checks the provider's signature of the receipt of
+// invocation id of tool, which answered input with output at cost, and
+// returns it for the receipt. Only answers to invocations the router
+// proxied are checked.
+func (h *Handler) checkResponseSignature(
+	r *http.Request, tool *registry.Tool, id string, input []byte, output map[string]any, cost string,
+) (string, error) {
+	sig, ok := r.Context().Value(responseSigKey{}).(string)
+	if !ok {
+		return "", nil
+	}
+	rcpt := &registry.Receipt{
+		InvocationID: id,
+		ToolID:       tool.ID,
+		ConsumerID:   providerIDFromRequest(r),
+		ProviderID:   tool.ProviderID,
+		InputHash:    receipts.HashJSON(input),
+		OutputHash:   registry.HashPayload(output),
+		CostCLAW:     cost,
+		ProviderSig:  sig,
+	}
+	return sig, h.reg.VerifyResponseSignature(context.WithoutCancel(r.Context()), rcpt)
+}
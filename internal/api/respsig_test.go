@@ -0,0 +1,82 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestInvoke_ResponseSignature(t *testing.T) {
+	providerPub, providerKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, registryKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	// The provider signs the receipt of each call, for the cost the query
+	// names, unless it is "none".
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		output := map[string]any{"ok": true}
+		if cost := r.URL.Query().Get("cost"); cost != "none" {
+			c, err := receipts.DecodeCallSignature(r.Header.Get(receipts.CallSignatureHeader))
+			require.NoError(t, err)
+			w.Header().Set(receipts.ResponseSignatureHeader, receipts.SignResponse(c, output, cost, providerKey))
+		}
+		_ = json.NewEncoder(w).Encode(output)
+	}))
+	defer srv.Close()
+
+	newHandler := func(opts ...registry.Option) *api.Handler {
+		db, err := store.Open(":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		return api.NewHandler(registry.New(db, zaptest.NewLogger(t), opts...), zaptest.NewLogger(t), api.WithRegistryKey(registryKey))
+	}
+	provider := did.DIDKey(providerPub)
+	register := func(h *api.Handler, name, cost string) string {
+		payload := validToolPayload()
+		payload["name"] = name
+		payload["endpoint"] = srv.URL + "?cost=" + cost
+		rr := doAs(t, h, http.MethodPost, "/v1/tools", provider, "", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		return tool.ID
+	}
+	invoke := func(h *api.Handler, toolID string) *httptest.ResponseRecorder {
+		return doAs(t, h, http.MethodPost, "/v1/invoke", "did:claw:agent:consumer", "",
+			map[string]any{"tool_id": toolID, "input": map[string]any{"input": "hello"}})
+	}
+
+	h := newHandler()
+	rr := invoke(h, register(h, "signed", "5.0"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotNil(t, resp.Receipt)
+	v := receipts.Verify(resp.Receipt, map[string]any{"input": "hello"}, resp.Output, providerPub)
+	assert.True(t, v.Valid, v.Reasons)
+
+	rr = invoke(h, register(h, "wrong-cost", "0.1"))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_RESPONSE_SIGNATURE")
+
+	rr = invoke(h, register(h, "unsigned", "none"))
+	require.Equal(t, http.StatusOK, rr.Code, "unsigned answers pass unless signatures are required")
+
+	h = newHandler(registry.WithRequiredResponseSignatures(true))
+	rr = invoke(h, register(h, "unsigned", "none"))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_RESPONSE_SIGNATURE")
+	rr = invoke(h, register(h, "signed", "5.0"))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+)
+
+// exhaustedError is the last error of an invocation that failed on every
+// attempt its tool's retry policy allows. The invocation is dead-lettered
+// rather than failed.
+type exhaustedError struct {
+	err      error
+	attempts int
+}
+
+func (e *exhaustedError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.err, e.attempts)
+}
+
+func (e *exhaustedError) Unwrap() error { return e.err }
+
+// runWithRetry runs an invocation of tool with input, and runs it again
+// after a backoff while it fails in a way the tool's retry policy retries,
+// the provider did not say retrying is futile, and attempts are left. It returns the output, how many attempts it took
+// and, when every attempt failed, an *exhaustedError if the last failure
+// was one the policy retries.
+func runWithRetry(ctx context.Context, tool *registry.Tool, run runFunc, input []byte) ([]byte, int, error) {
+	timeout := time.Duration(tool.TimeoutMS) * time.Millisecond
+	policy := tool.Retry
+	for attempts := 1; ; attempts++ {
+		out, err := run(ctx, input, timeout)
+		if err == nil || policy == nil || !policy.Retries(runError(err).code, providerStatus(err)) || refusesRetry(err) {
+			return out, attempts, err
+		}
+		if attempts >= policy.MaxAttempts {
+			return nil, attempts, &exhaustedError{err: err, attempts: attempts}
+		}
+		t := time.NewTimer(policy.Backoff(attempts))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, attempts, err
+		case <-t.C:
+		}
+	}
+}
+
+// refusesRetry reports whether the provider failed with an error it says
+// is not retryable.
+func refusesRetry(err error) bool {
+	var se *router.StatusError
+	return errors.As(err, &se) && se.Retryable != nil && !*se.Retryable
+}
+
+// providerStatus returns the HTTP status the provider failed with, or 0
+// when it did not answer.
+func providerStatus(err error) int {
+	var se *router.StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
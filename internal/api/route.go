@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// route resolves the capability of req, if it names one in place of a tool,
+// to the tool the routing strategy ranks first, passing over tools whose
+// endpoints all have their circuit open while another is left.
+func (h *Handler) route(r *http.Request, req *registry.InvokeRequest) *invokeError {
+	if req.Capability == "" {
+		if req.Strategy != "" {
+			return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "strategy requires capability"}
+		}
+		return nil
+	}
+	if req.ToolID != "" {
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: "tool_id and capability are mutually exclusive"}
+	}
+	input := req.Input
+	if input == nil {
+		input = map[string]any{}
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	}
+	candidates, err := h.reg.Route(r.Context(), req.Capability, req.Strategy, b)
+	switch {
+	case errors.Is(err, registry.ErrUnknownStrategy):
+		return &invokeError{status: http.StatusBadRequest, code: "INVALID_BODY", msg: err.Error()}
+	case errors.Is(err, registry.ErrNoRoute):
+		return &invokeError{status: http.StatusNotFound, code: "CAPABILITY_NOT_FOUND", msg: err.Error()}
+	case err != nil:
+		return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+	}
+	req.ToolID = candidates[0].Tool.ID
+	for _, c := range candidates {
+		if !h.circuitsOpen(c.Tool) {
+			req.ToolID = c.Tool.ID
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoke_Capability(t *testing.T) {
+	var requests atomic.Int32
+	srv := rpcProvider(t, &requests)
+	h := newTestHandler(t)
+
+	var ids []string
+	for _, p := range []struct{ did, price string }{
+		{"did:claw:agent:dear", "4.0"},
+		{"did:claw:agent:cheap", "1.0"},
+	} {
+		payload := validToolPayload()
+		payload["name"] = "double"
+		payload["endpoint"] = registry.JSONRPCScheme + srv.URL + "/rpc#double"
+		payload["pricing"] = map[string]any{"model": "per_call", "amount_claw": p.price}
+		rr := doAs(t, h, http.MethodPost, "/v1/tools", p.did, "", payload)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var tool registry.Tool
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+		ids = append(ids, tool.ID)
+	}
+	dear, cheap := ids[0], ids[1]
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"capability": "double", "input": map[string]any{"n": 2}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, cheap, resp.ToolID, "cheapest by default")
+	assert.Equal(t, map[string]any{"n": float64(4)}, resp.Output)
+	assert.Equal(t, "1.0", resp.CostCLAW)
+
+	// Both providers have no reputation yet, so the tie keeps the oldest.
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke",
+		map[string]any{"capability": "double", "strategy": "highest_reputation", "input": map[string]any{"n": 2}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, dear, resp.ToolID)
+
+	for name, tc := range map[string]struct {
+		body   map[string]any
+		status int
+		code   string
+	}{
+		"both":             {map[string]any{"tool_id": cheap, "capability": "double"}, http.StatusBadRequest, "INVALID_BODY"},
+		"unknown strategy": {map[string]any{"capability": "double", "strategy": "random"}, http.StatusBadRequest, "INVALID_BODY"},
+		"strategy alone":   {map[string]any{"tool_id": cheap, "strategy": "cheapest"}, http.StatusBadRequest, "INVALID_BODY"},
+		"no provider":      {map[string]any{"capability": "triple"}, http.StatusNotFound, "CAPABILITY_NOT_FOUND"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := doRequest(t, h, http.MethodPost, "/v1/invoke", tc.body)
+			assert.Equal(t, tc.status, rr.Code, rr.Body.String())
+			assert.Contains(t, rr.Body.String(), tc.code)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/go-chi/chi/v5"
+)
+
+// listProviderRules handles GET /admin/provider-rules.
+func (h *Handler) listProviderRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.reg.ListProviderRules(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+// addProviderRule handles POST /admin/provider-rules.
+func (h *Handler) addProviderRule(w http.ResponseWriter, r *http.Request) {
+	var rule registry.ProviderRule
+	if !decodeBody(w, r, 0, &rule) {
+		return
+	}
+	created, err := h.reg.AddProviderRule(r.Context(), &rule)
+	if err != nil {
+		if errors.Is(err, registry.ErrDuplicate) {
+			writeError(w, http.StatusConflict, "DUPLICATE_RULE", err.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "INVALID_RULE", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// deleteProviderRule handles DELETE /admin/provider-rules/{id}.
+func (h *Handler) deleteProviderRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.reg.DeleteProviderRule(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, registry.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "RULE_NOT_FOUND", "rule not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRules_API(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret",
+		`{"action":"deny","field":"did","pattern":"did:claw:agent:test-*","reason":"no test tools"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var rule map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rule))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "PROVIDER_BLOCKED")
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/provider-rules", "s3cret", "")
+	assert.Contains(t, rr.Body.String(), "no test tools")
+
+	rr = adminRequest(t, h, http.MethodDelete, "/admin/provider-rules/"+rule["id"].(string), "s3cret", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = adminRequest(t, h, http.MethodDelete, "/admin/provider-rules/"+rule["id"].(string), "s3cret", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", validToolPayload())
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestProviderRules_CheckedOnInvoke(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"output": "ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+	toolID := registerRPCTool(t, h, "registered-before", srv.URL)
+	invoke := map[string]any{"tool_id": toolID, "input": map[string]any{}}
+	require.Equal(t, http.StatusOK, doRequest(t, h, http.MethodPost, "/v1/invoke", invoke).Code)
+
+	rr := adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret",
+		`{"action":"deny","field":"endpoint","pattern":"`+srv.URL+`*","reason":"decommissioned"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", invoke)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "a new rule stops traffic to tools registered before it")
+	assert.Contains(t, rr.Body.String(), "PROVIDER_BLOCKED")
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke/batch", map[string]any{"invocations": []any{invoke}})
+	assert.Contains(t, rr.Body.String(), "PROVIDER_BLOCKED", "batch invocations too")
+}
+
+func TestProviderRules_APIValidation(t *testing.T) {
+	h := newProxiedHandler(t, api.WithAdminToken("s3cret"))
+
+	rr := adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret", `{"action":"nope"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_RULE")
+
+	body := `{"action":"deny","field":"endpoint","pattern":"*"}`
+	require.Equal(t, http.StatusCreated, adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret", body).Code)
+	rr = adminRequest(t, h, http.MethodPost, "/admin/provider-rules", "s3cret", body)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = adminRequest(t, h, http.MethodGet, "/admin/provider-rules", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
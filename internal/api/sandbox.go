@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+)
+
+// WithSandbox enables uploading WebAssembly modules and invoking tools whose
+// endpoint is a wasm:// module, executed by ex.
+func WithSandbox(ex *sandbox.Executor) Option {
+	return func(h *Handler) { h.sandbox = ex }
+}
+
+// WithContainers enables invoking tools whose endpoint is an oci:// image,
+// run in ephemeral containers by c.
+func WithContainers(c *sandbox.Containers) Option {
+	return func(h *Handler) { h.containers = c }
+}
+
+// runFunc runs a tool: a wasm module or container image on the registry, or
+// a call to its provider.
+type runFunc func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error)
+
+// sandboxRunner returns how to run tool on the registry, or nil when its
+// endpoint is a remote provider or its kind of sandbox is not enabled.
+func (h *Handler) sandboxRunner(tool *registry.Tool) runFunc {
+	if digest, ok := registry.ModuleDigest(tool.Endpoint); ok && h.sandbox != nil {
+		return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+			return h.sandbox.Run(ctx, digest, input, timeout)
+		}
+	}
+	if image, ok := registry.ContainerImage(tool.Endpoint); ok && h.containers != nil {
+		return func(ctx context.Context, input []byte, timeout time.Duration) ([]byte, error) {
+			return h.containers.Run(ctx, image, input, timeout)
+		}
+	}
+	return nil
+}
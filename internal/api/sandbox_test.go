@@ -0,0 +1,59 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// echoRuntime stands in for a container runtime: it copies stdin to stdout
+// and records the spec it was given.
+type echoRuntime struct{ spec sandbox.ContainerSpec }
+
+func (e *echoRuntime) Run(_ context.Context, spec sandbox.ContainerSpec, stdin io.Reader, stdout, _ io.Writer) error {
+	e.spec = spec
+	_, err := io.Copy(stdout, stdin)
+	return err
+}
+
+func TestInvoke_Container(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	rt := &echoRuntime{}
+	reg := registry.New(db, zaptest.NewLogger(t))
+	h := api.NewHandler(reg, zaptest.NewLogger(t), api.WithContainers(sandbox.NewContainers(rt, sandbox.ContainerLimits{})))
+
+	image := "ghcr.io/acme/echo@sha256:" + strings.Repeat("ab", 32)
+	payload := validToolPayload()
+	payload["endpoint"] = registry.OCIScheme + image
+	rr := doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": tool.ID, "input": map[string]any{"n": 1}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"n": float64(1)}, resp.Output)
+	assert.Equal(t, image, rt.spec.Image)
+	assert.False(t, rt.spec.Limits.Network, "containers get no network by default")
+
+	payload["version"] = "2.0.0"
+	payload["endpoint"] = registry.OCIScheme + "ghcr.io/acme/echo:latest"
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_ENDPOINT")
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"go.uber.org/zap"
+)
+
+// provenCaller reports whether the caller of r proved it is
+// providerIDFromRequest(r), with a proven API key, rather than only naming
+// a DID in the Authorization header.
+func provenCaller(r *http.Request) bool {
+	k := apiKeyFrom(r.Context())
+	return k != nil && k.Proven
+}
+
+// walletOwner returns the consumer whose wallet the caller of r may use,
+// or writes the error and returns false. Escrow accounts hold CLAW, so a
+// claimed DID is not enough: the caller must send a proven API key.
+func (h *Handler) walletOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	consumer := providerIDFromRequest(r)
+	switch {
+	case consumer == anonymousConsumer:
+		unauthorized(w, "wallets belong to an identity; send Authorization")
+	case !h.reg.SettlesOnChain():
+		h.writeWalletError(w, r, registry.ErrNoWallet)
+	case !provenCaller(r):
+		unauthorized(w, "wallets need a proven API key (POST /v1/providers/:id/keys); a DID in Authorization proves nothing")
+	default:
+		return consumer, true
+	}
+	return "", false
+}
+
+// getWallet handles GET /v1/wallet: the caller's escrow account, which it
+// funds to pay for invocations when the registry settles payments on
+// chain, and its own account.
+func (h *Handler) getWallet(w http.ResponseWriter, r *http.Request) {
+	consumer, ok := h.walletOwner(w, r)
+	if !ok {
+		return
+	}
+	info, err := h.reg.Wallet(r.Context(), consumer)
+	if err != nil {
+		h.writeWalletError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// withdrawFromWallet handles POST /v1/wallet/withdraw, which sends CLAW the
+// caller's escrow account does not hold for invocations to its own account.
+func (h *Handler) withdrawFromWallet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AmountCLAW string `json:"amount_claw"`
+	}
+	if !decodeBody(w, r, 4<<10, &req) {
+		return
+	}
+	consumer, ok := h.walletOwner(w, r)
+	if !ok {
+		return
+	}
+	wd, err := h.reg.Withdraw(r.Context(), consumer, req.AmountCLAW)
+	if err != nil {
+		h.writeWalletError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wd)
+}
+
+// writeWalletError writes the response to a failed wallet request.
+func (h *Handler) writeWalletError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNoWallet):
+		writeError(w, http.StatusNotFound, "NO_WALLET", err.Error())
+	case errors.Is(err, registry.ErrInvalidAmount):
+		writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+	case errors.Is(err, registry.ErrNoAccount):
+		writeError(w, http.StatusBadRequest, "NO_ACCOUNT", err.Error())
+	default:
+		ierr := walletError(err)
+		if ierr.status == http.StatusInternalServerError {
+			h.logger(r).Error("wallet", zap.Error(err))
+		}
+		writeInvokeError(w, ierr)
+	}
+}
+
+// walletError maps an error holding or moving CLAW to its response.
+func walletError(err error) *invokeError {
+	switch {
+	case errors.Is(err, registry.ErrInsufficientFunds):
+		return &invokeError{status: http.StatusPaymentRequired, code: "INSUFFICIENT_FUNDS", msg: err.Error()}
+	case errors.Is(err, registry.ErrChain):
+		return &invokeError{status: http.StatusBadGateway, code: "CHAIN_UNAVAILABLE", msg: err.Error()}
+	}
+	return &invokeError{status: http.StatusInternalServerError, code: "INTERNAL_ERROR", msg: err.Error()}
+}
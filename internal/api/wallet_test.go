@@ -0,0 +1,181 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fixedWallet reports the balances it is given by address and refuses
+// transfers.
+type fixedWallet map[string]string
+
+func (w fixedWallet) Address(account ed25519.PublicKey) string { return hex.EncodeToString(account) }
+
+func (w fixedWallet) Balance(_ context.Context, account ed25519.PublicKey) (string, error) {
+	if b, ok := w[w.Address(account)]; ok {
+		return b, nil
+	}
+	return "0", nil
+}
+
+func (w fixedWallet) Transfer(context.Context, ed25519.PrivateKey, ed25519.PublicKey, string) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func (w fixedWallet) Reserve() (deposit, fee string) { return "0", "0" }
+
+// provenKey registers the provider id with a key of its own and returns
+// the secret of a proven API key acting as it.
+func provenKey(t *testing.T, h http.Handler, id string) string {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	provider := validProviderPayload()
+	provider["id"], provider["pubkey"] = id, "ed25519:"+hex.EncodeToString(pub)
+	rr := doAs(t, h, http.MethodPost, "/v1/providers", id, "", provider)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/challenge", map[string]any{"id": id, "pubkey": provider["pubkey"]})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var c registry.KeyChallenge
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&c))
+	rr = doRequest(t, h, http.MethodPost, "/v1/providers/"+id+"/keys", map[string]any{
+		"name": "wallet", "scopes": []string{registry.ScopeToolsRead, registry.ScopeInvoke}, "challenge": c.Challenge,
+		"challenge_signature": base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(c.Challenge))),
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var k registry.APIKey
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&k))
+	return k.Secret
+}
+
+func newWalletHandler(t *testing.T, wallet registry.Wallet) *api.Handler {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	reg := registry.New(db, zaptest.NewLogger(t), registry.WithWallet(wallet, []byte("escrow seed")))
+	return api.NewHandler(reg, zaptest.NewLogger(t))
+}
+
+func TestWallet_HoldsInvocations(t *testing.T) {
+	wallet := fixedWallet{}
+	h := newWalletHandler(t, wallet)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"output": "ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	toolID := registerRPCTool(t, h, "paid", srv.URL)
+	const consumer = "did:claw:agent:payer"
+	secret := provenKey(t, h, consumer)
+
+	rr := doWithKey(t, h, http.MethodGet, "/v1/wallet", secret, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var info registry.WalletInfo
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&info))
+	assert.Equal(t, consumer, info.ConsumerID)
+	assert.Equal(t, "0", info.AvailableCLAW)
+	assert.NotEmpty(t, info.Address, "the provider's registered key")
+
+	invoke := map[string]any{"tool_id": toolID, "input": map[string]any{}}
+	rr = doWithKey(t, h, http.MethodPost, "/v1/invoke", secret, invoke)
+	require.Equal(t, http.StatusPaymentRequired, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "INSUFFICIENT_FUNDS")
+
+	wallet[info.EscrowAddress] = "7"
+	rr = doWithKey(t, h, http.MethodPost, "/v1/invoke", secret, invoke)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = doWithKey(t, h, http.MethodPost, "/v1/invoke", secret, invoke)
+	require.Equal(t, http.StatusPaymentRequired, rr.Code, "5 of the 7 CLAW are held for the first invocation")
+
+	rr = doWithKey(t, h, http.MethodGet, "/v1/wallet", secret, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&info))
+	assert.Equal(t, "5", info.HeldCLAW)
+	assert.Equal(t, "2", info.AvailableCLAW)
+
+	for _, tc := range []struct {
+		amount string
+		status int
+		code   string
+	}{
+		{"ten", http.StatusBadRequest, "INVALID_AMOUNT"},
+		{"3", http.StatusPaymentRequired, "INSUFFICIENT_FUNDS"},
+		{"1", http.StatusBadGateway, "CHAIN_UNAVAILABLE"},
+	} {
+		rr = doWithKey(t, h, http.MethodPost, "/v1/wallet/withdraw", secret, map[string]any{"amount_claw": tc.amount})
+		assert.Equal(t, tc.status, rr.Code, tc.amount)
+		assert.Contains(t, rr.Body.String(), tc.code, tc.amount)
+	}
+}
+
+func TestWallet_SpoofedDIDCannotSpend(t *testing.T) {
+	wallet := fixedWallet{}
+	h := newWalletHandler(t, wallet)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"output": "ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	const victim = "did:claw:agent:victim"
+	secret := provenKey(t, h, victim)
+	rr := doWithKey(t, h, http.MethodGet, "/v1/wallet", secret, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var info registry.WalletInfo
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&info))
+	wallet[info.EscrowAddress] = "100"
+
+	// The attacker's own paid tool, invoked in the victim's name.
+	toolID := registerRPCTool(t, h, "attacker-tool", srv.URL)
+	invoke := map[string]any{"tool_id": toolID, "input": map[string]any{}}
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", victim, "", invoke)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke/batch", victim, "", map[string]any{"invocations": []any{invoke}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "UNAUTHORIZED")
+	rr = doAs(t, h, http.MethodGet, "/v1/wallet", victim, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = doAs(t, h, http.MethodPost, "/v1/wallet/withdraw", victim, "", map[string]any{"amount_claw": "1"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = doWithKey(t, h, http.MethodGet, "/v1/wallet", secret, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&info))
+	assert.Equal(t, "0", info.HeldCLAW, "nothing was held in the victim's escrow account")
+
+	// A consumer signature with a nonce proves the caller too.
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", invoke)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	invoke["consumer_signature"] = receipts.SignRequest(toolID, consumer, map[string]any{}, "", time.Now(), key)
+	rr = doAs(t, h, http.MethodPost, "/v1/invoke", consumer, "", invoke)
+	assert.Equal(t, http.StatusPaymentRequired, rr.Code, "a signed caller gets as far as its own, empty, escrow account")
+}
+
+func TestWallet_NotConfigured(t *testing.T) {
+	h := newTestHandler(t)
+	rr := doRequest(t, h, http.MethodGet, "/v1/wallet", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "NO_WALLET")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/wallet", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "anonymous callers have no wallet")
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// uploadModule handles POST /v1/modules. The body is the raw module; the
+// response carries the wasm:// endpoint to register tools against.
+func (h *Handler) uploadModule(w http.ResponseWriter, r *http.Request) {
+	if h.sandbox == nil {
+		writeError(w, http.StatusNotImplemented, "NOT_IMPLEMENTED", "wasm execution is not enabled on this registry")
+		return
+	}
+	limit := int64(h.sandbox.Limits().MaxModuleBytes)
+	wasm, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "LIMIT_EXCEEDED", fmt.Sprintf("module exceeds %d bytes", limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	if err := h.sandbox.Validate(r.Context(), wasm); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_MODULE", err.Error())
+		return
+	}
+	m, err := h.reg.PutModule(r.Context(), providerIDFromRequest(r), wasm)
+	if err != nil {
+		h.logger(r).Error("store module", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
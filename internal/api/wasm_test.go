@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newSandboxHandler(t *testing.T, limits sandbox.Limits) http.Handler {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	reg := registry.New(db, zaptest.NewLogger(t))
+	ex, err := sandbox.New(context.Background(), limits, reg.ModuleBytes)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ex.Close(context.Background()) })
+	return api.NewHandler(reg, zaptest.NewLogger(t), api.WithSandbox(ex))
+}
+
+func uploadModule(t *testing.T, h http.Handler, name string) *httptest.ResponseRecorder {
+	t.Helper()
+	wasm, err := os.ReadFile("../sandbox/testdata/" + name + ".wasm")
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/v1/modules", bytes.NewReader(wasm))
+	req.Header.Set("Content-Type", "application/wasm")
+	req.Header.Set("Authorization", "Bearer "+testCaller)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// registerWASMTool uploads a test module and registers a tool running it.
+func registerWASMTool(t *testing.T, h http.Handler, module string) string {
+	t.Helper()
+	rr := uploadModule(t, h, module)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var m registry.Module
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&m))
+
+	payload := validToolPayload()
+	payload["name"] = module
+	payload["endpoint"] = m.Endpoint
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tool registry.Tool
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tool))
+	return tool.ID
+}
+
+func TestInvoke_WASM(t *testing.T) {
+	h := newSandboxHandler(t, sandbox.Limits{})
+	id := registerWASMTool(t, h, "echo")
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": id, "input": map[string]any{"text": "hi"}})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp registry.InvokeResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, map[string]any{"text": "hi"}, resp.Output)
+	assert.Equal(t, "5.0", resp.CostCLAW)
+	assert.NotEmpty(t, resp.InvocationID)
+}
+
+func TestInvoke_WASMFailures(t *testing.T) {
+	h := newSandboxHandler(t, sandbox.Limits{Timeout: 100 * time.Millisecond})
+
+	rr := doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": registerWASMTool(t, h, "spin")})
+	assert.Equal(t, http.StatusRequestTimeout, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVOKE_TIMEOUT")
+
+	rr = doRequest(t, h, http.MethodPost, "/v1/invoke", map[string]any{"tool_id": registerWASMTool(t, h, "fail")})
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "TOOL_FAILED")
+}
+
+func TestUploadModule(t *testing.T) {
+	h := newSandboxHandler(t, sandbox.Limits{MemoryPages: 16, MaxModuleBytes: 128})
+
+	rr := uploadModule(t, h, "big")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "INVALID_MODULE")
+
+	rr = uploadModule(t, h, "echo")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	payload := validToolPayload()
+	payload["endpoint"] = registry.WASMScheme + "sha256:0000"
+	rr = doRequest(t, h, http.MethodPost, "/v1/tools", payload)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "MODULE_NOT_FOUND")
+
+	// Without a sandbox, uploads are refused.
+	rr = uploadModule(t, newTestHandler(t), "echo")
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+}
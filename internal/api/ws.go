@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"golang.org/x/net/websocket"
+)
+
+// wsMaxInflight bounds the invocations running at once on one channel.
+const wsMaxInflight = maxBatch
+
+// Frame types of the invocation channel.
+const (
+	wsInvoke = "invoke" // client: start an invocation
+	wsCancel = "cancel" // client: abandon the invocation started as ref
+	wsResult = "result" // server: an invocation completed
+	wsError  = "error"  // server: an invocation, or a frame, failed
+)
+
+// wsRequest is a frame sent by the client: an invoke request, or a cancel,
+// tagged with a ref the client chooses to match responses to requests.
+type wsRequest struct {
+	Type string `json:"type"`
+	Ref  string `json:"ref"`
+	registry.InvokeRequest
+}
+
+// wsResponse is a frame sent by the server: the outcome of the invocation
+// started as Ref, in the shape of a batch result.
+type wsResponse struct {
+	Type string `json:"type"`
+	Ref  string `json:"ref,omitempty"`
+	batchResult
+}
+
+// wsChannel is one connection of GET /v1/invoke/ws.
+type wsChannel struct {
+	h    *Handler
+	r    *http.Request
+	conn *websocket.Conn
+	wmu  sync.Mutex
+	mu   sync.Mutex
+	refs map[string]context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// invokeWS handles GET /v1/invoke/ws, a WebSocket over which the caller
+// multiplexes invocations: each invoke frame runs concurrently, as POST
+// /v1/invoke would, and is answered by a result or error frame with the
+// same ref once it finishes. The upgrade is a GET, but it only serves
+// invocations, so every maintenance mode refuses it.
+func (h *Handler) invokeWS(w http.ResponseWriter, r *http.Request) {
+	if m := h.currentMaintenance(r.Context()); m != nil {
+		writeMaintenance(w, m)
+		return
+	}
+	srv := websocket.Server{
+		// Callers authenticate with a bearer token, never a cookie, so a
+		// page on another origin cannot act for them: any Origin will do.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			if limit := h.reg.Limits().MaxInputBytes; limit > 0 {
+				conn.MaxPayloadBytes = limit + 16<<10
+			}
+			c := &wsChannel{h: h, r: r, conn: conn, refs: map[string]context.CancelFunc{}}
+			c.serve()
+		},
+	}
+	srv.ServeHTTP(w, r)
+}
+
+// serve reads frames until the connection closes, then abandons the
+// invocations still running and waits for them.
+func (c *wsChannel) serve() {
+	ctx, cancel := context.WithCancel(c.r.Context())
+	defer func() {
+		cancel()
+		c.wg.Wait()
+	}()
+	for {
+		var req wsRequest
+		if err := websocket.JSON.Receive(c.conn, &req); err != nil {
+			var syntax *json.SyntaxError
+			var typ *json.UnmarshalTypeError
+			if errors.As(err, &syntax) || errors.As(err, &typ) {
+				c.fail("", &invokeError{code: "INVALID_BODY", msg: "invalid JSON"})
+				continue
+			}
+			return
+		}
+		switch req.Type {
+		case wsInvoke:
+			c.start(ctx, &req)
+		case wsCancel:
+			c.mu.Lock()
+			if stop := c.refs[req.Ref]; stop != nil {
+				stop()
+			}
+			c.mu.Unlock()
+		default:
+			c.fail(req.Ref, &invokeError{code: "INVALID_BODY", msg: "type must be invoke or cancel"})
+		}
+	}
+}
+
+// start runs the invocation of req in the background. Maintenance started
+// after the channel opened refuses it.
+func (c *wsChannel) start(ctx context.Context, req *wsRequest) {
+	if req.Ref == "" {
+		c.fail("", &invokeError{code: "INVALID_BODY", msg: "ref is required"})
+		return
+	}
+	if m := c.h.currentMaintenance(ctx); m != nil {
+		c.fail(req.Ref, &invokeError{code: "MAINTENANCE", msg: m.message()})
+		return
+	}
+	c.mu.Lock()
+	_, dup := c.refs[req.Ref]
+	busy := len(c.refs) >= wsMaxInflight
+	var cancel context.CancelFunc
+	if !dup && !busy {
+		ctx, cancel = context.WithCancel(ctx)
+		c.refs[req.Ref] = cancel
+	}
+	c.mu.Unlock()
+	switch {
+	case dup:
+		c.fail(req.Ref, &invokeError{code: "INVALID_BODY", msg: "ref " + req.Ref + " is already in flight"})
+		return
+	case busy:
+		c.fail(req.Ref, &invokeError{code: "RATE_LIMITED", msg: "too many invocations in flight on this channel"})
+		return
+	case !c.h.inflight.begin():
+		c.forget(req.Ref)
+		c.fail(req.Ref, &invokeError{code: "SHUTTING_DOWN", msg: "server is shutting down"})
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.h.inflight.end()
+		defer c.forget(req.Ref)
+		r := c.r.WithContext(ctx)
+		var resp *registry.InvokeResponse
+		ierr := c.h.route(r, &req.InvokeRequest)
+		if ierr == nil {
+			resp, ierr = c.h.invoke(r, &req.InvokeRequest)
+		}
+		if ierr != nil && ctx.Err() != nil {
+			ierr.code, ierr.msg = "INVOCATION_CANCELED", "invocation canceled"
+		}
+		c.send(&wsResponse{Ref: req.Ref, batchResult: newBatchResult(req.ToolID, resp, ierr)}, ierr)
+	}()
+}
+
+// forget drops ref once its invocation is over.
+func (c *wsChannel) forget(ref string) {
+	c.mu.Lock()
+	if stop := c.refs[ref]; stop != nil {
+		stop()
+		delete(c.refs, ref)
+	}
+	c.mu.Unlock()
+}
+
+// fail answers ref with an error that was not recorded as an invocation.
+func (c *wsChannel) fail(ref string, ierr *invokeError) {
+	c.send(&wsResponse{Ref: ref, batchResult: newBatchResult("", nil, ierr)}, ierr)
+}
+
+// send writes resp, typed as an error frame when ierr is set. Write errors
+// mean the connection is gone, which the read loop notices.
+func (c *wsChannel) send(resp *wsResponse, ierr *invokeError) {
+	resp.Type = wsResult
+	if ierr != nil {
+		resp.Type = wsError
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = websocket.JSON.Send(c.conn, resp)
+}
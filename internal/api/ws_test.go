@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/net/websocket"
+)
+
+type wsFrame struct {
+	Type         string         `json:"type"`
+	Ref          string         `json:"ref"`
+	InvocationID string         `json:"invocation_id"`
+	Output       map[string]any `json:"output"`
+	Error        *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func dialWS(t *testing.T, h http.Handler) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/v1/invoke/ws", srv.URL)
+	require.NoError(t, err)
+	cfg.Header.Set("Authorization", "Bearer "+testCaller)
+	conn, err := websocket.DialConfig(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+	return conn
+}
+
+func TestInvokeWS(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		_, _ = w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	t.Cleanup(provider.Close)
+	t.Cleanup(func() { close(release) })
+	// Invocations run concurrently, so the store must be one database
+	// across connections, which :memory: is not.
+	db, err := store.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := api.NewHandler(registry.New(db, zaptest.NewLogger(t)), zaptest.NewLogger(t))
+	fast := registerRPCTool(t, h, "fast", provider.URL+"/fast")
+	slow := registerRPCTool(t, h, "slow", provider.URL+"/slow")
+	conn := dialWS(t, h)
+
+	send := func(frame map[string]any) { require.NoError(t, websocket.JSON.Send(conn, frame)) }
+	recv := func() wsFrame {
+		var f wsFrame
+		require.NoError(t, websocket.JSON.Receive(conn, &f))
+		return f
+	}
+
+	send(map[string]any{"type": "invoke", "ref": "a", "tool_id": slow, "input": map[string]any{}})
+	send(map[string]any{"type": "invoke", "ref": "b", "tool_id": fast, "input": map[string]any{}})
+	f := recv()
+	assert.Equal(t, "result", f.Type)
+	assert.Equal(t, "b", f.Ref, "the fast invocation overtakes the slow one")
+	assert.Equal(t, map[string]any{"path": "/fast"}, f.Output)
+	assert.NotEmpty(t, f.InvocationID)
+
+	send(map[string]any{"type": "invoke", "ref": "a", "tool_id": fast})
+	f = recv()
+	assert.Equal(t, "error", f.Type)
+	assert.Equal(t, "INVALID_BODY", f.Error.Code, "ref a is still in flight")
+
+	<-started
+	send(map[string]any{"type": "cancel", "ref": "a"})
+	f = recv()
+	assert.Equal(t, "a", f.Ref)
+	assert.Equal(t, "error", f.Type)
+	assert.Equal(t, "INVOCATION_CANCELED", f.Error.Code)
+	assert.NotEmpty(t, f.InvocationID, "the canceled invocation was recorded")
+
+	send(map[string]any{"type": "invoke", "ref": "c", "tool_id": "did:claw:tool:missing"})
+	f = recv()
+	assert.Equal(t, "TOOL_NOT_FOUND", f.Error.Code)
+
+	require.NoError(t, websocket.Message.Send(conn, "{not json"))
+	f = recv()
+	assert.Equal(t, "INVALID_BODY", f.Error.Code)
+}
+
+func TestInvokeWS_Maintenance(t *testing.T) {
+	h := newProxiedHandler(t)
+	conn := dialWS(t, h)
+	require.NoError(t, h.SetMaintenance(context.Background(), api.Maintenance{Mode: api.MaintenanceReadOnly}))
+
+	require.NoError(t, websocket.JSON.Send(conn, map[string]any{"type": "invoke", "ref": "a", "tool_id": "did:claw:tool:x"}))
+	var f wsFrame
+	require.NoError(t, websocket.JSON.Receive(conn, &f))
+	assert.Equal(t, "error", f.Type)
+	assert.Equal(t, "a", f.Ref)
+	assert.Equal(t, "MAINTENANCE", f.Error.Code, "open channels stop invoking")
+
+	rr := doRequest(t, h, http.MethodGet, "/v1/invoke/ws", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "read-only maintenance refuses new channels")
+	assert.Contains(t, rr.Body.String(), "MAINTENANCE")
+}
@@ -0,0 +1,55 @@
+// Package cas stores documents in content-addressed storage, where each one
+// is named by a CID of its bytes: in IPFS through the Kubo RPC API, or in a
+// local directory. Consumers holding a CID can fetch and verify a document
+// without trusting, or even reaching, the registry.
+package cas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned for a CID the store does not hold.
+	ErrNotFound = errors.New("content not found")
+	// ErrInvalidCID is returned for a malformed CID.
+	ErrInvalidCID = errors.New("invalid cid")
+)
+
+// Store pins documents and returns them by CID.
+type Store interface {
+	// Put stores data and returns its CID.
+	Put(ctx context.Context, data []byte) (string, error)
+	// Get returns the document named by cid.
+	Get(ctx context.Context, cid string) ([]byte, error)
+}
+
+// rawPrefix starts a CIDv1 of raw bytes hashed with SHA-256: version 1,
+// the raw multicodec, the sha2-256 multihash and its 32-byte length.
+var rawPrefix = []byte{0x01, 0x55, 0x12, 0x20}
+
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// CID returns the CIDv1 of data as a single raw block, in base32 ("bafkrei…").
+// IPFS assigns the same CID to documents up to its 256 KiB chunk size when
+// they are added with raw leaves, which is how IPFS stores them.
+func CID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "b" + base32Lower.EncodeToString(append(append([]byte{}, rawPrefix...), sum[:]...))
+}
+
+// digest returns the SHA-256 digest a raw CID names.
+func digest(cid string) ([]byte, error) {
+	enc, ok := strings.CutPrefix(cid, "b")
+	if !ok {
+		return nil, ErrInvalidCID
+	}
+	raw, err := base32Lower.DecodeString(enc)
+	if err != nil || len(raw) != len(rawPrefix)+sha256.Size || string(raw[:len(rawPrefix)]) != string(rawPrefix) {
+		return nil, ErrInvalidCID
+	}
+	return raw[len(rawPrefix):], nil
+}
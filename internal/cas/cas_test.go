@@ -0,0 +1,114 @@
+package cas_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCID(t *testing.T) {
+	// The well-known CID of the empty raw block.
+	assert.Equal(t, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", cas.CID(nil))
+	assert.NotEqual(t, cas.CID([]byte("a")), cas.CID([]byte("b")))
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pins")
+	d, err := cas.NewDir(path)
+	require.NoError(t, err)
+
+	doc := []byte(`{"name":"echo"}`)
+	cid, err := d.Put(ctx, doc)
+	require.NoError(t, err)
+	assert.Equal(t, cas.CID(doc), cid)
+	again, err := d.Put(ctx, doc)
+	require.NoError(t, err)
+	assert.Equal(t, cid, again)
+
+	got, err := d.Get(ctx, cid)
+	require.NoError(t, err)
+	assert.Equal(t, doc, got)
+
+	_, err = d.Get(ctx, cas.CID([]byte("other")))
+	assert.ErrorIs(t, err, cas.ErrNotFound)
+	_, err = d.Get(ctx, "../../etc/passwd")
+	assert.ErrorIs(t, err, cas.ErrInvalidCID)
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, cid), []byte("tampered"), 0o644))
+	_, err = d.Get(ctx, cid)
+	assert.ErrorContains(t, err, "corrupt")
+}
+
+// fakeKubo serves the add and cat commands of the Kubo RPC API from memory.
+func fakeKubo(t *testing.T) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	blocks := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v0/add":
+			assert.Equal(t, "1", r.URL.Query().Get("cid-version"))
+			assert.Equal(t, "true", r.URL.Query().Get("raw-leaves"))
+			assert.Equal(t, "true", r.URL.Query().Get("pin"))
+			f, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			cid := cas.CID(data)
+			blocks[cid] = data
+			_ = json.NewEncoder(w).Encode(map[string]string{"Name": "document", "Hash": cid})
+		case "/api/v0/cat":
+			data, ok := blocks[r.URL.Query().Get("arg")]
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"Message": "block was not found locally (offline): ipld: could not find", "Code": 0, "Type": "error",
+				})
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIPFS(t *testing.T) {
+	ctx := context.Background()
+	srv := fakeKubo(t)
+	s := cas.NewIPFS(srv.URL+"/", srv.Client())
+
+	doc := []byte(`{"name":"echo","version":"1.0.0"}`)
+	cid, err := s.Put(ctx, doc)
+	require.NoError(t, err)
+	assert.Equal(t, cas.CID(doc), cid)
+
+	got, err := s.Get(ctx, cid)
+	require.NoError(t, err)
+	assert.Equal(t, doc, got)
+
+	_, err = s.Get(ctx, cas.CID([]byte("missing")))
+	assert.ErrorIs(t, err, cas.ErrNotFound)
+
+	_, err = cas.NewIPFS("http://127.0.0.1:1", http.DefaultClient).Put(ctx, doc)
+	assert.Error(t, err)
+}
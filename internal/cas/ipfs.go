@@ -0,0 +1,100 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxDocument bounds a document read back from IPFS.
+const maxDocument = 16 << 20
+
+// IPFS pins documents on an IPFS node through its Kubo RPC API.
+type IPFS struct {
+	api string
+	hc  *http.Client
+}
+
+// NewIPFS returns a store on the node whose RPC API listens at api, e.g.
+// http://127.0.0.1:5001.
+func NewIPFS(api string, hc *http.Client) *IPFS {
+	return &IPFS{api: strings.TrimSuffix(api, "/"), hc: hc}
+}
+
+// Put adds and pins data as a CIDv1 with raw leaves, so small documents get
+// the same CID as CID computes.
+func (s *IPFS) Put(ctx context.Context, data []byte) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "document")
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	q := url.Values{"cid-version": {"1"}, "raw-leaves": {"true"}, "pin": {"true"}}
+	raw, err := s.call(ctx, "add", q, mw.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := json.Unmarshal(raw, &added); err != nil || added.Hash == "" {
+		return "", fmt.Errorf("ipfs add: unexpected response %q", raw)
+	}
+	return added.Hash, nil
+}
+
+// Get fetches the document named by cid from the node, which retrieves it
+// from the network if it does not hold it.
+func (s *IPFS) Get(ctx context.Context, cid string) ([]byte, error) {
+	return s.call(ctx, "cat", url.Values{"arg": {cid}}, "", http.NoBody)
+}
+
+// call posts to an RPC command; the Kubo API only accepts POST.
+func (s *IPFS) call(ctx context.Context, cmd string, q url.Values, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.api+"/api/v0/"+cmd+"?"+q.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ipfs %s: %w", cmd, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxDocument+1))
+	if err != nil {
+		return nil, fmt.Errorf("ipfs %s: %w", cmd, err)
+	}
+	if len(raw) > maxDocument {
+		return nil, fmt.Errorf("ipfs %s: response exceeds %d bytes", cmd, maxDocument)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors come back as {"Message": "...", "Code": 0, "Type": "error"}.
+		var e struct {
+			Message string `json:"Message"`
+		}
+		if json.Unmarshal(raw, &e) == nil && e.Message != "" {
+			if strings.Contains(e.Message, "not found") {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, e.Message)
+			}
+			return nil, fmt.Errorf("ipfs %s: %s", cmd, e.Message)
+		}
+		return nil, fmt.Errorf("ipfs %s: %s", cmd, resp.Status)
+	}
+	return raw, nil
+}
@@ -0,0 +1,200 @@
+// Package clawchain talks to ClawChain, a Substrate chain, over the node's
+// JSON-RPC websocket API: it anchors receipt batches by submitting the
+// Merkle root of each batch in an unsigned call of the chain's receipt
+// anchor pallet, and its Wallet reads and transfers the CLAW that pays for
+// invocations.
+package clawchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// ErrRPC is returned when the node rejects a request.
+var ErrRPC = errors.New("clawchain rpc")
+
+// extrinsicV4Unsigned is the version byte of an unsigned extrinsic.
+const extrinsicV4Unsigned = 0x04
+
+// DefaultTimeout bounds a request unless Client.Timeout says otherwise.
+const DefaultTimeout = 30 * time.Second
+
+// Client makes requests of a ClawChain node.
+type Client struct {
+	url string
+	// call is the pallet and call index of the anchoring call, which takes
+	// the 32-byte root as its only argument, or nil when the client does
+	// not anchor.
+	call    []byte
+	Timeout time.Duration
+}
+
+// New returns a client of the node at wsURL, ws:// or wss://, that anchors
+// roots with the call whose pallet and call index call gives as four hex
+// digits, "0x2a00" for call 0 of pallet 42, as the runtime metadata lists
+// them. A client with an empty call only backs a Wallet.
+func New(wsURL, call string) (*Client, error) {
+	if !strings.HasPrefix(wsURL, "ws://") && !strings.HasPrefix(wsURL, "wss://") {
+		return nil, fmt.Errorf("clawchain url %q must be ws:// or wss://", wsURL)
+	}
+	c := &Client{url: wsURL, Timeout: DefaultTimeout}
+	if call != "" {
+		index, err := parseCall(call, "anchor")
+		if err != nil {
+			return nil, err
+		}
+		c.call = index[:]
+	}
+	return c, nil
+}
+
+// parseCall decodes the pallet and call index of a call, what it is for
+// naming it in errors.
+func parseCall(call, what string) ([2]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(call, "0x"))
+	if err != nil || len(b) != 2 {
+		return [2]byte{}, fmt.Errorf("clawchain %s call %q must be 0x and four hex digits: pallet and call index", what, call)
+	}
+	return [2]byte{b[0], b[1]}, nil
+}
+
+// Extrinsic returns the SCALE encoding of the unsigned extrinsic anchoring
+// root, "sha256:<hex>": its compact length, the version byte, the call
+// index and the 32 root bytes.
+func (c *Client) Extrinsic(root string) ([]byte, error) {
+	digest, err := hex.DecodeString(strings.TrimPrefix(root, "sha256:"))
+	if err != nil || len(digest) != 32 {
+		return nil, fmt.Errorf("root %q is not sha256:<64 hex>", root)
+	}
+	if c.call == nil {
+		return nil, errors.New("no clawchain anchor call configured")
+	}
+	body := append([]byte{extrinsicV4Unsigned, c.call[0], c.call[1]}, digest...)
+	// Lengths below 64 take the single-byte compact mode.
+	return append([]byte{byte(len(body) << 2)}, body...), nil
+}
+
+// Anchor submits root and returns the hash of the extrinsic recording it.
+func (c *Client) Anchor(ctx context.Context, root string) (string, error) {
+	xt, err := c.Extrinsic(root)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	s, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.close()
+	var hash string
+	if err := s.call("author_submitExtrinsic", []string{"0x" + hex.EncodeToString(xt)}, &hash); err != nil {
+		return "", err
+	}
+	if hash == "" {
+		return "", fmt.Errorf("%w: no extrinsic hash in the response", ErrRPC)
+	}
+	return hash, nil
+}
+
+// session is a connection to the node, over which requests are made one
+// after the other.
+type session struct {
+	conn *websocket.Conn
+	id   int
+}
+
+// dial connects to the node until the deadline of ctx.
+func (c *Client) dial(ctx context.Context) (*session, error) {
+	cfg, err := websocket.NewConfig(c.url, "http://localhost/")
+	if err != nil {
+		return nil, fmt.Errorf("clawchain url: %w", err)
+	}
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dial clawchain: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return &session{conn: conn}, nil
+}
+
+func (s *session) close() { _ = s.conn.Close() }
+
+// call makes the JSON-RPC request method with params and decodes its
+// result into result.
+func (s *session) call(method string, params, result any) error {
+	s.id++
+	req := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      s.id,
+		"method":  method,
+		"params":  params,
+	}
+	if err := websocket.JSON.Send(s.conn, req); err != nil {
+		return fmt.Errorf("send %s to clawchain: %w", method, err)
+	}
+	var resp struct {
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data,omitempty"`
+		} `json:"error"`
+	}
+	for {
+		if err := websocket.JSON.Receive(s.conn, &resp); err != nil {
+			return fmt.Errorf("read clawchain response: %w", err)
+		}
+		// Notifications of subscriptions, which name a method, are
+		// skipped.
+		if resp.Method == "" {
+			break
+		}
+		resp.Method = ""
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%w: %s: %d %s %s", ErrRPC, method, resp.Error.Code, resp.Error.Message, resp.Error.Data)
+	}
+	if len(resp.Result) == 0 {
+		return fmt.Errorf("%w: %s: no result in the response", ErrRPC, method)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrRPC, method, err)
+	}
+	return nil
+}
+
+// notification reads the next notification of subscription sub, the result
+// of the call that made it, skipping any other message, and decodes its
+// result into result.
+func (s *session) notification(sub json.RawMessage, result any) error {
+	for {
+		var msg struct {
+			Params struct {
+				Subscription json.RawMessage `json:"subscription"`
+				Result       json.RawMessage `json:"result"`
+			} `json:"params"`
+		}
+		if err := websocket.JSON.Receive(s.conn, &msg); err != nil {
+			return fmt.Errorf("read clawchain notification: %w", err)
+		}
+		if !bytes.Equal(msg.Params.Subscription, sub) {
+			continue
+		}
+		if err := json.Unmarshal(msg.Params.Result, result); err != nil {
+			return fmt.Errorf("%w: notification of %s: %v", ErrRPC, sub, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+package clawchain_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/clawchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+const root = "sha256:ab000000000000000000000000000000000000000000000000000000000000cd"
+
+// node answers author_submitExtrinsic like a Substrate node, rejecting
+// extrinsics when reject is set.
+func node(t *testing.T, got *string, reject bool) string {
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+		}
+		require.NoError(t, websocket.JSON.Receive(conn, &req))
+		assert.Equal(t, "author_submitExtrinsic", req.Method)
+		*got = req.Params[0]
+		if reject {
+			_ = websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "id": 1,
+				"error": map[string]any{"code": 1010, "message": "Invalid Transaction"}})
+			return
+		}
+		_ = websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "id": 1, "result": "0xfeed"})
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestAnchor(t *testing.T) {
+	var got string
+	c, err := clawchain.New(node(t, &got, false), "0x2a00")
+	require.NoError(t, err)
+	tx, err := c.Anchor(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, "0xfeed", tx)
+	// Compact length 35, unsigned v4, pallet 42 call 0, then the root.
+	assert.Equal(t, "0x8c042a00"+strings.TrimPrefix(root, "sha256:"), got)
+
+	c, err = clawchain.New(node(t, &got, true), "0x2a00")
+	require.NoError(t, err)
+	_, err = c.Anchor(context.Background(), root)
+	assert.ErrorIs(t, err, clawchain.ErrRPC)
+
+	_, err = c.Extrinsic("sha256:" + hex.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := clawchain.New("http://node:9944", "0x2a00")
+	assert.ErrorContains(t, err, "ws://")
+	_, err = clawchain.New("ws://node:9944", "0x2a")
+	assert.ErrorContains(t, err, "four hex digits")
+}
@@ -0,0 +1,401 @@
+package clawchain
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// DefaultDecimals is how many decimal places of planck, the chain's
+	// smallest unit, make up a CLAW unless Wallet.Decimals says otherwise.
+	DefaultDecimals = 12
+	// DefaultSS58Prefix is the network prefix of addresses unless
+	// Wallet.SS58Prefix says otherwise: that of generic Substrate chains.
+	DefaultSS58Prefix = 42
+	// DefaultExistentialDeposit and DefaultTransferFee are the CLAW an
+	// account must keep and a transfer costs unless Wallet says otherwise:
+	// well above those of Substrate's node template, as overestimating
+	// them only keeps more CLAW back.
+	DefaultExistentialDeposit = "0.001"
+	DefaultTransferFee        = "0.01"
+)
+
+// ErrTransferFailed is returned when the chain did not carry out a
+// transfer: the pool found it invalid, another transaction took its place
+// or its block records that it failed. No CLAW was sent.
+var ErrTransferFailed = errors.New("transfer failed")
+
+const (
+	// extrinsicV4Signed is the version byte of a signed extrinsic.
+	extrinsicV4Signed = 0x84
+	// multiAddressID and multiSignatureEd25519 are the variants of an
+	// account given by its ID and of an Ed25519 signature.
+	multiAddressID        = 0x00
+	multiSignatureEd25519 = 0x00
+	// immortalEra is the era of a transaction valid at any block.
+	immortalEra = 0x00
+	// maxSignedPayload is the longest payload signed as is; longer ones
+	// are signed by their BLAKE2b-256 hash.
+	maxSignedPayload = 256
+)
+
+// systemAccountPrefix is the storage prefix of the System pallet's Account
+// map, whose values hold the balances of accounts: the twox128 hashes of
+// "System" and "Account".
+const systemAccountPrefix = "26aa394eea5630e07c48ae0c9558cef7b99d880ec681799c0cf30e8886371da9"
+
+// systemEventsKey is the storage key of the System pallet's Events, those
+// of the block they are read at: the twox128 hashes of "System" and
+// "Events".
+const systemEventsKey = "0x26aa394eea5630e07c48ae0c9558cef780d41e5e16056765bc8461851072c9d7"
+
+const (
+	// applyExtrinsic is the phase of the events of an extrinsic, followed
+	// by its index in the block as a little-endian u32.
+	applyExtrinsic = 0x00
+	// systemPallet is the index of the System pallet, first in FRAME
+	// runtimes, and extrinsicSuccess and extrinsicFailed those of its
+	// events recording the outcome of an extrinsic.
+	systemPallet     = 0x00
+	extrinsicSuccess = 0x00
+	extrinsicFailed  = 0x01
+)
+
+// base58Alphabet is the alphabet of SS58 addresses.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Wallet reads the CLAW balances of ClawChain accounts and transfers CLAW
+// between them. Accounts are Ed25519 keys, whose public key is the account
+// ID; transfers are signed by the key of the account they are made from.
+// Amounts are decimal strings of CLAW, like the registry's prices.
+type Wallet struct {
+	c *Client
+	// transfer is the pallet and call index of the balances pallet's
+	// transfer_keep_alive call.
+	transfer   [2]byte
+	Decimals   int
+	SS58Prefix byte
+	// ExistentialDeposit is the least CLAW an account may hold and
+	// TransferFee the most a transfer costs its sender, decimal strings.
+	ExistentialDeposit string
+	TransferFee        string
+}
+
+// NewWallet returns a wallet that makes its requests with c and transfers
+// with the call whose pallet and call index transferCall gives, as
+// "0x0603" for the transfer_keep_alive call of the balances pallet when it
+// is pallet 6 of the runtime.
+func NewWallet(c *Client, transferCall string) (*Wallet, error) {
+	index, err := parseCall(transferCall, "transfer")
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{
+		c:                  c,
+		transfer:           index,
+		Decimals:           DefaultDecimals,
+		SS58Prefix:         DefaultSS58Prefix,
+		ExistentialDeposit: DefaultExistentialDeposit,
+		TransferFee:        DefaultTransferFee,
+	}, nil
+}
+
+// Reserve returns the CLAW an account that makes transfers must keep: the
+// existential deposit, and the fee of each transfer on top of its amount.
+func (w *Wallet) Reserve() (deposit, fee string) {
+	return w.ExistentialDeposit, w.TransferFee
+}
+
+// Address returns the SS58 address of account, the form wallets take it
+// in.
+func (w *Wallet) Address(account ed25519.PublicKey) string {
+	b := append([]byte{w.SS58Prefix}, account...)
+	sum := blake2b.Sum512(append([]byte("SS58PRE"), b...))
+	return base58(append(b, sum[:2]...))
+}
+
+// Balance returns the free balance of account: the CLAW it holds that is
+// neither reserved nor locked. Accounts the chain does not know hold none.
+func (w *Wallet) Balance(ctx context.Context, account ed25519.PublicKey) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.c.Timeout)
+	defer cancel()
+	s, err := w.c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.close()
+
+	var info *string
+	if err := s.call("state_getStorage", []string{"0x" + accountKey(account)}, &info); err != nil {
+		return "", err
+	}
+	if info == nil {
+		return "0", nil
+	}
+	// AccountInfo is the nonce and three reference counts, each a u32,
+	// followed by the free balance, a little-endian u128.
+	b, err := hex.DecodeString(strings.TrimPrefix(*info, "0x"))
+	if err != nil || len(b) < 32 {
+		return "", fmt.Errorf("%w: account info %q is not an AccountInfo", ErrRPC, *info)
+	}
+	free := make([]byte, 16)
+	for i := range free {
+		free[i] = b[31-i]
+	}
+	return w.claw(new(big.Int).SetBytes(free)), nil
+}
+
+// Transfer sends amount CLAW from the account of from to to, leaving from
+// with at least the chain's existential deposit, and waits for a block to
+// include the transaction. It returns the hash of the transaction once the
+// block's events record that it succeeded. The transaction fee is paid by
+// from on top of amount.
+//
+// When it returns an error, the hash is empty if no CLAW was sent: the
+// node refused the transaction or, with ErrTransferFailed, the chain did
+// not carry it out. It is set if the transaction was submitted but its
+// outcome is unknown, because the wait timed out, the node dropped it from
+// its pool or its block does not tell; it may still be carried out.
+func (w *Wallet) Transfer(ctx context.Context, from ed25519.PrivateKey, to ed25519.PublicKey, amount string) (string, error) {
+	value, err := w.planck(amount)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.c.Timeout)
+	defer cancel()
+	s, err := w.c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.close()
+
+	var version struct {
+		SpecVersion        uint32 `json:"specVersion"`
+		TransactionVersion uint32 `json:"transactionVersion"`
+	}
+	if err := s.call("state_getRuntimeVersion", []any{}, &version); err != nil {
+		return "", err
+	}
+	var genesis string
+	if err := s.call("chain_getBlockHash", []int{0}, &genesis); err != nil {
+		return "", err
+	}
+	genesisHash, err := hex.DecodeString(strings.TrimPrefix(genesis, "0x"))
+	if err != nil || len(genesisHash) != 32 {
+		return "", fmt.Errorf("%w: genesis hash %q is not 32 bytes of hex", ErrRPC, genesis)
+	}
+	// The next index counts the transactions of from waiting in the pool,
+	// so transfers in quick succession do not reuse a nonce.
+	var nonce uint64
+	if err := s.call("system_accountNextIndex", []string{w.Address(from.Public().(ed25519.PublicKey))}, &nonce); err != nil {
+		return "", err
+	}
+
+	call := append([]byte{w.transfer[0], w.transfer[1], multiAddressID}, to...)
+	call = append(call, compact(value)...)
+	// The signed extensions of a FRAME runtime: the era, the nonce and the
+	// tip, then the spec and transaction versions, the genesis hash and the
+	// hash of the block the era starts at, the genesis for immortal ones.
+	extra := append(append([]byte{immortalEra}, compact(new(big.Int).SetUint64(nonce))...), compact(new(big.Int))...)
+	additional := binary.LittleEndian.AppendUint32(nil, version.SpecVersion)
+	additional = binary.LittleEndian.AppendUint32(additional, version.TransactionVersion)
+	additional = append(append(additional, genesisHash...), genesisHash...)
+	payload := append(append(append([]byte{}, call...), extra...), additional...)
+	if len(payload) > maxSignedPayload {
+		sum := blake2b.Sum256(payload)
+		payload = sum[:]
+	}
+
+	body := append([]byte{extrinsicV4Signed, multiAddressID}, from.Public().(ed25519.PublicKey)...)
+	body = append(append(body, multiSignatureEd25519), ed25519.Sign(from, payload)...)
+	body = append(append(body, extra...), call...)
+	xt := append(compact(big.NewInt(int64(len(body)))), body...)
+
+	signed := "0x" + hex.EncodeToString(xt)
+	sum := blake2b.Sum256(xt)
+	hash := "0x" + hex.EncodeToString(sum[:])
+	var sub json.RawMessage
+	if err := s.call("author_submitAndWatchExtrinsic", []string{signed}, &sub); err != nil {
+		// A node that answered with an error refused the transaction; one
+		// that did not answer may have taken it.
+		if errors.Is(err, ErrRPC) {
+			return "", err
+		}
+		return hash, err
+	}
+	for {
+		var status json.RawMessage
+		if err := s.notification(sub, &status); err != nil {
+			return hash, fmt.Errorf("watch transfer %s: %w", hash, err)
+		}
+		// The pool's statuses: future, ready, dropped and invalid as
+		// strings, the others as objects naming a block or transaction.
+		var state string
+		if json.Unmarshal(status, &state) == nil {
+			switch state {
+			case "invalid":
+				return "", fmt.Errorf("%w: the node found transfer %s invalid", ErrTransferFailed, hash)
+			case "dropped":
+				return hash, fmt.Errorf("%w: the node dropped transfer %s from its pool", ErrRPC, hash)
+			}
+			continue
+		}
+		var update struct {
+			InBlock         string `json:"inBlock"`
+			Finalized       string `json:"finalized"`
+			Usurped         string `json:"usurped"`
+			FinalityTimeout string `json:"finalityTimeout"`
+		}
+		if err := json.Unmarshal(status, &update); err != nil {
+			return hash, fmt.Errorf("%w: status %s of transfer %s: %v", ErrRPC, status, hash, err)
+		}
+		switch {
+		case update.Usurped != "":
+			return "", fmt.Errorf("%w: transaction %s took the place of transfer %s", ErrTransferFailed, update.Usurped, hash)
+		case update.FinalityTimeout != "":
+			return hash, fmt.Errorf("%w: block %s including transfer %s was not finalized", ErrRPC, update.FinalityTimeout, hash)
+		case update.InBlock != "" || update.Finalized != "":
+			err := outcome(s, cmp.Or(update.InBlock, update.Finalized), signed)
+			if errors.Is(err, ErrTransferFailed) {
+				return "", fmt.Errorf("transfer %s: %w", hash, err)
+			}
+			if err != nil {
+				return hash, fmt.Errorf("transfer %s: %w", hash, err)
+			}
+			return hash, nil
+		}
+		// Broadcast to peers, or retracted from a block no longer best:
+		// still waiting for one.
+	}
+}
+
+// outcome reports whether the extrinsic signed, hex-encoded, succeeded in
+// block: nil when the block's events record its success, an error wrapping
+// ErrTransferFailed when they record its failure, and another error when
+// the block does not include it or its events do not tell.
+func outcome(s *session, block, signed string) error {
+	var b struct {
+		Block struct {
+			Extrinsics []string `json:"extrinsics"`
+		} `json:"block"`
+	}
+	if err := s.call("chain_getBlock", []string{block}, &b); err != nil {
+		return err
+	}
+	index := slices.IndexFunc(b.Block.Extrinsics, func(xt string) bool { return strings.EqualFold(xt, signed) })
+	if index < 0 {
+		return fmt.Errorf("%w: block %s does not include it", ErrRPC, block)
+	}
+	var events *string
+	if err := s.call("state_getStorage", []string{systemEventsKey, block}, &events); err != nil {
+		return err
+	}
+	if events == nil {
+		return fmt.Errorf("%w: block %s has no events", ErrRPC, block)
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(*events, "0x"))
+	if err != nil {
+		return fmt.Errorf("%w: events of block %s are not hex", ErrRPC, block)
+	}
+	// Each event record starts with its phase, then the pallet and event
+	// index of the event. The records are not decoded, which takes the
+	// runtime's metadata: the bytes of the other events could only make
+	// both outcomes appear, which is taken as not telling.
+	phase := binary.LittleEndian.AppendUint32([]byte{applyExtrinsic}, uint32(index))
+	succeeded := bytes.Contains(raw, append(slices.Clip(phase), systemPallet, extrinsicSuccess))
+	failed := bytes.Contains(raw, append(slices.Clip(phase), systemPallet, extrinsicFailed))
+	switch {
+	case succeeded && !failed:
+		return nil
+	case failed && !succeeded:
+		return fmt.Errorf("%w: block %s records ExtrinsicFailed", ErrTransferFailed, block)
+	}
+	return fmt.Errorf("%w: the events of block %s do not tell whether it succeeded", ErrRPC, block)
+}
+
+// accountKey returns the hex storage key of the AccountInfo of account: the
+// map's prefix, then the account ID's BLAKE2b-128 hash and the ID itself.
+func accountKey(account ed25519.PublicKey) string {
+	h, _ := blake2b.New(16, nil)
+	h.Write(account)
+	return systemAccountPrefix + hex.EncodeToString(h.Sum(nil)) + hex.EncodeToString(account)
+}
+
+// planck returns the planck of a positive decimal amount of CLAW.
+func (w *Wallet) planck(amount string) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(amount, ".")
+	if len(frac) > w.Decimals {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", amount, w.Decimals)
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", w.Decimals-len(frac)), 10)
+	if !ok || v.Sign() <= 0 || strings.ContainsAny(amount, "+-") {
+		return nil, fmt.Errorf("amount %q is not a positive decimal", amount)
+	}
+	return v, nil
+}
+
+// claw returns planck as a decimal amount of CLAW, without trailing zeros.
+func (w *Wallet) claw(planck *big.Int) string {
+	s := planck.String()
+	if len(s) <= w.Decimals {
+		s = strings.Repeat("0", w.Decimals-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-w.Decimals], strings.TrimRight(s[len(s)-w.Decimals:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// compact returns the SCALE compact encoding of a non-negative v.
+func compact(v *big.Int) []byte {
+	switch {
+	case v.BitLen() <= 6:
+		return []byte{byte(v.Uint64() << 2)}
+	case v.BitLen() <= 14:
+		return binary.LittleEndian.AppendUint16(nil, uint16(v.Uint64()<<2|0b01))
+	case v.BitLen() <= 30:
+		return binary.LittleEndian.AppendUint32(nil, uint32(v.Uint64()<<2|0b10))
+	}
+	// The big-integer mode: the byte count less four, then the bytes,
+	// little-endian.
+	b := v.Bytes()
+	le := make([]byte, len(b))
+	for i := range b {
+		le[i] = b[len(b)-1-i]
+	}
+	return append([]byte{byte(len(le)-4)<<2 | 0b11}, le...)
+}
+
+// base58 encodes b in the Bitcoin alphabet, as SS58 addresses are.
+func base58(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	var out []byte
+	radix, mod := big.NewInt(58), new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,297 @@
+package clawchain_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/clawchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/net/websocket"
+)
+
+// alice is the public key of Substrate's well-known development account.
+var alice = ed25519.PublicKey(mustHex("d43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"))
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// watch is the result of a subscription: the statuses notified after the
+// node answers with its ID.
+type watch []any
+
+// walletNode answers requests like a Substrate node, from results by
+// method, and records the params of each. A result that is a func is
+// called for the result to answer with.
+func walletNode(t *testing.T, results map[string]any, params map[string][]any) *clawchain.Wallet {
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for {
+			var req struct {
+				ID     int    `json:"id"`
+				Method string `json:"method"`
+				Params []any  `json:"params"`
+			}
+			if err := websocket.JSON.Receive(conn, &req); err != nil {
+				return
+			}
+			params[req.Method] = req.Params
+			result := results[req.Method]
+			if f, ok := result.(func() any); ok {
+				result = f()
+			}
+			updates, ok := result.(watch)
+			if ok {
+				result = "sub-1"
+			}
+			_ = websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+			for _, u := range updates {
+				_ = websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "method": "author_extrinsicUpdate",
+					"params": map[string]any{"subscription": "sub-1", "result": u}})
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, err := clawchain.New("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	require.NoError(t, err)
+	// Short, for the transfers no block includes.
+	c.Timeout = time.Second
+	w, err := clawchain.NewWallet(c, "0x0603")
+	require.NoError(t, err)
+	return w
+}
+
+func TestWallet_Balance(t *testing.T) {
+	// Nonce 5, three reference counts, then a free balance of 1.5 CLAW.
+	info := "0x05000000" + strings.Repeat("00", 12) + "0098f73e5d0100000000000000000000" + strings.Repeat("00", 48)
+	params := map[string][]any{}
+	w := walletNode(t, map[string]any{"state_getStorage": info}, params)
+	assert.Equal(t, "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", w.Address(alice))
+
+	balance, err := w.Balance(context.Background(), alice)
+	require.NoError(t, err)
+	assert.Equal(t, "1.5", balance)
+	assert.Equal(t, []any{"0x26aa394eea5630e07c48ae0c9558cef7b99d880ec681799c0cf30e8886371da9" +
+		"de1e86a9a8c739864cf3cc5ec2bea59fd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d"},
+		params["state_getStorage"], "System.Account of the account")
+
+	w = walletNode(t, map[string]any{"state_getStorage": nil}, params)
+	balance, err = w.Balance(context.Background(), alice)
+	require.NoError(t, err)
+	assert.Equal(t, "0", balance, "an account the chain does not know")
+}
+
+// systemEvents returns the hex System.Events of a block whose extrinsics
+// raised the System pallet's events, by index.
+func systemEvents(events ...byte) string {
+	s := "0x" + hex.EncodeToString([]byte{byte(len(events) << 2)})
+	for i, e := range events {
+		// ApplyExtrinsic(i), the System pallet's event e with some data,
+		// no topics.
+		s += "00" + hex.EncodeToString(binary.LittleEndian.AppendUint32(nil, uint32(i))) + "00" +
+			hex.EncodeToString([]byte{e}) + "0a0b0c" + "00"
+	}
+	return s
+}
+
+// transferNode answers the requests of a transfer, including it in block
+// 0xb10c after the statuses of updates, with events raising the System
+// pallet's event outcome for it.
+func transferNode(t *testing.T, params map[string][]any, updates watch, outcome byte) *clawchain.Wallet {
+	return walletNode(t, map[string]any{
+		"state_getRuntimeVersion":        map[string]any{"specVersion": 100, "transactionVersion": 2},
+		"chain_getBlockHash":             "0x" + strings.Repeat("11", 32),
+		"system_accountNextIndex":        7,
+		"author_submitAndWatchExtrinsic": updates,
+		"chain_getBlock": func() any {
+			return map[string]any{"block": map[string]any{
+				"extrinsics": []any{"0x280402000b50d5a0a29301", params["author_submitAndWatchExtrinsic"][0]},
+			}}
+		},
+		"state_getStorage": systemEvents(0x00, outcome),
+	}, params)
+}
+
+func TestWallet_Transfer(t *testing.T) {
+	genesis := strings.Repeat("11", 32)
+	params := map[string][]any{}
+	w := transferNode(t, params, watch{"ready", map[string]any{"broadcast": []string{"peer"}},
+		map[string]any{"inBlock": "0xb10c"}}, 0x00)
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tx, err := w.Transfer(context.Background(), key, alice, "2.5")
+	require.NoError(t, err)
+	assert.Equal(t, []any{w.Address(pub)}, params["system_accountNextIndex"])
+	assert.Equal(t, []any{"0xb10c"}, params["chain_getBlock"])
+	assert.Equal(t, []any{"0x26aa394eea5630e07c48ae0c9558cef780d41e5e16056765bc8461851072c9d7", "0xb10c"},
+		params["state_getStorage"], "System.Events of the block")
+
+	xt := mustHex(strings.TrimPrefix(params["author_submitAndWatchExtrinsic"][0].(string), "0x"))
+	sum := blake2b.Sum256(xt)
+	assert.Equal(t, "0x"+hex.EncodeToString(sum[:]), tx, "the extrinsic's hash")
+	// Compact length 2 bytes, then signed v4, the sender's ID and its
+	// Ed25519 signature.
+	length := binary.LittleEndian.Uint16(xt) >> 2
+	require.Len(t, xt, int(length)+2)
+	body := xt[2:]
+	assert.Equal(t, []byte{0x84, 0x00}, body[:2])
+	assert.Equal(t, []byte(pub), body[2:34])
+	assert.Equal(t, byte(0x00), body[34])
+	sig := body[35:99]
+	// Immortal, nonce 7, no tip; then transfer_keep_alive to alice of
+	// 2.5e12 planck in compact big-integer mode.
+	extra, call := body[99:102], body[102:]
+	assert.Equal(t, []byte{0x00, 7 << 2, 0x00}, extra)
+	assert.Equal(t, append(append([]byte{0x06, 0x03, 0x00}, alice...), mustHex("0b00a89c134602")...), call)
+
+	payload := append(append([]byte{}, call...), extra...)
+	payload = append(payload, 100, 0, 0, 0, 2, 0, 0, 0)
+	payload = append(append(payload, mustHex(genesis)...), mustHex(genesis)...)
+	assert.True(t, ed25519.Verify(pub, payload, sig), "signed over call, extensions and additional data")
+
+	for _, amount := range []string{"0", "-1", "1.0000000000001", "ten", ""} {
+		_, err := w.Transfer(context.Background(), key, alice, amount)
+		assert.Error(t, err, amount)
+	}
+}
+
+func TestWallet_TransferOutcome(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name    string
+		updates watch
+		outcome byte
+		// failed is whether no CLAW was sent, sent whether it was.
+		failed, sent bool
+	}{
+		{"finalized", watch{"ready", map[string]any{"finalized": "0xb10c"}}, 0x00, false, true},
+		{"extrinsic failed", watch{map[string]any{"inBlock": "0xb10c"}}, 0x01, true, false},
+		{"invalid", watch{"invalid"}, 0x00, true, false},
+		{"usurped", watch{"ready", map[string]any{"usurped": "0xbeef"}}, 0x00, true, false},
+		{"dropped", watch{"ready", "dropped"}, 0x00, false, false},
+		{"finality timeout", watch{map[string]any{"finalityTimeout": "0xb10c"}}, 0x00, false, false},
+		{"no verdict", watch{map[string]any{"inBlock": "0xb10c"}}, 0x07, false, false},
+		{"no status before the timeout", watch{"ready"}, 0x00, false, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := transferNode(t, map[string][]any{}, tt.updates, tt.outcome)
+			tx, err := w.Transfer(context.Background(), key, alice, "1")
+			switch {
+			case tt.sent:
+				require.NoError(t, err)
+				assert.NotEmpty(t, tx)
+			case tt.failed:
+				require.ErrorIs(t, err, clawchain.ErrTransferFailed)
+				assert.Empty(t, tx, "nothing was sent")
+			default:
+				require.Error(t, err)
+				assert.NotErrorIs(t, err, clawchain.ErrTransferFailed)
+				assert.NotEmpty(t, tx, "the transfer may still be carried out")
+			}
+		})
+	}
+}
+
+func TestWallet_TransferUnverified(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name     string
+		included bool
+		events   any
+		want     string
+	}{
+		{"not in the block", false, systemEvents(0x00, 0x00), "block 0xb10c does not include it"},
+		{"no events", true, nil, "block 0xb10c has no events"},
+		{"events not hex", true, "0xzz", "events of block 0xb10c are not hex"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string][]any{}
+			w := walletNode(t, map[string]any{
+				"state_getRuntimeVersion":        map[string]any{"specVersion": 100, "transactionVersion": 2},
+				"chain_getBlockHash":             "0x" + strings.Repeat("11", 32),
+				"system_accountNextIndex":        7,
+				"author_submitAndWatchExtrinsic": watch{map[string]any{"inBlock": "0xb10c"}},
+				"chain_getBlock": func() any {
+					extrinsics := []any{"0x280402000b50d5a0a29301"}
+					if tt.included {
+						extrinsics = append(extrinsics, params["author_submitAndWatchExtrinsic"][0])
+					}
+					return map[string]any{"block": map[string]any{"extrinsics": extrinsics}}
+				},
+				"state_getStorage": tt.events,
+			}, params)
+			tx, err := w.Transfer(context.Background(), key, alice, "0.000001")
+			assert.ErrorIs(t, err, clawchain.ErrRPC)
+			assert.ErrorContains(t, err, tt.want)
+			assert.NotEmpty(t, tx, "the transfer may have been carried out")
+		})
+	}
+}
+
+func TestWallet_TransferRefused(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name    string
+		results map[string]any
+		want    string
+	}{
+		{"invalid genesis", map[string]any{
+			"state_getRuntimeVersion": map[string]any{"specVersion": 100, "transactionVersion": 2},
+			"chain_getBlockHash":      "0x11",
+		}, `genesis hash "0x11" is not 32 bytes of hex`},
+		{"no genesis", map[string]any{}, `genesis hash "" is not 32 bytes of hex`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := walletNode(t, tt.results, map[string][]any{})
+			tx, err := w.Transfer(context.Background(), key, alice, "1")
+			assert.ErrorContains(t, err, tt.want)
+			assert.Empty(t, tx, "nothing was sent")
+		})
+	}
+}
+
+func TestWallet_BalanceAmounts(t *testing.T) {
+	account := func(free string) string {
+		return "0x" + strings.Repeat("00", 16) + free + strings.Repeat("00", 48)
+	}
+	for free, want := range map[string]string{
+		"05000000000000000000000000000000": "0.000000000005",
+		"0010a5d4e80000000000000000000000": "1",
+	} {
+		w := walletNode(t, map[string]any{"state_getStorage": account(free)}, map[string][]any{})
+		balance, err := w.Balance(context.Background(), alice)
+		require.NoError(t, err)
+		assert.Equal(t, want, balance)
+	}
+
+	w := walletNode(t, map[string]any{"state_getStorage": "0x0500"}, map[string][]any{})
+	_, err := w.Balance(context.Background(), alice)
+	assert.ErrorIs(t, err, clawchain.ErrRPC)
+	deposit, fee := w.Reserve()
+	assert.Equal(t, clawchain.DefaultExistentialDeposit, deposit)
+	assert.Equal(t, clawchain.DefaultTransferFee, fee)
+}
+
+func TestNewWallet_Invalid(t *testing.T) {
+	c, err := clawchain.New("ws://node:9944", "")
+	require.NoError(t, err)
+	_, err = clawchain.NewWallet(c, "transfer")
+	assert.ErrorContains(t, err, "transfer call")
+	_, err = c.Anchor(context.Background(), root)
+	assert.ErrorContains(t, err, "no clawchain anchor call", "a wallet-only client does not anchor")
+}
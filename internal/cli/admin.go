@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func newAdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Maintain the registry database",
+	}
+
+	cmd.AddCommand(
+		newAdminReindexCmd(),
+	)
+
+	return cmd
+}
+
+func newAdminReindexCmd() *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the full-text search indexes from the tool tables",
+		Long: `Rebuild the full-text search indexes of tools and federated tools from
+their tables. Search reads the indexes, which triggers keep in step with the
+tables; run this after editing the tables by hand or a failed migration.
+serve also rebuilds, on startup, any index whose row count is off.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := store.Open(dbPath)
+			if err != nil {
+				return fmt.Errorf("open store: %w", err)
+			}
+			defer func() { _ = db.Close() }()
+			if err := db.Reindex(cmd.Context()); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Rebuilt full-text search indexes.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", "./data/agent-tools.db", "SQLite database path")
+	return cmd
+}
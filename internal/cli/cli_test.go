@@ -1,6 +1,7 @@
 package cli_test
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
@@ -73,3 +74,12 @@ func TestInitCmd_Idempotent(t *testing.T) {
 	err = root.Execute()
 	assert.NoError(t, err)
 }
+
+func TestAdminReindex(t *testing.T) {
+	root := cli.NewRootCmd()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"admin", "reindex", "--db", t.TempDir() + "/agent-tools.db"})
+	require.NoError(t, root.Execute())
+	assert.Contains(t, out.String(), "Rebuilt full-text search indexes.")
+}
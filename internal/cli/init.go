@@ -31,8 +31,27 @@ func newInitCmd() *cobra.Command {
 addr = ":8433"
 db   = "./data/agent-tools.db"
 
+# [log], [http] and [reputation] are re-read on SIGHUP when serve runs
+# with --config; settings left unset keep their command-line values.
+[log]
+# level = "info"
+# components = { registry = "debug" }
+
+[http]
+# cors_origins = ["https://app.example.com"]
+
+[reputation]
+# half_life          = "720h" # a month-old day of invocations counts half
+# inactive_half_life = "336h" # an idle provider loses half its reputation in two weeks
+
+# [clawchain] is read when serve starts: receipt batches are anchored on the
+# node every --anchor-interval with the anchoring call of its runtime, and
+# paid invocations are settled in CLAW with its transfer call, from escrow
+# accounts derived from --escrow-key-file.
 [clawchain]
-# ws_url = "ws://testnet.clawchain.win:9944"
+# ws_url        = "ws://testnet.clawchain.win:9944"
+# anchor_call   = "0x2a00" # pallet and call index
+# transfer_call = "0x0603" # balances.transfer_keep_alive
 `
 			if err := os.WriteFile(cfgPath, []byte(cfg), 0o600); err != nil {
 				return fmt.Errorf("write config: %w", err)
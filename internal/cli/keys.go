@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/spf13/cobra"
+)
+
+// identity is an agent's key file: its Ed25519 seed, sealed with the
+// secrets key and bound to the DID, next to the public parts.
+type identity struct {
+	DID       string    `json:"did"`
+	PubKey    string    `json:"pubkey"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newKeysCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Create and inspect agent identities",
+	}
+
+	cmd.AddCommand(
+		newKeysGenerateCmd(),
+		newKeysShowCmd(),
+		newKeysExportCmd(),
+	)
+
+	return cmd
+}
+
+func newKeysGenerateCmd() *cobra.Command {
+	var (
+		out     string
+		keyFile string
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate an Ed25519 keypair and its did:claw:agent identifier",
+		Long: `Generate an Ed25519 keypair, derive its did:claw:agent identifier and
+write both to a key file, the private key encrypted with the secrets key
+(--secrets-key-file or $AGENT_TOOLS_SECRETS_KEY; create one with
+"openssl rand -base64 32"). Register the DID and public key it prints with
+POST /v1/providers to act as a provider.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			box, err := keysBox(keyFile)
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				if out, err = defaultIdentityPath(); err != nil {
+					return err
+				}
+			}
+			if _, err := os.Stat(out); err == nil && !force {
+				return fmt.Errorf("%s already exists; pass --force to replace it", out)
+			}
+			pub, key, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return fmt.Errorf("generate key: %w", err)
+			}
+			id := identity{
+				DID:       did.AgentID(pub),
+				PubKey:    receipts.SigPrefix + hex.EncodeToString(pub),
+				CreatedAt: time.Now().UTC().Truncate(time.Second),
+			}
+			if id.Key, err = box.Seal(key.Seed(), []byte(id.DID)); err != nil {
+				return fmt.Errorf("encrypt key: %w", err)
+			}
+			if err := writeIdentity(out, &id); err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintln(w, id.DID)
+			fmt.Fprintf(w, "Public key: %s\nKey file:   %s\n", id.PubKey, out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "key file to write (default ~/.agent-tools/identity.json)")
+	cmd.Flags().StringVar(&keyFile, "secrets-key-file", "", "base64 AES-256 key encrypting the private key (default $AGENT_TOOLS_SECRETS_KEY)")
+	cmd.Flags().BoolVar(&force, "force", false, "replace an existing key file")
+	return cmd
+}
+
+func newKeysShowCmd() *cobra.Command {
+	var path, keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the DID and public key of a key file",
+		Long: `Print the DID and public key of a key file, after checking that the
+secrets key decrypts it and that the private key matches both.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			id, _, err := loadIdentity(path, keyFile)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintln(w, id.DID)
+			fmt.Fprintf(w, "Public key: %s\nCreated:    %s\n", id.PubKey, id.CreatedAt.Format(time.RFC3339))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "file", "", "key file to read (default ~/.agent-tools/identity.json)")
+	cmd.Flags().StringVar(&keyFile, "secrets-key-file", "", "base64 AES-256 key encrypting the private key (default $AGENT_TOOLS_SECRETS_KEY)")
+	return cmd
+}
+
+func newKeysExportCmd() *cobra.Command {
+	var path, keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the private key of a key file, unencrypted",
+		Long: `Print the private key of a key file as "ed25519:<hex seed>", the form
+the Go SDK's LoadSigningKey and ParseSigningKey read. Anyone holding it can
+act as the agent; keep it out of shell history and logs.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, key, err := loadIdentity(path, keyFile)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), receipts.SigPrefix+hex.EncodeToString(key.Seed()))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "file", "", "key file to read (default ~/.agent-tools/identity.json)")
+	cmd.Flags().StringVar(&keyFile, "secrets-key-file", "", "base64 AES-256 key encrypting the private key (default $AGENT_TOOLS_SECRETS_KEY)")
+	return cmd
+}
+
+// keysBox opens the secrets key the keys commands encrypt with, which,
+// unlike for serve, is required.
+func keysBox(keyFile string) (*secrets.Box, error) {
+	box, err := openSecrets(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, errors.New("a secrets key is needed to encrypt the private key: pass --secrets-key-file or set $AGENT_TOOLS_SECRETS_KEY")
+	}
+	return box, nil
+}
+
+func defaultIdentityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate key file: %w", err)
+	}
+	return filepath.Join(home, ".agent-tools", "identity.json"), nil
+}
+
+func writeIdentity(path string, id *identity) error {
+	b, err := json.MarshalIndent(id, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write key file: %w", err)
+	}
+	return nil
+}
+
+// loadIdentity reads and decrypts the key file at path, or the default one,
+// and checks that its private key matches its DID and public key.
+func loadIdentity(path, keyFile string) (*identity, ed25519.PrivateKey, error) {
+	box, err := keysBox(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		if path, err = defaultIdentityPath(); err != nil {
+			return nil, nil, err
+		}
+	}
+	b, err := os.ReadFile(path) //nolint:gosec // path comes from the operator's command line
+	if err != nil {
+		return nil, nil, fmt.Errorf("read key file: %w", err)
+	}
+	var id identity
+	if err := json.Unmarshal(b, &id); err != nil {
+		return nil, nil, fmt.Errorf("parse key file %s: %w", path, err)
+	}
+	seed, err := box.Open(id.Key, []byte(id.DID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt key file %s: %w", path, err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, nil, fmt.Errorf("key file %s: private key is %d bytes, want %d", path, len(seed), ed25519.SeedSize)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	pub := key.Public().(ed25519.PublicKey)
+	if did.AgentID(pub) != id.DID || receipts.SigPrefix+hex.EncodeToString(pub) != id.PubKey {
+		return nil, nil, fmt.Errorf("key file %s: private key does not match its DID and public key", path)
+	}
+	return &id, key, nil
+}
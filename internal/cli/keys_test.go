@@ -0,0 +1,91 @@
+package cli_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/cli"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "secrets.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(secret)), 0o600))
+	path := filepath.Join(dir, "id", "identity.json")
+	run := func(args ...string) (string, error) {
+		root := cli.NewRootCmd()
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs(args)
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("keys", "generate", "--out", path, "--secrets-key-file", keyFile)
+	require.NoError(t, err)
+	id, _, _ := strings.Cut(out, "\n")
+	assert.True(t, strings.HasPrefix(id, did.AgentPrefix), out)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	_, err = run("keys", "generate", "--out", path, "--secrets-key-file", keyFile)
+	assert.ErrorContains(t, err, "already exists")
+
+	show, err := run("keys", "show", "--file", path, "--secrets-key-file", keyFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(show, id+"\n"), show)
+
+	seed, err := run("keys", "export", "--file", path, "--secrets-key-file", keyFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), strings.TrimPrefix(strings.TrimSpace(seed), "ed25519:"), "the key file is encrypted")
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(seed), "ed25519:"))
+	require.NoError(t, err)
+	pub := ed25519.NewKeyFromSeed(raw).Public().(ed25519.PublicKey)
+	assert.Equal(t, id, did.AgentID(pub))
+	assert.Contains(t, show, hex.EncodeToString(pub))
+
+	other := filepath.Join(dir, "other.key")
+	require.NoError(t, os.WriteFile(other, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0o600))
+	_, err = run("keys", "show", "--file", path, "--secrets-key-file", other)
+	assert.ErrorContains(t, err, "decrypt key file")
+
+	_, err = run("keys", "show", "--file", filepath.Join(dir, "missing.json"), "--secrets-key-file", keyFile)
+	assert.ErrorContains(t, err, "read key file")
+	garbled := filepath.Join(dir, "garbled.json")
+	require.NoError(t, os.WriteFile(garbled, []byte("{"), 0o600))
+	_, err = run("keys", "show", "--file", garbled, "--secrets-key-file", keyFile)
+	assert.ErrorContains(t, err, "parse key file")
+	forged := filepath.Join(dir, "forged.json")
+	require.NoError(t, os.WriteFile(forged, []byte(strings.Replace(string(b), hex.EncodeToString(pub), strings.Repeat("0", 64), 1)), 0o600))
+	_, err = run("keys", "show", "--file", forged, "--secrets-key-file", keyFile)
+	assert.ErrorContains(t, err, "does not match its DID and public key")
+
+	t.Setenv("HOME", dir)
+	out, err = run("keys", "generate", "--secrets-key-file", keyFile)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, ".agent-tools", "identity.json"))
+	show, err = run("keys", "show", "--secrets-key-file", keyFile)
+	require.NoError(t, err)
+	home, _, _ := strings.Cut(out, "\n")
+	assert.True(t, strings.HasPrefix(show, home+"\n"), show)
+
+	t.Setenv("AGENT_TOOLS_SECRETS_KEY", "")
+	_, err = run("keys", "generate", "--out", filepath.Join(dir, "new.json"))
+	assert.ErrorContains(t, err, "secrets key is needed")
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// listen opens the server's listener. A socket handed over by systemd
+// (LISTEN_PID/LISTEN_FDS) takes precedence; otherwise spec selects a
+// "unix:///path" or "tcp://host:port" listener, and an empty spec listens on
+// the TCP address addr.
+func listen(spec, addr string) (net.Listener, error) {
+	if ln, err := activatedListener(); ln != nil || err != nil {
+		return ln, err
+	}
+	switch {
+	case spec == "":
+		return net.Listen("tcp", addr)
+	case strings.HasPrefix(spec, "tcp://"):
+		return net.Listen("tcp", strings.TrimPrefix(spec, "tcp://"))
+	case strings.HasPrefix(spec, "unix://"):
+		return listenUnix(strings.TrimPrefix(spec, "unix://"))
+	default:
+		return nil, fmt.Errorf("listen %q: want unix:///path or tcp://host:port", spec)
+	}
+}
+
+// listenUnix listens on a unix socket at path, replacing a stale socket left
+// behind by a previous run. Any other file at path is left alone.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("listen: unix socket path is empty")
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen: %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// activatedListener returns the first socket passed by systemd socket
+// activation, or nil when the process was not socket-activated. The
+// activation variables are cleared so child processes do not inherit them.
+func activatedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_3")
+	defer func() { _ = f.Close() }()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger components that accept a per-component level override.
+const (
+	componentHTTP     = "http"
+	componentRegistry = "registry"
+	componentStore    = "store"
+)
+
+// logOptions holds the serve command's logging flags.
+type logOptions struct {
+	Levels map[string]string
+	Level  string
+	Format string
+
+	// atomic holds the live level of every logger built so far, keyed by
+	// component ("" for the root logger), so levels can change at runtime.
+	atomic map[string]zap.AtomicLevel
+}
+
+// build returns a logger for component, honoring a per-component level
+// override when one is set.
+func (o *logOptions) build(component string) (*zap.Logger, error) {
+	lvl, err := o.levelFor(component, o.Level, o.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg zap.Config
+	switch o.Format {
+	case "json", "":
+		cfg = zap.NewProductionConfig()
+	case "console":
+		cfg = zap.NewDevelopmentConfig()
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want json or console)", o.Format)
+	}
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	if o.atomic == nil {
+		o.atomic = make(map[string]zap.AtomicLevel)
+	}
+	o.atomic[component] = cfg.Level
+
+	log, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("build logger: %w", err)
+	}
+	if component == "" {
+		return log, nil
+	}
+	return log.Named(component), nil
+}
+
+// levelFor resolves the level of component from a default and overrides.
+func (o *logOptions) levelFor(component, level string, overrides map[string]string) (zapcore.Level, error) {
+	if l, ok := overrides[component]; ok {
+		level = l
+	}
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return lvl, fmt.Errorf("log level for %q: %w", component, err)
+	}
+	return lvl, nil
+}
+
+// setLevels changes the level of every logger already built. Nothing changes
+// unless all levels parse.
+func (o *logOptions) setLevels(level string, overrides map[string]string) error {
+	next := make(map[string]zapcore.Level, len(o.atomic))
+	for component := range o.atomic {
+		lvl, err := o.levelFor(component, level, overrides)
+		if err != nil {
+			return err
+		}
+		next[component] = lvl
+	}
+	for component, lvl := range next {
+		o.atomic[component].SetLevel(lvl)
+	}
+	return nil
+}
+
+// validate checks that every per-component override names a known component.
+func (o *logOptions) validate() error {
+	for c := range o.Levels {
+		switch c {
+		case componentHTTP, componentRegistry, componentStore:
+		default:
+			return fmt.Errorf("unknown log component %q", c)
+		}
+	}
+	return nil
+}
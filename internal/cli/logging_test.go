@@ -0,0 +1,45 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeCmd_InvalidLogFormat(t *testing.T) {
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--log-format", "xml"})
+	err := root.Execute()
+	assert.ErrorContains(t, err, "unknown log format")
+}
+
+func TestServeCmd_InvalidLogLevel(t *testing.T) {
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--log-level", "chatty"})
+	err := root.Execute()
+	assert.ErrorContains(t, err, "log level")
+}
+
+func TestServeCmd_InvalidComponentLevel(t *testing.T) {
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--log-component-level", "registry=loud"})
+	err := root.Execute()
+	assert.ErrorContains(t, err, `log level for "registry"`)
+}
+
+func TestServeCmd_UnknownLogComponent(t *testing.T) {
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--log-component-level", "gpu=debug"})
+	err := root.Execute()
+	assert.ErrorContains(t, err, "unknown log component")
+}
+
+func TestServeCmd_LoggingFlagsExist(t *testing.T) {
+	root := cli.NewRootCmd()
+	serveCmd, _, err := root.Find([]string{"serve"})
+	assert.NoError(t, err)
+	for _, name := range []string{"log-level", "log-format", "log-component-level", "access-log"} {
+		assert.NotNil(t, serveCmd.Flag(name), name)
+	}
+}
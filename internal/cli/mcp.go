@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newMCPCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Expose registry tools over the Model Context Protocol",
+	}
+	cmd.AddCommand(newMCPServeCmd(), newMCPImportCmd())
+	return cmd
+}
+
+func newMCPServeCmd() *cobra.Command {
+	var (
+		registryURL string
+		token       string
+		transport   string
+		addr        string
+		query       string
+		tag         string
+		collection  string
+		collVersion string
+		logOpts     logOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve registry tools to MCP clients over stdio or SSE",
+		Long: `Serve registry tools to MCP clients such as Claude Desktop.
+
+Each registered tool is listed as an MCP tool; calls are made through the
+registry's /v1/invoke endpoint, so pricing and receipts apply as usual.
+With --collection only the tools of that collection are served, which
+installs the whole bundle in one go.
+With --transport stdio (the default) the client starts this command and
+talks to it on stdin and stdout; logs go to stderr.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := logOpts.validate(); err != nil {
+				return err
+			}
+			log, err := logOpts.build("mcp")
+			if err != nil {
+				return err
+			}
+			defer func() { _ = log.Sync() }()
+
+			var opts []agenttools.ClientOption
+			if token != "" {
+				opts = append(opts, agenttools.WithAuthToken(token))
+			}
+			client := agenttools.NewClient(registryURL, opts...)
+			backend := mcp.NewRegistryBackend(client, query, tag)
+			if collection != "" {
+				if query != "" || tag != "" {
+					return errors.New("--collection cannot be combined with --query or --tag")
+				}
+				backend = mcp.NewCollectionBackend(client, collection, collVersion)
+			}
+			srv := mcp.NewServer(backend, mcp.Implementation{Name: "agent-tools", Version: "0.1.0"}, log)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			switch transport {
+			case "stdio":
+				return srv.ServeStdio(ctx, os.Stdin, os.Stdout)
+			case "sse":
+				return serveMCPSSE(ctx, srv, addr, log)
+			default:
+				return fmt.Errorf("unknown transport %q (want stdio or sse)", transport)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&registryURL, "registry", "http://localhost:8433", "Registry URL")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("AGENT_TOOLS_TOKEN"), "Bearer token for invocations (env AGENT_TOOLS_TOKEN)")
+	cmd.Flags().StringVar(&transport, "transport", "stdio", "MCP transport: stdio or sse")
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8434", "Listen address for the sse transport")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "Only expose tools matching this search query")
+	cmd.Flags().StringVar(&tag, "tag", "", "Only expose tools with this tag")
+	cmd.Flags().StringVar(&collection, "collection", "", "Only expose the tools of this collection")
+	cmd.Flags().StringVar(&collVersion, "collection-version", "", "Version of --collection (default the latest)")
+	cmd.Flags().StringVar(&logOpts.Level, "log-level", "info", "log level: debug, info, warn, error")
+	cmd.Flags().StringVar(&logOpts.Format, "log-format", "json", "log format: json or console")
+	return cmd
+}
+
+func serveMCPSSE(ctx context.Context, srv *mcp.Server, addr string, log *zap.Logger) error {
+	hs := &http.Server{
+		Addr:              addr,
+		Handler:           srv.SSEHandler(""),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("mcp sse listening", zap.String("addr", addr))
+		errCh <- hs.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Event streams never finish on their own; close them once the grace
+	// period is up.
+	if err := hs.Shutdown(shutCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	_ = hs.Close()
+	return nil
+}
+
+func newMCPImportCmd() *cobra.Command {
+	var (
+		registryURL string
+		token       string
+		server      string
+		opts        mcp.ImportOptions
+		price       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Register the tools of an MCP server in the registry",
+		Long: `Connect to an MCP server over HTTP+SSE, read its tools and their input
+schemas, and register each of them with the server as its endpoint
+(mcp+https://host/sse#tool). The tools are registered as the provider
+identified by --token.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if price != "" {
+				opts.Pricing = &agenttools.Pricing{Model: "per_call", AmountCLAW: price}
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), time.Minute)
+			defer cancel()
+
+			c, err := mcp.Dial(ctx, server, nil, mcp.Implementation{Name: "agent-tools", Version: "0.1.0"})
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var clientOpts []agenttools.ClientOption
+			if token != "" {
+				clientOpts = append(clientOpts, agenttools.WithAuthToken(token))
+			}
+			results, err := mcp.Import(ctx, c, agenttools.NewClient(registryURL, clientOpts...), opts)
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+					fmt.Fprintf(cmd.OutOrStdout(), "  ✗ %s: %v\n", r.Name, r.Err)
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "  ✓ %s @ %s (%s)\n", r.Tool.Name, r.Tool.Version, r.Tool.ID)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %d of %d tools from %s\n", len(results)-failed, len(results), c.ServerInfo().Name)
+			if failed > 0 {
+				return fmt.Errorf("%d tools failed to register", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&registryURL, "registry", "http://localhost:8433", "Registry URL")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("AGENT_TOOLS_TOKEN"), "Provider token (env AGENT_TOOLS_TOKEN)")
+	cmd.Flags().StringVar(&server, "server", "", "SSE URL of the MCP server, e.g. https://mcp.example.com/sse")
+	cmd.Flags().StringVar(&opts.Prefix, "prefix", "", "Prefix for the registered tool names")
+	cmd.Flags().StringVar(&opts.Version, "version", "", "Version to register (default: the server's version)")
+	cmd.Flags().StringSliceVar(&opts.Tags, "tag", nil, "Tags for the registered tools")
+	cmd.Flags().StringVar(&price, "price", "", "Per-call price in CLAW (default: free)")
+	cmd.Flags().Int64Var(&opts.TimeoutMS, "timeout-ms", 0, "Invocation timeout in milliseconds (default: registry default)")
+	_ = cmd.MarkFlagRequired("server")
+	return cmd
+}
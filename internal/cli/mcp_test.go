@@ -0,0 +1,136 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/cli"
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// mcpTools is an MCP backend listing a read and a write tool.
+type mcpTools struct{}
+
+func (mcpTools) ListTools(context.Context, string) ([]mcp.Tool, string, error) {
+	schema := json.RawMessage(`{"type":"object"}`)
+	return []mcp.Tool{{Name: "read", InputSchema: schema}, {Name: "write", InputSchema: schema}}, "", nil
+}
+
+func (mcpTools) CallTool(context.Context, string, map[string]any) (*mcp.CallToolResult, error) {
+	return &mcp.CallToolResult{}, nil
+}
+
+func TestMCPImportCmd(t *testing.T) {
+	server := httptest.NewServer(mcp.NewServer(mcpTools{}, mcp.Implementation{Name: "files", Version: "2.0.0"}, zap.NewNop()).
+		SSEHandler(""))
+	defer server.Close()
+	var registered []map[string]any
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "Bearer prov-token", r.Header.Get("Authorization"))
+		registered = append(registered, req)
+		if req["name"] == "fs.write" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"code":"DUPLICATE","message":"exists"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "t1", "name": req["name"], "version": req["version"]})
+	}))
+	defer reg.Close()
+
+	root := cli.NewRootCmd()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{
+		"mcp", "import", "--server", server.URL + "/sse", "--registry", reg.URL, "--token", "prov-token",
+		"--prefix", "fs.", "--price", "0.1",
+	})
+	assert.ErrorContains(t, root.Execute(), "1 tools failed to register")
+	assert.Contains(t, out.String(), "✓ fs.read @ 2.0.0 (t1)")
+	assert.Contains(t, out.String(), "✗ fs.write: api error DUPLICATE: exists")
+	assert.Contains(t, out.String(), "Imported 1 of 2 tools from files")
+	require.Len(t, registered, 2)
+	assert.Equal(t, map[string]any{"model": "per_call", "amount_claw": "0.1"}, registered[0]["pricing"])
+
+	notMCP := httptest.NewServer(http.NotFoundHandler())
+	defer notMCP.Close()
+	root = cli.NewRootCmd()
+	root.SetArgs([]string{"mcp", "import", "--server", notMCP.URL + "/sse", "--registry", reg.URL})
+	assert.ErrorContains(t, root.Execute(), "http 404")
+}
+
+func TestMCPServeCmd_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args []string
+		want string
+	}{
+		"collection and query": {[]string{"--collection", "audit-suite", "--query", "lint"}, "cannot be combined"},
+		"unknown transport":    {[]string{"--transport", "carrier-pigeon"}, "unknown transport"},
+		"bad log level":        {[]string{"--log-level", "chatty"}, "log level"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := cli.NewRootCmd()
+			root.SetArgs(append([]string{"mcp", "serve"}, tc.args...))
+			assert.ErrorContains(t, root.Execute(), tc.want)
+		})
+	}
+}
+
+func TestMCPServeCmd_SSE(t *testing.T) {
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"tools":[],"total":0}`))
+	}))
+	defer reg.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+
+	// The address is taken.
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{
+		"mcp", "serve", "--transport", "sse", "--addr", addr, "--registry", reg.URL, "--collection", "audit-suite",
+		"--log-level", "error",
+	})
+	assert.ErrorContains(t, root.Execute(), "address already in use")
+	require.NoError(t, ln.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	root = cli.NewRootCmd()
+	root.SetArgs([]string{
+		"mcp", "serve", "--transport", "sse", "--addr", addr, "--registry", reg.URL, "--token", "tok", "--log-level", "error",
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- root.ExecuteContext(ctx) }()
+
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+"/sse", http.NoBody)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false
+		}
+		defer func() { _ = resp.Body.Close() }()
+		return resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Type") == "text/event-stream"
+	}, 5*time.Second, 20*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("mcp serve did not shut down")
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
+	"go.uber.org/zap"
+)
+
+// settings are the parts of the config file that can change while serving.
+// Anything left out falls back to the corresponding command-line flag.
+type settings struct {
+	Log struct {
+		Level      string            `toml:"level"`
+		Components map[string]string `toml:"components"`
+	} `toml:"log"`
+	HTTP struct {
+		CORSOrigins []string `toml:"cors_origins"`
+	} `toml:"http"`
+	Reputation struct {
+		HalfLife         time.Duration `toml:"half_life"`
+		InactiveHalfLife time.Duration `toml:"inactive_half_life"`
+	} `toml:"reputation"`
+	Anonymous struct {
+		Mode string `toml:"mode"`
+		// Rate is a pointer because 0 disables the limit.
+		Rate  *int `toml:"rate"`
+		Burst int  `toml:"burst"`
+	} `toml:"anonymous"`
+	Webhooks struct {
+		Attempts int           `toml:"attempts"`
+		Backoff  time.Duration `toml:"backoff"`
+	} `toml:"webhooks"`
+	// Clawchain is read once, when serve starts.
+	Clawchain struct {
+		WSURL        string `toml:"ws_url"`
+		AnchorCall   string `toml:"anchor_call"`
+		TransferCall string `toml:"transfer_call"`
+		// ExistentialDeposit and TransferFee are the CLAW an escrow
+		// account keeps back, besides what it holds, for the chain's
+		// existential deposit and the fee of each transfer.
+		ExistentialDeposit string `toml:"existential_deposit"`
+		TransferFee        string `toml:"transfer_fee"`
+	} `toml:"clawchain"`
+}
+
+// loadSettings reads the reloadable settings from the TOML file at path.
+// Sections it does not know about, such as [server], are ignored.
+func loadSettings(path string) (*settings, error) {
+	var s settings
+	if _, err := toml.DecodeFile(path, &s); err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := (&logOptions{Levels: s.Log.Components}).validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if s.Reputation.HalfLife < 0 || s.Reputation.InactiveHalfLife < 0 {
+		return nil, fmt.Errorf("config %s: reputation half-lives must be positive", path)
+	}
+	if s.Anonymous.Mode != "" {
+		if err := (api.AnonymousPolicy{Mode: s.Anonymous.Mode}).Validate(); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	if (s.Anonymous.Rate != nil && *s.Anonymous.Rate < 0) || s.Anonymous.Burst < 0 {
+		return nil, fmt.Errorf("config %s: anonymous rate and burst must not be negative", path)
+	}
+	if s.Webhooks.Attempts < 0 || s.Webhooks.Backoff < 0 {
+		return nil, fmt.Errorf("config %s: webhook attempts and backoff must be positive", path)
+	}
+	return &s, nil
+}
+
+// reloader re-applies the config file to a running server.
+type reloader struct {
+	path       string
+	logOpts    *logOptions
+	cors       []string
+	reputation registry.ReputationConfig
+	anonymous  api.AnonymousPolicy
+	webhooks   webhooks.Config
+	handler    *api.Handler
+	reg        *registry.Registry
+	hooks      *webhooks.Dispatcher
+	log        *zap.Logger
+	mu         sync.Mutex
+}
+
+// reload reads the config file and applies it. A file that fails to parse or
+// validate leaves the running settings untouched.
+func (rl *reloader) reload() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	level, levels, cors, rep := rl.logOpts.Level, rl.logOpts.Levels, rl.cors, rl.reputation
+	anon, hookCfg := rl.anonymous, rl.webhooks
+	if rl.path != "" {
+		s, err := loadSettings(rl.path)
+		if err != nil {
+			return err
+		}
+		if s.Log.Level != "" {
+			level = s.Log.Level
+		}
+		if len(s.Log.Components) > 0 {
+			merged := make(map[string]string, len(levels)+len(s.Log.Components))
+			for c, l := range levels {
+				merged[c] = l
+			}
+			for c, l := range s.Log.Components {
+				merged[c] = l
+			}
+			levels = merged
+		}
+		if len(s.HTTP.CORSOrigins) > 0 {
+			cors = s.HTTP.CORSOrigins
+		}
+		if s.Reputation.HalfLife > 0 {
+			rep.HalfLife = s.Reputation.HalfLife
+		}
+		if s.Reputation.InactiveHalfLife > 0 {
+			rep.InactiveHalfLife = s.Reputation.InactiveHalfLife
+		}
+		if s.Anonymous.Mode != "" {
+			anon.Mode = s.Anonymous.Mode
+		}
+		if s.Anonymous.Rate != nil {
+			anon.PerMinute = *s.Anonymous.Rate
+		}
+		if s.Anonymous.Burst > 0 {
+			anon.Burst = s.Anonymous.Burst
+		}
+		if s.Webhooks.Attempts > 0 {
+			hookCfg.Attempts = s.Webhooks.Attempts
+		}
+		if s.Webhooks.Backoff > 0 {
+			hookCfg.Backoff = s.Webhooks.Backoff
+		}
+	}
+
+	if err := rl.logOpts.setLevels(level, levels); err != nil {
+		return err
+	}
+	rl.handler.SetCORSOrigins(cors)
+	if err := rl.handler.SetAnonymous(anon); err != nil {
+		return err
+	}
+	if rl.hooks != nil {
+		rl.hooks.SetConfig(hookCfg)
+	}
+	if rl.reg != nil {
+		if err := rl.reg.SetReputation(rep); err != nil {
+			return err
+		}
+	}
+	rl.log.Info("settings applied", zap.String("config", rl.path), zap.String("log_level", level),
+		zap.Strings("cors_origins", cors), zap.Duration("reputation_half_life", rep.HalfLife),
+		zap.Duration("reputation_inactive_half_life", rep.InactiveHalfLife), zap.String("anonymous", anon.Mode),
+		zap.Int("anonymous_rate", anon.PerMinute), zap.Int("anonymous_burst", anon.Burst),
+		zap.Int("webhook_attempts", hookCfg.Attempts), zap.Duration("webhook_backoff", hookCfg.Backoff))
+	return nil
+}
+
+// watch reloads on every SIGHUP until ctx is done.
+func (rl *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := rl.reload(); err != nil {
+				rl.log.Warn("config reload failed", zap.Error(err))
+			}
+		}
+	}
+}
@@ -22,6 +22,9 @@ Learn more: https://github.com/clawinfra/agent-tools`,
 		newServeCmd(),
 		newInitCmd(),
 		newToolCmd(),
+		newMCPCmd(),
+		newAdminCmd(),
+		newKeysCmd(),
 	)
 
 	return root
@@ -1,46 +1,331 @@
 package cli
 
 import (
+	"cmp"
 	"context"
+	"crypto/ed25519"
+	"database/sql"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/clawinfra/agent-tools/internal/abuse"
+	"github.com/clawinfra/agent-tools/internal/accesslog"
 	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"github.com/clawinfra/agent-tools/internal/clawchain"
+	"github.com/clawinfra/agent-tools/internal/coord"
+	"github.com/clawinfra/agent-tools/internal/errreport"
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/federation"
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/netguard"
 	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/seed"
 	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
+	"github.com/clawinfra/agent-tools/internal/worker"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+	_ "github.com/lib/pq" // Postgres driver for --coord-dsn
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func newServeCmd() *cobra.Command {
 	var (
-		addr   string
-		dbPath string
+		addr      string
+		dbPath    string
+		slowQuery time.Duration
+		rollup    time.Duration
+		invBatch  time.Duration
+		accessLog bool
+		logOpts   logOptions
+		sentryDSN string
+		accessOut string
+		accessRot accesslog.Options
+		grace     time.Duration
+		coordDSN  string
+		instance  string
+		peerURLs  map[string]string
+		fedSync   time.Duration
+		basePath  string
+		proxied   bool
+		cfgPath   string
+		origins   []string
+		listenOn  string
+		adminTok  string
+		maintMode string
+		seedPath  string
+		seedFake  int
+		limits    = registry.DefaultLimits
+		warnAt    float64
+		routeBy   string
+		detect    bool
+		keyFile   string
+		regKey    string
+		regDID    string
+		signed    bool
+		nonces    bool
+		respSigs  bool
+		keyProof  bool
+		rbac      bool
+		anon      = api.DefaultAnonymousPolicy
+		abuseCfg  = abuse.DefaultConfig
+		wasm      bool
+		wasmMemMB int
+		wasmLim   = sandbox.DefaultLimits
+		ctrs      bool
+		ctrCLI    sandbox.CLI
+		ctrMemMB  int64
+		ctrLim    = sandbox.DefaultContainerLimits
+		guard     netguard.Policy
+		evSource  string
+		ipfsAPI   string
+		casDir    string
+		payTTL    time.Duration
+		slaCheck  time.Duration
+		confCheck time.Duration
+		reapEvery time.Duration
+		reapGrace time.Duration
+		notice    time.Duration
+		chainWS   string
+		chainCall string
+		xferCall  string
+		escrowKey string
+		anchorInt time.Duration
+		settleInt time.Duration
+		repCfg    = registry.DefaultReputationConfig
+		jobWork   int
+		jobShares = jobs.DefaultShares
+		jobTTL    time.Duration
+		hookWork  int
+		hookCfg   = webhooks.DefaultConfig
+		breaker   = router.DefaultBreakerConfig
 	)
 
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the agent-tools registry server",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			log, _ := zap.NewProduction()
+			if err := logOpts.validate(); err != nil {
+				return err
+			}
+			if err := anon.Validate(); err != nil {
+				return err
+			}
+			if err := repCfg.Validate(); err != nil {
+				return err
+			}
+			log, err := logOpts.build("")
+			if err != nil {
+				return err
+			}
 			defer log.Sync() //nolint:errcheck // Sync error on stderr is non-actionable
+			storeLog, err := logOpts.build(componentStore)
+			if err != nil {
+				return err
+			}
+			regLog, err := logOpts.build(componentRegistry)
+			if err != nil {
+				return err
+			}
+			httpLog, err := logOpts.build(componentHTTP)
+			if err != nil {
+				return err
+			}
 
 			db, err := store.Open(dbPath)
 			if err != nil {
 				return fmt.Errorf("open store: %w", err)
 			}
 			defer func() { _ = db.Close() }()
+			db.SetSlowQueryLog(slowQuery, storeLog)
+			rebuilt, err := db.RepairFTS(context.Background())
+			if err != nil {
+				return fmt.Errorf("check full-text indexes: %w", err)
+			}
+			if len(rebuilt) > 0 {
+				storeLog.Warn("rebuilt full-text indexes out of step with their tables", zap.Strings("indexes", rebuilt))
+			}
 
-			reg := registry.New(db, log)
-			handler := api.NewHandler(reg, log)
+			peers, err := federation.ParsePeers(peerURLs)
+			if err != nil {
+				return err
+			}
+
+			locker, closeLocker, err := openLocker(coordDSN)
+			if err != nil {
+				return err
+			}
+			defer closeLocker()
+			if coordDSN != "" && instance == "" {
+				instance = defaultInstanceID()
+			}
+
+			regOpts := []registry.Option{
+				registry.WithInstanceID(instance),
+				registry.WithLimits(limits),
+				registry.WithSignedManifests(signed),
+				registry.WithRequiredNonces(nonces),
+				registry.WithRequiredResponseSignatures(respSigs),
+				registry.WithEndpointPolicy(guard),
+				registry.WithEventSource(evSource),
+				registry.WithPayloadRetention(payTTL),
+				registry.WithReapGrace(reapGrace),
+				registry.WithPriceNotice(notice),
+				registry.WithWarnThreshold(warnAt),
+				registry.WithDefaultRouteStrategy(routeBy),
+				registry.WithReputation(repCfg),
+			}
+			box, err := openSecrets(keyFile)
+			if err != nil {
+				return err
+			}
+			if box != nil {
+				regOpts = append(regOpts, registry.WithSecrets(box))
+			}
+			pins, err := openCAS(ipfsAPI, casDir)
+			if err != nil {
+				return err
+			}
+			if pins != nil {
+				regOpts = append(regOpts, registry.WithCAS(pins))
+			}
+			if invBatch > 0 {
+				regOpts = append(regOpts, registry.WithInvocationBatching())
+			}
+			chain, wallet, err := openClawchain(chainWS, chainCall, xferCall, cfgPath)
+			if err != nil {
+				return err
+			}
+			if chain != nil && anchorInt > 0 {
+				regOpts = append(regOpts, registry.WithAnchorer(chain))
+			}
+			if wallet != nil {
+				escrow, err := openSigningKey(escrowKey, "AGENT_TOOLS_ESCROW_KEY", "escrow key")
+				if err != nil {
+					return err
+				}
+				if escrow == nil {
+					return errors.New("settling payments on ClawChain needs the key escrow accounts are derived from: " +
+						"pass --escrow-key-file or set $AGENT_TOOLS_ESCROW_KEY")
+				}
+				regOpts = append(regOpts, registry.WithWallet(wallet, escrow.Seed()))
+			}
+			reg := registry.New(db, regLog, regOpts...)
+			if !slices.Contains(reg.RouteStrategies(), routeBy) {
+				return fmt.Errorf("--route-strategy must be one of %s", strings.Join(reg.RouteStrategies(), ", "))
+			}
+			if err := seedRegistry(cmd.Context(), log, reg, seedPath, seedFake); err != nil {
+				return err
+			}
+			apiOpts := []api.Option{
+				api.WithAccessLog(accessLog),
+				api.WithBasePath(basePath),
+				api.WithTrustedProxy(proxied),
+				api.WithAnonymous(anon),
+				api.WithKeyProofs(keyProof),
+				api.WithRBAC(rbac),
+			}
+			if adminTok == "" {
+				adminTok = os.Getenv("AGENT_TOOLS_ADMIN_TOKEN")
+			}
+			if adminTok != "" {
+				apiOpts = append(apiOpts, api.WithAdminToken(adminTok))
+			}
+			var detector *abuse.Detector
+			if detect {
+				detector = abuse.New(reg, regLog, abuseCfg)
+				apiOpts = append(apiOpts, api.WithAbuseDetector(detector))
+			}
+			if breaker.Failures > 0 {
+				apiOpts = append(apiOpts, api.WithCircuitBreaker(router.NewBreaker(breaker)))
+			}
+			signer, err := openSigningKey(regKey, "AGENT_TOOLS_REGISTRY_KEY", "registry key")
+			if err != nil {
+				return err
+			}
+			if signer != nil {
+				apiOpts = append(apiOpts, api.WithRegistryKey(signer))
+			}
+			if regDID != "" {
+				apiOpts = append(apiOpts, api.WithRegistryDID(regDID))
+			}
+			if wasm {
+				wasmLim.MemoryPages = uint32(wasmMemMB << 20 / sandbox.PageSize)
+				ex, err := sandbox.New(cmd.Context(), wasmLim, reg.ModuleBytes)
+				if err != nil {
+					return err
+				}
+				defer func() { _ = ex.Close(context.Background()) }()
+				apiOpts = append(apiOpts, api.WithSandbox(ex))
+			}
+			if ctrs {
+				ctrLim.MemoryBytes = ctrMemMB << 20
+				apiOpts = append(apiOpts, api.WithContainers(sandbox.NewContainers(ctrCLI, ctrLim)))
+			}
+			rl := &reloader{
+				path: cfgPath, logOpts: &logOpts, cors: origins, reputation: repCfg, anonymous: anon, reg: reg, log: log,
+			}
+			if cfgPath != "" {
+				apiOpts = append(apiOpts, api.WithReload(rl.reload))
+			}
+			if accessLog && accessOut != "" {
+				sink, err := accesslog.Open(accessOut, accessRot)
+				if err != nil {
+					return err
+				}
+				defer func() { _ = sink.Close() }()
+				apiOpts = append(apiOpts, api.WithAccessLogger(accesslog.NewLogger(sink)))
+			}
+			if sentryDSN != "" {
+				sentry, err := errreport.NewSentry(sentryDSN, log)
+				if err != nil {
+					return err
+				}
+				defer sentry.Close()
+				apiOpts = append(apiOpts, api.WithErrorReporter(sentry))
+			}
+			var queue *jobs.Queue
+			if jobWork > 0 {
+				queue = jobs.New(db, regLog, jobTTL)
+				apiOpts = append(apiOpts, api.WithJobQueue(queue))
+			}
+			var hooks *webhooks.Dispatcher
+			if queue != nil && box != nil && hookWork > 0 {
+				hookCfg.Retention = jobTTL
+				hooks = webhooks.New(db, regLog, &http.Client{Transport: reg.EndpointTransport()}, box, hookCfg)
+				rl.hooks, rl.webhooks = hooks, hookCfg
+				apiOpts = append(apiOpts, api.WithWebhooks(hooks))
+			}
+			handler := api.NewHandler(reg, httpLog, apiOpts...)
+			rl.handler = handler
+			// The mode is shared by the replicas, so one started without
+			// --maintenance leaves it as the others set it.
+			if cmd.Flags().Changed("maintenance") {
+				if err := handler.SetMaintenance(cmd.Context(), api.Maintenance{Mode: maintMode}); err != nil {
+					return err
+				}
+			}
+			if err := rl.reload(); err != nil {
+				return err
+			}
 
+			ln, err := listen(listenOn, addr)
+			if err != nil {
+				return err
+			}
 			srv := &http.Server{
-				Addr:         addr,
 				Handler:      handler,
 				ReadTimeout:  10 * time.Second,
 				WriteTimeout: 60 * time.Second,
@@ -49,27 +334,396 @@ func newServeCmd() *cobra.Command {
 
 			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
 			defer cancel()
+			go rl.watch(ctx)
+
+			// Queued invocation records are this process's own, so every
+			// replica flushes its queue.
+			go worker.Periodic(ctx, log, "invocation-flush", invBatch, reg.FlushInvocations)
+			go worker.Periodic(ctx, log, "usage-rollup", rollup,
+				coord.Exclusive(locker, "usage-rollup", reg.RollupRecentUsage))
+			go worker.Periodic(ctx, log, "cache-purge", rollup,
+				coord.Exclusive(locker, "cache-purge", reg.PurgeResultCache))
+			go worker.Periodic(ctx, log, "payload-purge", rollup,
+				coord.Exclusive(locker, "payload-purge", reg.PurgePayloads))
+			go worker.Periodic(ctx, log, "idempotency-purge", rollup,
+				coord.Exclusive(locker, "idempotency-purge", reg.PurgeIdempotencyKeys))
+			go worker.Periodic(ctx, log, "sla-check", slaCheck,
+				coord.Exclusive(locker, "sla-check", reg.CheckSLAs))
+			go worker.Periodic(ctx, log, "conformance-check", confCheck,
+				coord.Exclusive(locker, "conformance-check", handler.CheckConformance))
+			go worker.Periodic(ctx, log, "reputation", rollup,
+				coord.Exclusive(locker, "reputation", reg.RecomputeReputation))
+			go worker.Periodic(ctx, log, "policy-decision-purge", rollup,
+				coord.Exclusive(locker, "policy-decision-purge", reg.PurgePolicyDecisions))
+			go worker.Periodic(ctx, log, "nonce-purge", rollup,
+				coord.Exclusive(locker, "nonce-purge", reg.PurgeNonces))
+			go worker.Periodic(ctx, log, "invocation-reaper", reapEvery,
+				coord.Exclusive(locker, "invocation-reaper", reg.ReapAbandonedInvocations))
+			if queue != nil {
+				go handler.RunJobs(ctx, jobWork, jobShares)
+				go worker.Periodic(ctx, log, "job-purge", rollup,
+					coord.Exclusive(locker, "job-purge", queue.Purge))
+			}
+			if hooks != nil {
+				go hooks.Run(ctx, hookWork)
+				go worker.Periodic(ctx, log, "webhook-purge", rollup,
+					coord.Exclusive(locker, "webhook-purge", hooks.Purge))
+			}
+			if detector != nil {
+				go worker.Periodic(ctx, log, "abuse-spend", rollup,
+					coord.Exclusive(locker, "abuse-spend", detector.CheckSpend))
+			}
+			if chain != nil {
+				go worker.Periodic(ctx, log, "receipt-anchor", anchorInt,
+					coord.Exclusive(locker, "receipt-anchor", reg.AnchorReceipts))
+			}
+			if wallet != nil {
+				go worker.Periodic(ctx, log, "escrow-settle", settleInt,
+					coord.Exclusive(locker, "escrow-settle", reg.SettleEscrows))
+			}
+			if len(peers) > 0 {
+				syncer := federation.NewSyncer(reg, regLog, peers)
+				go worker.Periodic(ctx, log, "federation-sync", fedSync,
+					coord.Exclusive(locker, "federation-sync", syncer.Sync))
+			}
 
 			go func() {
-				log.Info("registry server listening", zap.String("addr", addr))
-				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Info("registry server listening", zap.Stringer("addr", ln.Addr()))
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 					log.Error("server error", zap.Error(err))
 					cancel()
 				}
 			}()
 
 			<-ctx.Done()
-
-			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer shutdownCancel()
-
-			log.Info("shutting down")
-			return srv.Shutdown(shutdownCtx)
+			return shutdown(log, srv, handler, reg, grace)
 		},
 	}
 
 	cmd.Flags().StringVar(&addr, "addr", ":8433", "listen address")
+	cmd.Flags().StringVar(&listenOn, "listen", "",
+		"unix:///path/to.sock or tcp://host:port; overrides --addr (a systemd-activated socket overrides both)")
+	cmd.Flags().StringVar(&cfgPath, "config", "",
+		"TOML file with [log] and [http] settings, re-read on SIGHUP or, with --admin-token, POST /admin/reload")
+	cmd.Flags().StringSliceVar(&origins, "cors-origin", []string{"*"}, "allowed CORS origins")
+	cmd.Flags().StringVar(&basePath, "base-path", "", "serve every route under this prefix, e.g. /agent-tools")
+	cmd.Flags().BoolVar(&proxied, "trust-proxy", false,
+		"build generated URLs from X-Forwarded-Proto/Host (only behind a proxy that sets them)")
 	cmd.Flags().StringVar(&dbPath, "db", "./data/agent-tools.db", "SQLite database path")
+	cmd.Flags().DurationVar(&slowQuery, "slow-query", 250*time.Millisecond, "log SQL statements slower than this (0 disables)")
+	cmd.Flags().DurationVar(&invBatch, "invocation-batch", 5*time.Millisecond,
+		"group-commit new invocation records of free tools this often (0 inserts each one before invoking)")
+	cmd.Flags().DurationVar(&rollup, "rollup-interval", 5*time.Minute,
+		"how often to aggregate daily usage and purge expired cached results (0 disables)")
+	cmd.Flags().StringToStringVar(&peerURLs, "peer", nil,
+		"peer registries to mirror into search results, e.g. eu=https://eu.example.com (repeatable)")
+	cmd.Flags().DurationVar(&fedSync, "federation-interval", 10*time.Minute, "how often to re-sync peer catalogs (0 disables)")
+	cmd.Flags().StringVar(&logOpts.Level, "log-level", "info", "log level: debug, info, warn, error")
+	cmd.Flags().StringVar(&logOpts.Format, "log-format", "json", "log format: json or console")
+	cmd.Flags().StringToStringVar(&logOpts.Levels, "log-component-level", nil,
+		"per-component log levels, e.g. registry=debug,http=warn (components: http, registry, store)")
+	cmd.Flags().BoolVar(&accessLog, "access-log", true, "log every HTTP request")
+	cmd.Flags().StringVar(&accessOut, "access-log-file", "", "write access logs to this file instead of the application log")
+	cmd.Flags().Int64Var(&accessRot.MaxBytes, "access-log-max-bytes", 100<<20, "rotate the access log file at this size (0 disables)")
+	cmd.Flags().DurationVar(&accessRot.Interval, "access-log-rotate", 24*time.Hour, "rotate the access log file after this long (0 disables)")
+	cmd.Flags().IntVar(&accessRot.MaxBackups, "access-log-max-backups", 30, "rotated access log files to keep (0 keeps all)")
+	cmd.Flags().DurationVar(&grace, "shutdown-grace", 30*time.Second, "how long to wait for in-flight invocations on shutdown")
+	cmd.Flags().StringVar(&coordDSN, "coord-dsn", "",
+		"Postgres DSN shared by all replicas; background jobs take advisory locks so only one replica runs each")
+	cmd.Flags().StringVar(&instance, "instance-id", "", "replica identifier (defaults to hostname-pid when --coord-dsn is set)")
+	cmd.Flags().StringVar(&anon.Mode, "anonymous", anon.Mode,
+		"what callers without Authorization may do: off, read-only (search and get) or full")
+	cmd.Flags().IntVar(&anon.PerMinute, "anonymous-rate", anon.PerMinute, "anonymous requests per minute per client IP (0 disables)")
+	cmd.Flags().IntVar(&anon.Burst, "anonymous-burst", anon.Burst, "anonymous request burst per client IP")
+	cmd.Flags().StringVar(&adminTok, "admin-token", "",
+		"shared secret for /admin endpoints, sent as X-Admin-Token (default $AGENT_TOOLS_ADMIN_TOKEN)")
+	cmd.Flags().StringVar(&maintMode, "maintenance", api.MaintenanceOff,
+		"switch every replica to a maintenance mode on start: off, read-only or full")
+	cmd.Flags().IntVar(&limits.MaxSchemaBytes, "max-schema-bytes", limits.MaxSchemaBytes,
+		"largest accepted tool input/output schema (0 disables)")
+	cmd.Flags().IntVar(&limits.MaxDepth, "max-json-depth", limits.MaxDepth, "deepest accepted JSON nesting in schemas and inputs (0 disables)")
+	cmd.Flags().IntVar(&limits.MaxInputBytes, "max-input-bytes", limits.MaxInputBytes, "largest accepted invocation input (0 disables)")
+	cmd.Flags().IntVar(&limits.MaxPayloadBytes, "max-payload-bytes", limits.MaxPayloadBytes,
+		"largest invocation input or output stored when a consumer asks for it (0 disables)")
+	cmd.Flags().DurationVar(&slaCheck, "sla-check-interval", time.Minute,
+		"how often endpoints of tools with an SLA are probed and their compliance recomputed (0 disables)")
+	cmd.Flags().DurationVar(&confCheck, "conformance-interval", time.Hour,
+		"how often the conformance tests of tools are run (0 disables)")
+	cmd.Flags().DurationVar(&reapEvery, "reap-interval", time.Minute,
+		"how often invocations left pending by a crashed replica are failed and refunded (0 disables)")
+	cmd.Flags().DurationVar(&reapGrace, "reap-grace", registry.DefaultReapGrace,
+		"how long a pending invocation may outlive its tool's timeout and retries before it is reaped")
+	cmd.Flags().DurationVar(&notice, "price-notice", registry.DefaultPriceNotice,
+		"how long a version raising its tool's price is charged the old price, announced by a tool.pricing_changed event (0 disables)")
+	cmd.Flags().StringVar(&chainWS, "clawchain-ws", "",
+		"anchor receipt batches on the ClawChain node with this websocket RPC URL (default [clawchain] ws_url of --config)")
+	cmd.Flags().StringVar(&chainCall, "clawchain-anchor-call", "",
+		"pallet and call index of the chain's anchoring call, e.g. 0x2a00 (default [clawchain] anchor_call of --config)")
+	cmd.Flags().DurationVar(&anchorInt, "anchor-interval", 10*time.Minute, "how often to anchor new receipts on ClawChain (0 disables)")
+	cmd.Flags().StringVar(&xferCall, "clawchain-transfer-call", "",
+		"settle paid invocations in CLAW with the chain's transfer_keep_alive call at this pallet and call index, e.g. 0x0603 "+
+			"(default [clawchain] transfer_call of --config)")
+	cmd.Flags().StringVar(&escrowKey, "escrow-key-file", "",
+		"Ed25519 key the consumers' escrow accounts are derived from when payments are settled on ClawChain (default $AGENT_TOOLS_ESCROW_KEY)")
+	cmd.Flags().DurationVar(&settleInt, "settle-interval", time.Minute,
+		"how often the escrows of ended invocations are released to providers or refunded (0 disables)")
+	cmd.Flags().DurationVar(&repCfg.HalfLife, "reputation-half-life", repCfg.HalfLife,
+		"age at which a provider's invocations and SLA violations count half towards its reputation")
+	cmd.Flags().DurationVar(&repCfg.InactiveHalfLife, "reputation-inactive-half-life", repCfg.InactiveHalfLife,
+		"how long an inactive provider takes to lose half of a positive reputation")
+	cmd.Flags().DurationVar(&payTTL, "payload-retention", registry.DefaultPayloadRetention,
+		"how long stored invocation payloads are kept before they are purged")
+	cmd.Flags().Float64Var(&warnAt, "warn-at", registry.DefaultWarnThreshold,
+		"share of a tool quota or invocation budget at which consumers are sent a quota.warning event")
+	cmd.Flags().StringVar(&routeBy, "route-strategy", registry.DefaultRouteStrategy,
+		"how POST /v1/invoke picks among the tools offering a capability when the request names no strategy: "+
+			"cheapest, lowest_latency or highest_reputation")
+	cmd.Flags().IntVar(&breaker.Failures, "breaker-failures", breaker.Failures,
+		"consecutive failures or timeouts of a provider endpoint that open its circuit (0 disables)")
+	cmd.Flags().DurationVar(&breaker.Cooldown, "breaker-cooldown", breaker.Cooldown,
+		"how long an open circuit fails invocations fast before probing the endpoint again")
+	cmd.Flags().IntVar(&jobWork, "async-workers", 4, "workers running async invocations (0 disables POST /v1/invoke?mode=async)")
+	cmd.Flags().Float64Var(&jobShares.Normal, "async-normal-share", jobShares.Normal,
+		"share of the async workers normal and low priority invocations may occupy")
+	cmd.Flags().Float64Var(&jobShares.Low, "async-low-share", jobShares.Low, "share of the async workers low priority invocations may occupy")
+	cmd.Flags().DurationVar(&jobTTL, "job-retention", 24*time.Hour, "how long the results of async invocations are kept for polling")
+	cmd.Flags().IntVar(&hookWork, "webhook-workers", 2,
+		"workers delivering async invocation callbacks (0 disables callback_url; needs --secrets-key-file)")
+	cmd.Flags().IntVar(&hookCfg.Attempts, "webhook-attempts", hookCfg.Attempts, "deliveries of a callback tried before it is marked failed")
+	cmd.Flags().DurationVar(&hookCfg.Backoff, "webhook-backoff", hookCfg.Backoff,
+		"wait before retrying a failed callback delivery, doubled after each further failure up to an hour")
+	cmd.Flags().BoolVar(&detect, "abuse-detection", true, "flag and throttle consumers with anomalous traffic for admin review")
+	cmd.Flags().DurationVar(&abuseCfg.Window, "abuse-window", abuseCfg.Window, "window over which catalog reads and bad requests are counted")
+	cmd.Flags().IntVar(&abuseCfg.CatalogReads, "abuse-catalog-reads", abuseCfg.CatalogReads,
+		"distinct catalog URLs a consumer may fetch per window (0 disables)")
+	cmd.Flags().IntVar(&abuseCfg.BadRequests, "abuse-bad-requests", abuseCfg.BadRequests,
+		"rejected requests a consumer may send per window (0 disables)")
+	cmd.Flags().Float64Var(&abuseCfg.SpendFactor, "abuse-spend-factor", abuseCfg.SpendFactor,
+		"flag consumers spending more than this multiple of their 7-day daily average (0 disables)")
+	cmd.Flags().Float64Var(&abuseCfg.MinSpend, "abuse-min-spend", abuseCfg.MinSpend, "daily CLAW spend below which spikes are ignored")
+	cmd.Flags().StringVar(&keyFile, "secrets-key-file", "",
+		"base64 AES-256 key encrypting provider endpoint credentials and stored payloads (default $AGENT_TOOLS_SECRETS_KEY)")
+	cmd.Flags().StringVar(&regKey, "registry-key-file", "",
+		"Ed25519 key signing the calls proxied to providers, published at /.well-known/registry-key (default $AGENT_TOOLS_REGISTRY_KEY)")
+	cmd.Flags().StringVar(&regDID, "registry-did", "",
+		"the registry's own DID, served at /.well-known/did.json (default the did:web of the URL it is reached at)")
+	cmd.Flags().BoolVar(&signed, "require-signed-manifests", false,
+		"reject tool registrations without a manifest_signature from the provider's key")
+	cmd.Flags().BoolVar(&nonces, "require-nonces", false,
+		"reject signed tool registrations and consumer signatures without a nonce, which is otherwise only checked when given")
+	cmd.Flags().BoolVar(&respSigs, "require-response-signatures", false,
+		"fail proxied invocations whose provider did not sign the receipt in X-Provider-Signature, which is otherwise only checked when given")
+	cmd.Flags().BoolVar(&keyProof, "require-key-proof", true,
+		"reject provider registrations without a signed challenge proving the provider holds its pubkey")
+	cmd.Flags().BoolVar(&rbac, "rbac", true,
+		"only let providers authenticated with a proven API key register and change tools; see POST /v1/providers/{id}/keys")
+	cmd.Flags().BoolVar(&wasm, "wasm", false, "run tools uploaded as WebAssembly modules (wasm:// endpoints) in a sandbox")
+	cmd.Flags().IntVar(&wasmMemMB, "wasm-memory-mb", int(wasmLim.MemoryPages)*sandbox.PageSize>>20, "memory limit of each wasm instance")
+	cmd.Flags().DurationVar(&wasmLim.Timeout, "wasm-timeout", wasmLim.Timeout, "execution budget of a wasm invocation")
+	cmd.Flags().IntVar(&wasmLim.MaxModuleBytes, "wasm-max-module-bytes", wasmLim.MaxModuleBytes, "largest accepted wasm module upload")
+	cmd.Flags().IntVar(&wasmLim.MaxOutputBytes, "wasm-max-output-bytes", wasmLim.MaxOutputBytes, "largest accepted wasm tool output")
+	cmd.Flags().BoolVar(&ctrs, "containers", false, "run tools packaged as OCI images (oci:// endpoints) in ephemeral containers")
+	cmd.Flags().StringVar(&ctrCLI.Path, "container-cli", "docker", "Docker-compatible CLI that starts containers: docker, podman or nerdctl")
+	cmd.Flags().StringVar(&ctrCLI.OCIRuntime, "container-runtime", "",
+		"OCI runtime for tool containers, e.g. runsc (gVisor) or kata-fc (Firecracker); empty uses the CLI default")
+	cmd.Flags().Float64Var(&ctrLim.CPUs, "container-cpus", ctrLim.CPUs, "CPUs available to each tool container")
+	cmd.Flags().Int64Var(&ctrMemMB, "container-memory-mb", ctrLim.MemoryBytes>>20, "memory limit of each tool container")
+	cmd.Flags().IntVar(&ctrLim.PIDs, "container-pids", ctrLim.PIDs, "process limit of each tool container")
+	cmd.Flags().DurationVar(&ctrLim.Timeout, "container-timeout", ctrLim.Timeout,
+		"execution budget of a container invocation, including start")
+	cmd.Flags().BoolVar(&ctrLim.Network, "container-network", false, "give tool containers network access (default none)")
+	cmd.Flags().StringSliceVar(&guard.Schemes, "endpoint-schemes", nil,
+		"only accept tool and provider endpoints with these URL schemes, e.g. https,grpc (default any)")
+	cmd.Flags().IntSliceVar(&guard.Ports, "endpoint-ports", nil, "only accept endpoints on these ports (default any)")
+	cmd.Flags().BoolVar(&guard.BlockPrivate, "endpoint-block-private", false,
+		"refuse endpoints on loopback and private networks; link-local and metadata addresses are always refused")
+	cmd.Flags().StringVar(&seedPath, "seed", "", "load providers and tools from this JSON fixtures file on start (duplicates are skipped)")
+	cmd.Flags().IntVar(&seedFake, "seed-fake", 0, "development: register this many generated tools on start")
+	cmd.Flags().StringVar(&evSource, "event-source", events.DefaultSource,
+		"CloudEvents source of registry events, e.g. the registry's public URL")
+	cmd.Flags().StringVar(&ipfsAPI, "ipfs-api", "",
+		"pin tool manifests to the IPFS node with this Kubo RPC API, e.g. http://127.0.0.1:5001")
+	cmd.Flags().StringVar(&casDir, "cas-dir", "", "pin tool manifests as files named by CID in this directory")
+	cmd.Flags().StringVar(&sentryDSN, "sentry-dsn", "", "report 5xx responses and panics to this Sentry-compatible DSN")
 
 	return cmd
 }
+
+// shutdown drains in-flight invocations for up to grace, stops the HTTP
+// server, and marks any invocation still pending as interrupted. The caller
+// closes the database afterwards.
+func shutdown(log *zap.Logger, srv *http.Server, handler *api.Handler, reg *registry.Registry, grace time.Duration) error {
+	log.Info("shutting down", zap.Duration("grace", grace))
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := handler.Drain(ctx); err != nil {
+		log.Warn("in-flight invocations did not finish before grace period", zap.Error(err))
+	}
+	// End open event streams, which would otherwise hold Shutdown until the
+	// grace period expires.
+	reg.Events().Close()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warn("http shutdown", zap.Error(err))
+	}
+
+	// Use a fresh context: the grace period may already have expired.
+	markCtx, markCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer markCancel()
+	if _, err := reg.InterruptPendingInvocations(markCtx, "interrupted by server shutdown"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// seedRegistry applies the --seed fixtures file and --seed-fake tools.
+func seedRegistry(ctx context.Context, log *zap.Logger, reg *registry.Registry, path string, fake int) error {
+	var sets []*seed.Fixtures
+	if path != "" {
+		f, err := seed.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, f)
+	}
+	if fake > 0 {
+		sets = append(sets, seed.Fake(fake, 1))
+	}
+	for _, f := range sets {
+		sum, err := seed.Load(ctx, reg, f)
+		if err != nil {
+			return err
+		}
+		log.Info("seeded registry", zap.Int("providers", sum.Providers),
+			zap.Int("tools", sum.Tools), zap.Int("skipped", sum.Skipped))
+	}
+	return nil
+}
+
+// openSecrets loads the key for provider endpoint credentials from path, or
+// from $AGENT_TOOLS_SECRETS_KEY. With neither set, endpoint auth is disabled.
+func openSecrets(path string) (*secrets.Box, error) {
+	var (
+		key []byte
+		err error
+	)
+	switch env := os.Getenv("AGENT_TOOLS_SECRETS_KEY"); {
+	case path != "":
+		key, err = secrets.ReadKeyFile(path)
+	case env != "":
+		key, err = secrets.ParseKey(env)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secrets.New(key)
+}
+
+// openSigningKey returns the key what names, read from path or the
+// environment variable env, or nil when neither is set.
+func openSigningKey(path, env, what string) (ed25519.PrivateKey, error) {
+	s := os.Getenv(env)
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", what, err)
+		}
+		s = string(b)
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return agenttools.ParseSigningKey(s)
+}
+
+// openCAS returns the content-addressed store tool manifests are pinned
+// to, or nil when neither an IPFS node nor a directory is configured.
+func openCAS(ipfsAPI, dir string) (cas.Store, error) {
+	switch {
+	case ipfsAPI != "" && dir != "":
+		return nil, fmt.Errorf("--ipfs-api and --cas-dir are mutually exclusive")
+	case ipfsAPI != "":
+		return cas.NewIPFS(ipfsAPI, &http.Client{Timeout: 30 * time.Second}), nil
+	case dir != "":
+		return cas.NewDir(dir)
+	}
+	return nil, nil
+}
+
+// openClawchain returns the client receipts are anchored with and the
+// wallet payments are settled with, configured by the flags or else the
+// [clawchain] section of the config file at cfgPath, which alone sets the
+// existential deposit and transfer fee the wallet keeps back. Each is nil
+// when the call it makes is not configured, and both when no node is.
+func openClawchain(wsURL, anchorCall, transferCall, cfgPath string) (*clawchain.Client, *clawchain.Wallet, error) {
+	var deposit, fee string
+	if cfgPath != "" {
+		s, err := loadSettings(cfgPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		deposit, fee = s.Clawchain.ExistentialDeposit, s.Clawchain.TransferFee
+		if wsURL == "" {
+			wsURL = s.Clawchain.WSURL
+		}
+		if anchorCall == "" {
+			anchorCall = s.Clawchain.AnchorCall
+		}
+		if transferCall == "" {
+			transferCall = s.Clawchain.TransferCall
+		}
+	}
+	switch {
+	case wsURL == "":
+		return nil, nil, nil
+	case anchorCall == "" && transferCall == "":
+		return nil, nil, errors.New("ClawChain needs the pallet and call index of its anchoring call, its transfer call or both: " +
+			"pass --clawchain-anchor-call or --clawchain-transfer-call, or set [clawchain] anchor_call or transfer_call")
+	}
+	c, err := clawchain.New(wsURL, anchorCall)
+	if err != nil {
+		return nil, nil, err
+	}
+	var anchorer *clawchain.Client
+	if anchorCall != "" {
+		anchorer = c
+	}
+	if transferCall == "" {
+		return anchorer, nil, nil
+	}
+	w, err := clawchain.NewWallet(c, transferCall)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.ExistentialDeposit, w.TransferFee = cmp.Or(deposit, w.ExistentialDeposit), cmp.Or(fee, w.TransferFee)
+	for _, kv := range [][2]string{{"existential_deposit", w.ExistentialDeposit}, {"transfer_fee", w.TransferFee}} {
+		if a, ok := new(big.Rat).SetString(kv[1]); !ok || a.Sign() < 0 || strings.ContainsAny(kv[1], "/eE") {
+			return nil, nil, fmt.Errorf("[clawchain] %s %q is not a decimal amount of CLAW", kv[0], kv[1])
+		}
+	}
+	return anchorer, w, nil
+}
+
+// openLocker returns the job coordination lock for this replica: Postgres
+// advisory locks when dsn is set, an in-process lock otherwise.
+func openLocker(dsn string) (coord.Locker, func(), error) {
+	if dsn == "" {
+		return coord.NewLocal(), func() {}, nil
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open coordination db: %w", err)
+	}
+	return coord.NewPostgres(db), func() { _ = db.Close() }, nil
+}
+
+// defaultInstanceID identifies this process among replicas.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -1,8 +1,16 @@
 package cli_test
 
 import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/clawinfra/agent-tools/internal/cli"
 	"github.com/stretchr/testify/assert"
@@ -31,4 +39,233 @@ func TestServeCmd_Structure(t *testing.T) {
 	assert.Equal(t, "serve", serveCmd.Use)
 	assert.NotNil(t, serveCmd.Flag("addr"))
 	assert.NotNil(t, serveCmd.Flag("db"))
+	assert.NotNil(t, serveCmd.Flag("slow-query"))
+}
+
+func TestServeCmd_GracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{
+		"serve", "--addr", "127.0.0.1:0", "--db", t.TempDir() + "/agent-tools.db",
+		"--shutdown-grace", "100ms", "--log-level", "error",
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- root.ExecuteContext(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not shut down")
+	}
+}
+
+func TestServeCmd_Config(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		body string
+		want string
+	}{
+		"bad level":         {body: "[log]\nlevel = \"chatty\"\n", want: "log level"},
+		"unknown component": {body: "[log.components]\ngpu = \"debug\"\n", want: "unknown log component"},
+		"bad toml":          {body: "[log\n", want: "read config"},
+		"bad anonymous":     {body: "[anonymous]\nmode = \"sometimes\"\n", want: "unknown anonymous mode"},
+		"negative rate":     {body: "[anonymous]\nrate = -1\n", want: "must not be negative"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".toml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.body), 0o600))
+			root := cli.NewRootCmd()
+			root.SetArgs([]string{"serve", "--addr", "127.0.0.1:0", "--db", filepath.Join(dir, "db"), "--config", path})
+			assert.ErrorContains(t, root.Execute(), tc.want)
+		})
+	}
+
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--db", filepath.Join(dir, "db"), "--config", filepath.Join(dir, "missing.toml")})
+	assert.ErrorContains(t, root.Execute(), "read config")
+}
+
+func TestServeCmd_UnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "at") // short path: sun_path is limited to ~100 bytes
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "agent-tools.sock")
+	// A stale socket from a previous run is replaced.
+	stale, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{
+		"serve", "--listen", "unix://" + sock, "--db", filepath.Join(dir, "db"),
+		"--shutdown-grace", "100ms", "--log-level", "error",
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- root.ExecuteContext(ctx) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://agent-tools/healthz", http.NoBody)
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not shut down")
+	}
+}
+
+func TestServeCmd_InvalidListen(t *testing.T) {
+	// Not socket-activated: there are no descriptors to take over.
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+	for spec, want := range map[string]string{
+		"udp://:53":           "want unix:///path or tcp://host:port",
+		"unix://":             "unix socket path is empty",
+		"tcp://127.0.0.1:bad": "unknown port",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			root := cli.NewRootCmd()
+			root.SetArgs([]string{"serve", "--listen", spec, "--db", filepath.Join(t.TempDir(), "db")})
+			assert.ErrorContains(t, root.Execute(), want)
+		})
+	}
+}
+
+func TestServeCmd_ListenRefusesNonSocket(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "not-a-socket")
+	require.NoError(t, os.WriteFile(path, []byte("keep me"), 0o600))
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{"serve", "--listen", "unix://" + path, "--db", filepath.Join(dir, "db")})
+	assert.ErrorContains(t, root.Execute(), "is not a socket")
+}
+
+func TestServeCmd_Features(t *testing.T) {
+	dir := t.TempDir()
+	secretsKey := filepath.Join(dir, "secrets.key")
+	require.NoError(t, os.WriteFile(secretsKey, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0o600))
+	signingKey := filepath.Join(dir, "registry.key")
+	require.NoError(t, os.WriteFile(signingKey, []byte("ed25519:"+strings.Repeat("01", 32)), 0o600))
+	cfg := filepath.Join(dir, "agent-tools.toml")
+	require.NoError(t, os.WriteFile(cfg, []byte("[clawchain]\nexistential_deposit = \"0.5\"\n"), 0o600))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	root := cli.NewRootCmd()
+	root.SetArgs([]string{
+		"serve", "--addr", addr, "--db", filepath.Join(dir, "db"), "--config", cfg,
+		"--shutdown-grace", "100ms", "--log-level", "error",
+		"--secrets-key-file", secretsKey, "--registry-key-file", signingKey, "--registry-did", "did:web:registry.example",
+		"--admin-token", "s3cret", "--cas-dir", filepath.Join(dir, "cas"), "--access-log-file", filepath.Join(dir, "access.log"),
+		"--sentry-dsn", "http://public@127.0.0.1:9/1", "--wasm", "--containers", "--maintenance", "read-only",
+		"--peer", "eu=http://127.0.0.1:9", "--seed-fake", "2",
+		"--clawchain-ws", "ws://127.0.0.1:9", "--clawchain-anchor-call", "0x2a00", "--clawchain-transfer-call", "0x0603",
+		"--escrow-key-file", signingKey,
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- root.ExecuteContext(ctx) }()
+
+	get := func(path string) int {
+		resp, err := http.Get("http://" + addr + path) //nolint:noctx // test
+		if err != nil {
+			return 0
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Eventually(t, func() bool { return get("/healthz") == http.StatusOK }, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, http.StatusOK, get("/.well-known/registry-key"))
+	assert.Equal(t, http.StatusOK, get("/v1/tools"), "read-only maintenance still serves reads")
+
+	reload := func() int {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+"/admin/reload", http.NoBody)
+		req.Header.Set("X-Admin-Token", "s3cret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.NoError(t, os.WriteFile(cfg, []byte(`[log]
+level = "warn"
+[log.components]
+http = "debug"
+[http]
+cors_origins = ["https://app.example"]
+[reputation]
+half_life = "240h"
+inactive_half_life = "720h"
+[anonymous]
+mode = "read-only"
+rate = 10
+burst = 5
+[webhooks]
+attempts = 3
+backoff = "2s"
+`), 0o600))
+	assert.Equal(t, http.StatusOK, reload())
+	require.NoError(t, os.WriteFile(cfg, []byte("[webhooks]\nattempts = -1\n"), 0o600))
+	assert.Equal(t, http.StatusInternalServerError, reload(), "an invalid file leaves the settings as they are")
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not shut down")
+	}
+}
+
+func TestServeCmd_InvalidFeatures(t *testing.T) {
+	dir := t.TempDir()
+	signingKey := filepath.Join(dir, "registry.key")
+	require.NoError(t, os.WriteFile(signingKey, []byte("ed25519:"+strings.Repeat("01", 32)), 0o600))
+	badDeposit := filepath.Join(dir, "deposit.toml")
+	require.NoError(t, os.WriteFile(badDeposit, []byte("[clawchain]\nexistential_deposit = \"-1\"\n"), 0o600))
+	chain := []string{"--clawchain-ws", "ws://127.0.0.1:9", "--clawchain-transfer-call", "0x0603"}
+	for name, tc := range map[string]struct {
+		args []string
+		want string
+	}{
+		"no escrow key":        {chain, "needs the key escrow accounts are derived from"},
+		"bad deposit":          {append([]string{"--config", badDeposit}, chain...), "is not a decimal amount of CLAW"},
+		"no chain call":        {[]string{"--clawchain-ws", "ws://127.0.0.1:9"}, "needs the pallet and call index"},
+		"bad chain url":        {[]string{"--clawchain-ws", "http://node", "--clawchain-anchor-call", "0x2a00"}, "must be ws://"},
+		"bad transfer call":    {[]string{"--clawchain-ws", "ws://node", "--clawchain-transfer-call", "0x06"}, "transfer call"},
+		"unknown strategy":     {[]string{"--route-strategy", "random"}, "--route-strategy must be one of"},
+		"two content stores":   {[]string{"--ipfs-api", "http://127.0.0.1:5001", "--cas-dir", dir}, "mutually exclusive"},
+		"missing secrets key":  {[]string{"--secrets-key-file", filepath.Join(dir, "missing")}, "read secrets key"},
+		"missing registry key": {[]string{"--registry-key-file", filepath.Join(dir, "missing")}, "read registry key"},
+		"missing seed":         {[]string{"--seed", filepath.Join(dir, "missing.json")}, "read fixtures"},
+		"bad peer":             {[]string{"--peer", "eu=ftp://peer"}, "invalid url"},
+		"bad maintenance":      {[]string{"--maintenance", "sometimes"}, "unknown maintenance mode"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := cli.NewRootCmd()
+			root.SetArgs(append([]string{"serve", "--addr", "127.0.0.1:0", "--db", filepath.Join(dir, "db"), "--log-level", "error"},
+				tc.args...))
+			assert.ErrorContains(t, root.Execute(), tc.want)
+		})
+	}
 }
@@ -0,0 +1,58 @@
+// Package coord coordinates background jobs between registry replicas so
+// that periodic work (rollups, health checks, reapers) runs on one replica
+// at a time. It does not share request state: rate limits, abuse counters
+// and circuit breakers stay per replica.
+package coord
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clawinfra/agent-tools/internal/worker"
+)
+
+// Locker grants named, non-blocking exclusive locks.
+type Locker interface {
+	// TryLock acquires name if it is free. When ok is false the lock is held
+	// elsewhere and release is nil.
+	TryLock(ctx context.Context, name string) (release func(), ok bool, err error)
+}
+
+// Local is an in-process Locker for single-replica deployments.
+type Local struct {
+	held map[string]bool
+	mu   sync.Mutex
+}
+
+// NewLocal creates a Local locker.
+func NewLocal() *Local {
+	return &Local{held: make(map[string]bool)}
+}
+
+// TryLock implements Locker.
+func (l *Local) TryLock(_ context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return func() {
+		l.mu.Lock()
+		delete(l.held, name)
+		l.mu.Unlock()
+	}, true, nil
+}
+
+// Exclusive wraps job so that each run first takes the named lock and is
+// skipped when another replica holds it.
+func Exclusive(l Locker, name string, job worker.Job) worker.Job {
+	return func(ctx context.Context) error {
+		release, ok, err := l.TryLock(ctx, name)
+		if err != nil || !ok {
+			return err
+		}
+		defer release()
+		return job(ctx)
+	}
+}
@@ -0,0 +1,67 @@
+package coord_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/coord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_TryLock(t *testing.T) {
+	l := coord.NewLocal()
+	ctx := context.Background()
+
+	release, ok, err := l.TryLock(ctx, "job")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok, err = l.TryLock(ctx, "job")
+	require.NoError(t, err)
+	assert.False(t, ok, "lock is already held")
+
+	_, ok, err = l.TryLock(ctx, "other")
+	require.NoError(t, err)
+	assert.True(t, ok, "locks are independent by name")
+
+	release()
+	_, ok, err = l.TryLock(ctx, "job")
+	require.NoError(t, err)
+	assert.True(t, ok, "lock is free after release")
+}
+
+func TestExclusive_SkipsWhenHeld(t *testing.T) {
+	l := coord.NewLocal()
+	ctx := context.Background()
+	runs := 0
+	job := coord.Exclusive(l, "rollup", func(context.Context) error {
+		runs++
+		return nil
+	})
+
+	require.NoError(t, job(ctx))
+	assert.Equal(t, 1, runs)
+
+	release, ok, err := l.TryLock(ctx, "rollup")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, job(ctx))
+	assert.Equal(t, 1, runs, "job must not run while another holder has the lock")
+
+	release()
+	require.NoError(t, job(ctx))
+	assert.Equal(t, 2, runs)
+}
+
+func TestExclusive_ReleasesAfterError(t *testing.T) {
+	l := coord.NewLocal()
+	boom := errors.New("boom")
+	job := coord.Exclusive(l, "rollup", func(context.Context) error { return boom })
+
+	require.ErrorIs(t, job(context.Background()), boom)
+	_, ok, err := l.TryLock(context.Background(), "rollup")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
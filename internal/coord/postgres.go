@@ -0,0 +1,51 @@
+package coord
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// Postgres is a Locker backed by Postgres session-level advisory locks, for
+// deployments running several replicas against a shared Postgres. Locks are
+// released automatically if the holding replica's connection drops.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres creates a Postgres locker. db must use a Postgres driver.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// TryLock implements Locker. The lock is tied to a dedicated connection,
+// which is returned to the pool on release.
+func (p *Postgres) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("advisory lock conn: %w", err)
+	}
+	key := lockKey(name)
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("advisory lock %s: %w", name, err)
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		_ = conn.Close()
+	}, true, nil
+}
+
+// lockKey maps a lock name onto the int64 key space of advisory locks.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("agent-tools:" + name))
+	return int64(h.Sum64()) //nolint:gosec // wrap-around is fine for a hash key
+}
@@ -0,0 +1,145 @@
+package coord_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/coord"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// advisoryDriver fakes the session-level advisory locks of Postgres: each
+// connection is a session, and locks held by a session are released when
+// it closes.
+type advisoryDriver struct {
+	mu   sync.Mutex
+	held map[int64]*advisoryConn
+	fail error
+}
+
+func (d *advisoryDriver) Open(string) (driver.Conn, error) {
+	return &advisoryConn{d: d}, nil
+}
+
+type advisoryConn struct{ d *advisoryDriver }
+
+func (c *advisoryConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *advisoryConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *advisoryConn) Close() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	for key, owner := range c.d.held {
+		if owner == c {
+			delete(c.d.held, key)
+		}
+	}
+	return nil
+}
+
+func (c *advisoryConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.d.fail != nil {
+		return nil, c.d.fail
+	}
+	if query != "SELECT pg_try_advisory_lock($1)" {
+		return nil, errors.New("unexpected query " + query)
+	}
+	key := args[0].Value.(int64)
+	owner, taken := c.d.held[key]
+	ok := !taken || owner == c
+	if ok {
+		c.d.held[key] = c
+	}
+	return &boolRows{v: ok}, nil
+}
+
+func (c *advisoryConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if query != "SELECT pg_advisory_unlock($1)" {
+		return nil, errors.New("unexpected query " + query)
+	}
+	if key := args[0].Value.(int64); c.d.held[key] == c {
+		delete(c.d.held, key)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type boolRows struct {
+	v    bool
+	done bool
+}
+
+func (r *boolRows) Columns() []string { return []string{"ok"} }
+func (r *boolRows) Close() error      { return nil }
+
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done, dest[0] = true, r.v
+	return nil
+}
+
+func openAdvisory(t *testing.T) (*sql.DB, *advisoryDriver) {
+	t.Helper()
+	d := &advisoryDriver{held: map[int64]*advisoryConn{}}
+	db := sql.OpenDB(connector{d})
+	t.Cleanup(func() { _ = db.Close() })
+	return db, d
+}
+
+type connector struct{ d *advisoryDriver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
+
+func TestPostgres_TryLock(t *testing.T) {
+	db, d := openAdvisory(t)
+	ctx := context.Background()
+	// Two replicas sharing one Postgres.
+	a, b := coord.NewPostgres(db), coord.NewPostgres(db)
+
+	release, ok, err := a.TryLock(ctx, "rollup")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = b.TryLock(ctx, "rollup")
+	require.NoError(t, err)
+	assert.False(t, ok, "the lock is held by another session")
+	releaseOther, ok, err := b.TryLock(ctx, "reaper")
+	require.NoError(t, err)
+	assert.True(t, ok, "locks are independent by name")
+
+	release()
+	release2, ok, err := b.TryLock(ctx, "rollup")
+	require.NoError(t, err)
+	assert.True(t, ok, "the lock is free after release")
+	release2()
+	releaseOther()
+
+	d.mu.Lock()
+	held := len(d.held)
+	d.mu.Unlock()
+	assert.Zero(t, held)
+}
+
+func TestPostgres_TryLockError(t *testing.T) {
+	db, d := openAdvisory(t)
+	d.fail = errors.New("connection reset")
+	release, ok, err := coord.NewPostgres(db).TryLock(context.Background(), "rollup")
+	assert.ErrorContains(t, err, "connection reset")
+	assert.False(t, ok)
+	assert.Nil(t, release)
+
+	require.NoError(t, db.Close())
+	_, _, err = coord.NewPostgres(db).TryLock(context.Background(), "rollup")
+	assert.Error(t, err)
+}
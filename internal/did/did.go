@@ -0,0 +1,229 @@
+// Package did resolves the verification keys of did:key and did:web
+// identifiers, so providers and consumers with an existing decentralized
+// identity can sign for themselves without registering a separate key.
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned for a DID method, or a key type, the
+	// resolver does not handle.
+	ErrUnsupported = errors.New("unsupported did")
+	// ErrResolve is returned when a DID cannot be resolved or its document
+	// is invalid.
+	ErrResolve = errors.New("did resolution failed")
+)
+
+// ed25519Multicodec prefixes an Ed25519 public key in multicodec form.
+var ed25519Multicodec = []byte{0xed, 0x01}
+
+const (
+	// maxDocument bounds a did:web document.
+	maxDocument = 256 << 10
+	// cacheTTL is how long resolved did:web keys are reused.
+	cacheTTL = 5 * time.Minute
+)
+
+// Resolvable reports whether id is a did:key or did:web identifier.
+func Resolvable(id string) bool {
+	return strings.HasPrefix(id, "did:key:") || strings.HasPrefix(id, "did:web:")
+}
+
+// Resolver resolves DIDs to their Ed25519 verification keys.
+type Resolver struct {
+	hc    *http.Client
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	keys    []ed25519.PublicKey
+	expires time.Time
+}
+
+// NewResolver returns a resolver that fetches did:web documents with hc.
+func NewResolver(hc *http.Client) *Resolver {
+	return &Resolver{hc: hc, cache: map[string]cached{}}
+}
+
+// Keys returns the Ed25519 verification keys of id.
+func (r *Resolver) Keys(ctx context.Context, id string) ([]ed25519.PublicKey, error) {
+	switch {
+	case strings.HasPrefix(id, "did:key:"):
+		key, err := KeyFromDIDKey(id)
+		if err != nil {
+			return nil, err
+		}
+		return []ed25519.PublicKey{key}, nil
+	case strings.HasPrefix(id, "did:web:"):
+		return r.webKeys(ctx, id)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, id)
+}
+
+// KeyFromDIDKey decodes the Ed25519 key a did:key identifier consists of.
+func KeyFromDIDKey(id string) (ed25519.PublicKey, error) {
+	mb, ok := strings.CutPrefix(id, "did:key:")
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a did:key", ErrUnsupported, id)
+	}
+	// A fragment names the key within the implied document; it is the key.
+	mb, _, _ = strings.Cut(mb, "#")
+	return multibaseKey(mb)
+}
+
+// DIDKey returns the did:key identifier of an Ed25519 key.
+func DIDKey(key ed25519.PublicKey) string {
+	return "did:key:" + Multibase(key)
+}
+
+// Multibase returns an Ed25519 key in the base58btc multibase, multicodec
+// form of publicKeyMultibase and did:key identifiers.
+func Multibase(key ed25519.PublicKey) string {
+	return "z" + base58Encode(append(append([]byte{}, ed25519Multicodec...), key...))
+}
+
+// AgentPrefix starts the registry's own agent identifiers.
+const AgentPrefix = "did:claw:agent:"
+
+// AgentID returns the did:claw:agent identifier of an Ed25519 key: the
+// base58 of the first 16 bytes of its SHA-256, so the identifier is stable
+// for the key and no one can claim it without it.
+func AgentID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return AgentPrefix + base58Encode(sum[:16])
+}
+
+// WebURL returns the URL of the DID document of a did:web identifier:
+// did:web:example.com is https://example.com/.well-known/did.json and
+// did:web:example.com:users:alice is https://example.com/users/alice/did.json.
+func WebURL(id string) (string, error) {
+	rest, ok := strings.CutPrefix(id, "did:web:")
+	if !ok || rest == "" {
+		return "", fmt.Errorf("%w: %s is not a did:web", ErrUnsupported, id)
+	}
+	parts := strings.Split(rest, ":")
+	host, err := url.PathUnescape(parts[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("%w: invalid did:web host in %s", ErrResolve, id)
+	}
+	path := "/.well-known"
+	if len(parts) > 1 {
+		segs := make([]string, len(parts)-1)
+		for i, p := range parts[1:] {
+			seg, err := url.PathUnescape(p)
+			if err != nil || seg == "" || seg == "." || seg == ".." || strings.Contains(seg, "/") {
+				return "", fmt.Errorf("%w: invalid did:web path in %s", ErrResolve, id)
+			}
+			segs[i] = url.PathEscape(seg)
+		}
+		path = "/" + strings.Join(segs, "/")
+	}
+	return "https://" + host + path + "/did.json", nil
+}
+
+func (r *Resolver) webKeys(ctx context.Context, id string) ([]ed25519.PublicKey, error) {
+	r.mu.Lock()
+	c, ok := r.cache[id]
+	r.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.keys, nil
+	}
+
+	u, err := WebURL(id)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResolve, err)
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+	resp, err := r.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResolve, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", ErrResolve, u, resp.Status)
+	}
+	var doc Document
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocument)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: decode %s: %w", ErrResolve, u, err)
+	}
+	if doc.ID != id {
+		return nil, fmt.Errorf("%w: document at %s is for %q", ErrResolve, u, doc.ID)
+	}
+
+	var keys []ed25519.PublicKey
+	for _, vm := range doc.VerificationMethod {
+		// Methods that are not Ed25519 are skipped, not fatal: a document
+		// may list keys of several types.
+		if key, err := vm.key(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %s has no Ed25519 verification method", ErrUnsupported, id)
+	}
+	r.mu.Lock()
+	r.cache[id] = cached{keys: keys, expires: time.Now().Add(cacheTTL)}
+	r.mu.Unlock()
+	return keys, nil
+}
+
+func (vm *VerificationMethod) key() (ed25519.PublicKey, error) {
+	switch vm.Type {
+	case "Ed25519VerificationKey2020", "Multikey":
+		return multibaseKey(vm.PublicKeyMultibase)
+	case "Ed25519VerificationKey2018":
+		raw, err := base58Decode(vm.PublicKeyBase58)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid publicKeyBase58", ErrResolve)
+		}
+		return raw, nil
+	case "JsonWebKey2020", "JsonWebKey":
+		if vm.PublicKeyJwk == nil || vm.PublicKeyJwk.Kty != "OKP" || vm.PublicKeyJwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: key is not an Ed25519 JWK", ErrUnsupported)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(vm.PublicKeyJwk.X)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid JWK x", ErrResolve)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("%w: verification method type %q", ErrUnsupported, vm.Type)
+}
+
+// multibaseKey decodes a base58btc multibase, multicodec Ed25519 key.
+func multibaseKey(mb string) (ed25519.PublicKey, error) {
+	enc, ok := strings.CutPrefix(mb, "z")
+	if !ok {
+		return nil, fmt.Errorf("%w: key is not base58btc multibase", ErrUnsupported)
+	}
+	raw, err := base58Decode(enc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResolve, err)
+	}
+	if len(raw) < 2 || raw[0] != ed25519Multicodec[0] || raw[1] != ed25519Multicodec[1] {
+		return nil, fmt.Errorf("%w: only Ed25519 keys are supported", ErrUnsupported)
+	}
+	if len(raw) != 2+ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: Ed25519 key has %d bytes", ErrResolve, len(raw)-2)
+	}
+	return ed25519.PublicKey(raw[2:]), nil
+}
@@ -0,0 +1,233 @@
+package did_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDKey_RoundTrip(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id := did.DIDKey(pub)
+	assert.True(t, strings.HasPrefix(id, "did:key:z6Mk"), id)
+
+	key, err := did.KeyFromDIDKey(id)
+	require.NoError(t, err)
+	assert.Equal(t, pub, key)
+
+	key, err = did.KeyFromDIDKey(id + "#" + strings.TrimPrefix(id, "did:key:"))
+	require.NoError(t, err)
+	assert.Equal(t, pub, key, "a fragment names the same key")
+
+	keys, err := did.NewResolver(http.DefaultClient).Keys(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{pub}, keys)
+}
+
+func TestDIDKey_KnownVector(t *testing.T) {
+	// From the did:key specification's Ed25519 test vectors.
+	key, err := did.KeyFromDIDKey("did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp")
+	require.NoError(t, err)
+	assert.Len(t, key, ed25519.PublicKeySize)
+	assert.Equal(t, "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp", did.DIDKey(key))
+}
+
+func TestDIDKey_Invalid(t *testing.T) {
+	for _, id := range []string{
+		"did:key:",
+		"did:key:6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp", // no multibase prefix
+		"did:key:z0OIl", // not base58
+		"did:key:zQ3sh", // not Ed25519
+		"did:web:example.com",
+	} {
+		_, err := did.KeyFromDIDKey(id)
+		assert.Error(t, err, id)
+	}
+
+	_, err := did.NewResolver(http.DefaultClient).Keys(context.Background(), "did:claw:agent:x")
+	assert.ErrorIs(t, err, did.ErrUnsupported)
+}
+
+func TestWebURL(t *testing.T) {
+	for id, want := range map[string]string{
+		"did:web:example.com":                  "https://example.com/.well-known/did.json",
+		"did:web:example.com:users:alice":      "https://example.com/users/alice/did.json",
+		"did:web:localhost%3A8443":             "https://localhost:8443/.well-known/did.json",
+		"did:web:example.com:a%20b":            "https://example.com/a%20b/did.json",
+		"did:web:example.com:users:alice:keys": "https://example.com/users/alice/keys/did.json",
+	} {
+		got, err := did.WebURL(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, want, got, id)
+	}
+	for _, id := range []string{"did:web:", "did:web:evil.com%2Fx", "did:web:example.com:..", "did:web:example.com::a", "did:key:z"} {
+		_, err := did.WebURL(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestWebID(t *testing.T) {
+	for u, want := range map[string]string{
+		"https://example.com":             "did:web:example.com",
+		"https://example.com/":            "did:web:example.com",
+		"http://localhost:8443":           "did:web:localhost%3A8443",
+		"https://example.com/agent-tools": "did:web:example.com:agent-tools",
+		"https://example.com/a%20b/c":     "did:web:example.com:a%20b:c",
+	} {
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		id := did.WebID(parsed)
+		assert.Equal(t, want, id, u)
+		_, err = did.WebURL(id)
+		assert.NoError(t, err, "%s resolves", id)
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id, res, _ := serveDocument(t, func(id string) any {
+		return did.NewDocument(id, []ed25519.PublicKey{pub}, []did.Service{
+			{ID: "#registry", Type: "AgentToolsRegistry", ServiceEndpoint: "https://example.com/v1"},
+		})
+	})
+	keys, err := res.Keys(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{pub}, keys, "the resolver reads the documents it writes")
+
+	doc := did.NewDocument("did:web:example.com", []ed25519.PublicKey{pub}, []did.Service{
+		{ID: "#registry", Type: "AgentToolsRegistry", ServiceEndpoint: "https://example.com/v1"},
+	})
+	assert.Equal(t, []string{"did:web:example.com#key-1"}, doc.AssertionMethod)
+	assert.Equal(t, "did:web:example.com", doc.VerificationMethod[0].Controller)
+	assert.Equal(t, "did:web:example.com#registry", doc.Service[0].ID)
+}
+
+// serveDocument serves the DID document built by doc for the did:web
+// identifier of the server. It returns that identifier, a resolver that
+// trusts the server and the number of requests served.
+func serveDocument(t *testing.T, doc func(id string) any) (string, *did.Resolver, *int) {
+	t.Helper()
+	var hits int
+	var id string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path != "/.well-known/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/did+json")
+		_ = json.NewEncoder(w).Encode(doc(id))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	id = "did:web:" + strings.ReplaceAll(u.Host, ":", "%3A")
+	return id, did.NewResolver(srv.Client()), &hits
+}
+
+func TestWebKeys(t *testing.T) {
+	a, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	b, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id, res, hits := serveDocument(t, func(id string) any {
+		return map[string]any{
+			"id": id,
+			"verificationMethod": []map[string]any{
+				{"id": id + "#a", "type": "Ed25519VerificationKey2020", "publicKeyMultibase": strings.TrimPrefix(did.DIDKey(a), "did:key:")},
+				{"id": id + "#b", "type": "JsonWebKey2020", "publicKeyJwk": map[string]string{
+					"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(b),
+				}},
+				{"id": id + "#c", "type": "EcdsaSecp256k1VerificationKey2019", "publicKeyHex": "02ab"},
+			},
+		}
+	})
+
+	ctx := context.Background()
+	keys, err := res.Keys(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{a, b}, keys, "keys of other types are skipped")
+
+	_, err = res.Keys(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, *hits, "resolved keys are cached")
+}
+
+func TestWebKeys_Rejected(t *testing.T) {
+	ctx := context.Background()
+
+	id, res, _ := serveDocument(t, func(string) any {
+		return map[string]any{"id": "did:web:someone.else"}
+	})
+	_, err := res.Keys(ctx, id)
+	assert.ErrorIs(t, err, did.ErrResolve, "document for another DID")
+
+	id, res, _ = serveDocument(t, func(id string) any {
+		return map[string]any{"id": id, "verificationMethod": []map[string]any{
+			{"id": id + "#k", "type": "EcdsaSecp256k1VerificationKey2019"},
+		}}
+	})
+	_, err = res.Keys(ctx, id)
+	assert.ErrorIs(t, err, did.ErrUnsupported, "no Ed25519 key")
+
+	_, err = res.Keys(ctx, id+":missing")
+	assert.ErrorIs(t, err, did.ErrResolve, "document not found")
+}
+
+func TestWebKeys_Malformed(t *testing.T) {
+	a, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	mb := strings.TrimPrefix(did.DIDKey(a), "did:key:")
+	id, res, _ := serveDocument(t, func(id string) any {
+		return map[string]any{
+			"id": id,
+			"verificationMethod": []map[string]any{
+				{"id": id + "#base58", "type": "Ed25519VerificationKey2018", "publicKeyBase58": "0OIl"},
+				{"id": id + "#jwk-crv", "type": "JsonWebKey2020", "publicKeyJwk": map[string]string{"kty": "OKP", "crv": "X25519"}},
+				{"id": id + "#jwk-x", "type": "JsonWebKey2020", "publicKeyJwk": map[string]string{"kty": "OKP", "crv": "Ed25519", "x": "!"}},
+				{"id": id + "#base64", "type": "Multikey", "publicKeyMultibase": "m" + mb[1:]},
+				{"id": id + "#alphabet", "type": "Multikey", "publicKeyMultibase": "z0OIl"},
+				{"id": id + "#secp256k1", "type": "Multikey", "publicKeyMultibase": "zQ3shokFTS3brHcDQrn82RUDfCZESWL1ZdCEJwekUDPQiYBme"},
+				{"id": id + "#short", "type": "Multikey", "publicKeyMultibase": mb[:len(mb)-4]},
+				{"id": id + "#ok", "type": "Multikey", "publicKeyMultibase": mb},
+			},
+		}
+	})
+	for _, bad := range []string{"did:web:", "did:key:z0OIl"} {
+		_, err := res.Keys(context.Background(), bad)
+		assert.Error(t, err, bad)
+	}
+
+	keys, err := res.Keys(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{a}, keys, "malformed keys are skipped")
+
+	id, res, _ = serveDocument(t, func(string) any { return "not a document" })
+	_, err = res.Keys(context.Background(), id)
+	assert.ErrorIs(t, err, did.ErrResolve)
+	assert.ErrorContains(t, err, "decode")
+}
+
+func TestAgentID(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id := did.AgentID(pub)
+	assert.True(t, strings.HasPrefix(id, did.AgentPrefix), id)
+	assert.Equal(t, id, did.AgentID(pub), "stable for the key")
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, did.AgentID(other))
+	assert.False(t, did.Resolvable(id))
+}
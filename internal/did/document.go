@@ -0,0 +1,85 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// documentContext is the @context of the documents NewDocument returns.
+var documentContext = []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/multikey/v1"}
+
+// Document is a DID document: the keys that speak for a DID and the
+// services it offers. The resolver reads the verification methods of
+// did:web documents; the registry serves its own.
+type Document struct {
+	Context            []string             `json:"@context,omitempty"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	// AssertionMethod lists the IDs of the verification methods the DID
+	// signs statements with, such as the registry's call signatures.
+	AssertionMethod []string  `json:"assertionMethod,omitempty"`
+	Service         []Service `json:"service,omitempty"`
+}
+
+// VerificationMethod is a key of a DID document. The resolver reads
+// Ed25519 keys in any of the forms below; NewDocument writes Multikeys.
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller,omitempty"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+	PublicKeyBase58    string `json:"publicKeyBase58,omitempty"`
+	PublicKeyJwk       *JWK   `json:"publicKeyJwk,omitempty"`
+}
+
+// JWK is the part of a JSON Web Key an Ed25519 verification method has.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// Service is an endpoint a DID document advertises.
+type Service struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// NewDocument returns the DID document of id, whose keys, named #key-1,
+// #key-2 and so on, are assertion methods, and which offers services, whose
+// IDs are fragments such as "#registry".
+func NewDocument(id string, keys []ed25519.PublicKey, services []Service) *Document {
+	doc := &Document{Context: documentContext, ID: id}
+	for i, key := range keys {
+		vm := VerificationMethod{
+			ID:                 fmt.Sprintf("%s#key-%d", id, i+1),
+			Type:               "Multikey",
+			Controller:         id,
+			PublicKeyMultibase: Multibase(key),
+		}
+		doc.VerificationMethod = append(doc.VerificationMethod, vm)
+		doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
+	}
+	for _, s := range services {
+		s.ID = id + s.ID
+		doc.Service = append(doc.Service, s)
+	}
+	return doc
+}
+
+// WebID returns the did:web identifier of the DID document served at the
+// base URL u: did:web:example.com for https://example.com, its port
+// percent-encoded, and did:web:example.com:registry for a registry served
+// under /registry, whose document is then /registry/did.json.
+func WebID(u *url.URL) string {
+	id := "did:web:" + strings.ReplaceAll(u.Host, ":", "%3A")
+	for _, seg := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		if seg != "" {
+			id += ":" + url.PathEscape(seg)
+		}
+	}
+	return id
+}
@@ -0,0 +1,33 @@
+// Package errreport defines the hook used to forward server failures to an
+// external error tracker, plus a Sentry-compatible implementation.
+package errreport
+
+import (
+	"context"
+	"time"
+)
+
+// Event describes a server-side failure: a 5xx response or a recovered panic.
+type Event struct {
+	Time      time.Time
+	Panic     any
+	RequestID string
+	Method    string
+	Path      string
+	Code      string
+	Message   string
+	Stack     []byte
+	Status    int
+}
+
+// Reporter receives server failures. Implementations must not block the
+// request path; slow transports should queue internally.
+type Reporter interface {
+	Report(ctx context.Context, ev *Event)
+}
+
+// Nop is a Reporter that discards every event.
+type Nop struct{}
+
+// Report implements Reporter.
+func (Nop) Report(context.Context, *Event) {}
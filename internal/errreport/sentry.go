@@ -0,0 +1,161 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sentryQueueSize bounds the number of undelivered events held in memory.
+const sentryQueueSize = 256
+
+// Sentry delivers events to a Sentry-compatible store endpoint
+// (Sentry, GlitchTip, etc.) identified by a DSN of the form
+// https://<key>@<host>/<project>.
+type Sentry struct {
+	client   *http.Client
+	log      *zap.Logger
+	queue    chan *Event
+	done     chan struct{}
+	endpoint string
+	auth     string
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewSentry parses dsn and starts a background sender.
+// Call Close to flush pending events on shutdown.
+func NewSentry(dsn string, log *zap.Logger) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse sentry dsn: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("sentry dsn has no public key")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("sentry dsn has no project id")
+	}
+
+	s := &Sentry{
+		client:   &http.Client{Timeout: 5 * time.Second},
+		log:      log,
+		queue:    make(chan *Event, sentryQueueSize),
+		done:     make(chan struct{}),
+		endpoint: fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=agent-tools/0.1.0, sentry_key=%s",
+			u.User.Username()),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Report queues ev for delivery. Events are dropped if the queue is full
+// or the reporter is closed.
+func (s *Sentry) Report(_ context.Context, ev *Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- ev:
+	default:
+		s.log.Warn("sentry queue full, dropping event", zap.String("request_id", ev.RequestID))
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be sent.
+func (s *Sentry) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *Sentry) run() {
+	defer close(s.done)
+	for ev := range s.queue {
+		if err := s.send(ev); err != nil {
+			s.log.Warn("sentry delivery failed", zap.Error(err))
+		}
+	}
+}
+
+// sentryEvent is the subset of the Sentry event payload we populate.
+type sentryEvent struct {
+	Extra     map[string]any    `json:"extra,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Request   map[string]string `json:"request,omitempty"`
+	EventID   string            `json:"event_id"`
+	Timestamp string            `json:"timestamp"`
+	Level     string            `json:"level"`
+	Platform  string            `json:"platform"`
+	Logger    string            `json:"logger"`
+	Message   string            `json:"message"`
+}
+
+func (s *Sentry) send(ev *Event) error {
+	payload := sentryEvent{
+		EventID:   newEventID(),
+		Timestamp: ev.Time.UTC().Format(time.RFC3339),
+		Level:     "error",
+		Platform:  "go",
+		Logger:    "agent-tools",
+		Message:   ev.Message,
+		Tags: map[string]string{
+			"status":     fmt.Sprint(ev.Status),
+			"request_id": ev.RequestID,
+		},
+		Request: map[string]string{"method": ev.Method, "url": ev.Path},
+	}
+	if ev.Code != "" {
+		payload.Tags["code"] = ev.Code
+	}
+	if ev.Panic != nil {
+		payload.Level = "fatal"
+		payload.Message = fmt.Sprintf("panic: %v", ev.Panic)
+		payload.Extra = map[string]any{"stack": string(ev.Stack)}
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
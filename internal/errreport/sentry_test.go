@@ -0,0 +1,85 @@
+package errreport_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/errreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewSentry_InvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"://bad", "https://sentry.example.com/1", "https://key@sentry.example.com/"} {
+		_, err := errreport.NewSentry(dsn, zap.NewNop())
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestSentry_DeliversEvents(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		path   string
+		auth   string
+		events []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		auth = r.Header.Get("X-Sentry-Auth")
+		var ev map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		events = append(events, ev)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "http://", "http://pubkey@", 1) + "/42"
+	s, err := errreport.NewSentry(dsn, zap.NewNop())
+	require.NoError(t, err)
+
+	s.Report(context.Background(), &errreport.Event{
+		Time: time.Now(), Status: 500, Code: "INTERNAL_ERROR", Message: "db closed", RequestID: "req-1",
+	})
+	s.Report(context.Background(), &errreport.Event{Time: time.Now(), Status: 500, Panic: "boom", Stack: []byte("trace")})
+	s.Close()
+	s.Close() // idempotent
+
+	// Events reported after Close are dropped.
+	s.Report(context.Background(), &errreport.Event{Time: time.Now(), Status: 500, Message: "late"})
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "/api/42/store/", path)
+	assert.Contains(t, auth, "sentry_key=pubkey")
+	require.Len(t, events, 2)
+	assert.Equal(t, "db closed", events[0]["message"])
+	assert.Equal(t, "req-1", events[0]["tags"].(map[string]any)["request_id"])
+	assert.Equal(t, "fatal", events[1]["level"])
+	assert.Equal(t, "panic: boom", events[1]["message"])
+}
+
+func TestSentry_DeliveryFailureIsNotFatal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s, err := errreport.NewSentry(strings.Replace(srv.URL, "http://", "http://k@", 1)+"/1", zap.NewNop())
+	require.NoError(t, err)
+	s.Report(context.Background(), &errreport.Event{Time: time.Now(), Status: 503})
+	s.Close()
+}
+
+func TestNop(t *testing.T) {
+	var r errreport.Reporter = errreport.Nop{}
+	r.Report(context.Background(), &errreport.Event{})
+}
@@ -0,0 +1,182 @@
+// Package events publishes registry events as CloudEvents 1.0, in the JSON
+// event format, so they can be fed to existing eventing infrastructure
+// without translation.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SpecVersion is the CloudEvents version events conform to.
+const SpecVersion = "1.0"
+
+// ContentType is the media type of an event in structured mode.
+const ContentType = "application/cloudevents+json"
+
+// DefaultSource is the source of events when none is configured.
+const DefaultSource = "urn:agent-tools:registry"
+
+// Event types, reverse-DNS prefixed as the CloudEvents spec recommends.
+const (
+	TypePrefix             = "io.clawinfra.agenttools."
+	ToolRegistered         = TypePrefix + "tool.registered"
+	ToolDeactivated        = TypePrefix + "tool.deactivated"
+	ToolSLAViolated        = TypePrefix + "tool.sla_violated"
+	ToolConformanceFailing = TypePrefix + "tool.conformance_failing"
+	ToolPricingChanged     = TypePrefix + "tool.pricing_changed"
+	InvocationCompleted    = TypePrefix + "invocation.completed"
+	InvocationFailed       = TypePrefix + "invocation.failed"
+	InvocationRefunded     = TypePrefix + "invocation.refunded"
+	EscrowReleased         = TypePrefix + "escrow.released"
+	QuotaWarning           = TypePrefix + "quota.warning"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is disconnected.
+const subscriberBuffer = 256
+
+// Event is a CloudEvent in the JSON event format. Namespace is an extension
+// attribute naming the registry namespace the event belongs to.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Namespace       string          `json:"namespace,omitempty"`
+	Data            json.RawMessage `json:"data"`
+	// Audience, when set, is the only caller who may see the event, e.g.
+	// the consumer of an invocation. It is not part of the CloudEvent.
+	Audience string `json:"-"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks: a subscriber
+// that falls too far behind is disconnected and has to subscribe again.
+type Bus struct {
+	source string
+	mu     sync.Mutex
+	subs   map[chan *Event]struct{}
+	closed bool
+}
+
+// NewBus returns a bus stamping events with source, a URI reference such
+// as https://registry.example.com.
+func NewBus(source string) *Bus {
+	if source == "" {
+		source = DefaultSource
+	}
+	return &Bus{source: source, subs: map[chan *Event]struct{}{}}
+}
+
+// Active reports whether anyone is subscribed, so publishers can skip
+// building events nobody receives.
+func (b *Bus) Active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+// Publish sends an event of type typ about subject to every subscriber.
+func (b *Bus) Publish(typ, subject, namespace, audience string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal event data: %w", err)
+	}
+	ev := &Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.NewString(),
+		Source:          b.source,
+		Type:            typ,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Namespace:       namespace,
+		Data:            raw,
+		Audience:        audience,
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel of published events and a function that ends
+// the subscription. The channel is closed when the subscriber falls behind
+// or the bus is closed.
+func (b *Bus) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close disconnects every subscriber and refuses new ones; it is called on
+// shutdown so open event streams end.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Matches reports whether ev has one of types, or any type when types is
+// empty. A type ending in "." matches every type it prefixes.
+func (ev *Event) Matches(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if ev.Type == t || (strings.HasSuffix(t, ".") && strings.HasPrefix(ev.Type, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRequest returns a webhook delivery of ev to url in structured content
+// mode: the whole event is the body, typed application/cloudevents+json.
+func NewRequest(ctx context.Context, url string, ev *Event) (*http.Request, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ContentType+"; charset=utf-8")
+	return req, nil
+}
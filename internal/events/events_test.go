@@ -0,0 +1,88 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	b := events.NewBus("https://registry.example.com")
+	assert.False(t, b.Active())
+	ch, cancel := b.Subscribe()
+	assert.True(t, b.Active())
+
+	require.NoError(t, b.Publish(events.ToolRegistered, "tool-1", "default", "", map[string]string{"id": "tool-1"}))
+	ev := <-ch
+	assert.Equal(t, events.SpecVersion, ev.SpecVersion)
+	assert.Equal(t, "https://registry.example.com", ev.Source)
+	assert.Equal(t, events.ToolRegistered, ev.Type)
+	assert.Equal(t, "tool-1", ev.Subject)
+	assert.NotEmpty(t, ev.ID)
+	assert.JSONEq(t, `{"id":"tool-1"}`, string(ev.Data))
+
+	cancel()
+	_, open := <-ch
+	assert.False(t, open)
+	assert.False(t, b.Active())
+	cancel() // idempotent
+}
+
+func TestBus_DisconnectsSlowSubscribers(t *testing.T) {
+	b := events.NewBus("")
+	ch, cancel := b.Subscribe()
+	defer cancel()
+	for i := 0; i < 300; i++ {
+		require.NoError(t, b.Publish(events.ToolRegistered, "t", "default", "", nil))
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	assert.Equal(t, 256, n)
+}
+
+func TestBus_Close(t *testing.T) {
+	b := events.NewBus("")
+	ch, _ := b.Subscribe()
+	b.Close()
+	_, open := <-ch
+	assert.False(t, open)
+
+	ch, _ = b.Subscribe()
+	_, open = <-ch
+	assert.False(t, open, "subscriptions after Close end at once")
+}
+
+func TestEvent_Matches(t *testing.T) {
+	ev := &events.Event{Type: events.InvocationCompleted}
+	assert.True(t, ev.Matches(nil))
+	assert.True(t, ev.Matches([]string{events.ToolRegistered, events.InvocationCompleted}))
+	assert.True(t, ev.Matches([]string{events.TypePrefix + "invocation."}))
+	assert.False(t, ev.Matches([]string{events.TypePrefix + "tool."}))
+	assert.False(t, ev.Matches([]string{events.TypePrefix + "invocation"}))
+}
+
+func TestNewRequest(t *testing.T) {
+	b := events.NewBus("")
+	ch, cancel := b.Subscribe()
+	defer cancel()
+	require.NoError(t, b.Publish(events.InvocationFailed, "inv_1", "acme", "did:claw:agent:c", map[string]string{"status": "failed"}))
+	ev := <-ch
+
+	req, err := events.NewRequest(context.Background(), "https://hooks.example.com/in", ev)
+	require.NoError(t, err)
+	assert.Equal(t, "application/cloudevents+json; charset=utf-8", req.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(req.Body)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, "1.0", got["specversion"])
+	assert.Equal(t, "acme", got["namespace"])
+	assert.Equal(t, "application/json", got["datacontenttype"])
+	assert.NotContains(t, got, "Audience")
+}
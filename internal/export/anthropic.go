@@ -0,0 +1,51 @@
+package export
+
+import (
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// FormatAnthropic is the Claude tool-use format.
+const FormatAnthropic = "anthropic"
+
+// Anthropic limits: tool names must match ^[a-zA-Z0-9_-]{1,64}$. Descriptions
+// have no hard limit, but long ones cost context on every request, so they
+// are cut to a size that still leaves room for usage guidance.
+const (
+	anthropicMaxName        = 64
+	anthropicMaxDescription = 1024
+)
+
+// AnthropicTool is one entry of the tools array of a Messages API request.
+type AnthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// AnthropicExport is the anthropic rendering of a tool listing. Tools can be
+// passed to the Messages API as is; ToolIDs maps the name in a tool_use
+// block back to the registry tool to invoke.
+type AnthropicExport struct {
+	Format  string            `json:"format"`
+	Tools   []AnthropicTool   `json:"tools"`
+	ToolIDs map[string]string `json:"tool_ids"`
+}
+
+// Anthropic renders tools as Claude tool-use definitions.
+func Anthropic(tools []*registry.Tool) *AnthropicExport {
+	out := &AnthropicExport{
+		Format:  FormatAnthropic,
+		Tools:   make([]AnthropicTool, len(tools)),
+		ToolIDs: make(map[string]string, len(tools)),
+	}
+	names := uniqueNames(tools, anthropicMaxName)
+	for i, t := range tools {
+		out.Tools[i] = AnthropicTool{
+			Name:        names[i],
+			Description: truncate(t.Description, anthropicMaxDescription),
+			InputSchema: objectSchema(t.Schema.Input),
+		}
+		out.ToolIDs[names[i]] = t.ID
+	}
+	return out
+}
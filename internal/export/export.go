@@ -0,0 +1,131 @@
+// Package export renders registry tools in the tool-definition formats of
+// LLM APIs, so agents can hand a registry listing straight to a model.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// ErrUnknownFormat is returned by Render for an unsupported format.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// formats maps each supported format to its renderer.
+var formats = map[string]func([]*registry.Tool) any{
+	FormatAnthropic: func(tools []*registry.Tool) any { return Anthropic(tools) },
+}
+
+// Formats returns the supported format names, sorted.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render converts tools to format.
+func Render(format string, tools []*registry.Tool) (any, error) {
+	render, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: %s)", ErrUnknownFormat, format, strings.Join(Formats(), ", "))
+	}
+	return render(tools), nil
+}
+
+// SanitizeName replaces every character outside [a-zA-Z0-9_-], which is all
+// most model APIs accept in tool names, with an underscore and cuts the
+// result to maxLen bytes.
+func SanitizeName(name string, maxLen int) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	s := sb.String()
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	if s == "" {
+		s = "tool"
+	}
+	return s
+}
+
+// IDSuffix returns a short, name-safe fragment of a tool ID: the first
+// eight alphanumerics of its last colon-separated part, i.e. of the hash
+// in a did:claw:tool ID.
+func IDSuffix(id string) string {
+	if i := strings.LastIndexByte(id, ':'); i >= 0 {
+		id = id[i+1:]
+	}
+	var sb strings.Builder
+	for _, r := range id {
+		if sb.Len() == 8 {
+			break
+		}
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// uniqueNames assigns each tool a sanitized name of at most maxLen bytes. Names
+// that collide, typically several versions of one tool, are suffixed with
+// a fragment of the tool ID.
+func uniqueNames(tools []*registry.Tool, maxLen int) []string {
+	count := make(map[string]int, len(tools))
+	for _, t := range tools {
+		count[SanitizeName(t.Name, maxLen)]++
+	}
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		name := SanitizeName(t.Name, maxLen)
+		if count[name] > 1 {
+			suffix := IDSuffix(t.ID)
+			name = SanitizeName(t.Name, maxLen-len(suffix)-1) + "_" + suffix
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// truncate shortens s to at most maxLen runes, cutting at a word boundary
+// where one is near and marking the cut with an ellipsis.
+func truncate(s string, maxLen int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= maxLen {
+		return s
+	}
+	runes := []rune(s)[:maxLen-1]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n\t"); i > len(cut)*3/4 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n\t.,;:") + "…"
+}
+
+// objectSchema returns the tool's input schema as a JSON object schema.
+// Tools registered without one accept any object.
+func objectSchema(raw json.RawMessage) map[string]any {
+	var schema map[string]any
+	if err := json.Unmarshal(raw, &schema); err != nil || schema == nil {
+		schema = map[string]any{}
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	return schema
+}
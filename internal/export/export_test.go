@@ -0,0 +1,60 @@
+package export_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/clawinfra/agent-tools/internal/export"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "web_search-v2", export.SanitizeName("web.search-v2", 64))
+	assert.Equal(t, "caf_", export.SanitizeName("café", 64))
+	assert.Equal(t, "abc", export.SanitizeName("abcdef", 3))
+	assert.Equal(t, "tool", export.SanitizeName("", 64))
+}
+
+func TestIDSuffix(t *testing.T) {
+	assert.Equal(t, "9f86d081", export.IDSuffix("did:claw:tool:9f86d081884c7d65"))
+	assert.Equal(t, "0a1b2c3d", export.IDSuffix("0a1b-2c3d-4e5f"))
+	assert.Equal(t, "abc", export.IDSuffix("abc"))
+}
+
+func TestAnthropic(t *testing.T) {
+	long := strings.Repeat("word ", 400)
+	tools := []*registry.Tool{
+		{ID: "did:claw:tool:aaaaaaaa11", Name: strings.Repeat("n", 80), Description: long,
+			Schema: registry.ToolSchema{Input: json.RawMessage(`{"type":"object","required":["q"]}`)}},
+		{ID: "did:claw:tool:bbbbbbbb22", Name: "dup"},
+		{ID: "did:claw:tool:cccccccc33", Name: "dup"},
+	}
+	out := export.Anthropic(tools)
+	require.Len(t, out.Tools, 3)
+
+	first := out.Tools[0]
+	assert.Len(t, first.Name, 64)
+	assert.LessOrEqual(t, utf8.RuneCountInString(first.Description), 1024)
+	assert.True(t, strings.HasSuffix(first.Description, "word…"))
+	assert.Equal(t, []any{"q"}, first.InputSchema["required"])
+
+	assert.Equal(t, "dup_bbbbbbbb", out.Tools[1].Name)
+	assert.Equal(t, "dup_cccccccc", out.Tools[2].Name)
+	assert.Equal(t, map[string]any{"type": "object"}, out.Tools[1].InputSchema)
+	assert.Equal(t, "did:claw:tool:cccccccc33", out.ToolIDs["dup_cccccccc"])
+}
+
+func TestRender_UnknownFormat(t *testing.T) {
+	_, err := export.Render("openapi", nil)
+	assert.ErrorIs(t, err, export.ErrUnknownFormat)
+	assert.ErrorContains(t, err, "anthropic")
+
+	out, err := export.Render(export.FormatAnthropic, nil)
+	require.NoError(t, err)
+	b, _ := json.Marshal(out)
+	assert.JSONEq(t, `{"format":"anthropic","tools":[],"tool_ids":{}}`, string(b))
+}
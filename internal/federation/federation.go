@@ -0,0 +1,120 @@
+// Package federation mirrors the catalogs of peer registries so that local
+// search can include their tools.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"go.uber.org/zap"
+)
+
+// pageSize is the page size requested from peers; maxPages bounds how much of
+// a peer's catalog is mirrored.
+const (
+	pageSize = 100
+	maxPages = 100
+)
+
+// Peer is a remote registry to mirror.
+type Peer struct {
+	Name string
+	URL  string
+}
+
+// ParsePeers validates a name→base URL map, as given on the command line,
+// and returns the peers sorted by name.
+func ParsePeers(m map[string]string) ([]Peer, error) {
+	peers := make([]Peer, 0, len(m))
+	for name, raw := range m {
+		if err := registry.ValidateNamespace(name); err != nil {
+			return nil, fmt.Errorf("peer name %q: must be 1-63 lowercase letters, digits or dashes", name)
+		}
+		if name == registry.SourceLocal {
+			return nil, fmt.Errorf("peer name %q is reserved", name)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("peer %s: invalid url %q", name, raw)
+		}
+		peers = append(peers, Peer{Name: name, URL: strings.TrimRight(raw, "/")})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+	return peers, nil
+}
+
+// Syncer periodically copies peer catalogs into the local registry.
+type Syncer struct {
+	reg    *registry.Registry
+	log    *zap.Logger
+	client *http.Client
+	peers  []Peer
+}
+
+// NewSyncer creates a Syncer for peers.
+func NewSyncer(reg *registry.Registry, log *zap.Logger, peers []Peer) *Syncer {
+	return &Syncer{
+		reg:    reg,
+		log:    log,
+		client: &http.Client{Timeout: 30 * time.Second},
+		peers:  peers,
+	}
+}
+
+// Sync mirrors every peer. A peer that cannot be reached keeps its previous
+// mirror; the failures are returned together once all peers were tried.
+func (s *Syncer) Sync(ctx context.Context) error {
+	var errs []error
+	for _, p := range s.peers {
+		tools, err := s.fetch(ctx, p)
+		if err == nil {
+			err = s.reg.MirrorPeer(ctx, p.Name, tools)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", p.Name, err))
+			continue
+		}
+		s.log.Debug("mirrored peer", zap.String("peer", p.Name), zap.Int("tools", len(tools)))
+	}
+	return errors.Join(errs...)
+}
+
+// fetch pages through a peer's public catalog.
+func (s *Syncer) fetch(ctx context.Context, p Peer) ([]*registry.Tool, error) {
+	var tools []*registry.Tool
+	for page := 1; page <= maxPages; page++ {
+		var result registry.SearchResult
+		if err := s.get(ctx, fmt.Sprintf("%s/v1/tools?page=%d&limit=%d", p.URL, page, pageSize), &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if len(result.Tools) < pageSize {
+			break
+		}
+	}
+	return tools, nil
+}
+
+func (s *Syncer) get(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
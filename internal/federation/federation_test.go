@@ -0,0 +1,96 @@
+package federation_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/internal/federation"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newRegistry(t *testing.T) *registry.Registry {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return registry.New(db, zaptest.NewLogger(t))
+}
+
+func TestParsePeers(t *testing.T) {
+	peers, err := federation.ParsePeers(map[string]string{
+		"us": "https://us.example.com/",
+		"eu": "http://eu.example.com",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []federation.Peer{
+		{Name: "eu", URL: "http://eu.example.com"},
+		{Name: "us", URL: "https://us.example.com"},
+	}, peers)
+
+	for _, bad := range []map[string]string{
+		{"EU": "https://eu.example.com"},
+		{"local": "https://eu.example.com"},
+		{"eu": "ftp://eu.example.com"},
+		{"eu": "not a url"},
+	} {
+		_, err := federation.ParsePeers(bad)
+		assert.Error(t, err, "%v", bad)
+	}
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	remote := newRegistry(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := remote.RegisterTool(ctx, &registry.RegisterToolRequest{
+			Name:        fmt.Sprintf("weather-%d", i),
+			Version:     "1.0.0",
+			Description: "forecasts",
+			Schema:      registry.ToolSchema{Input: []byte(`{"type":"object"}`)},
+			Pricing:     &registry.Pricing{Model: registry.PricingFree},
+			Endpoint:    "https://remote.example.com/weather",
+			ProviderID:  "did:claw:agent:remote",
+		})
+		require.NoError(t, err)
+	}
+	srv := httptest.NewServer(api.NewHandler(remote, zaptest.NewLogger(t)))
+	defer srv.Close()
+
+	local := newRegistry(t)
+	syncer := federation.NewSyncer(local, zaptest.NewLogger(t), []federation.Peer{{Name: "eu", URL: srv.URL}})
+	require.NoError(t, syncer.Sync(ctx))
+
+	res, err := local.SearchTools(ctx, &registry.SearchQuery{Query: "weather", Federated: true})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 3)
+	for _, tool := range res.Tools {
+		assert.Equal(t, "eu", tool.Source)
+		assert.Equal(t, "did:claw:agent:remote", tool.ProviderID)
+	}
+}
+
+func TestSyncer_UnreachablePeerKeepsMirror(t *testing.T) {
+	local := newRegistry(t)
+	ctx := context.Background()
+	require.NoError(t, local.MirrorPeer(ctx, "eu", []*registry.Tool{{
+		ID: "remote-1", Name: "weather", Schema: registry.ToolSchema{Input: []byte(`{}`)},
+	}}))
+
+	srv := httptest.NewServer(nil)
+	srv.Close()
+	syncer := federation.NewSyncer(local, zaptest.NewLogger(t), []federation.Peer{{Name: "eu", URL: srv.URL}})
+	err := syncer.Sync(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "peer eu")
+
+	res, err := local.SearchTools(ctx, &registry.SearchQuery{Federated: true})
+	require.NoError(t, err)
+	assert.Len(t, res.Tools, 1)
+}
@@ -0,0 +1,244 @@
+// Package grpcreflect describes the methods of gRPC services through server
+// reflection, so a provider can register a tool by naming a method instead
+// of writing its JSON Schemas by hand.
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrMethod is returned for a method that does not exist or cannot back a
+// tool.
+var ErrMethod = errors.New("unusable grpc method")
+
+// maxFiles bounds the descriptor files fetched for one method, against a
+// server that keeps naming new dependencies.
+const maxFiles = 256
+
+// Method is a unary gRPC method and everything needed to call it with JSON.
+type Method struct {
+	// FullName is the method path, "/package.Service/Method".
+	FullName string
+	Desc     protoreflect.MethodDescriptor
+	// Files holds the descriptors of the method's file and all of its
+	// dependencies; stored with a binding, it lets Load rebuild Desc
+	// without asking the server again.
+	Files *descriptorpb.FileDescriptorSet
+}
+
+// Comment returns the leading comment of the method in its .proto file, if
+// the server shipped source info.
+func (m *Method) Comment() string {
+	loc := m.Desc.ParentFile().SourceLocations().ByDescriptor(m.Desc)
+	return strings.TrimSpace(loc.LeadingComments)
+}
+
+// Resolve asks the server behind conn for method, given as
+// "package.Service/Method" or "package.Service.Method". Servers offering
+// only the older v1alpha reflection API are supported too.
+func Resolve(ctx context.Context, conn grpc.ClientConnInterface, method string) (*Method, error) {
+	service, name, err := splitMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newReflectionStream(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	files := map[string]*descriptorpb.FileDescriptorProto{}
+	var order []string
+	add := func(req *reflectionv1.ServerReflectionRequest) error {
+		resp, err := c.exchange(req)
+		if err != nil {
+			return err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			if codes.Code(e.ErrorCode) == codes.NotFound {
+				return fmt.Errorf("%w: %s", ErrMethod, e.ErrorMessage)
+			}
+			return fmt.Errorf("reflection: %s", e.ErrorMessage)
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return fmt.Errorf("reflection: decode descriptor: %w", err)
+			}
+			if _, ok := files[fd.GetName()]; !ok {
+				files[fd.GetName()] = fd
+				order = append(order, fd.GetName())
+			}
+		}
+		return nil
+	}
+
+	err = add(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Servers usually send dependencies along; fetch any they left out.
+	for i := 0; i < len(order); i++ {
+		if len(order) > maxFiles {
+			return nil, fmt.Errorf("reflection: more than %d descriptor files", maxFiles)
+		}
+		for _, dep := range files[order[i]].GetDependency() {
+			if _, ok := files[dep]; ok {
+				continue
+			}
+			err := add(&reflectionv1.ServerReflectionRequest{
+				MessageRequest: &reflectionv1.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := files[dep]; !ok {
+				return nil, fmt.Errorf("reflection: server did not return %s", dep)
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, name := range order {
+		set.File = append(set.File, files[name])
+	}
+	return find(set, service, name)
+}
+
+// Load rebuilds a method from the descriptors stored with its binding.
+func Load(files *descriptorpb.FileDescriptorSet, method string) (*Method, error) {
+	service, name, err := splitMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	return find(files, service, name)
+}
+
+func find(set *descriptorpb.FileDescriptorSet, service, name string) (*Method, error) {
+	reg, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("reflection: invalid descriptors: %w", err)
+	}
+	d, err := reg.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("%w: service %s not found", ErrMethod, service)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a service", ErrMethod, service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return nil, fmt.Errorf("%w: %s has no method %s", ErrMethod, service, name)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%w: %s.%s is streaming; only unary methods can be tools", ErrMethod, service, name)
+	}
+	return &Method{FullName: "/" + service + "/" + name, Desc: md, Files: set}, nil
+}
+
+// splitMethod accepts "pkg.Service/Method", "/pkg.Service/Method" and
+// "pkg.Service.Method".
+func splitMethod(method string) (service, name string, err error) {
+	m := strings.TrimPrefix(method, "/")
+	i := strings.LastIndexByte(m, '/')
+	if i < 0 {
+		i = strings.LastIndexByte(m, '.')
+	}
+	if i <= 0 || i == len(m)-1 {
+		return "", "", fmt.Errorf("%w: %q is not package.Service/Method", ErrMethod, method)
+	}
+	return m[:i], m[i+1:], nil
+}
+
+// reflectionStream is a reflection stream on either API version. The v1alpha
+// messages are wire-compatible with v1, so both are spoken in v1 types.
+type reflectionStream struct {
+	ctx     context.Context
+	conn    grpc.ClientConnInterface
+	v1      reflectionv1.ServerReflection_ServerReflectionInfoClient
+	v1alpha reflectionv1alpha.ServerReflection_ServerReflectionInfoClient
+}
+
+func newReflectionStream(ctx context.Context, conn grpc.ClientConnInterface) (*reflectionStream, error) {
+	v1, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reflection: %w", err)
+	}
+	return &reflectionStream{ctx: ctx, conn: conn, v1: v1}, nil
+}
+
+func (s *reflectionStream) exchange(req *reflectionv1.ServerReflectionRequest) (*reflectionv1.ServerReflectionResponse, error) {
+	if s.v1alpha == nil {
+		resp, err := roundTrip(s.v1, req)
+		if status.Code(err) != codes.Unimplemented {
+			return resp, err
+		}
+		// The first message on the stream tells whether the server knows v1.
+		alpha, err := reflectionv1alpha.NewServerReflectionClient(s.conn).ServerReflectionInfo(s.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reflection: %w", err)
+		}
+		s.v1alpha = alpha
+	}
+	var alphaReq reflectionv1alpha.ServerReflectionRequest
+	if err := convert(req, &alphaReq); err != nil {
+		return nil, err
+	}
+	alphaResp, err := roundTrip(s.v1alpha, &alphaReq)
+	if err != nil {
+		return nil, err
+	}
+	var resp reflectionv1.ServerReflectionResponse
+	return &resp, convert(alphaResp, &resp)
+}
+
+func (s *reflectionStream) close() {
+	_ = s.v1.CloseSend()
+	if s.v1alpha != nil {
+		_ = s.v1alpha.CloseSend()
+	}
+}
+
+type bidi[Req, Resp any] interface {
+	Send(*Req) error
+	Recv() (*Resp, error)
+}
+
+func roundTrip[Req, Resp any](s bidi[Req, Resp], req *Req) (*Resp, error) {
+	if err := s.Send(req); err != nil {
+		// A failed Send only reports io.EOF; Recv has the status.
+		if _, rerr := s.Recv(); rerr != nil {
+			return nil, fmt.Errorf("reflection: %w", rerr)
+		}
+		return nil, fmt.Errorf("reflection: %w", err)
+	}
+	resp, err := s.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("reflection: %w", err)
+	}
+	return resp, nil
+}
+
+func convert(from, to proto.Message) error {
+	b, err := proto.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, to)
+}
@@ -0,0 +1,151 @@
+package grpcreflect_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/grpcreflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func serve(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestResolve(t *testing.T) {
+	servers := map[string]func(*grpc.Server){
+		"v1": func(s *grpc.Server) { reflection.RegisterV1(s) },
+		"v1alpha only": func(s *grpc.Server) {
+			reflectionv1alpha.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{Services: s}))
+		},
+	}
+	for name, register := range servers {
+		t.Run(name, func(t *testing.T) {
+			conn := serve(t, register)
+			ctx := context.Background()
+
+			m, err := grpcreflect.Resolve(ctx, conn, "grpc.health.v1.Health/Check")
+			require.NoError(t, err)
+			assert.Equal(t, "/grpc.health.v1.Health/Check", m.FullName)
+			assert.Equal(t, "grpc.health.v1.HealthCheckResponse", string(m.Desc.Output().FullName()))
+			assert.NotEmpty(t, m.Files.File)
+
+			_, err = grpcreflect.Resolve(ctx, conn, "grpc.health.v1.Missing/Check")
+			assert.ErrorIs(t, err, grpcreflect.ErrMethod)
+		})
+	}
+}
+
+func TestResolve_NoReflection(t *testing.T) {
+	conn := serve(t, func(*grpc.Server) {})
+	_, err := grpcreflect.Resolve(context.Background(), conn, "grpc.health.v1.Health/Check")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, grpcreflect.ErrMethod)
+}
+
+// fileServer answers reflection requests from files by name, sending each
+// file without its dependencies, and with error for any name in errors.
+type fileServer struct {
+	reflectionv1.UnimplementedServerReflectionServer
+	files  map[string]*descriptorpb.FileDescriptorProto
+	errors map[string]codes.Code
+}
+
+func (f *fileServer) ServerReflectionInfo(s reflectionv1.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		req, err := s.Recv()
+		if err != nil {
+			return nil
+		}
+		name := req.GetFileByFilename()
+		if sym := req.GetFileContainingSymbol(); sym != "" {
+			name = "test/tree.proto"
+		}
+		resp := &reflectionv1.ServerReflectionResponse{}
+		if code, ok := f.errors[name]; ok {
+			resp.MessageResponse = &reflectionv1.ServerReflectionResponse_ErrorResponse{
+				ErrorResponse: &reflectionv1.ErrorResponse{ErrorCode: int32(code), ErrorMessage: name + " failed"},
+			}
+		} else {
+			var files [][]byte
+			if fd, ok := f.files[name]; ok {
+				b, _ := proto.Marshal(fd)
+				files = append(files, b)
+			}
+			resp.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: files},
+			}
+		}
+		if err := s.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func TestResolve_Dependencies(t *testing.T) {
+	tree := testFile(t)
+	tree.SourceCodeInfo = &descriptorpb.SourceCodeInfo{Location: []*descriptorpb.SourceCodeInfo_Location{{
+		Path:            []int32{6, 0, 2, 0}, // service 0, method 0
+		Span:            []int32{0, 0, 1},
+		LeadingComments: proto.String(" Walk visits a node.\n"),
+	}}}
+	ts := protodesc.ToFileDescriptorProto(mustFile(t, "google/protobuf/timestamp.proto"))
+	tests := []struct {
+		name   string
+		files  map[string]*descriptorpb.FileDescriptorProto
+		errors map[string]codes.Code
+		want   string
+	}{
+		{"fetched", map[string]*descriptorpb.FileDescriptorProto{tree.GetName(): tree, ts.GetName(): ts}, nil, ""},
+		{"missing", map[string]*descriptorpb.FileDescriptorProto{tree.GetName(): tree}, nil,
+			"reflection: server did not return google/protobuf/timestamp.proto"},
+		{"failing", map[string]*descriptorpb.FileDescriptorProto{tree.GetName(): tree},
+			map[string]codes.Code{ts.GetName(): codes.Internal}, "reflection: google/protobuf/timestamp.proto failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := serve(t, func(s *grpc.Server) {
+				reflectionv1.RegisterServerReflectionServer(s, &fileServer{files: tt.files, errors: tt.errors})
+			})
+			m, err := grpcreflect.Resolve(context.Background(), conn, "test.Tree/Walk")
+			if tt.want != "" {
+				assert.EqualError(t, err, tt.want)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, m.Files.File, 2, "the dependency the server left out is fetched")
+			assert.Equal(t, "Walk visits a node.", m.Comment())
+		})
+	}
+}
+
+func TestResolve_InvalidMethod(t *testing.T) {
+	conn := serve(t, func(s *grpc.Server) { reflection.RegisterV1(s) })
+	_, err := grpcreflect.Resolve(context.Background(), conn, "Check")
+	assert.ErrorIs(t, err, grpcreflect.ErrMethod)
+}
@@ -0,0 +1,119 @@
+package grpcreflect
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema returns a JSON Schema for md as the protobuf JSON mapping encodes
+// it, the form in which tool input arrives and output is returned: fields
+// by their JSON names, 64-bit integers as numbers or strings, enums by name
+// and well-known types in their special encodings. Recursive messages are
+// described to the depth where they repeat and left open below it.
+func Schema(md protoreflect.MessageDescriptor) map[string]any {
+	return messageSchema(md, map[protoreflect.FullName]bool{})
+}
+
+func messageSchema(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) map[string]any {
+	if s, ok := wellKnown(md.FullName()); ok {
+		return s
+	}
+	if seen[md.FullName()] {
+		return map[string]any{"type": "object"}
+	}
+	seen[md.FullName()] = true
+	defer delete(seen, md.FullName())
+
+	props := map[string]any{}
+	var required []string
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		props[fd.JSONName()] = fieldSchema(fd, seen)
+		if fd.Cardinality() == protoreflect.Required {
+			required = append(required, fd.JSONName())
+		}
+	}
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	s["title"] = string(md.FullName())
+	return s
+}
+
+func fieldSchema(fd protoreflect.FieldDescriptor, seen map[protoreflect.FullName]bool) map[string]any {
+	switch {
+	case fd.IsMap():
+		return map[string]any{"type": "object", "additionalProperties": singularSchema(fd.MapValue(), seen)}
+	case fd.IsList():
+		return map[string]any{"type": "array", "items": singularSchema(fd, seen)}
+	}
+	return singularSchema(fd, seen)
+}
+
+func singularSchema(fd protoreflect.FieldDescriptor, seen map[protoreflect.FullName]bool) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "minimum": -1 << 31, "maximum": 1<<31 - 1}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": int64(1<<32 - 1)}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// Encoded as strings so values past 2^53 survive JSON parsers.
+		return map[string]any{"type": []string{"integer", "string"}, "pattern": `^-?[0-9]+$`}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]any{"type": "number"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(fd.Message(), seen)
+	}
+	return map[string]any{}
+}
+
+// wellKnown returns the schemas of the well-known types whose JSON encoding
+// differs from that of an ordinary message.
+func wellKnown(name protoreflect.FullName) (map[string]any, bool) {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}, true
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string"}, true
+	case "google.protobuf.Struct":
+		return map[string]any{"type": "object"}, true
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array"}, true
+	case "google.protobuf.Value":
+		return map[string]any{}, true
+	case "google.protobuf.Empty":
+		return map[string]any{"type": "object", "properties": map[string]any{}}, true
+	case "google.protobuf.Any":
+		return map[string]any{"type": "object", "properties": map[string]any{"@type": map[string]any{"type": "string"}}}, true
+	case "google.protobuf.StringValue":
+		return map[string]any{"type": []string{"string", "null"}}, true
+	case "google.protobuf.BytesValue":
+		return map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}, true
+	case "google.protobuf.BoolValue":
+		return map[string]any{"type": []string{"boolean", "null"}}, true
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return map[string]any{"type": []string{"integer", "null"}}, true
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return map[string]any{"type": []string{"integer", "string", "null"}}, true
+	case "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return map[string]any{"type": []string{"number", "null"}}, true
+	}
+	return nil, false
+}
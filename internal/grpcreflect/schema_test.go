@@ -0,0 +1,181 @@
+package grpcreflect_test
+
+import (
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/grpcreflect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb" // registers google/protobuf/timestamp.proto
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label,
+	typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name: proto.String(name), Number: proto.Int32(num), Type: typ.Enum(), Label: label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// testFile describes:
+//
+//	message Node { string snake_name = 1; int64 big = 2; repeated Node children = 3;
+//	               Color color = 4; map<string, double> scores = 5;
+//	               google.protobuf.Timestamp at = 6; bytes blob = 7; }
+//	enum Color { RED = 0; GREEN = 1; }
+//	service Tree { rpc Walk(Node) returns (Node); rpc Stream(Node) returns (stream Node); }
+func testFile(t *testing.T) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	const (
+		opt = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		rep = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	)
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/tree.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Node"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("snake_name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("big", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, opt, ""),
+				field("children", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".test.Node"),
+				field("color", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, opt, ".test.Color"),
+				field("scores", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".test.Node.ScoresEntry"),
+				field("at", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, opt, ".google.protobuf.Timestamp"),
+				field("blob", 7, descriptorpb.FieldDescriptorProto_TYPE_BYTES, opt, ""),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("ScoresEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, opt, ""),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("RED"), Number: proto.Int32(0)},
+				{Name: proto.String("GREEN"), Number: proto.Int32(1)},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Tree"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Walk"), InputType: proto.String(".test.Node"), OutputType: proto.String(".test.Node")},
+				{Name: proto.String("Stream"), InputType: proto.String(".test.Node"), OutputType: proto.String(".test.Node"),
+					ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}
+}
+
+func TestSchema(t *testing.T) {
+	fd, err := protodesc.NewFile(testFile(t), protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	s := grpcreflect.Schema(fd.Messages().ByName("Node"))
+
+	assert.Equal(t, "object", s["type"])
+	assert.Equal(t, "test.Node", s["title"])
+	props := s["properties"].(map[string]any)
+	assert.Contains(t, props, "snakeName", "fields use their JSON names")
+	assert.Equal(t, []string{"integer", "string"}, props["big"].(map[string]any)["type"])
+	assert.Equal(t, []string{"RED", "GREEN"}, props["color"].(map[string]any)["enum"])
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, props["at"])
+	assert.Equal(t, "base64", props["blob"].(map[string]any)["contentEncoding"])
+	assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number"}}, props["scores"])
+
+	children := props["children"].(map[string]any)
+	assert.Equal(t, "array", children["type"])
+	assert.Equal(t, map[string]any{"type": "object"}, children["items"], "recursion stops at the repeated message")
+}
+
+func TestSchema_Scalars(t *testing.T) {
+	const req = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/scalars.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Scalars"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("flag", 1, descriptorpb.FieldDescriptorProto_TYPE_BOOL, req, ""),
+				field("small", 2, descriptorpb.FieldDescriptorProto_TYPE_SINT32, req, ""),
+				field("count", 3, descriptorpb.FieldDescriptorProto_TYPE_FIXED32, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, ""),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	s := grpcreflect.Schema(fd.Messages().ByName("Scalars"))
+
+	assert.Equal(t, []string{"flag", "small"}, s["required"], "proto2 required fields are required")
+	props := s["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "boolean"}, props["flag"])
+	assert.Equal(t, -1<<31, props["small"].(map[string]any)["minimum"])
+	assert.Equal(t, 0, props["count"].(map[string]any)["minimum"])
+}
+
+func TestSchema_WellKnown(t *testing.T) {
+	tests := []struct {
+		msg  proto.Message
+		want map[string]any
+	}{
+		{&durationpb.Duration{}, map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}},
+		{&fieldmaskpb.FieldMask{}, map[string]any{"type": "string"}},
+		{&structpb.Struct{}, map[string]any{"type": "object"}},
+		{&structpb.ListValue{}, map[string]any{"type": "array"}},
+		{&structpb.Value{}, map[string]any{}},
+		{&emptypb.Empty{}, map[string]any{"type": "object", "properties": map[string]any{}}},
+		{&anypb.Any{}, map[string]any{"type": "object", "properties": map[string]any{"@type": map[string]any{"type": "string"}}}},
+		{&wrapperspb.StringValue{}, map[string]any{"type": []string{"string", "null"}}},
+		{&wrapperspb.BytesValue{}, map[string]any{"type": []string{"string", "null"}, "contentEncoding": "base64"}},
+		{&wrapperspb.BoolValue{}, map[string]any{"type": []string{"boolean", "null"}}},
+		{&wrapperspb.UInt32Value{}, map[string]any{"type": []string{"integer", "null"}}},
+		{&wrapperspb.Int64Value{}, map[string]any{"type": []string{"integer", "string", "null"}}},
+		{&wrapperspb.DoubleValue{}, map[string]any{"type": []string{"number", "null"}}},
+	}
+	for _, tt := range tests {
+		md := tt.msg.ProtoReflect().Descriptor()
+		assert.Equal(t, tt.want, grpcreflect.Schema(md), string(md.FullName()))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tsFile := protodesc.ToFileDescriptorProto(mustFile(t, "google/protobuf/timestamp.proto"))
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFile(t), tsFile}}
+
+	for _, name := range []string{"test.Tree/Walk", "/test.Tree/Walk", "test.Tree.Walk"} {
+		m, err := grpcreflect.Load(set, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, "/test.Tree/Walk", m.FullName)
+		assert.Equal(t, "test.Node", string(m.Desc.Input().FullName()))
+	}
+	for _, name := range []string{"test.Tree/Stream", "test.Tree/Missing", "test.Nope/Walk", "Walk", "test.Node/Walk"} {
+		_, err := grpcreflect.Load(set, name)
+		assert.ErrorIs(t, err, grpcreflect.ErrMethod, name)
+	}
+}
+
+func mustFile(t *testing.T, path string) protoreflect.FileDescriptor {
+	t.Helper()
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	require.NoError(t, err)
+	return fd
+}
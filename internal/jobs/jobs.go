@@ -0,0 +1,328 @@
+// Package jobs is a persistent queue of background work. Jobs are kept in
+// the registry database, so those still queued survive a restart and can be
+// claimed by any replica sharing it.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/store"
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned for a job that does not exist or was purged.
+var ErrNotFound = errors.New("job not found")
+
+// Job states.
+const (
+	Queued  = "queued"
+	Running = "running"
+	Done    = "done"
+)
+
+// Priority is the class of a job. Queued jobs are claimed highest priority
+// first, then oldest first.
+type Priority int
+
+// Priority classes. The zero value is PriorityNormal.
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+var priorityNames = map[Priority]string{PriorityLow: "low", PriorityNormal: "normal", PriorityHigh: "high"}
+
+// String returns the name of p: low, normal or high.
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// ParsePriority returns the priority named s; empty is normal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for p, name := range priorityNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q: must be low, normal or high", s)
+}
+
+// Shares are the shares of a queue's workers, between 0 and 1, that jobs of
+// each priority and the priorities below it may occupy at once, so batch
+// work cannot starve interactive work of workers. Each class gets at least
+// one worker.
+type Shares struct {
+	High   float64
+	Normal float64
+	Low    float64
+}
+
+// DefaultShares let low priority jobs occupy a quarter of the workers and
+// normal and low ones together three quarters, keeping the rest for high
+// priority jobs.
+var DefaultShares = Shares{High: 1, Normal: 0.75, Low: 0.25}
+
+// limit returns how many of workers jobs of priority p or below may
+// occupy.
+func (s Shares) limit(p Priority, workers int) int {
+	share := map[Priority]float64{PriorityHigh: s.High, PriorityNormal: s.Normal, PriorityLow: s.Low}[p]
+	return max(1, int(math.Floor(share*float64(workers))))
+}
+
+// pollInterval is how often an idle worker looks for jobs enqueued by other
+// replicas; jobs enqueued on its own replica wake it at once.
+const pollInterval = time.Second
+
+// Job is a unit of queued work. Payload is what the handler needs to run
+// it, dropped once it is done; Result is what the handler returned.
+type Job struct {
+	ID         string
+	Namespace  string
+	Status     string
+	Priority   Priority
+	Payload    []byte
+	Result     []byte
+	EnqueuedAt time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// Handler runs a claimed job and returns its result. An error puts the job
+// back in the queue, to be claimed again later.
+type Handler func(ctx context.Context, job *Job) ([]byte, error)
+
+// Queue is a FIFO of jobs in a SQLite table.
+type Queue struct {
+	db        *store.DB
+	log       *zap.Logger
+	retention time.Duration
+	wake      chan struct{}
+
+	mu      sync.Mutex
+	running map[Priority]int
+}
+
+// New returns the queue kept in db. Finished jobs are purged retention
+// after they finish.
+func New(db *store.DB, log *zap.Logger, retention time.Duration) *Queue {
+	return &Queue{db: db, log: log, retention: retention, wake: make(chan struct{}, 1), running: map[Priority]int{}}
+}
+
+// Enqueue adds a job with the given ID to the end of the queue of its
+// priority.
+func (q *Queue) Enqueue(ctx context.Context, id, namespace string, priority Priority, payload []byte) error {
+	_, err := q.db.ExecContext(ctx,
+		"INSERT INTO jobs (id, namespace, status, payload, enqueued_at, priority) VALUES (?, ?, ?, ?, ?, ?)",
+		id, namespace, Queued, payload, time.Now().Unix(), priority)
+	if err != nil {
+		return fmt.Errorf("enqueue job: %w", err)
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Get returns job id.
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	var (
+		j                     Job
+		enqueuedAt            int64
+		startedAt, finishedAt sql.NullInt64
+	)
+	err := q.db.QueryRowContext(ctx, `
+		SELECT id, namespace, status, priority, payload, result, enqueued_at, started_at, finished_at FROM jobs WHERE id = ?
+	`, id).Scan(&j.ID, &j.Namespace, &j.Status, &j.Priority, &j.Payload, &j.Result, &enqueuedAt, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job: %w", err)
+	}
+	j.EnqueuedAt = time.Unix(enqueuedAt, 0).UTC()
+	j.StartedAt = timeOf(startedAt)
+	j.FinishedAt = timeOf(finishedAt)
+	return &j, nil
+}
+
+func timeOf(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0).UTC()
+	return &t
+}
+
+// Run runs queued jobs with handle on workers goroutines until ctx is done,
+// each priority on no more of them at once than its share. Shares apply to
+// this process's workers; replicas sharing the queue have their own. A job
+// that has started is run to the end even if ctx ends first.
+func (q *Queue) Run(ctx context.Context, workers int, shares Shares, handle Handler) {
+	limits := map[Priority]int{}
+	for p := range priorityNames {
+		limits[p] = shares.limit(p, workers)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, limits, handle)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, limits map[Priority]int, handle Handler) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil && q.runOne(ctx, limits, handle) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOne claims the next queued job of a priority under its limit and runs
+// it. It reports whether it did, so the worker moves straight on to the
+// next one.
+func (q *Queue) runOne(ctx context.Context, limits map[Priority]int, handle Handler) bool {
+	job, err := q.claimWithin(ctx, limits)
+	if err != nil {
+		if ctx.Err() == nil {
+			q.log.Warn("claim job", zap.Error(err))
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	defer func() {
+		q.mu.Lock()
+		q.running[job.Priority]--
+		q.mu.Unlock()
+	}()
+	ctx = context.WithoutCancel(ctx)
+	result, err := handle(ctx, job)
+	if err != nil {
+		q.log.Warn("job requeued", zap.String("job_id", job.ID), zap.Error(err))
+		if _, err := q.db.ExecContext(ctx,
+			"UPDATE jobs SET status = ?, started_at = NULL WHERE id = ?", Queued, job.ID); err != nil {
+			q.log.Error("requeue job", zap.String("job_id", job.ID), zap.Error(err))
+		}
+		return false
+	}
+	if _, err := q.db.ExecContext(ctx,
+		"UPDATE jobs SET status = ?, payload = NULL, result = ?, finished_at = ? WHERE id = ?",
+		Done, result, time.Now().Unix(), job.ID); err != nil {
+		q.log.Error("finish job", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	return true
+}
+
+// claimWithin claims the next job of a priority whose jobs and those of
+// lower priorities run on fewer workers than its limit, and counts it as running until runOne is done
+// with it.
+func (q *Queue) claimWithin(ctx context.Context, limits map[Priority]int) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var open []Priority
+	for p, limit := range limits {
+		busy := 0
+		for below, n := range q.running {
+			if below <= p {
+				busy += n
+			}
+		}
+		if busy < limit {
+			open = append(open, p)
+		}
+	}
+	job, err := q.claim(ctx, open)
+	if job != nil {
+		q.running[job.Priority]++
+	}
+	return job, err
+}
+
+// claim marks the queued job of the highest of priorities, the oldest of
+// that priority, running and returns it, or nil when there is none.
+func (q *Queue) claim(ctx context.Context, priorities []Priority) (*Job, error) {
+	if len(priorities) == 0 {
+		return nil, nil
+	}
+	args := []any{Queued}
+	for _, p := range priorities {
+		args = append(args, p)
+	}
+	var job *Job
+	err := q.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		var (
+			j          Job
+			enqueuedAt int64
+		)
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, namespace, priority, payload, enqueued_at FROM jobs
+			WHERE status = ? AND priority IN (?`+strings.Repeat(", ?", len(priorities)-1)+`)
+			ORDER BY priority DESC, enqueued_at, rowid LIMIT 1
+		`, args...).Scan(&j.ID, &j.Namespace, &j.Priority, &j.Payload, &enqueuedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE jobs SET status = ?, started_at = ? WHERE id = ?", Running, now.Unix(), j.ID); err != nil {
+			return err
+		}
+		j.Status = Running
+		j.EnqueuedAt = time.Unix(enqueuedAt, 0).UTC()
+		started := now.UTC().Truncate(time.Second)
+		j.StartedAt = &started
+		job = &j
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+	return job, nil
+}
+
+// Purge deletes jobs that finished more than the retention ago, and jobs
+// that started that long ago but never finished, left running by a replica
+// that died. It is intended to be run periodically by the serve command.
+func (q *Queue) Purge(ctx context.Context) error {
+	if q.retention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-q.retention).Unix()
+	res, err := q.db.ExecContext(ctx, `
+		DELETE FROM jobs WHERE status = ? AND finished_at < ? OR status = ? AND started_at < ?
+	`, Done, cutoff, Running, cutoff)
+	if err != nil {
+		return fmt.Errorf("purge jobs: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		q.log.Info("jobs purged", zap.Int64("count", n))
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/jobs"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func openQueue(t *testing.T, retention time.Duration) *jobs.Queue {
+	t.Helper()
+	// Workers claim jobs on their own connections, so the store must be
+	// one database across connections, which :memory: is not.
+	db, err := store.Open(filepath.Join(t.TempDir(), "jobs.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	return jobs.New(db, zaptest.NewLogger(t), retention)
+}
+
+// run runs q with handle on two workers until the test ends.
+func run(t *testing.T, q *jobs.Queue, handle jobs.Handler) {
+	t.Helper()
+	runWorkers(t, q, 2, handle)
+}
+
+func runWorkers(t *testing.T, q *jobs.Queue, workers int, handle jobs.Handler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, workers, jobs.DefaultShares, handle)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitDone(t *testing.T, q *jobs.Queue, id string) *jobs.Job {
+	t.Helper()
+	var job *jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status == jobs.Done
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestQueue_RunsJobs(t *testing.T) {
+	ctx := context.Background()
+	q := openQueue(t, time.Hour)
+	require.NoError(t, q.Enqueue(ctx, "a", "default", jobs.PriorityNormal, []byte("1")))
+	require.NoError(t, q.Enqueue(ctx, "b", "acme", jobs.PriorityNormal, []byte("2")))
+
+	job, err := q.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Queued, job.Status)
+	assert.Equal(t, []byte("1"), job.Payload)
+	_, err = q.Get(ctx, "missing")
+	assert.ErrorIs(t, err, jobs.ErrNotFound)
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]string{}
+	)
+	run(t, q, func(_ context.Context, job *jobs.Job) ([]byte, error) {
+		mu.Lock()
+		seen[job.ID] = job.Namespace
+		mu.Unlock()
+		return append([]byte("result "), job.Payload...), nil
+	})
+
+	job = waitDone(t, q, "a")
+	assert.Equal(t, []byte("result 1"), job.Result)
+	assert.Nil(t, job.Payload, "dropped once done")
+	assert.NotNil(t, job.FinishedAt)
+	job = waitDone(t, q, "b")
+	assert.Equal(t, []byte("result 2"), job.Result)
+
+	// Jobs enqueued while the workers wait are picked up.
+	require.NoError(t, q.Enqueue(ctx, "c", "default", jobs.PriorityNormal, nil))
+	waitDone(t, q, "c")
+	mu.Lock()
+	assert.Equal(t, map[string]string{"a": "default", "b": "acme", "c": "default"}, seen)
+	mu.Unlock()
+}
+
+func TestQueue_RequeuesOnError(t *testing.T) {
+	ctx := context.Background()
+	q := openQueue(t, time.Hour)
+	require.NoError(t, q.Enqueue(ctx, "a", "default", jobs.PriorityNormal, nil))
+	var (
+		mu    sync.Mutex
+		tries int
+	)
+	run(t, q, func(context.Context, *jobs.Job) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tries++; tries == 1 {
+			return nil, errors.New("not now")
+		}
+		return []byte("ok"), nil
+	})
+	job := waitDone(t, q, "a")
+	assert.Equal(t, []byte("ok"), job.Result)
+	mu.Lock()
+	assert.Equal(t, 2, tries)
+	mu.Unlock()
+}
+
+func TestQueue_Purge(t *testing.T) {
+	ctx := context.Background()
+	q := openQueue(t, time.Nanosecond)
+	require.NoError(t, q.Enqueue(ctx, "a", "default", jobs.PriorityNormal, nil))
+	require.NoError(t, q.Enqueue(ctx, "b", "default", jobs.PriorityNormal, nil))
+	run(t, q, func(context.Context, *jobs.Job) ([]byte, error) { return nil, nil })
+	waitDone(t, q, "a")
+	waitDone(t, q, "b")
+
+	time.Sleep(time.Second) // finished_at has a resolution of one second
+	require.NoError(t, q.Purge(ctx))
+	_, err := q.Get(ctx, "a")
+	assert.ErrorIs(t, err, jobs.ErrNotFound)
+}
+
+func TestQueue_Priorities(t *testing.T) {
+	ctx := context.Background()
+	q := openQueue(t, time.Hour)
+	require.NoError(t, q.Enqueue(ctx, "low", "default", jobs.PriorityLow, nil))
+	require.NoError(t, q.Enqueue(ctx, "normal", "default", jobs.PriorityNormal, nil))
+	require.NoError(t, q.Enqueue(ctx, "high", "default", jobs.PriorityHigh, nil))
+	job, err := q.Get(ctx, "high")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.PriorityHigh, job.Priority)
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	runWorkers(t, q, 1, func(_ context.Context, job *jobs.Job) ([]byte, error) {
+		mu.Lock()
+		order = append(order, job.ID)
+		mu.Unlock()
+		return nil, nil
+	})
+	waitDone(t, q, "low")
+	mu.Lock()
+	assert.Equal(t, []string{"high", "normal", "low"}, order)
+	mu.Unlock()
+}
+
+func TestQueue_Shares(t *testing.T) {
+	ctx := context.Background()
+	q := openQueue(t, time.Hour)
+	for _, id := range []string{"l1", "l2", "l3", "l4"} {
+		require.NoError(t, q.Enqueue(ctx, id, "default", jobs.PriorityLow, nil))
+	}
+	release := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		running int
+		most    int
+	)
+	runWorkers(t, q, 4, func(_ context.Context, job *jobs.Job) ([]byte, error) {
+		if job.Priority == jobs.PriorityLow {
+			mu.Lock()
+			running++
+			most = max(most, running)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}
+		return nil, nil
+	})
+
+	// A low priority job holds the one worker low priority jobs may
+	// occupy; the others stay free for interactive work.
+	require.NoError(t, q.Enqueue(ctx, "h", "default", jobs.PriorityHigh, nil))
+	waitDone(t, q, "h")
+	close(release)
+	waitDone(t, q, "l4")
+	mu.Lock()
+	assert.Equal(t, 1, most, "a quarter of 4 workers")
+	mu.Unlock()
+}
+
+func TestParsePriority(t *testing.T) {
+	p, err := jobs.ParsePriority("")
+	require.NoError(t, err)
+	assert.Equal(t, jobs.PriorityNormal, p)
+	p, err = jobs.ParsePriority("high")
+	require.NoError(t, err)
+	assert.Equal(t, "high", p.String())
+	_, err = jobs.ParsePriority("urgent")
+	assert.Error(t, err)
+}
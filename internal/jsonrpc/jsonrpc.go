@@ -0,0 +1,172 @@
+// Package jsonrpc calls providers that speak JSON-RPC 2.0 over HTTP, one
+// call at a time or several in a batch.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrProtocol is returned for a response that is not valid JSON-RPC 2.0.
+var ErrProtocol = errors.New("invalid json-rpc response")
+
+// maxResponse bounds the response body of one call or batch.
+const maxResponse = 16 << 20
+
+// Error is an error object returned by the server.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// Call is one method call of a batch.
+type Call struct {
+	Method string
+	Params json.RawMessage
+}
+
+// Result is the outcome of one call: its result, or the error the server
+// returned for it.
+type Result struct {
+	Value json.RawMessage
+	Err   error
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+}
+
+// Client posts JSON-RPC requests over HTTP.
+type Client struct {
+	hc *http.Client
+}
+
+// NewClient returns a client that sends requests with hc.
+func NewClient(hc *http.Client) *Client {
+	return &Client{hc: hc}
+}
+
+// Call calls method at url. header is added to the HTTP request, e.g. to
+// carry the provider's credentials.
+func (c *Client) Call(ctx context.Context, url string, header http.Header, method string, params json.RawMessage) (json.RawMessage, error) {
+	body, err := json.Marshal(request{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.post(ctx, url, header, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+	}
+	return resp.value()
+}
+
+// Batch sends calls to url as one JSON-RPC batch and returns their results
+// in the order of calls. The error is set when the batch as a whole failed.
+func (c *Client) Batch(ctx context.Context, url string, header http.Header, calls []Call) ([]Result, error) {
+	reqs := make([]request, len(calls))
+	for i, call := range calls {
+		reqs[i] = request{JSONRPC: "2.0", ID: i + 1, Method: call.Method, Params: call.Params}
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.post(ctx, url, header, body)
+	if err != nil {
+		return nil, err
+	}
+	var resps []response
+	if err := json.Unmarshal(raw, &resps); err != nil {
+		// A server rejecting the whole batch answers with a single error.
+		var single response
+		if json.Unmarshal(raw, &single) == nil && single.Error != nil {
+			return nil, single.Error
+		}
+		return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+	}
+
+	results := make([]Result, len(calls))
+	answered := make([]bool, len(calls))
+	for _, resp := range resps {
+		id, err := strconv.Atoi(string(resp.ID))
+		if err != nil || id < 1 || id > len(calls) || answered[id-1] {
+			continue
+		}
+		answered[id-1] = true
+		results[id-1].Value, results[id-1].Err = resp.value()
+	}
+	for i := range results {
+		if !answered[i] {
+			results[i].Err = fmt.Errorf("%w: no response to call %d", ErrProtocol, i+1)
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) post(ctx context.Context, url string, header http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxResponse {
+		return nil, fmt.Errorf("%w: response exceeds %d bytes", ErrProtocol, maxResponse)
+	}
+	// Servers may answer errors with a non-2xx status and a JSON-RPC body;
+	// only a body that is not JSON is a transport failure.
+	if resp.StatusCode/100 != 2 && !json.Valid(raw) {
+		return nil, fmt.Errorf("json-rpc: %s returned %s", url, resp.Status)
+	}
+	return raw, nil
+}
+
+func (r *response) value() (json.RawMessage, error) {
+	if r.JSONRPC != "2.0" {
+		return nil, fmt.Errorf("%w: jsonrpc is %q", ErrProtocol, r.JSONRPC)
+	}
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if r.Result == nil {
+		return nil, fmt.Errorf("%w: no result", ErrProtocol)
+	}
+	return r.Result, nil
+}
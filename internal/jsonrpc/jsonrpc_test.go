@@ -0,0 +1,156 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/jsonrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// server answers each request with its params, or with an error for method
+// "fail", and drops method "drop". Batch responses come back reversed.
+func server(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Key"))
+		body, _ := io.ReadAll(r.Body)
+		answer := func(req map[string]any) any {
+			switch req["method"] {
+			case "fail":
+				return map[string]any{"jsonrpc": "2.0", "id": req["id"], "error": map[string]any{"code": -32000, "message": "boom"}}
+			case "drop":
+				return nil
+			}
+			return map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": req["params"]}
+		}
+		var batch []map[string]any
+		if json.Unmarshal(body, &batch) == nil {
+			var out []any
+			for i := len(batch) - 1; i >= 0; i-- {
+				if a := answer(batch[i]); a != nil {
+					out = append(out, a)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req map[string]any
+		require.NoError(t, json.Unmarshal(body, &req))
+		_ = json.NewEncoder(w).Encode(answer(req))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCall(t *testing.T) {
+	srv := server(t)
+	c := jsonrpc.NewClient(srv.Client())
+	header := http.Header{"X-Key": {"secret"}}
+	ctx := context.Background()
+
+	out, err := c.Call(ctx, srv.URL, header, "echo", json.RawMessage(`{"n":1}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":1}`, string(out))
+
+	_, err = c.Call(ctx, srv.URL, header, "fail", nil)
+	var rpcErr *jsonrpc.Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32000, rpcErr.Code)
+
+	_, err = c.Call(ctx, srv.URL, header, "drop", nil)
+	assert.ErrorIs(t, err, jsonrpc.ErrProtocol)
+}
+
+func TestBatch(t *testing.T) {
+	srv := server(t)
+	c := jsonrpc.NewClient(srv.Client())
+	results, err := c.Batch(context.Background(), srv.URL, http.Header{"X-Key": {"secret"}}, []jsonrpc.Call{
+		{Method: "echo", Params: json.RawMessage(`{"a":1}`)},
+		{Method: "fail"},
+		{Method: "drop"},
+		{Method: "echo", Params: json.RawMessage(`{"b":2}`)},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.JSONEq(t, `{"a":1}`, string(results[0].Value))
+	assert.Error(t, results[1].Err)
+	assert.ErrorIs(t, results[2].Err, jsonrpc.ErrProtocol)
+	assert.JSONEq(t, `{"b":2}`, string(results[3].Value))
+}
+
+func TestBatch_Rejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batches not supported"}}`)
+	}))
+	defer srv.Close()
+
+	_, err := jsonrpc.NewClient(srv.Client()).Batch(context.Background(), srv.URL, nil, []jsonrpc.Call{{Method: "echo"}})
+	var rpcErr *jsonrpc.Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32600, rpcErr.Code)
+}
+
+func TestCall_InvalidResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"not json", http.StatusOK, `<html>`, "invalid json-rpc response"},
+		{"not json-rpc 2.0", http.StatusOK, `{"jsonrpc":"1.0","id":1,"result":1}`, `invalid json-rpc response: jsonrpc is "1.0"`},
+		{"http error", http.StatusBadGateway, `bad gateway`, "returned 502 Bad Gateway"},
+		{"too large", http.StatusOK, `"` + strings.Repeat("a", 16<<20) + `"`, "response exceeds 16777216 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			_, err := jsonrpc.NewClient(srv.Client()).Call(context.Background(), srv.URL, nil, "echo", nil)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	_, err := jsonrpc.NewClient(http.DefaultClient).Call(context.Background(), "http://\x7f", nil, "echo", nil)
+	assert.Error(t, err, "invalid url")
+	_, err = jsonrpc.NewClient(http.DefaultClient).Batch(context.Background(), "http://\x7f", nil, []jsonrpc.Call{{Method: "echo"}})
+	assert.Error(t, err, "invalid url")
+}
+
+func TestBatch_InvalidResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `[{"jsonrpc":"2.0","id":"x","result":1},{"jsonrpc":"2.0","id":1,"result":1},`+
+			`{"jsonrpc":"2.0","id":1,"result":2},{"jsonrpc":"2.0","id":9,"result":3}]`)
+	}))
+	defer srv.Close()
+	c := jsonrpc.NewClient(srv.Client())
+
+	results, err := c.Batch(context.Background(), srv.URL, nil, []jsonrpc.Call{{Method: "a"}, {Method: "b"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `1`, string(results[0].Value), "the first answer to a call counts")
+	assert.ErrorContains(t, results[1].Err, "no response to call 2")
+
+	_, err = c.Call(context.Background(), srv.URL, nil, "a", nil)
+	assert.ErrorIs(t, err, jsonrpc.ErrProtocol)
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":1}`)
+	}))
+	defer broken.Close()
+	_, err = c.Batch(context.Background(), broken.URL, nil, []jsonrpc.Call{{Method: "a"}})
+	assert.ErrorIs(t, err, jsonrpc.ErrProtocol, "a single response to a batch")
+
+	assert.Equal(t, "json-rpc error -32601: method not found", (&jsonrpc.Error{Code: -32601, Message: "method not found"}).Error())
+}
@@ -0,0 +1,301 @@
+// Package jsonschema validates JSON values against the JSON Schemas tools
+// declare for their input and output. It implements the validation
+// keywords those schemas use — types, properties, items, enums, bounds,
+// lengths, patterns and the boolean combinators — and ignores the rest,
+// such as $ref and format, which accept anything.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError is the first place a value breaks its schema. Path is a
+// JSON pointer into the value: /items/0/name.
+type ValidationError struct {
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return e.Path + ": " + e.Msg
+}
+
+// Validate checks value, as decoded by encoding/json, against schema. An
+// empty schema accepts anything. It returns a *ValidationError when value
+// does not conform, and another error when schema is not valid JSON.
+func Validate(schema json.RawMessage, value any) error {
+	if len(strings.TrimSpace(string(schema))) == 0 {
+		return nil
+	}
+	var s any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	return validate("", s, value)
+}
+
+func validate(path string, schema, v any) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return fail(path, "no value is allowed")
+		}
+		return nil
+	case map[string]any:
+		// The first failure is reported.
+		for _, check := range []func(string, map[string]any, any) error{
+			checkType, checkEnum, checkNumber, checkString, checkArray, checkObject, checkCombinators,
+		} {
+			if err := check(path, s, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fail(path, format string, args ...any) error {
+	return &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)}
+}
+
+func checkType(path string, s map[string]any, v any) error {
+	var types []string
+	switch t := s["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, m := range t {
+			if name, ok := m.(string); ok {
+				types = append(types, name)
+			}
+		}
+	default:
+		return nil
+	}
+	for _, t := range types {
+		if isType(t, v) {
+			return nil
+		}
+	}
+	return fail(path, "must be %s, not %s", strings.Join(types, " or "), typeOf(v))
+}
+
+func isType(t string, v any) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return typeOf(v) == t
+	}
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func checkEnum(path string, s map[string]any, v any) error {
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fail(path, "must be %v", c)
+	}
+	enum, ok := s["enum"].([]any)
+	if !ok {
+		return nil
+	}
+	for _, m := range enum {
+		if reflect.DeepEqual(m, v) {
+			return nil
+		}
+	}
+	return fail(path, "must be one of %v", enum)
+}
+
+func checkNumber(path string, s map[string]any, v any) error {
+	n, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	if m, ok := s["minimum"].(float64); ok && n < m {
+		return fail(path, "must be at least %v", m)
+	}
+	if m, ok := s["maximum"].(float64); ok && n > m {
+		return fail(path, "must be at most %v", m)
+	}
+	if m, ok := s["exclusiveMinimum"].(float64); ok && n <= m {
+		return fail(path, "must be greater than %v", m)
+	}
+	if m, ok := s["exclusiveMaximum"].(float64); ok && n >= m {
+		return fail(path, "must be less than %v", m)
+	}
+	if m, ok := s["multipleOf"].(float64); ok && m > 0 && math.Mod(n, m) != 0 {
+		return fail(path, "must be a multiple of %v", m)
+	}
+	return nil
+}
+
+func checkString(path string, s map[string]any, v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	n := float64(utf8.RuneCountInString(str))
+	if m, ok := s["minLength"].(float64); ok && n < m {
+		return fail(path, "must be at least %v characters", m)
+	}
+	if m, ok := s["maxLength"].(float64); ok && n > m {
+		return fail(path, "must be at most %v characters", m)
+	}
+	if p, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(p)
+		if err == nil && !re.MatchString(str) {
+			return fail(path, "must match %s", p)
+		}
+	}
+	return nil
+}
+
+func checkArray(path string, s map[string]any, v any) error {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	n := float64(len(arr))
+	if m, ok := s["minItems"].(float64); ok && n < m {
+		return fail(path, "must have at least %v items", m)
+	}
+	if m, ok := s["maxItems"].(float64); ok && n > m {
+		return fail(path, "must have at most %v items", m)
+	}
+	if s["uniqueItems"] == true {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					return fail(path, "items %d and %d are equal", j, i)
+				}
+			}
+		}
+	}
+	items, ok := s["items"]
+	if !ok {
+		return nil
+	}
+	for i, item := range arr {
+		if err := validate(fmt.Sprintf("%s/%d", path, i), items, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkObject(path string, s map[string]any, v any) error {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if required, ok := s["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, ok := obj[name]; !ok {
+					return fail(path, "%s is required", name)
+				}
+			}
+		}
+	}
+	n := float64(len(obj))
+	if m, ok := s["minProperties"].(float64); ok && n < m {
+		return fail(path, "must have at least %v properties", m)
+	}
+	if m, ok := s["maxProperties"].(float64); ok && n > m {
+		return fail(path, "must have at most %v properties", m)
+	}
+	props, _ := s["properties"].(map[string]any)
+	additional, hasAdditional := s["additionalProperties"]
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	// Sorted, so the same value always reports the same error.
+	sort.Strings(names)
+	for _, name := range names {
+		p := path + "/" + escape(name)
+		if ps, ok := props[name]; ok {
+			if err := validate(p, ps, obj[name]); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if additional == false {
+			return fail(p, "is not allowed")
+		}
+		if err := validate(p, additional, obj[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkCombinators(path string, s map[string]any, v any) error {
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := validate(path, sub, v); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok && matches(path, anyOf, v) == 0 {
+		return fail(path, "must match at least one schema of anyOf")
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok && matches(path, oneOf, v) != 1 {
+		return fail(path, "must match exactly one schema of oneOf")
+	}
+	if not, ok := s["not"]; ok && validate(path, not, v) == nil {
+		return fail(path, "must not match the schema of not")
+	}
+	return nil
+}
+
+// matches counts the schemas v is valid against.
+func matches(path string, schemas []any, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if validate(path, sub, v) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// escape escapes a key for use as a JSON pointer token.
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,109 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"required": ["city"],
+		"additionalProperties": false,
+		"properties": {
+			"city": {"type": "string", "minLength": 2, "pattern": "^[A-Z]"},
+			"days": {"type": "integer", "minimum": 1, "maximum": 14},
+			"units": {"enum": ["metric", "imperial"]},
+			"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+			"at": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+		}
+	}`)
+	for _, tc := range []struct {
+		value, path string
+	}{
+		{`{"city": "Oslo"}`, ""},
+		{`{"city": "Oslo", "days": 3, "units": "metric", "tags": ["a"], "at": null}`, ""},
+		{`[]`, "-"},
+		{`{}`, "-"},
+		{`{"city": "oslo"}`, "/city"},
+		{`{"city": "O"}`, "/city"},
+		{`{"city": "Oslo", "days": 1.5}`, "/days"},
+		{`{"city": "Oslo", "days": 15}`, "/days"},
+		{`{"city": "Oslo", "units": "kelvin"}`, "/units"},
+		{`{"city": "Oslo", "tags": ["a", 1]}`, "/tags/1"},
+		{`{"city": "Oslo", "tags": ["a", "b", "c"]}`, "/tags"},
+		{`{"city": "Oslo", "at": 5}`, "/at"},
+		{`{"city": "Oslo", "extra": true}`, "/extra"},
+	} {
+		err := jsonschema.Validate(schema, decode(t, tc.value))
+		if tc.path == "" {
+			assert.NoError(t, err, tc.value)
+			continue
+		}
+		var verr *jsonschema.ValidationError
+		require.True(t, errors.As(err, &verr), "%s: %v", tc.value, err)
+		if tc.path != "-" {
+			assert.Equal(t, tc.path, verr.Path, tc.value)
+		}
+	}
+}
+
+func TestValidate_EmptyAndInvalidSchemas(t *testing.T) {
+	assert.NoError(t, jsonschema.Validate(nil, decode(t, `{"any": 1}`)))
+	assert.NoError(t, jsonschema.Validate(json.RawMessage(`{}`), decode(t, `[1]`)))
+	assert.NoError(t, jsonschema.Validate(json.RawMessage(`true`), decode(t, `1`)))
+	assert.Error(t, jsonschema.Validate(json.RawMessage(`false`), decode(t, `1`)))
+
+	err := jsonschema.Validate(json.RawMessage(`{`), decode(t, `1`))
+	var verr *jsonschema.ValidationError
+	assert.False(t, errors.As(err, &verr), "a broken schema is not the value's fault")
+}
+
+func TestValidate_Keywords(t *testing.T) {
+	for _, tc := range []struct {
+		schema, value, err string
+	}{
+		{`{"const": "a"}`, `"a"`, ""},
+		{`{"const": "a"}`, `"b"`, "must be a"},
+		{`{"type": ["number", "boolean"]}`, `true`, ""},
+		{`{"type": ["number", "boolean"]}`, `"x"`, "must be number or boolean, not string"},
+		{`{"type": "number"}`, `{}`, "must be number, not object"},
+		{`{"minimum": 2}`, `1`, "must be at least 2"},
+		{`{"exclusiveMinimum": 1}`, `1`, "must be greater than 1"},
+		{`{"exclusiveMaximum": 1}`, `1`, "must be less than 1"},
+		{`{"multipleOf": 0.5}`, `1.5`, ""},
+		{`{"multipleOf": 2}`, `3`, "must be a multiple of 2"},
+		{`{"maxLength": 2}`, `"abc"`, "must be at most 2 characters"},
+		{`{"minItems": 2}`, `[1]`, "must have at least 2 items"},
+		{`{"uniqueItems": true}`, `[1, {"a": 1}, 2]`, ""},
+		{`{"uniqueItems": true}`, `[1, {"a": 1}, {"a": 1}]`, "items 1 and 2 are equal"},
+		{`{"minProperties": 1}`, `{}`, "must have at least 1 properties"},
+		{`{"maxProperties": 1}`, `{"a": 1, "b": 2}`, "must have at most 1 properties"},
+		{`{"properties": {"a": {}}, "additionalProperties": {"type": "string"}}`, `{"a": 1, "b": "x"}`, ""},
+		{`{"additionalProperties": {"type": "string"}}`, `{"b": 1}`, "/b: must be string, not number"},
+		{`{"allOf": [{"type": "integer"}, {"minimum": 2}]}`, `3`, ""},
+		{`{"allOf": [{"type": "integer"}, {"minimum": 2}]}`, `1`, "must be at least 2"},
+		{`{"oneOf": [{"type": "integer"}, {"type": "number"}]}`, `1.5`, ""},
+		{`{"oneOf": [{"type": "integer"}, {"type": "number"}]}`, `1`, "must match exactly one schema of oneOf"},
+		{`{"not": {"type": "null"}}`, `null`, "must not match the schema of not"},
+	} {
+		err := jsonschema.Validate(json.RawMessage(tc.schema), decode(t, tc.value))
+		if tc.err == "" {
+			assert.NoError(t, err, "%s %s", tc.schema, tc.value)
+			continue
+		}
+		assert.EqualError(t, err, tc.err, "%s %s", tc.schema, tc.value)
+	}
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrToolFailed is returned by CallEndpoint when the server reports the
+// tool call as failed.
+var ErrToolFailed = errors.New("mcp tool failed")
+
+// bridgeInfo is how the registry introduces itself to the MCP servers of
+// imported tools.
+var bridgeInfo = Implementation{Name: "agent-tools", Version: "0.1.0"}
+
+// CallEndpoint calls the tool behind endpoint, made by ToolEndpoint, with
+// the JSON object input as its arguments, over a connection of its own
+// through hc. It returns the tool's result as a JSON object: the object a
+// result of one text block holds, as the registry's own MCP server
+// answers, or else {"content": [...]} with the result's blocks.
+func CallEndpoint(ctx context.Context, hc *http.Client, endpoint string, input []byte) ([]byte, error) {
+	serverURL, tool, ok := ParseToolEndpoint(endpoint)
+	if !ok {
+		return nil, fmt.Errorf("not an mcp endpoint: %s", endpoint)
+	}
+	var args map[string]any
+	if err := json.Unmarshal(input, &args); err != nil {
+		return nil, fmt.Errorf("decode input: %w", err)
+	}
+	c, err := Dial(ctx, serverURL, hc, bridgeInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	res, err := c.CallTool(ctx, tool, args)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError {
+		texts := make([]string, 0, len(res.Content))
+		for _, b := range res.Content {
+			texts = append(texts, b.Text)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrToolFailed, strings.Join(texts, "\n"))
+	}
+	if len(res.Content) == 1 && res.Content[0].Type == "text" {
+		var out map[string]any
+		if json.Unmarshal([]byte(res.Content[0].Text), &out) == nil && out != nil {
+			return []byte(res.Content[0].Text), nil
+		}
+	}
+	return json.Marshal(map[string]any{"content": res.Content})
+}
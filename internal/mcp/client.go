@@ -0,0 +1,294 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned for calls on a Client whose event stream has ended.
+var ErrClosed = errors.New("mcp connection closed")
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// clientResponse is a response as the client decodes it.
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// Client talks to an MCP server over the HTTP+SSE transport.
+type Client struct {
+	url      string
+	hc       *http.Client
+	messages string
+	server   Implementation
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *clientResponse
+}
+
+// Dial opens the event stream at sseURL, waits for the server to announce
+// its message endpoint and performs the initialize handshake. hc nil uses
+// http.DefaultClient; it must not time out whole requests, which would end
+// the stream.
+func Dial(ctx context.Context, sseURL string, hc *http.Client, info Implementation) (*Client, error) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	base, err := url.Parse(sseURL)
+	if err != nil {
+		return nil, err
+	}
+	// The stream outlives ctx, which only bounds the handshake.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, sseURL, http.NoBody)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := hc.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("connect %s: %w", sseURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("connect %s: http %d", sseURL, resp.StatusCode)
+	}
+
+	c := &Client{
+		url:     sseURL,
+		hc:      hc,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pending: make(map[string]chan *clientResponse),
+	}
+	endpoint := make(chan string, 1)
+	go c.read(resp.Body, endpoint)
+
+	select {
+	case ep := <-endpoint:
+		ref, err := url.Parse(ep)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("invalid message endpoint %q: %w", ep, err)
+		}
+		c.messages = base.ResolveReference(ref).String()
+	case <-c.done:
+		c.Close()
+		return nil, fmt.Errorf("connect %s: %w", sseURL, c.err)
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+
+	var res initializeResult
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      info,
+	}, &res)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	c.server = res.ServerInfo
+	if err := c.notify(ctx, "notifications/initialized"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// URL returns the SSE URL the client connected to.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// ServerInfo returns the name and version the server reported.
+func (c *Client) ServerInfo() Implementation {
+	return c.server
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = listToolsParams{Cursor: cursor}
+		}
+		var res listToolsResult
+		if err := c.call(ctx, "tools/list", params, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" || res.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Close ends the event stream. Calls in flight fail with ErrClosed.
+func (c *Client) Close() {
+	c.cancel()
+	<-c.done
+}
+
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	ch := make(chan *clientResponse, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.post(ctx, id, method, params); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, out)
+	case <-c.done:
+		return fmt.Errorf("%s: %w", method, ErrClosed)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(ctx context.Context, method string) error {
+	return c.post(ctx, "", method, nil)
+}
+
+func (c *Client) post(ctx context.Context, id, method string, params any) error {
+	msg := request{JSONRPC: "2.0", Method: method}
+	if id != "" {
+		msg.ID = json.RawMessage(id)
+	}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = b
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.messages, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: http %d", method, resp.StatusCode)
+	}
+	return nil
+}
+
+// read consumes the event stream, sending the first endpoint event to
+// endpoint and routing message events to their callers.
+func (c *Client) read(body io.ReadCloser, endpoint chan<- string) {
+	defer close(c.done)
+	defer func() { _ = body.Close() }()
+
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64<<10), maxMessage)
+	var (
+		event string
+		data  []string
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				c.dispatch(event, strings.Join(data, "\n"), endpoint)
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	c.err = sc.Err()
+	if c.err == nil {
+		c.err = io.EOF
+	}
+}
+
+func (c *Client) dispatch(event, data string, endpoint chan<- string) {
+	switch event {
+	case "endpoint":
+		select {
+		case endpoint <- data:
+		default:
+		}
+	case "message", "":
+		var resp clientResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil || resp.ID == nil {
+			// Server requests and notifications are not supported; skip them.
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[string(resp.ID)]
+		c.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+	}
+}
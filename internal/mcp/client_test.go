@@ -0,0 +1,266 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func dialTest(t *testing.T) (*mcp.Client, string) {
+	t.Helper()
+	srv := httptest.NewServer(newServer().SSEHandler(""))
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := mcp.Dial(ctx, srv.URL+"/sse", nil, mcp.Implementation{Name: "client", Version: "1"})
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+	return c, srv.URL + "/sse"
+}
+
+func TestClient(t *testing.T) {
+	c, _ := dialTest(t)
+	ctx := context.Background()
+	assert.Equal(t, "test", c.ServerInfo().Name)
+
+	tools, err := c.ListTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 2, "both pages are fetched")
+	assert.Equal(t, "echo", tools[0].Name)
+
+	res, err := c.CallTool(ctx, "echo", map[string]any{"n": 2})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":2}`, res.Content[0].Text)
+
+	_, err = c.CallTool(ctx, "nope", nil)
+	assert.ErrorContains(t, err, "-32602")
+}
+
+func TestDial_Errors(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := mcp.Dial(ctx, srv.URL+"/sse", nil, mcp.Implementation{})
+	assert.ErrorContains(t, err, "http 404")
+
+	// A stream that ends before announcing its endpoint.
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer closed.Close()
+	_, err = mcp.Dial(ctx, closed.URL, nil, mcp.Implementation{})
+	assert.Error(t, err)
+
+	_, err = mcp.Dial(ctx, "://no-scheme", nil, mcp.Implementation{})
+	assert.Error(t, err)
+	_, err = mcp.Dial(ctx, "http://\x7f/sse", nil, mcp.Implementation{})
+	assert.Error(t, err)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	_, err = mcp.Dial(ctx, down.URL+"/sse", nil, mcp.Implementation{})
+	assert.ErrorContains(t, err, "connect "+down.URL)
+}
+
+// scriptedServer announces endpoint on its event stream and answers each
+// message posted to /messages with status, echoing its ID in a response on
+// the stream when respond is set. A status of 0 holds the stream open
+// without announcing an endpoint.
+func scriptedServer(t *testing.T, endpoint string, status func(method string) int, respond bool) *httptest.Server {
+	t.Helper()
+	events := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var msg struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+			code := status(msg.Method)
+			if respond && code/100 == 2 && msg.ID != nil {
+				events <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"serverInfo":{"name":"scripted"}}}`, msg.ID)
+			}
+			w.WriteHeader(code)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if endpoint != "" {
+			_, _ = fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", endpoint)
+		}
+		w.(http.Flusher).Flush()
+		if status == nil {
+			<-r.Context().Done()
+			return
+		}
+		for {
+			select {
+			case ev := <-events:
+				_, _ = fmt.Fprintf(w, ": keep-alive\nevent: message\ndata: %s\n\n", ev)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+				if !respond {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDial_Handshake(t *testing.T) {
+	accept := func(string) int { return http.StatusAccepted }
+	tests := []struct {
+		name     string
+		endpoint string
+		status   func(method string) int
+		respond  bool
+		want     string
+	}{
+		{"no endpoint", "", nil, false, context.DeadlineExceeded.Error()},
+		{"invalid endpoint", "%zz", accept, false, `invalid message endpoint "%zz"`},
+		{"initialize refused", "/messages", func(string) int { return http.StatusInternalServerError }, false,
+			"initialize: initialize: http 500"},
+		{"stream ends", "/messages", accept, false, "initialize: initialize: " + mcp.ErrClosed.Error()},
+		{"initialized refused", "/messages", func(method string) int {
+			if method == "initialize" {
+				return http.StatusAccepted
+			}
+			return http.StatusBadRequest
+		}, true, "notifications/initialized: http 400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := scriptedServer(t, tt.endpoint, tt.status, tt.respond)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, err := mcp.Dial(ctx, srv.URL+"/sse", nil, mcp.Implementation{})
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	srv := scriptedServer(t, "/messages", func(string) int { return http.StatusAccepted }, true)
+	c, err := mcp.Dial(context.Background(), srv.URL+"/sse", nil, mcp.Implementation{})
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "scripted", c.ServerInfo().Name)
+}
+
+func TestToolEndpoint(t *testing.T) {
+	ep := mcp.ToolEndpoint("https://mcp.example.com/sse", "get file")
+	assert.Equal(t, "mcp+https://mcp.example.com/sse#get%20file", ep)
+	server, tool, ok := mcp.ParseToolEndpoint(ep)
+	require.True(t, ok)
+	assert.Equal(t, "https://mcp.example.com/sse", server)
+	assert.Equal(t, "get file", tool)
+
+	_, _, ok = mcp.ParseToolEndpoint("https://mcp.example.com/sse#x")
+	assert.False(t, ok)
+	_, _, ok = mcp.ParseToolEndpoint("mcp+https://mcp.example.com/sse")
+	assert.False(t, ok)
+}
+
+func TestImport(t *testing.T) {
+	c, sseURL := dialTest(t)
+
+	var (
+		mu   sync.Mutex
+		regs []map[string]any
+	)
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		regs = append(regs, req)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if req["name"] == "fs.other" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = io.WriteString(w, `{"error":{"code":"DUPLICATE","message":"exists"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "t1", "name": req["name"], "version": req["version"]})
+	}))
+	defer reg.Close()
+
+	results, err := mcp.Import(context.Background(), c, agenttools.NewClient(reg.URL), mcp.ImportOptions{
+		Prefix: "fs.",
+		Tags:   []string{"mcp"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "fs.echo", results[0].Tool.Name)
+	assert.ErrorContains(t, results[1].Err, "DUPLICATE")
+
+	require.Len(t, regs, 2)
+	first := regs[0]
+	assert.Equal(t, "1", first["version"], "defaults to the server version")
+	assert.Equal(t, mcp.ToolEndpoint(sseURL, "echo"), first["endpoint"])
+	assert.True(t, strings.HasPrefix(first["endpoint"].(string), "mcp+http://"))
+	assert.Equal(t, map[string]any{"input": map[string]any{"type": "object"}}, first["schema"])
+	assert.Equal(t, []any{"mcp"}, first["tags"])
+}
+
+// resultBackend answers every call with the result of the tool's name.
+type resultBackend map[string]*mcp.CallToolResult
+
+func (b resultBackend) ListTools(context.Context, string) ([]mcp.Tool, string, error) {
+	return nil, "", nil
+}
+
+func (b resultBackend) CallTool(_ context.Context, name string, _ map[string]any) (*mcp.CallToolResult, error) {
+	if res, ok := b[name]; ok {
+		return res, nil
+	}
+	return nil, fmt.Errorf("%w: %s", mcp.ErrUnknownTool, name)
+}
+
+func TestCallEndpoint(t *testing.T) {
+	backend := resultBackend{
+		"object": {Content: []mcp.Content{{Type: "text", Text: `{"sum": 3}`}}},
+		"text":   {Content: []mcp.Content{{Type: "text", Text: "three"}}},
+		"failed": {Content: []mcp.Content{{Type: "text", Text: "division by zero"}, {Type: "text", Text: "at line 1"}}, IsError: true},
+	}
+	srv := httptest.NewServer(mcp.NewServer(backend, mcp.Implementation{Name: "calc", Version: "1"}, zap.NewNop()).SSEHandler(""))
+	defer srv.Close()
+	ctx := context.Background()
+	endpoint := func(tool string) string { return mcp.ToolEndpoint(srv.URL+"/sse", tool) }
+
+	out, err := mcp.CallEndpoint(ctx, nil, endpoint("object"), []byte(`{"a": 1, "b": 2}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sum": 3}`, string(out), "a JSON object result is the output")
+
+	out, err = mcp.CallEndpoint(ctx, nil, endpoint("text"), []byte(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"content": [{"type": "text", "text": "three"}]}`, string(out), "other results keep their blocks")
+
+	_, err = mcp.CallEndpoint(ctx, nil, endpoint("failed"), []byte(`{}`))
+	require.ErrorIs(t, err, mcp.ErrToolFailed)
+	assert.ErrorContains(t, err, "division by zero\nat line 1")
+
+	_, err = mcp.CallEndpoint(ctx, nil, endpoint("missing"), []byte(`{}`))
+	assert.ErrorContains(t, err, "-32602")
+	_, err = mcp.CallEndpoint(ctx, nil, endpoint("object"), []byte(`[1]`))
+	assert.ErrorContains(t, err, "decode input")
+	_, err = mcp.CallEndpoint(ctx, nil, srv.URL+"/sse", []byte(`{}`))
+	assert.ErrorContains(t, err, "not an mcp endpoint")
+	_, err = mcp.CallEndpoint(ctx, nil, mcp.ToolEndpoint(srv.URL+"/missing", "object"), []byte(`{}`))
+	assert.ErrorContains(t, err, "http 404")
+}
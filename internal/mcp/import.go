@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+)
+
+// endpointPrefix marks the endpoints of tools served by an MCP server:
+// mcp+https://host/sse#tool_name calls tool_name on the server whose event
+// stream is at https://host/sse.
+const endpointPrefix = "mcp+"
+
+// ToolEndpoint returns the registry endpoint of tool on the MCP server at
+// serverURL.
+func ToolEndpoint(serverURL, tool string) string {
+	base, _, _ := strings.Cut(serverURL, "#")
+	return endpointPrefix + base + "#" + url.PathEscape(tool)
+}
+
+// ParseToolEndpoint splits an endpoint made by ToolEndpoint.
+func ParseToolEndpoint(endpoint string) (serverURL, tool string, ok bool) {
+	rest, found := strings.CutPrefix(endpoint, endpointPrefix)
+	if !found {
+		return "", "", false
+	}
+	serverURL, frag, found := strings.Cut(rest, "#")
+	if !found || frag == "" {
+		return "", "", false
+	}
+	tool, err := url.PathUnescape(frag)
+	if err != nil {
+		return "", "", false
+	}
+	return serverURL, tool, true
+}
+
+// ImportOptions control how MCP tools are registered.
+type ImportOptions struct {
+	// Prefix is prepended to every tool name, e.g. "github." to group the
+	// tools of one server.
+	Prefix string
+	// Version is registered for every tool. MCP tools carry no version of
+	// their own, so the server's reported version is used when empty.
+	Version   string
+	Tags      []string
+	Pricing   *agenttools.Pricing
+	TimeoutMS int64
+}
+
+// ImportResult reports the registration of one MCP tool.
+type ImportResult struct {
+	Name string
+	Tool *agenttools.Tool
+	Err  error
+}
+
+// Import registers every tool of the MCP server behind c in the registry
+// behind reg, with the server as their endpoint. A tool that fails to
+// register does not stop the others; its error is in its result.
+func Import(ctx context.Context, c *Client, reg *agenttools.Client, opts ImportOptions) ([]ImportResult, error) {
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version := opts.Version
+	if version == "" {
+		version = c.ServerInfo().Version
+	}
+	if version == "" {
+		version = "0.0.0"
+	}
+	results := make([]ImportResult, 0, len(tools))
+	for _, t := range tools {
+		schema := map[string]any{"input": inputSchemaOf(t)}
+		req := &agenttools.RegisterToolRequest{
+			Name:        opts.Prefix + t.Name,
+			Version:     version,
+			Description: t.Description,
+			Endpoint:    ToolEndpoint(c.URL(), t.Name),
+			Schema:      schema,
+			Pricing:     opts.Pricing,
+			Tags:        opts.Tags,
+			TimeoutMS:   opts.TimeoutMS,
+		}
+		tool, err := reg.RegisterTool(ctx, req)
+		results = append(results, ImportResult{Name: req.Name, Tool: tool, Err: err})
+	}
+	return results, nil
+}
+
+func inputSchemaOf(t Tool) any {
+	if len(t.InputSchema) == 0 {
+		return emptySchema
+	}
+	return t.InputSchema
+}
@@ -0,0 +1,172 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/clawinfra/agent-tools/internal/export"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+)
+
+// maxNameLen is the longest tool name MCP clients accept.
+const maxNameLen = 64
+
+// pageSize is how many registry tools one tools/list page carries.
+const pageSize = 50
+
+// emptySchema is advertised for tools registered without an input schema.
+var emptySchema = json.RawMessage(`{"type":"object"}`)
+
+// RegistryBackend exposes the tools of an agent-tools registry, calling them
+// through its invocation endpoint so pricing, receipts and the usual
+// sandboxing all apply.
+type RegistryBackend struct {
+	client *agenttools.Client
+	query  string
+	tag    string
+	// collection and version, when collection is set, limit the backend
+	// to the tools of that collection.
+	collection string
+	version    string
+
+	mu    sync.RWMutex
+	names map[string]string // MCP name -> tool ID
+}
+
+// NewRegistryBackend creates a backend on client. When query or tag is set
+// only tools matching the search are exposed.
+func NewRegistryBackend(client *agenttools.Client, query, tag string) *RegistryBackend {
+	return &RegistryBackend{client: client, query: query, tag: tag, names: make(map[string]string)}
+}
+
+// NewCollectionBackend creates a backend on client that exposes the tools
+// of a collection, installing it as a unit: version pins it, the latest
+// version is used when it is empty.
+func NewCollectionBackend(client *agenttools.Client, name, version string) *RegistryBackend {
+	return &RegistryBackend{client: client, collection: name, version: version, names: make(map[string]string)}
+}
+
+// ListTools implements Backend. Cursors are registry listing cursors.
+func (b *RegistryBackend) ListTools(ctx context.Context, cursor string) ([]Tool, string, error) {
+	tools, next, err := b.fetch(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	out := make([]Tool, 0, len(tools))
+	b.mu.Lock()
+	for _, t := range tools {
+		name := ToolName(t)
+		b.names[name] = t.ID
+		out = append(out, Tool{Name: name, Description: describe(t), InputSchema: inputSchema(t)})
+	}
+	b.mu.Unlock()
+	return out, next, nil
+}
+
+// fetch returns the page of tools at cursor and the cursor of the next.
+// Search and collections have no pages, so a filtered listing is always a
+// single page.
+func (b *RegistryBackend) fetch(ctx context.Context, cursor string) ([]*agenttools.Tool, string, error) {
+	if b.collection != "" {
+		if cursor != "" {
+			return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+		}
+		c, err := b.client.GetCollection(ctx, b.collection, b.version)
+		if err != nil {
+			return nil, "", err
+		}
+		return c.Tools, "", nil
+	}
+	if b.query != "" || b.tag != "" {
+		if cursor != "" {
+			return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+		}
+		opts := []agenttools.SearchOption{agenttools.WithLimit(100)}
+		if b.tag != "" {
+			opts = append(opts, agenttools.WithTag(b.tag))
+		}
+		res, err := b.client.SearchTools(ctx, b.query, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		return res.Tools, "", nil
+	}
+	list, err := b.client.ListTools(ctx, &agenttools.ListToolsRequest{Cursor: cursor, Limit: pageSize})
+	if err != nil {
+		return nil, "", err
+	}
+	return list.Tools, list.NextCursor, nil
+}
+
+// CallTool implements Backend.
+func (b *RegistryBackend) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	id, err := b.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Invoke(ctx, id, args)
+	if err != nil {
+		// The registry answered, or could not be reached; either way the
+		// model should see why the call failed rather than a protocol error.
+		return &CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	out, err := json.Marshal(resp.Output)
+	if err != nil {
+		return nil, err
+	}
+	return &CallToolResult{Content: []Content{{Type: "text", Text: string(out)}}}, nil
+}
+
+// resolve maps an MCP name back to a tool ID, relisting the registry when a
+// client calls a tool it learned about from an earlier session.
+func (b *RegistryBackend) resolve(ctx context.Context, name string) (string, error) {
+	b.mu.RLock()
+	id, ok := b.names[name]
+	b.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+	for cursor := ""; ; {
+		_, next, err := b.ListTools(ctx, cursor)
+		if err != nil {
+			return "", err
+		}
+		b.mu.RLock()
+		id, ok = b.names[name]
+		b.mu.RUnlock()
+		if ok {
+			return id, nil
+		}
+		if next == "" {
+			return "", fmt.Errorf("%w: %s", ErrUnknownTool, name)
+		}
+		cursor = next
+	}
+}
+
+// ToolName returns the MCP name of t: its registry name reduced to the
+// characters MCP allows, suffixed with a prefix of its ID so that versions
+// and same-named tools of different providers stay distinct.
+func ToolName(t *agenttools.Tool) string {
+	suffix := export.IDSuffix(t.ID)
+	return export.SanitizeName(t.Name, maxNameLen-len(suffix)-1) + "_" + suffix
+}
+
+func describe(t *agenttools.Tool) string {
+	d := strings.TrimSpace(t.Description)
+	meta := fmt.Sprintf("[%s@%s, price: %s]", t.Name, t.Version, t.Pricing.String())
+	if d == "" {
+		return meta
+	}
+	return d + " " + meta
+}
+
+func inputSchema(t *agenttools.Tool) json.RawMessage {
+	if len(t.Schema.Input) == 0 || string(t.Schema.Input) == "null" {
+		return emptySchema
+	}
+	return t.Schema.Input
+}
@@ -0,0 +1,195 @@
+// Package mcp serves registry tools over the Model Context Protocol, so MCP
+// clients such as Claude can discover and call them directly. It speaks
+// JSON-RPC 2.0 over stdio or the HTTP+SSE transport.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnknownTool is returned by a Backend for a tool name it does not know.
+var ErrUnknownTool = errors.New("unknown tool")
+
+// Backend supplies the tools a Server exposes.
+type Backend interface {
+	// ListTools returns one page of tools and the cursor of the next page,
+	// or "" after the last.
+	ListTools(ctx context.Context, cursor string) ([]Tool, string, error)
+	// CallTool invokes the named tool. Failures of the tool itself belong in
+	// the result with IsError set; an error means the call could not be made.
+	CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error)
+}
+
+// Server answers MCP requests from a Backend.
+type Server struct {
+	backend Backend
+	info    Implementation
+	log     *zap.Logger
+}
+
+// NewServer creates a Server identifying itself with info.
+func NewServer(b Backend, info Implementation, log *zap.Logger) *Server {
+	return &Server{backend: b, info: info, log: log}
+}
+
+// Handle processes one JSON-RPC message, or a batch, and returns the reply.
+// It returns nil for notifications, which get no reply.
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil || len(batch) == 0 {
+			return s.encode(errorResponse(nil, codeInvalidRequest, "invalid batch"))
+		}
+		var replies []json.RawMessage
+		for _, m := range batch {
+			if r := s.Handle(ctx, m); r != nil {
+				replies = append(replies, r)
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		return s.encode(replies)
+	}
+
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return s.encode(errorResponse(nil, codeParseError, "parse error"))
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return s.encode(errorResponse(req.ID, codeInvalidRequest, "invalid request"))
+	}
+	result, rerr := s.dispatch(ctx, &req)
+	if req.ID == nil {
+		return nil
+	}
+	if rerr != nil {
+		return s.encode(&response{JSONRPC: "2.0", ID: req.ID, Error: rerr})
+	}
+	return s.encode(&response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) dispatch(ctx context.Context, req *request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var p initializeParams
+		if err := unmarshalParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		s.log.Info("mcp client connected",
+			zap.String("client", p.ClientInfo.Name), zap.String("protocol", p.ProtocolVersion))
+		return &initializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+			Instructions:    "Tools from the agent-tools registry. Descriptions show each tool's version and price.",
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		var p listToolsParams
+		if err := unmarshalParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		tools, next, err := s.backend.ListTools(ctx, p.Cursor)
+		if err != nil {
+			s.log.Error("mcp list tools", zap.Error(err))
+			return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		if tools == nil {
+			tools = []Tool{}
+		}
+		return &listToolsResult{Tools: tools, NextCursor: next}, nil
+	case "tools/call":
+		var p callToolParams
+		if err := unmarshalParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		res, err := s.backend.CallTool(ctx, p.Name, p.Arguments)
+		if errors.Is(err, ErrUnknownTool) {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		if err != nil {
+			s.log.Error("mcp call tool", zap.String("tool", p.Name), zap.Error(err))
+			return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		return res, nil
+	}
+	if req.ID == nil {
+		// Notifications such as notifications/initialized need no handling.
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+}
+
+func unmarshalParams(raw json.RawMessage, v any) *rpcError {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: msg}}
+}
+
+func (s *Server) encode(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		s.log.Error("mcp encode response", zap.Error(err))
+		return nil
+	}
+	return b
+}
+
+// maxMessage bounds one stdio message.
+const maxMessage = 4 << 20
+
+// ServeStdio reads newline-delimited messages from in and writes replies to
+// out until in is closed or ctx is done. Requests are handled concurrently so
+// a slow tool call does not block pings or listings.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 64<<10), maxMessage)
+	for sc.Scan() {
+		msg := bytes.Clone(sc.Bytes())
+		if len(bytes.TrimSpace(msg)) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply := s.Handle(ctx, msg)
+			if reply == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = out.Write(append(reply, '\n'))
+		}()
+	}
+	wg.Wait()
+	return sc.Err()
+}
@@ -0,0 +1,285 @@
+package mcp_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/mcp"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeBackend struct{}
+
+func (fakeBackend) ListTools(_ context.Context, cursor string) ([]mcp.Tool, string, error) {
+	if cursor == "" {
+		return []mcp.Tool{{Name: "echo", InputSchema: json.RawMessage(`{"type":"object"}`)}}, "2", nil
+	}
+	return []mcp.Tool{{Name: "other", InputSchema: json.RawMessage(`{"type":"object"}`)}}, "", nil
+}
+
+func (fakeBackend) CallTool(_ context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	if name != "echo" {
+		return nil, fmt.Errorf("%w: %s", mcp.ErrUnknownTool, name)
+	}
+	b, _ := json.Marshal(args)
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: string(b)}}}, nil
+}
+
+func newServer() *mcp.Server {
+	return mcp.NewServer(fakeBackend{}, mcp.Implementation{Name: "test", Version: "1"}, zap.NewNop())
+}
+
+func call(t *testing.T, s *mcp.Server, msg string) map[string]any {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(msg))
+	require.NotNil(t, out)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(out, &resp))
+	return resp
+}
+
+func TestHandle_Initialize(t *testing.T) {
+	resp := call(t, newServer(), `{"jsonrpc":"2.0","id":1,"method":"initialize",`+
+		`"params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"c","version":"1"}}}`)
+	result := resp["result"].(map[string]any)
+	assert.Equal(t, mcp.ProtocolVersion, result["protocolVersion"])
+	assert.Contains(t, result["capabilities"], "tools")
+	assert.Equal(t, "test", result["serverInfo"].(map[string]any)["name"])
+}
+
+func TestHandle_ListToolsPaginates(t *testing.T) {
+	s := newServer()
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	result := resp["result"].(map[string]any)
+	assert.Equal(t, "2", result["nextCursor"])
+	assert.Len(t, result["tools"], 1)
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{"cursor":"2"}}`)
+	result = resp["result"].(map[string]any)
+	assert.NotContains(t, result, "nextCursor")
+	assert.Equal(t, "other", result["tools"].([]any)[0].(map[string]any)["name"])
+}
+
+func TestHandle_CallTool(t *testing.T) {
+	resp := call(t, newServer(), `{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"echo","arguments":{"x":1}}}`)
+	assert.Equal(t, "a", resp["id"])
+	content := resp["result"].(map[string]any)["content"].([]any)
+	assert.Equal(t, `{"x":1}`, content[0].(map[string]any)["text"])
+}
+
+func TestHandle_Errors(t *testing.T) {
+	s := newServer()
+	code := func(resp map[string]any) float64 { return resp["error"].(map[string]any)["code"].(float64) }
+
+	assert.Equal(t, float64(-32700), code(call(t, s, `{not json`)))
+	assert.Equal(t, float64(-32600), code(call(t, s, `{"id":1,"method":"ping"}`)))
+	assert.Equal(t, float64(-32601), code(call(t, s, `{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)))
+	assert.Equal(t, float64(-32602), code(call(t, s, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"nope"}}`)))
+}
+
+func TestHandle_NotificationsAndBatches(t *testing.T) {
+	s := newServer()
+	assert.Nil(t, s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+
+	out := s.Handle(context.Background(), []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},`+
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}]`))
+	var replies []map[string]any
+	require.NoError(t, json.Unmarshal(out, &replies))
+	require.Len(t, replies, 1)
+	assert.Equal(t, float64(1), replies[0]["id"])
+}
+
+func TestServeStdio(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n")
+	var out strings.Builder
+	require.NoError(t, newServer().ServeStdio(context.Background(), in, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	ids := map[float64]bool{}
+	for _, l := range lines {
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal([]byte(l), &resp))
+		ids[resp["id"].(float64)] = true
+	}
+	assert.Equal(t, map[float64]bool{1: true, 2: true}, ids)
+}
+
+func TestSSE(t *testing.T) {
+	srv := httptest.NewServer(newServer().SSEHandler("/mcp"))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/mcp/sse", http.NoBody)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewReader(resp.Body)
+	event, data := readEvent(t, events)
+	require.Equal(t, "endpoint", event)
+	require.True(t, strings.HasPrefix(data, "/mcp/messages?sessionId="))
+
+	post, err := http.Post(srv.URL+data, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"echo","arguments":{"k":"v"}}}`))
+	require.NoError(t, err)
+	_ = post.Body.Close()
+	assert.Equal(t, http.StatusAccepted, post.StatusCode)
+
+	event, data = readEvent(t, events)
+	assert.Equal(t, "message", event)
+	assert.Contains(t, data, `"id":7`)
+	assert.Contains(t, data, `{\"k\":\"v\"}`)
+
+	unknown, err := http.Post(srv.URL+"/mcp/messages?sessionId=nope", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_ = unknown.Body.Close()
+	assert.Equal(t, http.StatusNotFound, unknown.StatusCode)
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestRegistryBackend(t *testing.T) {
+	var invoked map[string]any
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/tools":
+			_, _ = io.WriteString(w, `{"total":1,"page":1,"limit":50,"tools":[{"id":"0a1b2c3d-4e5f","name":"web.search",`+
+				`"version":"1.2.0","description":"Search the web.","pricing":{"model":"per_call","amount_claw":"0.5"},`+
+				`"schema":{"input":{"type":"object","properties":{"q":{"type":"string"}}}}}]}`)
+		case "/v1/invoke":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&invoked))
+			if invoked["input"].(map[string]any)["q"] == "fail" {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = io.WriteString(w, `{"error":{"code":"TOOL_FAILED","message":"provider down"}}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"invocation_id":"inv-1","tool_id":"0a1b2c3d-4e5f","output":{"hits":3},"duration_ms":4}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer reg.Close()
+
+	b := mcp.NewRegistryBackend(agenttools.NewClient(reg.URL), "", "")
+	ctx := context.Background()
+	tools, next, err := b.ListTools(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "web_search_0a1b2c3d", tools[0].Name)
+	assert.Contains(t, tools[0].Description, "Search the web.")
+	assert.Contains(t, tools[0].Description, "web.search@1.2.0, price: 0.5 CLAW/per_call")
+	assert.JSONEq(t, `{"type":"object","properties":{"q":{"type":"string"}}}`, string(tools[0].InputSchema))
+
+	// A fresh backend resolves names it has not listed yet.
+	b = mcp.NewRegistryBackend(agenttools.NewClient(reg.URL), "", "")
+	res, err := b.CallTool(ctx, "web_search_0a1b2c3d", map[string]any{"q": "go"})
+	require.NoError(t, err)
+	assert.False(t, res.IsError)
+	assert.JSONEq(t, `{"hits":3}`, res.Content[0].Text)
+	assert.Equal(t, "0a1b2c3d-4e5f", invoked["tool_id"])
+
+	res, err = b.CallTool(ctx, "web_search_0a1b2c3d", map[string]any{"q": "fail"})
+	require.NoError(t, err)
+	assert.True(t, res.IsError)
+	assert.Contains(t, res.Content[0].Text, "TOOL_FAILED")
+
+	_, err = b.CallTool(ctx, "missing_00000000", nil)
+	assert.ErrorIs(t, err, mcp.ErrUnknownTool)
+}
+
+func TestToolName(t *testing.T) {
+	long := strings.Repeat("x", 80)
+	name := mcp.ToolName(&agenttools.Tool{ID: "abcdef12-3456", Name: long})
+	assert.Len(t, name, 64)
+	assert.True(t, strings.HasSuffix(name, "_abcdef12"))
+	assert.Equal(t, "a_b_c-d_abcdef12", mcp.ToolName(&agenttools.Tool{ID: "abcdef12-3456", Name: "a.b c-d"}))
+	assert.Equal(t, "lint_9f86d081", mcp.ToolName(&agenttools.Tool{ID: "did:claw:tool:9f86d081884c7d65", Name: "lint"}))
+}
+
+func TestCollectionBackend(t *testing.T) {
+	var query string
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/collections/audit-suite", r.URL.Path)
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"name":"audit-suite","version":"1.0.0","tool_ids":["0a1b2c3d-4e5f","ffff0000-1111"],`+
+			`"tools":[{"id":"0a1b2c3d-4e5f","name":"slither","version":"1.0.0"}],"unavailable":["ffff0000-1111"]}`)
+	}))
+	defer reg.Close()
+
+	b := mcp.NewCollectionBackend(agenttools.NewClient(reg.URL), "audit-suite", "1.0.0")
+	tools, next, err := b.ListTools(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, tools, 1, "unavailable tools are not served")
+	assert.Equal(t, "slither_0a1b2c3d", tools[0].Name)
+	assert.Equal(t, "version=1.0.0", query)
+}
+
+func TestRegistryBackend_Search(t *testing.T) {
+	var query url.Values
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/tools/search" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"total":1,"tools":[{"id":"0a1b2c3d-4e5f","name":"slither","version":"1.0.0"}]}`)
+	}))
+	defer reg.Close()
+	ctx := context.Background()
+
+	b := mcp.NewRegistryBackend(agenttools.NewClient(reg.URL), "audit", "solidity")
+	tools, next, err := b.ListTools(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, next, "search results are a single page")
+	require.Len(t, tools, 1)
+	assert.Equal(t, "slither_0a1b2c3d", tools[0].Name)
+	assert.Equal(t, "audit", query.Get("q"))
+	assert.Equal(t, "solidity", query.Get("tag"))
+	assert.Equal(t, "100", query.Get("limit"))
+
+	_, _, err = b.ListTools(ctx, "2")
+	assert.ErrorContains(t, err, "invalid cursor")
+	_, _, err = mcp.NewCollectionBackend(agenttools.NewClient(reg.URL), "audit-suite", "").ListTools(ctx, "2")
+	assert.ErrorContains(t, err, "invalid cursor")
+	_, _, err = mcp.NewCollectionBackend(agenttools.NewClient(reg.URL), "audit-suite", "").ListTools(ctx, "")
+	assert.Error(t, err, "a collection the registry does not know")
+	_, err = mcp.NewRegistryBackend(agenttools.NewClient(reg.URL), "", "").CallTool(ctx, "slither_0a1b2c3d", nil)
+	assert.Error(t, err, "the listing fails")
+}
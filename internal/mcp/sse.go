@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not close it.
+const sseKeepAlive = 30 * time.Second
+
+// SSEHandler serves the HTTP+SSE transport: a client opens GET /sse, is told
+// where to POST its messages, and receives the replies as "message" events
+// on the stream. Mount it at the prefix the endpoint event should carry.
+func (s *Server) SSEHandler(prefix string) http.Handler {
+	t := &sseTransport{server: s, prefix: prefix, sessions: make(map[string]chan []byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/sse", t.stream)
+	mux.HandleFunc("POST "+prefix+"/messages", t.message)
+	return mux
+}
+
+type sseTransport struct {
+	server *Server
+	prefix string
+
+	mu       sync.Mutex
+	sessions map[string]chan []byte
+}
+
+func (t *sseTransport) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(raw[:])
+	ch := make(chan []byte, 16)
+	t.mu.Lock()
+	t.sessions[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.sessions, id)
+		t.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: endpoint\ndata: %s/messages?sessionId=%s\n\n", t.prefix, id)
+	flusher.Flush()
+
+	tick := time.NewTicker(sseKeepAlive)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+		case msg := <-ch:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+		}
+		flusher.Flush()
+	}
+}
+
+func (t *sseTransport) message(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	ch, ok := t.sessions[r.URL.Query().Get("sessionId")]
+	t.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessage))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Replies go out on the stream; the POST is only acknowledged. The
+	// request context ends with this handler, so the call runs detached
+	// from it.
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		reply := t.server.Handle(context.WithoutCancel(r.Context()), body)
+		if reply == nil {
+			return
+		}
+		select {
+		case ch <- reply:
+		case <-time.After(sseKeepAlive):
+			t.server.log.Warn("mcp sse client not reading; reply dropped")
+		}
+	}()
+}
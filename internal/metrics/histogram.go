@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are upper bounds in seconds suited to tool
+// invocations, which range from cache hits to multi-second model calls.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Exemplar links an observation to a specific trace or invocation.
+type Exemplar struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// HistogramVec is a set of histograms partitioned by labels.
+type HistogramVec struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts    []uint64 // per bucket, non-cumulative; last entry is +Inf
+	exemplars []*Exemplar
+	sum       float64
+	count     uint64
+}
+
+// Histogram returns the histogram family with the given name, creating it
+// on first use with the given bucket upper bounds.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[name]; ok {
+		return h
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: b, series: make(map[string]*histogram)}
+	r.histograms[name] = h
+	return h
+}
+
+// Observe records v for the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.ObserveWithExemplar(v, nil, labelValues...)
+}
+
+// ObserveWithExemplar records v and, when exemplar labels are given, keeps
+// them as the latest exemplar of the bucket v falls into.
+func (h *HistogramVec) ObserveWithExemplar(v float64, exemplar map[string]string, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{
+			counts:    make([]uint64, len(h.buckets)+1),
+			exemplars: make([]*Exemplar, len(h.buckets)+1),
+		}
+		h.series[key] = s
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	if len(exemplar) > 0 {
+		s.exemplars[i] = &Exemplar{Labels: exemplar, Value: v}
+	}
+}
+
+// Count returns the number of observations for the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer, openMetrics bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		base := formatLabels(h.labels, k)
+		var cum uint64
+		for i := range s.counts {
+			cum += s.counts[i]
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = fmt.Sprintf("%g", h.buckets[i])
+			}
+			line := fmt.Sprintf("%s_bucket%s %d", h.name, withLabel(base, "le", le), cum)
+			if openMetrics && s.exemplars[i] != nil {
+				line += " # " + formatExemplar(s.exemplars[i])
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", h.name, base, s.sum, h.name, base, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLabel appends name="value" to an existing rendered label set.
+func withLabel(base, name, value string) string {
+	pair := fmt.Sprintf("%s=%q", name, value)
+	if base == "" {
+		return "{" + pair + "}"
+	}
+	return base[:len(base)-1] + "," + pair + "}"
+}
+
+func formatExemplar(e *Exemplar) string {
+	names := make([]string, 0, len(e.Labels))
+	for n := range e.Labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, n := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", n, e.Labels[n]))
+	}
+	return fmt.Sprintf("{%s} %g", strings.Join(pairs, ","), e.Value)
+}
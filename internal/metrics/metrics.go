@@ -0,0 +1,176 @@
+// Package metrics provides lightweight counters exposed in the Prometheus
+// text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Default is the process-wide metrics registry.
+var Default = NewRegistry()
+
+// openMetricsType is the content type of the OpenMetrics exposition format,
+// the only text format that carries exemplars.
+const openMetricsType = "application/openmetrics-text"
+
+// Registry holds named metric families.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]*CounterVec
+	histograms map[string]*HistogramVec
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   make(map[string]*CounterVec),
+		histograms: make(map[string]*HistogramVec),
+	}
+}
+
+// family is implemented by every metric type the registry can expose.
+type family interface {
+	write(w io.Writer, openMetrics bool) error
+}
+
+// Counter returns the counter family with the given name, creating it on
+// first use. Subsequent calls with the same name return the same family.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	r.counters[name] = c
+	return c
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	return r.write(w, false)
+}
+
+// WriteOpenMetrics writes all metrics in the OpenMetrics text format,
+// including histogram exemplars.
+func (r *Registry) WriteOpenMetrics(w io.Writer) error {
+	if err := r.write(w, true); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+func (r *Registry) write(w io.Writer, openMetrics bool) error {
+	r.mu.Lock()
+	families := make(map[string]family, len(r.counters)+len(r.histograms))
+	for name, c := range r.counters {
+		families[name] = c
+	}
+	for name, h := range r.histograms {
+		families[name] = h
+	}
+	r.mu.Unlock()
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := families[name].write(w, openMetrics); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an http.Handler serving the registry. Scrapers that
+// accept OpenMetrics get exemplars; everyone else gets the classic format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), openMetricsType) {
+			w.Header().Set("Content-Type", openMetricsType+"; version=1.0.0; charset=utf-8")
+			_ = r.WriteOpenMetrics(w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = r.WriteText(w)
+	})
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels.
+type CounterVec struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+// Inc increments the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter for the given label values by v.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// Value returns the current counter value for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *CounterVec) write(w io.Writer, openMetrics bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// OpenMetrics names the counter family without the _total suffix.
+	family := c.name
+	if openMetrics {
+		family = strings.TrimSuffix(c.name, "_total")
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", family, c.help, family); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s%s %g\n", c.name, formatLabels(c.labels, k), c.values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatLabels renders a label set such as {tool="x",status="ok"}.
+func formatLabels(names []string, key string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	values := strings.Split(key, "\xff")
+	pairs := make([]string, 0, len(names))
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%q", n, v))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
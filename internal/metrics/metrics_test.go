@@ -0,0 +1,105 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounter_IncAndValue(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.Counter("test_total", "A test counter.", "tool")
+	c.Inc("a")
+	c.Inc("a")
+	c.Add(3, "b")
+
+	assert.Equal(t, 2.0, c.Value("a"))
+	assert.Equal(t, 3.0, c.Value("b"))
+	assert.Equal(t, 0.0, c.Value("missing"))
+}
+
+func TestCounter_SameNameReturnsSameFamily(t *testing.T) {
+	r := metrics.NewRegistry()
+	a := r.Counter("dup_total", "help")
+	b := r.Counter("dup_total", "help")
+	a.Inc()
+	assert.Equal(t, 1.0, b.Value())
+}
+
+func TestWriteText(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("plain_total", "Plain counter.").Inc()
+	r.Counter("labelled_total", "Labelled counter.", "tool", "status").Inc("x", "ok")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE plain_total counter\nplain_total 1\n")
+	assert.Contains(t, out, `labelled_total{tool="x",status="ok"} 1`)
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte("labelled_total")), bytes.Index(buf.Bytes(), []byte("plain_total")))
+}
+
+func TestHandler(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("served_total", "Served.").Inc()
+
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rr.Body.String(), "served_total 1")
+}
+
+func TestHistogram_Buckets(t *testing.T) {
+	r := metrics.NewRegistry()
+	h := r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "tool")
+	h.Observe(0.05, "a")
+	h.Observe(0.5, "a")
+	h.Observe(5, "a")
+	assert.Equal(t, uint64(3), h.Count("a"))
+	assert.Zero(t, h.Count("b"))
+	assert.Same(t, h, r.Histogram("latency_seconds", "Latency.", nil, "tool"))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE latency_seconds histogram")
+	assert.Contains(t, out, `latency_seconds_bucket{tool="a",le="0.1"} 1`)
+	assert.Contains(t, out, `latency_seconds_bucket{tool="a",le="1"} 2`)
+	assert.Contains(t, out, `latency_seconds_bucket{tool="a",le="+Inf"} 3`)
+	assert.Contains(t, out, `latency_seconds_sum{tool="a"} 5.55`)
+	assert.Contains(t, out, `latency_seconds_count{tool="a"} 3`)
+}
+
+func TestHistogram_Exemplars(t *testing.T) {
+	r := metrics.NewRegistry()
+	h := r.Histogram("call_seconds", "Calls.", []float64{1})
+	h.ObserveWithExemplar(0.3, map[string]string{"invocation_id": "inv_1"})
+
+	var classic bytes.Buffer
+	require.NoError(t, r.WriteText(&classic))
+	assert.NotContains(t, classic.String(), "inv_1")
+
+	var om bytes.Buffer
+	require.NoError(t, r.WriteOpenMetrics(&om))
+	assert.Contains(t, om.String(), `call_seconds_bucket{le="1"} 1 # {invocation_id="inv_1"} 0.3`)
+	assert.True(t, bytes.HasSuffix(om.Bytes(), []byte("# EOF\n")))
+}
+
+func TestHandler_OpenMetricsNegotiation(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Counter("hits_total", "Hits.").Inc()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, req)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/openmetrics-text")
+	assert.Contains(t, rr.Body.String(), "# TYPE hits counter\nhits_total 1\n")
+	assert.Contains(t, rr.Body.String(), "# EOF")
+}
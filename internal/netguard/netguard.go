@@ -0,0 +1,196 @@
+// Package netguard stops provider-supplied endpoints from reaching the
+// registry's own infrastructure. Endpoints are checked when they are
+// registered and again when they are dialed, against the addresses DNS
+// actually returned, so a name that later resolves to an internal address is
+// still refused.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDenied is returned for an endpoint or address the policy refuses.
+var ErrDenied = errors.New("endpoint denied")
+
+// alwaysDenied are never reachable from provider endpoints: link-local
+// (including the 169.254.169.254 cloud metadata service), unspecified,
+// multicast and broadcast addresses.
+var alwaysDenied = mustPrefixes(
+	"169.254.0.0/16", "fe80::/10",
+	"0.0.0.0/8", "::/128",
+	"224.0.0.0/4", "ff00::/8",
+	"255.255.255.255/32",
+	"fd00:ec2::254/128", // AWS metadata over IPv6
+)
+
+// privateRanges are refused when Policy.BlockPrivate is set.
+var privateRanges = mustPrefixes(
+	"127.0.0.0/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+	"100.64.0.0/10", // carrier-grade NAT
+	"fc00::/7",
+)
+
+// metadataHosts name cloud metadata services without an IP literal.
+var metadataHosts = map[string]bool{
+	"metadata.google.internal": true,
+	"metadata.goog":            true,
+	"metadata":                 true,
+	"instance-data":            true,
+}
+
+// localSchemes are endpoints the registry runs itself, or that providers
+// poll for; they are never dialed.
+var localSchemes = map[string]bool{"wasm": true, "oci": true, "push": true}
+
+// Policy restricts the endpoints providers may register and the router may
+// dial. The zero value only refuses link-local, metadata, unspecified and
+// multicast destinations.
+type Policy struct {
+	// Schemes, when set, are the only URL schemes accepted, e.g. https and
+	// grpc. wasm://, oci:// and push:// endpoints are always accepted.
+	Schemes []string
+	// Ports, when set, are the only ports accepted. Endpoints without an
+	// explicit port use their scheme's default.
+	Ports []int
+	// BlockPrivate also refuses loopback, RFC 1918, CGNAT and unique local
+	// addresses. Leave it off only when providers run on a private network.
+	BlockPrivate bool
+}
+
+// CheckEndpoint validates an endpoint at registration time: its scheme,
+// port and, when the host is an IP literal or a well-known metadata name,
+// its address. Names are resolved only when dialed.
+func (p Policy) CheckEndpoint(endpoint string) error {
+	if scheme, _, ok := strings.Cut(endpoint, "://"); ok && localSchemes[strings.ToLower(scheme)] {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("%w: %q is not a URL", ErrDenied, endpoint)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.Schemes) > 0 && !slices.ContainsFunc(p.Schemes, func(s string) bool { return strings.EqualFold(s, scheme) }) {
+		return fmt.Errorf("%w: scheme %q is not allowed (allowed: %s)", ErrDenied, scheme, strings.Join(p.Schemes, ", "))
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: %q has no host", ErrDenied, endpoint)
+	}
+	if err := p.checkPort(u.Port(), scheme); err != nil {
+		return err
+	}
+	if metadataHosts[host] {
+		return fmt.Errorf("%w: %s is a metadata service", ErrDenied, host)
+	}
+	if p.BlockPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return fmt.Errorf("%w: %s is a loopback name", ErrDenied, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.CheckAddr(addr)
+	}
+	return nil
+}
+
+// defaultPorts are assumed for endpoints without an explicit port.
+var defaultPorts = map[string]int{
+	"http": 80, "https": 443, "ws": 80, "wss": 443,
+	"mcp+http": 80, "mcp+https": 443, // tools imported from MCP servers
+	"jsonrpc+http": 80, "jsonrpc+https": 443, // JSON-RPC 2.0 providers
+}
+
+func (p Policy) checkPort(port, scheme string) error {
+	if len(p.Ports) == 0 {
+		return nil
+	}
+	n, ok := defaultPorts[scheme]
+	if port != "" {
+		var err error
+		if n, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("%w: invalid port %q", ErrDenied, port)
+		}
+	} else if !ok {
+		return fmt.Errorf("%w: %s endpoints need an explicit port", ErrDenied, scheme)
+	}
+	if !slices.Contains(p.Ports, n) {
+		return fmt.Errorf("%w: port %d is not allowed", ErrDenied, n)
+	}
+	return nil
+}
+
+// CheckAddr reports whether the policy allows connecting to addr.
+func (p Policy) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, pfx := range alwaysDenied {
+		if pfx.Contains(addr) {
+			return fmt.Errorf("%w: %s is in %s", ErrDenied, addr, pfx)
+		}
+	}
+	if p.BlockPrivate {
+		for _, pfx := range privateRanges {
+			if pfx.Contains(addr) {
+				return fmt.Errorf("%w: %s is in private range %s", ErrDenied, addr, pfx)
+			}
+		}
+	}
+	return nil
+}
+
+// DialContext resolves addr, drops the addresses the policy refuses and
+// connects to the first allowed one. The connection is pinned to the address
+// that was checked, so DNS cannot be rebound between check and connect.
+func (p Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Ports) > 0 {
+		if n, _ := strconv.Atoi(port); !slices.Contains(p.Ports, n) {
+			return nil, fmt.Errorf("%w: port %s is not allowed", ErrDenied, port)
+		}
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	var denied error
+	for _, ip := range ips {
+		if err := p.CheckAddr(ip); err != nil {
+			denied = err
+			continue
+		}
+		return d.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+	}
+	if denied != nil {
+		return nil, fmt.Errorf("dial %s: %w", host, denied)
+	}
+	return nil, fmt.Errorf("dial %s: no addresses", host)
+}
+
+// Transport returns an HTTP transport that dials through the policy and
+// ignores proxy environment variables, which would bypass it.
+func (p Policy) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = p.DialContext
+	return t
+}
+
+func mustPrefixes(ss ...string) []netip.Prefix {
+	out := make([]netip.Prefix, len(ss))
+	for i, s := range ss {
+		out[i] = netip.MustParsePrefix(s)
+	}
+	return out
+}
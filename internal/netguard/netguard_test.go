@@ -0,0 +1,84 @@
+package netguard_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/netguard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckEndpoint(t *testing.T) {
+	cases := []struct {
+		policy   netguard.Policy
+		endpoint string
+		ok       bool
+	}{
+		{netguard.Policy{}, "https://tools.example.com/invoke", true},
+		{netguard.Policy{}, "grpc://localhost:50051", true},
+		{netguard.Policy{}, "http://169.254.169.254/latest/meta-data/", false},
+		{netguard.Policy{}, "http://[::ffff:169.254.169.254]/", false},
+		{netguard.Policy{}, "http://metadata.google.internal/computeMetadata/v1/", false},
+		{netguard.Policy{}, "http://0.0.0.0:8080", false},
+		{netguard.Policy{}, "not a url", false},
+		{netguard.Policy{}, "wasm://sha256:abc", true},
+		{netguard.Policy{BlockPrivate: true}, "grpc://localhost:50051", false},
+		{netguard.Policy{BlockPrivate: true}, "http://10.1.2.3/", false},
+		{netguard.Policy{BlockPrivate: true}, "https://tools.example.com", true},
+		{netguard.Policy{Schemes: []string{"https"}}, "http://tools.example.com", false},
+		{netguard.Policy{Schemes: []string{"https"}}, "HTTPS://tools.example.com", true},
+		{netguard.Policy{Schemes: []string{"https"}}, "oci://ghcr.io/acme/lint@sha256:abc", true},
+		{netguard.Policy{Schemes: []string{"https"}, BlockPrivate: true}, "push://workers", true},
+		{netguard.Policy{Ports: []int{443}}, "https://tools.example.com", true},
+		{netguard.Policy{Ports: []int{443}}, "https://tools.example.com:8443", false},
+		{netguard.Policy{Ports: []int{443}}, "mcp+https://mcp.example.com/sse#search", true},
+		{netguard.Policy{Ports: []int{443}}, "jsonrpc+https://rpc.example.com/rpc#lint.check", true},
+		{netguard.Policy{Ports: []int{443}}, "grpc://tools.example.com", false},
+	}
+	for _, c := range cases {
+		err := c.policy.CheckEndpoint(c.endpoint)
+		if c.ok {
+			assert.NoError(t, err, c.endpoint)
+		} else {
+			assert.ErrorIs(t, err, netguard.ErrDenied, c.endpoint)
+		}
+	}
+}
+
+func TestDialContext_ChecksResolvedAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// Dialing a name checks the address it resolves to.
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	url := "http://localhost:" + port
+
+	blocked := &http.Client{Transport: netguard.Policy{BlockPrivate: true}.Transport()}
+	_, err = blocked.Get(url) //nolint:noctx // test
+	assert.ErrorIs(t, err, netguard.ErrDenied)
+
+	allowed := &http.Client{Transport: netguard.Policy{}.Transport()}
+	resp, err := allowed.Get(url) //nolint:noctx // test
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	_, err = netguard.Policy{Ports: []int{443}}.DialContext(context.Background(), "tcp", "localhost:"+port)
+	assert.ErrorIs(t, err, netguard.ErrDenied)
+}
+
+func TestCheckAddr(t *testing.T) {
+	p := netguard.Policy{}
+	assert.ErrorIs(t, p.CheckAddr(netip.MustParseAddr("fe80::1")), netguard.ErrDenied)
+	assert.ErrorIs(t, p.CheckAddr(netip.MustParseAddr("fd00:ec2::254")), netguard.ErrDenied)
+	assert.NoError(t, p.CheckAddr(netip.MustParseAddr("127.0.0.1")))
+	assert.NoError(t, p.CheckAddr(netip.MustParseAddr("93.184.216.34")))
+}
@@ -0,0 +1,474 @@
+// Package policy compiles and evaluates the expressions of invocation
+// policies: comparisons of the attributes of an invocation, such as its
+// consumer's organizations or its tool's tags and price, combined with and,
+// or and not.
+//
+//	"acme" in consumer.orgs and not ("approved" in tool.tags and cost <= 1)
+//
+// Operands are strings in double quotes, numbers, true and false, lists in
+// brackets and attribute names. Comparisons are ==, !=, <, <=, > and >= of
+// strings or numbers, x in list, and s matches "pattern", where * in the
+// pattern matches any run of characters.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrSyntax is returned for an expression that does not compile.
+var ErrSyntax = errors.New("policy syntax")
+
+// ErrType is returned when an expression compares values of the wrong
+// types, such as a string with a number.
+var ErrType = errors.New("policy type error")
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of e.
+func (e *Expr) String() string { return e.src }
+
+// Compile parses src, which may only name the attributes in vars. A var
+// ending in "." admits every attribute under it, so "output." admits
+// output.status and output.items.count.
+func Compile(src string, vars []string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, vars: vars}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at %d", ErrSyntax, t.text, t.pos)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Eval evaluates e with the attributes in vars, whose values are strings,
+// float64s, bools or []strings.
+func (e *Expr) Eval(vars map[string]any) (bool, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: %s is %s, not true or false", ErrType, e.src, typeName(v))
+	}
+	return b, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokString
+	tokNumber
+	tokIdent
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrSyntax, i)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad string at %d", ErrSyntax, i)
+			}
+			toks = append(toks, token{tokString, s, i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] >= 'a' && src[j] <= 'z' ||
+				src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(src) && src[j] == '=' {
+				j++
+			}
+			op := src[i:j]
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("%w: unknown operator %q at %d", ErrSyntax, op, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i = j
+		case strings.ContainsRune("()[],", rune(c)):
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at %d", ErrSyntax, c, i)
+		}
+	}
+	return append(toks, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+type parser struct {
+	toks []token
+	vars []string
+}
+
+func (p *parser) peek() token { return p.toks[0] }
+
+func (p *parser) next() token {
+	t := p.toks[0]
+	if t.kind != tokEOF {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+// keyword reports whether the next token is the word w, and consumes it.
+func (p *parser) keyword(w string) bool {
+	if t := p.peek(); t.kind == tokIdent && t.text == w {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) error {
+	if t := p.next(); t.kind != tokPunct || t.text != punct {
+		return fmt.Errorf("%w: expected %q at %d, got %q", ErrSyntax, punct, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.keyword("not") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	switch t := p.peek(); {
+	case t.kind == tokOp:
+		op = t.text
+	case t.kind == tokIdent && (t.text == "in" || t.text == "matches"):
+		op = t.text
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if op == "matches" {
+		lit, ok := right.(literal)
+		s, isString := lit.v.(string)
+		if !ok || !isString {
+			return nil, fmt.Errorf("%w: matches takes a quoted pattern", ErrSyntax)
+		}
+		return &match{x: left, re: globRegexp(s)}, nil
+	}
+	return &compare{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) operand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad number %q at %d", ErrSyntax, t.text, t.pos)
+		}
+		return literal{f}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literal{t.text == "true"}, nil
+		case "and", "or", "not", "in", "matches":
+			return nil, fmt.Errorf("%w: unexpected %q at %d", ErrSyntax, t.text, t.pos)
+		}
+		if !p.known(t.text) {
+			return nil, fmt.Errorf("%w: unknown attribute %q at %d; known are %s",
+				ErrSyntax, t.text, t.pos, strings.Join(p.vars, ", "))
+		}
+		return attr(t.text), nil
+	case tokPunct:
+		switch t.text {
+		case "(":
+			x, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			return p.list()
+		}
+	}
+	return nil, fmt.Errorf("%w: unexpected %q at %d", ErrSyntax, t.text, t.pos)
+}
+
+// known reports whether name is an attribute the expression may name.
+func (p *parser) known(name string) bool {
+	return slices.ContainsFunc(p.vars, func(v string) bool {
+		if prefix, ok := strings.CutSuffix(v, "."); ok {
+			return len(name) > len(v) && strings.HasPrefix(name, prefix+".")
+		}
+		return v == name
+	})
+}
+
+// list parses the rest of a list of strings, after its "[".
+func (p *parser) list() (node, error) {
+	items := []string{}
+	if t := p.peek(); t.kind == tokPunct && t.text == "]" {
+		p.next()
+		return literal{items}, nil
+	}
+	for {
+		t := p.next()
+		if t.kind != tokString {
+			return nil, fmt.Errorf("%w: lists hold quoted strings, got %q at %d", ErrSyntax, t.text, t.pos)
+		}
+		items = append(items, t.text)
+		if t := p.next(); t.kind != tokPunct || t.text != "," && t.text != "]" {
+			return nil, fmt.Errorf("%w: expected \",\" or \"]\" at %d, got %q", ErrSyntax, t.pos, t.text)
+		} else if t.text == "]" {
+			return literal{items}, nil
+		}
+	}
+}
+
+type node interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literal struct{ v any }
+
+func (l literal) eval(map[string]any) (any, error) { return l.v, nil }
+
+type attr string
+
+func (a attr) eval(vars map[string]any) (any, error) {
+	v, ok := vars[string(a)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not set", ErrType, string(a))
+	}
+	return v, nil
+}
+
+type not struct{ x node }
+
+func (n *not) eval(vars map[string]any) (any, error) {
+	b, err := evalBool(n.x, vars)
+	return !b, err
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (l *logical) eval(vars map[string]any) (any, error) {
+	left, err := evalBool(l.left, vars)
+	if err != nil {
+		return nil, err
+	}
+	if left != l.and {
+		return left, nil
+	}
+	return evalBool(l.right, vars)
+}
+
+type match struct {
+	x  node
+	re *regexp.Regexp
+}
+
+func (m *match) eval(vars map[string]any) (any, error) {
+	v, err := m.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: matches needs a string, got %s", ErrType, typeName(v))
+	}
+	return m.re.MatchString(s), nil
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (c *compare) eval(vars map[string]any) (any, error) {
+	left, err := c.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if c.op == "in" {
+		list, ok := right.([]string)
+		s, isString := left.(string)
+		if !ok || !isString {
+			return nil, fmt.Errorf("%w: in needs a string and a list, got %s and %s", ErrType, typeName(left), typeName(right))
+		}
+		return slices.Contains(list, s), nil
+	}
+	switch l := left.(type) {
+	case string:
+		if r, ok := right.(string); ok {
+			return order(c.op, strings.Compare(l, r)), nil
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			switch {
+			case l < r:
+				return order(c.op, -1), nil
+			case l > r:
+				return order(c.op, 1), nil
+			}
+			return order(c.op, 0), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok && (c.op == "==" || c.op == "!=") {
+			return (l == r) == (c.op == "=="), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot compare %s %s %s", ErrType, typeName(left), c.op, typeName(right))
+}
+
+// order applies op to the result of comparing its operands.
+func order(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func evalBool(n node, vars map[string]any) (bool, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: expected true or false, got %s", ErrType, typeName(v))
+	}
+	return b, nil
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a bool"
+	case []string:
+		return "a list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// globRegexp compiles pattern, where * matches any run of characters and
+// everything else matches literally.
+func globRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
@@ -0,0 +1,112 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testVars = []string{"consumer.id", "consumer.orgs", "tool.tags", "cost", "cached"}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"consumer.id":   "did:claw:agent:alice",
+		"consumer.orgs": []string{"acme"},
+		"tool.tags":     []string{"security", "approved"},
+		"cost":          0.5,
+		"cached":        false,
+	}
+	cases := map[string]bool{
+		`"acme" in consumer.orgs`:                                       true,
+		`"globex" in consumer.orgs`:                                     false,
+		`"approved" in tool.tags and cost <= 1`:                         true,
+		`"approved" in tool.tags and cost > 1`:                          false,
+		`not "approved" in tool.tags or cost >= 1`:                      false,
+		`not ("approved" in tool.tags and cost < 0.25)`:                 true,
+		`consumer.id matches "did:claw:agent:*"`:                        true,
+		`consumer.id matches "did:key:*"`:                               false,
+		`consumer.id == "did:claw:agent:alice" and cached == false`:     true,
+		`consumer.id != "did:claw:agent:alice"`:                         false,
+		`consumer.id in ["did:claw:agent:bob", "did:claw:agent:alice"]`: true,
+		`"x" in []`:                    false,
+		`cost == 0.5 or cost < -1`:     true,
+		`true`:                         true,
+		`false or (cost < 1 and true)`: true,
+	}
+	for src, want := range cases {
+		e, err := policy.Compile(src, testVars)
+		require.NoError(t, err, src)
+		got, err := e.Eval(vars)
+		require.NoError(t, err, src)
+		assert.Equal(t, want, got, src)
+		assert.Equal(t, src, e.String())
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`cost <`,
+		`cost = 1`,
+		`"acme" in consumer.groups`,
+		`("a" in tool.tags`,
+		`["a", 1]`,
+		`consumer.id matches consumer.id`,
+		`"unterminated`,
+		`cost <= 1 cost`,
+		`cost # 1`,
+		`and`,
+		`consumer.id == "\q"`,
+		`cost < 1.2.3`,
+		`(cost <) or true`,
+		`"a" in ["a" "b"]`,
+	} {
+		_, err := policy.Compile(src, testVars)
+		assert.ErrorIs(t, err, policy.ErrSyntax, src)
+	}
+}
+
+func TestEvalTypeErrors(t *testing.T) {
+	vars := map[string]any{"consumer.id": "did:claw:agent:alice", "consumer.orgs": []string{}, "cost": 1.0}
+	for _, src := range []string{
+		`cost`,
+		`consumer.id < 1`,
+		`cost in consumer.orgs`,
+		`not cost`,
+		`cost matches "1*"`,
+		`"a" in tool.tags`,
+		`true < false`,
+		`(cost < "a") == true`,
+		`true == (cost < "a")`,
+		`(cost < "a") matches "x"`,
+		`not (cost < "a")`,
+	} {
+		e, err := policy.Compile(src, testVars)
+		require.NoError(t, err, src)
+		_, err = e.Eval(vars)
+		assert.ErrorIs(t, err, policy.ErrType, src)
+	}
+
+	// Evaluation stops at the first operand that decides the result.
+	e, err := policy.Compile(`cost > 5 and consumer.id < 1`, testVars)
+	require.NoError(t, err)
+	ok, err := e.Eval(vars)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCompilePrefixVars(t *testing.T) {
+	vars := []string{"output."}
+	e, err := policy.Compile(`output.status == "ok" and output.items.count > 2`, vars)
+	require.NoError(t, err)
+	ok, err := e.Eval(map[string]any{"output.status": "ok", "output.items.count": 3.0})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, src := range []string{`output`, `output. == 1`, `outputs.status == "ok"`, `input.x == 1`} {
+		_, err := policy.Compile(src, vars)
+		assert.ErrorIs(t, err, policy.ErrSyntax, src)
+	}
+}
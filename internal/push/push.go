@@ -0,0 +1,193 @@
+// Package push dispatches invocations to providers that cannot accept
+// inbound connections. Such providers long-poll the registry for jobs and
+// post each result back; the invocation waits for it as it would for a
+// call to any other endpoint.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownJob is returned for a result to a job that does not exist,
+	// was not claimed by the provider, or was given up on.
+	ErrUnknownJob = errors.New("unknown push job")
+	// ErrOffline is returned when a job is sent to a provider that has not
+	// polled recently, so the invocation fails fast instead of timing out.
+	ErrOffline = errors.New("push provider is not polling")
+)
+
+// OfflineAfter is how long after its last poll a provider is considered
+// offline.
+const OfflineAfter = 2 * time.Minute
+
+// Job is an invocation waiting for a push provider.
+type Job struct {
+	ID       string          `json:"id"`
+	ToolID   string          `json:"tool_id"`
+	Channel  string          `json:"channel"`
+	Input    json.RawMessage `json:"input"`
+	Deadline time.Time       `json:"deadline,omitempty"`
+}
+
+type result struct {
+	out []byte
+	err error
+}
+
+type pending struct {
+	job      *Job
+	provider string
+	claimed  bool
+	done     chan result
+}
+
+// Broker queues jobs per provider until the provider polls for them, and
+// hands results back to the waiting invocations. It is in-memory: a
+// provider must poll the replica its invocations are routed to.
+type Broker struct {
+	mu     sync.Mutex
+	queues map[string][]*pending
+	jobs   map[string]*pending
+	seen   map[string]time.Time
+	wake   map[string]chan struct{}
+}
+
+// NewBroker returns an empty broker.
+func NewBroker() *Broker {
+	return &Broker{
+		queues: map[string][]*pending{},
+		jobs:   map[string]*pending{},
+		seen:   map[string]time.Time{},
+		wake:   map[string]chan struct{}{},
+	}
+}
+
+// Call queues job for provider and waits for its result until ctx is done.
+func (b *Broker) Call(ctx context.Context, provider string, job *Job) ([]byte, error) {
+	if job.ID == "" {
+		job.ID = "job_" + uuid.NewString()
+	}
+	if d, ok := ctx.Deadline(); ok {
+		job.Deadline = d.UTC()
+	}
+	p := &pending{job: job, provider: provider, done: make(chan result, 1)}
+
+	b.mu.Lock()
+	if seen, ok := b.seen[provider]; !ok || time.Since(seen) > OfflineAfter {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrOffline, provider)
+	}
+	b.queues[provider] = append(b.queues[provider], p)
+	b.jobs[job.ID] = p
+	b.notify(provider)
+	b.mu.Unlock()
+
+	select {
+	case res := <-p.done:
+		return res.out, res.err
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.drop(p)
+		b.mu.Unlock()
+		// The result may have raced the deadline.
+		select {
+		case res := <-p.done:
+			return res.out, res.err
+		default:
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Poll claims up to limit jobs queued for provider, on channel or on any
+// channel when it is empty, waiting until one is queued or ctx is done.
+// It returns no jobs, and no error, when ctx ends first.
+func (b *Broker) Poll(ctx context.Context, provider, channel string, limit int) []*Job {
+	for {
+		b.mu.Lock()
+		b.seen[provider] = time.Now()
+		var jobs []*Job
+		queue := b.queues[provider][:0]
+		for _, p := range b.queues[provider] {
+			if len(jobs) < limit && (channel == "" || p.job.Channel == channel) {
+				p.claimed = true
+				jobs = append(jobs, p.job)
+				continue
+			}
+			queue = append(queue, p)
+		}
+		b.queues[provider] = queue
+		wake := b.wakeup(provider)
+		b.mu.Unlock()
+		if len(jobs) > 0 {
+			return jobs
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.seen[provider] = time.Now()
+			b.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// Complete delivers the result of a job claimed by provider: its output,
+// or the failure it reported when errMsg is set.
+func (b *Broker) Complete(provider, id string, out []byte, errMsg string) error {
+	b.mu.Lock()
+	p, ok := b.jobs[id]
+	if !ok || p.provider != provider || !p.claimed {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownJob, id)
+	}
+	delete(b.jobs, id)
+	b.mu.Unlock()
+
+	if errMsg != "" {
+		p.done <- result{err: fmt.Errorf("push provider: %s", errMsg)}
+	} else {
+		p.done <- result{out: out}
+	}
+	return nil
+}
+
+// drop forgets a job that is no longer waited for. b.mu must be held.
+func (b *Broker) drop(p *pending) {
+	delete(b.jobs, p.job.ID)
+	queue := b.queues[p.provider]
+	for i, q := range queue {
+		if q == p {
+			b.queues[p.provider] = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+}
+
+// wakeup returns a channel closed when a job is next queued for provider.
+// b.mu must be held.
+func (b *Broker) wakeup(provider string) chan struct{} {
+	ch, ok := b.wake[provider]
+	if !ok {
+		ch = make(chan struct{})
+		b.wake[provider] = ch
+	}
+	return ch
+}
+
+// notify wakes the polls of provider. b.mu must be held.
+func (b *Broker) notify(provider string) {
+	if ch, ok := b.wake[provider]; ok {
+		close(ch)
+		delete(b.wake, provider)
+	}
+}
@@ -0,0 +1,112 @@
+package push_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/push"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// online makes provider known to b with a poll that returns at once.
+func online(b *push.Broker, provider string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Poll(ctx, provider, "", 1)
+}
+
+func TestBroker_RoundTrip(t *testing.T) {
+	b := push.NewBroker()
+	online(b, "p")
+
+	type reply struct {
+		out []byte
+		err error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		out, err := b.Call(ctx, "p", &push.Job{ToolID: "t", Channel: "gpu", Input: []byte(`{"n":1}`)})
+		done <- reply{out, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	jobs := b.Poll(ctx, "p", "gpu", 10)
+	require.Len(t, jobs, 1)
+	job := jobs[0]
+	assert.Equal(t, "t", job.ToolID)
+	assert.JSONEq(t, `{"n":1}`, string(job.Input))
+	assert.False(t, job.Deadline.IsZero())
+
+	assert.ErrorIs(t, b.Complete("other", job.ID, []byte(`{}`), ""), push.ErrUnknownJob, "only the claimant may answer")
+	require.NoError(t, b.Complete("p", job.ID, []byte(`{"n":2}`), ""))
+	res := <-done
+	require.NoError(t, res.err)
+	assert.JSONEq(t, `{"n":2}`, string(res.out))
+	assert.ErrorIs(t, b.Complete("p", job.ID, []byte(`{}`), ""), push.ErrUnknownJob, "answered once")
+}
+
+func TestBroker_ProviderError(t *testing.T) {
+	b := push.NewBroker()
+	online(b, "p")
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, job := range b.Poll(ctx, "p", "", 1) {
+			_ = b.Complete("p", job.ID, nil, "out of memory")
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := b.Call(ctx, "p", &push.Job{ToolID: "t"})
+	assert.ErrorContains(t, err, "out of memory")
+}
+
+func TestBroker_Offline(t *testing.T) {
+	b := push.NewBroker()
+	_, err := b.Call(context.Background(), "p", &push.Job{ToolID: "t"})
+	assert.ErrorIs(t, err, push.ErrOffline)
+}
+
+func TestBroker_Timeout(t *testing.T) {
+	b := push.NewBroker()
+	online(b, "p")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := b.Call(ctx, "p", &push.Job{ID: "job_1", ToolID: "t", Channel: "a"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The job was withdrawn: neither handed out nor answerable.
+	pollCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Empty(t, b.Poll(pollCtx, "p", "", 10))
+	assert.ErrorIs(t, b.Complete("p", "job_1", []byte(`{}`), ""), push.ErrUnknownJob)
+}
+
+func TestBroker_Channels(t *testing.T) {
+	b := push.NewBroker()
+	online(b, "p")
+	for _, ch := range []string{"a", "b"} {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, _ = b.Call(ctx, "p", &push.Job{ToolID: "t", Channel: ch})
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	jobs := b.Poll(ctx, "p", "b", 10)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "b", jobs[0].Channel, "the poll only takes its channel")
+	jobs = append(jobs, b.Poll(ctx, "p", "", 10)...)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "a", jobs[1].Channel)
+	for _, job := range jobs {
+		require.NoError(t, b.Complete("p", job.ID, []byte(`{}`), ""))
+	}
+}
@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Kinds of consumer anomaly.
+const (
+	FlagSpendSpike    = "spend_spike"
+	FlagCatalogScrape = "catalog_scrape"
+	FlagBadRequests   = "bad_requests"
+)
+
+// Review states of a ConsumerFlag.
+const (
+	FlagStatusOpen      = "open"      // awaiting review; the consumer is throttled
+	FlagStatusCleared   = "cleared"   // reviewed as a false positive
+	FlagStatusConfirmed = "confirmed" // reviewed as abuse; the consumer is blocked
+)
+
+// ConsumerFlag is an anomaly detected for a consumer, queued for admin
+// review. At most one flag of each kind is open per consumer.
+type ConsumerFlag struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	ID         string     `json:"id"`
+	ConsumerID string     `json:"consumer_id"`
+	Kind       string     `json:"kind"`
+	Detail     string     `json:"detail,omitempty"`
+	Status     string     `json:"status"`
+}
+
+// FlagConsumer opens a flag for consumer. It returns nil without error when
+// a flag of the same kind is already open.
+func (r *Registry) FlagConsumer(ctx context.Context, consumer, kind, detail string) (*ConsumerFlag, error) {
+	f := &ConsumerFlag{
+		ID:         "flag_" + uuid.NewString(),
+		ConsumerID: consumer,
+		Kind:       kind,
+		Detail:     detail,
+		Status:     FlagStatusOpen,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO consumer_flags (id, consumer_id, kind, detail, status, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, f.ID, f.ConsumerID, f.Kind, f.Detail, f.Status, f.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert consumer flag: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	r.logger(ctx).Warn("consumer flagged", zap.String("consumer_id", consumer),
+		zap.String("kind", kind), zap.String("detail", detail))
+	return f, nil
+}
+
+// ListConsumerFlags returns flags with the given status (all when empty),
+// newest first.
+func (r *Registry) ListConsumerFlags(ctx context.Context, status string) ([]*ConsumerFlag, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, consumer_id, kind, detail, status, created_at, reviewed_at
+		FROM consumer_flags
+		WHERE ? = '' OR status = ?
+		ORDER BY created_at DESC, id
+	`, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("list consumer flags: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	flags := []*ConsumerFlag{}
+	for rows.Next() {
+		var (
+			f        ConsumerFlag
+			created  int64
+			reviewed sql.NullInt64
+		)
+		if err := rows.Scan(&f.ID, &f.ConsumerID, &f.Kind, &f.Detail, &f.Status, &created, &reviewed); err != nil {
+			return nil, err
+		}
+		f.CreatedAt = time.Unix(created, 0).UTC()
+		if reviewed.Valid {
+			t := time.Unix(reviewed.Int64, 0).UTC()
+			f.ReviewedAt = &t
+		}
+		flags = append(flags, &f)
+	}
+	return flags, rows.Err()
+}
+
+// ReviewConsumerFlag records an admin decision on a flag: cleared lifts the
+// throttle, confirmed blocks the consumer until the flag is cleared.
+func (r *Registry) ReviewConsumerFlag(ctx context.Context, id, status string) (*ConsumerFlag, error) {
+	if status != FlagStatusCleared && status != FlagStatusConfirmed {
+		return nil, fmt.Errorf("status must be %q or %q", FlagStatusCleared, FlagStatusConfirmed)
+	}
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE consumer_flags SET status = ?, reviewed_at = ? WHERE id = ?",
+		status, time.Now().Unix(), id)
+	if err != nil {
+		return nil, fmt.Errorf("review consumer flag: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+
+	var (
+		f        ConsumerFlag
+		created  int64
+		reviewed int64
+	)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT id, consumer_id, kind, detail, status, created_at, reviewed_at FROM consumer_flags WHERE id = ?
+	`, id).Scan(&f.ID, &f.ConsumerID, &f.Kind, &f.Detail, &f.Status, &created, &reviewed)
+	if err != nil {
+		return nil, fmt.Errorf("get consumer flag: %w", err)
+	}
+	f.CreatedAt = time.Unix(created, 0).UTC()
+	t := time.Unix(reviewed, 0).UTC()
+	f.ReviewedAt = &t
+	r.logger(ctx).Info("consumer flag reviewed", zap.String("id", id),
+		zap.String("consumer_id", f.ConsumerID), zap.String("status", status))
+	return &f, nil
+}
+
+// ConsumerStanding returns FlagStatusConfirmed when the consumer is blocked,
+// FlagStatusOpen when it has a flag awaiting review, and "" otherwise.
+func (r *Registry) ConsumerStanding(ctx context.Context, consumer string) (string, error) {
+	var status string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT status FROM consumer_flags
+		WHERE consumer_id = ? AND status IN ('open', 'confirmed')
+		ORDER BY status = 'confirmed' DESC
+		LIMIT 1
+	`, consumer).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("consumer standing: %w", err)
+	}
+	return status, nil
+}
+
+// SpendSpike is a consumer whose spend on one day is far above its recent
+// daily average.
+type SpendSpike struct {
+	ConsumerID string  `json:"consumer_id"`
+	Spend      float64 `json:"spend_claw"`
+	Baseline   float64 `json:"baseline_claw"`
+}
+
+// spendBaselineDays is how many preceding days form a consumer's baseline.
+const spendBaselineDays = 7
+
+// SpendSpikes returns consumers whose rolled-up spend on the UTC day
+// containing t is at least minSpend and more than factor times their average
+// daily spend over the preceding week. Consumers with no history count as
+// spiking once they pass minSpend.
+func (r *Registry) SpendSpikes(ctx context.Context, t time.Time, factor, minSpend float64) ([]SpendSpike, error) {
+	u := t.UTC()
+	day := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := r.db.QueryContext(ctx, `
+		WITH today AS (
+			SELECT consumer_id, SUM(spend_claw) AS spend FROM usage_daily WHERE day = ? GROUP BY consumer_id
+		), baseline AS (
+			SELECT consumer_id, SUM(spend_claw) / ? AS spend FROM usage_daily
+			WHERE day >= ? AND day < ? GROUP BY consumer_id
+		)
+		SELECT t.consumer_id, t.spend, COALESCE(b.spend, 0)
+		FROM today t LEFT JOIN baseline b ON b.consumer_id = t.consumer_id
+		WHERE t.spend >= ? AND t.spend > ? * COALESCE(b.spend, 0)
+		ORDER BY t.consumer_id
+	`, day.Format(dayLayout), float64(spendBaselineDays),
+		day.AddDate(0, 0, -spendBaselineDays).Format(dayLayout), day.Format(dayLayout),
+		minSpend, factor)
+	if err != nil {
+		return nil, fmt.Errorf("spend spikes: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var spikes []SpendSpike
+	for rows.Next() {
+		var s SpendSpike
+		if err := rows.Scan(&s.ConsumerID, &s.Spend, &s.Baseline); err != nil {
+			return nil, err
+		}
+		spikes = append(spikes, s)
+	}
+	return spikes, rows.Err()
+}
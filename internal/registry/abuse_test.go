@@ -0,0 +1,110 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumerFlags(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	standing, err := r.ConsumerStanding(ctx, "consumer-a")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+
+	f, err := r.FlagConsumer(ctx, "consumer-a", registry.FlagCatalogScrape, "too many reads")
+	require.NoError(t, err)
+	require.NotNil(t, f)
+	assert.Equal(t, registry.FlagStatusOpen, f.Status)
+
+	// A second flag of the same kind is folded into the open one.
+	dup, err := r.FlagConsumer(ctx, "consumer-a", registry.FlagCatalogScrape, "again")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+
+	standing, err = r.ConsumerStanding(ctx, "consumer-a")
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusOpen, standing)
+
+	reviewed, err := r.ReviewConsumerFlag(ctx, f.ID, registry.FlagStatusConfirmed)
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusConfirmed, reviewed.Status)
+	require.NotNil(t, reviewed.ReviewedAt)
+
+	standing, err = r.ConsumerStanding(ctx, "consumer-a")
+	require.NoError(t, err)
+	assert.Equal(t, registry.FlagStatusConfirmed, standing)
+
+	_, err = r.ReviewConsumerFlag(ctx, f.ID, registry.FlagStatusCleared)
+	require.NoError(t, err)
+	standing, err = r.ConsumerStanding(ctx, "consumer-a")
+	require.NoError(t, err)
+	assert.Empty(t, standing)
+
+	// Once reviewed, the same anomaly can be flagged again.
+	f2, err := r.FlagConsumer(ctx, "consumer-a", registry.FlagCatalogScrape, "again")
+	require.NoError(t, err)
+	require.NotNil(t, f2)
+
+	open, err := r.ListConsumerFlags(ctx, registry.FlagStatusOpen)
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	assert.Equal(t, f2.ID, open[0].ID)
+	all, err := r.ListConsumerFlags(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestReviewConsumerFlag_Errors(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	_, err := r.ReviewConsumerFlag(ctx, "flag_missing", registry.FlagStatusCleared)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	_, err = r.ReviewConsumerFlag(ctx, "flag_missing", registry.FlagStatusOpen)
+	assert.Error(t, err)
+}
+
+func TestSpendSpikes(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	for consumer, cost := range map[string]string{"big-spender": "150", "small-spender": "5"} {
+		inv, err := r.RecordInvocation(ctx, tool.ID, consumer, map[string]any{})
+		require.NoError(t, err)
+		require.NoError(t, r.CompleteInvocation(ctx, inv, "sha256:out", "sig", cost))
+	}
+	require.NoError(t, r.RollupRecentUsage(ctx))
+
+	spikes, err := r.SpendSpikes(ctx, time.Now(), 10, 100)
+	require.NoError(t, err)
+	require.Len(t, spikes, 1)
+	assert.Equal(t, "big-spender", spikes[0].ConsumerID)
+	assert.InDelta(t, 150, spikes[0].Spend, 0.001)
+	assert.Zero(t, spikes[0].Baseline)
+
+	spikes, err = r.SpendSpikes(ctx, time.Now(), 10, 1000)
+	require.NoError(t, err)
+	assert.Empty(t, spikes)
+}
+
+func TestConsumerFlags_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	ctx := context.Background()
+	_, err := r.FlagConsumer(ctx, "c", registry.FlagBadRequests, "")
+	assert.Error(t, err)
+	_, err = r.ConsumerStanding(ctx, "c")
+	assert.Error(t, err)
+	_, err = r.ListConsumerFlags(ctx, "")
+	assert.Error(t, err)
+	_, err = r.SpendSpikes(ctx, time.Now(), 10, 100)
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrToolRevoked is returned when invoking a tool version that has been
+// revoked.
+var ErrToolRevoked = errors.New("tool revoked")
+
+// ErrInvalidAdvisory is returned for an advisory with an unknown status or
+// no note.
+var ErrInvalidAdvisory = errors.New("invalid advisory")
+
+// Advisory states of a tool version.
+const (
+	AdvisoryVulnerable = "vulnerable" // still listed and invocable, with a warning
+	AdvisoryRevoked    = "revoked"    // hidden from search and refused by the router
+)
+
+// Advisory is a security notice on a tool version.
+type Advisory struct {
+	At     time.Time `json:"at"`
+	Status string    `json:"status"`
+	Note   string    `json:"note"`
+	// By is "admin" or "provider". A provider cannot change an advisory set
+	// by an admin.
+	By string `json:"by"`
+}
+
+// Who may set advisories.
+const (
+	AdvisoryByAdmin    = "admin"
+	AdvisoryByProvider = "provider"
+)
+
+// Invocable returns an ErrToolRevoked error carrying the advisory note when
+// the tool has been revoked.
+func (t *Tool) Invocable() error {
+	if t.Advisory != nil && t.Advisory.Status == AdvisoryRevoked {
+		return fmt.Errorf("%w: %s", ErrToolRevoked, t.Advisory.Note)
+	}
+	return nil
+}
+
+// SetAdvisory marks a tool version as vulnerable or revoked, or clears its
+// advisory when a is nil. An admin (providerID "") may act on any tool in
+// any namespace; a provider only on its own tools in the context namespace,
+// and not on a tool whose advisory an admin set.
+func (r *Registry) SetAdvisory(ctx context.Context, id, providerID string, a *Advisory) error {
+	by := AdvisoryByAdmin
+	if providerID != "" {
+		by = AdvisoryByProvider
+	}
+	var (
+		status, note, setBy string
+		at                  sql.NullInt64
+	)
+	if a != nil {
+		if a.Status != AdvisoryVulnerable && a.Status != AdvisoryRevoked {
+			return fmt.Errorf("%w: status must be %q or %q", ErrInvalidAdvisory, AdvisoryVulnerable, AdvisoryRevoked)
+		}
+		if a.Note == "" {
+			return fmt.Errorf("%w: note is required", ErrInvalidAdvisory)
+		}
+		status, note, setBy = a.Status, a.Note, by
+		at = sql.NullInt64{Int64: time.Now().Unix(), Valid: true}
+	}
+
+	query := "UPDATE tools SET advisory_status = ?, advisory_note = ?, advisory_by = ?, advisory_at = ? WHERE id = ?"
+	args := []any{status, note, setBy, at, id}
+	if providerID != "" {
+		var current string
+		err := r.db.QueryRowContext(ctx,
+			"SELECT advisory_by FROM tools WHERE id = ? AND provider_id = ? AND namespace = ?",
+			id, providerID, NamespaceFrom(ctx)).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w or not authorized", ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("get advisory: %w", err)
+		}
+		if current == AdvisoryByAdmin {
+			return fmt.Errorf("%w: the advisory on %s was set by an admin", ErrForbidden, id)
+		}
+		query += " AND provider_id = ? AND namespace = ?"
+		args = append(args, providerID, NamespaceFrom(ctx))
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("set advisory: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	r.logger(ctx).Warn("tool advisory updated", zap.String("id", id), zap.String("status", status), zap.String("by", by))
+	return nil
+}
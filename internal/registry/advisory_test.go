@@ -0,0 +1,82 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAdvisory_RevokedHiddenFromSearch(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	owner := validRegisterReq().ProviderID
+
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, owner,
+		&registry.Advisory{Status: registry.AdvisoryVulnerable, Note: "CVE-2026-0001: upgrade to 1.0.1"}))
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Advisory)
+	assert.Equal(t, registry.AdvisoryByProvider, got.Advisory.By)
+	assert.NoError(t, got.Invocable(), "vulnerable tools stay invocable")
+	list, err := r.ListTools(ctx, 1, 20)
+	require.NoError(t, err)
+	assert.Len(t, list.Tools, 1)
+
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, owner,
+		&registry.Advisory{Status: registry.AdvisoryRevoked, Note: "key compromised"}))
+	got, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	err = got.Invocable()
+	assert.ErrorIs(t, err, registry.ErrToolRevoked)
+	assert.ErrorContains(t, err, "key compromised")
+
+	list, err = r.ListTools(ctx, 1, 20)
+	require.NoError(t, err)
+	assert.Empty(t, list.Tools)
+	assert.Zero(t, list.Total)
+	found, err := r.SearchTools(ctx, &registry.SearchQuery{Query: "test"})
+	require.NoError(t, err)
+	assert.Empty(t, found.Tools)
+
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, owner, nil))
+	got, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Advisory)
+}
+
+func TestSetAdvisory_AdminOverridesProvider(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	owner := validRegisterReq().ProviderID
+
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, "", &registry.Advisory{Status: registry.AdvisoryRevoked, Note: "malware"}))
+	err = r.SetAdvisory(ctx, tool.ID, owner, nil)
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	// Once an admin clears it, the provider may publish its own advisory.
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, "", nil))
+	require.NoError(t, r.SetAdvisory(ctx, tool.ID, owner, &registry.Advisory{Status: registry.AdvisoryVulnerable, Note: "n"}))
+}
+
+func TestSetAdvisory_Errors(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	err = r.SetAdvisory(ctx, tool.ID, "", &registry.Advisory{Status: "bad", Note: "n"})
+	assert.ErrorIs(t, err, registry.ErrInvalidAdvisory)
+	err = r.SetAdvisory(ctx, tool.ID, "", &registry.Advisory{Status: registry.AdvisoryRevoked})
+	assert.ErrorIs(t, err, registry.ErrInvalidAdvisory)
+	err = r.SetAdvisory(ctx, tool.ID, "did:claw:agent:stranger", nil)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	err = r.SetAdvisory(ctx, "did:claw:tool:missing", "", nil)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
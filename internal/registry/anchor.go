@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrNotAnchored is returned for the proof of a receipt whose batch has not
+// been anchored yet.
+var ErrNotAnchored = errors.New("receipt not anchored yet")
+
+// maxAnchorBatch bounds how many receipts one anchored root covers; more
+// wait for the next run.
+const maxAnchorBatch = 10_000
+
+// Anchorer records Merkle roots on a chain.
+type Anchorer interface {
+	// Anchor submits root, "sha256:<hex>", and returns the hash of the
+	// transaction recording it.
+	Anchor(ctx context.Context, root string) (string, error)
+}
+
+// WithAnchorer anchors receipts with a: AnchorReceipts submits the Merkle
+// root of the receipts written since its last run.
+func WithAnchorer(a Anchorer) Option {
+	return func(r *Registry) { r.anchorer = a }
+}
+
+// AnchorReceipts anchors the Merkle root of the receipts of every namespace
+// not anchored yet, oldest first and up to maxAnchorBatch of them, and
+// stores the inclusion proof of each. It does nothing without an Anchorer
+// or receipts to anchor. A root that is anchored but not stored, because
+// the registry stopped in between, is anchored again with the same
+// receipts by the next run.
+func (r *Registry) AnchorReceipts(ctx context.Context) error {
+	if r.anchorer == nil {
+		return nil
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+receiptColumns+
+		" FROM receipts WHERE anchor_id = '' ORDER BY executed_at, rowid LIMIT ?", maxAnchorBatch)
+	if err != nil {
+		return fmt.Errorf("unanchored receipts: %w", err)
+	}
+	var (
+		ids    []string
+		leaves []string
+	)
+	for rows.Next() {
+		rcpt, err := scanReceipt(rows)
+		if err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt: %w", err)
+		}
+		ids = append(ids, rcpt.ID)
+		leaves = append(leaves, receipts.LeafHash(rcpt))
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unanchored receipts: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	root, paths, err := receipts.MerkleTree(leaves)
+	if err != nil {
+		return err
+	}
+	tx, err := r.anchorer.Anchor(ctx, root)
+	if err != nil {
+		return fmt.Errorf("anchor %d receipts: %w", len(ids), err)
+	}
+	id := "anchor_" + uuid.NewString()
+	err = r.db.WriteTx(ctx, func(sqlTx *sql.Tx) error {
+		if _, err := sqlTx.ExecContext(ctx,
+			"INSERT INTO receipt_anchors (id, root, size, tx_hash, anchored_at) VALUES (?, ?, ?, ?, ?)",
+			id, root, len(ids), tx, time.Now().Unix()); err != nil {
+			return err
+		}
+		for i, rid := range ids {
+			path, err := json.Marshal(paths[i])
+			if err != nil {
+				return err
+			}
+			if _, err := sqlTx.ExecContext(ctx,
+				"UPDATE receipts SET anchor_id = ?, anchor_index = ?, anchor_path = ? WHERE id = ? AND anchor_id = ''",
+				id, i, string(path), rid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store anchor %s: %w", tx, err)
+	}
+	r.logger(ctx).Info("receipts anchored", zap.String("batch", id), zap.String("root", root),
+		zap.String("tx", tx), zap.Int("receipts", len(ids)))
+	return nil
+}
+
+// ReceiptProof returns the inclusion proof of receipt id of the context
+// namespace in its anchored batch, or ErrNotAnchored while it has none.
+func (r *Registry) ReceiptProof(ctx context.Context, id string) (*receipts.Proof, error) {
+	rcpt, err := r.GetReceipt(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		p          = receipts.Proof{ReceiptID: rcpt.ID, Leaf: receipts.LeafHash(rcpt)}
+		path       string
+		anchoredAt int64
+	)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT r.anchor_index, r.anchor_path, a.id, a.root, a.tx_hash, a.anchored_at
+		FROM receipts r JOIN receipt_anchors a ON a.id = r.anchor_id
+		WHERE r.id = ?
+	`, rcpt.ID).Scan(&p.Index, &path, &p.BatchID, &p.Root, &p.TxHash, &anchoredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotAnchored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("receipt proof: %w", err)
+	}
+	if err := json.Unmarshal([]byte(path), &p.Path); err != nil {
+		return nil, fmt.Errorf("unmarshal proof path: %w", err)
+	}
+	p.AnchoredAt = time.Unix(anchoredAt, 0).UTC()
+	return &p, nil
+}
@@ -0,0 +1,83 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeChain records the roots anchored on it, failing while err is set.
+type fakeChain struct {
+	roots []string
+	err   error
+}
+
+func (c *fakeChain) Anchor(_ context.Context, root string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	c.roots = append(c.roots, root)
+	return fmt.Sprintf("0xtx%d", len(c.roots)), nil
+}
+
+func TestAnchorReceipts(t *testing.T) {
+	ctx := context.Background()
+	chain := &fakeChain{}
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithAnchorer(chain))
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	complete := func(n int) []string {
+		var ids []string
+		for i := 0; i < n; i++ {
+			id, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"i": i})
+			require.NoError(t, err)
+			require.NoError(t, r.CompleteInvocation(ctx, id, "h", "", "1"))
+			rcpt, err := r.InvocationReceipt(ctx, id)
+			require.NoError(t, err)
+			ids = append(ids, rcpt.ID)
+		}
+		return ids
+	}
+	first := complete(3)
+	_, err = r.ReceiptProof(ctx, first[0])
+	assert.ErrorIs(t, err, registry.ErrNotAnchored)
+
+	chain.err = errors.New("node down")
+	assert.ErrorContains(t, r.AnchorReceipts(ctx), "node down")
+	_, err = r.ReceiptProof(ctx, first[0])
+	assert.ErrorIs(t, err, registry.ErrNotAnchored, "nothing is stored for a failed anchor")
+
+	chain.err = nil
+	require.NoError(t, r.AnchorReceipts(ctx))
+	require.Len(t, chain.roots, 1)
+	for i, id := range first {
+		p, err := r.ReceiptProof(ctx, id)
+		require.NoError(t, err)
+		rcpt, err := r.GetReceipt(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, i, p.Index)
+		assert.Equal(t, chain.roots[0], p.Root)
+		assert.Equal(t, "0xtx1", p.TxHash)
+		assert.NoError(t, receipts.VerifyProof(p, rcpt), "the receipt as served proves its inclusion")
+	}
+
+	require.NoError(t, r.AnchorReceipts(ctx))
+	assert.Len(t, chain.roots, 1, "nothing new to anchor")
+	second := complete(2)
+	require.NoError(t, r.AnchorReceipts(ctx))
+	require.Len(t, chain.roots, 2)
+	p, err := r.ReceiptProof(ctx, second[1])
+	require.NoError(t, err)
+	assert.Equal(t, chain.roots[1], p.Root)
+	assert.Equal(t, 1, p.Index)
+
+	_, err = r.ReceiptProof(ctx, "rcpt_missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
@@ -0,0 +1,224 @@
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// API key scopes. A key may only be used on the routes its scopes allow;
+// ScopeAdmin allows every route, the /admin API included.
+const (
+	ScopeToolsRead  = "tools:read"
+	ScopeToolsWrite = "tools:write"
+	ScopeInvoke     = "invoke"
+	ScopeAdmin      = "admin"
+)
+
+// Scopes are the known API key scopes.
+var Scopes = []string{ScopeToolsRead, ScopeToolsWrite, ScopeInvoke, ScopeAdmin}
+
+// APIKeyPrefix starts every API key secret, so they are told apart from
+// DIDs and found by secret scanners.
+const APIKeyPrefix = "atk_"
+
+var (
+	// ErrInvalidScope is returned for an API key without scopes or with an
+	// unknown one.
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidAPIKey is returned for an API key secret that is unknown or
+	// revoked.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// APIKey is a secret a DID hands to consumers that do not sign requests:
+// requests sent with it act as its owner, limited to its scopes. The
+// secret itself is only returned when the key is created.
+type APIKey struct {
+	ID      string   `json:"id"`
+	OwnerID string   `json:"owner_id"`
+	Name    string   `json:"name,omitempty"`
+	Prefix  string   `json:"prefix"` // the start of the secret, to recognize it by
+	Scopes  []string `json:"scopes"`
+	// Proven is set on keys created with CreateProviderAPIKey, whose owner
+	// proved it holds the key of the provider it acts as.
+	Proven bool `json:"proven,omitempty"`
+	// Secret is set when the key is created only.
+	Secret     string     `json:"secret,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether k allows scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+const apiKeyColumns = "id, owner_id, name, prefix, scopes, proven, created_at, last_used_at, revoked_at"
+
+// CreateAPIKey creates a key acting as ownerID with scopes. The returned
+// key carries its secret, which is not stored and cannot be read again.
+func (r *Registry) CreateAPIKey(ctx context.Context, ownerID, name string, scopes []string) (*APIKey, error) {
+	return r.createAPIKey(ctx, ownerID, name, scopes, false)
+}
+
+// CreateProviderAPIKey creates a proven key acting as provider providerID
+// of the context namespace, once signature proves, as ProveKey does, that
+// the caller holds the provider's registered key.
+func (r *Registry) CreateProviderAPIKey(ctx context.Context, providerID, name string, scopes []string, challenge, signature string) (
+	*APIKey, error,
+) {
+	p, err := r.GetProvider(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ProveKey(ctx, p, challenge, signature); err != nil {
+		return nil, err
+	}
+	return r.createAPIKey(ctx, providerID, name, scopes, true)
+}
+
+func (r *Registry) createAPIKey(ctx context.Context, ownerID, name string, scopes []string, proven bool) (*APIKey, error) {
+	if ownerID == "" {
+		return nil, fmt.Errorf("owner is required")
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one of %s is required", ErrInvalidScope, strings.Join(Scopes, ", "))
+	}
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return nil, fmt.Errorf("%w %q: want %s", ErrInvalidScope, s, strings.Join(Scopes, ", "))
+		}
+	}
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate api key: %w", err)
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	k := &APIKey{
+		ID:        "key_" + uuid.NewString(),
+		OwnerID:   ownerID,
+		Name:      name,
+		Prefix:    secret[:len(APIKeyPrefix)+6],
+		Scopes:    slices.Compact(scopes),
+		Proven:    proven,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, owner_id, name, prefix, secret_hash, scopes, proven, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, k.ID, ownerID, name, k.Prefix, hashAPIKey(secret), strings.Join(k.Scopes, " "), proven, k.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert api key: %w", err)
+	}
+	return k, nil
+}
+
+// ListAPIKeys returns the keys of ownerID, revoked ones included, newest
+// first.
+func (r *Registry) ListAPIKeys(ctx context.Context, ownerID string) ([]*APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+apiKeyColumns+
+		" FROM api_keys WHERE owner_id = ? ORDER BY created_at DESC, id DESC", ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	keys := []*APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKey returns key id of ownerID, or ErrNotFound.
+func (r *Registry) GetAPIKey(ctx context.Context, ownerID, id string) (*APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+
+		" FROM api_keys WHERE id = ? AND owner_id = ?", id, ownerID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return k, nil
+}
+
+// RevokeAPIKey revokes key id of ownerID, which stops working at once.
+// Revoking a revoked key does nothing.
+func (r *Registry) RevokeAPIKey(ctx context.Context, ownerID, id string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? AND owner_id = ?
+	`, time.Now().Unix(), id, ownerID)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the key of secret, or ErrInvalidAPIKey when
+// it is unknown or revoked, and records its use.
+func (r *Registry) AuthenticateAPIKey(ctx context.Context, secret string) (*APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+
+		" FROM api_keys WHERE secret_hash = ? AND revoked_at IS NULL", hashAPIKey(secret)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now().Unix(), k.ID); err != nil {
+		r.logger(ctx).Warn("record api key use", zap.String("key_id", k.ID), zap.Error(err))
+	}
+	return k, nil
+}
+
+// hashAPIKey returns the stored hash of secret. Secrets are random, so an
+// unsalted hash cannot be reversed.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanAPIKey(row scanner) (*APIKey, error) {
+	var (
+		k                  APIKey
+		scopes             string
+		createdAt          int64
+		lastUsed, revokeAt sql.NullInt64
+	)
+	if err := row.Scan(&k.ID, &k.OwnerID, &k.Name, &k.Prefix, &scopes, &k.Proven, &createdAt, &lastUsed, &revokeAt); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	k.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if lastUsed.Valid {
+		t := time.Unix(lastUsed.Int64, 0).UTC()
+		k.LastUsedAt = &t
+	}
+	if revokeAt.Valid {
+		t := time.Unix(revokeAt.Int64, 0).UTC()
+		k.RevokedAt = &t
+	}
+	return &k, nil
+}
@@ -0,0 +1,101 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	const owner = "did:claw:agent:owner"
+
+	k, err := r.CreateAPIKey(ctx, owner, "ci", []string{registry.ScopeInvoke, registry.ScopeToolsRead, registry.ScopeInvoke})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(k.Secret, registry.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(k.Secret, k.Prefix))
+	assert.Equal(t, []string{registry.ScopeInvoke, registry.ScopeToolsRead}, k.Scopes)
+	assert.True(t, k.HasScope(registry.ScopeInvoke))
+	assert.False(t, k.HasScope(registry.ScopeToolsWrite))
+
+	got, err := r.AuthenticateAPIKey(ctx, k.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, k.ID, got.ID)
+	assert.Equal(t, owner, got.OwnerID)
+	assert.Empty(t, got.Secret)
+	_, err = r.AuthenticateAPIKey(ctx, k.Secret+"x")
+	assert.ErrorIs(t, err, registry.ErrInvalidAPIKey)
+
+	keys, err := r.ListAPIKeys(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Secret, "secrets are not stored")
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.Nil(t, keys[0].RevokedAt)
+
+	assert.ErrorIs(t, r.RevokeAPIKey(ctx, "did:claw:agent:other", k.ID), registry.ErrNotFound, "only the owner revokes")
+	require.NoError(t, r.RevokeAPIKey(ctx, owner, k.ID))
+	require.NoError(t, r.RevokeAPIKey(ctx, owner, k.ID))
+	_, err = r.AuthenticateAPIKey(ctx, k.Secret)
+	assert.ErrorIs(t, err, registry.ErrInvalidAPIKey)
+	keys, err = r.ListAPIKeys(ctx, owner)
+	require.NoError(t, err)
+	assert.NotNil(t, keys[0].RevokedAt)
+
+	admin, err := r.CreateAPIKey(ctx, owner, "", []string{registry.ScopeAdmin})
+	require.NoError(t, err)
+	assert.True(t, admin.HasScope(registry.ScopeToolsWrite), "admin allows every scope")
+
+	for _, scopes := range [][]string{nil, {"tools:delete"}} {
+		_, err = r.CreateAPIKey(ctx, owner, "", scopes)
+		assert.ErrorIs(t, err, registry.ErrInvalidScope)
+	}
+}
+
+func TestCreateProviderAPIKey(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	p, err := r.RegisterProvider(ctx, &registry.Provider{
+		ID: "did:claw:agent:p", Endpoint: "grpc://localhost:50051", PubKey: "ed25519:" + hex.EncodeToString(pub),
+	})
+	require.NoError(t, err)
+	challenge := func() (string, string) {
+		c, err := r.IssueKeyChallenge(ctx, p.ID, p.PubKey)
+		require.NoError(t, err)
+		return c.Challenge, base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(c.Challenge)))
+	}
+
+	c, sig := challenge()
+	k, err := r.CreateProviderAPIKey(ctx, p.ID, "deploy", []string{registry.ScopeToolsWrite}, c, sig)
+	require.NoError(t, err)
+	assert.True(t, k.Proven)
+	got, err := r.AuthenticateAPIKey(ctx, k.Secret)
+	require.NoError(t, err)
+	assert.True(t, got.Proven)
+
+	_, err = r.CreateProviderAPIKey(ctx, p.ID, "", []string{registry.ScopeToolsWrite}, c, sig)
+	assert.ErrorIs(t, err, registry.ErrInvalidKeyProof, "challenges are single-use")
+	c, sig = challenge()
+	_, err = r.CreateProviderAPIKey(ctx, "did:claw:agent:unknown", "", []string{registry.ScopeToolsWrite}, c, sig)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	plain, err := r.CreateAPIKey(ctx, p.ID, "", []string{registry.ScopeToolsWrite})
+	require.NoError(t, err)
+	assert.False(t, plain.Proven)
+
+	got, err = r.GetAPIKey(ctx, p.ID, k.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Proven)
+	_, err = r.GetAPIKey(ctx, "did:claw:agent:other", k.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
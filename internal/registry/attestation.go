@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+// maxQuoteBytes bounds an attestation quote. SGX DCAP quotes and SEV-SNP
+// reports are a few KiB, with their certificate chains.
+const maxQuoteBytes = 64 << 10
+
+var (
+	// ErrInvalidAttestation is returned for an attestation that is
+	// malformed or that its format's verifier refuses.
+	ErrInvalidAttestation = errors.New("invalid attestation")
+
+	// ErrReceiptSealed is returned for an attestation of a receipt that
+	// already has one or was anchored, which would change what its
+	// anchored root covers.
+	ErrReceiptSealed = errors.New("receipt sealed")
+)
+
+// Attestation is the quote of a trusted execution environment attached to
+// a receipt.
+type Attestation = receipts.Attestation
+
+// attestationFormat is the form of Attestation.Format.
+var attestationFormat = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// AttestationVerifier checks the quotes of one attestation format.
+type AttestationVerifier interface {
+	// VerifyAttestation checks that quote is genuine, made by an
+	// environment the verifier trusts, and that its report data is
+	// reportData.
+	VerifyAttestation(ctx context.Context, quote, reportData []byte) error
+}
+
+// WithAttestationVerifier checks the attestations of format with v, when
+// they are attached and when their receipts are verified. Attestations of
+// formats without a verifier are accepted and left unchecked.
+func WithAttestationVerifier(format string, v AttestationVerifier) Option {
+	return func(r *Registry) {
+		if r.attesters == nil {
+			r.attesters = map[string]AttestationVerifier{}
+		}
+		r.attesters[format] = v
+	}
+}
+
+// AttachAttestation attaches a to receipt id of the context namespace, for
+// providerID, which must be the provider of its tool. A receipt takes one
+// attestation, before it is anchored.
+func (r *Registry) AttachAttestation(ctx context.Context, id, providerID string, a *Attestation) (*Receipt, error) {
+	switch {
+	case !attestationFormat.MatchString(a.Format):
+		return nil, fmt.Errorf("%w: format must be 1 to 64 lowercase letters, digits and '-'", ErrInvalidAttestation)
+	case len(a.Quote) == 0:
+		return nil, fmt.Errorf("%w: quote is required", ErrInvalidAttestation)
+	case len(a.Quote) > maxQuoteBytes:
+		return nil, fmt.Errorf("%w: quote is over %d bytes", ErrInvalidAttestation, maxQuoteBytes)
+	}
+	rcpt, err := r.GetReceipt(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rcpt.ProviderID != providerID {
+		return nil, fmt.Errorf("%w: only the provider of the tool may attest its receipts", ErrForbidden)
+	}
+	rcpt.Attestation = a
+	if _, err := r.verifyAttestation(ctx, rcpt); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("encode attestation: %w", err)
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE receipts SET attestation = ? WHERE id = ? AND namespace = ? AND attestation = '' AND anchor_id = ''
+	`, string(b), id, NamespaceFrom(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("attach attestation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: receipt %s is already attested or anchored", ErrReceiptSealed, id)
+	}
+	return rcpt, nil
+}
+
+// verifyAttestation checks the attestation of rcpt with the verifier of
+// its format and reports whether there was one.
+func (r *Registry) verifyAttestation(ctx context.Context, rcpt *Receipt) (bool, error) {
+	v, ok := r.attesters[rcpt.Attestation.Format]
+	if !ok {
+		return false, nil
+	}
+	if err := v.VerifyAttestation(ctx, rcpt.Attestation.Quote, receipts.ReportData(rcpt)); err != nil {
+		return true, fmt.Errorf("%w: %w", ErrInvalidAttestation, err)
+	}
+	return true, nil
+}
+
+// decodeAttestation reads the attestation column of a receipt, empty for
+// receipts without one.
+func decodeAttestation(s string) (*Attestation, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var a Attestation
+	if err := json.Unmarshal([]byte(s), &a); err != nil {
+		return nil, fmt.Errorf("decode attestation: %w", err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,80 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeEnclave accepts quotes that are "genuine" followed by their report
+// data.
+type fakeEnclave struct{}
+
+func (fakeEnclave) VerifyAttestation(_ context.Context, quote, reportData []byte) error {
+	if !bytes.Equal(quote, append([]byte("genuine"), reportData...)) {
+		return errors.New("quote does not verify")
+	}
+	return nil
+}
+
+func TestAttachAttestation(t *testing.T) {
+	ctx := context.Background()
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithAttestationVerifier("sgx-dcap", fakeEnclave{}))
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	receipt := func() *registry.Receipt {
+		id, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"q": 1})
+		require.NoError(t, err)
+		require.NoError(t, r.CompleteInvocation(ctx, id, "sha256:out", "", "5.0"))
+		rcpt, err := r.InvocationReceipt(ctx, id)
+		require.NoError(t, err)
+		return rcpt
+	}
+	rcpt := receipt()
+	genuine := &registry.Attestation{Format: "sgx-dcap", Quote: append([]byte("genuine"), receipts.ReportData(rcpt)...)}
+
+	_, err = r.AttachAttestation(ctx, rcpt.ID, "consumer", genuine)
+	assert.ErrorIs(t, err, registry.ErrForbidden, "only the provider attests")
+	_, err = r.AttachAttestation(ctx, "rcpt_missing", tool.ProviderID, genuine)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	for _, a := range []*registry.Attestation{
+		{Format: "SGX", Quote: []byte("q")},
+		{Format: "sgx-dcap"},
+		{Format: "sgx-dcap", Quote: []byte("forged")},
+	} {
+		_, err = r.AttachAttestation(ctx, rcpt.ID, tool.ProviderID, a)
+		assert.ErrorIs(t, err, registry.ErrInvalidAttestation, a.Format)
+	}
+
+	attested, err := r.AttachAttestation(ctx, rcpt.ID, tool.ProviderID, genuine)
+	require.NoError(t, err)
+	assert.Equal(t, genuine, attested.Attestation)
+	got, err := r.GetReceipt(ctx, rcpt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, genuine, got.Attestation)
+	_, err = r.AttachAttestation(ctx, rcpt.ID, tool.ProviderID, genuine)
+	assert.ErrorIs(t, err, registry.ErrReceiptSealed, "a receipt takes one attestation")
+
+	v := r.VerifyReceipt(ctx, got, nil, nil)
+	assert.Equal(t, receipts.CheckValid, v.Attestation)
+	// The quote binds the receipt: it does not verify for another one.
+	other := receipt()
+	other.Attestation = genuine
+	v = r.VerifyReceipt(ctx, other, nil, nil)
+	assert.Equal(t, receipts.CheckInvalid, v.Attestation)
+	assert.False(t, v.Valid)
+
+	// Formats without a verifier are accepted and left unchecked.
+	unknown := &registry.Attestation{Format: "sev-snp", Quote: []byte("report")}
+	got, err = r.AttachAttestation(ctx, other.ID, tool.ProviderID, unknown)
+	require.NoError(t, err)
+	assert.Equal(t, receipts.CheckUnchecked, r.VerifyReceipt(ctx, got, nil, nil).Attestation)
+	assert.Empty(t, r.VerifyReceipt(ctx, receipt(), nil, nil).Attestation)
+}
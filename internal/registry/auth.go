@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidAuth is returned for endpoint auth that cannot be stored: it is
+// malformed, or the registry has no secrets key.
+var ErrInvalidAuth = errors.New("invalid endpoint auth")
+
+// secretPlaceholder is replaced by the secret when rendering a template.
+const secretPlaceholder = "{secret}"
+
+// reservedHeaders may not carry endpoint auth: the router sets them itself.
+var reservedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Content-Type": true, "Connection": true,
+	"Transfer-Encoding": true, "Te": true, "Upgrade": true, "Trailer": true,
+}
+
+// EndpointAuth is the credential the invocation router attaches to every call
+// to a tool's endpoint. It is write-only: the registry stores it encrypted
+// and never returns it to consumers.
+type EndpointAuth struct {
+	// Header defaults to Authorization.
+	Header string `json:"header,omitempty"`
+	// Template is the header value, with {secret} standing for Secret;
+	// it defaults to "Bearer {secret}".
+	Template string `json:"template,omitempty"`
+	Secret   string `json:"secret"`
+}
+
+// Render returns the header name and value to send.
+func (a *EndpointAuth) Render() (name, value string) {
+	return a.Header, strings.ReplaceAll(a.Template, secretPlaceholder, a.Secret)
+}
+
+// normalize fills in defaults and validates a.
+func (a *EndpointAuth) normalize() error {
+	if a.Secret == "" {
+		return fmt.Errorf("%w: secret is required", ErrInvalidAuth)
+	}
+	if a.Header == "" {
+		a.Header = "Authorization"
+	}
+	if a.Template == "" {
+		a.Template = "Bearer " + secretPlaceholder
+	}
+	a.Header = textproto.CanonicalMIMEHeaderKey(a.Header)
+	if strings.ContainsAny(a.Header, " \t\r\n:") || reservedHeaders[a.Header] {
+		return fmt.Errorf("%w: header %q cannot be used", ErrInvalidAuth, a.Header)
+	}
+	if !strings.Contains(a.Template, secretPlaceholder) {
+		return fmt.Errorf("%w: template must contain %s", ErrInvalidAuth, secretPlaceholder)
+	}
+	if strings.ContainsAny(a.Template+a.Secret, "\r\n") {
+		return fmt.Errorf("%w: header value must be a single line", ErrInvalidAuth)
+	}
+	return nil
+}
+
+// WithSecrets enables endpoint auth, sealing it with box. Without it, tools
+// that carry auth are rejected.
+func WithSecrets(box *secrets.Box) Option {
+	return func(r *Registry) { r.secrets = box }
+}
+
+// sealAuth validates and encrypts auth for toolID. A nil auth seals to "".
+func (r *Registry) sealAuth(toolID string, auth *EndpointAuth) (string, error) {
+	if auth == nil {
+		return "", nil
+	}
+	if r.secrets == nil {
+		return "", fmt.Errorf("%w: this registry has no secrets key configured", ErrInvalidAuth)
+	}
+	if err := auth.normalize(); err != nil {
+		return "", err
+	}
+	plain, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	// Binding to the tool ID stops a sealed value being copied to another tool.
+	return r.secrets.Seal(plain, []byte(toolID))
+}
+
+// SetEndpointAuth replaces, or with a nil auth removes, the endpoint auth of
+// a tool owned by providerID.
+func (r *Registry) SetEndpointAuth(ctx context.Context, id, providerID string, auth *EndpointAuth) error {
+	sealed, err := r.sealAuth(id, auth)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE tools SET endpoint_auth = ?, updated_at = ? WHERE id = ? AND provider_id = ? AND namespace = ?",
+		sealed, time.Now().Unix(), id, providerID, NamespaceFrom(ctx))
+	if err != nil {
+		return fmt.Errorf("set endpoint auth: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w or not authorized", ErrNotFound)
+	}
+	r.logger(ctx).Info("endpoint auth updated", zap.String("id", id), zap.Bool("removed", auth == nil))
+	return nil
+}
+
+// EndpointAuth returns the decrypted endpoint auth of a tool, or nil when it
+// has none. Only the invocation router should call it.
+func (r *Registry) EndpointAuth(ctx context.Context, id string) (*EndpointAuth, error) {
+	var sealed string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT endpoint_auth FROM tools WHERE id = ? AND namespace = ?", id, NamespaceFrom(ctx)).Scan(&sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get endpoint auth: %w", err)
+	}
+	if sealed == "" {
+		return nil, nil
+	}
+	if r.secrets == nil {
+		return nil, fmt.Errorf("tool %s has endpoint auth but no secrets key is configured", id)
+	}
+	plain, err := r.secrets.Open(sealed, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", id, err)
+	}
+	var auth EndpointAuth
+	if err := json.Unmarshal(plain, &auth); err != nil {
+		return nil, fmt.Errorf("decode endpoint auth: %w", err)
+	}
+	return &auth, nil
+}
@@ -0,0 +1,82 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newSecretsRegistry(t *testing.T) *registry.Registry {
+	t.Helper()
+	box, err := secrets.New(bytes.Repeat([]byte{1}, secrets.KeySize))
+	require.NoError(t, err)
+	return registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithSecrets(box))
+}
+
+func TestEndpointAuth_RegisterAndRender(t *testing.T) {
+	r := newSecretsRegistry(t)
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.Auth = &registry.EndpointAuth{Secret: "sk-123"}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+
+	auth, err := r.EndpointAuth(ctx, tool.ID)
+	require.NoError(t, err)
+	require.NotNil(t, auth)
+	name, value := auth.Render()
+	assert.Equal(t, "Authorization", name)
+	assert.Equal(t, "Bearer sk-123", value)
+
+	err = r.SetEndpointAuth(ctx, tool.ID, req.ProviderID,
+		&registry.EndpointAuth{Header: "x-api-key", Template: "{secret}", Secret: "k2"})
+	require.NoError(t, err)
+	auth, err = r.EndpointAuth(ctx, tool.ID)
+	require.NoError(t, err)
+	name, value = auth.Render()
+	assert.Equal(t, "X-Api-Key", name)
+	assert.Equal(t, "k2", value)
+
+	require.NoError(t, r.SetEndpointAuth(ctx, tool.ID, req.ProviderID, nil))
+	auth, err = r.EndpointAuth(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Nil(t, auth)
+}
+
+func TestEndpointAuth_Errors(t *testing.T) {
+	r := newSecretsRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	owner := validRegisterReq().ProviderID
+
+	for _, bad := range []*registry.EndpointAuth{
+		{},
+		{Secret: "s", Header: "Host"},
+		{Secret: "s", Header: "X Bad"},
+		{Secret: "s", Template: "no placeholder"},
+		{Secret: "s\r\nX-Injected: 1"},
+	} {
+		err := r.SetEndpointAuth(ctx, tool.ID, owner, bad)
+		assert.ErrorIs(t, err, registry.ErrInvalidAuth, "%+v", bad)
+	}
+
+	err = r.SetEndpointAuth(ctx, tool.ID, "did:claw:agent:someone-else", &registry.EndpointAuth{Secret: "s"})
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	_, err = r.EndpointAuth(ctx, "did:claw:tool:missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	// Without a secrets key, auth is refused rather than stored in the clear.
+	plain := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Auth = &registry.EndpointAuth{Secret: "s"}
+	_, err = plain.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidAuth)
+}
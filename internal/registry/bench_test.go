@@ -0,0 +1,111 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"go.uber.org/zap"
+)
+
+// benchCatalog is how many tools the read benchmarks run against.
+const benchCatalog = 1000
+
+// newBenchRegistry returns a registry holding n tools, and their IDs. It
+// logs nothing, so logging does not dominate the measurements.
+func newBenchRegistry(b *testing.B, n int) (*registry.Registry, []string) {
+	b.Helper()
+	db, err := store.Open(":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+	r := registry.New(db, zap.NewNop())
+	ids := make([]string, n)
+	for i := range ids {
+		tool, err := r.RegisterTool(context.Background(), benchRegisterReq(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids[i] = tool.ID
+	}
+	return r, ids
+}
+
+func benchRegisterReq(i int) *registry.RegisterToolRequest {
+	req := validRegisterReq()
+	req.Name = fmt.Sprintf("bench-tool-%d", i)
+	req.Description = fmt.Sprintf("Benchmark tool %d audits solidity contracts", i)
+	if i%10 == 0 {
+		req.Description = fmt.Sprintf("Benchmark tool %d forecasts weather", i)
+	}
+	return req
+}
+
+func BenchmarkRegisterTool(b *testing.B) {
+	r, _ := newBenchRegistry(b, 0)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.RegisterTool(ctx, benchRegisterReq(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetTool(b *testing.B) {
+	r, ids := newBenchRegistry(b, benchCatalog)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GetTool(ctx, ids[i%len(ids)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearchTools(b *testing.B) {
+	r, _ := newBenchRegistry(b, benchCatalog)
+	ctx := context.Background()
+	for _, query := range []string{"weather", "solidity"} {
+		b.Run(query, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.SearchTools(ctx, &registry.SearchQuery{Query: query, Limit: 20}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListTools(b *testing.B) {
+	r, _ := newBenchRegistry(b, benchCatalog)
+	ctx := context.Background()
+	// Both modes fetch the last page; the cursor seeks to it directly.
+	last := benchCatalog / 20
+	prev, err := r.ListTools(ctx, last-1, 20)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("offset", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.ListTools(ctx, last, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cursor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.ListToolsAfter(ctx, prev.NextCursor, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// bytesPerToken is how many bytes of JSON the registry counts as a token
+// when a per-token provider does not report its usage.
+const bytesPerToken = 4
+
+type budgetKey struct{}
+
+// WithBudget records on ctx the most, in CLAW, the consumer agreed to pay
+// for the invocation run with it; "" sets no limit.
+func WithBudget(ctx context.Context, budget string) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// BudgetFrom returns the budget recorded on ctx by WithBudget.
+func BudgetFrom(ctx context.Context) string {
+	budget, _ := ctx.Value(budgetKey{}).(string)
+	return budget
+}
+
+// EstimateCost returns the least an invocation of tool with the encoded
+// input costs, known before the call: the per-call price, or for per-token
+// tools the price of the input's tokens. It is "" for tools that cost
+// nothing or whose price is not per call or per token.
+func EstimateCost(tool *Tool, input []byte) string {
+	if tool.Pricing == nil {
+		return ""
+	}
+	switch tool.Pricing.Model {
+	case PricingPerCall:
+		return tool.Pricing.AmountCLAW
+	case PricingPerToken:
+		return tokenCost(tool.Pricing.AmountCLAW, estimateTokens(input))
+	}
+	return ""
+}
+
+// InvocationCost returns what an invocation of tool that took the encoded
+// input and returned out costs: the per-call price, or for per-token tools
+// the price of the tokens the provider reports in the output's
+// usage.tokens, or failing that of the input's and output's tokens counted
+// at four bytes each.
+func InvocationCost(tool *Tool, input, out []byte) string {
+	if tool.Pricing == nil || tool.Pricing.Model != PricingPerToken {
+		return EstimateCost(tool, input)
+	}
+	var reported struct {
+		Usage struct {
+			Tokens *int64 `json:"tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(out, &reported); err == nil && reported.Usage.Tokens != nil && *reported.Usage.Tokens >= 0 {
+		return tokenCost(tool.Pricing.AmountCLAW, *reported.Usage.Tokens)
+	}
+	return tokenCost(tool.Pricing.AmountCLAW, estimateTokens(input)+estimateTokens(out))
+}
+
+func estimateTokens(b []byte) int64 {
+	return int64((len(b) + bytesPerToken - 1) / bytesPerToken)
+}
+
+// tokenCost prices tokens at perToken CLAW each, rounded to nine decimals so
+// that float noise does not end up on receipts.
+func tokenCost(perToken string, tokens int64) string {
+	price, err := strconv.ParseFloat(perToken, 64)
+	if err != nil {
+		return ""
+	}
+	cost := math.Round(price*float64(tokens)*1e9) / 1e9
+	return strconv.FormatFloat(cost, 'f', -1, 64)
+}
+
+// CheckBudget returns ErrBudgetExceeded when cost is over budget, and
+// ErrInvalidBudget when budget is not a non-negative decimal; an empty
+// budget allows any cost.
+func CheckBudget(cost, budget string) error {
+	if budget == "" {
+		return nil
+	}
+	b, err := strconv.ParseFloat(budget, 64)
+	if err != nil || b < 0 {
+		return fmt.Errorf("%w: budget_claw must be a non-negative decimal", ErrInvalidBudget)
+	}
+	if cost == "" {
+		return nil
+	}
+	c, err := strconv.ParseFloat(cost, 64)
+	if err == nil && c > b {
+		return fmt.Errorf("%w: costs %s CLAW, budget is %s", ErrBudgetExceeded, cost, budget)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package registry_test
+
+import (
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvocationCost(t *testing.T) {
+	perCall := &registry.Tool{Pricing: &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "5.0"}}
+	perToken := &registry.Tool{Pricing: &registry.Pricing{Model: registry.PricingPerToken, AmountCLAW: "0.1"}}
+	free := &registry.Tool{Pricing: &registry.Pricing{Model: registry.PricingFree}}
+	input := []byte(`{"text":"hello"}`) // 16 bytes, 4 tokens
+
+	assert.Equal(t, "5.0", registry.EstimateCost(perCall, input))
+	assert.Equal(t, "0.4", registry.EstimateCost(perToken, input))
+	assert.Empty(t, registry.EstimateCost(free, input))
+	assert.Empty(t, registry.EstimateCost(&registry.Tool{}, input))
+
+	assert.Equal(t, "5.0", registry.InvocationCost(perCall, input, []byte(`{"usage":{"tokens":1000}}`)))
+	assert.Equal(t, "0.3", registry.InvocationCost(perToken, input, []byte(`{"text":"hi","usage":{"tokens":3}}`)),
+		"tokens the provider reports")
+	assert.Equal(t, "0.7", registry.InvocationCost(perToken, input, []byte(`{"t":"hi!"}`)),
+		"4 input and 3 output tokens counted")
+}
+
+func TestCheckBudget(t *testing.T) {
+	assert.NoError(t, registry.CheckBudget("5.0", ""))
+	assert.NoError(t, registry.CheckBudget("5.0", "5"))
+	assert.NoError(t, registry.CheckBudget("", "0"))
+	assert.ErrorIs(t, registry.CheckBudget("5.01", "5"), registry.ErrBudgetExceeded)
+	assert.ErrorIs(t, registry.CheckBudget("1", "-1"), registry.ErrInvalidBudget)
+	assert.ErrorIs(t, registry.CheckBudget("", "lots"), registry.ErrInvalidBudget)
+}
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"go.uber.org/zap"
+)
+
+// DefaultCacheTTL is how long results of a tool registered as
+// deterministic, without a cache policy of its own, stay cached.
+const DefaultCacheTTL = time.Hour
+
+// Cacheable reports whether results of the tool may be served from cache.
+func (t *Tool) Cacheable() bool {
+	return t.Cache != nil && t.Cache.Deterministic && t.Cache.TTLSeconds > 0
+}
+
+// CachedPrice returns what a result served from cache costs: the cache
+// price of a per-call tool, and nothing otherwise.
+func (t *Tool) CachedPrice() string {
+	if t.Pricing == nil || t.Pricing.Model != PricingPerCall || t.Cache == nil {
+		return ""
+	}
+	return t.Cache.PriceCLAW
+}
+
+// CachedResult returns the cached output of tool for the encoded input, if
+// a fresh one exists. Lookup errors are logged and treated as a miss.
+func (r *Registry) CachedResult(ctx context.Context, tool *Tool, input []byte) ([]byte, bool) {
+	if !tool.Cacheable() {
+		return nil, false
+	}
+	var out []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT output FROM result_cache WHERE tool_id = ? AND input_hash = ? AND expires_at > ?
+	`, tool.ID, hashInput(input), time.Now().Unix()).Scan(&out)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger(ctx).Warn("read result cache", zap.String("tool_id", tool.ID), zap.Error(err))
+		}
+		return nil, false
+	}
+	return out, true
+}
+
+// CacheResult stores the output of tool for the encoded input for the TTL
+// of its cache policy. It does nothing for tools that are not cacheable.
+func (r *Registry) CacheResult(ctx context.Context, tool *Tool, input, output []byte) {
+	if !tool.Cacheable() {
+		return
+	}
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO result_cache (tool_id, input_hash, output, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tool.ID, hashInput(input), output, now.Unix(), now.Add(tool.Cache.TTL()).Unix())
+	if err != nil {
+		r.logger(ctx).Warn("write result cache", zap.String("tool_id", tool.ID), zap.Error(err))
+	}
+}
+
+// CompleteCachedInvocation completes an invocation whose output was served
+// from the result cache and stores its receipt.
+func (r *Registry) CompleteCachedInvocation(ctx context.Context, id, outputHash, costCLAW string) error {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET
+			status = 'completed', output_hash = ?, cost_claw = ?, completed_at = ?, cached = 1
+		WHERE id = ?
+	`, outputHash, costCLAW, time.Now().Unix(), id)
+	if err != nil {
+		return err
+	}
+	if err := r.writeReceipt(ctx, id); err != nil {
+		return err
+	}
+	r.publishInvocation(ctx, events.InvocationCompleted, id)
+	return nil
+}
+
+// PurgeResultCache deletes expired cached results.
+func (r *Registry) PurgeResultCache(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM result_cache WHERE expires_at <= ?", time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("purge result cache: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger(ctx).Debug("result cache purged", zap.Int64("rows", n))
+	}
+	return nil
+}
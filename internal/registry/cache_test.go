@@ -0,0 +1,107 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCachePolicy_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cache *registry.CachePolicy
+		err   string
+	}{
+		"valid":             {cache: &registry.CachePolicy{Deterministic: true, TTLSeconds: 60, PriceCLAW: "0.5"}},
+		"free":              {cache: &registry.CachePolicy{Deterministic: true, TTLSeconds: 60}},
+		"not deterministic": {cache: &registry.CachePolicy{TTLSeconds: 60}, err: "deterministic"},
+		"no ttl":            {cache: &registry.CachePolicy{Deterministic: true}, err: "ttl_seconds"},
+		"bad price":         {cache: &registry.CachePolicy{Deterministic: true, TTLSeconds: 60, PriceCLAW: "-1"}, err: "non-negative"},
+		"dearer":            {cache: &registry.CachePolicy{Deterministic: true, TTLSeconds: 60, PriceCLAW: "6"}, err: "exceed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := validRegisterReq()
+			req.Cache = tc.cache
+			err := req.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestRegisterToolRequest_Deterministic(t *testing.T) {
+	req := validRegisterReq()
+	req.Deterministic = true
+	require.NoError(t, req.Validate())
+	assert.Equal(t, &registry.CachePolicy{Deterministic: true, TTLSeconds: 3600}, req.Cache)
+
+	explicit := validRegisterReq()
+	explicit.Cache = &registry.CachePolicy{Deterministic: true, TTLSeconds: 3600}
+	h1, err := registry.ManifestHash(req)
+	require.NoError(t, err)
+	h2, err := registry.ManifestHash(explicit)
+	require.NoError(t, err)
+	assert.Equal(t, h1, h2, "the shorthand hashes as the policy it stands for")
+
+	req = validRegisterReq()
+	req.Deterministic, req.Cache = true, &registry.CachePolicy{Deterministic: true, TTLSeconds: 60}
+	require.NoError(t, req.Validate())
+	assert.Equal(t, int64(60), req.Cache.TTLSeconds, "an explicit policy wins")
+}
+
+func TestResultCache(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t))
+	ctx := context.Background()
+	req := validRegisterReq()
+	req.Cache = &registry.CachePolicy{Deterministic: true, TTLSeconds: 60, PriceCLAW: "0.5"}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, req.Cache, tool.Cache)
+	assert.True(t, tool.Cacheable())
+	assert.Equal(t, "0.5", tool.CachedPrice())
+
+	input := []byte(`{"input":"x"}`)
+	_, ok := r.CachedResult(ctx, tool, input)
+	assert.False(t, ok)
+	r.CacheResult(ctx, tool, input, []byte(`{"output":"y"}`))
+	out, ok := r.CachedResult(ctx, tool, input)
+	require.True(t, ok)
+	assert.JSONEq(t, `{"output":"y"}`, string(out))
+	_, ok = r.CachedResult(ctx, tool, []byte(`{"input":"z"}`))
+	assert.False(t, ok, "other input")
+
+	id, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"input": "x"})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteCachedInvocation(ctx, id, "sha256:00", tool.CachedPrice()))
+	inv, err := r.GetInvocation(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, inv.Cached)
+	assert.Equal(t, "0.5", inv.CostCLAW)
+
+	// Expired results are neither served nor kept.
+	_, err = db.ExecContext(ctx, "UPDATE result_cache SET expires_at = 0")
+	require.NoError(t, err)
+	_, ok = r.CachedResult(ctx, tool, input)
+	assert.False(t, ok, "expired")
+	require.NoError(t, r.PurgeResultCache(ctx))
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM result_cache").Scan(&n))
+	assert.Zero(t, n)
+
+	// Tools without a policy are never cached.
+	plain, err := r.RegisterTool(ctx, &registry.RegisterToolRequest{
+		Name: "plain", Version: "1.0.0", Endpoint: "https://p.example", ProviderID: "did:claw:agent:p",
+		Schema: registry.ToolSchema{Input: []byte(`{}`)},
+	})
+	require.NoError(t, err)
+	r.CacheResult(ctx, plain, input, []byte(`{}`))
+	_, ok = r.CachedResult(ctx, plain, input)
+	assert.False(t, ok)
+}
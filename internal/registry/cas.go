@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"go.uber.org/zap"
+)
+
+// WithCAS pins the manifest of every registered tool version to store and
+// records its CID on the tool.
+func WithCAS(store cas.Store) Option {
+	return func(r *Registry) { r.cas = store }
+}
+
+// pinManifest stores the canonical manifest of req and returns its CID, or
+// "" when no store is configured. Pinning failures are logged rather than
+// failing the registration: the CID is a convenience for consumers, the
+// manifest hash already pins the content.
+func (r *Registry) pinManifest(ctx context.Context, req *RegisterToolRequest) string {
+	if r.cas == nil {
+		return ""
+	}
+	doc, err := ManifestDocument(req)
+	if err == nil {
+		var cid string
+		if cid, err = r.cas.Put(ctx, doc); err == nil {
+			return cid
+		}
+	}
+	r.logger(ctx).Warn("pin manifest", zap.String("name", req.Name), zap.String("version", req.Version), zap.Error(err))
+	return ""
+}
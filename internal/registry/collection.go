@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidCollection is returned for a collection with a malformed name
+// or version, no tools, repeated tools or tools that do not exist.
+var ErrInvalidCollection = errors.New("invalid collection")
+
+// maxCollectionTools bounds the tools of one collection version.
+const maxCollectionTools = 100
+
+var collectionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Collection is a named, versioned bundle of tools, such as
+// "solidity-audit-suite", that agents find and install as a unit. The
+// first owner to publish a name keeps it: only they publish its further
+// versions.
+type Collection struct {
+	CreatedAt   time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Description string    `json:"description,omitempty"`
+	OwnerID     string    `json:"owner_id"`
+	ToolIDs     []string  `json:"tool_ids"`
+	Tags        []string  `json:"tags"`
+}
+
+func (c *Collection) validate() error {
+	if !collectionNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("%w: name must be 1-63 lowercase letters, digits or dashes", ErrInvalidCollection)
+	}
+	if c.Version == "" || len(c.Version) > 64 {
+		return fmt.Errorf("%w: version must be 1-64 characters", ErrInvalidCollection)
+	}
+	if len(c.ToolIDs) == 0 || len(c.ToolIDs) > maxCollectionTools {
+		return fmt.Errorf("%w: a collection has 1 to %d tools", ErrInvalidCollection, maxCollectionTools)
+	}
+	for i, id := range c.ToolIDs {
+		if slices.Contains(c.ToolIDs[:i], id) {
+			return fmt.Errorf("%w: tool %s is listed twice", ErrInvalidCollection, id)
+		}
+	}
+	c.Tags = CanonicalTags(c.Tags)
+	return nil
+}
+
+// CreateCollection publishes a version of a collection in the context
+// namespace, owned by c.OwnerID. It returns ErrForbidden when another owner
+// holds the name and ErrDuplicate when the version exists.
+func (r *Registry) CreateCollection(ctx context.Context, c *Collection) (*Collection, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	for _, id := range c.ToolIDs {
+		if _, err := r.GetTool(ctx, id); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("%w: tool %s not found", ErrInvalidCollection, id)
+			}
+			return nil, err
+		}
+	}
+	ns := NamespaceFrom(ctx)
+	var owner string
+	err := r.db.QueryRowContext(ctx, "SELECT owner_id FROM collections WHERE namespace = ? AND name = ? LIMIT 1",
+		ns, c.Name).Scan(&owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("get collection owner: %w", err)
+	}
+	if owner != "" && owner != c.OwnerID {
+		return nil, fmt.Errorf("%w: collection %s belongs to %s", ErrForbidden, c.Name, owner)
+	}
+
+	toolIDs, err := json.Marshal(c.ToolIDs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal tool ids: %w", err)
+	}
+	c.ID = "coll_" + uuid.NewString()
+	c.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO collections (id, namespace, name, version, description, owner_id, tool_ids, tags, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.ID, ns, c.Name, c.Version, c.Description, c.OwnerID, string(toolIDs), encodeTags(c.Tags), c.CreatedAt.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: collection %s@%s", ErrDuplicate, c.Name, c.Version)
+		}
+		return nil, fmt.Errorf("insert collection: %w", err)
+	}
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	r.logger(ctx).Info("collection published", zap.String("name", c.Name), zap.String("version", c.Version),
+		zap.String("owner", c.OwnerID), zap.Int("tools", len(c.ToolIDs)))
+	return c, nil
+}
+
+// collectionColumns is the column list scanned by scanCollection.
+const collectionColumns = "id, name, version, description, owner_id, tool_ids, tags, created_at"
+
+func scanCollection(row scanner) (*Collection, error) {
+	var (
+		c             Collection
+		toolIDs, tags string
+		created       int64
+	)
+	if err := row.Scan(&c.ID, &c.Name, &c.Version, &c.Description, &c.OwnerID, &toolIDs, &tags, &created); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(toolIDs), &c.ToolIDs); err != nil {
+		return nil, fmt.Errorf("unmarshal tool ids: %w", err)
+	}
+	var err error
+	if c.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+	c.CreatedAt = time.Unix(created, 0).UTC()
+	return &c, nil
+}
+
+// GetCollection returns a version of a collection in the context
+// namespace, the latest one when version is empty.
+func (r *Registry) GetCollection(ctx context.Context, name, version string) (*Collection, error) {
+	where, args := "namespace = ? AND name = ?", []any{NamespaceFrom(ctx), name}
+	if version != "" {
+		where += " AND version = ?"
+		args = append(args, version)
+	}
+	c, err := scanCollection(r.db.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE "+where+
+		" ORDER BY created_at DESC, rowid DESC LIMIT 1", args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get collection: %w", err)
+	}
+	return c, nil
+}
+
+// ListCollectionVersions returns every version of a collection, newest
+// first.
+func (r *Registry) ListCollectionVersions(ctx context.Context, name string) ([]*Collection, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+collectionColumns+
+		" FROM collections WHERE namespace = ? AND name = ? ORDER BY created_at DESC, rowid DESC", NamespaceFrom(ctx), name)
+	if err != nil {
+		return nil, fmt.Errorf("list collection versions: %w", err)
+	}
+	return scanCollections(rows)
+}
+
+// SearchCollections returns the latest version of each collection of the
+// context namespace whose name or description contains query and, when tag
+// is set, that carries the tag; newest first, at most limit of them.
+func (r *Registry) SearchCollections(ctx context.Context, query, tag string, limit int) ([]*Collection, error) {
+	where, args := "namespace = ?", []any{NamespaceFrom(ctx)}
+	if query != "" {
+		where += " AND (name LIKE ? ESCAPE '\\' OR description LIKE ? ESCAPE '\\')"
+		pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if tags := CanonicalTags([]string{tag}); len(tags) > 0 {
+		where += " AND EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ?)"
+		args = append(args, tags[0])
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+collectionColumns+" FROM collections c WHERE "+where+`
+		AND NOT EXISTS (SELECT 1 FROM collections n
+			WHERE n.namespace = c.namespace AND n.name = c.name AND (n.created_at, n.rowid) > (c.created_at, c.rowid))
+		ORDER BY created_at DESC, rowid DESC LIMIT ?`, append(args, listLimit(limit))...)
+	if err != nil {
+		return nil, fmt.Errorf("search collections: %w", err)
+	}
+	return scanCollections(rows)
+}
+
+func scanCollections(rows *sql.Rows) ([]*Collection, error) {
+	defer func() { _ = rows.Close() }()
+	out := []*Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan collection: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// CollectionTools returns the tools of a collection that can be invoked,
+// in the collection's order, and the IDs of those that cannot any more:
+// deactivated, revoked or deleted since the version was published.
+func (r *Registry) CollectionTools(ctx context.Context, c *Collection) ([]*Tool, []string, error) {
+	tools := make([]*Tool, 0, len(c.ToolIDs))
+	unavailable := []string{}
+	for _, id := range c.ToolIDs {
+		t, err := r.GetTool(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			unavailable = append(unavailable, id)
+		case err != nil:
+			return nil, nil, err
+		case !t.IsActive || t.Invocable() != nil:
+			unavailable = append(unavailable, id)
+		default:
+			tools = append(tools, t)
+		}
+	}
+	return tools, unavailable, nil
+}
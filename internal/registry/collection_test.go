@@ -0,0 +1,96 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollections(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	var ids []string
+	for _, name := range []string{"slither", "mythril", "echidna"} {
+		req := validRegisterReq()
+		req.Name = name
+		tool, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+		ids = append(ids, tool.ID)
+	}
+	const owner = "did:claw:agent:curator"
+
+	v1, err := r.CreateCollection(ctx, &registry.Collection{
+		Name: "solidity-audit-suite", Version: "1.0.0", OwnerID: owner, Description: "Static analysis for Solidity",
+		ToolIDs: ids[:2], Tags: []string{"Solidity", "audit"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"solidity", "audit"}, v1.Tags)
+	_, err = r.CreateCollection(ctx, &registry.Collection{Name: "solidity-audit-suite", Version: "1.1.0", OwnerID: owner,
+		ToolIDs: ids, Tags: []string{"solidity"}})
+	require.NoError(t, err)
+	_, err = r.CreateCollection(ctx, &registry.Collection{Name: "fuzzers", Version: "1", OwnerID: "did:claw:agent:other",
+		ToolIDs: ids[2:]})
+	require.NoError(t, err)
+
+	latest, err := r.GetCollection(ctx, "solidity-audit-suite", "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", latest.Version)
+	assert.Equal(t, ids, latest.ToolIDs)
+	got, err := r.GetCollection(ctx, "solidity-audit-suite", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, v1, got)
+	_, err = r.GetCollection(ctx, "solidity-audit-suite", "2.0.0")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	versions, err := r.ListCollectionVersions(ctx, "solidity-audit-suite")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "1.1.0", versions[0].Version)
+
+	names := func(cs []*registry.Collection) []string {
+		var out []string
+		for _, c := range cs {
+			out = append(out, c.Name+"@"+c.Version)
+		}
+		return out
+	}
+	found, err := r.SearchCollections(ctx, "", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fuzzers@1", "solidity-audit-suite@1.1.0"}, names(found), "latest versions only")
+	found, err = r.SearchCollections(ctx, "Static", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, found, "the description of the latest version does not match")
+	found, err = r.SearchCollections(ctx, "audit", "SOLIDITY", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"solidity-audit-suite@1.1.0"}, names(found))
+	found, err = r.SearchCollections(ctx, "100%", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	require.NoError(t, r.DeactivateTool(ctx, ids[1], validRegisterReq().ProviderID))
+	tools, unavailable, err := r.CollectionTools(ctx, latest)
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	assert.Equal(t, ids[0], tools[0].ID)
+	assert.Equal(t, []string{ids[1]}, unavailable)
+
+	_, err = r.CreateCollection(ctx, &registry.Collection{Name: "fuzzers", Version: "2", OwnerID: owner, ToolIDs: ids[2:]})
+	assert.ErrorIs(t, err, registry.ErrForbidden, "the name belongs to its first owner")
+	_, err = r.CreateCollection(ctx, &registry.Collection{Name: "fuzzers", Version: "1", OwnerID: "did:claw:agent:other",
+		ToolIDs: ids[2:]})
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+	for name, c := range map[string]*registry.Collection{
+		"name":     {Name: "Audit Suite", Version: "1", ToolIDs: ids},
+		"version":  {Name: "suite", ToolIDs: ids},
+		"no tools": {Name: "suite", Version: "1"},
+		"twice":    {Name: "suite", Version: "1", ToolIDs: []string{ids[0], ids[0]}},
+		"unknown":  {Name: "suite", Version: "1", ToolIDs: []string{"did:claw:tool:missing"}},
+	} {
+		c.OwnerID = owner
+		_, err := r.CreateCollection(ctx, c)
+		assert.ErrorIs(t, err, registry.ErrInvalidCollection, name)
+	}
+}
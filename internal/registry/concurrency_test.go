@@ -0,0 +1,57 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestConcurrentWriters(t *testing.T) {
+	// A file database: every pooled connection of :memory: is a separate
+	// database, and only a shared file can contend for the write lock.
+	db, err := store.Open(t.TempDir() + "/agent-tools.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	r := registry.New(db, zap.NewNop())
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	const writers = 500
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				req := validRegisterReq()
+				req.Name = fmt.Sprintf("tool-%d", i)
+				_, err := r.RegisterTool(ctx, req)
+				errs <- err
+				return
+			}
+			id, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:consumer", map[string]any{"n": i})
+			if err == nil {
+				err = r.CompleteInvocation(ctx, id, "out", "", "5.0")
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	list, err := r.ListTools(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1+writers/2, list.Total)
+}
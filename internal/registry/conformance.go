@@ -0,0 +1,213 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/policy"
+	"go.uber.org/zap"
+)
+
+// maxToolTests is how many conformance tests a tool may be registered with.
+const maxToolTests = 10
+
+// ErrInvalidTests is returned for conformance tests without a name or
+// whose expectation does not compile.
+var ErrInvalidTests = errors.New("invalid tests")
+
+// Conformance states of a tool.
+const (
+	ConformancePassing = "passing"
+	ConformanceFailing = "failing"
+)
+
+// Health states of a tool.
+const (
+	HealthHealthy = "healthy"
+	HealthFailing = "failing"
+)
+
+// testVars are the attributes the expectation of a ToolTest may name: the
+// fields of the output, by path, such as output.status or output.items.
+var testVars = []string{"output."}
+
+// ToolTest is a conformance test a tool may be registered with. The
+// registry invokes the tool with Input when it is registered and
+// periodically after, and the tool conforms while every test's Expect holds
+// of its output.
+type ToolTest struct {
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input"`
+	// Expect is a policy expression over the output, whose fields it names
+	// by path: `output.status == "ok" and output.count > 0`.
+	Expect string `json:"expect"`
+}
+
+// Check returns why output fails the test, or nil when it passes.
+func (t *ToolTest) Check(output map[string]any) error {
+	e, err := policy.Compile(t.Expect, testVars)
+	if err != nil {
+		return err
+	}
+	vars := map[string]any{}
+	flattenOutput(vars, "output", output)
+	ok, err := e.Eval(vars)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("expected %s", t.Expect)
+	}
+	return nil
+}
+
+// flattenOutput adds the fields of v under prefix to vars, as the values
+// policy expressions compare: strings, numbers, bools and lists of strings.
+// Other values can only be reached through their fields.
+func flattenOutput(vars map[string]any, prefix string, v map[string]any) {
+	for k, e := range v {
+		name := prefix + "." + k
+		switch e := e.(type) {
+		case string, float64, bool:
+			vars[name] = e
+		case map[string]any:
+			flattenOutput(vars, name, e)
+		case []any:
+			list := make([]string, 0, len(e))
+			for _, item := range e {
+				if s, ok := item.(string); ok {
+					list = append(list, s)
+				}
+			}
+			if len(list) == len(e) {
+				vars[name] = list
+			}
+		}
+	}
+}
+
+func validateTests(tests []ToolTest) error {
+	if len(tests) > maxToolTests {
+		return fmt.Errorf("%w: at most %d tests", ErrInvalidTests, maxToolTests)
+	}
+	seen := make(map[string]bool, len(tests))
+	for i, t := range tests {
+		if t.Name == "" {
+			return fmt.Errorf("%w: test %d has no name", ErrInvalidTests, i)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("%w: duplicate test %q", ErrInvalidTests, t.Name)
+		}
+		seen[t.Name] = true
+		if _, err := policy.Compile(t.Expect, testVars); err != nil {
+			return fmt.Errorf("%w: test %q: %w", ErrInvalidTests, t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Conformance is the outcome of the last run of a tool's tests.
+type Conformance struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Status    string        `json:"status"`
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Failures  []TestFailure `json:"failures,omitempty"`
+}
+
+// TestFailure is a test that failed and why.
+type TestFailure struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// NewConformance returns the conformance of a run of tests that ended in
+// failures, one per failed test.
+func NewConformance(tests int, failures []TestFailure) *Conformance {
+	c := &Conformance{
+		CheckedAt: time.Now().UTC(),
+		Status:    ConformancePassing,
+		Passed:    tests - len(failures),
+		Failed:    len(failures),
+		Failures:  failures,
+	}
+	if len(failures) > 0 {
+		c.Status = ConformanceFailing
+	}
+	return c
+}
+
+// Health is whether a tool is keeping its promises: it is failing while
+// its conformance tests fail or it violates its SLA.
+type Health struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Health returns the health of t as last checked.
+func (t *Tool) Health() *Health {
+	h := &Health{Status: HealthHealthy}
+	if t.Conformance != nil && t.Conformance.Status == ConformanceFailing {
+		for _, f := range t.Conformance.Failures {
+			h.Reasons = append(h.Reasons, fmt.Sprintf("test %s: %s", f.Name, f.Reason))
+		}
+	}
+	if t.slaViolated() {
+		h.Reasons = append(h.Reasons, "violates its sla")
+	}
+	if len(h.Reasons) > 0 {
+		h.Status = HealthFailing
+	}
+	return h
+}
+
+// encodeTests returns the stored form of conformance tests, "" for none.
+func encodeTests(tests []ToolTest) (string, error) {
+	if len(tests) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(tests)
+	if err != nil {
+		return "", fmt.Errorf("marshal tests: %w", err)
+	}
+	return string(b), nil
+}
+
+// ConformanceTools returns the active tools with conformance tests, in
+// every namespace.
+func (r *Registry) ConformanceTools(ctx context.Context) ([]*Tool, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+toolColumns+" FROM tools WHERE tests != '' AND is_active = 1")
+	if err != nil {
+		return nil, fmt.Errorf("list conformance tools: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	return scanTools(rows)
+}
+
+// SaveConformance stores the outcome c of a run of the tests of t and
+// announces it when t has just started failing them.
+func (r *Registry) SaveConformance(ctx context.Context, t *Tool, c *Conformance) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal conformance: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "UPDATE tools SET conformance = ? WHERE id = ?", string(b), t.ID); err != nil {
+		return fmt.Errorf("save conformance: %w", err)
+	}
+	wasFailing := t.Conformance != nil && t.Conformance.Status == ConformanceFailing
+	t.Conformance = c
+	if c.Status == ConformanceFailing && !wasFailing {
+		ctx := WithNamespace(ctx, t.Namespace)
+		r.logger(ctx).Warn("tool fails its conformance tests",
+			zap.String("tool_id", t.ID),
+			zap.String("provider", t.ProviderID),
+			zap.Int("failed", c.Failed),
+		)
+		r.publish(ctx, events.ToolConformanceFailing, t.ID, "", map[string]any{"id": t.ID, "conformance": c})
+	}
+	return nil
+}
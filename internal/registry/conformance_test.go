@@ -0,0 +1,120 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolTest_Check(t *testing.T) {
+	output := map[string]any{
+		"status": "ok",
+		"count":  float64(3),
+		"tags":   []any{"a", "b"},
+		"geo":    map[string]any{"city": "Oslo"},
+		"mixed":  []any{"a", float64(1)},
+	}
+	for expect, pass := range map[string]bool{
+		`output.status == "ok" and output.count > 2`: true,
+		`"b" in output.tags`:                         true,
+		`output.geo.city matches "O*"`:               true,
+		`output.count == 4`:                          false,
+	} {
+		err := (&registry.ToolTest{Name: "t", Expect: expect}).Check(output)
+		assert.Equal(t, pass, err == nil, "%s: %v", expect, err)
+	}
+	err := (&registry.ToolTest{Name: "t", Expect: `"a" in output.mixed`}).Check(output)
+	assert.Error(t, err, "lists of other than strings cannot be compared")
+	err = (&registry.ToolTest{Name: "t", Expect: `output.missing == 1`}).Check(output)
+	assert.Error(t, err)
+}
+
+func TestRegisterTool_Tests(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Tests = []registry.ToolTest{{Name: "echo", Input: map[string]any{"input": "hi"}, Expect: `output.output == "hi"`}}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, req.Tests, got.Tests)
+	assert.Nil(t, got.Conformance, "not run yet")
+
+	hash, err := registry.ManifestHash(validRegisterReq())
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, tool.ManifestHash, "the tests are part of the manifest")
+
+	tooMany := make([]registry.ToolTest, 11)
+	for i := range tooMany {
+		tooMany[i] = registry.ToolTest{Name: fmt.Sprint(i), Expect: "true"}
+	}
+	for name, tests := range map[string][]registry.ToolTest{
+		"unnamed":   {{Expect: "true"}},
+		"duplicate": {{Name: "a", Expect: "true"}, {Name: "a", Expect: "true"}},
+		"syntax":    {{Name: "a", Expect: "output.x =="}},
+		"input":     {{Name: "a", Expect: `input.x == 1`}},
+		"too many":  tooMany,
+	} {
+		req := validRegisterReq()
+		req.Version, req.Tests = "2.0.0", tests
+		_, err := r.RegisterTool(ctx, req)
+		assert.ErrorIs(t, err, registry.ErrInvalidTests, name)
+	}
+}
+
+func TestSaveConformance(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Tests = []registry.ToolTest{{Name: "ok", Expect: "true"}}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	_, err = r.RegisterTool(ctx, func() *registry.RegisterToolRequest {
+		req := validRegisterReq()
+		req.Name = "untested"
+		return req
+	}())
+	require.NoError(t, err)
+
+	tools, err := r.ConformanceTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 1, "only tools with tests are checked")
+	assert.Equal(t, tool.ID, tools[0].ID)
+	assert.Equal(t, registry.HealthHealthy, tools[0].Health().Status)
+
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+	failing := registry.NewConformance(1, []registry.TestFailure{{Name: "ok", Reason: "timeout"}})
+	require.NoError(t, r.SaveConformance(ctx, tools[0], failing))
+	require.NoError(t, r.SaveConformance(ctx, tools[0], registry.NewConformance(1, []registry.TestFailure{{Name: "ok"}})))
+
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Conformance)
+	assert.Equal(t, registry.ConformanceFailing, got.Conformance.Status)
+	assert.Equal(t, 0, got.Conformance.Passed)
+	health := got.Health()
+	assert.Equal(t, registry.HealthFailing, health.Status)
+	assert.Equal(t, []string{"test ok: "}, health.Reasons)
+
+	var failed int
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.ToolConformanceFailing {
+			failed++
+			assert.Equal(t, tool.ID, ev.Subject)
+		}
+	}
+	assert.Equal(t, 1, failed, "only the start of a failure is announced")
+
+	require.NoError(t, r.SaveConformance(ctx, got, registry.NewConformance(1, nil)))
+	got, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registry.ConformancePassing, got.Conformance.Status)
+	assert.Equal(t, registry.HealthHealthy, got.Health().Status)
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+// ConsumerSignatureSkew is how far from the registry's clock a consumer
+// signature may have been made.
+const ConsumerSignatureSkew = 5 * time.Minute
+
+// ErrInvalidConsumerSignature is returned for a consumer signature that is
+// malformed, stale, does not verify or is made with a key that is not the
+// consumer's.
+var ErrInvalidConsumerSignature = errors.New("invalid consumer signature")
+
+// VerifyConsumerSignature checks that s signs a call of toolID by
+// consumerID with input and budget, recently, with one of the consumer's
+// VerificationKeys, and carries a nonce when they are required. The nonce
+// is claimed separately, with ClaimNonce, once the call is sure to run.
+func (r *Registry) VerifyConsumerSignature(
+	ctx context.Context, s *receipts.RequestSignature, toolID, consumerID string, input any, budget string,
+) error {
+	switch {
+	case s.ToolID != toolID:
+		return fmt.Errorf("%w: signed for tool %s", ErrInvalidConsumerSignature, s.ToolID)
+	case s.ConsumerID != consumerID:
+		return fmt.Errorf("%w: signed for consumer %s", ErrInvalidConsumerSignature, s.ConsumerID)
+	case s.BudgetCLAW != budget:
+		return fmt.Errorf("%w: signed for a budget of %q", ErrInvalidConsumerSignature, s.BudgetCLAW)
+	case s.Nonce == "" && r.requireNonces:
+		return fmt.Errorf("%w: nonce is required", ErrInvalidConsumerSignature)
+	case s.Nonce != "":
+		if err := checkNonce(s.Nonce); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidConsumerSignature, err)
+		}
+	}
+	if d := time.Since(s.SignedAt); d > ConsumerSignatureSkew || d < -ConsumerSignatureSkew {
+		return fmt.Errorf("%w: signed_at is more than %s away", ErrInvalidConsumerSignature, ConsumerSignatureSkew)
+	}
+	if err := receipts.VerifyRequest(s, input); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConsumerSignature, err)
+	}
+	key, _ := receipts.ParsePubKey(s.PubKey)
+	keys, err := r.VerificationKeys(ctx, consumerID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConsumerSignature, err)
+	}
+	for _, k := range keys {
+		if k.Equal(key) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: pubkey is not a key of %s", ErrInvalidConsumerSignature, consumerID)
+}
@@ -0,0 +1,69 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestVerifyConsumerSignature(t *testing.T) {
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(pub)
+	input := map[string]any{"q": "x"}
+	now := time.Now()
+
+	r := newTestRegistry(t)
+	s := receipts.SignRequest("did:claw:tool:1", consumer, input, "2", now, key)
+	require.NoError(t, r.VerifyConsumerSignature(ctx, s, "did:claw:tool:1", consumer, input, "2"))
+
+	unsigned := *s
+	unsigned.Nonce = ""
+	assert.ErrorIs(t, r.VerifyConsumerSignature(ctx, &unsigned, "did:claw:tool:1", consumer, input, "2"),
+		registry.ErrInvalidConsumerSignature, "the nonce is signed over")
+	strict := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithRequiredNonces(true))
+	assert.ErrorContains(t, strict.VerifyConsumerSignature(ctx, &unsigned, "did:claw:tool:1", consumer, input, "2"),
+		"nonce is required")
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		sig      *receipts.RequestSignature
+		tool     string
+		consumer string
+		input    any
+		budget   string
+		want     string
+	}{
+		{"other tool", s, "did:claw:tool:2", consumer, input, "2", "signed for tool did:claw:tool:1"},
+		{"other consumer", s, "did:claw:tool:1", "did:claw:agent:x", input, "2", "signed for consumer " + consumer},
+		{"other budget", s, "did:claw:tool:1", consumer, input, "3", `signed for a budget of "2"`},
+		{"bad nonce", func() *receipts.RequestSignature {
+			c := *s
+			c.Nonce = "short"
+			return &c
+		}(), "did:claw:tool:1", consumer, input, "2", "nonce"},
+		{"stale", receipts.SignRequest("did:claw:tool:1", consumer, input, "2", now.Add(-time.Hour), key),
+			"did:claw:tool:1", consumer, input, "2", "signed_at is more than 5m0s away"},
+		{"other input", s, "did:claw:tool:1", consumer, map[string]any{"q": "y"}, "2", "invalid request signature"},
+		{"not the consumer's key", receipts.SignRequest("did:claw:tool:1", consumer, input, "2", now, other),
+			"did:claw:tool:1", consumer, input, "2", "pubkey is not a key of " + consumer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.VerifyConsumerSignature(ctx, tt.sig, tt.tool, tt.consumer, tt.input, tt.budget)
+			assert.ErrorIs(t, err, registry.ErrInvalidConsumerSignature)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OCIScheme prefixes the endpoint of a tool packaged as a container image
+// that the registry runs itself: "oci://ghcr.io/acme/lint@sha256:<hex>".
+const OCIScheme = "oci://"
+
+// imageDigest matches the digest an image reference must be pinned to, so
+// the image cannot change under a signed manifest.
+var imageDigest = regexp.MustCompile(`^[a-z0-9][a-z0-9._/:-]*@sha256:[0-9a-f]{64}$`)
+
+// ContainerImage returns the image reference of an oci:// endpoint.
+func ContainerImage(endpoint string) (string, bool) {
+	image, ok := strings.CutPrefix(endpoint, OCIScheme)
+	return image, ok && image != ""
+}
+
+// checkImage verifies that an oci:// endpoint names an image pinned by
+// digest. Other endpoints pass.
+func checkImage(endpoint string) error {
+	image, ok := strings.CutPrefix(endpoint, OCIScheme)
+	if !ok {
+		return nil
+	}
+	if !imageDigest.MatchString(image) {
+		return fmt.Errorf("%w: container images must be pinned by digest, e.g. %sregistry/name@sha256:<hex>",
+			ErrInvalidEndpoint, OCIScheme)
+	}
+	return nil
+}
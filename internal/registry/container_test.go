@@ -0,0 +1,29 @@
+package registry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTool_OCIEndpoint(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.Endpoint = registry.OCIScheme + "ghcr.io/acme/lint:latest"
+	_, err := r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint, "tags are mutable; images must be pinned")
+
+	req = validRegisterReq()
+	req.Endpoint = registry.OCIScheme + "ghcr.io/acme/lint@sha256:" + strings.Repeat("ab", 32)
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	image, ok := registry.ContainerImage(tool.Endpoint)
+	assert.True(t, ok)
+	assert.Equal(t, "ghcr.io/acme/lint@sha256:"+strings.Repeat("ab", 32), image)
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a listing cursor the registry did not
+// issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// listCursor is the position of a tool or invocation in the newest-first
+// listing order: those created in the same second are ordered by ID.
+type listCursor struct {
+	createdAt int64
+	id        string
+}
+
+// String encodes the cursor opaquely, so clients do not come to depend on
+// its contents.
+func (c *listCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.createdAt, 10) + ":" + c.id))
+}
+
+func parseCursor(s string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	createdAt, err := strconv.ParseInt(ts, 10, 64)
+	if !ok || err != nil || id == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	return &listCursor{createdAt: createdAt, id: id}, nil
+}
+
+// listLimit applies the default and maximum page size of listings.
+func listLimit(limit int) int {
+	if limit <= 0 || limit > 100 {
+		return 20
+	}
+	return limit
+}
+
+// ListToolsAfter returns up to limit tools listed after cursor, newest
+// first; an empty cursor starts at the newest tool. Unlike ListTools it
+// seeks straight to the cursor, so every page costs the same however deep
+// into the catalog it is.
+func (r *Registry) ListToolsAfter(ctx context.Context, cursor string, limit int) (*SearchResult, error) {
+	var after *listCursor
+	if cursor != "" {
+		c, err := parseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+	return r.listTools(ctx, after, 0, listLimit(limit))
+}
+
+// listTools returns the active tools of the context namespace in listing
+// order, after the cursor if one is given and past offset. It reads one row
+// more than limit to learn whether a next page exists.
+func (r *Registry) listTools(ctx context.Context, after *listCursor, offset, limit int) (*SearchResult, error) {
+	ns := NamespaceFrom(ctx)
+	where, args := "", []any{ns}
+	if after != nil {
+		where = "AND (created_at, id) < (?, ?)"
+		args = append(args, after.createdAt, after.id)
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+toolColumns+` FROM tools
+		WHERE is_active = 1 AND advisory_status != 'revoked' AND namespace = ? `+where+`
+		ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?
+	`, append(args, limit+1, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list tools: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	tools, err := scanTools(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	err = r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM tools WHERE is_active = 1 AND advisory_status != 'revoked' AND namespace = ?", ns).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("count tools: %w", err)
+	}
+
+	res := &SearchResult{Tools: tools, Total: total, Limit: limit}
+	if len(tools) > limit {
+		res.Tools = tools[:limit]
+		last := res.Tools[limit-1]
+		res.NextCursor = (&listCursor{createdAt: last.CreatedAt.Unix(), id: last.ID}).String()
+	}
+	return res, nil
+}
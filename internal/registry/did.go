@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"go.uber.org/zap"
+)
+
+// didTimeout bounds fetching a did:web document.
+const didTimeout = 10 * time.Second
+
+// WithDIDResolver sets how did:key and did:web identifiers are resolved.
+// It defaults to a resolver that fetches did:web documents through the
+// endpoint policy.
+func WithDIDResolver(res *did.Resolver) Option {
+	return func(r *Registry) { r.dids = res }
+}
+
+// defaultDIDResolver fetches did:web documents through the endpoint policy,
+// so a DID cannot be used to reach hosts tools may not.
+func (r *Registry) defaultDIDResolver() *did.Resolver {
+	return did.NewResolver(&http.Client{Transport: r.EndpointTransport(), Timeout: didTimeout})
+}
+
+// VerificationKeys returns the keys signatures by id verify against: the
+// pubkey the provider registered, if any, and for did:key and did:web
+// identifiers the Ed25519 keys the DID resolves to. An organization signs
+// with the keys of its members.
+func (r *Registry) VerificationKeys(ctx context.Context, id string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	if IsOrg(id) {
+		members, err := r.orgMemberIDs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			// One member whose did:web cannot be fetched does not keep the
+			// others from signing.
+			memberKeys, err := r.VerificationKeys(ctx, m)
+			if err != nil {
+				r.logger(ctx).Warn("resolve organization member keys", zap.String("org", id), zap.String("member", m),
+					zap.Error(err))
+				continue
+			}
+			keys = append(keys, memberKeys...)
+		}
+	}
+	p, err := r.GetProvider(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if p != nil && p.PubKey != "" {
+		key, err := parsePubKey(p.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if did.Resolvable(id) {
+		resolved, err := r.dids.Keys(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, resolved...)
+	}
+	return keys, nil
+}
+
+// checkProviderKey requires a pubkey of providers whose ID does not carry
+// its own keys, and rejects a did:key ID that is malformed or contradicts
+// the pubkey.
+func checkProviderKey(p *Provider) error {
+	if strings.HasPrefix(p.ID, "did:key:") {
+		didKey, err := did.KeyFromDIDKey(p.ID)
+		if err != nil {
+			return err
+		}
+		if key, err := parsePubKey(p.PubKey); p.PubKey != "" && (err != nil || !didKey.Equal(key)) {
+			return fmt.Errorf("pubkey does not match %s", p.ID)
+		}
+		return nil
+	}
+	if p.PubKey == "" && !did.Resolvable(p.ID) {
+		return fmt.Errorf("pubkey is required")
+	}
+	return nil
+}
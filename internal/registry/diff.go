@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/clawinfra/agent-tools/internal/schemadiff"
+)
+
+// SchemaDiff is how the schemas of a tool differ from those of another
+// version it is compared against.
+type SchemaDiff struct {
+	ToolID  string              `json:"tool_id"`
+	Against string              `json:"against"`
+	Input   []schemadiff.Change `json:"input"`
+	Output  []schemadiff.Change `json:"output"`
+	// Breaking is set when a consumer of Against may fail against ToolID.
+	Breaking bool `json:"breaking"`
+}
+
+// DiffSchemas compares the schemas of tool id with those of tool against,
+// as changes made going from against to id. Both must be visible in the
+// context namespace.
+func (r *Registry) DiffSchemas(ctx context.Context, id, against string) (*SchemaDiff, error) {
+	tool, err := r.GetTool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	base, err := r.GetTool(ctx, against)
+	if err != nil {
+		return nil, err
+	}
+	return diffSchemas(base, tool)
+}
+
+func diffSchemas(base, tool *Tool) (*SchemaDiff, error) {
+	in, err := schemadiff.Diff(base.Schema.Input, tool.Schema.Input, schemadiff.Input)
+	if err != nil {
+		return nil, fmt.Errorf("diff input schema: %w", err)
+	}
+	out, err := schemadiff.Diff(base.Schema.Output, tool.Schema.Output, schemadiff.Output)
+	if err != nil {
+		return nil, fmt.Errorf("diff output schema: %w", err)
+	}
+	if in == nil {
+		in = []schemadiff.Change{}
+	}
+	if out == nil {
+		out = []schemadiff.Change{}
+	}
+	return &SchemaDiff{
+		ToolID:   tool.ID,
+		Against:  base.ID,
+		Input:    in,
+		Output:   out,
+		Breaking: schemadiff.Breaking(in) || schemadiff.Breaking(out),
+	}, nil
+}
+
+// Compatibility records how the schemas of a tool version compare with
+// those of the version of the tool registered before it.
+type Compatibility struct {
+	Against  string `json:"against"`
+	Breaking bool   `json:"breaking"`
+}
+
+// checkCompat diffs the schemas of req, to be registered as id, against the
+// latest version of the same tool by the same provider. It returns nil when
+// there is no earlier version.
+func (r *Registry) checkCompat(ctx context.Context, id string, req *RegisterToolRequest) (*Compatibility, error) {
+	prev, err := scanTool(r.db.QueryRowContext(ctx, "SELECT "+toolColumns+` FROM tools
+		WHERE namespace = ? AND provider_id = ? AND name = ?
+		ORDER BY created_at DESC, rowid DESC LIMIT 1
+	`, NamespaceFrom(ctx), req.ProviderID, req.Name))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find previous version: %w", err)
+	}
+	diff, err := diffSchemas(prev, &Tool{ID: id, Schema: req.Schema})
+	if err != nil {
+		return nil, err
+	}
+	return &Compatibility{Against: prev.ID, Breaking: diff.Breaking}, nil
+}
+
+// ToolVersions returns every version of the tool id is a version of, that
+// is every tool with its name and provider, newest first. Deactivated and
+// revoked versions are included.
+func (r *Registry) ToolVersions(ctx context.Context, id string) ([]*Tool, error) {
+	tool, err := r.GetTool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+toolColumns+` FROM tools
+		WHERE namespace = ? AND provider_id = ? AND name = ?
+		ORDER BY created_at DESC, rowid DESC
+	`, tool.Namespace, tool.ProviderID, tool.Name)
+	if err != nil {
+		return nil, fmt.Errorf("list versions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	return scanTools(rows)
+}
@@ -0,0 +1,108 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/schemadiff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTool_CheckCompat(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.CheckCompat = true
+	v1, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Nil(t, v1.Compat, "nothing to compare the first version with")
+
+	req = validRegisterReq()
+	req.Version = "1.1.0"
+	req.CheckCompat = true
+	req.Schema.Output = []byte(`{"type":"object","properties":{"output":{"type":"string"},"extra":{"type":"number"}}}`)
+	v11, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, &registry.Compatibility{Against: v1.ID, Breaking: false}, v11.Compat)
+
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	req.CheckCompat = true
+	req.Schema.Input = []byte(`{"type":"object","properties":{"input":{"type":"integer"}}}`)
+	v2, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, &registry.Compatibility{Against: v11.ID, Breaking: true}, v2.Compat)
+
+	req = validRegisterReq()
+	req.Version = "2.0.1"
+	v201, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Nil(t, v201.Compat, "not checked unless asked")
+
+	versions, err := r.ToolVersions(ctx, v1.ID)
+	require.NoError(t, err)
+	var ids []string
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+	}
+	assert.Equal(t, []string{v201.ID, v2.ID, v11.ID, v1.ID}, ids)
+	assert.True(t, versions[1].Compat.Breaking)
+}
+
+func TestToolVersions_OtherTools(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	other := validRegisterReq()
+	other.Name = "other-tool"
+	_, err = r.RegisterTool(ctx, other)
+	require.NoError(t, err)
+	other = validRegisterReq()
+	other.ProviderID = "did:claw:agent:someone-else"
+	_, err = r.RegisterTool(ctx, other)
+	require.NoError(t, err)
+
+	versions, err := r.ToolVersions(ctx, tool.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, tool.ID, versions[0].ID)
+
+	_, err = r.ToolVersions(ctx, "did:claw:tool:missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestDiffSchemas(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	v1, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	req := validRegisterReq()
+	req.Version = "2.0.0"
+	req.Schema.Input = []byte(`{"type":"object","properties":{"input":{"type":"integer"}}}`)
+	v2, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+
+	diff, err := r.DiffSchemas(ctx, v2.ID, v1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, v2.ID, diff.ToolID)
+	assert.Equal(t, v1.ID, diff.Against)
+	assert.True(t, diff.Breaking)
+	assert.NotEmpty(t, diff.Input)
+	assert.Equal(t, []schemadiff.Change{}, diff.Output)
+
+	same, err := r.DiffSchemas(ctx, v1.ID, v1.ID)
+	require.NoError(t, err)
+	assert.False(t, same.Breaking)
+	assert.Empty(t, same.Input)
+
+	_, err = r.DiffSchemas(ctx, "did:claw:tool:missing", v1.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	_, err = r.DiffSchemas(ctx, v1.ID, "did:claw:tool:missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/jsonschema"
+)
+
+var (
+	// ErrInvalidInput is returned for an invocation input that does not
+	// conform to the tool's input schema.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrBudgetExceeded is returned when an invocation would cost more than
+	// the consumer's budget.
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// ErrInvalidBudget is returned for a budget that is not a non-negative
+	// decimal.
+	ErrInvalidBudget = errors.New("invalid budget")
+)
+
+// DryRunResponse is what an invocation would do, returned instead of
+// running it when InvokeRequest.DryRun is set.
+type DryRunResponse struct {
+	DryRun  bool     `json:"dry_run"`
+	ToolID  string   `json:"tool_id"`
+	Pricing *Pricing `json:"pricing,omitempty"`
+	// CostCLAW is what the invocation would be charged: the tool's
+	// per-call price, or its cache price when Cached. It is empty for free
+	// tools and for prices only known after the call, such as per-token.
+	CostCLAW string `json:"cost_claw,omitempty"`
+	// Cached is set when the output would be served from the result cache;
+	// CacheHit repeats it.
+	Cached    bool  `json:"cached,omitempty"`
+	CacheHit  bool  `json:"cache_hit,omitempty"`
+	TimeoutMS int64 `json:"timeout_ms"`
+	// QuotaRemaining is how many calls the consumer has left of the tool's
+	// quota, this one included, until QuotaResetAt. Both are absent for
+	// tools without a quota.
+	QuotaRemaining *int64     `json:"quota_remaining,omitempty"`
+	QuotaResetAt   *time.Time `json:"quota_reset_at,omitempty"`
+}
+
+// DryRun checks an invocation of tool by consumerID with the encoded input
+// and budget as far as it can without calling the provider, recording
+// anything or counting it against a quota: input limits and schema,
+// payload storage (see WithPayloadStorage), the cost and the quota. It
+// returns ErrLimitExceeded, ErrInvalidInput, ErrPayloadStorage,
+// ErrInvalidBudget, ErrBudgetExceeded or ErrQuotaExceeded for an
+// invocation that would fail; with ErrQuotaExceeded the response says when
+// the quota resets.
+func (r *Registry) DryRun(
+	ctx context.Context, tool *Tool, consumerID string, input []byte, budget string,
+) (*DryRunResponse, error) {
+	resp := &DryRunResponse{DryRun: true, ToolID: tool.ID, Pricing: tool.Pricing, TimeoutMS: tool.TimeoutMS}
+	if err := r.limits.CheckInput(input); err != nil {
+		return nil, err
+	}
+	var v any
+	if err := json.Unmarshal(input, &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if err := jsonschema.Validate(tool.Schema.Input, v); err != nil {
+		var verr *jsonschema.ValidationError
+		if errors.As(err, &verr) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		// Otherwise the schema itself is not JSON, and constrains nothing.
+	}
+	if payloadOwner(ctx) != "" {
+		if err := r.checkPayloadStorage(input); err != nil {
+			return nil, err
+		}
+	}
+
+	if tool.Pricing != nil && tool.Pricing.Model == PricingPerCall {
+		resp.CostCLAW = tool.Pricing.AmountCLAW
+	}
+	if _, ok := r.CachedResult(ctx, tool, input); ok {
+		resp.Cached, resp.CacheHit, resp.CostCLAW = true, true, tool.CachedPrice()
+	}
+	estimate := resp.CostCLAW
+	if estimate == "" {
+		// The least a per-token invocation costs is its input.
+		estimate = EstimateCost(tool, input)
+	}
+	if err := CheckBudget(estimate, budget); err != nil {
+		return nil, err
+	}
+	// Policies are checked as they would be, without recording a decision.
+	d, err := r.decidePolicies(ctx, tool, consumerID, estimate)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.err(); err != nil {
+		return nil, err
+	}
+
+	left, reset, err := r.QuotaLeft(ctx, tool, consumerID)
+	if err != nil {
+		return nil, err
+	}
+	if left >= 0 {
+		resp.QuotaRemaining, resp.QuotaResetAt = &left, &reset
+		if left == 0 {
+			return resp, fmt.Errorf("%w: %d calls per %s, resets at %s",
+				ErrQuotaExceeded, tool.Quota.Calls, tool.Quota.Period, reset.Format(time.RFC3339))
+		}
+	}
+	return resp, nil
+}
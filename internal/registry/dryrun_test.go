@@ -0,0 +1,50 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Schema.Input = []byte(`{"type": "object", "properties": {"n": {"type": "integer"}}}`)
+	req.Cache = &registry.CachePolicy{Deterministic: true, TTLSeconds: 60, PriceCLAW: "0.5"}
+	req.Quota = &registry.Quota{Calls: 2, Period: registry.QuotaDay}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+
+	resp, err := r.DryRun(ctx, tool, "consumer", []byte(`{"n": 1}`), "")
+	require.NoError(t, err)
+	assert.Equal(t, "5.0", resp.CostCLAW)
+	assert.False(t, resp.Cached)
+	assert.EqualValues(t, 2, *resp.QuotaRemaining)
+
+	r.CacheResult(ctx, tool, []byte(`{"n": 1}`), []byte(`{"n": 2}`))
+	_, err = r.TakeQuota(ctx, tool, "consumer")
+	require.NoError(t, err)
+	resp, err = r.DryRun(ctx, tool, "consumer", []byte(`{"n": 1}`), "1")
+	require.NoError(t, err, "a cache hit fits a budget the tool's price does not")
+	assert.True(t, resp.Cached)
+	assert.Equal(t, "0.5", resp.CostCLAW)
+	assert.EqualValues(t, 1, *resp.QuotaRemaining)
+
+	_, err = r.DryRun(ctx, tool, "consumer", []byte(`{"n": 2}`), "1")
+	assert.ErrorIs(t, err, registry.ErrBudgetExceeded)
+	_, err = r.DryRun(ctx, tool, "consumer", []byte(`{"n": "two"}`), "")
+	assert.ErrorIs(t, err, registry.ErrInvalidInput)
+	_, err = r.DryRun(registry.WithPayloadStorage(ctx, "consumer"), tool, "consumer", []byte(`{}`), "")
+	assert.ErrorIs(t, err, registry.ErrPayloadStorage, "no secrets key")
+
+	_, err = r.TakeQuota(ctx, tool, "consumer")
+	require.NoError(t, err)
+	resp, err = r.DryRun(ctx, tool, "consumer", []byte(`{}`), "")
+	assert.ErrorIs(t, err, registry.ErrQuotaExceeded)
+	require.NotNil(t, resp)
+	assert.NotNil(t, resp.QuotaResetAt)
+}
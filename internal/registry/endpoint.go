@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/clawinfra/agent-tools/internal/netguard"
+)
+
+// ErrInvalidEndpoint is returned for a tool or provider endpoint the
+// registry will not route to.
+var ErrInvalidEndpoint = errors.New("invalid endpoint")
+
+// WithEndpointPolicy restricts the endpoints tools and providers may
+// register. The router dials through the same policy.
+func WithEndpointPolicy(p netguard.Policy) Option {
+	return func(r *Registry) { r.endpoints = p }
+}
+
+// checkEndpoint validates a tool or provider endpoint against the policy.
+func (r *Registry) checkEndpoint(endpoint string) error {
+	if err := r.endpoints.CheckEndpoint(endpoint); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+	if err := checkJSONRPC(endpoint); err != nil {
+		return err
+	}
+	if err := checkPush(endpoint); err != nil {
+		return err
+	}
+	return checkImage(endpoint)
+}
+
+// CheckCallbackURL validates a URL the registry is asked to call back, such
+// as the callback of an async invocation, against the endpoint policy.
+func (r *Registry) CheckCallbackURL(callback string) error {
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%w: %q is not an http or https URL", ErrInvalidEndpoint, callback)
+	}
+	if err := r.endpoints.CheckEndpoint(callback); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+	return nil
+}
+
+// EndpointTransport returns an HTTP transport that dials tool endpoints
+// through the endpoint policy.
+func (r *Registry) EndpointTransport() *http.Transport {
+	return r.endpoints.Transport()
+}
@@ -0,0 +1,37 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/netguard"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEndpointPolicy(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t),
+		registry.WithEndpointPolicy(netguard.Policy{Schemes: []string{"https", "grpc"}, BlockPrivate: true}))
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.Endpoint = "grpc://127.0.0.1:50051"
+	_, err := r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+
+	req = validRegisterReq()
+	req.Endpoint = "http://tools.example.com"
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+
+	req = validRegisterReq()
+	req.Endpoint = "https://tools.example.com/invoke"
+	_, err = r.RegisterTool(ctx, req)
+	assert.NoError(t, err)
+
+	_, err = r.RegisterProvider(ctx, &registry.Provider{
+		ID: "did:claw:agent:meta", Endpoint: "https://169.254.169.254", PubKey: "ed25519:00",
+	})
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+}
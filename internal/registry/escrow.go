@@ -0,0 +1,534 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNoWallet is returned by the wallet methods of a registry that does
+	// not settle payments on chain.
+	ErrNoWallet = errors.New("payments are not settled on chain")
+	// ErrInsufficientFunds is returned when a consumer's escrow account
+	// cannot cover an invocation or withdrawal besides what it holds for
+	// invocations not settled yet.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrInvalidAmount is returned for a withdrawal that is not a positive
+	// decimal amount of CLAW.
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrNoAccount is returned for a DID without a key whose account could
+	// receive CLAW.
+	ErrNoAccount = errors.New("no account")
+	// ErrChain is returned when the chain could not be read or written.
+	ErrChain = errors.New("chain unavailable")
+)
+
+// Escrow statuses. An escrow is held from the start of its invocation until
+// SettleEscrows releases its cost to the provider, releasing until a block
+// confirms the transfer, or refunds it. One that could not be released in
+// maxSettleAttempts runs is failed: its hold is dropped and the provider is
+// not paid.
+const (
+	EscrowHeld      = "held"
+	EscrowReleasing = "releasing"
+	EscrowReleased  = "released"
+	EscrowRefunded  = "refunded"
+	EscrowFailed    = "failed"
+)
+
+// Withdrawal statuses. A withdrawal is reserved in the escrow account,
+// pending, until a block confirms its transfer.
+const (
+	withdrawalPending = "pending"
+	withdrawalSent    = "sent"
+	withdrawalFailed  = "failed"
+)
+
+// maxSettleBatch bounds how many escrows one run of SettleEscrows settles;
+// more wait for the next run.
+const maxSettleBatch = 1000
+
+// maxSettleAttempts is how many runs of SettleEscrows try to release an
+// escrow before failing it, so that a provider without an account or a
+// transfer the chain keeps refusing does not hold the consumer's CLAW
+// forever.
+const maxSettleAttempts = 10
+
+// Wallet holds and moves CLAW on a chain. Accounts are Ed25519 keys.
+type Wallet interface {
+	// Address returns the address CLAW is sent to account at.
+	Address(account ed25519.PublicKey) string
+	// Balance returns the CLAW account holds, a decimal string.
+	Balance(ctx context.Context, account ed25519.PublicKey) (string, error)
+	// Transfer sends amount CLAW, a decimal string, from the account of
+	// from to to and returns the hash of the transaction once a block
+	// confirms it. On error, the hash is empty when nothing was sent and
+	// set when the transaction was submitted but its outcome is unknown.
+	Transfer(ctx context.Context, from ed25519.PrivateKey, to ed25519.PublicKey, amount string) (string, error)
+	// Reserve returns the CLAW an account that makes transfers must keep,
+	// decimal strings: the existential deposit, and the fee of each
+	// transfer on top of its amount.
+	Reserve() (deposit, fee string)
+}
+
+// WithWallet settles paid invocations in CLAW with w. Each consumer pays
+// from an escrow account of its own, whose key the registry derives from
+// seed and the consumer's DID, and which the consumer funds by sending CLAW
+// to its address. An invocation holds its estimated cost in the account;
+// SettleEscrows transfers the cost to the tool's provider once the
+// invocation completes, or drops the hold when it fails, and Withdraw
+// returns what is not held to the consumer.
+func WithWallet(w Wallet, seed []byte) Option {
+	return func(r *Registry) { r.wallet, r.escrowSeed = w, seed }
+}
+
+// SettlesOnChain reports whether paid invocations are settled in CLAW, from
+// the escrow accounts of their consumers.
+func (r *Registry) SettlesOnChain() bool {
+	return r.wallet != nil
+}
+
+// Escrow is the cost of an invocation held in its consumer's escrow
+// account.
+type Escrow struct {
+	ID           string `json:"id"`
+	InvocationID string `json:"invocation_id"`
+	ConsumerID   string `json:"consumer_id"`
+	AmountCLAW   string `json:"amount_claw"`
+	Status       string `json:"status"`
+	// SettledCLAW is what was released to the provider, the invocation's
+	// cost, and TxHash the hash of the transfer.
+	SettledCLAW string     `json:"settled_claw,omitempty"`
+	TxHash      string     `json:"tx_hash,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+}
+
+// WalletInfo is a consumer's escrow account and, when its DID has a key,
+// its own account, which withdrawals are sent to.
+type WalletInfo struct {
+	ConsumerID string `json:"consumer_id"`
+	// EscrowAddress is where the consumer sends the CLAW it pays for
+	// invocations with, and EscrowCLAW what the account holds. HeldCLAW of
+	// it is held for invocations not settled yet and withdrawals being
+	// sent, with the fees of their transfers; AvailableCLAW is what one
+	// more transfer can send of the rest, less its fee and the existential
+	// deposit.
+	EscrowAddress string `json:"escrow_address"`
+	EscrowCLAW    string `json:"escrow_claw"`
+	HeldCLAW      string `json:"held_claw"`
+	AvailableCLAW string `json:"available_claw"`
+	Address       string `json:"address,omitempty"`
+	BalanceCLAW   string `json:"balance_claw,omitempty"`
+}
+
+// Withdrawal is a transfer of CLAW from a consumer's escrow account to its
+// own.
+type Withdrawal struct {
+	ID         string `json:"id"`
+	ConsumerID string `json:"consumer_id"`
+	AmountCLAW string `json:"amount_claw"`
+	Address    string `json:"address"`
+	TxHash     string `json:"tx_hash"`
+}
+
+// escrowKey returns the key of the escrow account of consumerID: the
+// Ed25519 key whose seed is the HMAC-SHA256 of the DID under the escrow
+// seed, so the registry can sign for every consumer while keeping a single
+// secret.
+func (r *Registry) escrowKey(consumerID string) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, r.escrowSeed)
+	mac.Write([]byte(consumerID))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil))
+}
+
+// accountOf returns the account CLAW is sent to id at: that of the pubkey
+// the provider registered, or else of the first key its did:key or did:web
+// resolves to.
+func (r *Registry) accountOf(ctx context.Context, id string) (ed25519.PublicKey, error) {
+	p, err := r.GetProvider(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if p != nil && p.PubKey != "" {
+		return parsePubKey(p.PubKey)
+	}
+	if did.Resolvable(id) {
+		keys, err := r.dids.Keys(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			return keys[0], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s has no key", ErrNoAccount, id)
+}
+
+// Wallet returns the wallet of consumerID.
+func (r *Registry) Wallet(ctx context.Context, consumerID string) (*WalletInfo, error) {
+	if r.wallet == nil {
+		return nil, ErrNoWallet
+	}
+	balance, err := r.escrowBalance(ctx, consumerID)
+	if err != nil {
+		return nil, err
+	}
+	available, held, err := r.availableCLAW(ctx, r.db, consumerID, balance)
+	if err != nil {
+		return nil, err
+	}
+	if available.Sign() < 0 {
+		available.SetInt64(0)
+	}
+	info := &WalletInfo{
+		ConsumerID:    consumerID,
+		EscrowAddress: r.wallet.Address(r.escrowKey(consumerID).Public().(ed25519.PublicKey)),
+		EscrowCLAW:    ratCLAW(balance),
+		HeldCLAW:      ratCLAW(held),
+		AvailableCLAW: ratCLAW(available),
+	}
+	own, err := r.accountOf(ctx, consumerID)
+	if errors.Is(err, ErrNoAccount) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Address = r.wallet.Address(own)
+	if info.BalanceCLAW, err = r.wallet.Balance(ctx, own); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChain, err)
+	}
+	return info, nil
+}
+
+// escrowBalance returns what the escrow account of consumerID holds.
+func (r *Registry) escrowBalance(ctx context.Context, consumerID string) (*big.Rat, error) {
+	s, err := r.wallet.Balance(ctx, r.escrowKey(consumerID).Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChain, err)
+	}
+	balance, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: balance %q is not a decimal", ErrChain, s)
+	}
+	return balance, nil
+}
+
+// queryer is a database or a transaction on it.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// availableCLAW returns what the escrow account of consumerID, whose
+// balance is balance, can send in one more transfer, and what it holds: the
+// balance less the holds and the fees of their transfers, the fee of this
+// one and the existential deposit the account must keep.
+func (r *Registry) availableCLAW(ctx context.Context, q queryer, consumerID string, balance *big.Rat) (*big.Rat, *big.Rat, error) {
+	d, f := r.wallet.Reserve()
+	deposit, fee := decimalCLAW(d), decimalCLAW(f)
+	held, err := heldCLAW(ctx, q, consumerID, fee)
+	if err != nil {
+		return nil, nil, err
+	}
+	available := new(big.Rat).Sub(balance, held)
+	return available.Sub(available, deposit).Sub(available, fee), held, nil
+}
+
+// decimalCLAW parses a decimal amount of CLAW, or returns zero.
+func decimalCLAW(amount string) *big.Rat {
+	if a, ok := new(big.Rat).SetString(amount); ok {
+		return a
+	}
+	return new(big.Rat)
+}
+
+// heldCLAW returns how much the escrow account of consumerID holds for
+// invocations not settled yet and withdrawals being sent, with fee for the
+// transfer of each.
+func heldCLAW(ctx context.Context, q queryer, consumerID string, fee *big.Rat) (*big.Rat, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT amount_claw FROM escrows WHERE consumer_id = ? AND status IN (?, ?)
+		UNION ALL
+		SELECT amount_claw FROM withdrawals WHERE consumer_id = ? AND status = ?
+	`, consumerID, EscrowHeld, EscrowReleasing, consumerID, withdrawalPending)
+	if err != nil {
+		return nil, fmt.Errorf("held escrows: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	held := new(big.Rat)
+	for rows.Next() {
+		var amount string
+		if err := rows.Scan(&amount); err != nil {
+			return nil, fmt.Errorf("scan escrow: %w", err)
+		}
+		held.Add(held, decimalCLAW(amount)).Add(held, fee)
+	}
+	return held, rows.Err()
+}
+
+// HoldEscrow holds amount CLAW, the estimated cost of invocation
+// invocationID, in the escrow account of consumerID, or returns
+// ErrInsufficientFunds when the account cannot cover it besides what it
+// holds already. It does nothing without a wallet or for free invocations.
+func (r *Registry) HoldEscrow(ctx context.Context, invocationID, consumerID, amount string) error {
+	want, ok := new(big.Rat).SetString(amount)
+	if r.wallet == nil || !ok || want.Sign() <= 0 {
+		return nil
+	}
+	// The chain is read before the transaction, so it is not waited on
+	// while writes are held up; the holds are summed inside it, so that
+	// concurrent invocations cannot hold the same funds.
+	balance, err := r.escrowBalance(ctx, consumerID)
+	if err != nil {
+		return err
+	}
+	return r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		available, _, err := r.availableCLAW(ctx, tx, consumerID, balance)
+		if err != nil {
+			return err
+		}
+		if available.Cmp(want) < 0 {
+			return fmt.Errorf("%w: the invocation costs %s CLAW, the escrow account has %s available", ErrInsufficientFunds,
+				amount, ratCLAW(available))
+		}
+		id := "escrow_" + uuid.NewString()
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO escrows (id, invocation_id, consumer_id, amount_claw, status, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		`, id, invocationID, consumerID, amount, EscrowHeld, time.Now().Unix()); err != nil {
+			return fmt.Errorf("hold escrow: %w", err)
+		}
+		// The reaper reports the escrow of an invocation it refunds.
+		_, err = tx.ExecContext(ctx, `UPDATE invocations SET escrow_id = ? WHERE id = ?`, id, invocationID)
+		return err
+	})
+}
+
+// settling is a held escrow whose invocation has ended.
+type settling struct {
+	Escrow
+	status     string
+	costCLAW   string
+	namespace  string
+	providerID string
+	attempts   int
+}
+
+// SettleEscrows settles the escrows of the invocations that ended since its
+// last run, oldest first and up to maxSettleBatch of them: the cost of a
+// completed invocation is transferred from its consumer's escrow account to
+// the tool's provider, and the hold of one that failed, or cost nothing, is
+// refunded. An escrow that cannot be released stays held for the next run,
+// up to maxSettleAttempts runs, and is then failed; one whose transfer was
+// submitted but not confirmed in a block, or that the registry stopped in
+// the middle of releasing, is left releasing, for an operator to check on
+// chain, rather than risk paying twice. It does nothing without a wallet,
+// and is intended to be run periodically by the serve command.
+func (r *Registry) SettleEscrows(ctx context.Context) error {
+	if r.wallet == nil {
+		return nil
+	}
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	ended, err := r.endedEscrows(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range ended {
+		ctx := WithNamespace(ctx, e.namespace)
+		if err := r.settle(ctx, &e); err != nil {
+			r.logger(ctx).Warn("settle escrow", zap.String("escrow_id", e.ID), zap.String("invocation_id", e.InvocationID),
+				zap.Error(err))
+			errs = append(errs, fmt.Errorf("settle escrow %s: %w", e.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// endedEscrows returns the held escrows of invocations no longer queued or
+// pending.
+func (r *Registry) endedEscrows(ctx context.Context) ([]settling, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT e.id, e.invocation_id, e.consumer_id, e.amount_claw, e.created_at, i.status, COALESCE(i.cost_claw, ''),
+			i.namespace, t.provider_id, e.attempts
+		FROM escrows e JOIN invocations i ON i.id = e.invocation_id JOIN tools t ON t.id = i.tool_id
+		WHERE e.status = ? AND i.status NOT IN ('queued', 'pending')
+		ORDER BY e.created_at, e.rowid LIMIT ?
+	`, EscrowHeld, maxSettleBatch)
+	if err != nil {
+		return nil, fmt.Errorf("ended escrows: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var ended []settling
+	for rows.Next() {
+		var (
+			e         settling
+			createdAt int64
+		)
+		err := rows.Scan(&e.ID, &e.InvocationID, &e.ConsumerID, &e.AmountCLAW, &createdAt, &e.status, &e.costCLAW,
+			&e.namespace, &e.providerID, &e.attempts)
+		if err != nil {
+			return nil, fmt.Errorf("scan escrow: %w", err)
+		}
+		e.Status, e.CreatedAt = EscrowHeld, time.Unix(createdAt, 0).UTC()
+		ended = append(ended, e)
+	}
+	return ended, rows.Err()
+}
+
+// settle releases or refunds the escrow e.
+func (r *Registry) settle(ctx context.Context, e *settling) error {
+	cost, ok := new(big.Rat).SetString(e.costCLAW)
+	if e.status != "completed" || !ok || cost.Sign() <= 0 {
+		_, err := r.db.ExecContext(ctx, `UPDATE escrows SET status = ?, settled_at = ? WHERE id = ? AND status = ?`,
+			EscrowRefunded, time.Now().Unix(), e.ID, EscrowHeld)
+		return err
+	}
+	to, err := r.accountOf(ctx, e.providerID)
+	if err != nil {
+		return r.retrySettle(ctx, e, err)
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE escrows SET status = ? WHERE id = ? AND status = ?`,
+		EscrowReleasing, e.ID, EscrowHeld); err != nil {
+		return err
+	}
+	tx, err := r.wallet.Transfer(ctx, r.escrowKey(e.ConsumerID), to, e.costCLAW)
+	if err != nil && tx != "" {
+		// The transfer may still be carried out, so the escrow stays
+		// releasing, and held, for an operator to check on chain.
+		cause := fmt.Errorf("%w: transfer %s not confirmed, escrow left releasing: %v", ErrChain, tx, err)
+		if _, err := r.db.ExecContext(ctx, `UPDATE escrows SET tx_hash = ? WHERE id = ?`, tx, e.ID); err != nil {
+			return errors.Join(cause, err)
+		}
+		return cause
+	}
+	if err != nil {
+		// Nothing was sent, so the escrow can be tried again.
+		return r.retrySettle(ctx, e, fmt.Errorf("%w: %v", ErrChain, err))
+	}
+	now := time.Now().UTC()
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE escrows SET status = ?, settled_claw = ?, tx_hash = ?, settled_at = ? WHERE id = ?
+	`, EscrowReleased, e.costCLAW, tx, now.Unix(), e.ID); err != nil {
+		return fmt.Errorf("record release in %s: %w", tx, err)
+	}
+	released := e.Escrow
+	released.Status, released.SettledCLAW, released.TxHash, released.SettledAt = EscrowReleased, e.costCLAW, tx, &now
+	r.publish(ctx, events.EscrowReleased, e.InvocationID, e.ConsumerID, &released)
+	return nil
+}
+
+// retrySettle records that releasing escrow e failed with cause: the escrow
+// is held again for the next run of SettleEscrows or, once it has failed
+// maxSettleAttempts times, failed. It returns cause.
+func (r *Registry) retrySettle(ctx context.Context, e *settling, cause error) error {
+	status, settledAt := EscrowHeld, sql.NullInt64{}
+	if e.attempts+1 >= maxSettleAttempts {
+		status, settledAt = EscrowFailed, sql.NullInt64{Int64: time.Now().Unix(), Valid: true}
+		r.logger(ctx).Error("escrow failed; the provider is not paid", zap.String("escrow_id", e.ID),
+			zap.String("invocation_id", e.InvocationID), zap.String("provider_id", e.providerID),
+			zap.String("cost_claw", e.costCLAW), zap.Int("attempts", e.attempts+1), zap.Error(cause))
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE escrows SET status = ?, attempts = attempts + 1, settled_at = ? WHERE id = ?`,
+		status, settledAt, e.ID); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// Withdraw transfers amount CLAW from the escrow account of consumerID to
+// its own account, out of what is not held for invocations. The amount is
+// reserved in the account while the transfer is made, so that invocations
+// cannot hold it meanwhile; a withdrawal whose transfer was submitted but
+// not confirmed in a block, or that the registry stopped in the middle of
+// sending, stays reserved, for an operator to check on chain.
+func (r *Registry) Withdraw(ctx context.Context, consumerID, amount string) (*Withdrawal, error) {
+	if r.wallet == nil {
+		return nil, ErrNoWallet
+	}
+	want, ok := new(big.Rat).SetString(amount)
+	if !ok || want.Sign() <= 0 || strings.ContainsAny(amount, "/eE") {
+		return nil, fmt.Errorf("%w: amount_claw must be a positive decimal", ErrInvalidAmount)
+	}
+	to, err := r.accountOf(ctx, consumerID)
+	if err != nil {
+		return nil, err
+	}
+	// As in HoldEscrow, the chain is read before the transaction and the
+	// holds are summed inside it.
+	balance, err := r.escrowBalance(ctx, consumerID)
+	if err != nil {
+		return nil, err
+	}
+	id := "wd_" + uuid.NewString()
+	err = r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		available, _, err := r.availableCLAW(ctx, tx, consumerID, balance)
+		if err != nil {
+			return err
+		}
+		if available.Cmp(want) < 0 {
+			return fmt.Errorf("%w: %s CLAW available in the escrow account", ErrInsufficientFunds, ratCLAW(available))
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO withdrawals (id, consumer_id, amount_claw, status, created_at) VALUES (?, ?, ?, ?, ?)
+		`, id, consumerID, amount, withdrawalPending, time.Now().Unix()); err != nil {
+			return fmt.Errorf("reserve withdrawal: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The outcome is recorded even when the caller has gone away.
+	ctx = context.WithoutCancel(ctx)
+	tx, err := r.wallet.Transfer(ctx, r.escrowKey(consumerID), to, amount)
+	if err != nil && tx != "" {
+		// The transfer may still be carried out, so the withdrawal stays
+		// pending, and reserved, for an operator to check on chain.
+		cause := fmt.Errorf("%w: transfer %s not confirmed, withdrawal %s left pending: %v", ErrChain, tx, id, err)
+		if _, err := r.db.ExecContext(ctx, `UPDATE withdrawals SET tx_hash = ? WHERE id = ?`, tx, id); err != nil {
+			return nil, errors.Join(cause, err)
+		}
+		return nil, cause
+	}
+	if err != nil {
+		// Nothing was sent, so the reservation is dropped.
+		if _, rerr := r.db.ExecContext(ctx, `UPDATE withdrawals SET status = ? WHERE id = ?`, withdrawalFailed, id); rerr != nil {
+			return nil, errors.Join(fmt.Errorf("%w: %v", ErrChain, err), rerr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrChain, err)
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE withdrawals SET status = ?, tx_hash = ? WHERE id = ?`,
+		withdrawalSent, tx, id); err != nil {
+		return nil, fmt.Errorf("record withdrawal in %s: %w", tx, err)
+	}
+	r.logger(ctx).Info("escrow withdrawn", zap.String("consumer_id", consumerID), zap.String("amount_claw", amount),
+		zap.String("tx_hash", tx))
+	return &Withdrawal{ID: id, ConsumerID: consumerID, AmountCLAW: amount, Address: r.wallet.Address(to), TxHash: tx}, nil
+}
+
+// ratCLAW formats an amount of CLAW with up to nine decimals, like the
+// costs of invocations.
+func ratCLAW(amount *big.Rat) string {
+	s := amount.FloatString(9)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
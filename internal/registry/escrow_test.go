@@ -0,0 +1,316 @@
+package registry_test
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeWallet keeps balances by address and moves them on transfer, failing
+// while err is set and submitting transfers no block confirms while
+// unconfirmed is. onTransfer, if set, runs as each transfer starts. Accounts
+// keep deposit and pay fee for each transfer, "0" when empty.
+type fakeWallet struct {
+	balances     map[string]*big.Rat
+	transfers    []string
+	err          error
+	unconfirmed  bool
+	onTransfer   func()
+	deposit, fee string
+}
+
+func (w *fakeWallet) Address(account ed25519.PublicKey) string {
+	return hex.EncodeToString(account)
+}
+
+func (w *fakeWallet) Balance(_ context.Context, account ed25519.PublicKey) (string, error) {
+	if b := w.balances[w.Address(account)]; b != nil {
+		return b.RatString(), nil
+	}
+	return "0", nil
+}
+
+func (w *fakeWallet) Transfer(_ context.Context, from ed25519.PrivateKey, to ed25519.PublicKey, amount string) (string, error) {
+	if w.onTransfer != nil {
+		w.onTransfer()
+	}
+	if w.err != nil {
+		return "", w.err
+	}
+	if w.unconfirmed {
+		return "0xunconfirmed", errors.New("no block included the transfer in time")
+	}
+	a, _ := new(big.Rat).SetString(amount)
+	src, dst := w.Address(from.Public().(ed25519.PublicKey)), w.Address(to)
+	if w.balances[dst] == nil {
+		w.balances[dst] = new(big.Rat)
+	}
+	w.balances[src].Sub(w.balances[src], a)
+	w.balances[dst].Add(w.balances[dst], a)
+	w.transfers = append(w.transfers, fmt.Sprintf("%s %s->%s", amount, src, dst))
+	return fmt.Sprintf("0xtx%d", len(w.transfers)), nil
+}
+
+func (w *fakeWallet) Reserve() (deposit, fee string) {
+	return cmp.Or(w.deposit, "0"), cmp.Or(w.fee, "0")
+}
+
+// newWalletRegistry returns a registry settling payments with a fake wallet,
+// a paid tool of a provider with a key and a did:key consumer whose escrow
+// account holds fund CLAW.
+func newWalletRegistry(t *testing.T, fund string) (*registry.Registry, *fakeWallet, *registry.Tool, string) {
+	t.Helper()
+	ctx := context.Background()
+	w := &fakeWallet{balances: map[string]*big.Rat{}}
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithWallet(w, []byte("escrow seed")))
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = r.RegisterProvider(ctx, &registry.Provider{
+		ID: "did:claw:agent:test-provider", Endpoint: "grpc://localhost:50051", PubKey: "ed25519:" + hex.EncodeToString(pub),
+	})
+	require.NoError(t, err)
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	consumerPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	consumer := did.DIDKey(consumerPub)
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	w.balances[info.EscrowAddress], _ = new(big.Rat).SetString(fund)
+	return r, w, tool, consumer
+}
+
+func TestHoldEscrow(t *testing.T) {
+	ctx := context.Background()
+	r, _, tool, consumer := newWalletRegistry(t, "8")
+
+	first, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.HoldEscrow(ctx, first, consumer, "5"))
+	second, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	err = r.HoldEscrow(ctx, second, consumer, "5")
+	assert.ErrorIs(t, err, registry.ErrInsufficientFunds, "5 of the 8 CLAW are held already")
+	require.NoError(t, r.HoldEscrow(ctx, second, consumer, "0"), "free invocations hold nothing")
+
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "8", info.EscrowCLAW)
+	assert.Equal(t, "5", info.HeldCLAW)
+	assert.Equal(t, "3", info.AvailableCLAW)
+	assert.Equal(t, "0", info.BalanceCLAW, "the did:key's own account")
+	assert.NotEmpty(t, info.Address)
+}
+
+func TestHoldEscrow_KeepsDepositAndFees(t *testing.T) {
+	ctx := context.Background()
+	r, w, tool, consumer := newWalletRegistry(t, "10")
+	w.deposit, w.fee = "1", "0.5"
+
+	first, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	err = r.HoldEscrow(ctx, first, consumer, "8.6")
+	assert.ErrorIs(t, err, registry.ErrInsufficientFunds, "the deposit and the fee of the release are kept")
+	require.NoError(t, r.HoldEscrow(ctx, first, consumer, "5"))
+
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5.5", info.HeldCLAW, "the hold and the fee of its release")
+	assert.Equal(t, "3", info.AvailableCLAW, "less the deposit and the fee of one more transfer")
+	_, err = r.Withdraw(ctx, consumer, "3.5")
+	assert.ErrorIs(t, err, registry.ErrInsufficientFunds)
+
+	w.fee = "5"
+	info, err = r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "0", info.AvailableCLAW, "never less than nothing")
+}
+
+func TestSettleEscrows(t *testing.T) {
+	ctx := context.Background()
+	r, w, tool, consumer := newWalletRegistry(t, "10")
+	hold := func() string {
+		id, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+		require.NoError(t, err)
+		require.NoError(t, r.HoldEscrow(ctx, id, consumer, "5"))
+		return id
+	}
+	completed, failed := hold(), hold()
+	require.NoError(t, r.CompleteInvocation(ctx, completed, "h", "", "4"))
+	require.NoError(t, r.FailInvocation(ctx, failed, "boom"))
+
+	w.err = errors.New("node down")
+	err := r.SettleEscrows(ctx)
+	assert.ErrorIs(t, err, registry.ErrChain)
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5", info.HeldCLAW, "the failed invocation is refunded, the completed one held for the next run")
+
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+	w.err = nil
+	require.NoError(t, r.SettleEscrows(ctx))
+	require.Len(t, w.transfers, 1)
+	info, err = r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "6", info.EscrowCLAW, "the cost, not the estimate, is released")
+	assert.Equal(t, "0", info.HeldCLAW)
+	var released *registry.Escrow
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.EscrowReleased {
+			require.NoError(t, json.Unmarshal(ev.Data, &released))
+			assert.Equal(t, consumer, ev.Audience)
+		}
+	}
+	require.NotNil(t, released)
+	assert.Equal(t, completed, released.InvocationID)
+	assert.Equal(t, "4", released.SettledCLAW)
+	assert.Equal(t, "0xtx1", released.TxHash)
+
+	hold()
+	require.NoError(t, r.SettleEscrows(ctx))
+	assert.Len(t, w.transfers, 1, "nothing is settled twice, nor while pending")
+	info, err = r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5", info.HeldCLAW, "the pending invocation's")
+}
+
+func TestWithdraw(t *testing.T) {
+	ctx := context.Background()
+	r, _, tool, consumer := newWalletRegistry(t, "10")
+	id, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.HoldEscrow(ctx, id, consumer, "5"))
+
+	for _, amount := range []string{"", "0", "-1", "1e3", "1/2", "ten"} {
+		_, err := r.Withdraw(ctx, consumer, amount)
+		assert.ErrorIs(t, err, registry.ErrInvalidAmount, amount)
+	}
+	_, err = r.Withdraw(ctx, consumer, "5.5")
+	assert.ErrorIs(t, err, registry.ErrInsufficientFunds, "5 of the 10 CLAW are held")
+	_, err = r.Withdraw(ctx, "did:claw:agent:keyless", "1")
+	assert.ErrorIs(t, err, registry.ErrNoAccount)
+
+	wd, err := r.Withdraw(ctx, consumer, "5")
+	require.NoError(t, err)
+	assert.Equal(t, "0xtx1", wd.TxHash)
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5", info.EscrowCLAW)
+	assert.Equal(t, "0", info.AvailableCLAW)
+	assert.Equal(t, "5", info.BalanceCLAW)
+	assert.Equal(t, info.Address, wd.Address)
+}
+
+func TestSettleEscrows_FailsAfterAttempts(t *testing.T) {
+	ctx := context.Background()
+	r, w, tool, consumer := newWalletRegistry(t, "10")
+	id, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.HoldEscrow(ctx, id, consumer, "5"))
+	require.NoError(t, r.CompleteInvocation(ctx, id, "h", "", "4"))
+
+	w.err = errors.New("insufficient balance")
+	for run := 1; run < 10; run++ {
+		assert.ErrorIs(t, r.SettleEscrows(ctx), registry.ErrChain)
+	}
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5", info.HeldCLAW, "held for the next run")
+
+	assert.ErrorIs(t, r.SettleEscrows(ctx), registry.ErrChain)
+	info, err = r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "0", info.HeldCLAW, "the escrow is failed after 10 runs")
+	w.err = nil
+	require.NoError(t, r.SettleEscrows(ctx))
+	assert.Empty(t, w.transfers, "a failed escrow is not tried again")
+}
+
+func TestWithdraw_ReservesAmount(t *testing.T) {
+	ctx := context.Background()
+	r, w, tool, consumer := newWalletRegistry(t, "10")
+	id, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+
+	w.onTransfer = func() {
+		w.onTransfer = nil
+		info, err := r.Wallet(ctx, consumer)
+		require.NoError(t, err)
+		assert.Equal(t, "8", info.HeldCLAW, "the withdrawal is reserved while it is sent")
+		err = r.HoldEscrow(ctx, id, consumer, "5")
+		assert.ErrorIs(t, err, registry.ErrInsufficientFunds, "an invocation cannot hold the CLAW being withdrawn")
+	}
+	_, err = r.Withdraw(ctx, consumer, "8")
+	require.NoError(t, err)
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "2", info.EscrowCLAW)
+	assert.Equal(t, "0", info.HeldCLAW)
+
+	w.err = errors.New("node down")
+	_, err = r.Withdraw(ctx, consumer, "2")
+	assert.ErrorIs(t, err, registry.ErrChain)
+	info, err = r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "2", info.AvailableCLAW, "a withdrawal that was not sent reserves nothing")
+}
+
+func TestSettleEscrows_Unconfirmed(t *testing.T) {
+	ctx := context.Background()
+	r, w, tool, consumer := newWalletRegistry(t, "10")
+	id, err := r.RecordInvocation(ctx, tool.ID, consumer, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.HoldEscrow(ctx, id, consumer, "5"))
+	require.NoError(t, r.CompleteInvocation(ctx, id, "h", "", "4"))
+
+	w.unconfirmed = true
+	assert.ErrorIs(t, r.SettleEscrows(ctx), registry.ErrChain)
+	w.unconfirmed = false
+	for run := 0; run < 10; run++ {
+		require.NoError(t, r.SettleEscrows(ctx))
+	}
+	assert.Empty(t, w.transfers, "a transfer that may yet be carried out is not made again")
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "5", info.HeldCLAW, "the escrow stays releasing, and held")
+}
+
+func TestWithdraw_Unconfirmed(t *testing.T) {
+	ctx := context.Background()
+	r, w, _, consumer := newWalletRegistry(t, "10")
+
+	w.unconfirmed = true
+	_, err := r.Withdraw(ctx, consumer, "4")
+	require.ErrorIs(t, err, registry.ErrChain)
+	assert.ErrorContains(t, err, "0xunconfirmed")
+	info, err := r.Wallet(ctx, consumer)
+	require.NoError(t, err)
+	assert.Equal(t, "4", info.HeldCLAW, "the withdrawal stays pending, and reserved")
+	assert.Equal(t, "6", info.AvailableCLAW)
+}
+
+func TestWallet_NotConfigured(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	_, err := r.Wallet(ctx, "did:claw:agent:c")
+	assert.ErrorIs(t, err, registry.ErrNoWallet)
+	_, err = r.Withdraw(ctx, "did:claw:agent:c", "1")
+	assert.ErrorIs(t, err, registry.ErrNoWallet)
+	assert.NoError(t, r.HoldEscrow(ctx, "inv", "did:claw:agent:c", "5"), "invocations are not held")
+	assert.NoError(t, r.SettleEscrows(ctx))
+}
@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"go.uber.org/zap"
+)
+
+// WithEventSource sets the CloudEvents source of registry events, e.g. the
+// registry's public URL. It defaults to events.DefaultSource.
+func WithEventSource(source string) Option {
+	return func(r *Registry) { r.events = events.NewBus(source) }
+}
+
+// Events returns the bus registry events are published on.
+func (r *Registry) Events() *events.Bus {
+	return r.events
+}
+
+// publish emits an event in the context namespace. Only audience may see
+// it when set.
+func (r *Registry) publish(ctx context.Context, typ, subject, audience string, data any) {
+	if err := r.events.Publish(typ, subject, NamespaceFrom(ctx), audience, data); err != nil {
+		r.logger(ctx).Warn("publish event", zap.String("type", typ), zap.Error(err))
+	}
+}
+
+// publishInvocation emits the current record of invocation id to its
+// consumer.
+func (r *Registry) publishInvocation(ctx context.Context, typ, id string) {
+	if !r.events.Active() {
+		return
+	}
+	inv, err := r.GetInvocation(ctx, id)
+	if err != nil {
+		r.logger(ctx).Warn("publish event", zap.String("type", typ), zap.Error(err))
+		return
+	}
+	r.publish(ctx, typ, id, inv.ConsumerID, inv)
+}
@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Routing policies of a tool with several endpoints.
+const (
+	// RoutingFailover sends every invocation to the primary endpoint and
+	// moves down the list of endpoints when one is unreachable or failing.
+	RoutingFailover = "failover"
+	// RoutingRoundRobin spreads invocations over all endpoints in turn,
+	// failing over to the others like RoutingFailover.
+	RoutingRoundRobin = "round_robin"
+)
+
+// maxEndpoints bounds the endpoints of one tool, the primary included.
+const maxEndpoints = 8
+
+// AllEndpoints returns the endpoints serving the tool, the primary first.
+func (t *Tool) AllEndpoints() []string {
+	return append([]string{t.Endpoint}, t.Endpoints...)
+}
+
+// validateEndpoints checks the fallback endpoints and routing policy of a
+// registration. Only tools proxied over HTTP can have several endpoints.
+func (r *RegisterToolRequest) validateEndpoints() error {
+	switch r.Routing {
+	case "", RoutingFailover, RoutingRoundRobin:
+	default:
+		return fmt.Errorf("%w: routing must be %s or %s", ErrInvalidEndpoint, RoutingFailover, RoutingRoundRobin)
+	}
+	if len(r.Endpoints) == 0 {
+		if r.Routing != "" {
+			return fmt.Errorf("%w: routing needs endpoints", ErrInvalidEndpoint)
+		}
+		return nil
+	}
+	if r.Routing == "" {
+		r.Routing = RoutingFailover
+	}
+	all := append([]string{r.Endpoint}, r.Endpoints...)
+	if len(all) > maxEndpoints {
+		return fmt.Errorf("%w: a tool may have at most %d endpoints", ErrInvalidEndpoint, maxEndpoints)
+	}
+	for i, e := range all {
+		if !strings.HasPrefix(e, "http://") && !strings.HasPrefix(e, "https://") {
+			return fmt.Errorf("%w: only http and https tools may have several endpoints, not %q", ErrInvalidEndpoint, e)
+		}
+		if slices.Contains(all[:i], e) {
+			return fmt.Errorf("%w: endpoint %q is listed twice", ErrInvalidEndpoint, e)
+		}
+	}
+	return nil
+}
+
+// encodeEndpoints returns the stored form of the fallback endpoints, "" for
+// none.
+func encodeEndpoints(endpoints []string) (string, error) {
+	if len(endpoints) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(endpoints)
+	if err != nil {
+		return "", fmt.Errorf("marshal endpoints: %w", err)
+	}
+	return string(b), nil
+}
+
+type servedKey struct{}
+
+// WithServedEndpoint records, on the invocation completed or failed with
+// ctx, the endpoint that served it. It is set for tools with several
+// endpoints, so it can be told which replica answered.
+func WithServedEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, servedKey{}, endpoint)
+}
+
+func servedEndpoint(ctx context.Context) string {
+	e, _ := ctx.Value(servedKey{}).(string)
+	return e
+}
@@ -0,0 +1,66 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTool_Endpoints(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Endpoint = "https://eu.example.com/tool"
+	req.Endpoints = []string{"https://us.example.com/tool", "https://ap.example.com/tool"}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, registry.RoutingFailover, tool.Routing, "the default")
+	assert.Equal(t, []string{"https://eu.example.com/tool", "https://us.example.com/tool", "https://ap.example.com/tool"},
+		tool.AllEndpoints())
+
+	single := validRegisterReq()
+	single.Name, single.Endpoint = "single", "https://example.com/tool"
+	h1, err := registry.ManifestHash(single)
+	require.NoError(t, err)
+	single.Endpoints = []string{"https://backup.example.com"}
+	h2, err := registry.ManifestHash(single)
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h2, "fallbacks are part of the manifest")
+
+	for name, mutate := range map[string]func(*registry.RegisterToolRequest){
+		"routing":   func(req *registry.RegisterToolRequest) { req.Routing = "random" },
+		"no ends":   func(req *registry.RegisterToolRequest) { req.Endpoints, req.Routing = nil, registry.RoutingRoundRobin },
+		"scheme":    func(req *registry.RegisterToolRequest) { req.Endpoints = []string{"grpc://backup.example.com:50051"} },
+		"duplicate": func(req *registry.RegisterToolRequest) { req.Endpoints = []string{req.Endpoint} },
+		"primary":   func(req *registry.RegisterToolRequest) { req.Endpoint = "grpc://localhost:50051" },
+		"too many": func(req *registry.RegisterToolRequest) {
+			req.Endpoints = []string{"https://1.example.com", "https://2.example.com", "https://3.example.com",
+				"https://4.example.com", "https://5.example.com", "https://6.example.com", "https://7.example.com",
+				"https://8.example.com"}
+		},
+	} {
+		req := validRegisterReq()
+		req.Name, req.Endpoint = "bad-"+name, "https://example.com/tool"
+		req.Endpoints = []string{"https://backup.example.com"}
+		mutate(req)
+		_, err := r.RegisterTool(ctx, req)
+		assert.ErrorIs(t, err, registry.ErrInvalidEndpoint, name)
+	}
+}
+
+func TestServedEndpoint(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	id, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{})
+	require.NoError(t, err)
+	served := registry.WithServedEndpoint(ctx, "https://us.example.com/tool")
+	require.NoError(t, r.CompleteInvocation(served, id, "sha256:00", "", ""))
+	inv, err := r.GetInvocation(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://us.example.com/tool", inv.Endpoint)
+}
@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ProviderError is what a provider said about an invocation it failed: its
+// own error code and message and whether trying again may succeed.
+type ProviderError struct {
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Retryable bool   `json:"retryable"`
+	// Status is the HTTP status the provider answered with, for tools
+	// served over HTTP.
+	Status int `json:"status,omitempty"`
+}
+
+type providerErrorKey struct{}
+
+// WithProviderError records, on the invocation failed with ctx, the error
+// the provider failed it with.
+func WithProviderError(ctx context.Context, pe *ProviderError) context.Context {
+	return context.WithValue(ctx, providerErrorKey{}, pe)
+}
+
+// providerErrorColumn returns the provider_error value of the invocation
+// failed with ctx, NULL when the provider gave none.
+func providerErrorColumn(ctx context.Context) (any, error) {
+	pe, _ := ctx.Value(providerErrorKey{}).(*ProviderError)
+	if pe == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(pe)
+	if err != nil {
+		return nil, fmt.Errorf("marshal provider error: %w", err)
+	}
+	return string(b), nil
+}
+
+// decodeProviderError returns the provider error stored as s, nil for none.
+func decodeProviderError(s string) (*ProviderError, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var pe ProviderError
+	if err := json.Unmarshal([]byte(s), &pe); err != nil {
+		return nil, fmt.Errorf("decode provider error: %w", err)
+	}
+	return &pe, nil
+}
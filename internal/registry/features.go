@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Features a tool may declare, so consumers and the invoker know what it
+// supports instead of guessing.
+const (
+	// FeatureStreaming marks a tool served over HTTP that may answer with a
+	// Server-Sent Events stream, whose last event is the output.
+	FeatureStreaming = "streaming"
+	// FeatureBatch marks a JSON-RPC tool whose endpoint accepts JSON-RPC
+	// batches, so calls to it in one POST /v1/invoke/batch are sent as one.
+	FeatureBatch = "batch"
+	// FeatureCacheable and FeatureDeterministic stand for a cache policy:
+	// declaring either gives the tool one, as Deterministic does, and tools
+	// with one always list both.
+	FeatureCacheable     = "cacheable"
+	FeatureDeterministic = "deterministic"
+)
+
+// features are the known features, in canonical order.
+var features = []string{FeatureBatch, FeatureCacheable, FeatureDeterministic, FeatureStreaming}
+
+// ErrInvalidFeatures is returned for a registration declaring an unknown
+// feature or one its endpoint cannot offer.
+var ErrInvalidFeatures = errors.New("invalid features")
+
+// Has reports whether the tool declared feature.
+func (t *Tool) Has(feature string) bool {
+	return slices.Contains(t.Features, feature)
+}
+
+// validateFeatures checks the features of a registration and folds the
+// cacheable and deterministic ones into its cache policy, leaving Features
+// with those kept in the manifest: streaming and batch, sorted.
+func (r *RegisterToolRequest) validateFeatures() error {
+	var declared []string
+	for _, f := range r.Features {
+		f = strings.ToLower(strings.TrimSpace(f))
+		switch {
+		case !slices.Contains(features, f):
+			return fmt.Errorf("%w: unknown feature %q, must be one of %s", ErrInvalidFeatures, f, strings.Join(features, ", "))
+		case f == FeatureDeterministic || f == FeatureCacheable:
+			r.Deterministic = true
+		case f == FeatureBatch:
+			if _, _, ok := JSONRPCEndpoint(r.Endpoint); !ok {
+				return fmt.Errorf("%w: only JSON-RPC tools can take batches", ErrInvalidFeatures)
+			}
+		case f == FeatureStreaming:
+			if !strings.HasPrefix(r.Endpoint, "http://") && !strings.HasPrefix(r.Endpoint, "https://") {
+				return fmt.Errorf("%w: only http and https tools can stream", ErrInvalidFeatures)
+			}
+		}
+		if (f == FeatureBatch || f == FeatureStreaming) && !slices.Contains(declared, f) {
+			declared = append(declared, f)
+		}
+	}
+	slices.Sort(declared)
+	r.Features = declared
+	return nil
+}
+
+// encodeFeatures returns the stored form of declared features, "" for none.
+func encodeFeatures(features []string) (string, error) {
+	if len(features) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(features)
+	if err != nil {
+		return "", fmt.Errorf("marshal features: %w", err)
+	}
+	return string(b), nil
+}
+
+// decodeFeatures returns the features of a tool from their stored form and
+// its cache policy.
+func decodeFeatures(s string, cache *CachePolicy) ([]string, error) {
+	var out []string
+	if s != "" {
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, fmt.Errorf("unmarshal features: %w", err)
+		}
+	}
+	if cache != nil && cache.Deterministic {
+		out = append(out, FeatureCacheable, FeatureDeterministic)
+		slices.Sort(out)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTool_Features(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		endpoint string
+		features []string
+		want     []string
+		err      bool
+	}{
+		"none":            {"https://t.example/a", nil, nil, false},
+		"streaming":       {"https://t.example/b", []string{"Streaming ", "streaming"}, []string{"streaming"}, false},
+		"batch":           {"jsonrpc+https://t.example/rpc#c", []string{"batch"}, []string{"batch"}, false},
+		"deterministic":   {"https://t.example/d", []string{"deterministic"}, []string{"cacheable", "deterministic"}, false},
+		"cacheable":       {"https://t.example/e", []string{"cacheable"}, []string{"cacheable", "deterministic"}, false},
+		"unknown":         {"https://t.example/f", []string{"telepathy"}, nil, true},
+		"batch over http": {"https://t.example/g", []string{"batch"}, nil, true},
+		"streaming rpc":   {"jsonrpc+https://t.example/rpc#h", []string{"streaming"}, nil, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := validRegisterReq()
+			req.Name, req.Endpoint, req.Features = "features-"+name, tc.endpoint, tc.features
+			tool, err := r.RegisterTool(ctx, req)
+			if tc.err {
+				assert.ErrorIs(t, err, registry.ErrInvalidFeatures)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, tool.Features)
+			got, err := r.GetTool(ctx, tool.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Features)
+			assert.Equal(t, tool.Has(registry.FeatureDeterministic), got.Cacheable())
+		})
+	}
+}
+
+func TestManifestHash_Features(t *testing.T) {
+	plain := validRegisterReq()
+	before, err := registry.ManifestHash(plain)
+	require.NoError(t, err)
+
+	req := validRegisterReq()
+	req.Features = []string{}
+	hash, err := registry.ManifestHash(req)
+	require.NoError(t, err)
+	assert.Equal(t, before, hash, "tools without features hash as before")
+
+	req.Endpoint = "https://t.example/x"
+	plainHTTP, err := registry.ManifestHash(req)
+	require.NoError(t, err)
+	req.Features = []string{registry.FeatureStreaming}
+	hash, err = registry.ManifestHash(req)
+	require.NoError(t, err)
+	assert.NotEqual(t, plainHTTP, hash)
+}
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SourceLocal marks search results registered with this registry rather than
+// mirrored from a peer.
+const SourceLocal = "local"
+
+// federatedColumns selects a mirrored tool in the same shape as toolColumns,
+// followed by its origin.
+const federatedColumns = "id, name, version, description, schema_json, pricing, provider_id, endpoint, " +
+	"timeout_ms, tags, created_at, synced_at, 1, '" + DefaultNamespace + "', '', '', " +
+	"'', '', '', '', '', NULL, '', NULL, '', '', '', '', '', '', '', '', '', '', '', 0, '', '', '', origin"
+
+// sourcedRow scans a trailing source column after the tool columns.
+type sourcedRow struct {
+	row    scanner
+	source *string
+}
+
+func (s sourcedRow) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.source)...)
+}
+
+// MirrorPeer replaces the mirrored catalog of the peer registry origin with
+// tools. Mirrors are read-only: they are searchable but never invoked or
+// modified locally.
+func (r *Registry) MirrorPeer(ctx context.Context, origin string, tools []*Tool) error {
+	now := time.Now().Unix()
+	err := r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM federated_tools WHERE origin = ?", origin); err != nil {
+			return err
+		}
+		for _, t := range tools {
+			schemaJSON, err := json.Marshal(t.Schema)
+			if err != nil {
+				return fmt.Errorf("marshal schema: %w", err)
+			}
+			pricing := t.Pricing
+			if pricing == nil {
+				pricing = &Pricing{Model: PricingFree}
+			}
+			pricingJSON, err := json.Marshal(pricing)
+			if err != nil {
+				return fmt.Errorf("marshal pricing: %w", err)
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT OR REPLACE INTO federated_tools
+					(origin, id, name, version, description, schema_json, pricing, provider_id,
+					 endpoint, timeout_ms, tags, created_at, synced_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, origin, t.ID, t.Name, t.Version, t.Description, string(schemaJSON), string(pricingJSON),
+				t.ProviderID, t.Endpoint, t.TimeoutMS, encodeTags(CanonicalTags(t.Tags)), t.CreatedAt.Unix(), now)
+			if err != nil {
+				return fmt.Errorf("mirror tool %s: %w", t.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("mirror peer: %w", err)
+	}
+	return nil
+}
+
+// searchFederated returns up to limit mirrored tools matching query, newest
+// first.
+func (r *Registry) searchFederated(ctx context.Context, query string, limit int) ([]*Tool, error) {
+	var args []any
+	where := ""
+	if query != "" {
+		where = "WHERE rowid IN (SELECT rowid FROM federated_tools_fts WHERE federated_tools_fts MATCH ?)"
+		args = append(args, query+"*")
+	}
+	args = append(args, limit)
+	rows, err := r.db.QueryContext(ctx, "SELECT "+federatedColumns+" FROM federated_tools "+where+
+		" ORDER BY created_at DESC LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("search federated: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tools []*Tool
+	for rows.Next() {
+		var source string
+		t, err := scanTool(sourcedRow{row: rows, source: &source})
+		if err != nil {
+			return nil, err
+		}
+		t.Source = source
+		tools = append(tools, t)
+	}
+	return tools, rows.Err()
+}
+
+// mergeNewest merges two newest-first tool lists, tools violating their
+// SLA last, and returns the page starting at offset.
+func mergeNewest(a, b []*Tool, offset, limit int) []*Tool {
+	all := make([]*Tool, 0, len(a)+len(b))
+	all = append(all, a...)
+	all = append(all, b...)
+	sort.SliceStable(all, func(i, j int) bool {
+		if vi, vj := all[i].slaViolated(), all[j].slaViolated(); vi != vj {
+			return vj
+		}
+		return all[i].CreatedAt.After(all[j].CreatedAt)
+	})
+	if offset >= len(all) {
+		return nil
+	}
+	all = all[offset:]
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all
+}
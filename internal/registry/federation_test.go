@@ -0,0 +1,92 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mirroredTool(id, name string, created time.Time) *registry.Tool {
+	return &registry.Tool{
+		ID:          id,
+		Name:        name,
+		Version:     "1.0.0",
+		Description: "mirrored " + name,
+		Schema:      registry.ToolSchema{Input: []byte(`{"type":"object"}`)},
+		ProviderID:  "did:claw:agent:remote",
+		Endpoint:    "https://remote.example.com/" + name,
+		TimeoutMS:   1000,
+		Tags:        []string{"remote"},
+		CreatedAt:   created,
+	}
+}
+
+func TestSearchTools_Federated(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	_, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	require.NoError(t, r.MirrorPeer(ctx, "eu", []*registry.Tool{
+		mirroredTool("remote-1", "test-remote", time.Now().Add(time.Hour)),
+	}))
+
+	res, err := r.SearchTools(ctx, &registry.SearchQuery{Query: "test", Federated: true})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 2)
+	assert.Equal(t, "remote-1", res.Tools[0].ID, "results are merged newest first")
+	assert.Equal(t, "eu", res.Tools[0].Source)
+	assert.Equal(t, registry.SourceLocal, res.Tools[1].Source)
+
+	res, err = r.SearchTools(ctx, &registry.SearchQuery{Query: "test"})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 1)
+	assert.Equal(t, registry.SourceLocal, res.Tools[0].Source)
+
+	res, err = r.SearchTools(ctx, &registry.SearchQuery{Query: "test", Federated: true, Limit: 1, Page: 2})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 1)
+	assert.Equal(t, registry.SourceLocal, res.Tools[0].Source)
+}
+
+func TestSearchTools_FederatedOnlyInDefaultNamespace(t *testing.T) {
+	r := newTestRegistry(t)
+	require.NoError(t, r.MirrorPeer(context.Background(), "eu", []*registry.Tool{
+		mirroredTool("remote-1", "remote", time.Now()),
+	}))
+
+	ctx := registry.WithNamespace(context.Background(), "acme")
+	res, err := r.SearchTools(ctx, &registry.SearchQuery{Federated: true})
+	require.NoError(t, err)
+	assert.Empty(t, res.Tools)
+}
+
+func TestMirrorPeer_ReplacesPreviousMirror(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	require.NoError(t, r.MirrorPeer(ctx, "eu", []*registry.Tool{
+		mirroredTool("remote-1", "alpha", time.Now()),
+	}))
+	require.NoError(t, r.MirrorPeer(ctx, "eu", []*registry.Tool{
+		mirroredTool("remote-2", "beta", time.Now()),
+	}))
+
+	res, err := r.SearchTools(ctx, &registry.SearchQuery{Query: "alpha", Federated: true})
+	require.NoError(t, err)
+	assert.Empty(t, res.Tools, "stale mirror rows and their index entries are removed")
+
+	res, err = r.SearchTools(ctx, &registry.SearchQuery{Query: "beta", Federated: true})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 1)
+	assert.Equal(t, "remote-2", res.Tools[0].ID)
+}
+
+func TestMirrorPeer_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	assert.Error(t, r.MirrorPeer(context.Background(), "eu", nil))
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidForecast is returned for a forecast of an unknown period or
+// with a negative cap.
+var ErrInvalidForecast = errors.New("invalid forecast")
+
+// Forecast periods, UTC calendar days and months.
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// forecastWindow is how far back the spend rate of a forecast looks; the
+// trend compares it with the window before.
+const forecastWindow = 7 * 24 * time.Hour
+
+// minForecastAge is the least history a spend rate is drawn from, so that
+// a consumer's first call is not taken for a day's pace.
+const minForecastAge = time.Hour
+
+// ForecastQuery selects the spend a forecast projects.
+type ForecastQuery struct {
+	ConsumerID string
+	// Period is PeriodDay or PeriodMonth; "" is PeriodMonth.
+	Period string
+	// CapCLAW, when set, is the spend the consumer means to stay under.
+	CapCLAW string
+	// At is when the forecast is made; zero is now.
+	At time.Time
+}
+
+// SpendForecast projects a consumer's spend to the end of the current
+// period at the pace of its recent spend.
+type SpendForecast struct {
+	ConsumerID  string    `json:"consumer_id"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	// SpentCLAW is the cost of the invocations completed since PeriodStart.
+	SpentCLAW string `json:"spent_claw"`
+	// DailyRateCLAW is the average daily spend over the last seven days, or
+	// since the first invocation of the consumer if that is more recent.
+	DailyRateCLAW string `json:"daily_rate_claw"`
+	// Trend is how much more, as a fraction, the consumer spent in the last
+	// seven days than in the seven before: 0.25 for a quarter more, -0.5
+	// for half as much. It is nil without spend in the earlier week.
+	Trend *float64 `json:"trend"`
+	// ProjectedCLAW is SpentCLAW plus the daily rate until PeriodEnd.
+	ProjectedCLAW string `json:"projected_claw"`
+	CapCLAW       string `json:"cap_claw,omitempty"`
+	// ExhaustedAt is when spend at the daily rate reaches the cap, or
+	// reached it, if that is before PeriodEnd.
+	ExhaustedAt *time.Time   `json:"exhausted_at,omitempty"`
+	ByTool      []*ToolSpend `json:"by_tool"`
+}
+
+// ToolSpend is what a consumer spent on a tool in a forecast's period.
+type ToolSpend struct {
+	ToolID    string `json:"tool_id"`
+	SpentCLAW string `json:"spent_claw"`
+	Calls     int64  `json:"calls"`
+}
+
+// periodBounds returns the UTC day or month containing t.
+func periodBounds(period string, t time.Time) (time.Time, time.Time, error) {
+	u := t.UTC()
+	switch period {
+	case PeriodDay:
+		start := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1), nil
+	case PeriodMonth:
+		start := time.Date(u.Year(), u.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%w: period must be %s or %s", ErrInvalidForecast, PeriodDay, PeriodMonth)
+}
+
+// ForecastSpend projects the spend of q.ConsumerID in the context
+// namespace to the end of the current period, from the cost of its
+// completed invocations. Unlike usage rollups, it reads the invocations
+// themselves and so counts calls made seconds ago.
+func (r *Registry) ForecastSpend(ctx context.Context, q ForecastQuery) (*SpendForecast, error) {
+	if q.Period == "" {
+		q.Period = PeriodMonth
+	}
+	now := q.At
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC().Truncate(time.Second)
+	start, end, err := periodBounds(q.Period, now)
+	if err != nil {
+		return nil, err
+	}
+	var budget float64
+	if q.CapCLAW != "" {
+		if budget, err = strconv.ParseFloat(q.CapCLAW, 64); err != nil || budget < 0 {
+			return nil, fmt.Errorf("%w: cap_claw must be a non-negative decimal", ErrInvalidForecast)
+		}
+	}
+	ns := NamespaceFrom(ctx)
+	f := &SpendForecast{
+		ConsumerID:  q.ConsumerID,
+		Period:      q.Period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		CapCLAW:     q.CapCLAW,
+		ByTool:      []*ToolSpend{},
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tool_id, COUNT(*), COALESCE(SUM(CAST(cost_claw AS REAL)), 0) AS spend FROM invocations
+		WHERE namespace = ? AND consumer_id = ? AND status = 'completed' AND started_at >= ? AND started_at <= ?
+		GROUP BY tool_id
+		ORDER BY spend DESC, tool_id
+	`, ns, q.ConsumerID, start.Unix(), now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("spend by tool: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var spent float64
+	for rows.Next() {
+		var (
+			t     ToolSpend
+			spend float64
+		)
+		if err := rows.Scan(&t.ToolID, &t.Calls, &spend); err != nil {
+			return nil, fmt.Errorf("scan tool spend: %w", err)
+		}
+		t.SpentCLAW = formatCLAW(spend)
+		f.ByTool = append(f.ByTool, &t)
+		spent += spend
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("spend by tool: %w", err)
+	}
+
+	var (
+		recent, earlier float64
+		first           *int64
+	)
+	since := now.Add(-forecastWindow)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN started_at >= ? THEN CAST(cost_claw AS REAL) END), 0),
+		       COALESCE(SUM(CASE WHEN started_at < ? THEN CAST(cost_claw AS REAL) END), 0),
+		       MIN(started_at)
+		FROM invocations
+		WHERE namespace = ? AND consumer_id = ? AND status = 'completed' AND started_at >= ? AND started_at <= ?
+	`, since.Unix(), since.Unix(), ns, q.ConsumerID, since.Add(-forecastWindow).Unix(), now.Unix()).Scan(&recent, &earlier, &first)
+	if err != nil {
+		return nil, fmt.Errorf("recent spend: %w", err)
+	}
+	age := forecastWindow
+	if first != nil {
+		age = min(age, max(now.Sub(time.Unix(*first, 0)), minForecastAge))
+	}
+	perDay := recent / age.Hours() * 24
+	if earlier > 0 {
+		trend := math.Round((recent/earlier-1)*1e4) / 1e4
+		f.Trend = &trend
+	}
+
+	days := end.Sub(now).Hours() / 24
+	f.SpentCLAW = formatCLAW(spent)
+	f.DailyRateCLAW = formatCLAW(perDay)
+	f.ProjectedCLAW = formatCLAW(spent + perDay*days)
+	if q.CapCLAW != "" {
+		switch {
+		case spent >= budget:
+			f.ExhaustedAt = &now
+		case perDay > 0:
+			at := now.Add(time.Duration((budget - spent) / perDay * float64(24*time.Hour))).Truncate(time.Second)
+			if at.Before(end) {
+				f.ExhaustedAt = &at
+			}
+		}
+	}
+	return f, nil
+}
+
+// formatCLAW formats an amount of CLAW summed from invocation costs,
+// rounded to nine decimals like the costs themselves.
+func formatCLAW(amount float64) string {
+	return strconv.FormatFloat(math.Round(amount*1e9)/1e9, 'f', -1, 64)
+}
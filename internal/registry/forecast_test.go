@@ -0,0 +1,82 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestForecastSpend(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t))
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	at := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	spend := func(consumer string, ago time.Duration, cost string, complete bool) {
+		id, err := r.RecordInvocation(ctx, tool.ID, consumer, map[string]any{"ago": ago.String()})
+		require.NoError(t, err)
+		if complete {
+			require.NoError(t, r.CompleteInvocation(ctx, id, "h", "", cost))
+		}
+		_, err = db.ExecContext(ctx, "UPDATE invocations SET started_at = ? WHERE id = ?", at.Add(-ago).Unix(), id)
+		require.NoError(t, err)
+	}
+	const day = 24 * time.Hour
+	spend("consumer", 23*day, "100", true) // last month, before the trend window
+	spend("consumer", 10*day, "7", true)
+	spend("consumer", 2*day, "10", true)
+	spend("consumer", day, "4", true)
+	spend("consumer", time.Hour, "50", false) // still pending
+	spend("other", day, "1000", true)
+
+	f, err := r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", CapCLAW: "30", At: at})
+	require.NoError(t, err)
+	assert.Equal(t, registry.PeriodMonth, f.Period)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), f.PeriodStart)
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), f.PeriodEnd)
+	assert.Equal(t, "21", f.SpentCLAW)
+	assert.Equal(t, "2", f.DailyRateCLAW, "14 CLAW over the last week")
+	require.NotNil(t, f.Trend)
+	assert.Equal(t, 1.0, *f.Trend, "twice the spend of the week before")
+	assert.Equal(t, "54", f.ProjectedCLAW, "16.5 more days at 2 a day")
+	require.NotNil(t, f.ExhaustedAt)
+	assert.Equal(t, time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), *f.ExhaustedAt)
+	require.Len(t, f.ByTool, 1)
+	assert.Equal(t, registry.ToolSpend{ToolID: tool.ID, SpentCLAW: "21", Calls: 3}, *f.ByTool[0])
+
+	f, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", CapCLAW: "20", At: at})
+	require.NoError(t, err)
+	require.NotNil(t, f.ExhaustedAt)
+	assert.Equal(t, at, *f.ExhaustedAt, "already over the cap")
+	f, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", CapCLAW: "100", At: at})
+	require.NoError(t, err)
+	assert.Nil(t, f.ExhaustedAt, "the cap outlasts the month")
+
+	f, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", Period: registry.PeriodDay, At: at})
+	require.NoError(t, err)
+	assert.Equal(t, "0", f.SpentCLAW)
+	assert.Equal(t, "1", f.ProjectedCLAW)
+	assert.Empty(t, f.ByTool)
+
+	// A newcomer's rate is drawn from the time since its first call.
+	f, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "other", At: at})
+	require.NoError(t, err)
+	assert.Equal(t, "1000", f.DailyRateCLAW)
+	assert.Nil(t, f.Trend)
+
+	f, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "nobody", At: at})
+	require.NoError(t, err)
+	assert.Equal(t, "0", f.ProjectedCLAW)
+
+	_, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", Period: "week"})
+	assert.ErrorIs(t, err, registry.ErrInvalidForecast)
+	_, err = r.ForecastSpend(ctx, registry.ForecastQuery{ConsumerID: "consumer", CapCLAW: "-1"})
+	assert.ErrorIs(t, err, registry.ErrInvalidForecast)
+}
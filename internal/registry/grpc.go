@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/grpcreflect"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrReflection is returned when a gRPC tool cannot be described through
+// server reflection: the server is unreachable, does not offer reflection,
+// or has no usable method by that name.
+var ErrReflection = errors.New("grpc reflection failed")
+
+// reflectTimeout bounds the introspection of one gRPC method.
+const reflectTimeout = 10 * time.Second
+
+// RegisterGRPCToolRequest registers a tool for one unary method of a gRPC
+// service with server reflection enabled. The registry derives the tool's
+// JSON Schemas from the method's protobuf descriptors.
+type RegisterGRPCToolRequest struct {
+	Pricing *Pricing `json:"pricing"`
+	// Name defaults to the method's full name, e.g. "acme.lint.Linter.Check".
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Description defaults to the method's comment, when the server ships
+	// source info.
+	Description string `json:"description"`
+	// Target is grpc://host:port, or grpcs://host:port for TLS.
+	Target string `json:"target"`
+	// Method is "package.Service/Method".
+	Method     string   `json:"method"`
+	ProviderID string   `json:"-"`
+	Tags       []string `json:"tags"`
+	TimeoutMS  int64    `json:"timeout_ms"`
+}
+
+// GRPCBinding is what the invoker needs to call a reflected tool: where, which
+// method, and the descriptors to translate JSON to protobuf and back.
+type GRPCBinding struct {
+	ToolID string
+	Target string
+	Method *grpcreflect.Method
+	TLS    bool
+}
+
+// RegisterGRPCTool introspects req.Method on req.Target and registers it as
+// a tool whose endpoint is the method's URL, grpc://host:port/pkg.Service/Method.
+func (r *Registry) RegisterGRPCTool(ctx context.Context, req *RegisterGRPCToolRequest) (*Tool, error) {
+	if req.Version == "" {
+		return nil, fmt.Errorf("validate: version is required")
+	}
+	hostport, useTLS, err := grpcTarget(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkEndpoint(req.Target); err != nil {
+		return nil, err
+	}
+	if err := r.CheckProvider(ctx, req.ProviderID, req.Target); err != nil {
+		return nil, err
+	}
+
+	method, err := r.reflectMethod(ctx, hostport, useTLS, req.Method)
+	if err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(grpcreflect.Schema(method.Desc.Input()))
+	if err != nil {
+		return nil, fmt.Errorf("marshal input schema: %w", err)
+	}
+	output, err := json.Marshal(grpcreflect.Schema(method.Desc.Output()))
+	if err != nil {
+		return nil, fmt.Errorf("marshal output schema: %w", err)
+	}
+	files, err := proto.Marshal(method.Files)
+	if err != nil {
+		return nil, fmt.Errorf("marshal descriptors: %w", err)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = string(method.Desc.FullName())
+	}
+	description := req.Description
+	if description == "" {
+		description = method.Comment()
+	}
+	tool, err := r.RegisterTool(ctx, &RegisterToolRequest{
+		Name:        name,
+		Version:     req.Version,
+		Description: description,
+		Endpoint:    strings.TrimSuffix(req.Target, "/") + method.FullName,
+		ProviderID:  req.ProviderID,
+		Schema:      ToolSchema{Input: input, Output: output},
+		Pricing:     req.Pricing,
+		Tags:        req.Tags,
+		TimeoutMS:   req.TimeoutMS,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO grpc_bindings (tool_id, target, method, descriptors, created_at) VALUES (?, ?, ?, ?, ?)
+	`, tool.ID, req.Target, method.FullName, files, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert grpc binding: %w", err)
+	}
+	r.logger(ctx).Info("grpc tool bound",
+		zap.String("id", tool.ID), zap.String("target", req.Target), zap.String("method", method.FullName))
+	return tool, nil
+}
+
+// GRPCBinding returns the binding of a tool registered with RegisterGRPCTool.
+func (r *Registry) GRPCBinding(ctx context.Context, toolID string) (*GRPCBinding, error) {
+	var (
+		b      = GRPCBinding{ToolID: toolID}
+		method string
+		files  []byte
+	)
+	err := r.db.QueryRowContext(ctx,
+		"SELECT target, method, descriptors FROM grpc_bindings WHERE tool_id = ?", toolID,
+	).Scan(&b.Target, &method, &files)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no grpc binding for %s", ErrNotFound, toolID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get grpc binding: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(files, &set); err != nil {
+		return nil, fmt.Errorf("decode grpc binding: %w", err)
+	}
+	if b.Method, err = grpcreflect.Load(&set, method); err != nil {
+		return nil, fmt.Errorf("load grpc binding: %w", err)
+	}
+	b.TLS = strings.HasPrefix(b.Target, "grpcs://")
+	return &b, nil
+}
+
+// DialGRPC connects to a gRPC target through the endpoint policy.
+func (r *Registry) DialGRPC(hostport string, useTLS bool) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return grpc.NewClient("passthrough:///"+hostport,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return r.endpoints.DialContext(ctx, "tcp", addr)
+		}),
+	)
+}
+
+func (r *Registry) reflectMethod(ctx context.Context, hostport string, useTLS bool, method string) (*grpcreflect.Method, error) {
+	conn, err := r.DialGRPC(hostport, useTLS)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReflection, err)
+	}
+	defer func() { _ = conn.Close() }()
+	ctx, cancel := context.WithTimeout(ctx, reflectTimeout)
+	defer cancel()
+	m, err := grpcreflect.Resolve(ctx, conn, method)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReflection, err)
+	}
+	return m, nil
+}
+
+// grpcTarget parses grpc://host:port or grpcs://host:port.
+func grpcTarget(target string) (hostport string, useTLS bool, err error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "grpc" && u.Scheme != "grpcs") || u.Port() == "" || strings.Trim(u.Path, "/") != "" {
+		return "", false, fmt.Errorf("%w: target must be grpc://host:port or grpcs://host:port", ErrInvalidEndpoint)
+	}
+	return u.Host, u.Scheme == "grpcs", nil
+}
@@ -0,0 +1,91 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/netguard"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// startGRPC serves the standard health service with reflection enabled and
+// returns its grpc:// target.
+func startGRPC(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return "grpc://" + lis.Addr().String()
+}
+
+func TestRegisterGRPCTool(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	target := startGRPC(t)
+
+	tool, err := r.RegisterGRPCTool(ctx, &registry.RegisterGRPCToolRequest{
+		Version:    "1.0.0",
+		Target:     target,
+		Method:     "grpc.health.v1.Health/Check",
+		ProviderID: "did:claw:provider:grpc",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "grpc.health.v1.Health.Check", tool.Name)
+	assert.Equal(t, target+"/grpc.health.v1.Health/Check", tool.Endpoint)
+
+	var input, output map[string]any
+	require.NoError(t, json.Unmarshal(tool.Schema.Input, &input))
+	require.NoError(t, json.Unmarshal(tool.Schema.Output, &output))
+	assert.Equal(t, map[string]any{"type": "string"}, input["properties"].(map[string]any)["service"])
+	status := output["properties"].(map[string]any)["status"].(map[string]any)
+	assert.Contains(t, status["enum"], "SERVING")
+
+	b, err := r.GRPCBinding(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, target, b.Target)
+	assert.False(t, b.TLS)
+	assert.Equal(t, "/grpc.health.v1.Health/Check", b.Method.FullName)
+	assert.Equal(t, "grpc.health.v1.HealthCheckRequest", string(b.Method.Desc.Input().FullName()))
+
+	_, err = r.GRPCBinding(ctx, "did:claw:tool:none")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestRegisterGRPCTool_Errors(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	target := startGRPC(t)
+	req := func(target, method string) *registry.RegisterGRPCToolRequest {
+		return &registry.RegisterGRPCToolRequest{Name: "health", Version: "1.0.0", Target: target, Method: method}
+	}
+
+	_, err := r.RegisterGRPCTool(ctx, req(target, "grpc.health.v1.Health/Nope"))
+	assert.ErrorIs(t, err, registry.ErrReflection)
+	_, err = r.RegisterGRPCTool(ctx, req(target, "grpc.health.v1.Health/Watch"))
+	assert.ErrorIs(t, err, registry.ErrReflection, "streaming methods are refused")
+	assert.ErrorContains(t, err, "streaming")
+	_, err = r.RegisterGRPCTool(ctx, req(target, "acme.Missing/Call"))
+	assert.ErrorIs(t, err, registry.ErrReflection)
+	_, err = r.RegisterGRPCTool(ctx, req("https://example.com", "a.B/C"))
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+	_, err = r.RegisterGRPCTool(ctx, req(target+"/a.B/C", "a.B/C"))
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+
+	guarded := registry.New(openTestDB(t), zaptest.NewLogger(t),
+		registry.WithEndpointPolicy(netguard.Policy{BlockPrivate: true}))
+	_, err = guarded.RegisterGRPCTool(ctx, req(target, "grpc.health.v1.Health/Check"))
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MaxIconBytes bounds the size of tool icons.
+const MaxIconBytes = 256 << 10
+
+// IconTypes are the content types tool icons may have. SVG is left out: it
+// can carry scripts.
+var IconTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// ErrInvalidIcon is returned for an icon that is empty, too large, of a
+// content type other than IconTypes or whose bytes are not of its content
+// type.
+var ErrInvalidIcon = errors.New("invalid icon")
+
+// Icon is the icon or logo of a tool, for catalogs to render.
+type Icon struct {
+	Digest      string    `json:"digest"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	Data        []byte    `json:"-"`
+}
+
+// checkIcon returns the media type of an icon of contentType, after
+// checking that data is an image of that type.
+func checkIcon(contentType string, data []byte) (string, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	switch {
+	case err != nil || !slices.Contains(IconTypes, mt):
+		return "", fmt.Errorf("%w: content type %q must be one of %v", ErrInvalidIcon, contentType, IconTypes)
+	case len(data) == 0:
+		return "", fmt.Errorf("%w: empty", ErrInvalidIcon)
+	case len(data) > MaxIconBytes:
+		return "", fmt.Errorf("%w: larger than %d bytes", ErrInvalidIcon, MaxIconBytes)
+	}
+	if sniffed := http.DetectContentType(data); sniffed != mt {
+		return "", fmt.Errorf("%w: content is %s, not %s", ErrInvalidIcon, sniffed, mt)
+	}
+	return mt, nil
+}
+
+// SetIcon makes data, an image of contentType, the icon of the tool with
+// the given id, which providerID must own.
+func (r *Registry) SetIcon(ctx context.Context, id, providerID, contentType string, data []byte) (*Icon, error) {
+	mt, err := checkIcon(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if err := r.setToolIcon(ctx, id, providerID, digest); err != nil {
+		return nil, err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO icons (digest, content_type, size, data, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(digest) DO NOTHING
+	`, digest, mt, len(data), data, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert icon: %w", err)
+	}
+	r.logger(ctx).Info("tool icon updated", zap.String("id", id), zap.String("digest", digest), zap.Int("size", len(data)))
+	return r.GetIcon(ctx, id)
+}
+
+// DeleteIcon removes the icon of the tool with the given id, which
+// providerID must own.
+func (r *Registry) DeleteIcon(ctx context.Context, id, providerID string) error {
+	if err := r.setToolIcon(ctx, id, providerID, ""); err != nil {
+		return err
+	}
+	r.logger(ctx).Info("tool icon removed", zap.String("id", id))
+	return nil
+}
+
+// setToolIcon points the tool at the icon of digest, and drops the icon it
+// had when no tool uses it any more.
+func (r *Registry) setToolIcon(ctx context.Context, id, providerID, digest string) error {
+	var old string
+	err := r.db.QueryRowContext(ctx, "SELECT icon FROM tools WHERE id = ? AND provider_id = ? AND namespace = ?",
+		id, providerID, NamespaceFrom(ctx)).Scan(&old)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w or not authorized", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("get icon: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, "UPDATE tools SET icon = ?, updated_at = ? WHERE id = ?", digest, time.Now().Unix(), id); err != nil {
+		return fmt.Errorf("set icon: %w", err)
+	}
+	if old != "" && old != digest {
+		_, err = r.db.ExecContext(ctx, "DELETE FROM icons WHERE digest = ? AND NOT EXISTS (SELECT 1 FROM tools WHERE icon = ?)", old, old)
+		if err != nil {
+			return fmt.Errorf("delete icon: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetIcon returns the icon of the tool with the given id in the context
+// namespace, or ErrNotFound when it has none.
+func (r *Registry) GetIcon(ctx context.Context, id string) (*Icon, error) {
+	var (
+		icon      Icon
+		createdAt int64
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT i.digest, i.content_type, i.size, i.data, i.created_at FROM tools t JOIN icons i ON i.digest = t.icon
+		WHERE t.id = ? AND t.namespace = ?
+	`, id, NamespaceFrom(ctx)).Scan(&icon.Digest, &icon.ContentType, &icon.Size, &icon.Data, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get icon: %w", err)
+	}
+	icon.CreatedAt = time.Unix(createdAt, 0).UTC()
+	return &icon, nil
+}
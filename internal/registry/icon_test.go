@@ -0,0 +1,70 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngIcon(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, size, size))))
+	return buf.Bytes()
+}
+
+func TestToolIcon(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	req := validRegisterReq()
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	_, err = r.GetIcon(ctx, tool.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	small := pngIcon(t, 1)
+	icon, err := r.SetIcon(ctx, tool.ID, req.ProviderID, "image/png", small)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", icon.ContentType)
+	assert.Equal(t, len(small), icon.Size)
+	assert.Equal(t, small, icon.Data)
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, icon.Digest, got.Icon)
+
+	_, err = r.SetIcon(ctx, tool.ID, "did:claw:agent:someone-else", "image/png", small)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	for name, tc := range map[string]struct {
+		contentType string
+		data        []byte
+	}{
+		"svg":        {"image/svg+xml", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)},
+		"mismatched": {"image/jpeg", small},
+		"not image":  {"image/png", []byte("hello")},
+		"empty":      {"image/png", nil},
+		"too large":  {"image/png", append(pngIcon(t, 1), make([]byte, registry.MaxIconBytes)...)},
+	} {
+		_, err := r.SetIcon(ctx, tool.ID, req.ProviderID, tc.contentType, tc.data)
+		assert.ErrorIs(t, err, registry.ErrInvalidIcon, name)
+	}
+
+	larger := pngIcon(t, 8)
+	icon, err = r.SetIcon(ctx, tool.ID, req.ProviderID, "image/png; charset=binary", larger)
+	require.NoError(t, err)
+	got, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, icon.Digest, got.Icon, "a new icon replaces the old")
+
+	require.NoError(t, r.DeleteIcon(ctx, tool.ID, req.ProviderID))
+	_, err = r.GetIcon(ctx, tool.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	got, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Icon)
+}
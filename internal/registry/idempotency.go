@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyWindow is how long an idempotency key stands for the first
+// invocation a consumer sent with it.
+const IdempotencyWindow = 24 * time.Hour
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused for
+// another tool or input, or while the invocation it stands for is running.
+var ErrIdempotencyConflict = errors.New("idempotency key conflict")
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey marks ctx so the invocation recorded with it holds
+// key, unique among the consumer's invocations of the last
+// IdempotencyWindow. Such invocations are never batched, so a duplicate is
+// refused by RecordInvocation itself.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// idempotencyKey returns the key ctx carries as a column value, NULL when
+// it carries none.
+func idempotencyKey(ctx context.Context) any {
+	if key, _ := ctx.Value(idempotencyKeyKey{}).(string); key != "" {
+		return key
+	}
+	return nil
+}
+
+// IdempotentInvocation returns the invocation consumerID sent with key in
+// the last IdempotencyWindow and, once it completed, its output, for a
+// retry to be answered with instead of invoking the tool again. It returns
+// ErrNotFound when there is none, and ErrIdempotencyConflict when it was of
+// another tool or input or is still running. Failed invocations release
+// their key, so they can be retried.
+func (r *Registry) IdempotentInvocation(
+	ctx context.Context, consumerID, key, toolID string, input []byte,
+) (*Invocation, []byte, error) {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return nil, nil, err
+	}
+	// Keys past the window are released here too, not only when purged, so
+	// reusing one never hits the unique index.
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET idempotency_key = NULL, idempotent_output = NULL
+		WHERE consumer_id = ? AND idempotency_key = ? AND started_at <= ?
+	`, consumerID, key, time.Now().Add(-IdempotencyWindow).Unix())
+	if err != nil {
+		return nil, nil, fmt.Errorf("release idempotency key: %w", err)
+	}
+	inv, err := scanInvocation(r.db.QueryRowContext(ctx, "SELECT "+invocationColumns+`
+		FROM invocations WHERE consumer_id = ? AND idempotency_key = ?
+	`, consumerID, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get idempotent invocation: %w", err)
+	}
+	var output []byte
+	err = r.db.QueryRowContext(ctx, "SELECT idempotent_output FROM invocations WHERE id = ?", inv.ID).Scan(&output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get idempotent output: %w", err)
+	}
+	switch {
+	case inv.ToolID != toolID || inv.InputHash != hashInput(input):
+		return nil, nil, fmt.Errorf("%w: key %q was sent with another tool or input", ErrIdempotencyConflict, key)
+	case inv.Status != "completed" || output == nil:
+		return nil, nil, fmt.Errorf("%w: invocation %s with key %q is %s", ErrIdempotencyConflict, inv.ID, key, inv.Status)
+	}
+	return inv, output, nil
+}
+
+// StoreIdempotentOutput keeps output with invocation id for retries with
+// its idempotency key. It does nothing for invocations sent without one.
+func (r *Registry) StoreIdempotentOutput(ctx context.Context, id string, output []byte) error {
+	if idempotencyKey(ctx) == nil {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, "UPDATE invocations SET idempotent_output = ? WHERE id = ? AND idempotency_key IS NOT NULL",
+		output, id)
+	if err != nil {
+		return fmt.Errorf("store idempotent output: %w", err)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys releases the idempotency keys, and drops the outputs
+// kept for them, of invocations older than IdempotencyWindow.
+func (r *Registry) PurgeIdempotencyKeys(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET idempotency_key = NULL, idempotent_output = NULL
+		WHERE idempotency_key IS NOT NULL AND started_at <= ?
+	`, time.Now().Add(-IdempotencyWindow).Unix())
+	if err != nil {
+		return fmt.Errorf("purge idempotency keys: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger(ctx).Debug("idempotency keys purged", zap.Int64("rows", n))
+	}
+	return nil
+}
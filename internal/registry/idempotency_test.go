@@ -0,0 +1,51 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentInvocation(t *testing.T) {
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(context.Background(), validRegisterReq())
+	require.NoError(t, err)
+	ctx := registry.WithIdempotencyKey(context.Background(), "key-1")
+	input := map[string]any{"q": "x"}
+	encoded := []byte(`{"q":"x"}`)
+
+	_, _, err = r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, encoded)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	id, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", input)
+	require.NoError(t, err)
+	_, _, err = r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, encoded)
+	assert.ErrorIs(t, err, registry.ErrIdempotencyConflict, "still running")
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer-a", input)
+	assert.ErrorIs(t, err, registry.ErrIdempotencyConflict)
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer-b", input)
+	assert.NoError(t, err, "keys are per consumer")
+
+	require.NoError(t, r.FailInvocation(ctx, id, "boom"))
+	_, _, err = r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, encoded)
+	assert.ErrorIs(t, err, registry.ErrNotFound, "failed invocations release their key")
+
+	id, err = r.RecordInvocation(ctx, tool.ID, "consumer-a", input)
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteInvocation(ctx, id, "hash", "", "5"))
+	require.NoError(t, r.StoreIdempotentOutput(ctx, id, []byte(`{"ok":true}`)))
+	inv, out, err := r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, encoded)
+	require.NoError(t, err)
+	assert.Equal(t, id, inv.ID)
+	assert.Equal(t, "5", inv.CostCLAW)
+	assert.JSONEq(t, `{"ok":true}`, string(out))
+
+	_, _, err = r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, []byte(`{"q":"y"}`))
+	assert.ErrorIs(t, err, registry.ErrIdempotencyConflict, "another input")
+	require.NoError(t, r.PurgeIdempotencyKeys(ctx))
+	_, _, err = r.IdempotentInvocation(ctx, "consumer-a", "key-1", tool.ID, encoded)
+	assert.NoError(t, err, "keys stay for the whole window")
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// invocationBatchMax is how many invocation records may wait for a group
+// commit; the call that fills the batch commits it.
+const invocationBatchMax = 256
+
+// invocationLog buffers new invocation records so that many are inserted
+// in one transaction instead of one each.
+type invocationLog struct {
+	mu      sync.Mutex
+	pending []pendingInvocation
+	// commit is held while a batch is written, so a flush that finds
+	// nothing pending still waits for rows taken by a flush in progress.
+	commit sync.Mutex
+}
+
+type pendingInvocation struct {
+	id, toolID, consumerID, inputHash, namespace string
+	startedAt                                    int64
+	payload                                      *sealedPayload
+	metadata                                     any
+}
+
+// WithInvocationBatching makes RecordInvocation queue new invocation
+// records for a group commit instead of inserting each one before
+// returning. FlushInvocations commits the queue; run it every few
+// milliseconds. Reads and updates of an invocation flush first, so callers
+// of the registry never see a queued invocation missing.
+func WithInvocationBatching() Option {
+	return func(r *Registry) { r.invlog = &invocationLog{} }
+}
+
+type syncWritesKey struct{}
+
+// WithSyncWrites marks ctx so invocations recorded with it are inserted
+// before RecordInvocation returns, even with batching enabled: for records
+// that must survive a crash right after the call, such as paid invocations.
+func WithSyncWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, syncWritesKey{}, true)
+}
+
+func syncWrites(ctx context.Context) bool {
+	v, _ := ctx.Value(syncWritesKey{}).(bool)
+	return v
+}
+
+// queueInvocation adds inv to the next group commit. It reports false when
+// inv must be inserted directly.
+func (r *Registry) queueInvocation(ctx context.Context, inv pendingInvocation) (bool, error) {
+	if r.invlog == nil || syncWrites(ctx) || IsQueued(ctx) || idempotencyKey(ctx) != nil {
+		return false, nil
+	}
+	r.invlog.mu.Lock()
+	r.invlog.pending = append(r.invlog.pending, inv)
+	full := len(r.invlog.pending) >= invocationBatchMax
+	r.invlog.mu.Unlock()
+	if full {
+		return true, r.FlushInvocations(ctx)
+	}
+	return true, nil
+}
+
+// FlushInvocations commits queued invocation records in one transaction,
+// falling back to one per record when the batch fails. It does nothing
+// unless batching is enabled.
+func (r *Registry) FlushInvocations(ctx context.Context) error {
+	if r.invlog == nil {
+		return nil
+	}
+	r.invlog.commit.Lock()
+	defer r.invlog.commit.Unlock()
+	r.invlog.mu.Lock()
+	batch := r.invlog.pending
+	r.invlog.pending = nil
+	r.invlog.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// The batch holds other callers' records: do not let this caller's
+	// cancellation drop them.
+	ctx = context.WithoutCancel(ctx)
+	start := time.Now()
+	if err := r.insertInvocations(ctx, batch); err != nil {
+		if len(batch) == 1 {
+			r.logger(ctx).Error("invocation record lost", zap.String("invocation_id", batch[0].id), zap.Error(err))
+			return fmt.Errorf("flush invocations: %w", err)
+		}
+		// One bad record, such as one for a tool deleted meanwhile, rolls
+		// back the whole batch: insert the records one at a time so only
+		// the bad ones are lost.
+		r.logger(ctx).Warn("invocation batch failed; inserting records one at a time",
+			zap.Int("count", len(batch)), zap.Error(err))
+		var lost error
+		for _, inv := range batch {
+			if err := r.insertInvocations(ctx, []pendingInvocation{inv}); err != nil {
+				r.logger(ctx).Error("invocation record lost", zap.String("invocation_id", inv.id), zap.Error(err))
+				lost = err
+			}
+		}
+		if lost != nil {
+			return fmt.Errorf("flush invocations: %w", lost)
+		}
+	}
+	r.logger(ctx).Debug("invocations flushed", zap.Int("count", len(batch)), zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// insertInvocations inserts batch in one transaction.
+func (r *Registry) insertInvocations(ctx context.Context, batch []pendingInvocation) error {
+	return r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO invocations (id, tool_id, consumer_id, input_hash, started_at, status, namespace, instance_id,
+				input, payload_owner, payload_expires_at, metadata)
+			VALUES (?, ?, ?, ?, ?, 'pending', ?, ?, ?, ?, ?, ?)
+		`)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = stmt.Close() }()
+		for _, inv := range batch {
+			sealed, owner, expiresAt := inv.payload.columns()
+			_, err := stmt.ExecContext(ctx, inv.id, inv.toolID, inv.consumerID, inv.inputHash, inv.startedAt, inv.namespace,
+				r.instanceID, sealed, owner, expiresAt, inv.metadata)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,97 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func countInvocations(t *testing.T, db *store.DB) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM invocations").Scan(&n))
+	return n
+}
+
+func TestInvocationBatching(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithInvocationBatching())
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	first, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"n": 1})
+	require.NoError(t, err)
+	second, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"n": 2})
+	require.NoError(t, err)
+	assert.Zero(t, countInvocations(t, db), "records wait for the group commit")
+
+	inv, err := r.GetInvocation(ctx, first)
+	require.NoError(t, err, "reading an invocation flushes the queue")
+	assert.Equal(t, "pending", inv.Status)
+	assert.Equal(t, 2, countInvocations(t, db))
+
+	third, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"n": 3})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteInvocation(ctx, third, "sha256:out", "", "5.0"))
+	inv, err = r.GetInvocation(ctx, third)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", inv.Status, "an update of a queued record is not lost")
+
+	require.NoError(t, r.FailInvocation(ctx, second, "boom"))
+	require.NoError(t, r.FlushInvocations(ctx), "flushing an empty queue is a no-op")
+	assert.Equal(t, 3, countInvocations(t, db))
+}
+
+func TestInvocationBatching_SyncWrites(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithInvocationBatching())
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	_, err = r.RecordInvocation(registry.WithSyncWrites(ctx), tool.ID, "did:claw:agent:c", map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, countInvocations(t, db))
+}
+
+func TestInvocationBatching_FullBatchCommits(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithInvocationBatching())
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	for i := 0; i < 256; i++ {
+		_, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"n": i})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 256, countInvocations(t, db), "the call that fills the batch commits it")
+}
+
+func TestInvocationBatching_BadRecordKeepsOthers(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithInvocationBatching())
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	first, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{})
+	require.NoError(t, err)
+	_, err = r.RecordInvocation(ctx, "tool_missing", "did:claw:agent:c", map[string]any{})
+	require.NoError(t, err)
+	third, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{})
+	require.NoError(t, err)
+
+	assert.Error(t, r.FlushInvocations(ctx), "the record for a missing tool fails its foreign key")
+	assert.Equal(t, 2, countInvocations(t, db), "the other records of the batch are kept")
+	for _, id := range []string{first, third} {
+		_, err := r.GetInvocation(ctx, id)
+		assert.NoError(t, err, id)
+	}
+}
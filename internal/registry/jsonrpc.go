@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// JSONRPCScheme prefixes the endpoint of a tool served by a JSON-RPC 2.0
+// provider: "jsonrpc+https://host/rpc#lint.check" calls method lint.check
+// on https://host/rpc, with the tool input as named params.
+const JSONRPCScheme = "jsonrpc+"
+
+// JSONRPCEndpoint splits a jsonrpc+ endpoint into the URL to post calls to
+// and the method to call.
+func JSONRPCEndpoint(endpoint string) (target, method string, ok bool) {
+	rest, found := strings.CutPrefix(endpoint, JSONRPCScheme)
+	if !found {
+		return "", "", false
+	}
+	target, frag, found := strings.Cut(rest, "#")
+	if !found || frag == "" {
+		return "", "", false
+	}
+	method, err := url.PathUnescape(frag)
+	if err != nil {
+		return "", "", false
+	}
+	return target, method, true
+}
+
+// checkJSONRPC verifies that a jsonrpc+ endpoint is an HTTP URL naming a
+// method. Other endpoints pass.
+func checkJSONRPC(endpoint string) error {
+	if !strings.HasPrefix(endpoint, JSONRPCScheme) {
+		return nil
+	}
+	target, _, ok := JSONRPCEndpoint(endpoint)
+	u, err := url.Parse(target)
+	if !ok || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: JSON-RPC endpoints are %shttps://host/path#method", ErrInvalidEndpoint, JSONRPCScheme)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRPCEndpoint(t *testing.T) {
+	target, method, ok := registry.JSONRPCEndpoint("jsonrpc+https://rpc.example.com/v1#lint%2Fcheck")
+	require.True(t, ok)
+	assert.Equal(t, "https://rpc.example.com/v1", target)
+	assert.Equal(t, "lint/check", method)
+
+	_, _, ok = registry.JSONRPCEndpoint("jsonrpc+https://rpc.example.com/v1")
+	assert.False(t, ok)
+	_, _, ok = registry.JSONRPCEndpoint("https://rpc.example.com/v1#lint")
+	assert.False(t, ok)
+}
+
+func TestRegisterTool_JSONRPCEndpoint(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	for _, endpoint := range []string{"jsonrpc+https://rpc.example.com/v1", "jsonrpc+ftp://rpc.example.com#lint"} {
+		req := validRegisterReq()
+		req.Endpoint = endpoint
+		_, err := r.RegisterTool(ctx, req)
+		assert.ErrorIs(t, err, registry.ErrInvalidEndpoint, endpoint)
+	}
+
+	req := validRegisterReq()
+	req.Endpoint = "jsonrpc+https://rpc.example.com/v1#lint.check"
+	_, err := r.RegisterTool(ctx, req)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+)
+
+// KeyChallengeTTL is how long a key challenge can be answered.
+const KeyChallengeTTL = 5 * time.Minute
+
+// ErrInvalidKeyProof is returned for a provider registration whose key
+// proof is missing, names an unknown or expired challenge, or does not
+// verify.
+var ErrInvalidKeyProof = errors.New("invalid key proof")
+
+// KeyChallenge is a nonce a provider signs with the key it registers, to
+// prove it holds the key rather than squatting on someone else's.
+type KeyChallenge struct {
+	Challenge  string    `json:"challenge"`
+	ProviderID string    `json:"provider_id"`
+	PubKey     string    `json:"pubkey,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// IssueKeyChallenge returns a single-use challenge for registering provider
+// id with pubkey, which is empty for a did:key or did:web ID proving a key
+// its DID resolves to.
+func (r *Registry) IssueKeyChallenge(ctx context.Context, id, pubkey string) (*KeyChallenge, error) {
+	if id == "" {
+		return nil, fmt.Errorf("provider id is required")
+	}
+	if err := checkProviderKey(&Provider{ID: id, PubKey: pubkey}); err != nil {
+		return nil, err
+	}
+	if pubkey != "" {
+		if _, err := parsePubKey(pubkey); err != nil {
+			return nil, err
+		}
+	}
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate challenge: %w", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &KeyChallenge{
+		Challenge:  base64.RawURLEncoding.EncodeToString(nonce),
+		ProviderID: id,
+		PubKey:     pubkey,
+		ExpiresAt:  now.Add(KeyChallengeTTL),
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM key_challenges WHERE expires_at <= ?", now.Unix()); err != nil {
+		return nil, fmt.Errorf("purge key challenges: %w", err)
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO key_challenges (challenge, provider_id, pubkey, namespace, expires_at) VALUES (?, ?, ?, ?, ?)
+	`, c.Challenge, id, pubkey, NamespaceFrom(ctx), c.ExpiresAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert key challenge: %w", err)
+	}
+	return c, nil
+}
+
+// ProveKey checks that signature is the base64 Ed25519 signature of
+// challenge, issued by IssueKeyChallenge for the ID and pubkey of p, by the
+// pubkey or, when p has none, by a key its DID resolves to. The challenge
+// is used up either way.
+func (r *Registry) ProveKey(ctx context.Context, p *Provider, challenge, signature string) error {
+	if challenge == "" || signature == "" {
+		return fmt.Errorf("%w: challenge and challenge_signature are required", ErrInvalidKeyProof)
+	}
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM key_challenges
+		WHERE challenge = ? AND provider_id = ? AND pubkey = ? AND namespace = ? AND expires_at > ?
+	`, challenge, p.ID, p.PubKey, NamespaceFrom(ctx), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("use key challenge: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: unknown or expired challenge for %s and this pubkey", ErrInvalidKeyProof, p.ID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: challenge_signature must be base64", ErrInvalidKeyProof)
+	}
+	var keys []ed25519.PublicKey
+	switch {
+	case p.PubKey != "":
+		key, err := parsePubKey(p.PubKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	case did.Resolvable(p.ID):
+		if keys, err = r.dids.Keys(ctx, p.ID); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidKeyProof, err)
+		}
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, []byte(challenge), sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature does not verify against the key of %s", ErrInvalidKeyProof, p.ID)
+}
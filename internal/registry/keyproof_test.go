@@ -0,0 +1,68 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProveKey(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	p := &registry.Provider{ID: "did:claw:agent:p", Endpoint: "grpc://localhost:50051", PubKey: "ed25519:" + hex.EncodeToString(pub)}
+	sign := func(c *registry.KeyChallenge, k ed25519.PrivateKey) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(k, []byte(c.Challenge)))
+	}
+
+	c, err := r.IssueKeyChallenge(ctx, p.ID, p.PubKey)
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, c.ProviderID)
+	assert.WithinDuration(t, time.Now().Add(registry.KeyChallengeTTL), c.ExpiresAt, 2*time.Second)
+	require.NoError(t, r.ProveKey(ctx, p, c.Challenge, sign(c, key)))
+	assert.ErrorIs(t, r.ProveKey(ctx, p, c.Challenge, sign(c, key)), registry.ErrInvalidKeyProof, "challenges are single-use")
+
+	// Someone else's pubkey cannot be proven without its private key.
+	_, squatter, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	c, err = r.IssueKeyChallenge(ctx, p.ID, p.PubKey)
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.ProveKey(ctx, p, c.Challenge, sign(c, squatter)), registry.ErrInvalidKeyProof)
+	assert.ErrorIs(t, r.ProveKey(ctx, p, c.Challenge, sign(c, key)), registry.ErrInvalidKeyProof,
+		"a failed proof uses up the challenge")
+
+	// A challenge is bound to the ID, pubkey and namespace it was issued for.
+	c, err = r.IssueKeyChallenge(ctx, p.ID, p.PubKey)
+	require.NoError(t, err)
+	other := *p
+	other.ID = "did:claw:agent:other"
+	assert.ErrorIs(t, r.ProveKey(ctx, &other, c.Challenge, sign(c, key)), registry.ErrInvalidKeyProof)
+	assert.ErrorIs(t, r.ProveKey(registry.WithNamespace(ctx, "other"), p, c.Challenge, sign(c, key)), registry.ErrInvalidKeyProof)
+	require.NoError(t, r.ProveKey(ctx, p, c.Challenge, sign(c, key)))
+
+	assert.ErrorIs(t, r.ProveKey(ctx, p, "", ""), registry.ErrInvalidKeyProof)
+	_, err = r.IssueKeyChallenge(ctx, p.ID, "ed25519:nothex")
+	assert.Error(t, err)
+	_, err = r.IssueKeyChallenge(ctx, "", p.PubKey)
+	assert.Error(t, err)
+}
+
+func TestProveKey_DIDKey(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	p := &registry.Provider{ID: did.DIDKey(pub), Endpoint: "https://p.example"}
+	c, err := r.IssueKeyChallenge(ctx, p.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, r.ProveKey(ctx, p, c.Challenge, base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(c.Challenge)))))
+}
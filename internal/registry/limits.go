@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned for schemas, descriptions or inputs that are
+// larger or more deeply nested than the registry accepts.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bounds client-supplied JSON so pathological payloads cannot tie up
+// the store or its full-text index. A zero field disables that check.
+type Limits struct {
+	MaxSchemaBytes      int // each of a tool's input and output schemas
+	MaxDescriptionBytes int
+	MaxTags             int
+	MaxDepth            int // JSON nesting depth of schemas and inputs
+	MaxInputBytes       int // invocation input
+	MaxPayloadBytes     int // each of a stored invocation input and output
+}
+
+// DefaultLimits are the limits of a Registry created without WithLimits.
+var DefaultLimits = Limits{
+	MaxSchemaBytes:      64 << 10,
+	MaxDescriptionBytes: 4 << 10,
+	MaxTags:             32,
+	MaxDepth:            32,
+	MaxInputBytes:       1 << 20,
+	MaxPayloadBytes:     1 << 20,
+}
+
+// WithLimits replaces DefaultLimits.
+func WithLimits(l Limits) Option {
+	return func(r *Registry) { r.limits = l }
+}
+
+// Limits returns the limits the registry enforces.
+func (r *Registry) Limits() Limits {
+	return r.limits
+}
+
+// checkTool enforces the limits on a registration request.
+func (l Limits) checkTool(req *RegisterToolRequest) error {
+	if l.MaxDescriptionBytes > 0 && len(req.Description) > l.MaxDescriptionBytes {
+		return fmt.Errorf("%w: description is %d bytes, max %d", ErrLimitExceeded, len(req.Description), l.MaxDescriptionBytes)
+	}
+	for lang, d := range req.Descriptions {
+		if l.MaxDescriptionBytes > 0 && len(d) > l.MaxDescriptionBytes {
+			return fmt.Errorf("%w: description in %s is %d bytes, max %d", ErrLimitExceeded, lang, len(d), l.MaxDescriptionBytes)
+		}
+	}
+	if l.MaxTags > 0 && len(req.Tags) > l.MaxTags {
+		return fmt.Errorf("%w: %d tags, max %d", ErrLimitExceeded, len(req.Tags), l.MaxTags)
+	}
+	if err := l.checkJSON("input schema", req.Schema.Input, l.MaxSchemaBytes); err != nil {
+		return err
+	}
+	return l.checkJSON("output schema", req.Schema.Output, l.MaxSchemaBytes)
+}
+
+// CheckInput enforces the size and depth limits on a raw invocation input.
+func (l Limits) CheckInput(raw []byte) error {
+	return l.checkJSON("input", raw, l.MaxInputBytes)
+}
+
+func (l Limits) checkJSON(what string, raw []byte, maxBytes int) error {
+	if maxBytes > 0 && len(raw) > maxBytes {
+		return fmt.Errorf("%w: %s is %d bytes, max %d", ErrLimitExceeded, what, len(raw), maxBytes)
+	}
+	if l.MaxDepth > 0 {
+		if d := jsonDepth(raw); d > l.MaxDepth {
+			return fmt.Errorf("%w: %s nests %d levels deep, max %d", ErrLimitExceeded, what, d, l.MaxDepth)
+		}
+	}
+	return nil
+}
+
+// jsonDepth returns the maximum object/array nesting depth of a JSON
+// document without decoding it. Brackets inside strings are ignored.
+func jsonDepth(raw []byte) int {
+	depth, maxDepth := 0, 0
+	inString, escaped := false, false
+	for _, c := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return maxDepth
+}
@@ -0,0 +1,82 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func nested(depth int) string {
+	return strings.Repeat(`{"a":`, depth) + "1" + strings.Repeat("}", depth)
+}
+
+func TestRegisterTool_Limits(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	for name, mutate := range map[string]func(*registry.RegisterToolRequest){
+		"schema too large": func(req *registry.RegisterToolRequest) {
+			req.Schema.Input = []byte(`{"description":"` + strings.Repeat("x", 70<<10) + `"}`)
+		},
+		"schema too deep": func(req *registry.RegisterToolRequest) {
+			req.Schema.Output = []byte(nested(40))
+		},
+		"description too long": func(req *registry.RegisterToolRequest) {
+			req.Description = strings.Repeat("x", 5<<10)
+		},
+		"too many tags": func(req *registry.RegisterToolRequest) {
+			req.Tags = make([]string, 33)
+			for i := range req.Tags {
+				req.Tags[i] = fmt.Sprintf("tag-%d", i)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := validRegisterReq()
+			mutate(req)
+			_, err := r.RegisterTool(ctx, req)
+			assert.ErrorIs(t, err, registry.ErrLimitExceeded)
+		})
+	}
+}
+
+func TestRegisterTool_DepthIgnoresBracketsInStrings(t *testing.T) {
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Schema.Input = []byte(`{"pattern":"` + strings.Repeat(`[{\"`, 50) + `"}`)
+	_, err := r.RegisterTool(context.Background(), req)
+	assert.NoError(t, err)
+}
+
+func TestRecordInvocation_InputLimits(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithLimits(registry.Limits{
+		MaxDepth:      3,
+		MaxInputBytes: 64,
+	}))
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": map[string]any{"b": map[string]any{"c": map[string]any{}}}})
+	assert.ErrorIs(t, err, registry.ErrLimitExceeded)
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": strings.Repeat("x", 100)})
+	assert.ErrorIs(t, err, registry.ErrLimitExceeded)
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": map[string]any{"b": 1}})
+	assert.NoError(t, err)
+}
+
+func TestLimits_ZeroDisables(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithLimits(registry.Limits{}))
+	req := validRegisterReq()
+	req.Schema.Output = []byte(nested(100))
+	req.Description = strings.Repeat("x", 10<<10)
+	_, err := r.RegisterTool(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, registry.Limits{}, r.Limits())
+}
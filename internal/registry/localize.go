@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage is the language of the descriptions of tools registered
+// without one.
+const DefaultLanguage = "en"
+
+// MaxDescriptions bounds the translations of a tool's description.
+const MaxDescriptions = 32
+
+// ErrInvalidLanguage is returned for a registration whose language or a
+// translation's language is not a valid BCP 47 tag, or that has too many or
+// empty translations.
+var ErrInvalidLanguage = errors.New("invalid language")
+
+// validateLanguages canonicalizes the language of a registration and the
+// tags its translated descriptions are keyed by.
+func (r *RegisterToolRequest) validateLanguages() error {
+	if r.Language != "" {
+		tag, err := language.Parse(r.Language)
+		if err != nil {
+			return fmt.Errorf("%w: language %q: %v", ErrInvalidLanguage, r.Language, err)
+		}
+		r.Language = tag.String()
+	}
+	if len(r.Descriptions) > MaxDescriptions {
+		return fmt.Errorf("%w: at most %d descriptions", ErrInvalidLanguage, MaxDescriptions)
+	}
+	var descriptions map[string]string
+	for lang, d := range r.Descriptions {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return fmt.Errorf("%w: description language %q: %v", ErrInvalidLanguage, lang, err)
+		}
+		if strings.TrimSpace(d) == "" {
+			return fmt.Errorf("%w: description in %s is empty", ErrInvalidLanguage, tag)
+		}
+		if descriptions == nil {
+			descriptions = make(map[string]string, len(r.Descriptions))
+		}
+		descriptions[tag.String()] = d
+	}
+	r.Descriptions = descriptions
+	return nil
+}
+
+// Localize sets the tool's Description, and Language, to the description
+// that best matches accept, an Accept-Language header, among its own and its
+// translations. It keeps its own when none matches, and returns the
+// language of the description.
+func (t *Tool) Localize(accept string) string {
+	lang := t.Language
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+	if len(t.Descriptions) == 0 || accept == "" {
+		return lang
+	}
+	desired, _, err := language.ParseAcceptLanguage(accept)
+	if err != nil || len(desired) == 0 {
+		return lang
+	}
+	langs := make([]string, 0, len(t.Descriptions))
+	for l := range t.Descriptions {
+		if l != lang {
+			langs = append(langs, l)
+		}
+	}
+	slices.Sort(langs)
+	langs = append([]string{lang}, langs...)
+	supported := make([]language.Tag, len(langs))
+	for i, l := range langs {
+		supported[i] = language.Make(l)
+	}
+	_, i, confidence := language.NewMatcher(supported).Match(desired...)
+	if confidence == language.No || i == 0 {
+		return lang
+	}
+	t.Description, t.Language = t.Descriptions[langs[i]], langs[i]
+	return langs[i]
+}
+
+// encodeDescriptions returns the stored form of translated descriptions, ""
+// for none.
+func encodeDescriptions(d map[string]string) (string, error) {
+	if len(d) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("marshal descriptions: %w", err)
+	}
+	return string(b), nil
+}
+
+func decodeDescriptions(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var d map[string]string
+	if err := json.Unmarshal([]byte(s), &d); err != nil {
+		return nil, fmt.Errorf("unmarshal descriptions: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,55 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolLocalize(t *testing.T) {
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Descriptions = map[string]string{"de-de": "Ein Testwerkzeug", "fr": "Un outil de test", "pt-BR": "Uma ferramenta"}
+	tool, err := r.RegisterTool(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, tool.Language)
+	assert.Equal(t, map[string]string{"de-DE": "Ein Testwerkzeug", "fr": "Un outil de test", "pt-BR": "Uma ferramenta"},
+		tool.Descriptions, "tags are canonical")
+
+	for accept, want := range map[string]struct{ lang, description string }{
+		"":                          {"en", "A test tool"},
+		"de":                        {"de-DE", "Ein Testwerkzeug"},
+		"fr-CH, fr;q=0.9, en;q=0.8": {"fr", "Un outil de test"},
+		"ja, en;q=0.5":              {"en", "A test tool"},
+		"ja":                        {"en", "A test tool"},
+		"pt":                        {"pt-BR", "Uma ferramenta"},
+		"not a language!":           {"en", "A test tool"},
+	} {
+		got, err := r.GetTool(context.Background(), tool.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want.lang, got.Localize(accept), accept)
+		assert.Equal(t, want.description, got.Description, accept)
+	}
+
+	plain := validRegisterReq()
+	plainHash, err := registry.ManifestHash(plain)
+	require.NoError(t, err)
+	assert.NotEqual(t, plainHash, tool.ManifestHash, "translations are part of the manifest")
+
+	for name, descriptions := range map[string]map[string]string{
+		"bad tag": {"klingon!": "x"},
+		"empty":   {"de": " "},
+	} {
+		req := validRegisterReq()
+		req.Version, req.Descriptions = "2.0.0", descriptions
+		_, err := r.RegisterTool(context.Background(), req)
+		assert.ErrorIs(t, err, registry.ErrInvalidLanguage, name)
+	}
+	req = validRegisterReq()
+	req.Version, req.Language = "2.0.0", "english"
+	_, err = r.RegisterTool(context.Background(), req)
+	assert.ErrorIs(t, err, registry.ErrInvalidLanguage)
+}
@@ -0,0 +1,189 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a manifest hash or signature does not
+// match the registration, or a required signature is missing.
+var ErrInvalidSignature = errors.New("invalid manifest signature")
+
+// WithSignedManifests makes a valid provider signature mandatory on every
+// tool registration. Signatures that are supplied are always verified.
+func WithSignedManifests(required bool) Option {
+	return func(r *Registry) { r.requireSigned = required }
+}
+
+// manifest is the signed content of a tool version. Field order is fixed and
+// schemas are re-encoded with sorted keys, so equal manifests hash equally
+// regardless of how the client formatted them.
+type manifest struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	ProviderID  string   `json:"provider_id"`
+	Description string   `json:"description"`
+	Endpoint    string   `json:"endpoint"`
+	Input       any      `json:"input"`
+	Output      any      `json:"output"`
+	Pricing     *Pricing `json:"pricing"`
+	Tags        []string `json:"tags"`
+	TimeoutMS   int64    `json:"timeout_ms"`
+	// Cache is omitted when unset, so manifests of tools that do not
+	// declare caching hash as they did before it existed.
+	Cache *CachePolicy `json:"cache,omitempty"`
+	SLA   *SLA         `json:"sla,omitempty"`
+	// Endpoints and Routing are omitted for tools with one endpoint.
+	Endpoints []string     `json:"endpoints,omitempty"`
+	Routing   string       `json:"routing,omitempty"`
+	Retry     *RetryPolicy `json:"retry,omitempty"`
+	Mock      *Mock        `json:"mock,omitempty"`
+	Tests     []ToolTest   `json:"tests,omitempty"`
+	// Features lists the declared streaming and batch features, the others
+	// being part of Cache.
+	Features []string `json:"features,omitempty"`
+	// Language and Descriptions are omitted for tools described in one
+	// language.
+	Language     string            `json:"language,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	// Nonce and SignedAt are omitted for registrations without a nonce.
+	Nonce    string `json:"nonce,omitempty"`
+	SignedAt string `json:"signed_at,omitempty"`
+}
+
+// ManifestHash returns "sha256:<hex>" over the canonical manifest of a
+// registration: name, version, provider, description, endpoint, schemas,
+// pricing, tags, timeout, cache policy, SLA, further endpoints, retry
+// policy, mock responder, conformance tests, features, description translations and nonce,
+// after defaults are applied.
+// Providers sign this string with their Ed25519 key.
+func ManifestHash(req *RegisterToolRequest) (string, error) {
+	b, err := ManifestDocument(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ManifestDocument returns the canonical manifest of a registration, the
+// bytes ManifestHash hashes.
+func ManifestDocument(req *RegisterToolRequest) ([]byte, error) {
+	c := *req
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	m := manifest{
+		Name:         c.Name,
+		Version:      c.Version,
+		ProviderID:   c.ProviderID,
+		Description:  c.Description,
+		Endpoint:     c.Endpoint,
+		Pricing:      c.Pricing,
+		Tags:         c.Tags,
+		TimeoutMS:    c.TimeoutMS,
+		Cache:        c.Cache,
+		SLA:          c.SLA,
+		Endpoints:    c.Endpoints,
+		Routing:      c.Routing,
+		Retry:        c.Retry,
+		Mock:         c.Mock,
+		Tests:        c.Tests,
+		Features:     c.Features,
+		Language:     c.Language,
+		Descriptions: c.Descriptions,
+		Nonce:        c.Nonce,
+	}
+	if c.SignedAt != nil {
+		m.SignedAt = c.SignedAt.UTC().Format(time.RFC3339)
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	if err := json.Unmarshal(c.Schema.Input, &m.Input); err != nil {
+		return nil, fmt.Errorf("input schema: %w", err)
+	}
+	if len(c.Schema.Output) > 0 {
+		if err := json.Unmarshal(c.Schema.Output, &m.Output); err != nil {
+			return nil, fmt.Errorf("output schema: %w", err)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// verifyManifest computes the manifest hash of req and checks it against the
+// hash and signature the provider supplied, then claims the nonce of a
+// signed manifest.
+func (r *Registry) verifyManifest(ctx context.Context, req *RegisterToolRequest) (string, error) {
+	hash, err := ManifestHash(req)
+	if err != nil {
+		return "", err
+	}
+	if req.ManifestHash != "" && req.ManifestHash != hash {
+		return "", fmt.Errorf("%w: manifest_hash %s does not match the registration (%s)",
+			ErrInvalidSignature, req.ManifestHash, hash)
+	}
+	if req.ManifestSignature == "" {
+		if r.requireSigned {
+			return "", fmt.Errorf("%w: manifest_signature is required", ErrInvalidSignature)
+		}
+		return hash, nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(req.ManifestSignature)
+	if err != nil {
+		return "", fmt.Errorf("%w: manifest_signature must be base64", ErrInvalidSignature)
+	}
+	keys, err := r.VerificationKeys(ctx, req.ProviderID)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("%w: provider %s has no registered pubkey", ErrInvalidSignature, req.ProviderID)
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, []byte(hash), sig) {
+			return hash, r.claimManifestNonce(ctx, req)
+		}
+	}
+	return "", fmt.Errorf("%w: signature does not verify against provider %s", ErrInvalidSignature, req.ProviderID)
+}
+
+// claimManifestNonce claims the nonce of the signed registration req, which
+// must have one when nonces are required.
+func (r *Registry) claimManifestNonce(ctx context.Context, req *RegisterToolRequest) error {
+	if req.Nonce == "" {
+		if r.requireNonces {
+			return fmt.Errorf("%w: nonce is required", ErrInvalidSignature)
+		}
+		return nil
+	}
+	var signedAt time.Time
+	if req.SignedAt != nil {
+		signedAt = *req.SignedAt
+	}
+	if err := checkNonce(req.Nonce); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if err := checkSignedAt(signedAt); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return r.ClaimNonce(ctx, req.ProviderID, req.Nonce, signedAt)
+}
+
+// parsePubKey decodes a provider key of the form "ed25519:<hex>".
+func parsePubKey(s string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(s, "ed25519:"))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("provider pubkey must be ed25519:<%d hex bytes>", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
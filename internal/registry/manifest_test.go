@@ -0,0 +1,188 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// registerSigner registers the test provider with a fresh Ed25519 key.
+func registerSigner(t *testing.T, r *registry.Registry) ed25519.PrivateKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = r.RegisterProvider(context.Background(), &registry.Provider{
+		ID:       validRegisterReq().ProviderID,
+		Endpoint: "grpc://localhost:50051",
+		PubKey:   "ed25519:" + hex.EncodeToString(pub),
+	})
+	require.NoError(t, err)
+	return priv
+}
+
+func sign(t *testing.T, key ed25519.PrivateKey, req *registry.RegisterToolRequest) {
+	t.Helper()
+	hash, err := registry.ManifestHash(req)
+	require.NoError(t, err)
+	req.ManifestHash = hash
+	req.ManifestSignature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(hash)))
+}
+
+func TestManifestHash_Canonical(t *testing.T) {
+	a := validRegisterReq()
+	b := validRegisterReq()
+	b.Schema.Input = []byte(`{ "properties": {"input": {"type": "string"}}, "type": "object" }`)
+	ha, err := registry.ManifestHash(a)
+	require.NoError(t, err)
+	hb, err := registry.ManifestHash(b)
+	require.NoError(t, err)
+	assert.Equal(t, ha, hb, "formatting and key order must not change the hash")
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, ha)
+
+	b.Pricing.AmountCLAW = "6.0"
+	hb, err = registry.ManifestHash(b)
+	require.NoError(t, err)
+	assert.NotEqual(t, ha, hb)
+}
+
+func TestRegisterTool_SignedManifest(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithSignedManifests(true))
+	ctx := context.Background()
+	key := registerSigner(t, r)
+
+	_, err := r.RegisterTool(ctx, validRegisterReq())
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature, "signature required")
+
+	req := validRegisterReq()
+	sign(t, key, req)
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req.ManifestHash, tool.ManifestHash)
+	assert.Equal(t, req.ManifestSignature, tool.ManifestSignature)
+
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tool.ManifestHash, got.ManifestHash)
+}
+
+func TestRegisterTool_ManifestRejected(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	// Unsigned registrations are accepted but still hashed.
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	assert.NotEmpty(t, tool.ManifestHash)
+	assert.Empty(t, tool.ManifestSignature)
+
+	req := validRegisterReq()
+	req.Version = "2.0.0"
+	req.ManifestSignature = base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorContains(t, err, "no registered pubkey")
+
+	key := registerSigner(t, r)
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	sign(t, key, req)
+	req.Description = "changed after signing"
+	req.ManifestHash = ""
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorContains(t, err, "does not verify")
+
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	req.ManifestHash = "sha256:00"
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	req.ManifestSignature = "not base64!"
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+}
+
+func TestRegisterTool_DIDKeyProvider(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id := did.DIDKey(pub)
+
+	// A did:key identity carries its key; a contradicting pubkey is refused.
+	other, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = r.RegisterProvider(ctx, &registry.Provider{
+		ID: id, Endpoint: "https://p.example", PubKey: "ed25519:" + hex.EncodeToString(other),
+	})
+	assert.ErrorContains(t, err, "does not match")
+	_, err = r.RegisterProvider(ctx, &registry.Provider{ID: "did:key:zBAD", Endpoint: "https://p.example"})
+	assert.Error(t, err)
+	_, err = r.RegisterProvider(ctx, &registry.Provider{ID: id, Endpoint: "https://p.example"})
+	require.NoError(t, err)
+
+	req := validRegisterReq()
+	req.ProviderID = id
+	sign(t, key, req)
+	_, err = r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+
+	keys, err := r.VerificationKeys(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{pub}, keys)
+
+	req = validRegisterReq()
+	req.ProviderID = id
+	req.Version = "2.0.0"
+	sign(t, otherKey, req)
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+}
+
+// failingStore is a content store that is down.
+type failingStore struct{}
+
+func (failingStore) Put(context.Context, []byte) (string, error) { return "", errors.New("ipfs down") }
+func (failingStore) Get(context.Context, string) ([]byte, error) { return nil, errors.New("ipfs down") }
+
+func TestRegisterTool_PinsManifest(t *testing.T) {
+	ctx := context.Background()
+	pins, err := cas.NewDir(t.TempDir())
+	require.NoError(t, err)
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithCAS(pins))
+
+	req := validRegisterReq()
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	require.NotEmpty(t, tool.ManifestCID)
+
+	doc, err := pins.Get(ctx, tool.ManifestCID)
+	require.NoError(t, err)
+	want, err := registry.ManifestDocument(validRegisterReq())
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(doc))
+	sum := sha256.Sum256(doc)
+	assert.Equal(t, tool.ManifestHash, "sha256:"+hex.EncodeToString(sum[:]), "the pinned document is what the hash covers")
+
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tool.ManifestCID, got.ManifestCID)
+
+	// An unavailable store does not block registrations.
+	r = registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithCAS(failingStore{}))
+	tool, err = r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	assert.Empty(t, tool.ManifestCID)
+}
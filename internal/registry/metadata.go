@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Bounds of invocation metadata.
+const (
+	maxMetadataKeys  = 16
+	maxMetadataKey   = 64
+	maxMetadataValue = 256
+)
+
+// ErrInvalidMetadata is returned for invocation metadata with too many
+// entries, a malformed key or a value that is too long.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// metadataKey is what metadata keys may look like, so they can be matched
+// in a JSON path without quoting.
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Metadata is opaque context a consumer attaches to an invocation, such as
+// a task ID, a parent trace or a session, to correlate invocations across
+// agents. The registry stores and returns it but never interprets it.
+type Metadata map[string]string
+
+// Validate checks that m has at most 16 entries, keys of letters, digits,
+// '_', '.' and '-' of up to 64 bytes and values of up to 256 bytes.
+func (m Metadata) Validate() error {
+	if len(m) > maxMetadataKeys {
+		return fmt.Errorf("%w: at most %d entries", ErrInvalidMetadata, maxMetadataKeys)
+	}
+	for k, v := range m {
+		if err := checkMetadataKey(k); err != nil {
+			return err
+		}
+		if len(v) > maxMetadataValue {
+			return fmt.Errorf("%w: value of %q is longer than %d bytes", ErrInvalidMetadata, k, maxMetadataValue)
+		}
+	}
+	return nil
+}
+
+func checkMetadataKey(k string) error {
+	if len(k) > maxMetadataKey || !metadataKey.MatchString(k) {
+		return fmt.Errorf("%w: key %q must be 1 to %d letters, digits, '_', '.' or '-'", ErrInvalidMetadata, k, maxMetadataKey)
+	}
+	return nil
+}
+
+type metadataKeyKey struct{}
+
+// WithMetadata marks ctx so the invocation recorded with it holds m.
+func WithMetadata(ctx context.Context, m Metadata) context.Context {
+	if len(m) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, metadataKeyKey{}, m)
+}
+
+// MetadataFrom returns the metadata ctx was marked with by WithMetadata.
+func MetadataFrom(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataKeyKey{}).(Metadata)
+	return m
+}
+
+// encodeMetadata returns the column value of m, NULL when empty.
+func encodeMetadata(m Metadata) (any, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshal metadata: %w", err)
+	}
+	return string(b), nil
+}
+
+func decodeMetadata(s string) (Metadata, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var m Metadata
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("unmarshal metadata: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package registry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestInvocationMetadata(t *testing.T) {
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(context.Background(), validRegisterReq())
+	require.NoError(t, err)
+	record := func(m registry.Metadata) string {
+		id, err := r.RecordInvocation(registry.WithMetadata(context.Background(), m), tool.ID, "consumer-a", map[string]any{})
+		require.NoError(t, err)
+		return id
+	}
+	first := record(registry.Metadata{"task_id": "t1", "trace": "abc"})
+	second := record(registry.Metadata{"task_id": "t1"})
+	record(registry.Metadata{"task_id": "t2"})
+	record(nil)
+
+	inv, err := r.GetInvocation(context.Background(), first)
+	require.NoError(t, err)
+	assert.Equal(t, registry.Metadata{"task_id": "t1", "trace": "abc"}, inv.Metadata)
+
+	ids := func(f registry.InvocationFilter) []string {
+		f.ConsumerID = "consumer-a"
+		list, err := r.ListInvocations(context.Background(), f, "", 0)
+		require.NoError(t, err)
+		var out []string
+		for _, inv := range list.Invocations {
+			out = append(out, inv.ID)
+		}
+		return out
+	}
+	assert.Len(t, ids(registry.InvocationFilter{}), 4)
+	assert.ElementsMatch(t, []string{first, second}, ids(registry.InvocationFilter{Metadata: registry.Metadata{"task_id": "t1"}}))
+	assert.Equal(t, []string{first}, ids(registry.InvocationFilter{Metadata: registry.Metadata{"task_id": "t1", "trace": "abc"}}))
+	assert.Empty(t, ids(registry.InvocationFilter{Metadata: registry.Metadata{"session": "s"}}))
+
+	_, err = r.ListInvocations(context.Background(),
+		registry.InvocationFilter{ConsumerID: "consumer-a", Metadata: registry.Metadata{`a"b`: "x"}}, "", 0)
+	assert.ErrorIs(t, err, registry.ErrInvalidMetadata)
+}
+
+func TestInvocationMetadata_Batched(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithInvocationBatching())
+	tool, err := r.RegisterTool(context.Background(), validRegisterReq())
+	require.NoError(t, err)
+	ctx := registry.WithMetadata(context.Background(), registry.Metadata{"session": "s1"})
+	id, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", map[string]any{})
+	require.NoError(t, err)
+
+	inv, err := r.GetInvocation(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, registry.Metadata{"session": "s1"}, inv.Metadata)
+}
+
+func TestMetadata_Validate(t *testing.T) {
+	assert.NoError(t, registry.Metadata{"task_id": "t1", "parent.trace-id": "x"}.Validate())
+	assert.ErrorIs(t, registry.Metadata{"": "x"}.Validate(), registry.ErrInvalidMetadata)
+	assert.ErrorIs(t, registry.Metadata{"a b": "x"}.Validate(), registry.ErrInvalidMetadata)
+	assert.ErrorIs(t, registry.Metadata{"k": strings.Repeat("x", 257)}.Validate(), registry.ErrInvalidMetadata)
+	many := registry.Metadata{}
+	for i := 0; i < 17; i++ {
+		many[strings.Repeat("k", i+1)] = "v"
+	}
+	assert.ErrorIs(t, many.Validate(), registry.ErrInvalidMetadata)
+}
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+)
+
+// Bounds of a mock responder.
+const (
+	maxMockResponses = 32
+	maxMockBytes     = 64 << 10
+)
+
+var (
+	// ErrInvalidMock is returned for a mock responder without responses
+	// or template, or that is too large.
+	ErrInvalidMock = errors.New("invalid mock")
+
+	// ErrNoMock is returned for a mock invocation of a tool registered
+	// without a mock responder, or whose responder has no answer for the
+	// input.
+	ErrNoMock = errors.New("no mock response")
+)
+
+// Mock is a responder a tool may be registered with, for consumers to
+// integration-test against free of charge: invocations asking for
+// "mock": true get its output without the provider being called.
+type Mock struct {
+	// Responses are canned outputs; the first whose When matches the input
+	// is returned.
+	Responses []MockResponse `json:"responses,omitempty"`
+	// Template is the output for inputs no response matches. Strings in it
+	// of the form "{{input.path}}" are replaced by the value at that path
+	// of the input, and such placeholders within longer strings by its
+	// text.
+	Template map[string]any `json:"template,omitempty"`
+}
+
+// MockResponse is a canned output of a Mock.
+type MockResponse struct {
+	// When holds the input fields the response answers, by top-level
+	// name; an empty When matches any input.
+	When   map[string]any `json:"when,omitempty"`
+	Output map[string]any `json:"output"`
+}
+
+// mockPlaceholder matches a template placeholder, its path captured.
+var mockPlaceholder = regexp.MustCompile(`\{\{\s*input((?:\.[A-Za-z0-9_-]+)*)\s*\}\}`)
+
+func (m *Mock) validate() error {
+	if len(m.Responses) == 0 && m.Template == nil {
+		return fmt.Errorf("%w: responses or template is required", ErrInvalidMock)
+	}
+	if len(m.Responses) > maxMockResponses {
+		return fmt.Errorf("%w: at most %d responses", ErrInvalidMock, maxMockResponses)
+	}
+	for i, resp := range m.Responses {
+		if resp.Output == nil {
+			return fmt.Errorf("%w: response %d has no output", ErrInvalidMock, i)
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMock, err)
+	}
+	if len(b) > maxMockBytes {
+		return fmt.Errorf("%w: mock is %d bytes, max %d", ErrInvalidMock, len(b), maxMockBytes)
+	}
+	return nil
+}
+
+// Respond returns the output of the mock for input: that of the first
+// response whose When matches it, or else the template filled in from it.
+// It returns ErrNoMock when nothing answers the input.
+func (m *Mock) Respond(input map[string]any) (map[string]any, error) {
+	for _, resp := range m.Responses {
+		if mockMatches(resp.When, input) {
+			return resp.Output, nil
+		}
+	}
+	if m.Template == nil {
+		return nil, fmt.Errorf("%w: no response matches the input", ErrNoMock)
+	}
+	return fillTemplate(m.Template, input).(map[string]any), nil
+}
+
+// mockMatches reports whether every field of when equals that of input.
+// Both come decoded from JSON, so equal values have equal types.
+func mockMatches(when, input map[string]any) bool {
+	for k, v := range when {
+		got, ok := input[k]
+		if !ok || !reflect.DeepEqual(got, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// fillTemplate returns a copy of v with its placeholders replaced from
+// input.
+func fillTemplate(v any, input map[string]any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = fillTemplate(e, input)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = fillTemplate(e, input)
+		}
+		return out
+	case string:
+		if m := mockPlaceholder.FindStringSubmatch(v); m != nil && m[0] == v {
+			return inputAt(input, m[1])
+		}
+		return mockPlaceholder.ReplaceAllStringFunc(v, func(p string) string {
+			switch x := inputAt(input, mockPlaceholder.FindStringSubmatch(p)[1]).(type) {
+			case nil:
+				return ""
+			case string:
+				return x
+			default:
+				b, _ := json.Marshal(x)
+				return string(b)
+			}
+		})
+	}
+	return v
+}
+
+// inputAt returns the value at path, ".a.b", of input, or nil when there
+// is none. The empty path is the whole input.
+func inputAt(input map[string]any, path string) any {
+	var v any = input
+	for _, k := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if k == "" {
+			continue
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[k]
+	}
+	return v
+}
+
+// encodeMock returns the stored form of a mock responder, "" for none.
+func encodeMock(m *Mock) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("marshal mock: %w", err)
+	}
+	return string(b), nil
+}
+
+// decodeMock reads the mock column of a tool, empty for tools without a
+// mock responder.
+func decodeMock(s string) (*Mock, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var m Mock
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("unmarshal mock: %w", err)
+	}
+	return &m, nil
+}
+
+// CompleteMockInvocation completes an invocation answered by its tool's
+// mock responder, free of charge, and stores its receipt, marked mock.
+func (r *Registry) CompleteMockInvocation(ctx context.Context, id, outputHash string) error {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET status = 'completed', output_hash = ?, cost_claw = '', completed_at = ?, mock = 1
+		WHERE id = ?
+	`, outputHash, time.Now().Unix(), id)
+	if err != nil {
+		return err
+	}
+	if err := r.writeReceipt(ctx, id); err != nil {
+		return err
+	}
+	r.publishInvocation(ctx, events.InvocationCompleted, id)
+	return nil
+}
@@ -0,0 +1,99 @@
+package registry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMock_Respond(t *testing.T) {
+	m := &registry.Mock{
+		Responses: []registry.MockResponse{
+			{When: map[string]any{"city": "Paris", "units": "c"}, Output: map[string]any{"temp": float64(21)}},
+			{When: map[string]any{"city": "Paris"}, Output: map[string]any{"temp": float64(70)}},
+		},
+		Template: map[string]any{
+			"city":    "{{input.city}}",
+			"where":   []any{"{{ input.geo.lat }}"},
+			"summary": "sunny in {{input.city}} at {{input.geo}}",
+			"echo":    "{{input}}",
+		},
+	}
+	out, err := m.Respond(map[string]any{"city": "Paris", "units": "c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"temp": float64(21)}, out)
+	out, err = m.Respond(map[string]any{"city": "Paris", "units": "f"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"temp": float64(70)}, out, "the first matching response wins")
+
+	input := map[string]any{"city": "Oslo", "geo": map[string]any{"lat": 59.9}}
+	out, err = m.Respond(input)
+	require.NoError(t, err)
+	assert.Equal(t, "Oslo", out["city"])
+	assert.Equal(t, []any{59.9}, out["where"], "whole-string placeholders keep the type of the value")
+	assert.Equal(t, `sunny in Oslo at {"lat":59.9}`, out["summary"])
+	assert.Equal(t, input, out["echo"])
+	assert.Equal(t, "{{input.city}}", m.Template["city"], "the template is not modified")
+
+	m.Template = nil
+	_, err = m.Respond(input)
+	assert.ErrorIs(t, err, registry.ErrNoMock)
+}
+
+func TestRegisterTool_Mock(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Mock = &registry.Mock{Template: map[string]any{"output": "mocked {{input.input}}"}}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, req.Mock, got.Mock)
+
+	withoutMock := validRegisterReq()
+	hash, err := registry.ManifestHash(withoutMock)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, tool.ManifestHash, "the mock is part of the manifest")
+
+	responses := make([]registry.MockResponse, 33)
+	for i := range responses {
+		responses[i] = registry.MockResponse{Output: map[string]any{}}
+	}
+	for name, m := range map[string]*registry.Mock{
+		"empty":     {},
+		"no output": {Responses: []registry.MockResponse{{When: map[string]any{"a": 1.0}}}},
+		"too many":  {Responses: responses},
+		"too large": {Template: map[string]any{"s": strings.Repeat("x", 64<<10)}},
+	} {
+		req := validRegisterReq()
+		req.Name, req.Mock = "bad-"+strings.ReplaceAll(name, " ", "-"), m
+		_, err := r.RegisterTool(ctx, req)
+		assert.ErrorIs(t, err, registry.ErrInvalidMock, name)
+	}
+}
+
+func TestCompleteMockInvocation(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	id, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:c", map[string]any{"input": "x"})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteMockInvocation(ctx, id, registry.HashPayload(map[string]any{"output": "y"})))
+
+	inv, err := r.GetInvocation(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", inv.Status)
+	assert.True(t, inv.Mock)
+	assert.Empty(t, inv.CostCLAW, "mock invocations are free")
+
+	rcpt, err := r.InvocationReceipt(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, rcpt.Mock)
+	assert.Empty(t, rcpt.CostCLAW)
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultNamespace is used when a request does not name a namespace. It is
+// open to every caller, which keeps single-tenant deployments unchanged.
+const DefaultNamespace = "default"
+
+// Namespace member roles.
+const (
+	RoleOwner  = "owner"
+	RoleMember = "member"
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ErrInvalidNamespace is returned for malformed namespace names.
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+type namespaceKey struct{}
+
+// WithNamespace returns a context scoping registry operations to ns.
+func WithNamespace(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, ns)
+}
+
+// NamespaceFrom returns the namespace carried by ctx, or DefaultNamespace.
+func NamespaceFrom(ctx context.Context) string {
+	if ns, ok := ctx.Value(namespaceKey{}).(string); ok && ns != "" {
+		return ns
+	}
+	return DefaultNamespace
+}
+
+// ValidateNamespace checks that ns is a lowercase DNS-label-style name.
+func ValidateNamespace(ns string) error {
+	if !namespacePattern.MatchString(ns) {
+		return fmt.Errorf("%w %q: use 1-63 lowercase letters, digits or dashes", ErrInvalidNamespace, ns)
+	}
+	return nil
+}
+
+// NamespaceMember is an identity allowed to act within a namespace.
+type NamespaceMember struct {
+	CreatedAt time.Time `json:"created_at"`
+	Namespace string    `json:"namespace"`
+	MemberID  string    `json:"member_id"`
+	Role      string    `json:"role"`
+}
+
+// CreateNamespace creates ns with ownerID as its owner.
+func (r *Registry) CreateNamespace(ctx context.Context, ns, ownerID string) (*NamespaceMember, error) {
+	if err := ValidateNamespace(ns); err != nil {
+		return nil, err
+	}
+	if ns == DefaultNamespace {
+		return nil, fmt.Errorf("%w: namespace %s", ErrDuplicate, ns)
+	}
+	var exists int
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM namespace_members WHERE namespace = ?", ns).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check namespace: %w", err)
+	}
+	if exists > 0 {
+		return nil, fmt.Errorf("%w: namespace %s", ErrDuplicate, ns)
+	}
+	m, err := r.addMember(ctx, ns, ownerID, RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	r.logger(ctx).Info("namespace created", zap.String("namespace", ns), zap.String("owner", ownerID))
+	return m, nil
+}
+
+// AddNamespaceMember grants memberID access to ns. Only owners may add members.
+func (r *Registry) AddNamespaceMember(ctx context.Context, ns, callerID, memberID string) (*NamespaceMember, error) {
+	role, err := r.memberRole(ctx, ns, callerID)
+	if err != nil {
+		return nil, err
+	}
+	if role != RoleOwner {
+		return nil, fmt.Errorf("%w: only namespace owners can add members", ErrForbidden)
+	}
+	return r.addMember(ctx, ns, memberID, RoleMember)
+}
+
+// ListNamespaceMembers returns the members of ns.
+func (r *Registry) ListNamespaceMembers(ctx context.Context, ns string) ([]*NamespaceMember, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT namespace, member_id, role, created_at FROM namespace_members
+		WHERE namespace = ? ORDER BY created_at, member_id
+	`, ns)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []*NamespaceMember
+	for rows.Next() {
+		var (
+			m         NamespaceMember
+			createdAt int64
+		)
+		if err := rows.Scan(&m.Namespace, &m.MemberID, &m.Role, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = time.Unix(createdAt, 0)
+		out = append(out, &m)
+	}
+	return out, rows.Err()
+}
+
+// AuthorizeNamespace checks that callerID may act within ns. Everyone may
+// use DefaultNamespace; other namespaces require membership.
+func (r *Registry) AuthorizeNamespace(ctx context.Context, ns, callerID string) error {
+	if err := ValidateNamespace(ns); err != nil {
+		return err
+	}
+	if ns == DefaultNamespace {
+		return nil
+	}
+	_, err := r.memberRole(ctx, ns, callerID)
+	return err
+}
+
+func (r *Registry) memberRole(ctx context.Context, ns, memberID string) (string, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT role FROM namespace_members WHERE namespace = ? AND member_id = ?", ns, memberID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w: %s is not a member of namespace %s", ErrForbidden, memberID, ns)
+		}
+		return "", fmt.Errorf("lookup member: %w", err)
+	}
+	return role, nil
+}
+
+func (r *Registry) addMember(ctx context.Context, ns, memberID, role string) (*NamespaceMember, error) {
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO namespace_members (namespace, member_id, role, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(namespace, member_id) DO NOTHING
+	`, ns, memberID, role, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("add member: %w", err)
+	}
+	return &NamespaceMember{Namespace: ns, MemberID: memberID, Role: role, CreatedAt: now}, nil
+}
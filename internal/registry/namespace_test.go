@@ -0,0 +1,123 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceFrom_Default(t *testing.T) {
+	assert.Equal(t, registry.DefaultNamespace, registry.NamespaceFrom(context.Background()))
+	ctx := registry.WithNamespace(context.Background(), "acme")
+	assert.Equal(t, "acme", registry.NamespaceFrom(ctx))
+}
+
+func TestValidateNamespace(t *testing.T) {
+	assert.NoError(t, registry.ValidateNamespace("acme-fleet-1"))
+	for _, bad := range []string{"", "Acme", "-acme", "has space", string(make([]byte, 64))} {
+		assert.ErrorIs(t, registry.ValidateNamespace(bad), registry.ErrInvalidNamespace, bad)
+	}
+}
+
+func TestNamespaces_IsolateTools(t *testing.T) {
+	r := newTestRegistry(t)
+	acme := registry.WithNamespace(context.Background(), "acme")
+	globex := registry.WithNamespace(context.Background(), "globex")
+
+	req := validRegisterReq()
+	req.ProviderID = "did:claw:agent:acme-provider"
+	tool, err := r.RegisterTool(acme, req)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tool.Namespace)
+
+	_, err = r.GetTool(globex, tool.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	_, err = r.GetTool(context.Background(), tool.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	list, err := r.ListTools(globex, 1, 20)
+	require.NoError(t, err)
+	assert.Zero(t, list.Total)
+
+	found, err := r.SearchTools(acme, &registry.SearchQuery{Query: "test"})
+	require.NoError(t, err)
+	assert.Len(t, found.Tools, 1)
+	found, err = r.SearchTools(globex, &registry.SearchQuery{Query: "test"})
+	require.NoError(t, err)
+	assert.Empty(t, found.Tools)
+
+	assert.ErrorIs(t, r.DeactivateTool(globex, tool.ID, req.ProviderID), registry.ErrNotFound)
+	assert.NoError(t, r.DeactivateTool(acme, tool.ID, req.ProviderID))
+}
+
+func TestNamespaces_ProviderBelongsToOneNamespace(t *testing.T) {
+	r := newTestRegistry(t)
+	acme := registry.WithNamespace(context.Background(), "acme")
+	globex := registry.WithNamespace(context.Background(), "globex")
+
+	p := &registry.Provider{ID: "did:claw:agent:p", Endpoint: "https://p", PubKey: "k"}
+	got, err := r.RegisterProvider(acme, p)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", got.Namespace)
+
+	_, err = r.RegisterProvider(globex, p)
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	req := validRegisterReq()
+	req.ProviderID = p.ID
+	_, err = r.RegisterTool(globex, req)
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	providers, err := r.ListProviders(globex)
+	require.NoError(t, err)
+	assert.Empty(t, providers)
+}
+
+func TestNamespaceMembership(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	owner, err := r.CreateNamespace(ctx, "acme", "did:claw:agent:alice")
+	require.NoError(t, err)
+	assert.Equal(t, registry.RoleOwner, owner.Role)
+
+	_, err = r.CreateNamespace(ctx, "acme", "did:claw:agent:mallory")
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+	_, err = r.CreateNamespace(ctx, registry.DefaultNamespace, "did:claw:agent:alice")
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+	_, err = r.CreateNamespace(ctx, "Bad Name", "did:claw:agent:alice")
+	assert.ErrorIs(t, err, registry.ErrInvalidNamespace)
+
+	assert.NoError(t, r.AuthorizeNamespace(ctx, registry.DefaultNamespace, "anyone"))
+	assert.NoError(t, r.AuthorizeNamespace(ctx, "acme", "did:claw:agent:alice"))
+	assert.ErrorIs(t, r.AuthorizeNamespace(ctx, "acme", "did:claw:agent:bob"), registry.ErrForbidden)
+
+	_, err = r.AddNamespaceMember(ctx, "acme", "did:claw:agent:bob", "did:claw:agent:carol")
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	m, err := r.AddNamespaceMember(ctx, "acme", "did:claw:agent:alice", "did:claw:agent:bob")
+	require.NoError(t, err)
+	assert.Equal(t, registry.RoleMember, m.Role)
+	assert.NoError(t, r.AuthorizeNamespace(ctx, "acme", "did:claw:agent:bob"))
+
+	// Members cannot add further members.
+	_, err = r.AddNamespaceMember(ctx, "acme", "did:claw:agent:bob", "did:claw:agent:carol")
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	members, err := r.ListNamespaceMembers(ctx, "acme")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+}
+
+func TestNamespaces_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	ctx := context.Background()
+	_, err := r.CreateNamespace(ctx, "acme", "alice")
+	assert.Error(t, err)
+	assert.Error(t, r.AuthorizeNamespace(ctx, "acme", "alice"))
+	_, err = r.ListNamespaceMembers(ctx, "acme")
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxSeenNonces bounds the nonces a registry remembers in memory. Those it
+// forgets, or never saw because another replica did, are still found in
+// the seen_nonces table.
+const maxSeenNonces = 100_000
+
+// ErrReplayedRequest is returned for a signed registration or invocation
+// whose nonce was already used.
+var ErrReplayedRequest = errors.New("replayed request")
+
+// WithRequiredNonces makes signed tool registrations and consumer
+// signatures carry a nonce, so they cannot be replayed within the
+// ConsumerSignatureSkew they are accepted for. Nonces that are supplied are
+// always checked.
+func WithRequiredNonces(required bool) Option {
+	return func(r *Registry) { r.requireNonces = required }
+}
+
+// nonceCache holds the nonces this registry saw recently, by signer and
+// nonce, until they expire, so replays are refused without a write.
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// has reports whether key was seen and has not expired at now.
+func (c *nonceCache) has(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exp, ok := c.seen[key]
+	return ok && exp.After(now)
+}
+
+// add remembers key until exp, first dropping expired nonces when the
+// cache is full, and every nonce when that is not enough.
+func (c *nonceCache) add(key string, exp, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = map[string]time.Time{}
+	}
+	if len(c.seen) >= maxSeenNonces {
+		for k, e := range c.seen {
+			if !e.After(now) {
+				delete(c.seen, k)
+			}
+		}
+		if len(c.seen) >= maxSeenNonces {
+			c.seen = map[string]time.Time{}
+		}
+	}
+	c.seen[key] = exp
+}
+
+// checkNonce checks that nonce is 16 to 128 characters long.
+func checkNonce(nonce string) error {
+	if len(nonce) < 16 || len(nonce) > 128 {
+		return fmt.Errorf("nonce must be 16 to 128 characters")
+	}
+	return nil
+}
+
+// checkSignedAt checks that a signature made at signedAt is within
+// ConsumerSignatureSkew of now.
+func checkSignedAt(signedAt time.Time) error {
+	if signedAt.IsZero() {
+		return fmt.Errorf("signed_at is required with a nonce")
+	}
+	if d := time.Since(signedAt); d > ConsumerSignatureSkew || d < -ConsumerSignatureSkew {
+		return fmt.Errorf("signed_at is more than %s away", ConsumerSignatureSkew)
+	}
+	return nil
+}
+
+// ClaimNonce records the use of nonce by signer in a signature made at
+// signedAt, or returns ErrReplayedRequest when it was already used. A
+// nonce is remembered for as long as a signature made at signedAt is
+// accepted, after which it can only be replayed with a stale signed_at.
+func (r *Registry) ClaimNonce(ctx context.Context, signer, nonce string, signedAt time.Time) error {
+	now := time.Now()
+	key := signer + "\x00" + nonce
+	if r.nonces.has(key, now) {
+		return fmt.Errorf("%w: nonce %s was already used", ErrReplayedRequest, nonce)
+	}
+	exp := signedAt.Add(ConsumerSignatureSkew)
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO seen_nonces (signer, nonce, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (signer, nonce) DO UPDATE SET expires_at = excluded.expires_at
+		WHERE seen_nonces.expires_at <= ?
+	`, signer, nonce, exp.Unix(), now.Unix())
+	if err != nil {
+		return fmt.Errorf("record nonce: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: nonce %s was already used", ErrReplayedRequest, nonce)
+	}
+	r.nonces.add(key, exp, now)
+	return nil
+}
+
+// PurgeNonces deletes the nonces whose signatures would now be refused as
+// stale.
+func (r *Registry) PurgeNonces(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM seen_nonces WHERE expires_at <= ?", time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("purge nonces: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger(ctx).Debug("seen nonces purged", zap.Int64("rows", n))
+	}
+	return nil
+}
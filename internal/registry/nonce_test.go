@@ -0,0 +1,72 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestClaimNonce(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t))
+	nonce := receipts.NewNonce()
+	now := time.Now()
+
+	require.NoError(t, r.ClaimNonce(ctx, "did:claw:agent:a", nonce, now))
+	assert.ErrorIs(t, r.ClaimNonce(ctx, "did:claw:agent:a", nonce, now), registry.ErrReplayedRequest)
+	assert.NoError(t, r.ClaimNonce(ctx, "did:claw:agent:b", nonce, now), "nonces are per signer")
+
+	// Another replica, with nothing in memory, finds the nonce in the
+	// database.
+	replica := registry.New(db, zaptest.NewLogger(t))
+	assert.ErrorIs(t, replica.ClaimNonce(ctx, "did:claw:agent:a", nonce, now), registry.ErrReplayedRequest)
+
+	// Once the signatures it was used in are stale, a nonce is forgotten.
+	old := receipts.NewNonce()
+	require.NoError(t, r.ClaimNonce(ctx, "did:claw:agent:a", old, now.Add(-time.Hour)))
+	require.NoError(t, r.PurgeNonces(ctx))
+	assert.NoError(t, replica.ClaimNonce(ctx, "did:claw:agent:a", old, now))
+	assert.ErrorIs(t, replica.ClaimNonce(ctx, "did:claw:agent:a", nonce, now), registry.ErrReplayedRequest)
+}
+
+func TestRegisterTool_Nonce(t *testing.T) {
+	ctx := context.Background()
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithRequiredNonces(true))
+	key := registerSigner(t, r)
+
+	req := validRegisterReq()
+	sign(t, key, req)
+	_, err := r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature, "nonce required")
+
+	signedAt := time.Now()
+	req = validRegisterReq()
+	req.Nonce, req.SignedAt = receipts.NewNonce(), &signedAt
+	sign(t, key, req)
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req.ManifestHash, tool.ManifestHash)
+
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrReplayedRequest)
+
+	stale := signedAt.Add(-time.Hour)
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	req.Nonce, req.SignedAt = receipts.NewNonce(), &stale
+	sign(t, key, req)
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+
+	req.Nonce, req.SignedAt = "short", &signedAt
+	sign(t, key, req)
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+}
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"context"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// slowInvocationRatio is the fraction of a tool's declared timeout after
+// which an invocation is reported as slow.
+const slowInvocationRatio = 0.8
+
+// Invocation outcomes used as the "outcome" metric label.
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var (
+	slowInvocations = metrics.Default.Counter(
+		"agent_tools_slow_invocations_total",
+		"Invocations that used more than 80% of the tool's declared timeout.",
+		"tool", "provider",
+	)
+	invocationsTotal = metrics.Default.Counter(
+		"agent_tools_invocations_total",
+		"Provider invocations by tool, provider and outcome.",
+		"tool", "provider", "outcome",
+	)
+	invocationLatency = metrics.Default.Histogram(
+		"agent_tools_invocation_duration_seconds",
+		"Provider invocation latency by tool, provider and outcome.",
+		metrics.DefaultLatencyBuckets,
+		"tool", "provider", "outcome",
+	)
+)
+
+// ObserveInvocation records the latency and outcome of a provider invocation
+// (with the invocation ID as exemplar), samples it against the tool's SLA
+// if it declares one, and reports it when it came close to the tool's
+// declared timeout, so degrading tools are visible before they start
+// failing.
+func (r *Registry) ObserveInvocation(ctx context.Context, tool *Tool, invocationID string, elapsed time.Duration, invokeErr error) {
+	if tool == nil {
+		return
+	}
+	outcome := outcomeSuccess
+	if invokeErr != nil {
+		outcome = outcomeError
+	}
+	invocationsTotal.Inc(tool.ID, tool.ProviderID, outcome)
+	var exemplar map[string]string
+	if invocationID != "" {
+		exemplar = map[string]string{"invocation_id": invocationID}
+	}
+	invocationLatency.ObserveWithExemplar(elapsed.Seconds(), exemplar, tool.ID, tool.ProviderID, outcome)
+	if tool.SLA != nil {
+		r.recordSLASample(ctx, tool.ID, slaSourceInvocation, invokeErr == nil, elapsed)
+	}
+
+	if tool.TimeoutMS <= 0 {
+		return
+	}
+	limit := time.Duration(float64(tool.TimeoutMS)*slowInvocationRatio) * time.Millisecond
+	if elapsed < limit {
+		return
+	}
+	slowInvocations.Inc(tool.ID, tool.ProviderID)
+	r.logger(ctx).Warn("slow invocation",
+		zap.String("tool", tool.ID),
+		zap.String("provider", tool.ProviderID),
+		zap.String("invocation_id", invocationID),
+		zap.Duration("elapsed", elapsed),
+		zap.Int64("timeout_ms", tool.TimeoutMS),
+	)
+}
@@ -0,0 +1,52 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/metrics"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestObserveInvocation_Slow(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	r := registry.New(openTestDB(t), zap.New(core))
+	tool := &registry.Tool{ID: "did:claw:tool:slow", ProviderID: "prov", TimeoutMS: 1000}
+
+	r.ObserveInvocation(context.Background(), tool, "inv_1", 850*time.Millisecond, nil)
+	assert.Equal(t, 1, logs.FilterMessage("slow invocation").Len())
+}
+
+func TestObserveInvocation_Fast(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	r := registry.New(openTestDB(t), zap.New(core))
+	tool := &registry.Tool{ID: "did:claw:tool:fast", ProviderID: "prov", TimeoutMS: 1000}
+
+	r.ObserveInvocation(context.Background(), tool, "inv_2", 100*time.Millisecond, nil)
+	r.ObserveInvocation(context.Background(), nil, "", time.Hour, nil)
+	assert.Zero(t, logs.Len())
+}
+
+func TestObserveInvocation_LatencyHistogram(t *testing.T) {
+	r := newTestRegistry(t)
+	tool := &registry.Tool{ID: "did:claw:tool:histo", ProviderID: "prov-h", TimeoutMS: 5000}
+
+	r.ObserveInvocation(context.Background(), tool, "inv_ok", 30*time.Millisecond, nil)
+	r.ObserveInvocation(context.Background(), tool, "inv_bad", 2*time.Second, errors.New("boom"))
+
+	var buf bytes.Buffer
+	require.NoError(t, metrics.Default.WriteOpenMetrics(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `agent_tools_invocation_duration_seconds_count{tool="did:claw:tool:histo",provider="prov-h",outcome="success"} 1`)
+	assert.Contains(t, out, `agent_tools_invocation_duration_seconds_count{tool="did:claw:tool:histo",provider="prov-h",outcome="error"} 1`)
+	assert.Contains(t, out, `# {invocation_id="inv_bad"} 2`)
+	assert.Contains(t, out, `agent_tools_invocations_total{tool="did:claw:tool:histo",provider="prov-h",outcome="error"} 1`)
+}
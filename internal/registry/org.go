@@ -0,0 +1,267 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Organization member roles. Owners manage the members of an organization
+// as well as its tools; maintainers manage its tools only.
+const (
+	OrgRoleOwner      = "owner"
+	OrgRoleMaintainer = "maintainer"
+)
+
+// orgPrefix starts the provider ID of every organization.
+const orgPrefix = "did:claw:org:"
+
+// ErrInvalidOrg is returned for a malformed organization name or member
+// role, and for changes that would leave an organization without owners.
+var ErrInvalidOrg = errors.New("invalid organization")
+
+// Org is a provider account shared by a team: its tools are registered with
+// the organization's ID as their provider, and any of its members may
+// manage them with their own key.
+type Org struct {
+	CreatedAt time.Time    `json:"created_at"`
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Members   []*OrgMember `json:"members,omitempty"`
+}
+
+// OrgMember is a DID allowed to act as an organization.
+type OrgMember struct {
+	CreatedAt time.Time `json:"created_at"`
+	OrgID     string    `json:"org_id"`
+	MemberID  string    `json:"member_id"`
+	Role      string    `json:"role"`
+}
+
+// OrgID returns the provider ID of the organization named name; an ID is
+// returned unchanged.
+func OrgID(name string) string {
+	if IsOrg(name) {
+		return name
+	}
+	return orgPrefix + name
+}
+
+// IsOrg reports whether id is the provider ID of an organization.
+func IsOrg(id string) bool {
+	return strings.HasPrefix(id, orgPrefix)
+}
+
+func validOrgRole(role string) error {
+	if role != OrgRoleOwner && role != OrgRoleMaintainer {
+		return fmt.Errorf("%w: role must be %s or %s", ErrInvalidOrg, OrgRoleOwner, OrgRoleMaintainer)
+	}
+	return nil
+}
+
+// CreateOrg creates the organization name with ownerID as its first owner.
+func (r *Registry) CreateOrg(ctx context.Context, name, ownerID string) (*Org, error) {
+	if !namespacePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w %q: use 1-63 lowercase letters, digits or dashes", ErrInvalidOrg, name)
+	}
+	if IsOrg(ownerID) {
+		return nil, fmt.Errorf("%w: an organization cannot be a member of another", ErrInvalidOrg)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	org := &Org{ID: OrgID(name), Name: name, CreatedAt: now}
+	owner := &OrgMember{OrgID: org.ID, MemberID: ownerID, Role: OrgRoleOwner, CreatedAt: now}
+	err := r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO orgs (id, name, created_at) VALUES (?, ?, ?)",
+			org.ID, name, now.Unix()); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return fmt.Errorf("%w: organization %s", ErrDuplicate, name)
+			}
+			return fmt.Errorf("insert organization: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO org_members (org_id, member_id, role, created_at) VALUES (?, ?, ?, ?)",
+			org.ID, ownerID, OrgRoleOwner, now.Unix()); err != nil {
+			return fmt.Errorf("insert owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	org.Members = []*OrgMember{owner}
+	r.logger(ctx).Info("organization created", zap.String("org", org.ID), zap.String("owner", ownerID))
+	return org, nil
+}
+
+// GetOrg returns an organization, by name or ID, with its members.
+func (r *Registry) GetOrg(ctx context.Context, org string) (*Org, error) {
+	var (
+		o       Org
+		created int64
+	)
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM orgs WHERE id = ?", OrgID(org)).
+		Scan(&o.ID, &o.Name, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get organization: %w", err)
+	}
+	o.CreatedAt = time.Unix(created, 0).UTC()
+	if o.Members, err = r.orgMembers(ctx, "org_id = ?", o.ID); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// MemberOrgs returns the memberships of memberID, oldest first.
+func (r *Registry) MemberOrgs(ctx context.Context, memberID string) ([]*OrgMember, error) {
+	return r.orgMembers(ctx, "member_id = ?", memberID)
+}
+
+func (r *Registry) orgMembers(ctx context.Context, where string, arg string) ([]*OrgMember, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT org_id, member_id, role, created_at FROM org_members WHERE "+where+
+		" ORDER BY created_at, member_id", arg)
+	if err != nil {
+		return nil, fmt.Errorf("list organization members: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	out := []*OrgMember{}
+	for rows.Next() {
+		var (
+			m       OrgMember
+			created int64
+		)
+		if err := rows.Scan(&m.OrgID, &m.MemberID, &m.Role, &created); err != nil {
+			return nil, fmt.Errorf("scan organization member: %w", err)
+		}
+		m.CreatedAt = time.Unix(created, 0).UTC()
+		out = append(out, &m)
+	}
+	return out, rows.Err()
+}
+
+// OrgRole returns the role of memberID in an organization, named by name or
+// ID. It returns ErrNotFound for an unknown organization and ErrForbidden
+// when memberID is not a member.
+func (r *Registry) OrgRole(ctx context.Context, org, memberID string) (string, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT role FROM org_members WHERE org_id = o.id AND member_id = ?), '')
+		FROM orgs o WHERE o.id = ?
+	`, memberID, OrgID(org)).Scan(&role)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", ErrNotFound
+	case err != nil:
+		return "", fmt.Errorf("lookup organization member: %w", err)
+	case role == "":
+		return "", fmt.Errorf("%w: %s is not a member of organization %s", ErrForbidden, memberID, OrgID(org))
+	}
+	return role, nil
+}
+
+// SetOrgMember adds memberID to an organization with role, or changes the
+// role of a member. Only owners may, and the last owner cannot be demoted.
+func (r *Registry) SetOrgMember(ctx context.Context, org, callerID, memberID, role string) (*OrgMember, error) {
+	if err := validOrgRole(role); err != nil {
+		return nil, err
+	}
+	if memberID == "" || IsOrg(memberID) {
+		return nil, fmt.Errorf("%w: member_id must be the DID of an agent", ErrInvalidOrg)
+	}
+	if err := r.requireOrgOwner(ctx, org, callerID); err != nil {
+		return nil, err
+	}
+	id := OrgID(org)
+	if role != OrgRoleOwner {
+		if err := r.keepAnOwner(ctx, id, memberID); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO org_members (org_id, member_id, role, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(org_id, member_id) DO UPDATE SET role = excluded.role
+	`, id, memberID, role, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("set organization member: %w", err)
+	}
+	r.logger(ctx).Info("organization member set", zap.String("org", id), zap.String("member", memberID),
+		zap.String("role", role), zap.String("by", callerID))
+	return &OrgMember{OrgID: id, MemberID: memberID, Role: role, CreatedAt: now}, nil
+}
+
+// RemoveOrgMember removes memberID from an organization. Owners may remove
+// anyone but the last owner; members may remove themselves.
+func (r *Registry) RemoveOrgMember(ctx context.Context, org, callerID, memberID string) error {
+	if callerID != memberID {
+		if err := r.requireOrgOwner(ctx, org, callerID); err != nil {
+			return err
+		}
+	}
+	id := OrgID(org)
+	if err := r.keepAnOwner(ctx, id, memberID); err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, "DELETE FROM org_members WHERE org_id = ? AND member_id = ?", id, memberID)
+	if err != nil {
+		return fmt.Errorf("remove organization member: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	r.logger(ctx).Info("organization member removed", zap.String("org", id), zap.String("member", memberID),
+		zap.String("by", callerID))
+	return nil
+}
+
+func (r *Registry) requireOrgOwner(ctx context.Context, org, callerID string) error {
+	role, err := r.OrgRole(ctx, org, callerID)
+	if err != nil {
+		return err
+	}
+	if role != OrgRoleOwner {
+		return fmt.Errorf("%w: only organization owners manage its members", ErrForbidden)
+	}
+	return nil
+}
+
+// keepAnOwner refuses to demote or remove memberID when they are the last
+// owner of the organization.
+func (r *Registry) keepAnOwner(ctx context.Context, orgID, memberID string) error {
+	var others int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM org_members WHERE org_id = ? AND role = ? AND member_id != ?
+	`, orgID, OrgRoleOwner, memberID).Scan(&others)
+	if err != nil {
+		return fmt.Errorf("count organization owners: %w", err)
+	}
+	if others > 0 {
+		return nil
+	}
+	var role string
+	err = r.db.QueryRowContext(ctx, "SELECT role FROM org_members WHERE org_id = ? AND member_id = ?", orgID, memberID).
+		Scan(&role)
+	if err == nil && role == OrgRoleOwner {
+		return fmt.Errorf("%w: %s is the last owner of %s", ErrInvalidOrg, memberID, orgID)
+	}
+	return nil
+}
+
+// orgMemberIDs returns the members of the organization id.
+func (r *Registry) orgMemberIDs(ctx context.Context, id string) ([]string, error) {
+	members, err := r.orgMembers(ctx, "org_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.MemberID)
+	}
+	return ids, nil
+}
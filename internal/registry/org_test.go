@@ -0,0 +1,89 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestOrgs(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	const (
+		alice = "did:claw:agent:alice"
+		bob   = "did:claw:agent:bob"
+	)
+	org, err := r.CreateOrg(ctx, "audit-team", alice)
+	require.NoError(t, err)
+	assert.Equal(t, "did:claw:org:audit-team", org.ID)
+	require.Len(t, org.Members, 1)
+	assert.Equal(t, registry.OrgRoleOwner, org.Members[0].Role)
+	_, err = r.CreateOrg(ctx, "audit-team", bob)
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+	_, err = r.CreateOrg(ctx, "Audit Team", bob)
+	assert.ErrorIs(t, err, registry.ErrInvalidOrg)
+	_, err = r.CreateOrg(ctx, "nested", org.ID)
+	assert.ErrorIs(t, err, registry.ErrInvalidOrg, "organizations are not members")
+	_, err = r.GetOrg(ctx, "missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	_, err = r.SetOrgMember(ctx, "audit-team", alice, org.ID, registry.OrgRoleMaintainer)
+	assert.ErrorIs(t, err, registry.ErrInvalidOrg)
+	_, err = r.SetOrgMember(ctx, "audit-team", bob, bob, registry.OrgRoleOwner)
+	assert.ErrorIs(t, err, registry.ErrForbidden, "only owners add members")
+	_, err = r.SetOrgMember(ctx, "audit-team", alice, bob, "admin")
+	assert.ErrorIs(t, err, registry.ErrInvalidOrg)
+	m, err := r.SetOrgMember(ctx, org.ID, alice, bob, registry.OrgRoleMaintainer)
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, m.OrgID)
+
+	role, err := r.OrgRole(ctx, "audit-team", bob)
+	require.NoError(t, err)
+	assert.Equal(t, registry.OrgRoleMaintainer, role)
+	_, err = r.OrgRole(ctx, "audit-team", "did:claw:agent:carol")
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+	_, err = r.OrgRole(ctx, "missing", alice)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	memberships, err := r.MemberOrgs(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, org.ID, memberships[0].OrgID)
+
+	_, err = r.SetOrgMember(ctx, "audit-team", alice, alice, registry.OrgRoleMaintainer)
+	assert.ErrorIs(t, err, registry.ErrInvalidOrg, "the last owner cannot step down")
+	assert.ErrorIs(t, r.RemoveOrgMember(ctx, "audit-team", alice, alice), registry.ErrInvalidOrg)
+	assert.ErrorIs(t, r.RemoveOrgMember(ctx, "audit-team", bob, alice), registry.ErrForbidden)
+	require.NoError(t, r.RemoveOrgMember(ctx, "audit-team", bob, bob), "members may leave")
+	assert.ErrorIs(t, r.RemoveOrgMember(ctx, "audit-team", alice, bob), registry.ErrNotFound)
+
+	got, err := r.GetOrg(ctx, org.ID)
+	require.NoError(t, err)
+	assert.Len(t, got.Members, 1)
+}
+
+func TestOrgs_MemberKeysSign(t *testing.T) {
+	r := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithSignedManifests(true))
+	ctx := context.Background()
+	key := registerSigner(t, r)
+	_, err := r.CreateOrg(ctx, "signers", validRegisterReq().ProviderID)
+	require.NoError(t, err)
+
+	req := validRegisterReq()
+	req.ProviderID = registry.OrgID("signers")
+	sign(t, key, req)
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err, "a member's key signs for the organization")
+	assert.Equal(t, req.ProviderID, tool.ProviderID)
+	require.NoError(t, r.DeactivateTool(ctx, tool.ID, req.ProviderID))
+
+	req = validRegisterReq()
+	req.Version = "2.0.0"
+	req.ProviderID = registry.OrgID("others")
+	sign(t, key, req)
+	_, err = r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+}
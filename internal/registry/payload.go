@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrPayloadStorage is returned when payload storage is requested of a
+// registry that cannot encrypt payloads, or for a payload over the limit.
+var ErrPayloadStorage = errors.New("payload storage unavailable")
+
+// DefaultPayloadRetention is how long stored payloads are kept by a
+// Registry created without WithPayloadRetention.
+const DefaultPayloadRetention = 7 * 24 * time.Hour
+
+// WithPayloadRetention sets how long stored invocation payloads are kept
+// before PurgePayloads deletes them.
+func WithPayloadRetention(d time.Duration) Option {
+	return func(r *Registry) { r.payloadTTL = d }
+}
+
+type payloadOwnerKey struct{}
+
+// WithPayloadStorage marks ctx so invocations recorded with it keep their
+// input, and later their output, encrypted and keyed to owner: the consumer
+// or the provider whose consent the storage is. Only owner may read the
+// payload back or delete it. Inputs are otherwise only hashed.
+func WithPayloadStorage(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, payloadOwnerKey{}, owner)
+}
+
+func payloadOwner(ctx context.Context) string {
+	v, _ := ctx.Value(payloadOwnerKey{}).(string)
+	return v
+}
+
+// Payload is the stored input and output of an invocation.
+type Payload struct {
+	InvocationID string          `json:"invocation_id"`
+	Owner        string          `json:"owner"`
+	Input        json.RawMessage `json:"input"`
+	Output       json.RawMessage `json:"output,omitempty"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+}
+
+// sealedPayload is the stored form of a new invocation's input.
+type sealedPayload struct {
+	owner, input string
+	expiresAt    int64
+}
+
+// payloadAAD binds a sealed payload to its invocation, its owner and which
+// half of the exchange it is, so sealed values cannot be swapped.
+func payloadAAD(id, owner, part string) []byte {
+	return []byte("payload\x00" + id + "\x00" + owner + "\x00" + part)
+}
+
+// sealInput encrypts the input of invocation id for the payload owner of
+// ctx. It returns nil when storage was not requested.
+func (r *Registry) sealInput(ctx context.Context, id string, input []byte) (*sealedPayload, error) {
+	owner := payloadOwner(ctx)
+	if owner == "" {
+		return nil, nil
+	}
+	if err := r.checkPayloadStorage(input); err != nil {
+		return nil, err
+	}
+	sealed, err := r.secrets.Seal(input, payloadAAD(id, owner, "input"))
+	if err != nil {
+		return nil, err
+	}
+	return &sealedPayload{owner: owner, input: sealed, expiresAt: time.Now().Add(r.payloadTTL).Unix()}, nil
+}
+
+// checkPayloadStorage returns why input could not be stored, if it could
+// not.
+func (r *Registry) checkPayloadStorage(input []byte) error {
+	if r.secrets == nil {
+		return fmt.Errorf("%w: this registry has no secrets key configured", ErrPayloadStorage)
+	}
+	if limit := r.limits.MaxPayloadBytes; limit > 0 && len(input) > limit {
+		return fmt.Errorf("%w: input is %d bytes, max %d stored", ErrLimitExceeded, len(input), limit)
+	}
+	return nil
+}
+
+// columns returns the input, payload_owner and payload_expires_at values of
+// an invocation row.
+func (p *sealedPayload) columns() (input any, owner string, expiresAt any) {
+	if p == nil {
+		return nil, "", nil
+	}
+	return p.input, p.owner, p.expiresAt
+}
+
+// StorePayloadOutput keeps output with the stored input of invocation id.
+// It does nothing unless ctx carries WithPayloadStorage, as the ctx the
+// invocation was recorded with did, and drops an output over the payload
+// limit, keeping the input.
+func (r *Registry) StorePayloadOutput(ctx context.Context, id string, output []byte) error {
+	if payloadOwner(ctx) == "" {
+		return nil
+	}
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	var owner string
+	err := r.db.QueryRowContext(ctx, "SELECT payload_owner FROM invocations WHERE id = ?", id).Scan(&owner)
+	if err != nil {
+		return fmt.Errorf("read payload owner: %w", err)
+	}
+	if owner == "" || r.secrets == nil {
+		return nil
+	}
+	if limit := r.limits.MaxPayloadBytes; limit > 0 && len(output) > limit {
+		r.logger(ctx).Info("payload output not stored", zap.String("invocation_id", id), zap.Int("bytes", len(output)))
+		return nil
+	}
+	sealed, err := r.secrets.Seal(output, payloadAAD(id, owner, "output"))
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, "UPDATE invocations SET output = ? WHERE id = ?", sealed, id); err != nil {
+		return fmt.Errorf("store payload output: %w", err)
+	}
+	return nil
+}
+
+// GetPayload returns the stored payload of invocation id to its owner.
+func (r *Registry) GetPayload(ctx context.Context, id, caller string) (*Payload, error) {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return nil, err
+	}
+	var (
+		p             = Payload{InvocationID: id}
+		input, output sql.NullString
+		expiresAt     sql.NullInt64
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT payload_owner, input, output, payload_expires_at FROM invocations WHERE id = ? AND namespace = ?
+	`, id, NamespaceFrom(ctx)).Scan(&p.Owner, &input, &output, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get payload: %w", err)
+	}
+	if p.Owner == "" || !input.Valid {
+		return nil, ErrInputNotStored
+	}
+	if caller != "" && caller != p.Owner {
+		return nil, fmt.Errorf("%w: the payload of %s is keyed to %s", ErrForbidden, id, p.Owner)
+	}
+	if r.secrets == nil {
+		return nil, fmt.Errorf("%w: this registry has no secrets key configured", ErrPayloadStorage)
+	}
+	if p.Input, err = r.secrets.Open(input.String, payloadAAD(id, p.Owner, "input")); err != nil {
+		return nil, fmt.Errorf("open payload input: %w", err)
+	}
+	if output.Valid {
+		if p.Output, err = r.secrets.Open(output.String, payloadAAD(id, p.Owner, "output")); err != nil {
+			return nil, fmt.Errorf("open payload output: %w", err)
+		}
+	}
+	p.ExpiresAt = time.Unix(expiresAt.Int64, 0).UTC()
+	return &p, nil
+}
+
+// DeletePayload deletes the stored payload of invocation id for its owner.
+// The invocation record and its hashes are kept.
+func (r *Registry) DeletePayload(ctx context.Context, id, caller string) error {
+	if _, err := r.GetPayload(ctx, id, caller); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET input = NULL, output = NULL, payload_owner = '', payload_expires_at = NULL WHERE id = ?
+	`, id)
+	if err != nil {
+		return fmt.Errorf("delete payload: %w", err)
+	}
+	return nil
+}
+
+// PurgePayloads deletes stored payloads past their retention.
+func (r *Registry) PurgePayloads(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET input = NULL, output = NULL, payload_owner = '', payload_expires_at = NULL
+		WHERE payload_expires_at <= ?
+	`, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("purge payloads: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger(ctx).Debug("payloads purged", zap.Int64("rows", n))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newPayloadRegistry(t *testing.T, opts ...registry.Option) *registry.Registry {
+	t.Helper()
+	box, err := secrets.New(bytes.Repeat([]byte{1}, secrets.KeySize))
+	require.NoError(t, err)
+	opts = append([]registry.Option{registry.WithSecrets(box), registry.WithInvocationBatching()}, opts...)
+	return registry.New(openTestDB(t), zaptest.NewLogger(t), opts...)
+}
+
+func TestPayload_RoundTrip(t *testing.T) {
+	r := newPayloadRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	kept, err := r.RecordInvocation(registry.WithPayloadStorage(ctx, "consumer"), tool.ID, "consumer", map[string]any{"q": "x"})
+	require.NoError(t, err)
+	require.NoError(t, r.StorePayloadOutput(registry.WithPayloadStorage(ctx, "consumer"), kept, []byte(`{"a":1}`)))
+	hashed, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"q": "x"})
+	require.NoError(t, err)
+
+	p, err := r.GetPayload(ctx, kept, "consumer")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"q":"x"}`, string(p.Input))
+	assert.JSONEq(t, `{"a":1}`, string(p.Output))
+	assert.WithinDuration(t, time.Now().Add(registry.DefaultPayloadRetention), p.ExpiresAt, time.Minute)
+
+	_, err = r.GetPayload(ctx, kept, tool.ProviderID)
+	assert.ErrorIs(t, err, registry.ErrForbidden, "the payload is keyed to the consumer")
+	_, err = r.GetPayload(ctx, hashed, "consumer")
+	assert.ErrorIs(t, err, registry.ErrInputNotStored)
+
+	rp, err := r.ReplayInvocation(ctx, kept, tool.ProviderID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"q":"x"}`, string(rp.Input))
+	_, err = r.ReplayInvocation(ctx, kept, "did:claw:agent:other")
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+
+	require.NoError(t, r.DeletePayload(ctx, kept, "consumer"))
+	_, err = r.GetPayload(ctx, kept, "consumer")
+	assert.ErrorIs(t, err, registry.ErrInputNotStored)
+	inv, err := r.GetInvocation(ctx, kept)
+	require.NoError(t, err)
+	assert.NotEmpty(t, inv.InputHash, "deleting a payload keeps the record")
+}
+
+func TestPayload_EncryptedAtRest(t *testing.T) {
+	db := openTestDB(t)
+	box, err := secrets.New(bytes.Repeat([]byte{1}, secrets.KeySize))
+	require.NoError(t, err)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithSecrets(box))
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	id, err := r.RecordInvocation(registry.WithPayloadStorage(ctx, "consumer"), tool.ID, "consumer", map[string]any{"q": "top secret"})
+	require.NoError(t, err)
+	var raw string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT input FROM invocations WHERE id = ?", id).Scan(&raw))
+	assert.NotContains(t, raw, "top secret")
+}
+
+func TestPayload_LimitsAndRetention(t *testing.T) {
+	limits := registry.DefaultLimits
+	limits.MaxPayloadBytes = 32
+	r := newPayloadRegistry(t, registry.WithLimits(limits), registry.WithPayloadRetention(-time.Second))
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	stored := registry.WithPayloadStorage(ctx, "consumer")
+
+	_, err = r.RecordInvocation(stored, tool.ID, "consumer", map[string]any{"q": strings.Repeat("x", 64)})
+	assert.ErrorIs(t, err, registry.ErrLimitExceeded)
+
+	id, err := r.RecordInvocation(stored, tool.ID, "consumer", map[string]any{"q": "x"})
+	require.NoError(t, err)
+	require.NoError(t, r.StorePayloadOutput(stored, id, []byte(`{"out":"`+strings.Repeat("y", 64)+`"}`)))
+	p, err := r.GetPayload(ctx, id, "consumer")
+	require.NoError(t, err)
+	assert.Nil(t, p.Output, "an output over the limit is dropped")
+
+	require.NoError(t, r.PurgePayloads(ctx))
+	_, err = r.GetPayload(ctx, id, "consumer")
+	assert.ErrorIs(t, err, registry.ErrInputNotStored, "expired payloads are purged")
+
+	_, err = newTestRegistry(t).RecordInvocation(stored, tool.ID, "consumer", map[string]any{})
+	assert.ErrorIs(t, err, registry.ErrPayloadStorage, "no secrets key")
+}
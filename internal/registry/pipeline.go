@@ -0,0 +1,429 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidPipeline is returned for a pipeline without steps, with
+// malformed or repeated step IDs, unknown tools, mappings that reference
+// nothing or steps that depend on each other in a cycle.
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// maxPipelineSteps bounds the steps of one pipeline.
+const maxPipelineSteps = 16
+
+var stepIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Statuses of a pipeline run.
+const (
+	PipelineCompleted = "completed"
+	PipelineFailed    = "failed"
+)
+
+// Pipeline is a DAG of registered tools run server-side as one unit. Each
+// step's input is built from constants and from the pipeline's input and
+// the outputs of earlier steps; steps that do not depend on each other run
+// concurrently.
+type Pipeline struct {
+	CreatedAt   time.Time       `json:"created_at"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	OwnerID     string          `json:"owner_id"`
+	Steps       []*PipelineStep `json:"steps"`
+	// Output maps the fields of the run's output to references (see
+	// PipelineStep.Map). Empty returns the outputs of the steps no other
+	// step depends on, keyed by step ID.
+	Output map[string]string `json:"output,omitempty"`
+}
+
+// PipelineStep invokes one tool of a pipeline.
+type PipelineStep struct {
+	ID     string `json:"id"`
+	ToolID string `json:"tool_id"`
+	// Input holds constant input fields.
+	Input map[string]any `json:"input,omitempty"`
+	// Map sets input fields to references, overriding Input: "input" is
+	// the pipeline's input and "steps.<id>" the output of step <id>, each
+	// optionally followed by a dotted path into it, such as
+	// "steps.lint.issues". A step runs after the steps it references.
+	Map map[string]string `json:"map,omitempty"`
+	// After lists further steps to run this one after.
+	After []string `json:"after,omitempty"`
+}
+
+// PipelineRun is the record of one run of a pipeline.
+type PipelineRun struct {
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt time.Time        `json:"completed_at"`
+	ID          string           `json:"id"`
+	PipelineID  string           `json:"pipeline_id"`
+	ConsumerID  string           `json:"consumer_id"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Receipt     *PipelineReceipt `json:"receipt"`
+}
+
+// PipelineReceipt aggregates the invocations of a pipeline run into one
+// proof: the hashes of the run's input and output, each step's invocation
+// and their total cost. Hash commits to all of it.
+type PipelineReceipt struct {
+	RunID      string         `json:"run_id"`
+	PipelineID string         `json:"pipeline_id"`
+	ConsumerID string         `json:"consumer_id"`
+	InputHash  string         `json:"input_hash"`
+	OutputHash string         `json:"output_hash,omitempty"`
+	Steps      []*StepReceipt `json:"steps"`
+	CostCLAW   string         `json:"cost_claw,omitempty"`
+	ExecutedAt time.Time      `json:"executed_at"`
+	Hash       string         `json:"hash"`
+}
+
+// StepReceipt is the part of a pipeline receipt for one step.
+type StepReceipt struct {
+	StepID       string `json:"step_id"`
+	InvocationID string `json:"invocation_id"`
+	ToolID       string `json:"tool_id"`
+	ProviderID   string `json:"provider_id"`
+	InputHash    string `json:"input_hash"`
+	OutputHash   string `json:"output_hash,omitempty"`
+	CostCLAW     string `json:"cost_claw,omitempty"`
+	Cached       bool   `json:"cached,omitempty"`
+}
+
+// Seal totals the cost of the steps and sets Hash, the SHA-256 of the
+// receipt's JSON encoding without it.
+func (rc *PipelineReceipt) Seal() error {
+	var total float64
+	for _, s := range rc.Steps {
+		if c, err := strconv.ParseFloat(s.CostCLAW, 64); err == nil {
+			total += c
+		}
+	}
+	rc.CostCLAW = ""
+	if total > 0 {
+		rc.CostCLAW = strconv.FormatFloat(total, 'f', -1, 64)
+	}
+	rc.Hash = ""
+	b, err := json.Marshal(rc)
+	if err != nil {
+		return fmt.Errorf("marshal receipt: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	rc.Hash = "sha256:" + hex.EncodeToString(sum[:])
+	return nil
+}
+
+// HashPayload returns the hash recorded for an input or output of an
+// invocation or pipeline run.
+func HashPayload(v any) string {
+	return receipts.Hash(v)
+}
+
+// deps returns the IDs of the steps s runs after.
+func (s *PipelineStep) deps() []string {
+	deps := slices.Clone(s.After)
+	for _, ref := range s.Map {
+		if id, ok := refStep(ref); ok && !slices.Contains(deps, id) {
+			deps = append(deps, id)
+		}
+	}
+	return deps
+}
+
+// refStep returns the step a reference names, if it names one.
+func refStep(ref string) (string, bool) {
+	rest, ok := strings.CutPrefix(ref, "steps.")
+	if !ok {
+		return "", false
+	}
+	id, _, _ := strings.Cut(rest, ".")
+	return id, true
+}
+
+func (p *Pipeline) validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPipeline)
+	}
+	if len(p.Steps) == 0 || len(p.Steps) > maxPipelineSteps {
+		return fmt.Errorf("%w: a pipeline has 1 to %d steps", ErrInvalidPipeline, maxPipelineSteps)
+	}
+	ids := make([]string, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		if s == nil || !stepIDPattern.MatchString(s.ID) {
+			return fmt.Errorf("%w: step IDs are 1-32 lowercase letters, digits, dashes or underscores", ErrInvalidPipeline)
+		}
+		if slices.Contains(ids, s.ID) {
+			return fmt.Errorf("%w: step %q is defined twice", ErrInvalidPipeline, s.ID)
+		}
+		if s.ToolID == "" {
+			return fmt.Errorf("%w: step %q has no tool_id", ErrInvalidPipeline, s.ID)
+		}
+		ids = append(ids, s.ID)
+	}
+	checkRef := func(ref string) error {
+		if ref == "input" || strings.HasPrefix(ref, "input.") {
+			return nil
+		}
+		if id, ok := refStep(ref); ok && slices.Contains(ids, id) {
+			return nil
+		}
+		return fmt.Errorf("%w: %q references neither input nor a step", ErrInvalidPipeline, ref)
+	}
+	for _, s := range p.Steps {
+		for _, ref := range s.Map {
+			if err := checkRef(ref); err != nil {
+				return err
+			}
+		}
+		for _, id := range s.deps() {
+			if !slices.Contains(ids, id) || id == s.ID {
+				return fmt.Errorf("%w: step %q cannot run after %q", ErrInvalidPipeline, s.ID, id)
+			}
+		}
+	}
+	for _, ref := range p.Output {
+		if err := checkRef(ref); err != nil {
+			return err
+		}
+	}
+	if _, err := p.Stages(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stages returns the steps in the order they run: the steps of a stage
+// depend only on steps of earlier stages, so they can run concurrently.
+func (p *Pipeline) Stages() ([][]*PipelineStep, error) {
+	done := map[string]bool{}
+	var stages [][]*PipelineStep
+	for len(done) < len(p.Steps) {
+		var stage []*PipelineStep
+		for _, s := range p.Steps {
+			if done[s.ID] {
+				continue
+			}
+			ready := true
+			for _, id := range s.deps() {
+				ready = ready && done[id]
+			}
+			if ready {
+				stage = append(stage, s)
+			}
+		}
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("%w: steps depend on each other in a cycle", ErrInvalidPipeline)
+		}
+		for _, s := range stage {
+			done[s.ID] = true
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// StepInput builds the input of step s from the pipeline's input and the
+// outputs of the steps run so far. A reference to a missing field fails.
+func (s *PipelineStep) StepInput(input map[string]any, outputs map[string]map[string]any) (map[string]any, error) {
+	in := make(map[string]any, len(s.Input)+len(s.Map))
+	for k, v := range s.Input {
+		in[k] = v
+	}
+	for k, ref := range s.Map {
+		v, err := resolveRef(ref, input, outputs)
+		if err != nil {
+			return nil, err
+		}
+		in[k] = v
+	}
+	return in, nil
+}
+
+// Result builds the output of a run from its input and the outputs of its
+// steps.
+func (p *Pipeline) Result(input map[string]any, outputs map[string]map[string]any) (map[string]any, error) {
+	out := map[string]any{}
+	if len(p.Output) == 0 {
+		for _, s := range p.Steps {
+			if !slices.ContainsFunc(p.Steps, func(o *PipelineStep) bool { return slices.Contains(o.deps(), s.ID) }) {
+				out[s.ID] = outputs[s.ID]
+			}
+		}
+		return out, nil
+	}
+	for k, ref := range p.Output {
+		v, err := resolveRef(ref, input, outputs)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func resolveRef(ref string, input map[string]any, outputs map[string]map[string]any) (any, error) {
+	var (
+		v    any = input
+		path string
+	)
+	if id, ok := refStep(ref); ok {
+		v = outputs[id]
+		path = strings.TrimPrefix(strings.TrimPrefix(ref, "steps."+id), ".")
+	} else {
+		path = strings.TrimPrefix(strings.TrimPrefix(ref, "input"), ".")
+	}
+	if path == "" {
+		return v, nil
+	}
+	for _, field := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%q: not an object at %q", ref, field)
+		}
+		if v, ok = obj[field]; !ok {
+			return nil, fmt.Errorf("%q: no field %q", ref, field)
+		}
+	}
+	return v, nil
+}
+
+// CreatePipeline validates p, checks that its tools exist and stores it in
+// the context namespace, owned by p.OwnerID. It returns ErrDuplicate when
+// the owner already has a pipeline of that name.
+func (r *Registry) CreatePipeline(ctx context.Context, p *Pipeline) (*Pipeline, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	for _, s := range p.Steps {
+		if _, err := r.GetTool(ctx, s.ToolID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, fmt.Errorf("%w: step %q: tool %s not found", ErrInvalidPipeline, s.ID, s.ToolID)
+			}
+			return nil, err
+		}
+	}
+	def, err := json.Marshal(pipelineDefinition{Description: p.Description, Steps: p.Steps, Output: p.Output})
+	if err != nil {
+		return nil, fmt.Errorf("marshal pipeline: %w", err)
+	}
+	p.ID = "pipe_" + uuid.NewString()
+	p.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO pipelines (id, namespace, name, owner_id, definition, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, p.ID, NamespaceFrom(ctx), p.Name, p.OwnerID, string(def), p.CreatedAt.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: pipeline %s", ErrDuplicate, p.Name)
+		}
+		return nil, fmt.Errorf("insert pipeline: %w", err)
+	}
+	r.logger(ctx).Info("pipeline created", zap.String("id", p.ID), zap.String("owner", p.OwnerID),
+		zap.Int("steps", len(p.Steps)))
+	return p, nil
+}
+
+// pipelineDefinition is the stored form of a pipeline's steps.
+type pipelineDefinition struct {
+	Description string            `json:"description,omitempty"`
+	Steps       []*PipelineStep   `json:"steps"`
+	Output      map[string]string `json:"output,omitempty"`
+}
+
+// GetPipeline returns a pipeline of the context namespace.
+func (r *Registry) GetPipeline(ctx context.Context, id string) (*Pipeline, error) {
+	var (
+		p       Pipeline
+		def     string
+		created int64
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, owner_id, definition, created_at FROM pipelines WHERE id = ? AND namespace = ?
+	`, id, NamespaceFrom(ctx)).Scan(&p.ID, &p.Name, &p.OwnerID, &def, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline: %w", err)
+	}
+	var d pipelineDefinition
+	if err := json.Unmarshal([]byte(def), &d); err != nil {
+		return nil, fmt.Errorf("unmarshal pipeline: %w", err)
+	}
+	p.Description, p.Steps, p.Output = d.Description, d.Steps, d.Output
+	p.CreatedAt = time.Unix(created, 0).UTC()
+	return &p, nil
+}
+
+// DeletePipeline removes a pipeline; only its owner may. The records of its
+// runs are kept.
+func (r *Registry) DeletePipeline(ctx context.Context, id, callerID string) error {
+	p, err := r.GetPipeline(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p.OwnerID != callerID {
+		return fmt.Errorf("%w: only the owner of a pipeline may delete it", ErrForbidden)
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM pipelines WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete pipeline: %w", err)
+	}
+	return nil
+}
+
+// RecordPipelineRun stores the record of a finished run, with its receipt.
+func (r *Registry) RecordPipelineRun(ctx context.Context, run *PipelineRun) error {
+	receipt, err := json.Marshal(run.Receipt)
+	if err != nil {
+		return fmt.Errorf("marshal receipt: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO pipeline_runs (id, pipeline_id, namespace, consumer_id, status, error, receipt, started_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.ID, run.PipelineID, NamespaceFrom(ctx), run.ConsumerID, run.Status, run.Error, string(receipt),
+		run.StartedAt.Unix(), run.CompletedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("insert pipeline run: %w", err)
+	}
+	return nil
+}
+
+// GetPipelineRun returns the record of a run in the context namespace.
+func (r *Registry) GetPipelineRun(ctx context.Context, id string) (*PipelineRun, error) {
+	var (
+		run                PipelineRun
+		receipt            string
+		started, completed int64
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, pipeline_id, consumer_id, status, error, receipt, started_at, completed_at
+		FROM pipeline_runs WHERE id = ? AND namespace = ?
+	`, id, NamespaceFrom(ctx)).Scan(&run.ID, &run.PipelineID, &run.ConsumerID, &run.Status, &run.Error, &receipt,
+		&started, &completed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline run: %w", err)
+	}
+	if err := json.Unmarshal([]byte(receipt), &run.Receipt); err != nil {
+		return nil, fmt.Errorf("unmarshal receipt: %w", err)
+	}
+	run.StartedAt, run.CompletedAt = time.Unix(started, 0).UTC(), time.Unix(completed, 0).UTC()
+	return &run, nil
+}
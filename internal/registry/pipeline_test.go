@@ -0,0 +1,145 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stepIDs(stages [][]*registry.PipelineStep) [][]string {
+	var ids [][]string
+	for _, stage := range stages {
+		var s []string
+		for _, step := range stage {
+			s = append(s, step.ID)
+		}
+		ids = append(ids, s)
+	}
+	return ids
+}
+
+func TestPipeline_Stages(t *testing.T) {
+	p := &registry.Pipeline{Steps: []*registry.PipelineStep{
+		{ID: "report", ToolID: "t", Map: map[string]string{"lint": "steps.lint", "fix": "steps.fix.code"}},
+		{ID: "lint", ToolID: "t", Map: map[string]string{"code": "input.code"}},
+		{ID: "fix", ToolID: "t", Map: map[string]string{"issues": "steps.lint.issues"}},
+		{ID: "notify", ToolID: "t", After: []string{"lint"}},
+	}}
+	stages, err := p.Stages()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"lint"}, {"fix", "notify"}, {"report"}}, stepIDs(stages))
+
+	input := map[string]any{"code": "x := 1"}
+	outputs := map[string]map[string]any{
+		"lint": {"issues": []any{"unused"}},
+		"fix":  {"code": "_ = 1"},
+	}
+	in, err := p.Steps[0].StepInput(input, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"lint": outputs["lint"], "fix": "_ = 1"}, in)
+	in, err = p.Steps[1].StepInput(input, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"code": "x := 1"}, in)
+	_, err = (&registry.PipelineStep{Map: map[string]string{"x": "input.missing"}}).StepInput(input, outputs)
+	assert.Error(t, err)
+	_, err = (&registry.PipelineStep{Map: map[string]string{"x": "input.code.line"}}).StepInput(input, outputs)
+	assert.ErrorContains(t, err, "not an object")
+	in, err = (&registry.PipelineStep{Input: map[string]any{"mode": "strict"}, Map: map[string]string{"all": "input"}}).
+		StepInput(input, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"mode": "strict", "all": input}, in)
+
+	outputs["report"], outputs["notify"] = map[string]any{"ok": true}, map[string]any{}
+	out, err := p.Result(input, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"report": outputs["report"], "notify": outputs["notify"]}, out, "the sinks")
+	p.Output = map[string]string{"fixed": "steps.fix.code"}
+	out, err = p.Result(input, outputs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"fixed": "_ = 1"}, out)
+}
+
+func TestCreatePipeline(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	p, err := r.CreatePipeline(ctx, &registry.Pipeline{
+		Name: "audit", OwnerID: "owner",
+		Steps: []*registry.PipelineStep{
+			{ID: "a", ToolID: tool.ID, Input: map[string]any{"mode": "strict"}},
+			{ID: "b", ToolID: tool.ID, Map: map[string]string{"prev": "steps.a"}},
+		},
+	})
+	require.NoError(t, err)
+	got, err := r.GetPipeline(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, p, got)
+
+	_, err = r.CreatePipeline(ctx, &registry.Pipeline{Name: "audit", OwnerID: "owner", Steps: p.Steps})
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+
+	for name, steps := range map[string][]*registry.PipelineStep{
+		"no steps":  nil,
+		"bad id":    {{ID: "Bad ID", ToolID: tool.ID}},
+		"twice":     {{ID: "a", ToolID: tool.ID}, {ID: "a", ToolID: tool.ID}},
+		"no tool":   {{ID: "a"}},
+		"unknown":   {{ID: "a", ToolID: "did:claw:tool:missing"}},
+		"bad ref":   {{ID: "a", ToolID: tool.ID, Map: map[string]string{"x": "output.y"}}},
+		"no step":   {{ID: "a", ToolID: tool.ID, Map: map[string]string{"x": "steps.b"}}},
+		"self":      {{ID: "a", ToolID: tool.ID, After: []string{"a"}}},
+		"cycle":     {{ID: "a", ToolID: tool.ID, After: []string{"b"}}, {ID: "b", ToolID: tool.ID, After: []string{"a"}}},
+		"bad after": {{ID: "a", ToolID: tool.ID, After: []string{"c"}}},
+	} {
+		_, err := r.CreatePipeline(ctx, &registry.Pipeline{Name: "bad-" + name, OwnerID: "owner", Steps: steps})
+		assert.ErrorIs(t, err, registry.ErrInvalidPipeline, name)
+	}
+	_, err = r.CreatePipeline(ctx, &registry.Pipeline{Name: " ", OwnerID: "owner", Steps: p.Steps})
+	assert.ErrorIs(t, err, registry.ErrInvalidPipeline, "no name")
+	_, err = r.CreatePipeline(ctx, &registry.Pipeline{Name: "bad-output", OwnerID: "owner", Steps: p.Steps,
+		Output: map[string]string{"x": "steps.c"}})
+	assert.ErrorIs(t, err, registry.ErrInvalidPipeline, "bad output")
+
+	assert.ErrorIs(t, r.DeletePipeline(ctx, p.ID, "someone"), registry.ErrForbidden)
+	require.NoError(t, r.DeletePipeline(ctx, p.ID, "owner"))
+	_, err = r.GetPipeline(ctx, p.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestPipelineRun_Receipt(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	run := &registry.PipelineRun{
+		ID: "run_1", PipelineID: "pipe_1", ConsumerID: "consumer", Status: registry.PipelineCompleted,
+		StartedAt: now, CompletedAt: now,
+		Receipt: &registry.PipelineReceipt{
+			RunID: "run_1", PipelineID: "pipe_1", ConsumerID: "consumer", ExecutedAt: now,
+			InputHash: registry.HashPayload(map[string]any{}),
+			Steps: []*registry.StepReceipt{
+				{StepID: "a", InvocationID: "inv_1", CostCLAW: "1.5"},
+				{StepID: "b", InvocationID: "inv_2", CostCLAW: "0.25"},
+			},
+		},
+	}
+	require.NoError(t, run.Receipt.Seal())
+	assert.Equal(t, "1.75", run.Receipt.CostCLAW)
+	hash := run.Receipt.Hash
+	require.NoError(t, run.Receipt.Seal())
+	assert.Equal(t, hash, run.Receipt.Hash, "sealing is deterministic")
+	run.Receipt.Steps[1].InvocationID = "inv_3"
+	require.NoError(t, run.Receipt.Seal())
+	assert.NotEqual(t, hash, run.Receipt.Hash)
+
+	require.NoError(t, r.RecordPipelineRun(ctx, run))
+	got, err := r.GetPipelineRun(ctx, "run_1")
+	require.NoError(t, err)
+	assert.Equal(t, run, got)
+	_, err = r.GetPipelineRun(ctx, "run_2")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
@@ -0,0 +1,352 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/policy"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrPolicyDenied is returned for an invocation an operator policy
+	// does not allow.
+	ErrPolicyDenied = errors.New("denied by policy")
+	// ErrInvalidPolicy is returned for a policy without a name or whose
+	// expressions do not compile.
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// policyDecisionRetention is how long the decisions of policies are kept.
+const policyDecisionRetention = 30 * 24 * time.Hour
+
+// PolicyAttributes are the attributes of an invocation policies may test:
+// the consumer's DID and the names of its organizations, the tool's ID,
+// name, version, provider DID, tags, pricing model and price per unit,
+// the estimated cost of the call in CLAW and the namespace. Prices and
+// costs not known before the call are 0.
+var PolicyAttributes = []string{
+	"consumer.id", "consumer.orgs",
+	"tool.id", "tool.name", "tool.version", "tool.provider", "tool.tags", "tool.pricing_model", "tool.price",
+	"cost", "namespace",
+}
+
+// Policy governs invocations: those for which When holds, or all of them
+// when it is empty, are refused unless Require holds too. Both are
+// expressions of the policy package over PolicyAttributes, e.g. When
+// `"acme" in consumer.orgs` and Require `"approved" in tool.tags and
+// cost <= 1`. Every policy that governs an invocation must allow it.
+type Policy struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	When      string    `json:"when,omitempty"`
+	Require   string    `json:"require"`
+	// Reason is told to the consumers the policy refuses.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyDecision records how the policies governing an invocation decided
+// it. Invocations no policy governs are not recorded.
+type PolicyDecision struct {
+	DecidedAt  time.Time `json:"decided_at"`
+	ID         int64     `json:"id"`
+	Namespace  string    `json:"namespace"`
+	ConsumerID string    `json:"consumer_id"`
+	ToolID     string    `json:"tool_id"`
+	Allowed    bool      `json:"allowed"`
+	// Policies names the policies that governed the invocation, up to the
+	// one that refused it.
+	Policies []string `json:"policies"`
+	DeniedBy string   `json:"denied_by,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// PolicyDecisionFilter narrows the decisions listed; zero fields are
+// ignored.
+type PolicyDecisionFilter struct {
+	ConsumerID string
+	ToolID     string
+	DeniedOnly bool
+	// Before lists only decisions older than the one with this ID.
+	Before int64
+}
+
+// compiledPolicy is a policy with its expressions compiled; when is nil
+// for a policy governing every invocation.
+type compiledPolicy struct {
+	*Policy
+	when, require *policy.Expr
+}
+
+func compilePolicy(p *Policy) (*compiledPolicy, error) {
+	c := &compiledPolicy{Policy: p}
+	var err error
+	if p.When != "" {
+		if c.when, err = policy.Compile(p.When, PolicyAttributes); err != nil {
+			return nil, fmt.Errorf("%w: when: %v", ErrInvalidPolicy, err)
+		}
+	}
+	if c.require, err = policy.Compile(p.Require, PolicyAttributes); err != nil {
+		return nil, fmt.Errorf("%w: require: %v", ErrInvalidPolicy, err)
+	}
+	return c, nil
+}
+
+// AddPolicy stores a new policy. It governs the next invocation.
+func (r *Registry) AddPolicy(ctx context.Context, p *Policy) (*Policy, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidPolicy)
+	}
+	if _, err := compilePolicy(p); err != nil {
+		return nil, err
+	}
+	p.ID = "pol_" + uuid.NewString()
+	p.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO policies (id, name, applies_when, require, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, p.ID, p.Name, p.When, p.Require, p.Reason, p.CreatedAt.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: policy %s", ErrDuplicate, p.Name)
+		}
+		return nil, fmt.Errorf("insert policy: %w", err)
+	}
+	r.logger(ctx).Info("policy added", zap.String("id", p.ID), zap.String("name", p.Name),
+		zap.String("when", p.When), zap.String("require", p.Require))
+	return p, nil
+}
+
+// ListPolicies returns all policies, oldest first.
+func (r *Registry) ListPolicies(ctx context.Context) ([]*Policy, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, name, applies_when, require, reason, created_at FROM policies ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("list policies: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	policies := []*Policy{}
+	for rows.Next() {
+		var (
+			p       Policy
+			created int64
+		)
+		if err := rows.Scan(&p.ID, &p.Name, &p.When, &p.Require, &p.Reason, &created); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = time.Unix(created, 0).UTC()
+		policies = append(policies, &p)
+	}
+	return policies, rows.Err()
+}
+
+// DeletePolicy removes a policy.
+func (r *Registry) DeletePolicy(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM policies WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete policy: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	r.logger(ctx).Info("policy deleted", zap.String("id", id))
+	return nil
+}
+
+// CheckPolicies returns ErrPolicyDenied when a policy refuses an invocation
+// of tool by consumerID estimated to cost cost, and records the decision
+// of the policies that governed it. A policy that cannot be evaluated, such
+// as one comparing a price with a string, refuses the invocation.
+func (r *Registry) CheckPolicies(ctx context.Context, tool *Tool, consumerID, cost string) error {
+	d, err := r.decidePolicies(ctx, tool, consumerID, cost)
+	if err != nil || d == nil {
+		return err
+	}
+	policies, err := json.Marshal(d.Policies)
+	if err != nil {
+		return fmt.Errorf("marshal policies: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO policy_decisions (namespace, consumer_id, tool_id, allowed, policies, denied_by, reason, decided_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.Namespace, d.ConsumerID, d.ToolID, d.Allowed, string(policies), d.DeniedBy, d.Reason, d.DecidedAt.Unix()); err != nil {
+		return fmt.Errorf("record policy decision: %w", err)
+	}
+	log := r.logger(ctx).With(zap.String("consumer_id", consumerID), zap.String("tool_id", tool.ID),
+		zap.Strings("policies", d.Policies))
+	if d.Allowed {
+		log.Debug("invocation allowed by policy")
+		return nil
+	}
+	log.Info("invocation denied by policy", zap.String("policy", d.DeniedBy), zap.String("reason", d.Reason))
+	return d.err()
+}
+
+// decidePolicies evaluates the policies governing an invocation of tool by
+// consumerID, or returns nil when none does.
+func (r *Registry) decidePolicies(ctx context.Context, tool *Tool, consumerID, cost string) (*PolicyDecision, error) {
+	policies, err := r.ListPolicies(ctx)
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	vars, err := r.policyVars(ctx, tool, consumerID, cost)
+	if err != nil {
+		return nil, err
+	}
+	d := &PolicyDecision{
+		DecidedAt:  time.Now().UTC().Truncate(time.Second),
+		Namespace:  NamespaceFrom(ctx),
+		ConsumerID: consumerID,
+		ToolID:     tool.ID,
+		Allowed:    true,
+	}
+	for _, p := range policies {
+		c, err := compilePolicy(p)
+		governs := err == nil
+		if err == nil && c.when != nil {
+			governs, err = c.when.Eval(vars)
+		}
+		allowed := err == nil
+		if governs && err == nil {
+			allowed, err = c.require.Eval(vars)
+		}
+		if !governs && err == nil {
+			continue
+		}
+		d.Policies = append(d.Policies, p.Name)
+		if !allowed {
+			d.Allowed, d.DeniedBy, d.Reason = false, p.Name, p.Reason
+			switch {
+			case err != nil:
+				d.Reason = "policy could not be evaluated: " + err.Error()
+			case d.Reason == "":
+				d.Reason = "requires " + p.Require
+			}
+			break
+		}
+	}
+	if len(d.Policies) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// err returns the error refusing the invocation d denied.
+func (d *PolicyDecision) err() error {
+	if d == nil || d.Allowed {
+		return nil
+	}
+	return fmt.Errorf("%w %s: %s", ErrPolicyDenied, d.DeniedBy, d.Reason)
+}
+
+// policyVars returns the attributes policies test of an invocation of tool
+// by consumerID.
+func (r *Registry) policyVars(ctx context.Context, tool *Tool, consumerID, cost string) (map[string]any, error) {
+	orgs := []string{}
+	if IsOrg(consumerID) {
+		orgs = append(orgs, strings.TrimPrefix(consumerID, orgPrefix))
+	} else {
+		memberships, err := r.MemberOrgs(ctx, consumerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range memberships {
+			orgs = append(orgs, strings.TrimPrefix(m.OrgID, orgPrefix))
+		}
+	}
+	var (
+		model string
+		price float64
+	)
+	if tool.Pricing != nil {
+		model = string(tool.Pricing.Model)
+		price, _ = strconv.ParseFloat(tool.Pricing.AmountCLAW, 64)
+	}
+	estimate, _ := strconv.ParseFloat(cost, 64)
+	tags := tool.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return map[string]any{
+		"consumer.id":        consumerID,
+		"consumer.orgs":      orgs,
+		"tool.id":            tool.ID,
+		"tool.name":          tool.Name,
+		"tool.version":       tool.Version,
+		"tool.provider":      tool.ProviderID,
+		"tool.tags":          tags,
+		"tool.pricing_model": model,
+		"tool.price":         price,
+		"cost":               estimate,
+		"namespace":          tool.Namespace,
+	}, nil
+}
+
+// ListPolicyDecisions returns up to limit decisions matching f, newest
+// first.
+func (r *Registry) ListPolicyDecisions(ctx context.Context, f PolicyDecisionFilter, limit int) ([]*PolicyDecision, error) {
+	where := "WHERE 1 = 1"
+	var args []any
+	if f.ConsumerID != "" {
+		where += " AND consumer_id = ?"
+		args = append(args, f.ConsumerID)
+	}
+	if f.ToolID != "" {
+		where += " AND tool_id = ?"
+		args = append(args, f.ToolID)
+	}
+	if f.DeniedOnly {
+		where += " AND allowed = 0"
+	}
+	if f.Before > 0 {
+		where += " AND id < ?"
+		args = append(args, f.Before)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, namespace, consumer_id, tool_id, allowed, policies, denied_by, reason, decided_at
+		FROM policy_decisions `+where+` ORDER BY id DESC LIMIT ?`, append(args, listLimit(limit))...)
+	if err != nil {
+		return nil, fmt.Errorf("list policy decisions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	decisions := []*PolicyDecision{}
+	for rows.Next() {
+		var (
+			d        PolicyDecision
+			policies string
+			decided  int64
+		)
+		if err := rows.Scan(&d.ID, &d.Namespace, &d.ConsumerID, &d.ToolID, &d.Allowed, &policies, &d.DeniedBy,
+			&d.Reason, &decided); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(policies), &d.Policies); err != nil {
+			return nil, fmt.Errorf("unmarshal policies: %w", err)
+		}
+		d.DecidedAt = time.Unix(decided, 0).UTC()
+		decisions = append(decisions, &d)
+	}
+	return decisions, rows.Err()
+}
+
+// PurgePolicyDecisions deletes the decisions of policies past their
+// retention.
+func (r *Registry) PurgePolicyDecisions(ctx context.Context) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM policy_decisions WHERE decided_at < ?",
+		time.Now().Add(-policyDecisionRetention).Unix())
+	if err != nil {
+		return fmt.Errorf("purge policy decisions: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		r.logger(ctx).Debug("policy decisions purged", zap.Int64("rows", n))
+	}
+	return nil
+}
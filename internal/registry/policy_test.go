@@ -0,0 +1,106 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	const (
+		member   = "did:claw:agent:member"
+		outsider = "did:claw:agent:outsider"
+	)
+	_, err := r.CreateOrg(ctx, "acme", member)
+	require.NoError(t, err)
+
+	register := func(name, price string, tags ...string) *registry.Tool {
+		req := validRegisterReq()
+		req.Name, req.Tags = name, tags
+		req.Pricing = &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: price}
+		tool, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+		return tool
+	}
+	cheap := register("cheap", "0.5", "approved")
+	pricey := register("pricey", "5", "approved")
+	unvetted := register("unvetted", "0.1")
+
+	require.NoError(t, r.CheckPolicies(ctx, unvetted, member, "0.1"), "no policies, nothing to check")
+
+	p, err := r.AddPolicy(ctx, &registry.Policy{
+		Name:    "acme-approved",
+		When:    `"acme" in consumer.orgs`,
+		Require: `"approved" in tool.tags and cost <= 1`,
+		Reason:  "acme may only use approved tools under 1 CLAW a call",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, p.ID)
+
+	assert.NoError(t, r.CheckPolicies(ctx, cheap, member, "0.5"))
+	err = r.CheckPolicies(ctx, pricey, member, "5")
+	assert.ErrorIs(t, err, registry.ErrPolicyDenied)
+	assert.ErrorContains(t, err, "acme may only use approved tools")
+	assert.ErrorIs(t, r.CheckPolicies(ctx, unvetted, "did:claw:org:acme", "0.1"), registry.ErrPolicyDenied,
+		"the org itself is in the org")
+	assert.NoError(t, r.CheckPolicies(ctx, pricey, outsider, "5"), "not governed")
+
+	decisions, err := r.ListPolicyDecisions(ctx, registry.PolicyDecisionFilter{}, 0)
+	require.NoError(t, err)
+	require.Len(t, decisions, 3, "the outsider's invocation is not logged")
+	assert.Equal(t, "did:claw:org:acme", decisions[0].ConsumerID)
+	assert.False(t, decisions[1].Allowed)
+	assert.Equal(t, "acme-approved", decisions[1].DeniedBy)
+	assert.Equal(t, []string{"acme-approved"}, decisions[1].Policies)
+	assert.True(t, decisions[2].Allowed)
+	assert.Equal(t, cheap.ID, decisions[2].ToolID)
+
+	denied, err := r.ListPolicyDecisions(ctx, registry.PolicyDecisionFilter{ConsumerID: member, DeniedOnly: true}, 0)
+	require.NoError(t, err)
+	require.Len(t, denied, 1)
+	assert.Equal(t, pricey.ID, denied[0].ToolID)
+	older, err := r.ListPolicyDecisions(ctx, registry.PolicyDecisionFilter{Before: decisions[1].ID}, 0)
+	require.NoError(t, err)
+	require.Len(t, older, 1)
+	assert.Equal(t, decisions[2].ID, older[0].ID)
+
+	// A policy that cannot be evaluated refuses what it governs.
+	_, err = r.AddPolicy(ctx, &registry.Policy{Name: "broken", Require: `tool.price == "free"`})
+	require.NoError(t, err)
+	err = r.CheckPolicies(ctx, pricey, outsider, "5")
+	assert.ErrorIs(t, err, registry.ErrPolicyDenied)
+	assert.ErrorContains(t, err, "could not be evaluated")
+
+	policies, err := r.ListPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	for _, p := range policies {
+		require.NoError(t, r.DeletePolicy(ctx, p.ID))
+	}
+	assert.ErrorIs(t, r.DeletePolicy(ctx, p.ID), registry.ErrNotFound)
+	assert.NoError(t, r.CheckPolicies(ctx, pricey, member, "5"))
+	assert.NoError(t, r.PurgePolicyDecisions(ctx))
+}
+
+func TestAddPolicy_Invalid(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	for _, p := range []*registry.Policy{
+		{Require: "true"},
+		{Name: "no-require"},
+		{Name: "bad-when", When: `consumer.group == "x"`, Require: "true"},
+		{Name: "bad-require", Require: `cost <=`},
+	} {
+		_, err := r.AddPolicy(ctx, p)
+		assert.ErrorIs(t, err, registry.ErrInvalidPolicy, p.Name)
+	}
+	_, err := r.AddPolicy(ctx, &registry.Policy{Name: "dup", Require: "true"})
+	require.NoError(t, err)
+	_, err = r.AddPolicy(ctx, &registry.Policy{Name: "dup", Require: "true"})
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+}
@@ -0,0 +1,225 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+)
+
+// DefaultPriceNotice is how long after a price rise is registered it is
+// first charged, unless changed with WithPriceNotice.
+const DefaultPriceNotice = 72 * time.Hour
+
+// WithPriceNotice sets the notice period of price rises: a version that
+// raises the price of its line, or changes how it is priced, is charged
+// the price it replaces until d after it is registered. Price cuts apply
+// at once, as do rises when d is zero.
+func WithPriceNotice(d time.Duration) Option {
+	return func(r *Registry) { r.notice = d }
+}
+
+// PricingNotice is a price rise announced ahead of being charged.
+type PricingNotice struct {
+	Pricing     *Pricing  `json:"pricing"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// PricingChange is a change of the pricing of a line of tool versions,
+// which share a provider and a name: a version registered at another price
+// than the one before it. The first version of a line is a change from no
+// pricing.
+type PricingChange struct {
+	ChangedAt time.Time `json:"changed_at"`
+	// EffectiveAt is when the new pricing is first charged: ChangedAt, or
+	// the end of the notice period of a rise.
+	EffectiveAt time.Time `json:"effective_at"`
+	// Old is nil for the first version of the line.
+	Old       *Pricing `json:"old_pricing"`
+	New       *Pricing `json:"new_pricing"`
+	ToolID    string   `json:"tool_id"`
+	Version   string   `json:"version"`
+	ChangedBy string   `json:"changed_by"`
+	// Increase is set when the new pricing costs more per unit than the
+	// old one under the same model, or charges where the old one was free.
+	Increase bool `json:"increase"`
+}
+
+// recordPricing records the pricing of a newly registered tool, in tx, if
+// it differs from that of the latest earlier version of its line, and
+// holds the tool at the price charged so far while a rise is under notice.
+// It returns the change, or nil for none.
+func (r *Registry) recordPricing(ctx context.Context, tx *sql.Tx, t *Tool, by string) (*PricingChange, error) {
+	ns := NamespaceFrom(ctx)
+	var (
+		old, before sql.NullString
+		pendingAt   int64
+	)
+	err := tx.QueryRowContext(ctx, `
+		SELECT old_pricing, new_pricing, effective_at FROM pricing_changes
+		WHERE namespace = ? AND provider_id = ? AND name = ? AND tool_id != ?
+		ORDER BY changed_at DESC, id DESC LIMIT 1
+	`, ns, t.ProviderID, t.Name, t.ID).Scan(&before, &old, &pendingAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("latest pricing: %w", err)
+	}
+	newJSON, err := json.Marshal(t.Pricing)
+	if err != nil {
+		return nil, fmt.Errorf("marshal pricing: %w", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &PricingChange{
+		ChangedAt:   now,
+		EffectiveAt: now,
+		New:         t.Pricing,
+		ToolID:      t.ID,
+		Version:     t.Version,
+		ChangedBy:   by,
+	}
+	if old.Valid {
+		if c.Old, err = decodePricing(old.String); err != nil {
+			return nil, err
+		}
+		// While the latest change is under notice, the line is still
+		// charged the price that change replaced.
+		charged, pending := c.Old, time.Unix(pendingAt, 0).UTC()
+		if pending.After(now) && before.Valid {
+			if charged, err = decodePricing(before.String); err != nil {
+				return nil, err
+			}
+		}
+		if *c.Old == *c.New {
+			if pending.After(now) {
+				return nil, holdPricing(ctx, tx, t.ID, charged, pending)
+			}
+			return nil, nil
+		}
+		c.Increase = c.Old.costsLessThan(c.New)
+		if r.notice > 0 && !c.New.costsLessThan(charged) {
+			c.EffectiveAt = now.Add(r.notice)
+			if err := holdPricing(ctx, tx, t.ID, charged, c.EffectiveAt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pricing_changes (tool_id, namespace, provider_id, name, version, old_pricing, new_pricing,
+			changed_by, changed_at, effective_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, ns, t.ProviderID, t.Name, t.Version, old, string(newJSON), by, c.ChangedAt.Unix(), c.EffectiveAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("record pricing: %w", err)
+	}
+	return c, nil
+}
+
+// holdPricing charges tool id the pricing charged until a rise takes
+// effect at until.
+func holdPricing(ctx context.Context, tx *sql.Tx, id string, charged *Pricing, until time.Time) error {
+	b, err := json.Marshal(charged)
+	if err != nil {
+		return fmt.Errorf("marshal pricing: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE tools SET notice_pricing = ?, pricing_effective_at = ? WHERE id = ?",
+		string(b), until.Unix(), id); err != nil {
+		return fmt.Errorf("hold pricing: %w", err)
+	}
+	return nil
+}
+
+// applyPricingNotice charges t the pricing it is held at while its rise is
+// under notice, and announces the rise.
+func applyPricingNotice(t *Tool, noticeJSON string, effectiveAt int64) (*Tool, error) {
+	at := time.Unix(effectiveAt, 0).UTC()
+	if noticeJSON == "" || !at.After(time.Now()) {
+		return t, nil
+	}
+	charged, err := decodePricing(noticeJSON)
+	if err != nil {
+		return nil, err
+	}
+	t.PricingNotice = &PricingNotice{Pricing: t.Pricing, EffectiveAt: at}
+	t.Pricing = charged
+	return t, nil
+}
+
+// PricingHistory returns the pricing changes of the line of versions tool
+// id belongs to, newest first, including changes made by versions
+// registered after it.
+func (r *Registry) PricingHistory(ctx context.Context, id string) ([]*PricingChange, error) {
+	tool, err := r.GetTool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tool_id, version, old_pricing, new_pricing, changed_by, changed_at, effective_at FROM pricing_changes
+		WHERE namespace = ? AND provider_id = ? AND name = ?
+		ORDER BY changed_at DESC, id DESC
+	`, tool.Namespace, tool.ProviderID, tool.Name)
+	if err != nil {
+		return nil, fmt.Errorf("pricing history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	changes := []*PricingChange{}
+	for rows.Next() {
+		var (
+			c        PricingChange
+			old      sql.NullString
+			newJSON  string
+			changeAt int64
+			effectAt int64
+		)
+		if err := rows.Scan(&c.ToolID, &c.Version, &old, &newJSON, &c.ChangedBy, &changeAt, &effectAt); err != nil {
+			return nil, fmt.Errorf("scan pricing change: %w", err)
+		}
+		if c.New, err = decodePricing(newJSON); err != nil {
+			return nil, err
+		}
+		if old.Valid {
+			if c.Old, err = decodePricing(old.String); err != nil {
+				return nil, err
+			}
+			c.Increase = c.Old.costsLessThan(c.New)
+		}
+		c.ChangedAt = time.Unix(changeAt, 0).UTC()
+		c.EffectiveAt = time.Unix(effectAt, 0).UTC()
+		changes = append(changes, &c)
+	}
+	return changes, rows.Err()
+}
+
+// publishPricing announces a change of a line's pricing, ahead of its
+// EffectiveAt for a rise under notice; the first version of a line is
+// announced as registered instead.
+func (r *Registry) publishPricing(ctx context.Context, c *PricingChange) {
+	if c != nil && c.Old != nil {
+		r.publish(ctx, events.ToolPricingChanged, c.ToolID, "", c)
+	}
+}
+
+func decodePricing(s string) (*Pricing, error) {
+	var p Pricing
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil, fmt.Errorf("unmarshal pricing: %w", err)
+	}
+	return &p, nil
+}
+
+// costsLessThan reports whether p is free and q is not, or both price the
+// same unit and q's amount is higher.
+func (p *Pricing) costsLessThan(q *Pricing) bool {
+	if p.Model == PricingFree || q.Model == PricingFree {
+		return p.Model == PricingFree && q.Model != PricingFree
+	}
+	if p.Model != q.Model {
+		return false
+	}
+	old, err1 := strconv.ParseFloat(p.AmountCLAW, 64)
+	amount, err2 := strconv.ParseFloat(q.AmountCLAW, 64)
+	return err1 == nil && err2 == nil && amount > old
+}
@@ -0,0 +1,120 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPricingHistory(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	register := func(version string, p *registry.Pricing) *registry.Tool {
+		req := validRegisterReq()
+		req.Version = version
+		req.Pricing = p
+		tool, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+		return tool
+	}
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+
+	v1 := register("1.0.0", &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "1"})
+	register("1.1.0", &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "1"})
+	v2 := register("2.0.0", &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "2.5"})
+	v3 := register("3.0.0", nil)
+	other := validRegisterReq()
+	other.Name = "other-tool"
+	_, err := r.RegisterTool(ctx, other)
+	require.NoError(t, err)
+
+	changes, err := r.PricingHistory(ctx, v1.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 3, "unchanged prices and other tools are not changes")
+	assert.Equal(t, v3.ID, changes[0].ToolID)
+	assert.Equal(t, registry.PricingFree, changes[0].New.Model)
+	assert.False(t, changes[0].Increase)
+	assert.Equal(t, v2.ID, changes[1].ToolID)
+	assert.Equal(t, "2.0.0", changes[1].Version)
+	assert.Equal(t, &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: "1"}, changes[1].Old)
+	assert.Equal(t, "2.5", changes[1].New.AmountCLAW)
+	assert.True(t, changes[1].Increase)
+	assert.Equal(t, validRegisterReq().ProviderID, changes[1].ChangedBy)
+	assert.Equal(t, v1.ID, changes[2].ToolID)
+	assert.Nil(t, changes[2].Old, "the first version is a change from nothing")
+
+	var changed []registry.PricingChange
+	for len(ch) > 0 {
+		ev := <-ch
+		if ev.Type == events.ToolPricingChanged {
+			var c registry.PricingChange
+			require.NoError(t, json.Unmarshal(ev.Data, &c))
+			changed = append(changed, c)
+		}
+	}
+	require.Len(t, changed, 2)
+	assert.Equal(t, v2.ID, changed[0].ToolID)
+	assert.True(t, changed[0].Increase)
+
+	_, err = r.PricingHistory(ctx, "did:claw:tool:missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestPriceNotice(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithPriceNotice(time.Hour))
+	perCall := func(amount string) *registry.Pricing {
+		return &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: amount}
+	}
+	register := func(version string, p *registry.Pricing) *registry.Tool {
+		req := validRegisterReq()
+		req.Version = version
+		req.Pricing = p
+		tool, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+		return tool
+	}
+	register("1.0.0", perCall("1"))
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+
+	v2 := register("2.0.0", perCall("2"))
+	assert.Equal(t, perCall("1"), v2.Pricing, "charged the old price during the notice")
+	require.NotNil(t, v2.PricingNotice)
+	assert.Equal(t, perCall("2"), v2.PricingNotice.Pricing)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), v2.PricingNotice.EffectiveAt, 2*time.Second)
+	assert.Equal(t, "1", registry.EstimateCost(v2, []byte(`{}`)))
+	var notice *registry.PricingChange
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.ToolPricingChanged {
+			require.NoError(t, json.Unmarshal(ev.Data, &notice))
+		}
+	}
+	require.NotNil(t, notice, "subscribers are told ahead of the rise")
+	assert.Equal(t, v2.PricingNotice.EffectiveAt, notice.EffectiveAt)
+
+	v21 := register("2.1.0", perCall("2"))
+	assert.Equal(t, perCall("1"), v21.Pricing, "a new version does not cut the notice short")
+	require.NotNil(t, v21.PricingNotice)
+	assert.Equal(t, v2.PricingNotice.EffectiveAt, v21.PricingNotice.EffectiveAt)
+
+	v3 := register("3.0.0", perCall("0.5"))
+	assert.Equal(t, perCall("0.5"), v3.Pricing, "cuts apply at once")
+	assert.Nil(t, v3.PricingNotice)
+
+	_, err := db.ExecContext(ctx, "UPDATE tools SET pricing_effective_at = ? WHERE id = ?", time.Now().Add(-time.Second).Unix(), v2.ID)
+	require.NoError(t, err)
+	v2, err = r.GetTool(ctx, v2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, perCall("2"), v2.Pricing, "charged the new price once the notice is over")
+	assert.Nil(t, v2.PricingNotice)
+}
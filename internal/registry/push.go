@@ -0,0 +1,34 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PushScheme prefixes the endpoint of a tool served by a push provider,
+// one that polls the registry for invocations instead of accepting
+// connections: "push://gpu-workers" queues invocations on the provider's
+// gpu-workers channel.
+const PushScheme = "push://"
+
+var pushChannel = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// PushChannel returns the channel a push:// endpoint names.
+func PushChannel(endpoint string) (string, bool) {
+	channel, ok := strings.CutPrefix(endpoint, PushScheme)
+	return channel, ok && pushChannel.MatchString(channel)
+}
+
+// checkPush verifies that a push:// endpoint names a valid channel. Other
+// endpoints pass.
+func checkPush(endpoint string) error {
+	if !strings.HasPrefix(endpoint, PushScheme) {
+		return nil
+	}
+	if _, ok := PushChannel(endpoint); !ok {
+		return fmt.Errorf("%w: push endpoints are %s<channel> with a channel of up to 64 letters, digits, '.', '_' or '-'",
+			ErrInvalidEndpoint, PushScheme)
+	}
+	return nil
+}
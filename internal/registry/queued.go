@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type queuedKey struct{}
+
+// WithQueued marks ctx so an invocation recorded with it starts out
+// queued, waiting in the job queue, rather than pending. It is inserted
+// before RecordInvocation returns, like with WithSyncWrites, and is left
+// alone by InterruptPendingInvocations until StartQueuedInvocation picks it
+// up.
+func WithQueued(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queuedKey{}, true)
+}
+
+// IsQueued reports whether ctx was marked by WithQueued.
+func IsQueued(ctx context.Context) bool {
+	v, _ := ctx.Value(queuedKey{}).(bool)
+	return v
+}
+
+// StartQueuedInvocation moves queued invocation id to pending on this
+// instance, restarting its clock so its latency does not count the time it
+// spent queued. It reports false when the invocation is no longer queued.
+func (r *Registry) StartQueuedInvocation(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET status = 'pending', started_at = ?, instance_id = ?
+		WHERE id = ? AND status = 'queued'
+	`, time.Now().Unix(), r.instanceID, id)
+	if err != nil {
+		return false, fmt.Errorf("start queued invocation: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
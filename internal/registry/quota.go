@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrQuotaExceeded is returned when a consumer has used up its quota of
+	// calls to a tool for the current window.
+	ErrQuotaExceeded = errors.New("tool quota exceeded")
+	// ErrInvalidQuota is returned for a quota with no calls or an unknown
+	// period.
+	ErrInvalidQuota = errors.New("invalid quota")
+)
+
+// Quota periods.
+const (
+	QuotaMinute = "minute"
+	QuotaHour   = "hour"
+	QuotaDay    = "day"
+)
+
+// Quota limits how often each consumer may invoke a tool: Calls per Period,
+// counted in fixed windows that start on the UTC minute, hour or day.
+type Quota struct {
+	Period string `json:"period"`
+	Calls  int64  `json:"calls"`
+}
+
+// window returns the length of the quota's windows.
+func (q *Quota) window() time.Duration {
+	switch q.Period {
+	case QuotaMinute:
+		return time.Minute
+	case QuotaHour:
+		return time.Hour
+	case QuotaDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func (q *Quota) validate() error {
+	if q.Calls <= 0 {
+		return fmt.Errorf("%w: calls must be positive", ErrInvalidQuota)
+	}
+	if q.window() == 0 {
+		return fmt.Errorf("%w: period must be %s, %s or %s", ErrInvalidQuota, QuotaMinute, QuotaHour, QuotaDay)
+	}
+	return nil
+}
+
+// encodeQuota returns the stored form of q, "" for none.
+func encodeQuota(q *Quota) (string, error) {
+	if q == nil {
+		return "", nil
+	}
+	if err := q.validate(); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(q)
+	if err != nil {
+		return "", fmt.Errorf("marshal quota: %w", err)
+	}
+	return string(b), nil
+}
+
+// SetQuota replaces, or with a nil q removes, the per-consumer quota of a
+// tool owned by providerID. Calls already counted in the current window
+// count against the new quota.
+func (r *Registry) SetQuota(ctx context.Context, id, providerID string, q *Quota) error {
+	quota, err := encodeQuota(q)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE tools SET quota = ?, updated_at = ? WHERE id = ? AND provider_id = ? AND namespace = ?",
+		quota, time.Now().Unix(), id, providerID, NamespaceFrom(ctx))
+	if err != nil {
+		return fmt.Errorf("set quota: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w or not authorized", ErrNotFound)
+	}
+	r.logger(ctx).Info("tool quota updated", zap.String("id", id), zap.Bool("removed", q == nil))
+	return nil
+}
+
+// TakeQuota counts one call of tool by consumerID against the tool's quota
+// and returns when the current window ends. It returns ErrQuotaExceeded,
+// without counting the call, when the consumer has no calls left in the
+// window, and warns the consumer with an events.QuotaWarning when the call
+// uses up the warning threshold of the quota. Tools without a quota always
+// succeed.
+func (r *Registry) TakeQuota(ctx context.Context, tool *Tool, consumerID string) (time.Time, error) {
+	if tool.Quota == nil {
+		return time.Time{}, nil
+	}
+	window := tool.Quota.window()
+	start := time.Now().UTC().Truncate(window)
+	reset := start.Add(window)
+	// The counter restarts when a call falls in a new window, and is only
+	// bumped while calls are left in the current one.
+	var used int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO quota_usage (tool_id, consumer_id, window_start, calls) VALUES (?, ?, ?, 1)
+		ON CONFLICT(tool_id, consumer_id) DO UPDATE SET
+			calls = CASE WHEN window_start = excluded.window_start THEN calls + 1 ELSE 1 END,
+			window_start = excluded.window_start
+		WHERE window_start != excluded.window_start OR calls < ?
+		RETURNING calls
+	`, tool.ID, consumerID, start.Unix(), tool.Quota.Calls).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reset, fmt.Errorf("%w: %d calls per %s, resets at %s",
+			ErrQuotaExceeded, tool.Quota.Calls, tool.Quota.Period, reset.Format(time.RFC3339))
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("take quota: %w", err)
+	}
+	r.warnQuota(ctx, tool, consumerID, used, reset)
+	return reset, nil
+}
+
+// QuotaLeft returns how many calls consumerID has left of tool's quota in
+// the current window, and when the window ends, without counting a call.
+// Tools without a quota return -1.
+func (r *Registry) QuotaLeft(ctx context.Context, tool *Tool, consumerID string) (int64, time.Time, error) {
+	if tool.Quota == nil {
+		return -1, time.Time{}, nil
+	}
+	window := tool.Quota.window()
+	start := time.Now().UTC().Truncate(window)
+	var used int64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT calls FROM quota_usage WHERE tool_id = ? AND consumer_id = ? AND window_start = ?",
+		tool.ID, consumerID, start.Unix()).Scan(&used)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, fmt.Errorf("read quota: %w", err)
+	}
+	return max(tool.Quota.Calls-used, 0), start.Add(window), nil
+}
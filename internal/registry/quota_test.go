@@ -0,0 +1,108 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Quota = &registry.Quota{Calls: 2, Period: registry.QuotaHour}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req.Quota, tool.Quota)
+
+	for i := 0; i < 2; i++ {
+		reset, err := r.TakeQuota(ctx, tool, "consumer-a")
+		require.NoError(t, err)
+		assert.Equal(t, time.Now().UTC().Truncate(time.Hour).Add(time.Hour), reset)
+	}
+	reset, err := r.TakeQuota(ctx, tool, "consumer-a")
+	assert.ErrorIs(t, err, registry.ErrQuotaExceeded)
+	assert.True(t, reset.After(time.Now()))
+	_, err = r.TakeQuota(ctx, tool, "consumer-b")
+	assert.NoError(t, err, "quotas are per consumer")
+
+	require.NoError(t, r.SetQuota(ctx, tool.ID, req.ProviderID, &registry.Quota{Calls: 3, Period: registry.QuotaHour}))
+	tool, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	_, err = r.TakeQuota(ctx, tool, "consumer-a")
+	assert.NoError(t, err, "calls already counted count against the raised quota")
+	_, err = r.TakeQuota(ctx, tool, "consumer-a")
+	assert.ErrorIs(t, err, registry.ErrQuotaExceeded)
+
+	require.NoError(t, r.SetQuota(ctx, tool.ID, req.ProviderID, nil))
+	tool, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Nil(t, tool.Quota)
+	_, err = r.TakeQuota(ctx, tool, "consumer-a")
+	assert.NoError(t, err)
+}
+
+func TestQuota_Invalid(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Quota = &registry.Quota{Calls: 10, Period: "week"}
+	_, err := r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrInvalidQuota)
+
+	req = validRegisterReq()
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	err = r.SetQuota(ctx, tool.ID, req.ProviderID, &registry.Quota{Period: registry.QuotaDay})
+	assert.ErrorIs(t, err, registry.ErrInvalidQuota)
+	err = r.SetQuota(ctx, tool.ID, "did:claw:agent:someone-else", &registry.Quota{Calls: 1, Period: registry.QuotaDay})
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestQuota_Warning(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+	req := validRegisterReq()
+	req.Quota = &registry.Quota{Calls: 5, Period: registry.QuotaDay}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	<-ch // tool.registered
+
+	for i := 1; i <= 5; i++ {
+		_, err := r.TakeQuota(ctx, tool, "consumer-a")
+		require.NoError(t, err)
+		if i == 4 {
+			ev := <-ch
+			assert.Equal(t, events.QuotaWarning, ev.Type)
+			assert.Equal(t, "consumer-a", ev.Audience)
+			var w registry.LimitWarning
+			require.NoError(t, json.Unmarshal(ev.Data, &w))
+			assert.Equal(t, registry.LimitToolQuota, w.Kind)
+			assert.Equal(t, 4.0, w.Used)
+			assert.Equal(t, 5.0, w.Limit)
+			assert.NotNil(t, w.ResetAt)
+		}
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("warned again: %s", ev.Type)
+	default:
+	}
+
+	r.WarnBudget(ctx, tool, "consumer-a", "inv_1", "0.5", "1")
+	r.WarnBudget(ctx, tool, "consumer-a", "inv_2", "0.9", "")
+	r.WarnBudget(ctx, tool, "consumer-a", "inv_3", "0.9", "1")
+	ev := <-ch
+	var w registry.LimitWarning
+	require.NoError(t, json.Unmarshal(ev.Data, &w))
+	assert.Equal(t, registry.LimitBudget, w.Kind)
+	assert.Equal(t, "inv_3", w.InvocationID)
+}
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"go.uber.org/zap"
+)
+
+// DefaultReapGrace is how long past its last possible attempt a pending
+// invocation is left alone by a Registry created without WithReapGrace.
+const DefaultReapGrace = 10 * time.Minute
+
+// ReasonAbandoned starts the error of invocations failed by
+// ReapAbandonedInvocations, telling them apart from failures of the tool.
+const ReasonAbandoned = "abandoned"
+
+// WithReapGrace sets how long a pending invocation may outlive its tool's
+// timeout, retries included, before ReapAbandonedInvocations fails it.
+func WithReapGrace(d time.Duration) Option {
+	return func(r *Registry) { r.reapGrace = d }
+}
+
+// Refund tells that an invocation is not charged for. It is the data of an
+// events.InvocationRefunded event; payment backends release the escrow it
+// names.
+type Refund struct {
+	InvocationID string `json:"invocation_id"`
+	ToolID       string `json:"tool_id"`
+	ConsumerID   string `json:"consumer_id"`
+	EscrowID     string `json:"escrow_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// abandoned is a pending invocation found by ReapAbandonedInvocations.
+type abandoned struct {
+	Refund
+	namespace string
+}
+
+// ReapAbandonedInvocations fails the invocations left pending by a replica
+// that died mid-invoke: those started longer ago than their tool's timeout,
+// times the attempts its retry policy allows plus their backoff, and the
+// reap grace. Their escrow is refunded and they are failed with an error
+// starting with ReasonAbandoned. It is intended to be run periodically by
+// the serve command.
+func (r *Registry) ReapAbandonedInvocations(ctx context.Context) error {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	now := time.Now()
+	stale, err := r.abandonedInvocations(ctx, now)
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+	var reaped []abandoned
+	err = r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		reaped = reaped[:0]
+		for _, inv := range stale {
+			// Invocations completed since they were read are left alone.
+			res, err := tx.ExecContext(ctx, `
+				UPDATE invocations SET status = 'failed', error = ?, completed_at = ?, cost_claw = NULL, escrow_id = NULL,
+					idempotency_key = NULL
+				WHERE id = ? AND status = 'pending'
+			`, inv.Reason, now.Unix(), inv.InvocationID)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				reaped = append(reaped, inv)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reap invocations: %w", err)
+	}
+	for _, inv := range reaped {
+		ctx := WithNamespace(ctx, inv.namespace)
+		r.publishInvocation(ctx, events.InvocationFailed, inv.InvocationID)
+		refund := inv.Refund
+		r.publish(ctx, events.InvocationRefunded, inv.InvocationID, inv.ConsumerID, &refund)
+	}
+	if len(reaped) > 0 {
+		r.logger(ctx).Warn("reaped abandoned invocations", zap.Int("count", len(reaped)))
+	}
+	return nil
+}
+
+// abandonedInvocations returns the pending invocations past their deadline
+// at now.
+func (r *Registry) abandonedInvocations(ctx context.Context, now time.Time) ([]abandoned, error) {
+	// Nothing started after the grace plus one timeout ago can be late, so
+	// only older invocations are looked at.
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT i.id, i.tool_id, i.consumer_id, COALESCE(i.escrow_id, ''), i.namespace, i.started_at,
+			t.timeout_ms, t.retry_policy
+		FROM invocations i JOIN tools t ON t.id = i.tool_id
+		WHERE i.status = 'pending' AND i.started_at * 1000 + t.timeout_ms < ?
+	`, now.Add(-r.reapGrace).UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("read pending invocations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var stale []abandoned
+	for rows.Next() {
+		var (
+			inv                  abandoned
+			startedAt, timeoutMS int64
+			retry                string
+		)
+		err := rows.Scan(&inv.InvocationID, &inv.ToolID, &inv.ConsumerID, &inv.EscrowID, &inv.namespace, &startedAt,
+			&timeoutMS, &retry)
+		if err != nil {
+			return nil, fmt.Errorf("scan pending invocation: %w", err)
+		}
+		limit := time.Duration(timeoutMS) * time.Millisecond
+		if retry != "" {
+			var p RetryPolicy
+			if err := json.Unmarshal([]byte(retry), &p); err != nil {
+				return nil, fmt.Errorf("decode retry policy of %s: %w", inv.ToolID, err)
+			}
+			limit = runLimit(limit, &p)
+		}
+		if time.Unix(startedAt, 0).Add(limit + r.reapGrace).After(now) {
+			continue
+		}
+		inv.Reason = fmt.Sprintf("%s: no result within %s of the start", ReasonAbandoned, limit+r.reapGrace)
+		stale = append(stale, inv)
+	}
+	return stale, rows.Err()
+}
+
+// runLimit returns the longest an invocation of a tool with the timeout and
+// retry policy p may run: every attempt timing out, with the backoff before
+// each retry.
+func runLimit(timeout time.Duration, p *RetryPolicy) time.Duration {
+	limit := timeout
+	for n := 1; n < p.MaxAttempts; n++ {
+		limit += p.Backoff(n) + timeout
+	}
+	return limit
+}
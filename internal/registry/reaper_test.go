@@ -0,0 +1,80 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReapAbandonedInvocations(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithReapGrace(time.Minute))
+	req := validRegisterReq()
+	req.TimeoutMS = 10_000
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	retried := validRegisterReq()
+	retried.Name = "retried-tool"
+	retried.TimeoutMS = 10_000
+	retried.Retry = &registry.RetryPolicy{MaxAttempts: 3, BackoffMS: 60_000}
+	slow, err := r.RegisterTool(ctx, retried)
+	require.NoError(t, err)
+
+	record := func(toolID string, age time.Duration) string {
+		id, err := r.RecordInvocation(ctx, toolID, "consumer", map[string]any{"age": age.String()})
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "UPDATE invocations SET started_at = ?, escrow_id = ? WHERE id = ?",
+			time.Now().Add(-age).Unix(), "esc_"+id, id)
+		require.NoError(t, err)
+		return id
+	}
+	stale := record(tool.ID, 5*time.Minute)
+	running := record(tool.ID, 30*time.Second)
+	// Three attempts and two backoffs take up to 3m30s, plus the grace.
+	retrying := record(slow.ID, 4*time.Minute)
+	done := record(tool.ID, time.Hour)
+	require.NoError(t, r.CompleteInvocation(ctx, done, "h", "s", "1"))
+
+	ch, cancel := r.Events().Subscribe()
+	defer cancel()
+	require.NoError(t, r.ReapAbandonedInvocations(ctx))
+
+	inv, err := r.GetInvocation(ctx, stale)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", inv.Status)
+	assert.True(t, strings.HasPrefix(inv.Error, registry.ReasonAbandoned+":"), inv.Error)
+	assert.Empty(t, inv.CostCLAW)
+	for id, status := range map[string]string{running: "pending", retrying: "pending", done: "completed"} {
+		inv, err := r.GetInvocation(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, status, inv.Status, id)
+	}
+
+	ev := <-ch
+	assert.Equal(t, events.InvocationFailed, ev.Type)
+	ev = <-ch
+	assert.Equal(t, events.InvocationRefunded, ev.Type)
+	assert.Equal(t, "consumer", ev.Audience)
+	var refund registry.Refund
+	require.NoError(t, json.Unmarshal(ev.Data, &refund))
+	assert.Equal(t, stale, refund.InvocationID)
+	assert.Equal(t, "esc_"+stale, refund.EscrowID)
+	assert.Equal(t, inv.Error, refund.Reason)
+
+	// Reaping again finds nothing more.
+	require.NoError(t, r.ReapAbandonedInvocations(ctx))
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event %s", ev.Type)
+	default:
+	}
+}
@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/google/uuid"
+)
+
+// receiptColumns is the column list scanned by scanReceipt.
+const receiptColumns = "id, invocation_id, tool_id, consumer_id, provider_id, input_hash, output_hash, cost_claw, " +
+	"executed_at, provider_sig, cached, mock, metadata, attestation"
+
+// writeReceipt stores the receipt of invocation id, which must have
+// completed. An invocation has at most one receipt, so writing it again
+// does nothing.
+func (r *Registry) writeReceipt(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO receipts (id, invocation_id, tool_id, consumer_id, provider_id, namespace, input_hash, output_hash,
+			cost_claw, executed_at, provider_sig, cached, mock, metadata)
+		SELECT ?, i.id, i.tool_id, i.consumer_id, COALESCE(t.provider_id, ''), i.namespace, i.input_hash,
+			COALESCE(i.output_hash, ''), COALESCE(i.cost_claw, ''), i.completed_at, COALESCE(i.receipt_sig, ''), i.cached,
+			i.mock, i.metadata
+		FROM invocations i LEFT JOIN tools t ON t.id = i.tool_id
+		WHERE i.id = ? AND i.status = 'completed'
+		ON CONFLICT (invocation_id) DO NOTHING
+	`, "rcpt_"+uuid.NewString(), id)
+	if err != nil {
+		return fmt.Errorf("write receipt: %w", err)
+	}
+	return nil
+}
+
+// GetReceipt returns a receipt of the context namespace by ID.
+func (r *Registry) GetReceipt(ctx context.Context, id string) (*Receipt, error) {
+	return r.getReceipt(ctx, "id", id)
+}
+
+// InvocationReceipt returns the receipt of invocation id, which exists
+// once the invocation completed.
+func (r *Registry) InvocationReceipt(ctx context.Context, id string) (*Receipt, error) {
+	return r.getReceipt(ctx, "invocation_id", id)
+}
+
+func (r *Registry) getReceipt(ctx context.Context, column, id string) (*Receipt, error) {
+	rcpt, err := scanReceipt(r.db.QueryRowContext(ctx, "SELECT "+receiptColumns+
+		" FROM receipts WHERE "+column+" = ? AND namespace = ?", id, NamespaceFrom(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get receipt: %w", err)
+	}
+	return rcpt, nil
+}
+
+// ReceiptFilter narrows ListReceipts. Party is required: only receipts of
+// invocations it made, or of its tools, are listed. The other fields are
+// ignored when empty.
+type ReceiptFilter struct {
+	Party      string
+	ConsumerID string
+	ToolID     string
+	// From and To bound when the invocations completed, To exclusive.
+	From, To time.Time
+}
+
+// ReceiptList is a page of receipts, newest first. NextCursor is set when
+// there are more.
+type ReceiptList struct {
+	Receipts   []*Receipt `json:"receipts"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ListReceipts returns up to limit receipts of the context namespace
+// matching f, newest first, listed after cursor; an empty cursor starts at
+// the newest. It returns ErrInvalidCursor for a cursor it did not issue.
+func (r *Registry) ListReceipts(ctx context.Context, f ReceiptFilter, cursor string, limit int) (*ReceiptList, error) {
+	limit = listLimit(limit)
+	where, args := "namespace = ? AND (consumer_id = ? OR provider_id = ?)", []any{NamespaceFrom(ctx), f.Party, f.Party}
+	if f.ConsumerID != "" {
+		where += " AND consumer_id = ?"
+		args = append(args, f.ConsumerID)
+	}
+	if f.ToolID != "" {
+		where += " AND tool_id = ?"
+		args = append(args, f.ToolID)
+	}
+	if !f.From.IsZero() {
+		where += " AND executed_at >= ?"
+		args = append(args, f.From.Unix())
+	}
+	if !f.To.IsZero() {
+		where += " AND executed_at < ?"
+		args = append(args, f.To.Unix())
+	}
+	if cursor != "" {
+		after, err := parseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (executed_at, id) < (?, ?)"
+		args = append(args, after.createdAt, after.id)
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+receiptColumns+" FROM receipts WHERE "+where+
+		" ORDER BY executed_at DESC, id DESC LIMIT ?", append(args, limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("list receipts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := &ReceiptList{Receipts: []*Receipt{}}
+	for rows.Next() {
+		rcpt, err := scanReceipt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan receipt: %w", err)
+		}
+		list.Receipts = append(list.Receipts, rcpt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list receipts: %w", err)
+	}
+	if len(list.Receipts) > limit {
+		list.Receipts = list.Receipts[:limit]
+		last := list.Receipts[limit-1]
+		list.NextCursor = (&listCursor{createdAt: last.ExecutedAt.Unix(), id: last.ID}).String()
+	}
+	return list, nil
+}
+
+// VerifyReceipt checks rcpt against the keys of its provider, as
+// receipts.Verify does, and recomputes the hashes of input and output
+// unless they are nil. Keys that cannot be resolved fail the signature. An
+// attestation is checked by the verifier of its format, if there is one.
+func (r *Registry) VerifyReceipt(ctx context.Context, rcpt *Receipt, input, output any) *receipts.Verdict {
+	keys, err := r.VerificationKeys(ctx, rcpt.ProviderID)
+	v := receipts.Verify(rcpt, input, output, keys...)
+	if err != nil && v.Signature == receipts.CheckInvalid {
+		v.Reasons = append(v.Reasons, fmt.Sprintf("resolve keys of %s: %v", rcpt.ProviderID, err))
+	}
+	if rcpt.Attestation != nil {
+		switch checked, err := r.verifyAttestation(ctx, rcpt); {
+		case err != nil:
+			v.Attestation, v.Valid = receipts.CheckInvalid, false
+			v.Reasons = append(v.Reasons, err.Error())
+		case checked:
+			v.Attestation = receipts.CheckValid
+		}
+	}
+	return v
+}
+
+func scanReceipt(row scanner) (*Receipt, error) {
+	var (
+		rcpt       Receipt
+		executedAt int64
+		metadata   sql.NullString
+		attest     string
+	)
+	err := row.Scan(&rcpt.ID, &rcpt.InvocationID, &rcpt.ToolID, &rcpt.ConsumerID, &rcpt.ProviderID, &rcpt.InputHash,
+		&rcpt.OutputHash, &rcpt.CostCLAW, &executedAt, &rcpt.ProviderSig, &rcpt.Cached, &rcpt.Mock, &metadata, &attest)
+	if err != nil {
+		return nil, err
+	}
+	if rcpt.Metadata, err = decodeMetadata(metadata.String); err != nil {
+		return nil, err
+	}
+	if rcpt.Attestation, err = decodeAttestation(attest); err != nil {
+		return nil, err
+	}
+	rcpt.ExecutedAt = time.Unix(executedAt, 0).UTC()
+	return &rcpt, nil
+}
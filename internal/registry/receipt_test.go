@@ -0,0 +1,91 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceipts(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	invoke := func(consumer string, m registry.Metadata) string {
+		id, err := r.RecordInvocation(registry.WithMetadata(ctx, m), tool.ID, consumer, map[string]any{"q": 1})
+		require.NoError(t, err)
+		require.NoError(t, r.CompleteInvocation(ctx, id, "sha256:out", "ed25519:sig", "5.0"))
+		return id
+	}
+	first := invoke("consumer-a", registry.Metadata{"task_id": "t1"})
+	invoke("consumer-a", nil)
+	invoke("consumer-b", nil)
+
+	pending, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", map[string]any{})
+	require.NoError(t, err)
+	_, err = r.InvocationReceipt(ctx, pending)
+	assert.ErrorIs(t, err, registry.ErrNotFound, "an invocation that has not completed has no receipt")
+
+	rcpt, err := r.InvocationReceipt(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, first, rcpt.InvocationID)
+	assert.Equal(t, tool.ID, rcpt.ToolID)
+	assert.Equal(t, "consumer-a", rcpt.ConsumerID)
+	assert.Equal(t, tool.ProviderID, rcpt.ProviderID)
+	assert.Equal(t, "sha256:out", rcpt.OutputHash)
+	assert.Equal(t, "ed25519:sig", rcpt.ProviderSig)
+	assert.Equal(t, "5.0", rcpt.CostCLAW)
+	assert.Equal(t, map[string]string{"task_id": "t1"}, rcpt.Metadata)
+	assert.WithinDuration(t, time.Now(), rcpt.ExecutedAt, 2*time.Second)
+
+	got, err := r.GetReceipt(ctx, rcpt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, rcpt, got)
+	_, err = r.GetReceipt(registry.WithNamespace(ctx, "other"), rcpt.ID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+
+	list := func(f registry.ReceiptFilter) []*registry.Receipt {
+		l, err := r.ListReceipts(ctx, f, "", 0)
+		require.NoError(t, err)
+		return l.Receipts
+	}
+	assert.Len(t, list(registry.ReceiptFilter{Party: "consumer-a"}), 2)
+	assert.Len(t, list(registry.ReceiptFilter{Party: tool.ProviderID}), 3, "providers see the receipts of their tools")
+	assert.Len(t, list(registry.ReceiptFilter{Party: tool.ProviderID, ConsumerID: "consumer-b"}), 1)
+	assert.Empty(t, list(registry.ReceiptFilter{Party: "consumer-a", ConsumerID: "consumer-b"}))
+	assert.Empty(t, list(registry.ReceiptFilter{Party: "consumer-a", ToolID: "other"}))
+	assert.Empty(t, list(registry.ReceiptFilter{Party: "consumer-a", From: time.Now().Add(time.Hour)}))
+	assert.Len(t, list(registry.ReceiptFilter{Party: "consumer-a", From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}), 2)
+
+	page, err := r.ListReceipts(ctx, registry.ReceiptFilter{Party: tool.ProviderID}, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Receipts, 2)
+	require.NotEmpty(t, page.NextCursor)
+	rest, err := r.ListReceipts(ctx, registry.ReceiptFilter{Party: tool.ProviderID}, page.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Len(t, rest.Receipts, 1)
+	assert.Empty(t, rest.NextCursor)
+
+	_, err = r.ListReceipts(ctx, registry.ReceiptFilter{Party: "consumer-a"}, "bogus", 0)
+	assert.ErrorIs(t, err, registry.ErrInvalidCursor)
+}
+
+func TestReceipts_Cached(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	id, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", map[string]any{})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteCachedInvocation(ctx, id, "sha256:00", "0.5"))
+
+	rcpt, err := r.InvocationReceipt(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, rcpt.Cached)
+	assert.Equal(t, "0.5", rcpt.CostCLAW)
+	assert.Empty(t, rcpt.ProviderSig)
+}
@@ -9,9 +9,17 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/netguard"
+	"github.com/clawinfra/agent-tools/internal/secrets"
 	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -22,15 +30,80 @@ var ErrNotFound = errors.New("not found")
 // ErrDuplicate is returned when a tool with the same name+version already exists.
 var ErrDuplicate = errors.New("duplicate tool")
 
+// ErrForbidden is returned when the caller may not act on a resource.
+var ErrForbidden = errors.New("forbidden")
+
 // Registry manages tool registration and discovery.
 type Registry struct {
-	db  *store.DB
-	log *zap.Logger
+	db         *store.DB
+	log        *zap.Logger
+	instanceID string
+	limits     Limits
+	secrets    *secrets.Box
+	endpoints  netguard.Policy
+	events     *events.Bus
+	dids       *did.Resolver
+	cas        cas.Store
+	invlog     *invocationLog
+	payloadTTL time.Duration
+	reapGrace  time.Duration
+	notice     time.Duration
+	anchorer   Anchorer
+	wallet     Wallet
+	escrowSeed []byte
+	attesters  map[string]AttestationVerifier
+	warnAt     float64
+	// routes are the routing strategies by name, routeDefault the one
+	// used when a request names none.
+	routes       map[string]RouteStrategy
+	routeDefault string
+	// requireSigned rejects registrations without a manifest signature.
+	requireSigned bool
+	// requireNonces rejects signatures without a nonce; nonces holds the
+	// nonces seen recently.
+	requireNonces bool
+	nonces        nonceCache
+	// requireResponseSigs fails proxied invocations whose answer the
+	// provider did not sign.
+	requireResponseSigs bool
+	reputation          atomic.Pointer[ReputationConfig]
+}
+
+// Option configures a Registry.
+type Option func(*Registry)
+
+// WithInstanceID tags invocations started by this process so that, when
+// several replicas share a database, each one only interrupts its own
+// pending invocations on shutdown.
+func WithInstanceID(id string) Option {
+	return func(r *Registry) { r.instanceID = id }
 }
 
 // New creates a new Registry.
-func New(db *store.DB, log *zap.Logger) *Registry {
-	return &Registry{db: db, log: log}
+func New(db *store.DB, log *zap.Logger, opts ...Option) *Registry {
+	r := &Registry{
+		db: db, log: log, limits: DefaultLimits, payloadTTL: DefaultPayloadRetention, reapGrace: DefaultReapGrace,
+		notice: DefaultPriceNotice, warnAt: DefaultWarnThreshold, routes: builtinStrategies(), routeDefault: DefaultRouteStrategy,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	if r.events == nil {
+		r.events = events.NewBus("")
+	}
+	if r.dids == nil {
+		r.dids = r.defaultDIDResolver()
+	}
+	return r
+}
+
+// logger returns the registry logger annotated with the request ID carried
+// by ctx, if any, so registry events can be correlated with HTTP requests.
+func (r *Registry) logger(ctx context.Context) *zap.Logger {
+	if id := middleware.GetReqID(ctx); id != "" {
+		return r.log.With(zap.String("request_id", id))
+	}
+	return r.log
 }
 
 // RegisterTool registers a new tool and returns it.
@@ -38,6 +111,28 @@ func (r *Registry) RegisterTool(ctx context.Context, req *RegisterToolRequest) (
 	if err := req.Validate(); err != nil {
 		return nil, fmt.Errorf("validate: %w", err)
 	}
+	if err := r.limits.checkTool(req); err != nil {
+		return nil, err
+	}
+	if err := r.CheckProvider(ctx, req.ProviderID, append([]string{req.Endpoint}, req.Endpoints...)...); err != nil {
+		return nil, err
+	}
+	if err := r.checkEndpoint(req.Endpoint); err != nil {
+		return nil, err
+	}
+	for _, endpoint := range req.Endpoints {
+		if err := r.checkEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.checkModule(ctx, req.Endpoint); err != nil {
+		return nil, err
+	}
+	hash, err := r.verifyManifest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	cid := r.pinManifest(ctx, req)
 
 	schemaJSON, err := json.Marshal(req.Schema)
 	if err != nil {
@@ -47,26 +142,92 @@ func (r *Registry) RegisterTool(ctx context.Context, req *RegisterToolRequest) (
 	if err != nil {
 		return nil, fmt.Errorf("marshal pricing: %w", err)
 	}
+	var cacheJSON []byte
+	if req.Cache != nil {
+		if cacheJSON, err = json.Marshal(req.Cache); err != nil {
+			return nil, fmt.Errorf("marshal cache policy: %w", err)
+		}
+	}
 
+	ns := NamespaceFrom(ctx)
 	id := makeToolDID(req.Name, req.Version, req.ProviderID)
 	now := time.Now().Unix()
-	tags := strings.Join(req.Tags, ",")
+	tags := encodeTags(req.Tags)
 
-	// Auto-upsert the provider if not already registered (v0.1: no strict auth yet).
-	_, err = r.db.ExecContext(ctx, `
-		INSERT INTO providers (id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen)
-		VALUES (?, '', '', '', '0', 0, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET last_seen=excluded.last_seen
-	`, req.ProviderID, now, now)
+	auth, err := r.sealAuth(id, req.Auth)
 	if err != nil {
-		return nil, fmt.Errorf("upsert provider: %w", err)
+		return nil, err
+	}
+	var slaJSON []byte
+	if req.SLA != nil {
+		if slaJSON, err = json.Marshal(req.SLA); err != nil {
+			return nil, fmt.Errorf("marshal sla: %w", err)
+		}
+	}
+	quota, err := encodeQuota(req.Quota)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := encodeEndpoints(req.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := encodeRetryPolicy(req.Retry)
+	if err != nil {
+		return nil, err
+	}
+	features, err := encodeFeatures(req.Features)
+	if err != nil {
+		return nil, err
+	}
+	descriptions, err := encodeDescriptions(req.Descriptions)
+	if err != nil {
+		return nil, err
+	}
+	mock, err := encodeMock(req.Mock)
+	if err != nil {
+		return nil, err
+	}
+	tests, err := encodeTests(req.Tests)
+	if err != nil {
+		return nil, err
+	}
+	var compat Compatibility
+	var breaking sql.NullBool
+	if req.CheckCompat {
+		c, err := r.checkCompat(ctx, id, req)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			compat, breaking = *c, sql.NullBool{Bool: c.Breaking, Valid: true}
+		}
 	}
 
-	_, err = r.db.ExecContext(ctx, `
-		INSERT INTO tools (id, name, version, description, schema_json, pricing, provider_id, endpoint, timeout_ms, tags, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, req.Name, req.Version, req.Description, string(schemaJSON), string(pricingJSON),
-		req.ProviderID, req.Endpoint, req.TimeoutMS, tags, now, now)
+	// Auto-upsert the provider if not already registered (v0.1: no strict auth yet).
+	if err := r.touchProvider(ctx, req.ProviderID, now); err != nil {
+		return nil, err
+	}
+
+	var change *PricingChange
+	err = r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO tools (id, name, version, description, schema_json, pricing, provider_id, endpoint,
+				timeout_ms, tags, created_at, updated_at, namespace, endpoint_auth, manifest_hash, manifest_signature,
+				manifest_cid, cache_policy, compat_against, compat_breaking, sla, quota, endpoints, routing, retry_policy, features,
+				language, descriptions, mock, tests)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, id, req.Name, req.Version, req.Description, string(schemaJSON), string(pricingJSON),
+			req.ProviderID, req.Endpoint, req.TimeoutMS, tags, now, now, ns, auth, hash, req.ManifestSignature, cid,
+			string(cacheJSON), compat.Against, breaking, string(slaJSON), quota, endpoints, req.Routing, retry, features,
+			req.Language, descriptions, mock, tests)
+		if err != nil {
+			return err
+		}
+		t := &Tool{ID: id, Name: req.Name, Version: req.Version, ProviderID: req.ProviderID, Pricing: req.Pricing}
+		change, err = r.recordPricing(ctx, tx, t, req.ProviderID)
+		return err
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			return nil, fmt.Errorf("%w: %s@%s", ErrDuplicate, req.Name, req.Version)
@@ -74,62 +235,62 @@ func (r *Registry) RegisterTool(ctx context.Context, req *RegisterToolRequest) (
 		return nil, fmt.Errorf("insert tool: %w", err)
 	}
 
-	r.log.Info("tool registered",
+	r.logger(ctx).Info("tool registered",
 		zap.String("id", id),
 		zap.String("name", req.Name),
 		zap.String("version", req.Version),
 		zap.String("provider", req.ProviderID),
+		zap.String("namespace", ns),
 	)
 
-	return r.GetTool(ctx, id)
+	tool, err := r.GetTool(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.publish(ctx, events.ToolRegistered, id, "", tool)
+	r.publishPricing(ctx, change)
+	return tool, nil
+}
+
+// touchProvider creates a placeholder provider row for providerID in the
+// context namespace, or bumps last_seen if it already exists there.
+func (r *Registry) touchProvider(ctx context.Context, providerID string, now int64) error {
+	ns := NamespaceFrom(ctx)
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO providers (id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen, namespace)
+		VALUES (?, '', '', '', '0', 0, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET last_seen=excluded.last_seen
+		WHERE providers.namespace = excluded.namespace
+	`, providerID, now, now, ns)
+	if err != nil {
+		return fmt.Errorf("upsert provider: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: provider %s belongs to another namespace", ErrForbidden, providerID)
+	}
+	return nil
 }
 
 // GetTool returns a tool by ID.
 func (r *Registry) GetTool(ctx context.Context, id string) (*Tool, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT id, name, version, description, schema_json, pricing, provider_id, endpoint, timeout_ms, tags, created_at, updated_at, is_active
-		FROM tools WHERE id = ?
-	`, id)
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+toolColumns+" FROM tools WHERE id = ? AND namespace = ?", id, NamespaceFrom(ctx))
 	return scanTool(row)
 }
 
-// ListTools returns paginated tools.
+// ListTools returns a page of tools, newest first. Deep pages are cheaper
+// to walk with ListToolsAfter, starting from the NextCursor of any page.
 func (r *Registry) ListTools(ctx context.Context, page, limit int) (*SearchResult, error) {
 	if page <= 0 {
 		page = 1
 	}
-	if limit <= 0 || limit > 100 {
-		limit = 20
-	}
-	offset := (page - 1) * limit
-
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, name, version, description, schema_json, pricing, provider_id, endpoint, timeout_ms, tags, created_at, updated_at, is_active
-		FROM tools WHERE is_active = 1
-		ORDER BY created_at DESC LIMIT ? OFFSET ?
-	`, limit, offset)
-	if err != nil {
-		return nil, fmt.Errorf("list tools: %w", err)
-	}
-	defer func() { _ = rows.Close() }()
-
-	tools, err := scanTools(rows)
+	limit = listLimit(limit)
+	res, err := r.listTools(ctx, nil, (page-1)*limit, limit)
 	if err != nil {
 		return nil, err
 	}
-
-	var total int
-	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tools WHERE is_active = 1").Scan(&total)
-	if err != nil {
-		return nil, fmt.Errorf("count tools: %w", err)
-	}
-
-	return &SearchResult{
-		Tools: tools,
-		Total: total,
-		Page:  page,
-		Limit: limit,
-	}, nil
+	res.Page = page
+	return res, nil
 }
 
 // SearchTools performs full-text search on the tool registry.
@@ -147,21 +308,26 @@ func (r *Registry) SearchTools(ctx context.Context, q *SearchQuery) (*SearchResu
 		err  error
 	)
 
+	// Tools violating their SLA sink below the rest. With federation on,
+	// local and mirrored results are merged in Go, so each side must return
+	// everything up to the end of the requested page.
+	ns := NamespaceFrom(ctx)
+	federated := q.Federated && ns == DefaultNamespace
+	localLimit, localOffset := q.Limit, offset
+	if federated {
+		localLimit, localOffset = offset+q.Limit, 0
+	}
 	if q.Query != "" {
-		rows, err = r.db.QueryContext(ctx, `
-			SELECT t.id, t.name, t.version, t.description, t.schema_json, t.pricing, 
-			       t.provider_id, t.endpoint, t.timeout_ms, t.tags, t.created_at, t.updated_at, t.is_active
-			FROM tools t
-			WHERE t.is_active = 1
-			  AND t.rowid IN (SELECT rowid FROM tools_fts WHERE tools_fts MATCH ?)
-			ORDER BY t.created_at DESC LIMIT ? OFFSET ?
-		`, q.Query+"*", q.Limit, offset)
+		rows, err = r.db.QueryContext(ctx, "SELECT "+toolColumns+` FROM tools
+			WHERE is_active = 1 AND advisory_status != 'revoked' AND namespace = ?
+			  AND rowid IN (SELECT rowid FROM tools_fts WHERE tools_fts MATCH ?)
+			ORDER BY sla_violated, created_at DESC LIMIT ? OFFSET ?
+		`, ns, q.Query+"*", localLimit, localOffset)
 	} else {
-		rows, err = r.db.QueryContext(ctx, `
-			SELECT id, name, version, description, schema_json, pricing, provider_id, endpoint, timeout_ms, tags, created_at, updated_at, is_active
-			FROM tools WHERE is_active = 1
-			ORDER BY created_at DESC LIMIT ? OFFSET ?
-		`, q.Limit, offset)
+		rows, err = r.db.QueryContext(ctx, "SELECT "+toolColumns+` FROM tools
+			WHERE is_active = 1 AND advisory_status != 'revoked' AND namespace = ?
+			ORDER BY sla_violated, created_at DESC LIMIT ? OFFSET ?
+		`, ns, localLimit, localOffset)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("search tools: %w", err)
@@ -172,6 +338,16 @@ func (r *Registry) SearchTools(ctx context.Context, q *SearchQuery) (*SearchResu
 	if err != nil {
 		return nil, err
 	}
+	for _, t := range tools {
+		t.Source = SourceLocal
+	}
+	if federated {
+		mirrored, err := r.searchFederated(ctx, q.Query, offset+q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		tools = mergeNewest(tools, mirrored, offset, q.Limit)
+	}
 
 	return &SearchResult{
 		Tools: tools,
@@ -185,8 +361,8 @@ func (r *Registry) SearchTools(ctx context.Context, q *SearchQuery) (*SearchResu
 // DeactivateTool soft-deletes a tool.
 func (r *Registry) DeactivateTool(ctx context.Context, id, providerID string) error {
 	res, err := r.db.ExecContext(ctx,
-		"UPDATE tools SET is_active = 0, updated_at = ? WHERE id = ? AND provider_id = ?",
-		time.Now().Unix(), id, providerID)
+		"UPDATE tools SET is_active = 0, updated_at = ? WHERE id = ? AND provider_id = ? AND namespace = ?",
+		time.Now().Unix(), id, providerID, NamespaceFrom(ctx))
 	if err != nil {
 		return fmt.Errorf("deactivate: %w", err)
 	}
@@ -194,6 +370,7 @@ func (r *Registry) DeactivateTool(ctx context.Context, id, providerID string) er
 	if n == 0 {
 		return fmt.Errorf("%w or not authorized", ErrNotFound)
 	}
+	r.publish(ctx, events.ToolDeactivated, id, "", map[string]string{"id": id, "provider_id": providerID})
 	return nil
 }
 
@@ -205,45 +382,52 @@ func (r *Registry) RegisterProvider(ctx context.Context, p *Provider) (*Provider
 	if p.Endpoint == "" {
 		return nil, fmt.Errorf("endpoint is required")
 	}
-	if p.PubKey == "" {
-		return nil, fmt.Errorf("pubkey is required")
+	if err := checkProviderKey(p); err != nil {
+		return nil, err
+	}
+	if err := r.checkEndpoint(p.Endpoint); err != nil {
+		return nil, err
+	}
+	if err := r.CheckProvider(ctx, p.ID, p.Endpoint); err != nil {
+		return nil, err
 	}
 	now := time.Now().Unix()
 	if p.StakeCLAW == "" {
 		p.StakeCLAW = "0"
 	}
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO providers (id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen)
-		VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO providers (id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen, namespace)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			name=excluded.name,
 			endpoint=excluded.endpoint,
 			pubkey=excluded.pubkey,
 			stake_claw=excluded.stake_claw,
 			last_seen=excluded.last_seen
-	`, p.ID, p.Name, p.Endpoint, p.PubKey, p.StakeCLAW, now, now)
+		WHERE providers.namespace = excluded.namespace
+	`, p.ID, p.Name, p.Endpoint, p.PubKey, p.StakeCLAW, now, now, NamespaceFrom(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("upsert provider: %w", err)
 	}
-	r.log.Info("provider registered", zap.String("id", p.ID))
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: provider %s belongs to another namespace", ErrForbidden, p.ID)
+	}
+	r.logger(ctx).Info("provider registered", zap.String("id", p.ID))
 	return r.GetProvider(ctx, p.ID)
 }
 
 // GetProvider returns a provider by ID.
 func (r *Registry) GetProvider(ctx context.Context, id string) (*Provider, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen
-		FROM providers WHERE id = ?
-	`, id)
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+providerColumns+" FROM providers WHERE id = ? AND namespace = ?", id, NamespaceFrom(ctx))
 	return scanProvider(row)
 }
 
 // ListProviders returns all providers.
 func (r *Registry) ListProviders(ctx context.Context) ([]*Provider, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen
-		FROM providers ORDER BY reputation DESC, created_at DESC
-	`)
+	rows, err := r.db.QueryContext(ctx, "SELECT "+providerColumns+` FROM providers
+		WHERE namespace = ? ORDER BY reputation DESC, created_at DESC
+	`, NamespaceFrom(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("list providers: %w", err)
 	}
@@ -251,7 +435,7 @@ func (r *Registry) ListProviders(ctx context.Context) ([]*Provider, error) {
 
 	var providers []*Provider
 	for rows.Next() {
-		p, err := scanProviderRow(rows)
+		p, err := scanProvider(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -260,13 +444,21 @@ func (r *Registry) ListProviders(ctx context.Context) ([]*Provider, error) {
 	return providers, rows.Err()
 }
 
-func scanProvider(row *sql.Row) (*Provider, error) {
+// providerColumns is the column list scanned by scanProvider.
+const providerColumns = "id, name, endpoint, pubkey, stake_claw, reputation, created_at, last_seen, namespace"
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProvider(row scanner) (*Provider, error) {
 	var (
 		p         Provider
 		createdAt int64
 		lastSeen  int64
 	)
-	err := row.Scan(&p.ID, &p.Name, &p.Endpoint, &p.PubKey, &p.StakeCLAW, &p.Reputation, &createdAt, &lastSeen)
+	err := row.Scan(&p.ID, &p.Name, &p.Endpoint, &p.PubKey, &p.StakeCLAW, &p.Reputation, &createdAt, &lastSeen, &p.Namespace)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -278,66 +470,256 @@ func scanProvider(row *sql.Row) (*Provider, error) {
 	return &p, nil
 }
 
-func scanProviderRow(rows *sql.Rows) (*Provider, error) {
-	var (
-		p         Provider
-		createdAt int64
-		lastSeen  int64
-	)
-	if err := rows.Scan(&p.ID, &p.Name, &p.Endpoint, &p.PubKey, &p.StakeCLAW, &p.Reputation, &createdAt, &lastSeen); err != nil {
-		return nil, err
-	}
-	p.CreatedAt = time.Unix(createdAt, 0)
-	p.LastSeen = time.Unix(lastSeen, 0)
-	return &p, nil
-}
-
-// RecordInvocation creates a new invocation record.
-// input is the raw input map; the hash is computed automatically.
+// RecordInvocation creates a new invocation record, holding the metadata
+// ctx carries. input is the raw input map; the hash is computed
+// automatically.
 func (r *Registry) RecordInvocation(ctx context.Context, toolID, consumerID string, input map[string]any) (string, error) {
-	h, err := hashInput(input)
+	b, err := json.Marshal(input)
 	if err != nil {
-		return "", fmt.Errorf("hash input: %w", err)
+		return "", fmt.Errorf("marshal input: %w", err)
 	}
-	id := "inv_" + uuid.NewString()
+	if err := r.limits.CheckInput(b); err != nil {
+		return "", err
+	}
+	metadata, err := encodeMetadata(MetadataFrom(ctx))
+	if err != nil {
+		return "", err
+	}
+	inv := pendingInvocation{
+		id:         "inv_" + uuid.NewString(),
+		toolID:     toolID,
+		consumerID: consumerID,
+		inputHash:  hashInput(b),
+		namespace:  NamespaceFrom(ctx),
+		startedAt:  time.Now().Unix(),
+		metadata:   metadata,
+	}
+	if inv.payload, err = r.sealInput(ctx, inv.id, b); err != nil {
+		return "", err
+	}
+	if queued, err := r.queueInvocation(ctx, inv); queued {
+		return inv.id, err
+	}
+	status := "pending"
+	if IsQueued(ctx) {
+		status = "queued"
+	}
+	sealed, owner, expiresAt := inv.payload.columns()
 	_, err = r.db.ExecContext(ctx, `
-		INSERT INTO invocations (id, tool_id, consumer_id, input_hash, started_at, status)
-		VALUES (?, ?, ?, ?, ?, 'pending')
-	`, id, toolID, consumerID, h, time.Now().Unix())
+		INSERT INTO invocations (id, tool_id, consumer_id, input_hash, started_at, status, namespace, instance_id,
+			input, payload_owner, payload_expires_at, idempotency_key, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, inv.id, inv.toolID, inv.consumerID, inv.inputHash, inv.startedAt, status, inv.namespace, r.instanceID,
+		sealed, owner, expiresAt, idempotencyKey(ctx), inv.metadata)
 	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return "", fmt.Errorf("%w: another invocation with this key is running", ErrIdempotencyConflict)
+		}
 		return "", fmt.Errorf("record invocation: %w", err)
 	}
-	return id, nil
+	return inv.id, nil
 }
 
-// CompleteInvocation updates an invocation with its result.
+// CompleteInvocation updates an invocation with its result and stores its
+// receipt.
 func (r *Registry) CompleteInvocation(ctx context.Context, id, outputHash, receiptSig, costCLAW string) error {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
 	now := time.Now().Unix()
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE invocations SET
-			status = 'completed', output_hash = ?, receipt_sig = ?, cost_claw = ?, completed_at = ?
+			status = 'completed', output_hash = ?, receipt_sig = ?, cost_claw = ?, completed_at = ?, endpoint = ?,
+			attempts = ?
 		WHERE id = ?
-	`, outputHash, receiptSig, costCLAW, now, id)
-	return err
+	`, outputHash, receiptSig, costCLAW, now, servedEndpoint(ctx), attemptsOf(ctx), id)
+	if err != nil {
+		return err
+	}
+	if err := r.writeReceipt(ctx, id); err != nil {
+		return err
+	}
+	r.publishInvocation(ctx, events.InvocationCompleted, id)
+	return nil
 }
 
 // FailInvocation marks an invocation as failed.
 func (r *Registry) FailInvocation(ctx context.Context, id, reason string) error {
+	return r.failInvocation(ctx, id, "failed", reason)
+}
+
+func (r *Registry) failInvocation(ctx context.Context, id, status, reason string) error {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return err
+	}
+	providerErr, err := providerErrorColumn(ctx)
+	if err != nil {
+		return err
+	}
 	now := time.Now().Unix()
-	_, err := r.db.ExecContext(ctx, `
-		UPDATE invocations SET status = 'failed', error = ?, completed_at = ? WHERE id = ?
-	`, reason, now, id)
-	return err
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE invocations SET status = ?, error = ?, completed_at = ?, endpoint = ?, attempts = ?, provider_error = ?,
+			idempotency_key = NULL
+		WHERE id = ?
+	`, status, reason, now, servedEndpoint(ctx), attemptsOf(ctx), providerErr, id)
+	if err != nil {
+		return err
+	}
+	r.publishInvocation(ctx, events.InvocationFailed, id)
+	return nil
 }
 
-// hashInput computes the SHA-256 of a JSON-serialized input map.
-func hashInput(input map[string]any) (string, error) {
-	b, err := json.Marshal(input)
+// InterruptPendingInvocations marks every pending invocation started by this
+// instance as interrupted. It is called on shutdown after in-flight
+// invocations have been given a chance to finish, and returns the number of
+// invocations affected.
+func (r *Registry) InterruptPendingInvocations(ctx context.Context, reason string) (int64, error) {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return 0, err
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE invocations SET status = 'interrupted', error = ?, completed_at = ?
+		WHERE status = 'pending' AND instance_id = ?
+	`, reason, time.Now().Unix(), r.instanceID)
 	if err != nil {
-		return "", err
+		return 0, fmt.Errorf("interrupt invocations: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 {
+		r.logger(ctx).Warn("interrupted pending invocations", zap.Int64("count", n))
 	}
-	h := sha256.Sum256(b)
-	return "sha256:" + hex.EncodeToString(h[:]), nil
+	return n, nil
+}
+
+// GetInvocation returns an invocation record from the caller's namespace.
+func (r *Registry) GetInvocation(ctx context.Context, id string) (*Invocation, error) {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return nil, err
+	}
+	inv, err := scanInvocation(r.db.QueryRowContext(ctx, "SELECT "+invocationColumns+`
+		FROM invocations WHERE id = ? AND namespace = ?
+	`, id, NamespaceFrom(ctx)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get invocation: %w", err)
+	}
+	return inv, nil
+}
+
+// InvocationFilter narrows ListInvocations. ConsumerID is required; the
+// other fields are ignored when empty.
+type InvocationFilter struct {
+	ConsumerID string
+	ToolID     string
+	Status     string
+	// Metadata matches invocations holding all of its entries.
+	Metadata Metadata
+}
+
+// InvocationList is a page of invocations, newest first. NextCursor is set
+// when there are more.
+type InvocationList struct {
+	Invocations []*Invocation `json:"invocations"`
+	NextCursor  string        `json:"next_cursor,omitempty"`
+}
+
+// ListInvocations returns up to limit invocations of the context namespace
+// matching f, newest first, listed after cursor; an empty cursor starts at
+// the newest. It returns ErrInvalidCursor for a cursor it did not issue.
+func (r *Registry) ListInvocations(ctx context.Context, f InvocationFilter, cursor string, limit int) (*InvocationList, error) {
+	if err := r.FlushInvocations(ctx); err != nil {
+		return nil, err
+	}
+	limit = listLimit(limit)
+	where, args := "namespace = ? AND consumer_id = ?", []any{NamespaceFrom(ctx), f.ConsumerID}
+	if f.ToolID != "" {
+		where += " AND tool_id = ?"
+		args = append(args, f.ToolID)
+	}
+	if f.Status != "" {
+		where += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	for k, v := range f.Metadata {
+		if err := checkMetadataKey(k); err != nil {
+			return nil, err
+		}
+		where += " AND json_extract(metadata, ?) = ?"
+		args = append(args, `$."`+k+`"`, v)
+	}
+	if cursor != "" {
+		after, err := parseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND (started_at, id) < (?, ?)"
+		args = append(args, after.createdAt, after.id)
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+invocationColumns+" FROM invocations WHERE "+where+
+		" ORDER BY started_at DESC, id DESC LIMIT ?", append(args, limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("list invocations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	list := &InvocationList{Invocations: []*Invocation{}}
+	for rows.Next() {
+		inv, err := scanInvocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan invocation: %w", err)
+		}
+		list.Invocations = append(list.Invocations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list invocations: %w", err)
+	}
+	if len(list.Invocations) > limit {
+		list.Invocations = list.Invocations[:limit]
+		last := list.Invocations[limit-1]
+		list.NextCursor = (&listCursor{createdAt: last.StartedAt.Unix(), id: last.ID}).String()
+	}
+	return list, nil
+}
+
+// invocationColumns is the column list scanned by scanInvocation.
+const invocationColumns = "id, tool_id, consumer_id, input_hash, output_hash, receipt_sig, status, cost_claw, " +
+	"started_at, completed_at, error, cached, mock, endpoint, attempts, provider_error, metadata"
+
+func scanInvocation(row scanner) (*Invocation, error) {
+	var (
+		inv                             Invocation
+		outputHash, receiptSig, cost, e sql.NullString
+		providerErr, metadata           sql.NullString
+		startedAt                       int64
+		completedAt                     sql.NullInt64
+	)
+	err := row.Scan(&inv.ID, &inv.ToolID, &inv.ConsumerID, &inv.InputHash, &outputHash, &receiptSig, &inv.Status, &cost,
+		&startedAt, &completedAt, &e, &inv.Cached, &inv.Mock, &inv.Endpoint, &inv.Attempts, &providerErr, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if inv.ProviderError, err = decodeProviderError(providerErr.String); err != nil {
+		return nil, err
+	}
+	if inv.Metadata, err = decodeMetadata(metadata.String); err != nil {
+		return nil, err
+	}
+	inv.OutputHash, inv.ReceiptSig, inv.CostCLAW, inv.Error = outputHash.String, receiptSig.String, cost.String, e.String
+	inv.StartedAt = time.Unix(startedAt, 0).UTC()
+	if completedAt.Valid {
+		t := time.Unix(completedAt.Int64, 0).UTC()
+		inv.CompletedAt = &t
+	}
+	return &inv, nil
+}
+
+// hashInput computes the SHA-256 of the canonical form of a JSON-serialized
+// input map, so it matches the input_hash clients compute with
+// receipts.Hash.
+func hashInput(b []byte) string {
+	return receipts.HashJSON(b)
 }
 
 // makeToolDID generates a deterministic DID for a tool.
@@ -346,20 +728,48 @@ func makeToolDID(name, version, providerID string) string {
 	return "did:claw:tool:" + hex.EncodeToString(h[:16])
 }
 
-func scanTool(row *sql.Row) (*Tool, error) {
+// toolColumns is the column list scanned by scanTool.
+const toolColumns = "id, name, version, description, schema_json, pricing, provider_id, endpoint, " +
+	"timeout_ms, tags, created_at, updated_at, is_active, namespace, manifest_hash, manifest_signature, " +
+	"manifest_cid, cache_policy, advisory_status, advisory_note, advisory_by, advisory_at, compat_against, compat_breaking, " +
+	"sla, sla_compliance, quota, endpoints, routing, retry_policy, features, icon, language, descriptions, " +
+	"notice_pricing, pricing_effective_at, mock, tests, conformance"
+
+func scanTool(row scanner) (*Tool, error) {
 	var (
-		t           Tool
-		schemaJSON  string
-		pricingJSON string
-		tags        string
-		createdAt   int64
-		updatedAt   int64
-		isActive    int
+		t            Tool
+		schemaJSON   string
+		pricingJSON  string
+		tags         string
+		createdAt    int64
+		updatedAt    int64
+		isActive     int
+		cacheJSON    string
+		advisory     Advisory
+		advisoryAt   sql.NullInt64
+		compat       Compatibility
+		breaking     sql.NullBool
+		slaJSON      string
+		complJSON    string
+		quotaJSON    string
+		endpoints    string
+		retryJSON    string
+		features     string
+		descriptions string
+		noticeJSON   string
+		effectiveAt  int64
+		mock         string
+		tests        string
+		conformance  string
 	)
 	err := row.Scan(
 		&t.ID, &t.Name, &t.Version, &t.Description,
 		&schemaJSON, &pricingJSON, &t.ProviderID, &t.Endpoint,
-		&t.TimeoutMS, &tags, &createdAt, &updatedAt, &isActive,
+		&t.TimeoutMS, &tags, &createdAt, &updatedAt, &isActive, &t.Namespace,
+		&t.ManifestHash, &t.ManifestSignature, &t.ManifestCID, &cacheJSON,
+		&advisory.Status, &advisory.Note, &advisory.By, &advisoryAt,
+		&compat.Against, &breaking, &slaJSON, &complJSON, &quotaJSON, &endpoints, &t.Routing, &retryJSON, &features,
+		&t.Icon, &t.Language, &descriptions, &noticeJSON, &effectiveAt, &mock, &tests, &conformance,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -367,29 +777,80 @@ func scanTool(row *sql.Row) (*Tool, error) {
 		}
 		return nil, err
 	}
-	return assembleTool(&t, schemaJSON, pricingJSON, tags, createdAt, updatedAt, isActive)
+	if advisory.Status != "" {
+		advisory.At = time.Unix(advisoryAt.Int64, 0).UTC()
+		t.Advisory = &advisory
+	}
+	if breaking.Valid {
+		compat.Breaking = breaking.Bool
+		t.Compat = &compat
+	}
+	if cacheJSON != "" {
+		t.Cache = &CachePolicy{}
+		if err := json.Unmarshal([]byte(cacheJSON), t.Cache); err != nil {
+			return nil, fmt.Errorf("unmarshal cache policy: %w", err)
+		}
+		t.Deterministic = t.Cache.Deterministic
+	}
+	if slaJSON != "" {
+		t.SLA = &SLA{}
+		if err := json.Unmarshal([]byte(slaJSON), t.SLA); err != nil {
+			return nil, fmt.Errorf("unmarshal sla: %w", err)
+		}
+	}
+	if complJSON != "" {
+		t.SLACompliance = &SLACompliance{}
+		if err := json.Unmarshal([]byte(complJSON), t.SLACompliance); err != nil {
+			return nil, fmt.Errorf("unmarshal sla compliance: %w", err)
+		}
+	}
+	if quotaJSON != "" {
+		t.Quota = &Quota{}
+		if err := json.Unmarshal([]byte(quotaJSON), t.Quota); err != nil {
+			return nil, fmt.Errorf("unmarshal quota: %w", err)
+		}
+	}
+	if endpoints != "" {
+		if err := json.Unmarshal([]byte(endpoints), &t.Endpoints); err != nil {
+			return nil, fmt.Errorf("unmarshal endpoints: %w", err)
+		}
+	}
+	if retryJSON != "" {
+		t.Retry = &RetryPolicy{}
+		if err := json.Unmarshal([]byte(retryJSON), t.Retry); err != nil {
+			return nil, fmt.Errorf("unmarshal retry policy: %w", err)
+		}
+	}
+	if t.Features, err = decodeFeatures(features, t.Cache); err != nil {
+		return nil, err
+	}
+	if t.Descriptions, err = decodeDescriptions(descriptions); err != nil {
+		return nil, err
+	}
+	if t.Mock, err = decodeMock(mock); err != nil {
+		return nil, err
+	}
+	if tests != "" {
+		if err := json.Unmarshal([]byte(tests), &t.Tests); err != nil {
+			return nil, fmt.Errorf("unmarshal tests: %w", err)
+		}
+	}
+	if conformance != "" {
+		t.Conformance = &Conformance{}
+		if err := json.Unmarshal([]byte(conformance), t.Conformance); err != nil {
+			return nil, fmt.Errorf("unmarshal conformance: %w", err)
+		}
+	}
+	if _, err := assembleTool(&t, schemaJSON, pricingJSON, tags, createdAt, updatedAt, isActive); err != nil {
+		return nil, err
+	}
+	return applyPricingNotice(&t, noticeJSON, effectiveAt)
 }
 
 func scanTools(rows *sql.Rows) ([]*Tool, error) {
 	var tools []*Tool
 	for rows.Next() {
-		var (
-			t           Tool
-			schemaJSON  string
-			pricingJSON string
-			tags        string
-			createdAt   int64
-			updatedAt   int64
-			isActive    int
-		)
-		if err := rows.Scan(
-			&t.ID, &t.Name, &t.Version, &t.Description,
-			&schemaJSON, &pricingJSON, &t.ProviderID, &t.Endpoint,
-			&t.TimeoutMS, &tags, &createdAt, &updatedAt, &isActive,
-		); err != nil {
-			return nil, err
-		}
-		tool, err := assembleTool(&t, schemaJSON, pricingJSON, tags, createdAt, updatedAt, isActive)
+		tool, err := scanTool(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -406,8 +867,9 @@ func assembleTool(t *Tool, schemaJSON, pricingJSON, tags string, createdAt, upda
 	if err := json.Unmarshal([]byte(pricingJSON), t.Pricing); err != nil {
 		return nil, fmt.Errorf("unmarshal pricing: %w", err)
 	}
-	if tags != "" {
-		t.Tags = strings.Split(tags, ",")
+	var err error
+	if t.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
 	}
 	t.CreatedAt = time.Unix(createdAt, 0)
 	t.UpdatedAt = time.Unix(updatedAt, 0)
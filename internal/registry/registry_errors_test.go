@@ -94,3 +94,89 @@ func TestFailInvocation_BrokenDB(t *testing.T) {
 	err := r.FailInvocation(context.Background(), "inv-1", "timeout")
 	assert.Error(t, err)
 }
+
+func TestInterruptPendingInvocations_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	_, err := r.InterruptPendingInvocations(context.Background(), "shutdown")
+	assert.Error(t, err)
+}
+
+func TestBrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	for name, call := range map[string]func(context.Context) error{
+		"GetInvocation": func(ctx context.Context) error {
+			_, err := r.GetInvocation(ctx, "inv-1")
+			return err
+		},
+		"ListInvocations": func(ctx context.Context) error {
+			_, err := r.ListInvocations(ctx, registry.InvocationFilter{ConsumerID: "c"}, "", 10)
+			return err
+		},
+		"ToolVersions": func(ctx context.Context) error {
+			_, err := r.ToolVersions(ctx, "tool-id")
+			return err
+		},
+		"DiffSchemas": func(ctx context.Context) error {
+			_, err := r.DiffSchemas(ctx, "tool-id", "other-id")
+			return err
+		},
+		"PricingHistory": func(ctx context.Context) error {
+			_, err := r.PricingHistory(ctx, "tool-id")
+			return err
+		},
+		"GetPipeline": func(ctx context.Context) error {
+			_, err := r.GetPipeline(ctx, "pipe-1")
+			return err
+		},
+		"GetPipelineRun": func(ctx context.Context) error {
+			_, err := r.GetPipelineRun(ctx, "run-1")
+			return err
+		},
+		"DeletePipeline": func(ctx context.Context) error {
+			return r.DeletePipeline(ctx, "pipe-1", "prov-1")
+		},
+		"ListPolicies": func(ctx context.Context) error {
+			_, err := r.ListPolicies(ctx)
+			return err
+		},
+		"DeletePolicy": func(ctx context.Context) error {
+			return r.DeletePolicy(ctx, "pol-1")
+		},
+		"PurgePolicyDecisions": func(ctx context.Context) error {
+			return r.PurgePolicyDecisions(ctx)
+		},
+		"CheckSLAs": func(ctx context.Context) error {
+			return r.CheckSLAs(ctx)
+		},
+		"GetOrg": func(ctx context.Context) error {
+			_, err := r.GetOrg(ctx, "acme")
+			return err
+		},
+		"MemberOrgs": func(ctx context.Context) error {
+			_, err := r.MemberOrgs(ctx, "did:claw:agent:a")
+			return err
+		},
+		"OrgRole": func(ctx context.Context) error {
+			_, err := r.OrgRole(ctx, "acme", "did:claw:agent:a")
+			return err
+		},
+		"RecomputeReputation": func(ctx context.Context) error {
+			return r.RecomputeReputation(ctx)
+		},
+		"GetPayload": func(ctx context.Context) error {
+			_, err := r.GetPayload(ctx, "inv-1", "did:claw:agent:a")
+			return err
+		},
+		"PurgePayloads": func(ctx context.Context) error {
+			return r.PurgePayloads(ctx)
+		},
+		"ReceiptProof": func(ctx context.Context) error {
+			_, err := r.ReceiptProof(ctx, "inv-1")
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, call(context.Background()), "closed")
+		})
+	}
+}
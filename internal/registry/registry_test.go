@@ -7,9 +7,13 @@ import (
 
 	"github.com/clawinfra/agent-tools/internal/registry"
 	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func openTestDB(t *testing.T) *store.DB {
@@ -193,6 +197,60 @@ func TestListTools_Pagination(t *testing.T) {
 	assert.Len(t, page2.Tools, 2)
 }
 
+func TestListToolsAfter_WalksCatalog(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	// Tools registered within one second share created_at, so the walk
+	// also exercises the ID tie-break.
+	for i := 0; i < 7; i++ {
+		req := validRegisterReq()
+		req.Name = "tool-" + string(rune('a'+i))
+		_, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+	}
+	all, err := r.ListTools(ctx, 1, 20)
+	require.NoError(t, err)
+	assert.Empty(t, all.NextCursor, "a single page has no next cursor")
+
+	var walked []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		page, err := r.ListToolsAfter(ctx, cursor, 3)
+		require.NoError(t, err)
+		assert.Equal(t, 7, page.Total)
+		for _, tool := range page.Tools {
+			walked = append(walked, tool.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	want := make([]string, len(all.Tools))
+	for i, tool := range all.Tools {
+		want[i] = tool.ID
+	}
+	assert.Equal(t, want, walked, "the cursor walk lists every tool once, in page order")
+
+	first, err := r.ListTools(ctx, 1, 3)
+	require.NoError(t, err)
+	second, err := r.ListToolsAfter(ctx, first.NextCursor, 3)
+	require.NoError(t, err)
+	offset, err := r.ListTools(ctx, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, offset.Tools, second.Tools, "a page cursor continues where the page ends")
+}
+
+func TestListToolsAfter_InvalidCursor(t *testing.T) {
+	r := newTestRegistry(t)
+	for _, cursor := range []string{"!!", "bm9jb2xvbg", "eDpkaWQ"} {
+		_, err := r.ListToolsAfter(context.Background(), cursor, 10)
+		assert.ErrorIs(t, err, registry.ErrInvalidCursor, cursor)
+	}
+}
+
 func TestListTools_DefaultsPage(t *testing.T) {
 	r := newTestRegistry(t)
 	ctx := context.Background()
@@ -337,6 +395,33 @@ func TestFailInvocation(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestGetInvocation(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	invID, err := r.RecordInvocation(ctx, tool.ID, "did:claw:agent:consumer", map[string]any{"key": "value"})
+	require.NoError(t, err)
+
+	inv, err := r.GetInvocation(ctx, invID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", inv.Status)
+	assert.Equal(t, "did:claw:agent:consumer", inv.ConsumerID)
+	assert.Nil(t, inv.CompletedAt)
+
+	require.NoError(t, r.CompleteInvocation(ctx, invID, "sha256:output123", "", "5.0"))
+	inv, err = r.GetInvocation(ctx, invID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", inv.Status)
+	assert.Equal(t, "sha256:output123", inv.OutputHash)
+	assert.Equal(t, "5.0", inv.CostCLAW)
+	assert.NotNil(t, inv.CompletedAt)
+
+	_, err = r.GetInvocation(registry.WithNamespace(ctx, "acme"), invID)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
 func TestRegisterProvider_Success(t *testing.T) {
 	r := newTestRegistry(t)
 	ctx := context.Background()
@@ -540,3 +625,60 @@ func TestInvocation_CompleteAndFail(t *testing.T) {
 	err = r.FailInvocation(ctx, "nonexistent-inv", "timeout")
 	require.NoError(t, err) // No-op, no rows affected but no error
 }
+
+func TestRegisterTool_LogsRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	r := registry.New(openTestDB(t), zap.New(core))
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-123")
+
+	_, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	entries := logs.FilterMessage("tool registered").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "req-123", entries[0].ContextMap()["request_id"])
+}
+
+func TestInterruptPendingInvocations(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	_, err = r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": 1})
+	require.NoError(t, err)
+	done, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": 2})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteInvocation(ctx, done, "h", "s", "1"))
+
+	n, err := r.InterruptPendingInvocations(ctx, "shutdown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = r.InterruptPendingInvocations(ctx, "shutdown")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestInterruptPendingInvocations_ScopedToInstance(t *testing.T) {
+	db := openTestDB(t)
+	log := zaptest.NewLogger(t)
+	a := registry.New(db, log, registry.WithInstanceID("replica-a"))
+	b := registry.New(db, log, registry.WithInstanceID("replica-b"))
+	ctx := context.Background()
+
+	tool, err := a.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	_, err = a.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"a": 1})
+	require.NoError(t, err)
+	_, err = b.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"b": 1})
+	require.NoError(t, err)
+
+	n, err := a.InterruptPendingInvocations(ctx, "shutdown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = b.InterruptPendingInvocations(ctx, "shutdown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
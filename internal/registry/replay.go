@@ -0,0 +1,40 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInputNotStored is returned for an invocation whose input was not kept,
+// because neither party asked for payload storage or it has expired.
+var ErrInputNotStored = errors.New("invocation input not stored")
+
+// Replay is what is needed to run an invocation again.
+type Replay struct {
+	Invocation *Invocation
+	Tool       *Tool
+	Input      []byte
+}
+
+// ReplayInvocation returns invocation id with its tool and stored input.
+// owner, when set, must be the provider of the tool; admins pass "".
+func (r *Registry) ReplayInvocation(ctx context.Context, id, owner string) (*Replay, error) {
+	inv, err := r.GetInvocation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tool, err := r.GetTool(ctx, inv.ToolID)
+	if err != nil {
+		return nil, err
+	}
+	if owner != "" && owner != tool.ProviderID {
+		return nil, fmt.Errorf("%w: only the provider of %s may replay its invocations", ErrForbidden, tool.ID)
+	}
+	// Whoever the payload is keyed to, storing it consented to replay.
+	p, err := r.GetPayload(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Replay{Invocation: inv, Tool: tool, Input: p.Input}, nil
+}
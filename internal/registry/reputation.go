@@ -0,0 +1,214 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReputationConfig tunes how provider reputation weighs its history.
+type ReputationConfig struct {
+	// HalfLife is the age at which an event, such as a day of invocations
+	// or an SLA violation, counts half as much as a new one.
+	HalfLife time.Duration `json:"half_life"`
+	// InactiveHalfLife halves a positive reputation for every such period
+	// the provider has neither served invocations nor registered tools.
+	InactiveHalfLife time.Duration `json:"inactive_half_life"`
+}
+
+// DefaultReputationConfig applies unless WithReputation is used.
+var DefaultReputationConfig = ReputationConfig{HalfLife: 30 * 24 * time.Hour, InactiveHalfLife: 14 * 24 * time.Hour}
+
+// Validate checks that both half-lives are positive.
+func (c ReputationConfig) Validate() error {
+	if c.HalfLife <= 0 || c.InactiveHalfLife <= 0 {
+		return fmt.Errorf("reputation half-lives must be positive")
+	}
+	return nil
+}
+
+// Reasons of reputation events.
+const (
+	reputationSLA   = "sla_violated"
+	reputationCalls = "calls"
+)
+
+const (
+	// reputationDayPoints is what a day of invocations that all succeeded
+	// earns. Each failed call costs three times what a successful one
+	// earns, so a day of failures costs twice as much.
+	reputationDayPoints = 10
+	// reputationHorizon is how many half-lives events are kept for; older
+	// ones weigh under a thousandth.
+	reputationHorizon = 10
+)
+
+// WithReputation sets how reputation weighs history.
+func WithReputation(c ReputationConfig) Option {
+	return func(r *Registry) { r.reputation.Store(&c) }
+}
+
+// SetReputation replaces the reputation config of a running registry. It
+// applies from the next RecomputeReputation.
+func (r *Registry) SetReputation(c ReputationConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	r.reputation.Store(&c)
+	return nil
+}
+
+// ReputationConfig returns the reputation config in use.
+func (r *Registry) ReputationConfig() ReputationConfig {
+	if c := r.reputation.Load(); c != nil {
+		return *c
+	}
+	return DefaultReputationConfig
+}
+
+// RecomputeReputation rescores every provider from its history: each day
+// of invocations and each SLA violation weighs less the older it is, and a
+// positive score decays while the provider is inactive. It is intended to
+// be run periodically by the serve command, after the usage rollup.
+func (r *Registry) RecomputeReputation(ctx context.Context) error {
+	cfg := r.ReputationConfig()
+	now := time.Now().UTC()
+	cutoff := now.Add(-reputationHorizon * cfg.HalfLife)
+	if err := r.recordCallDays(ctx, cutoff); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM reputation_events WHERE at < ?", cutoff.Unix()); err != nil {
+		return fmt.Errorf("purge reputation events: %w", err)
+	}
+
+	changed, err := r.rescoreProviders(ctx, cfg, now)
+	if err != nil {
+		return err
+	}
+	err = r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		for id, rep := range changed {
+			if _, err := tx.ExecContext(ctx, "UPDATE providers SET reputation = ? WHERE id = ?", rep, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save reputation: %w", err)
+	}
+	r.log.Debug("reputation recomputed", zap.Int("changed", len(changed)))
+	return nil
+}
+
+// rescoreProviders returns the reputation of every provider whose score
+// changed, by ID.
+func (r *Registry) rescoreProviders(ctx context.Context, cfg ReputationConfig, now time.Time) (map[string]int64, error) {
+	scores, lastCall, err := r.reputationScores(ctx, cfg, now)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT id, reputation, last_seen FROM providers")
+	if err != nil {
+		return nil, fmt.Errorf("read providers: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	changed := map[string]int64{}
+	for rows.Next() {
+		var (
+			id                string
+			current, lastSeen int64
+		)
+		if err := rows.Scan(&id, &current, &lastSeen); err != nil {
+			return nil, fmt.Errorf("scan provider: %w", err)
+		}
+		score := scores[id]
+		if score > 0 {
+			score *= halve(now.Sub(time.Unix(max(lastSeen, lastCall[id]), 0)), cfg.InactiveHalfLife)
+		}
+		if rep := int64(math.Round(score)); rep != current {
+			changed[id] = rep
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read providers: %w", err)
+	}
+	return changed, nil
+}
+
+// reputationScores sums the reputation events of each provider, weighed by
+// age, and returns them with the end of each provider's last day of calls.
+func (r *Registry) reputationScores(ctx context.Context, cfg ReputationConfig, now time.Time) (
+	scores map[string]float64, lastCall map[string]int64, err error,
+) {
+	rows, err := r.db.QueryContext(ctx, "SELECT provider_id, reason, at, delta FROM reputation_events")
+	if err != nil {
+		return nil, nil, fmt.Errorf("read reputation events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	scores, lastCall = map[string]float64{}, map[string]int64{}
+	for rows.Next() {
+		var (
+			id, reason string
+			at         int64
+			delta      float64
+		)
+		if err := rows.Scan(&id, &reason, &at, &delta); err != nil {
+			return nil, nil, fmt.Errorf("scan reputation event: %w", err)
+		}
+		scores[id] += delta * halve(now.Sub(time.Unix(at, 0)), cfg.HalfLife)
+		if reason == reputationCalls {
+			lastCall[id] = max(lastCall[id], at+int64(24*time.Hour/time.Second))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read reputation events: %w", err)
+	}
+	return scores, lastCall, nil
+}
+
+// recordCallDays turns the daily usage since cutoff into one event per
+// provider and day, rewriting days whose usage grew since the last run.
+func (r *Registry) recordCallDays(ctx context.Context, cutoff time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO reputation_events (provider_id, reason, at, delta)
+		SELECT t.provider_id, ?, CAST(strftime('%s', u.day) AS INTEGER),
+			? * (SUM(u.calls) - 3.0 * SUM(u.errors)) / SUM(u.calls)
+		FROM usage_daily u JOIN tools t ON t.id = u.tool_id
+		WHERE u.day >= ?
+		GROUP BY t.provider_id, u.day
+		HAVING SUM(u.calls) > 0
+		ON CONFLICT (provider_id, reason, at) DO UPDATE SET delta = excluded.delta
+	`, reputationCalls, reputationDayPoints, cutoff.Format(dayLayout))
+	if err != nil {
+		return fmt.Errorf("record reputation of calls: %w", err)
+	}
+	return nil
+}
+
+// addReputationEvent records a one-off change of reputation, such as an SLA
+// violation, and applies it at once; RecomputeReputation weighs it by age
+// from then on.
+func addReputationEvent(ctx context.Context, tx *sql.Tx, providerID, reason string, delta int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO reputation_events (provider_id, reason, at, delta) VALUES (?, ?, ?, ?)
+		ON CONFLICT (provider_id, reason, at) DO UPDATE SET delta = delta + excluded.delta
+	`, providerID, reason, time.Now().Unix(), delta)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE providers SET reputation = reputation + ? WHERE id = ?", delta, providerID)
+	return err
+}
+
+// halve returns the weight of something age old: 1 when new, 1/2 after one
+// halfLife, 1/4 after two.
+func halve(age, halfLife time.Duration) float64 {
+	if age <= 0 {
+		return 1
+	}
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
@@ -0,0 +1,71 @@
+package registry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRecomputeReputation(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	const day = 24 * time.Hour
+	cfg := registry.ReputationConfig{HalfLife: 30 * day, InactiveHalfLife: 14 * day}
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithReputation(cfg))
+	now := time.Now()
+
+	// An active provider: a clean day of calls today and an SLA violation a
+	// half-life ago, which counts for half.
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO usage_daily (day, tool_id, consumer_id, calls, errors) VALUES (?, ?, 'c', 10, 0)",
+		now.UTC().Format("2006-01-02"), tool.ID)
+	require.NoError(t, err)
+	addEvent := func(id, reason string, age time.Duration, delta float64) {
+		_, err := db.ExecContext(ctx, "INSERT INTO reputation_events (provider_id, reason, at, delta) VALUES (?, ?, ?, ?)",
+			id, reason, now.Add(-age).Unix(), delta)
+		require.NoError(t, err)
+	}
+	addEvent(tool.ProviderID, "sla_violated", 30*day, -10)
+
+	// An idle provider: a good day fifteen days ago, not seen since.
+	for _, id := range []string{"did:claw:agent:idle", "did:claw:agent:bad"} {
+		_, err = r.RegisterProvider(ctx, &registry.Provider{ID: id, Endpoint: "https://p.example", PubKey: "ed25519:" + strings.Repeat("ab", 32)})
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "UPDATE providers SET last_seen = ? WHERE id = ?", now.Add(-60*day).Unix(), id)
+		require.NoError(t, err)
+	}
+	addEvent("did:claw:agent:idle", "calls", 15*day, 10)
+	// A bad provider does not recover by staying away.
+	addEvent("did:claw:agent:bad", "sla_violated", 0, -10)
+
+	require.NoError(t, r.RecomputeReputation(ctx))
+	reputation := func(id string) int64 {
+		p, err := r.GetProvider(ctx, id)
+		require.NoError(t, err)
+		return p.Reputation
+	}
+	assert.EqualValues(t, 10-5, reputation(tool.ProviderID))
+	assert.EqualValues(t, 4, reputation("did:claw:agent:idle"), "10 weighed by age to 7.1, halved by 14 idle days")
+	assert.EqualValues(t, -10, reputation("did:claw:agent:bad"))
+
+	// Failed calls cost more than successful ones earn.
+	_, err = db.ExecContext(ctx, "UPDATE usage_daily SET calls = 10, errors = 5")
+	require.NoError(t, err)
+	require.NoError(t, r.RecomputeReputation(ctx))
+	assert.EqualValues(t, -5-5, reputation(tool.ProviderID))
+
+	// A longer half-life remembers more of the past.
+	require.NoError(t, r.SetReputation(registry.ReputationConfig{HalfLife: 300 * day, InactiveHalfLife: 14 * day}))
+	require.NoError(t, r.RecomputeReputation(ctx))
+	assert.EqualValues(t, -5-9, reputation(tool.ProviderID))
+
+	assert.Error(t, r.SetReputation(registry.ReputationConfig{HalfLife: day}))
+	assert.Equal(t, 300*day, r.ReputationConfig().HalfLife)
+}
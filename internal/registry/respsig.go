@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+// ErrInvalidResponseSignature is returned for a provider answer whose
+// signature is missing when required, malformed or does not verify against
+// the provider's keys.
+var ErrInvalidResponseSignature = errors.New("invalid response signature")
+
+// WithRequiredResponseSignatures makes providers sign the receipt of every
+// invocation the registry proxies to them, in a
+// receipts.ResponseSignatureHeader. Signatures that are supplied are
+// always verified.
+func WithRequiredResponseSignatures(required bool) Option {
+	return func(r *Registry) { r.requireResponseSigs = required }
+}
+
+// VerifyResponseSignature checks rcpt.ProviderSig, what the provider of a
+// proxied invocation signed its answer with, against the keys of
+// rcpt.ProviderID: the invocation is only completed, with rcpt as its
+// receipt, when it verifies. An unsigned answer passes unless signatures
+// are required.
+func (r *Registry) VerifyResponseSignature(ctx context.Context, rcpt *Receipt) error {
+	if rcpt.ProviderSig == "" {
+		if r.requireResponseSigs {
+			return fmt.Errorf("%w: the provider did not sign its answer in %s", ErrInvalidResponseSignature,
+				receipts.ResponseSignatureHeader)
+		}
+		return nil
+	}
+	keys, err := r.VerificationKeys(ctx, rcpt.ProviderID)
+	if err != nil {
+		return fmt.Errorf("%w: resolve keys of %s: %w", ErrInvalidResponseSignature, rcpt.ProviderID, err)
+	}
+	if v := receipts.Verify(rcpt, nil, nil, keys...); v.Signature != receipts.CheckValid {
+		return fmt.Errorf("%w: %s", ErrInvalidResponseSignature, strings.Join(v.Reasons, "; "))
+	}
+	return nil
+}
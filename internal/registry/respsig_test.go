@@ -0,0 +1,49 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/did"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestVerifyResponseSignature(t *testing.T) {
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	rcpt := &registry.Receipt{
+		InvocationID: "inv_1",
+		ToolID:       "did:claw:tool:1",
+		ConsumerID:   "did:claw:agent:c",
+		ProviderID:   did.DIDKey(pub),
+		InputHash:    receipts.Hash(map[string]any{"a": 1}),
+		OutputHash:   receipts.Hash(map[string]any{"b": 2}),
+		CostCLAW:     "1.5",
+	}
+
+	r := newTestRegistry(t)
+	require.NoError(t, r.VerifyResponseSignature(ctx, rcpt), "unsigned answers pass by default")
+	receipts.Sign(rcpt, key)
+	require.NoError(t, r.VerifyResponseSignature(ctx, rcpt))
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	forged := *rcpt
+	receipts.Sign(&forged, other)
+	assert.ErrorIs(t, r.VerifyResponseSignature(ctx, &forged), registry.ErrInvalidResponseSignature)
+	tampered := *rcpt
+	tampered.OutputHash = receipts.Hash(map[string]any{"b": 3})
+	assert.ErrorIs(t, r.VerifyResponseSignature(ctx, &tampered), registry.ErrInvalidResponseSignature)
+
+	strict := registry.New(openTestDB(t), zaptest.NewLogger(t), registry.WithRequiredResponseSignatures(true))
+	require.NoError(t, strict.VerifyResponseSignature(ctx, rcpt))
+	unsigned := *rcpt
+	unsigned.ProviderSig = ""
+	assert.ErrorIs(t, strict.VerifyResponseSignature(ctx, &unsigned), registry.ErrInvalidResponseSignature)
+}
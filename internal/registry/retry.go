@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidRetryPolicy is returned for a retry policy with too few or too
+// many attempts, a negative backoff or an unknown error code.
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy")
+
+// Bounds of a retry policy.
+const (
+	maxRetryAttempts  = 10
+	maxRetryBackoffMS = 60_000
+)
+
+// StatusDeadLetter is the status of an invocation that still failed after
+// as many attempts as its tool's retry policy allows.
+const StatusDeadLetter = "dead_letter"
+
+// RetryCodes are the error codes a retry policy may retry on, besides the
+// HTTP statuses of the provider's answers.
+var RetryCodes = []string{"INVOKE_TIMEOUT", "TOOL_FAILED", "PROVIDER_OFFLINE"}
+
+// DefaultRetryOn is what a retry policy without RetryOn retries: timeouts,
+// push providers that are offline and gateway errors.
+var DefaultRetryOn = []string{"INVOKE_TIMEOUT", "PROVIDER_OFFLINE", "502", "503", "504"}
+
+// RetryPolicy has the registry retry failed invocations of a tool before
+// failing them for good.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an invocation is tried, the first
+	// attempt included.
+	MaxAttempts int `json:"max_attempts"`
+	// BackoffMS is the wait before the first retry; each further retry
+	// waits twice as long as the one before.
+	BackoffMS int64 `json:"backoff_ms,omitempty"`
+	// RetryOn are the failures that are retried: error codes of
+	// RetryCodes, or HTTP statuses the provider answered with, such as
+	// "503". Empty retries DefaultRetryOn.
+	RetryOn []string `json:"retry_on,omitempty"`
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 || p.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("%w: max_attempts must be between 1 and %d", ErrInvalidRetryPolicy, maxRetryAttempts)
+	}
+	if p.BackoffMS < 0 || p.BackoffMS > maxRetryBackoffMS {
+		return fmt.Errorf("%w: backoff_ms must be between 0 and %d", ErrInvalidRetryPolicy, maxRetryBackoffMS)
+	}
+	for _, code := range p.RetryOn {
+		if slices.Contains(RetryCodes, code) {
+			continue
+		}
+		if n, err := strconv.Atoi(code); err != nil || n < 400 || n > 599 {
+			return fmt.Errorf("%w: retry_on %q is neither an error code nor a 4xx or 5xx status", ErrInvalidRetryPolicy, code)
+		}
+	}
+	return nil
+}
+
+// Retries reports whether a failed attempt is retried: one that failed with
+// the error code, or after the provider answered with the HTTP status when
+// status is not 0.
+func (p *RetryPolicy) Retries(code string, status int) bool {
+	on := p.RetryOn
+	if len(on) == 0 {
+		on = DefaultRetryOn
+	}
+	return slices.Contains(on, code) || (status != 0 && slices.Contains(on, strconv.Itoa(status)))
+}
+
+// Backoff returns the wait before retrying after the given number of
+// attempts.
+func (p *RetryPolicy) Backoff(attempts int) time.Duration {
+	return time.Duration(p.BackoffMS) * time.Millisecond << (attempts - 1)
+}
+
+// encodeRetryPolicy returns the stored form of p, "" for none.
+func encodeRetryPolicy(p *RetryPolicy) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("marshal retry policy: %w", err)
+	}
+	return string(b), nil
+}
+
+type attemptsKey struct{}
+
+// WithAttempts records, on the invocation completed or failed with ctx, how
+// many times it was tried.
+func WithAttempts(ctx context.Context, attempts int) context.Context {
+	return context.WithValue(ctx, attemptsKey{}, attempts)
+}
+
+func attemptsOf(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptsKey{}).(int); ok {
+		return n
+	}
+	return 1
+}
+
+// DeadLetterInvocation marks an invocation that failed on every attempt its
+// tool's retry policy allows as dead-lettered, so it can be found with
+// ListInvocations and resubmitted.
+func (r *Registry) DeadLetterInvocation(ctx context.Context, id, reason string) error {
+	return r.failInvocation(ctx, id, StatusDeadLetter, reason)
+}
@@ -0,0 +1,100 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTool_RetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	req := validRegisterReq()
+	req.Retry = &registry.RetryPolicy{MaxAttempts: 3, BackoffMS: 100, RetryOn: []string{"TOOL_FAILED", "429"}}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, req.Retry, got.Retry)
+
+	for name, p := range map[string]*registry.RetryPolicy{
+		"no attempts":  {},
+		"too many":     {MaxAttempts: 11},
+		"backoff":      {MaxAttempts: 2, BackoffMS: -1},
+		"long backoff": {MaxAttempts: 2, BackoffMS: 60_001},
+		"code":         {MaxAttempts: 2, RetryOn: []string{"NOT_A_CODE"}},
+		"status":       {MaxAttempts: 2, RetryOn: []string{"200"}},
+	} {
+		req := validRegisterReq()
+		req.Name, req.Retry = "bad-"+name, p
+		_, err := r.RegisterTool(ctx, req)
+		assert.ErrorIs(t, err, registry.ErrInvalidRetryPolicy, name)
+	}
+}
+
+func TestRetryPolicy_Retries(t *testing.T) {
+	p := &registry.RetryPolicy{MaxAttempts: 3, BackoffMS: 100}
+	assert.True(t, p.Retries("INVOKE_TIMEOUT", 0))
+	assert.True(t, p.Retries("TOOL_FAILED", 503))
+	assert.False(t, p.Retries("TOOL_FAILED", 500), "not by default")
+	assert.False(t, p.Retries("TOOL_FAILED", 0))
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+
+	p.RetryOn = []string{"TOOL_FAILED"}
+	assert.True(t, p.Retries("TOOL_FAILED", 500))
+	assert.False(t, p.Retries("INVOKE_TIMEOUT", 0))
+}
+
+func TestListInvocations(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := r.RecordInvocation(ctx, tool.ID, "consumer", map[string]any{"i": i})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	_, err = r.RecordInvocation(ctx, tool.ID, "other", map[string]any{})
+	require.NoError(t, err)
+	require.NoError(t, r.DeadLetterInvocation(registry.WithAttempts(ctx, 3), ids[1], "503 Service Unavailable"))
+	require.NoError(t, r.DeadLetterInvocation(ctx, ids[3], "timeout"))
+	require.NoError(t, r.FailInvocation(ctx, ids[4], "boom"))
+
+	var seen []string
+	cursor := ""
+	for {
+		page, err := r.ListInvocations(ctx, registry.InvocationFilter{ConsumerID: "consumer"}, cursor, 2)
+		require.NoError(t, err)
+		for _, inv := range page.Invocations {
+			seen = append(seen, inv.ID)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.ElementsMatch(t, ids, seen, "each of the consumer's invocations once")
+
+	dead, err := r.ListInvocations(ctx,
+		registry.InvocationFilter{ConsumerID: "consumer", ToolID: tool.ID, Status: registry.StatusDeadLetter}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, dead.Invocations, 2)
+	assert.Empty(t, dead.NextCursor)
+	for _, inv := range dead.Invocations {
+		assert.Equal(t, registry.StatusDeadLetter, inv.Status)
+		if inv.ID == ids[1] {
+			assert.Equal(t, 3, inv.Attempts)
+			assert.Equal(t, "503 Service Unavailable", inv.Error)
+		}
+	}
+
+	_, err = r.ListInvocations(ctx, registry.InvocationFilter{ConsumerID: "consumer"}, "not a cursor", 0)
+	assert.ErrorIs(t, err, registry.ErrInvalidCursor)
+}
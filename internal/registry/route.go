@@ -0,0 +1,196 @@
+package registry
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Routing strategies every registry knows.
+const (
+	RouteCheapest          = "cheapest"
+	RouteLowestLatency     = "lowest_latency"
+	RouteHighestReputation = "highest_reputation"
+)
+
+// DefaultRouteStrategy is the strategy of a Registry created without
+// WithDefaultRouteStrategy.
+const DefaultRouteStrategy = RouteCheapest
+
+// RouteLatencyWindow is how far back the latency of a candidate is averaged.
+const RouteLatencyWindow = 7 * 24 * time.Hour
+
+var (
+	// ErrNoRoute is returned when no tool offers a capability.
+	ErrNoRoute = errors.New("no tool offers the capability")
+	// ErrUnknownStrategy is returned for a routing strategy the registry
+	// does not know.
+	ErrUnknownStrategy = errors.New("unknown routing strategy")
+)
+
+// RouteCandidate is a tool offering a capability, with what routing
+// strategies rank it by.
+type RouteCandidate struct {
+	Tool *Tool `json:"tool"`
+	// CostCLAW is what invoking the tool with the input is estimated to
+	// cost.
+	CostCLAW float64 `json:"cost_claw"`
+	// LatencyMS is the mean latency of the tool's invocations over the last
+	// RouteLatencyWindow, or -1 when it has not been invoked.
+	LatencyMS int64 `json:"latency_ms"`
+	// Reputation is the reputation of the tool's provider.
+	Reputation int64 `json:"reputation"`
+}
+
+// A RouteStrategy orders the candidates for a capability, best first. Sort
+// them stably, so equally ranked candidates stay oldest first.
+type RouteStrategy func(candidates []*RouteCandidate)
+
+// builtinStrategies returns the strategies every registry knows.
+func builtinStrategies() map[string]RouteStrategy {
+	return map[string]RouteStrategy{
+		RouteCheapest: func(c []*RouteCandidate) {
+			slices.SortStableFunc(c, func(a, b *RouteCandidate) int { return cmp.Compare(a.CostCLAW, b.CostCLAW) })
+		},
+		RouteLowestLatency: func(c []*RouteCandidate) {
+			// Tools never invoked go last: nothing says they are fast.
+			latency := func(rc *RouteCandidate) int64 {
+				if rc.LatencyMS < 0 {
+					return 1<<63 - 1
+				}
+				return rc.LatencyMS
+			}
+			slices.SortStableFunc(c, func(a, b *RouteCandidate) int { return cmp.Compare(latency(a), latency(b)) })
+		},
+		RouteHighestReputation: func(c []*RouteCandidate) {
+			slices.SortStableFunc(c, func(a, b *RouteCandidate) int { return cmp.Compare(b.Reputation, a.Reputation) })
+		},
+	}
+}
+
+// WithRouteStrategy adds a routing strategy under name, or replaces the
+// one of that name.
+func WithRouteStrategy(name string, s RouteStrategy) Option {
+	return func(r *Registry) { r.routes[name] = s }
+}
+
+// WithDefaultRouteStrategy sets the strategy used when a request names
+// none.
+func WithDefaultRouteStrategy(name string) Option {
+	return func(r *Registry) { r.routeDefault = name }
+}
+
+// RouteStrategies returns the names of the routing strategies r knows,
+// sorted.
+func (r *Registry) RouteStrategies() []string {
+	names := make([]string, 0, len(r.routes))
+	for name := range r.routes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Route returns the active, unrevoked tools offering capability, the latest
+// version of each tool of that name by each provider in the namespace,
+// ordered best first by strategy (the default one when empty) for the
+// encoded input.
+func (r *Registry) Route(ctx context.Context, capability, strategy string, input []byte) ([]*RouteCandidate, error) {
+	if strategy == "" {
+		strategy = r.routeDefault
+	}
+	rank, ok := r.routes[strategy]
+	if !ok {
+		return nil, fmt.Errorf("%w %q: must be one of %s", ErrUnknownStrategy, strategy, strings.Join(r.RouteStrategies(), ", "))
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+toolColumns+` FROM tools t
+		WHERE namespace = ? AND name = ? AND is_active = 1 AND advisory_status != 'revoked'
+		  AND created_at = (SELECT MAX(created_at) FROM tools
+			WHERE namespace = t.namespace AND provider_id = t.provider_id AND name = t.name AND is_active = 1)
+		ORDER BY created_at, rowid
+	`, NamespaceFrom(ctx), capability)
+	if err != nil {
+		return nil, fmt.Errorf("route: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	tools, err := scanTools(rows)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*RouteCandidate
+	for _, t := range tools {
+		cost, _ := strconv.ParseFloat(EstimateCost(t, input), 64)
+		candidates = append(candidates, &RouteCandidate{Tool: t, CostCLAW: cost, LatencyMS: -1})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrNoRoute, capability)
+	}
+	if err := r.routeStats(ctx, candidates); err != nil {
+		return nil, err
+	}
+	rank(candidates)
+	return candidates, nil
+}
+
+// routeStats fills in the latency and provider reputation of candidates.
+func (r *Registry) routeStats(ctx context.Context, candidates []*RouteCandidate) error {
+	byTool := map[string]*RouteCandidate{}
+	toolIDs := make([]any, 0, len(candidates))
+	providerIDs := make([]any, 0, len(candidates))
+	for _, c := range candidates {
+		byTool[c.Tool.ID] = c
+		toolIDs = append(toolIDs, c.Tool.ID)
+		providerIDs = append(providerIDs, c.Tool.ProviderID)
+	}
+	in := func(n int) string { return "(?" + strings.Repeat(", ?", n-1) + ")" }
+
+	since := time.Now().UTC().Add(-RouteLatencyWindow).Format(dayLayout)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tool_id, SUM(total_latency_ms) / SUM(calls) FROM usage_daily
+		WHERE day >= ? AND calls > 0 AND tool_id IN `+in(len(toolIDs))+`
+		GROUP BY tool_id
+	`, append([]any{since}, toolIDs...)...)
+	if err != nil {
+		return fmt.Errorf("route latency: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			id      string
+			latency int64
+		)
+		if err := rows.Scan(&id, &latency); err != nil {
+			return err
+		}
+		byTool[id].LatencyMS = latency
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reputations := map[string]int64{}
+	rows, err = r.db.QueryContext(ctx, "SELECT id, reputation FROM providers WHERE id IN "+in(len(providerIDs)), providerIDs...)
+	if err != nil {
+		return fmt.Errorf("route reputation: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			id  string
+			rep int64
+		)
+		if err := rows.Scan(&id, &rep); err != nil {
+			return err
+		}
+		reputations[id] = rep
+	}
+	for _, c := range candidates {
+		c.Reputation = reputations[c.Tool.ProviderID]
+	}
+	return rows.Err()
+}
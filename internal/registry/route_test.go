@@ -0,0 +1,84 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRoute(t *testing.T) {
+	db := openTestDB(t)
+	r := registry.New(db, zaptest.NewLogger(t), registry.WithRouteStrategy("newest", func(c []*registry.RouteCandidate) {
+		for i, j := 0, len(c)-1; i < j; i, j = i+1, j-1 {
+			c[i], c[j] = c[j], c[i]
+		}
+	}))
+	ctx := context.Background()
+
+	// Three providers offer "translate": cheap but slow, dear but fast, and
+	// middling but reputable. The cheap one also has an older version.
+	register := func(provider, version, price string) *registry.Tool {
+		req := validRegisterReq()
+		req.Name, req.Version, req.ProviderID = "translate", version, provider
+		req.Pricing = &registry.Pricing{Model: registry.PricingPerCall, AmountCLAW: price}
+		tool, err := r.RegisterTool(ctx, req)
+		require.NoError(t, err)
+		return tool
+	}
+	old := register("did:claw:agent:cheap", "0.9.0", "0.5")
+	_, err := db.ExecContext(ctx, "UPDATE tools SET created_at = created_at - 60 WHERE id = ?", old.ID)
+	require.NoError(t, err)
+	cheap := register("did:claw:agent:cheap", "1.0.0", "1.0")
+	fast := register("did:claw:agent:fast", "1.0.0", "3.0")
+	trusted := register("did:claw:agent:trusted", "1.0.0", "2.0")
+	other := validRegisterReq()
+	other.Name = "summarize"
+	_, err = r.RegisterTool(ctx, other)
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "UPDATE providers SET reputation = 90 WHERE id = ?", trusted.ProviderID)
+	require.NoError(t, err)
+	today := time.Now().UTC().Format("2006-01-02")
+	for id, latency := range map[string]int{cheap.ID: 900, fast.ID: 40} {
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO usage_daily (day, tool_id, consumer_id, calls, errors, total_latency_ms, max_latency_ms, spend_claw)
+			VALUES (?, ?, 'consumer', 10, 0, ?, ?, '0')
+		`, today, id, 10*latency, latency)
+		require.NoError(t, err)
+	}
+
+	ids := func(strategy string) []string {
+		candidates, err := r.Route(ctx, "translate", strategy, []byte(`{}`))
+		require.NoError(t, err)
+		var out []string
+		for _, c := range candidates {
+			out = append(out, c.Tool.ID)
+		}
+		return out
+	}
+	assert.Equal(t, []string{cheap.ID, trusted.ID, fast.ID}, ids(""), "cheapest by default, latest versions only")
+	assert.Equal(t, []string{cheap.ID, trusted.ID, fast.ID}, ids(registry.RouteCheapest))
+	assert.Equal(t, []string{fast.ID, cheap.ID, trusted.ID}, ids(registry.RouteLowestLatency), "never invoked goes last")
+	assert.Equal(t, []string{trusted.ID, cheap.ID, fast.ID}, ids(registry.RouteHighestReputation))
+	assert.Equal(t, []string{trusted.ID, fast.ID, cheap.ID}, ids("newest"))
+
+	candidates, err := r.Route(ctx, "translate", registry.RouteLowestLatency, []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), candidates[0].LatencyMS)
+	assert.Equal(t, 3.0, candidates[0].CostCLAW)
+	assert.Equal(t, int64(-1), candidates[2].LatencyMS)
+	assert.Equal(t, int64(90), candidates[2].Reputation)
+
+	_, err = r.Route(ctx, "translate", "random", nil)
+	assert.ErrorIs(t, err, registry.ErrUnknownStrategy)
+	_, err = r.Route(ctx, "transcribe", "", nil)
+	assert.ErrorIs(t, err, registry.ErrNoRoute)
+
+	require.NoError(t, r.SetAdvisory(ctx, cheap.ID, "", &registry.Advisory{Status: registry.AdvisoryRevoked, Note: "leaks input"}))
+	assert.Equal(t, []string{trusted.ID, fast.ID}, ids(registry.RouteCheapest), "revoked tools are never routed to")
+}
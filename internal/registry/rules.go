@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrProviderBlocked is returned when an operator rule rejects a provider.
+var ErrProviderBlocked = errors.New("provider blocked")
+
+// Rule actions and the provider attribute a rule matches.
+const (
+	RuleAllow    = "allow"
+	RuleDeny     = "deny"
+	RuleDID      = "did"
+	RuleEndpoint = "endpoint"
+)
+
+// ProviderRule allows or denies providers whose DID or endpoint matches
+// Pattern, where * matches any run of characters. Deny rules always win.
+// Once any allow rule exists, only providers matching an allow rule are
+// accepted.
+type ProviderRule struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	Field     string    `json:"field"`
+	Pattern   string    `json:"pattern"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// AddProviderRule stores a new rule. It takes effect for the next
+// registration or invocation.
+func (r *Registry) AddProviderRule(ctx context.Context, rule *ProviderRule) (*ProviderRule, error) {
+	if rule.Action != RuleAllow && rule.Action != RuleDeny {
+		return nil, fmt.Errorf("action must be %q or %q", RuleAllow, RuleDeny)
+	}
+	if rule.Field != RuleDID && rule.Field != RuleEndpoint {
+		return nil, fmt.Errorf("field must be %q or %q", RuleDID, RuleEndpoint)
+	}
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	rule.ID = "rule_" + uuid.NewString()
+	rule.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO provider_rules (id, action, field, pattern, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, rule.ID, rule.Action, rule.Field, rule.Pattern, rule.Reason, rule.CreatedAt.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("%w: %s %s %s", ErrDuplicate, rule.Action, rule.Field, rule.Pattern)
+		}
+		return nil, fmt.Errorf("insert provider rule: %w", err)
+	}
+	r.logger(ctx).Info("provider rule added", zap.String("id", rule.ID), zap.String("action", rule.Action),
+		zap.String("field", rule.Field), zap.String("pattern", rule.Pattern))
+	return rule, nil
+}
+
+// ListProviderRules returns all rules, oldest first.
+func (r *Registry) ListProviderRules(ctx context.Context) ([]*ProviderRule, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, action, field, pattern, reason, created_at FROM provider_rules ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("list provider rules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	rules := []*ProviderRule{}
+	for rows.Next() {
+		var (
+			rule    ProviderRule
+			created int64
+		)
+		if err := rows.Scan(&rule.ID, &rule.Action, &rule.Field, &rule.Pattern, &rule.Reason, &created); err != nil {
+			return nil, err
+		}
+		rule.CreatedAt = time.Unix(created, 0).UTC()
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteProviderRule removes a rule.
+func (r *Registry) DeleteProviderRule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM provider_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete provider rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CheckProvider returns ErrProviderBlocked when the rules reject a provider
+// with this DID at any of endpoints. An empty endpoint is only matched by
+// DID rules. The invoker calls it before every call so a new deny rule
+// stops traffic to already registered tools.
+func (r *Registry) CheckProvider(ctx context.Context, did string, endpoints ...string) error {
+	rules, err := r.ListProviderRules(ctx)
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		endpoints = []string{""}
+	}
+	for _, endpoint := range endpoints {
+		if err := checkRules(rules, did, endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRules applies rules to a provider with this DID and endpoint.
+func checkRules(rules []*ProviderRule, did, endpoint string) error {
+	allowed, haveAllow := false, false
+	for _, rule := range rules {
+		value := did
+		if rule.Field == RuleEndpoint {
+			value = endpoint
+		}
+		matched := value != "" && globMatch(rule.Pattern, value)
+		switch rule.Action {
+		case RuleDeny:
+			if matched {
+				return blocked(did, rule)
+			}
+		case RuleAllow:
+			haveAllow = true
+			allowed = allowed || matched
+		}
+	}
+	if haveAllow && !allowed {
+		return fmt.Errorf("%w: %s is not on the allowlist", ErrProviderBlocked, did)
+	}
+	return nil
+}
+
+func blocked(did string, rule *ProviderRule) error {
+	msg := fmt.Sprintf("%s: %s matches deny rule %s", did, rule.Field, rule.Pattern)
+	if rule.Reason != "" {
+		msg += " (" + rule.Reason + ")"
+	}
+	return fmt.Errorf("%w: %s", ErrProviderBlocked, msg)
+}
+
+// globMatch reports whether s matches pattern, where * matches any run of
+// characters (including /) and everything else matches literally.
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(s)
+}
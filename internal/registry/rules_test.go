@@ -0,0 +1,94 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRules_Deny(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	_, err := r.AddProviderRule(ctx, &registry.ProviderRule{
+		Action: registry.RuleDeny, Field: registry.RuleEndpoint, Pattern: "https://*.evil.example/*", Reason: "phishing",
+	})
+	require.NoError(t, err)
+
+	req := validRegisterReq()
+	req.Endpoint = "https://api.evil.example/v1/tool"
+	_, err = r.RegisterTool(ctx, req)
+	require.ErrorIs(t, err, registry.ErrProviderBlocked)
+	assert.Contains(t, err.Error(), "phishing")
+
+	assert.NoError(t, r.CheckProvider(ctx, "did:claw:agent:x", "https://good.example/tool"))
+	assert.ErrorIs(t, r.CheckProvider(ctx, "did:claw:agent:x", "https://good.example/tool", "https://api.evil.example/v1/tool"),
+		registry.ErrProviderBlocked, "every endpoint is checked")
+	assert.NoError(t, r.CheckProvider(ctx, "did:claw:agent:x"))
+}
+
+func TestProviderRules_AllowlistAndDenyWins(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	_, err := r.AddProviderRule(ctx, &registry.ProviderRule{
+		Action: registry.RuleAllow, Field: registry.RuleDID, Pattern: "did:claw:agent:trusted-*",
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, r.CheckProvider(ctx, "did:claw:agent:trusted-1", "https://a.example"))
+	assert.ErrorIs(t, r.CheckProvider(ctx, "did:claw:agent:other", "https://a.example"), registry.ErrProviderBlocked)
+
+	_, err = r.RegisterProvider(ctx, &registry.Provider{
+		ID: "did:claw:agent:other", Endpoint: "https://a.example", PubKey: "ed25519:aa",
+	})
+	assert.ErrorIs(t, err, registry.ErrProviderBlocked)
+
+	deny, err := r.AddProviderRule(ctx, &registry.ProviderRule{
+		Action: registry.RuleDeny, Field: registry.RuleDID, Pattern: "did:claw:agent:trusted-1",
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.CheckProvider(ctx, "did:claw:agent:trusted-1", ""), registry.ErrProviderBlocked)
+
+	require.NoError(t, r.DeleteProviderRule(ctx, deny.ID))
+	assert.NoError(t, r.CheckProvider(ctx, "did:claw:agent:trusted-1", ""))
+}
+
+func TestProviderRules_Manage(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	for _, bad := range []*registry.ProviderRule{
+		{Action: "block", Field: registry.RuleDID, Pattern: "x"},
+		{Action: registry.RuleDeny, Field: "name", Pattern: "x"},
+		{Action: registry.RuleDeny, Field: registry.RuleDID},
+	} {
+		_, err := r.AddProviderRule(ctx, bad)
+		assert.Error(t, err)
+	}
+
+	rule := &registry.ProviderRule{Action: registry.RuleDeny, Field: registry.RuleDID, Pattern: "did:*"}
+	_, err := r.AddProviderRule(ctx, rule)
+	require.NoError(t, err)
+	_, err = r.AddProviderRule(ctx, &registry.ProviderRule{Action: registry.RuleDeny, Field: registry.RuleDID, Pattern: "did:*"})
+	assert.ErrorIs(t, err, registry.ErrDuplicate)
+
+	rules, err := r.ListProviderRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, rule.ID, rules[0].ID)
+
+	assert.ErrorIs(t, r.DeleteProviderRule(ctx, "rule_missing"), registry.ErrNotFound)
+}
+
+func TestProviderRules_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	ctx := context.Background()
+	_, err := r.ListProviderRules(ctx)
+	assert.Error(t, err)
+	assert.Error(t, r.CheckProvider(ctx, "did:claw:agent:x", ""))
+	assert.Error(t, r.DeleteProviderRule(ctx, "x"))
+}
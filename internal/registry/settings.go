@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetSetting stores v, encoded as JSON, as the setting name, which every
+// replica sharing the database reads. A nil v deletes it.
+func (r *Registry) SetSetting(ctx context.Context, name string, v any) error {
+	if v == nil {
+		if _, err := r.db.ExecContext(ctx, "DELETE FROM settings WHERE name = ?", name); err != nil {
+			return fmt.Errorf("delete setting %s: %w", name, err)
+		}
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode setting %s: %w", name, err)
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO settings (name, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, name, string(b), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("store setting %s: %w", name, err)
+	}
+	return nil
+}
+
+// Setting decodes the setting name into v and reports whether it is set.
+func (r *Registry) Setting(ctx context.Context, name string, v any) (bool, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, "SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get setting %s: %w", name, err)
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("decode setting %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,34 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	type mode struct {
+		Mode string `json:"mode"`
+	}
+
+	var got mode
+	ok, err := r.Setting(ctx, "maintenance", &got)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, r.SetSetting(ctx, "maintenance", mode{Mode: "full"}))
+	require.NoError(t, r.SetSetting(ctx, "maintenance", mode{Mode: "read-only"}))
+	ok, err = r.Setting(ctx, "maintenance", &got)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "read-only", got.Mode)
+
+	require.NoError(t, r.SetSetting(ctx, "maintenance", nil))
+	ok, err = r.Setting(ctx, "maintenance", &got)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,262 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+	"github.com/clawinfra/agent-tools/internal/router"
+	"go.uber.org/zap"
+)
+
+const (
+	// SLAWindow is how far back samples count towards SLA compliance.
+	SLAWindow = 24 * time.Hour
+	// slaMinSamples is how many samples a window needs before a tool can
+	// be found in violation of its SLA.
+	slaMinSamples = 10
+	// slaPenalty is the reputation a provider loses each time one of its
+	// tools starts violating its SLA.
+	slaPenalty = 10
+	// slaProbeTimeout bounds one health check.
+	slaProbeTimeout = 10 * time.Second
+)
+
+// Sources of SLA samples.
+const (
+	slaSourceInvocation = "invocation"
+	slaSourceProbe      = "probe"
+)
+
+// SLA states of a tool.
+const (
+	SLAMet              = "met"
+	SLAViolated         = "violated"
+	SLAInsufficientData = "insufficient_data"
+)
+
+// SLA is what a provider promises about a tool: that invocations complete
+// within MaxLatencyMS at the 95th percentile, and that it is up, answering
+// invocations and health checks without failing, UptimePercent of the time.
+// Either may be zero to promise nothing about it.
+type SLA struct {
+	MaxLatencyMS  int64   `json:"max_latency_ms,omitempty"`
+	UptimePercent float64 `json:"uptime_percent,omitempty"`
+}
+
+// validate checks an SLA against the tool's timeout.
+func (s *SLA) validate(timeoutMS int64) error {
+	if s.MaxLatencyMS == 0 && s.UptimePercent == 0 {
+		return fmt.Errorf("sla must set max_latency_ms or uptime_percent")
+	}
+	if s.MaxLatencyMS < 0 || s.MaxLatencyMS > timeoutMS {
+		return fmt.Errorf("sla max_latency_ms must be between 0 and timeout_ms")
+	}
+	if s.UptimePercent < 0 || s.UptimePercent > 100 {
+		return fmt.Errorf("sla uptime_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// SLACompliance is how a tool measured up to its SLA over the day (the
+// SLAWindow) before CheckedAt.
+type SLACompliance struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	Status        string    `json:"status"`
+	Samples       int64     `json:"samples"`
+	UptimePercent float64   `json:"uptime_percent"`
+	P95LatencyMS  int64     `json:"p95_latency_ms"`
+}
+
+// slaViolated reports whether the tool was last found violating its SLA.
+func (t *Tool) slaViolated() bool {
+	return t.SLACompliance != nil && t.SLACompliance.Status == SLAViolated
+}
+
+// recordSLASample records the outcome of one call of a tool that declares
+// an SLA. Errors are logged: a lost sample must not fail the invocation.
+func (r *Registry) recordSLASample(ctx context.Context, toolID, source string, ok bool, elapsed time.Duration) {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO sla_samples (tool_id, at, ok, latency_ms, source) VALUES (?, ?, ?, ?, ?)",
+		toolID, time.Now().Unix(), ok, elapsed.Milliseconds(), source)
+	if err != nil {
+		r.logger(ctx).Warn("record sla sample", zap.String("tool_id", toolID), zap.Error(err))
+	}
+}
+
+// slaTool is an active tool with an SLA, as CheckSLAs reads it.
+type slaTool struct {
+	id, namespace, providerID, endpoint string
+	sla                                 SLA
+	violated                            bool
+}
+
+// CheckSLAs probes the endpoints of active tools that declare an SLA,
+// recomputes their compliance from the samples of the last SLAWindow and
+// drops older samples. A tool that starts violating its SLA costs its
+// provider reputation and sinks to the end of search results until it
+// complies again. It is intended to be run periodically by the serve
+// command.
+func (r *Registry) CheckSLAs(ctx context.Context) error {
+	tools, err := r.slaTools(ctx)
+	if err != nil {
+		return err
+	}
+	hc := &http.Client{Transport: r.EndpointTransport(), Timeout: slaProbeTimeout}
+	for _, t := range tools {
+		if router.Routable(t.endpoint) {
+			ok, elapsed := probe(ctx, hc, t.endpoint)
+			r.recordSLASample(ctx, t.id, slaSourceProbe, ok, elapsed)
+		}
+		if err := r.evaluateSLA(ctx, t); err != nil {
+			return err
+		}
+	}
+	cutoff := time.Now().Add(-SLAWindow).Unix()
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM sla_samples WHERE at < ?", cutoff); err != nil {
+		return fmt.Errorf("purge sla samples: %w", err)
+	}
+	return nil
+}
+
+func (r *Registry) slaTools(ctx context.Context) ([]slaTool, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, namespace, provider_id, endpoint, sla, sla_violated FROM tools
+		WHERE sla != '' AND is_active = 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list sla tools: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var tools []slaTool
+	for rows.Next() {
+		var (
+			t       slaTool
+			slaJSON string
+		)
+		if err := rows.Scan(&t.id, &t.namespace, &t.providerID, &t.endpoint, &slaJSON, &t.violated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(slaJSON), &t.sla); err != nil {
+			return nil, fmt.Errorf("unmarshal sla of %s: %w", t.id, err)
+		}
+		tools = append(tools, t)
+	}
+	return tools, rows.Err()
+}
+
+// probe checks that endpoint answers. Tool endpoints expect invocations, so
+// any response but a server error counts as up.
+func probe(ctx context.Context, hc *http.Client, endpoint string) (bool, time.Duration) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, http.NoBody)
+	if err != nil {
+		return false, 0
+	}
+	resp, err := hc.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return false, elapsed
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError, elapsed
+}
+
+// evaluateSLA recomputes the compliance of t and penalizes its provider
+// when t starts violating its SLA.
+func (r *Registry) evaluateSLA(ctx context.Context, t slaTool) error {
+	now := time.Now()
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT ok, latency_ms FROM sla_samples WHERE tool_id = ? AND at >= ?", t.id, now.Add(-SLAWindow).Unix())
+	if err != nil {
+		return fmt.Errorf("read sla samples: %w", err)
+	}
+	var (
+		total     int64
+		latencies []int64
+	)
+	for rows.Next() {
+		var (
+			ok      bool
+			latency int64
+		)
+		if err := rows.Scan(&ok, &latency); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		total++
+		if ok {
+			latencies = append(latencies, latency)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	c := compliance(&t.sla, total, latencies)
+	c.CheckedAt = now.UTC()
+	return r.saveCompliance(ctx, t, c)
+}
+
+// compliance measures an SLA against total samples, of which the
+// successful ones took latencies.
+func compliance(sla *SLA, total int64, latencies []int64) *SLACompliance {
+	c := &SLACompliance{Samples: total, Status: SLAInsufficientData}
+	if total == 0 {
+		return c
+	}
+	c.UptimePercent = float64(len(latencies)) * 100 / float64(total)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		c.P95LatencyMS = latencies[(len(latencies)*95+99)/100-1]
+	}
+	if total < slaMinSamples {
+		return c
+	}
+	c.Status = SLAMet
+	if sla.UptimePercent > 0 && c.UptimePercent < sla.UptimePercent ||
+		sla.MaxLatencyMS > 0 && c.P95LatencyMS > sla.MaxLatencyMS {
+		c.Status = SLAViolated
+	}
+	return c
+}
+
+// saveCompliance stores the compliance of t and, if t has just started
+// violating its SLA, takes slaPenalty off its provider's reputation.
+func (r *Registry) saveCompliance(ctx context.Context, t slaTool, c *SLACompliance) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal sla compliance: %w", err)
+	}
+	violated := c.Status == SLAViolated
+	penalize := violated && !t.violated
+	err = r.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE tools SET sla_compliance = ?, sla_violated = ? WHERE id = ?", string(b), violated, t.id); err != nil {
+			return err
+		}
+		if !penalize {
+			return nil
+		}
+		return addReputationEvent(ctx, tx, t.providerID, reputationSLA, -slaPenalty)
+	})
+	if err != nil {
+		return fmt.Errorf("save sla compliance: %w", err)
+	}
+	if penalize {
+		ctx := WithNamespace(ctx, t.namespace)
+		r.logger(ctx).Warn("tool violates its sla",
+			zap.String("tool_id", t.id),
+			zap.String("provider", t.providerID),
+			zap.Float64("uptime_percent", c.UptimePercent),
+			zap.Int64("p95_latency_ms", c.P95LatencyMS),
+		)
+		r.publish(ctx, events.ToolSLAViolated, t.id, "", map[string]any{"id": t.id, "sla": t.sla, "compliance": c})
+	}
+	return nil
+}
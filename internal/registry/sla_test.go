@@ -0,0 +1,93 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLA_Validate(t *testing.T) {
+	for _, sla := range []*registry.SLA{
+		{},
+		{MaxLatencyMS: -1, UptimePercent: 99},
+		{MaxLatencyMS: 20000},
+		{UptimePercent: 101},
+	} {
+		req := validRegisterReq()
+		req.SLA = sla
+		assert.Error(t, req.Validate(), "%+v", sla)
+	}
+	req := validRegisterReq()
+	req.SLA = &registry.SLA{MaxLatencyMS: 500, UptimePercent: 99.5}
+	assert.NoError(t, req.Validate())
+}
+
+func TestCheckSLAs(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	req := validRegisterReq()
+	req.Endpoint = srv.URL
+	req.SLA = &registry.SLA{MaxLatencyMS: 500, UptimePercent: 90}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req.SLA, tool.SLA)
+	assert.Nil(t, tool.SLACompliance, "not checked yet")
+
+	other := validRegisterReq()
+	other.Name = "other-tool"
+	_, err = r.RegisterTool(ctx, other)
+	require.NoError(t, err)
+
+	require.NoError(t, r.CheckSLAs(ctx))
+	tool, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	require.NotNil(t, tool.SLACompliance)
+	assert.Equal(t, registry.SLAInsufficientData, tool.SLACompliance.Status)
+	assert.EqualValues(t, 1, tool.SLACompliance.Samples, "one probe")
+	assert.Equal(t, 100.0, tool.SLACompliance.UptimePercent)
+
+	for i := 0; i < 9; i++ {
+		r.ObserveInvocation(ctx, tool, "", 100*time.Millisecond, nil)
+	}
+	require.NoError(t, r.CheckSLAs(ctx))
+	tool, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registry.SLAMet, tool.SLACompliance.Status)
+
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		r.ObserveInvocation(ctx, tool, "", time.Second, errors.New("boom"))
+	}
+	require.NoError(t, r.CheckSLAs(ctx))
+	require.NoError(t, r.CheckSLAs(ctx))
+	tool, err = r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, registry.SLAViolated, tool.SLACompliance.Status)
+	assert.EqualValues(t, 15, tool.SLACompliance.Samples, "4 probes and 11 invocations")
+	assert.Less(t, tool.SLACompliance.UptimePercent, 90.0)
+
+	p, err := r.GetProvider(ctx, req.ProviderID)
+	require.NoError(t, err)
+	assert.EqualValues(t, -10, p.Reputation, "penalized once, when the violation started")
+
+	res, err := r.SearchTools(ctx, &registry.SearchQuery{})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 2)
+	assert.Equal(t, tool.ID, res.Tools[1].ID, "the violating tool sinks to the end")
+}
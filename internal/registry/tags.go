@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CanonicalTags returns tags trimmed, lowercased and in Unicode NFC form,
+// without empty or repeated tags, in their original order. Registration
+// stores tags in this form, so "Security", " security" and "SECURITY" are
+// one tag.
+func CanonicalTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(norm.NFC.String(strings.TrimSpace(tag)))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// encodeTags stores tags as a JSON array, which, unlike a joined string,
+// survives tags containing the separator.
+func encodeTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	b, _ := json.Marshal(tags)
+	return string(b)
+}
+
+func decodeTags(s string) ([]string, error) {
+	tags := []string{}
+	if s == "" {
+		return tags, nil
+	}
+	if err := json.Unmarshal([]byte(s), &tags); err != nil {
+		return nil, fmt.Errorf("unmarshal tags: %w", err)
+	}
+	return tags, nil
+}
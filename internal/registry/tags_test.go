@@ -0,0 +1,68 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalTags(t *testing.T) {
+	for _, tc := range []struct {
+		in, want []string
+	}{
+		{nil, nil},
+		{[]string{"", "  "}, []string{}},
+		{[]string{" Security", "security ", "SECURITY", "audit"}, []string{"security", "audit"}},
+		{[]string{"Ünïcode", "ünïcode"}, []string{"ünïcode"}},
+		// A decomposed "é" (e + combining acute) is the same tag as "é".
+		{[]string{"caf\u00e9", "cafe\u0301"}, []string{"caf\u00e9"}},
+		{[]string{"a,b", "a", "b"}, []string{"a,b", "a", "b"}},
+	} {
+		assert.Equal(t, tc.want, registry.CanonicalTags(tc.in), "%q", tc.in)
+	}
+}
+
+func TestRegisterTool_TagsRoundTrip(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.Tags = []string{"Comma,Separated", " 漢字 ", `quote"d`, `back\slash`, "comma,separated", ""}
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	want := []string{"comma,separated", "漢字", `quote"d`, `back\slash`}
+	assert.Equal(t, want, tool.Tags)
+
+	got, err := r.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want, got.Tags)
+
+	found, err := r.SearchTools(ctx, &registry.SearchQuery{Query: "漢字"})
+	require.NoError(t, err)
+	require.Len(t, found.Tools, 1, "tags stay searchable")
+	assert.Equal(t, want, found.Tools[0].Tags)
+
+	req = validRegisterReq()
+	req.Name = "untagged"
+	req.Tags = nil
+	tool, err = r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, tool.Tags, "no tags is an empty list, not null")
+}
+
+func TestRegisterTool_SignedCanonicalTags(t *testing.T) {
+	// The manifest hashes canonical tags, so a signature over the tags as
+	// the provider wrote them still verifies.
+	a := validRegisterReq()
+	a.Tags = []string{"Security", " audit", "security"}
+	b := validRegisterReq()
+	b.Tags = []string{"security", "audit"}
+	ha, err := registry.ManifestHash(a)
+	require.NoError(t, err)
+	hb, err := registry.ManifestHash(b)
+	require.NoError(t, err)
+	assert.Equal(t, hb, ha)
+}
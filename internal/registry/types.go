@@ -4,24 +4,76 @@ package registry
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
 )
 
 // Tool represents a registered tool in the registry.
 type Tool struct {
-	UpdatedAt   time.Time  `json:"updated_at"`
-	CreatedAt   time.Time  `json:"created_at"`
-	Pricing     *Pricing   `json:"pricing"`
-	ProviderID  string     `json:"provider_id"`
-	Description string     `json:"description"`
-	ID          string     `json:"id"`
-	Endpoint    string     `json:"endpoint"`
-	Version     string     `json:"version"`
-	Name        string     `json:"name"`
-	Schema      ToolSchema `json:"schema"`
-	Tags        []string   `json:"tags"`
-	TimeoutMS   int64      `json:"timeout_ms"`
-	IsActive    bool       `json:"is_active"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+	// Pricing is what an invocation is charged now: during the notice
+	// period of a price rise, the price the rise replaces.
+	Pricing     *Pricing `json:"pricing"`
+	ProviderID  string   `json:"provider_id"`
+	Description string   `json:"description"`
+	ID          string   `json:"id"`
+	Endpoint    string   `json:"endpoint"`
+	Version     string   `json:"version"`
+	Name        string   `json:"name"`
+	Namespace   string   `json:"namespace"`
+	Source      string   `json:"source,omitempty"` // set in search results: "local" or the peer name
+	// ManifestHash identifies the exact schema, pricing and endpoint of this
+	// version; see ManifestHash. It is empty for tools registered before
+	// manifests were hashed.
+	ManifestHash      string `json:"manifest_hash,omitempty"`
+	ManifestSignature string `json:"manifest_signature,omitempty"`
+	// ManifestCID is the CID the manifest was pinned under in
+	// content-addressed storage, when the registry pins manifests.
+	ManifestCID string       `json:"manifest_cid,omitempty"`
+	Advisory    *Advisory    `json:"advisory,omitempty"`
+	Cache       *CachePolicy `json:"cache,omitempty"`
+	// Deterministic is set for tools whose results are cached, those with
+	// a deterministic cache policy.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Features are what the tool supports: "streaming", "batch",
+	// "cacheable" and "deterministic".
+	Features []string `json:"features,omitempty"`
+	// Icon is the digest of the tool's icon, served at
+	// GET /v1/tools/{id}/icon, or empty when it has none.
+	Icon string `json:"icon,omitempty"`
+	// Language is the BCP 47 tag of the language of Description, empty for
+	// the default "en", and Descriptions are its translations by tag.
+	Language     string            `json:"language,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	// Compat is set when the provider asked for this version's schemas to
+	// be checked against the version registered before it.
+	Compat *Compatibility `json:"compat,omitempty"`
+	SLA    *SLA           `json:"sla,omitempty"`
+	// SLACompliance is how the tool measured up to its SLA when last
+	// checked.
+	SLACompliance *SLACompliance `json:"sla_compliance,omitempty"`
+	Quota         *Quota         `json:"quota,omitempty"`
+	// PricingNotice announces the price this version was registered at
+	// while it is not charged yet, during the notice period of a rise.
+	PricingNotice *PricingNotice `json:"pricing_notice,omitempty"`
+	// Endpoints are further endpoints serving the tool, such as fallbacks
+	// or regional replicas, spread over and failed over to as Routing says.
+	Endpoints []string     `json:"endpoints,omitempty"`
+	Routing   string       `json:"routing,omitempty"`
+	Retry     *RetryPolicy `json:"retry,omitempty"`
+	// Mock answers invocations that ask for it in place of the provider.
+	Mock *Mock `json:"mock,omitempty"`
+	// Tests are the tool's conformance tests, and Conformance the outcome
+	// of their last run.
+	Tests       []ToolTest   `json:"tests,omitempty"`
+	Conformance *Conformance `json:"conformance,omitempty"`
+	Schema      ToolSchema   `json:"schema"`
+	Tags        []string     `json:"tags"`
+	TimeoutMS   int64        `json:"timeout_ms"`
+	IsActive    bool         `json:"is_active"`
 }
 
 // ToolSchema defines the input and output JSON schemas for a tool.
@@ -44,6 +96,44 @@ func (s ToolSchema) Validate() error {
 	return nil
 }
 
+// CachePolicy declares that a tool's results may be served from cache:
+// invocations with the same input within TTLSeconds of a completed one get
+// its output without calling the tool, at PriceCLAW per call (free when
+// empty) instead of the tool's price.
+type CachePolicy struct {
+	Deterministic bool   `json:"deterministic"`
+	TTLSeconds    int64  `json:"ttl_seconds"`
+	PriceCLAW     string `json:"price_claw,omitempty"` // decimal string
+}
+
+// TTL returns how long a result stays cached.
+func (c *CachePolicy) TTL() time.Duration {
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+// validate checks a cache policy against the tool's pricing.
+func (c *CachePolicy) validate(p *Pricing) error {
+	if !c.Deterministic {
+		return fmt.Errorf("cache requires deterministic: true")
+	}
+	if c.TTLSeconds <= 0 {
+		return fmt.Errorf("cache ttl_seconds must be positive")
+	}
+	if c.PriceCLAW == "" {
+		return nil
+	}
+	price, err := strconv.ParseFloat(c.PriceCLAW, 64)
+	if err != nil || price < 0 {
+		return fmt.Errorf("cache price_claw must be a non-negative decimal")
+	}
+	if p.Model == PricingPerCall {
+		if amount, err := strconv.ParseFloat(p.AmountCLAW, 64); err == nil && price > amount {
+			return fmt.Errorf("cache price_claw must not exceed the per-call price")
+		}
+	}
+	return nil
+}
+
 // PricingModel enumerates how a tool charges for invocations.
 type PricingModel string
 
@@ -77,18 +167,62 @@ type Provider struct {
 	Endpoint   string    `json:"endpoint"`
 	PubKey     string    `json:"pubkey"`
 	StakeCLAW  string    `json:"stake_claw"`
+	Namespace  string    `json:"namespace"`
 	Reputation int64     `json:"reputation"`
 }
 
 // RegisterToolRequest is the input for tool registration.
 type RegisterToolRequest struct {
-	Pricing     *Pricing        `json:"pricing"`
-	Name        string          `json:"name"`
-	Version     string          `json:"version"`
-	Description string          `json:"description"`
-	Endpoint    string          `json:"endpoint"`
-	ProviderID  string          `json:"-"`
-	Schema      ToolSchema      `json:"schema"`
+	Pricing     *Pricing      `json:"pricing"`
+	Name        string        `json:"name"`
+	Version     string        `json:"version"`
+	Description string        `json:"description"`
+	Endpoint    string        `json:"endpoint"`
+	ProviderID  string        `json:"-"`
+	Schema      ToolSchema    `json:"schema"`
+	Auth        *EndpointAuth `json:"auth,omitempty"` // stored encrypted, never returned
+	Cache       *CachePolicy  `json:"cache,omitempty"`
+	// Deterministic declares that the tool's output depends only on its
+	// input. Without a Cache policy it caches results for DefaultCacheTTL,
+	// served free of charge.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Features declares what the tool supports; see Tool.Features.
+	// Declaring cacheable or deterministic is the same as Deterministic.
+	Features []string `json:"features,omitempty"`
+	// Language is the BCP 47 tag of the language of Description, and
+	// Descriptions are translations of it keyed by their tags, for
+	// Tool.Localize to pick from.
+	Language     string            `json:"language,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	SLA          *SLA              `json:"sla,omitempty"`
+	// Quota limits calls per consumer. It is not part of the manifest, so
+	// providers can change it with PUT /v1/tools/{id}/quota.
+	Quota *Quota `json:"quota,omitempty"`
+	// Endpoints are fallbacks or replicas of Endpoint, and Routing how
+	// invocations use them: RoutingFailover (the default) or
+	// RoutingRoundRobin.
+	Endpoints []string `json:"endpoints,omitempty"`
+	Routing   string   `json:"routing,omitempty"`
+	// Retry has failed invocations retried; see RetryPolicy.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Mock lets consumers try the tool for free; see Mock.
+	Mock *Mock `json:"mock,omitempty"`
+	// Tests are run on registration and periodically after; see ToolTest.
+	Tests []ToolTest `json:"tests,omitempty"`
+	// ManifestHash, if set, must equal the hash the registry computes.
+	ManifestHash string `json:"manifest_hash,omitempty"`
+	// ManifestSignature is the base64 Ed25519 signature of the manifest hash
+	// by the provider's registered pubkey.
+	ManifestSignature string `json:"manifest_signature,omitempty"`
+	// Nonce, random and used once, and SignedAt, within
+	// ConsumerSignatureSkew of the registry's clock, are part of the
+	// manifest, so a signed registration cannot be replayed to register
+	// the tool again once it is deleted.
+	Nonce    string     `json:"nonce,omitempty"`
+	SignedAt *time.Time `json:"signed_at,omitempty"`
+	// CheckCompat compares the schemas with those of the latest earlier
+	// version of the tool and records whether the change is breaking.
+	CheckCompat bool            `json:"check_compat,omitempty"`
 	Tags        []string        `json:"tags"`
 	RawSchema   json.RawMessage `json:"-"`
 	TimeoutMS   int64           `json:"timeout_ms"`
@@ -111,6 +245,47 @@ func (r *RegisterToolRequest) Validate() error {
 	if r.Pricing == nil {
 		r.Pricing = &Pricing{Model: PricingFree}
 	}
+	r.Tags = CanonicalTags(r.Tags)
+	if err := r.validateFeatures(); err != nil {
+		return err
+	}
+	if err := r.validateLanguages(); err != nil {
+		return err
+	}
+	if r.Deterministic && r.Cache == nil {
+		r.Cache = &CachePolicy{Deterministic: true, TTLSeconds: int64(DefaultCacheTTL / time.Second)}
+	}
+	if r.Cache != nil {
+		if err := r.Cache.validate(r.Pricing); err != nil {
+			return err
+		}
+	}
+	if r.SLA != nil {
+		if err := r.SLA.validate(r.TimeoutMS); err != nil {
+			return err
+		}
+	}
+	if r.Quota != nil {
+		if err := r.Quota.validate(); err != nil {
+			return err
+		}
+	}
+	if err := r.validateEndpoints(); err != nil {
+		return err
+	}
+	if r.Retry != nil {
+		if err := r.Retry.validate(); err != nil {
+			return err
+		}
+	}
+	if r.Mock != nil {
+		if err := r.Mock.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateTests(r.Tests); err != nil {
+		return err
+	}
 	return r.Schema.Validate()
 }
 
@@ -122,6 +297,9 @@ type SearchQuery struct {
 	MaxPrice float64 `json:"max_price_claw"`
 	Page     int     `json:"page"`
 	Limit    int     `json:"limit"`
+	// Federated includes tools mirrored from peer registries. Mirrors are
+	// only visible from the default namespace.
+	Federated bool `json:"federated"`
 }
 
 // SearchResult is the response from a tool search.
@@ -131,6 +309,9 @@ type SearchResult struct {
 	Total int     `json:"total"`
 	Page  int     `json:"page"`
 	Limit int     `json:"limit"`
+	// NextCursor continues a listing after this page; it is empty on the
+	// last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // Invocation tracks a single tool invocation lifecycle.
@@ -146,15 +327,65 @@ type Invocation struct {
 	StartedAt   time.Time  `json:"started_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 	Error       string     `json:"error,omitempty"`
+	// Cached is set when the output was served from the result cache.
+	Cached bool `json:"cached,omitempty"`
+	// Mock is set when the tool's mock responder answered it.
+	Mock bool `json:"mock,omitempty"`
+	// Endpoint is the endpoint that served the invocation, for tools with
+	// several.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Attempts is how many times the invocation was tried, more than once
+	// for tools with a retry policy.
+	Attempts int `json:"attempts,omitempty"`
+	// ProviderError is what the provider said about the failure of a
+	// failed invocation, when it said anything.
+	ProviderError *ProviderError `json:"provider_error,omitempty"`
+	// Metadata is what the consumer attached to the invocation.
+	Metadata Metadata `json:"metadata,omitempty"`
 }
 
+// Parties a stored invocation payload can be keyed to.
+const (
+	PayloadConsumer = "consumer"
+	PayloadProvider = "provider"
+)
+
 // InvokeRequest is the input for invoking a tool.
 type InvokeRequest struct {
-	ToolID         string         `json:"tool_id"`
-	Input          map[string]any `json:"input"`
-	BudgetCLAW     string         `json:"budget_claw,omitempty"`
-	IdempotencyKey string         `json:"idempotency_key,omitempty"`
-	ConsumerID     string         `json:"-"` // set from auth context
+	ToolID string `json:"tool_id"`
+	// Capability, in place of ToolID, has the registry pick among the tools
+	// of that name by Strategy (its default one when empty).
+	Capability string         `json:"capability,omitempty"`
+	Strategy   string         `json:"strategy,omitempty"`
+	Input      map[string]any `json:"input"`
+	BudgetCLAW string         `json:"budget_claw,omitempty"`
+	// IdempotencyKey makes retries of the invocation within
+	// IdempotencyWindow get its result instead of invoking the tool again.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// Metadata is stored with the invocation and returned with it, for
+	// correlating invocations across agents.
+	Metadata   Metadata `json:"metadata,omitempty"`
+	ConsumerID string   `json:"-"` // set from auth context
+	// StorePayload keeps the input and output, encrypted and keyed to
+	// PayloadConsumer or PayloadProvider, so the invocation can be replayed
+	// and disputed. Empty keeps only their hashes.
+	StorePayload string `json:"store_payload,omitempty"`
+	// DryRun checks the invocation, its cost and the caller's quota and
+	// budget without calling the provider, recording or charging anything.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Mock has the tool's mock responder answer instead of its provider,
+	// free of charge and outside the caller's quota.
+	Mock bool `json:"mock,omitempty"`
+	// Priority, low, normal (the default) or high, orders async invocations
+	// in the queue and bounds the workers they may occupy.
+	Priority string `json:"priority,omitempty"`
+	// CallbackURL, for async invocations, is POSTed the outcome once the
+	// invocation finishes, signed with CallbackSecret.
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"callback_secret,omitempty"`
+	// ConsumerSignature is the consumer's signature of the call, passed on
+	// to the provider; see VerifyConsumerSignature.
+	ConsumerSignature *receipts.RequestSignature `json:"consumer_signature,omitempty"`
 }
 
 // InvokeResponse is returned from a tool invocation.
@@ -165,17 +396,17 @@ type InvokeResponse struct {
 	Receipt      *Receipt       `json:"receipt,omitempty"`
 	CostCLAW     string         `json:"cost_claw,omitempty"`
 	DurationMS   int64          `json:"duration_ms"`
+	Cached       bool           `json:"cached,omitempty"`
+	// CacheHit repeats Cached under the name newer clients read.
+	CacheHit bool `json:"cache_hit,omitempty"`
+	// Replayed is set when the response is that of an earlier invocation
+	// with the same idempotency key.
+	Replayed bool `json:"replayed,omitempty"`
+	// Mock is set when the tool's mock responder answered.
+	Mock     bool     `json:"mock,omitempty"`
+	Metadata Metadata `json:"metadata,omitempty"`
 }
 
-// Receipt is a cryptographically signed proof of tool execution.
-type Receipt struct {
-	ID          string    `json:"id"`
-	ToolID      string    `json:"tool_id"`
-	ConsumerID  string    `json:"consumer_id"`
-	ProviderID  string    `json:"provider_id"`
-	InputHash   string    `json:"input_hash"`
-	OutputHash  string    `json:"output_hash"`
-	CostCLAW    string    `json:"cost_claw,omitempty"`
-	ExecutedAt  time.Time `json:"executed_at"`
-	ProviderSig string    `json:"provider_sig"`
-}
+// Receipt is a signed proof of tool execution. The registry keeps the
+// receipt of every completed invocation; package receipts verifies them.
+type Receipt = receipts.Receipt
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dayLayout is the format of usage_daily.day (UTC calendar date).
+const dayLayout = "2006-01-02"
+
+// DailyUsage is one aggregated row of per-tool usage for a UTC day.
+type DailyUsage struct {
+	Day          string `json:"day"`
+	ToolID       string `json:"tool_id"`
+	ConsumerID   string `json:"consumer_id,omitempty"`
+	SpendCLAW    string `json:"spend_claw"`
+	Calls        int64  `json:"calls"`
+	Errors       int64  `json:"errors"`
+	AvgLatencyMS int64  `json:"avg_latency_ms"`
+	MaxLatencyMS int64  `json:"max_latency_ms"`
+}
+
+// RollupUsage recomputes the usage_daily rows for the UTC day containing t
+// from the raw invocations table. It is idempotent and safe to re-run.
+func (r *Registry) RollupUsage(ctx context.Context, t time.Time) error {
+	u := t.UTC()
+	start := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO usage_daily (day, tool_id, consumer_id, calls, errors, total_latency_ms, max_latency_ms, spend_claw)
+		SELECT ?, tool_id, consumer_id,
+		       COUNT(*),
+		       SUM(CASE WHEN status NOT IN ('queued', 'pending', 'completed') THEN 1 ELSE 0 END),
+		       COALESCE(SUM((completed_at - started_at) * 1000), 0),
+		       COALESCE(MAX((completed_at - started_at) * 1000), 0),
+		       COALESCE(SUM(CASE WHEN status = 'completed' THEN CAST(cost_claw AS REAL) ELSE 0 END), 0)
+		FROM invocations
+		WHERE started_at >= ? AND started_at < ?
+		GROUP BY tool_id, consumer_id
+		ON CONFLICT(day, tool_id, consumer_id) DO UPDATE SET
+			calls = excluded.calls,
+			errors = excluded.errors,
+			total_latency_ms = excluded.total_latency_ms,
+			max_latency_ms = excluded.max_latency_ms,
+			spend_claw = excluded.spend_claw
+	`, start.Format(dayLayout), start.Unix(), end.Unix())
+	if err != nil {
+		return fmt.Errorf("rollup usage: %w", err)
+	}
+	return nil
+}
+
+// RollupRecentUsage rolls up today and yesterday, so invocations that
+// complete shortly after midnight are still attributed correctly. It is
+// intended to be run periodically by the serve command.
+func (r *Registry) RollupRecentUsage(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, day := range []time.Time{now.Add(-24 * time.Hour), now} {
+		if err := r.RollupUsage(ctx, day); err != nil {
+			return err
+		}
+	}
+	r.log.Debug("usage rollup complete", zap.Time("at", now))
+	return nil
+}
+
+// ToolUsage returns daily usage for a tool between from and to (inclusive
+// UTC days), summed across consumers.
+func (r *Registry) ToolUsage(ctx context.Context, toolID string, from, to time.Time) ([]*DailyUsage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT day, tool_id, SUM(calls), SUM(errors), SUM(total_latency_ms), MAX(max_latency_ms), SUM(spend_claw)
+		FROM usage_daily
+		WHERE tool_id = ? AND day >= ? AND day <= ?
+		  AND tool_id IN (SELECT id FROM tools WHERE namespace = ?)
+		GROUP BY day, tool_id
+		ORDER BY day
+	`, toolID, from.UTC().Format(dayLayout), to.UTC().Format(dayLayout), NamespaceFrom(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("tool usage: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []*DailyUsage
+	for rows.Next() {
+		var (
+			u            DailyUsage
+			totalLatency int64
+			spend        float64
+		)
+		if err := rows.Scan(&u.Day, &u.ToolID, &u.Calls, &u.Errors, &totalLatency, &u.MaxLatencyMS, &spend); err != nil {
+			return nil, err
+		}
+		if u.Calls > 0 {
+			u.AvgLatencyMS = totalLatency / u.Calls
+		}
+		u.SpendCLAW = strconv.FormatFloat(spend, 'f', -1, 64)
+		out = append(out, &u)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,55 @@
+package registry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupUsage(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	tool, err := r.RegisterTool(ctx, validRegisterReq())
+	require.NoError(t, err)
+
+	inv1, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", map[string]any{"n": 1})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteInvocation(ctx, inv1, "sha256:out", "sig", "5.0"))
+	inv2, err := r.RecordInvocation(ctx, tool.ID, "consumer-a", map[string]any{"n": 2})
+	require.NoError(t, err)
+	require.NoError(t, r.FailInvocation(ctx, inv2, "boom"))
+	inv3, err := r.RecordInvocation(ctx, tool.ID, "consumer-b", map[string]any{"n": 3})
+	require.NoError(t, err)
+	require.NoError(t, r.CompleteInvocation(ctx, inv3, "sha256:out", "sig", "2.5"))
+
+	require.NoError(t, r.RollupRecentUsage(ctx))
+	// Re-running must not double count.
+	require.NoError(t, r.RollupRecentUsage(ctx))
+
+	now := time.Now()
+	usage, err := r.ToolUsage(ctx, tool.ID, now.AddDate(0, 0, -1), now)
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, now.UTC().Format("2006-01-02"), usage[0].Day)
+	assert.Equal(t, int64(3), usage[0].Calls)
+	assert.Equal(t, int64(1), usage[0].Errors)
+	assert.Equal(t, "7.5", usage[0].SpendCLAW)
+}
+
+func TestToolUsage_Empty(t *testing.T) {
+	r := newTestRegistry(t)
+	usage, err := r.ToolUsage(context.Background(), "did:claw:tool:none", time.Now(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, usage)
+}
+
+func TestRollupUsage_BrokenDB(t *testing.T) {
+	r := newBrokenRegistry(t)
+	assert.Error(t, r.RollupRecentUsage(context.Background()))
+	_, err := r.ToolUsage(context.Background(), "x", time.Now(), time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/events"
+)
+
+// DefaultWarnThreshold is the share of a limit whose consumption is warned
+// about by a Registry created without WithWarnThreshold.
+const DefaultWarnThreshold = 0.8
+
+// Kinds of limit a LimitWarning is about.
+const (
+	LimitToolQuota = "tool_quota"
+	LimitBudget    = "budget"
+)
+
+// WithWarnThreshold sets the share of a tool quota or an invocation budget,
+// between 0 and 1, a consumer may use before it is warned that the limit is
+// near. 0 disables the warnings.
+func WithWarnThreshold(share float64) Option {
+	return func(r *Registry) { r.warnAt = share }
+}
+
+// LimitWarning tells a consumer that it used the threshold share of a limit
+// and will soon be refused with 429 TOOL_QUOTA_EXCEEDED or 402
+// BUDGET_EXCEEDED. It is the data of an events.QuotaWarning event.
+type LimitWarning struct {
+	Kind       string  `json:"kind"`
+	ToolID     string  `json:"tool_id"`
+	ConsumerID string  `json:"consumer_id"`
+	Used       float64 `json:"used"`
+	Limit      float64 `json:"limit"`
+	// InvocationID is the invocation that spent most of its budget.
+	InvocationID string `json:"invocation_id,omitempty"`
+	// ResetAt is when the window of a tool quota ends.
+	ResetAt *time.Time `json:"reset_at,omitempty"`
+}
+
+// crossesWarning reports whether using used of limit, up from before, is
+// the step that reaches the warning threshold.
+func (r *Registry) crossesWarning(before, used, limit float64) bool {
+	if r.warnAt <= 0 || limit <= 0 {
+		return false
+	}
+	at := r.warnAt * limit
+	return before < at && used >= at
+}
+
+// warnQuota warns the consumer of tool whose call, the used-th of the
+// current window, reached the threshold share of the tool's quota.
+func (r *Registry) warnQuota(ctx context.Context, tool *Tool, consumerID string, used int64, reset time.Time) {
+	limit := float64(tool.Quota.Calls)
+	if !r.crossesWarning(float64(used-1), float64(used), limit) {
+		return
+	}
+	r.publish(ctx, events.QuotaWarning, tool.ID, consumerID, &LimitWarning{
+		Kind: LimitToolQuota, ToolID: tool.ID, ConsumerID: consumerID, Used: float64(used), Limit: limit, ResetAt: &reset,
+	})
+}
+
+// WarnBudget warns consumerID when invocation id of tool cost at least the
+// threshold share of its budget, for agents to raise the budget of the
+// next calls before they are refused. Malformed or empty budgets and costs
+// are ignored.
+func (r *Registry) WarnBudget(ctx context.Context, tool *Tool, consumerID, id, cost, budget string) {
+	c, err1 := strconv.ParseFloat(cost, 64)
+	b, err2 := strconv.ParseFloat(budget, 64)
+	if err1 != nil || err2 != nil || !r.crossesWarning(math.Inf(-1), c, b) {
+		return
+	}
+	r.publish(ctx, events.QuotaWarning, tool.ID, consumerID, &LimitWarning{
+		Kind: LimitBudget, ToolID: tool.ID, ConsumerID: consumerID, InvocationID: id, Used: c, Limit: b,
+	})
+}
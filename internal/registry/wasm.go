@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WASMScheme prefixes the endpoint of a tool that the registry executes
+// itself: "wasm://sha256:<hex>" names an uploaded module by digest.
+const WASMScheme = "wasm://"
+
+// ErrModuleNotFound is returned for a wasm:// endpoint whose module has not
+// been uploaded.
+var ErrModuleNotFound = errors.New("wasm module not found")
+
+// Module is an uploaded WebAssembly module. The bytes themselves are only
+// returned to the executor.
+type Module struct {
+	Digest     string    `json:"digest"`
+	Endpoint   string    `json:"endpoint"` // use as the tool's endpoint
+	ProviderID string    `json:"provider_id"`
+	Size       int       `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ModuleDigest returns the module digest of a wasm:// endpoint.
+func ModuleDigest(endpoint string) (string, bool) {
+	digest, ok := strings.CutPrefix(endpoint, WASMScheme)
+	return digest, ok && digest != ""
+}
+
+// PutModule stores a module uploaded by providerID and returns it. Modules
+// are content-addressed: uploading the same bytes again returns the existing
+// module. The caller validates the bytes with the sandbox executor first.
+func (r *Registry) PutModule(ctx context.Context, providerID string, wasm []byte) (*Module, error) {
+	sum := sha256.Sum256(wasm)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO wasm_modules (digest, provider_id, size, module, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(digest) DO NOTHING
+	`, digest, providerID, len(wasm), wasm, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("insert module: %w", err)
+	}
+	m, err := r.moduleInfo(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	r.logger(ctx).Info("wasm module stored",
+		zap.String("digest", digest), zap.String("provider", providerID), zap.Int("size", len(wasm)))
+	return m, nil
+}
+
+func (r *Registry) moduleInfo(ctx context.Context, digest string) (*Module, error) {
+	var (
+		m         Module
+		createdAt int64
+	)
+	err := r.db.QueryRowContext(ctx,
+		"SELECT digest, provider_id, size, created_at FROM wasm_modules WHERE digest = ?", digest,
+	).Scan(&m.Digest, &m.ProviderID, &m.Size, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrModuleNotFound, digest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get module: %w", err)
+	}
+	m.Endpoint = WASMScheme + m.Digest
+	m.CreatedAt = time.Unix(createdAt, 0)
+	return &m, nil
+}
+
+// ModuleBytes returns the bytes of the module with the given digest. It is
+// the sandbox executor's loader.
+func (r *Registry) ModuleBytes(ctx context.Context, digest string) ([]byte, error) {
+	var wasm []byte
+	err := r.db.QueryRowContext(ctx, "SELECT module FROM wasm_modules WHERE digest = ?", digest).Scan(&wasm)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrModuleNotFound, digest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get module: %w", err)
+	}
+	return wasm, nil
+}
+
+// checkModule verifies that a wasm:// endpoint names an uploaded module.
+// Other endpoints pass.
+func (r *Registry) checkModule(ctx context.Context, endpoint string) error {
+	digest, ok := strings.CutPrefix(endpoint, WASMScheme)
+	if !ok {
+		return nil
+	}
+	_, err := r.moduleInfo(ctx, digest)
+	return err
+}
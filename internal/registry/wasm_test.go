@@ -0,0 +1,59 @@
+package registry_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutModule_ContentAddressed(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+	wasm, err := os.ReadFile("../sandbox/testdata/echo.wasm")
+	require.NoError(t, err)
+
+	m, err := r.PutModule(ctx, "did:claw:agent:test-provider", wasm)
+	require.NoError(t, err)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, m.Digest)
+	assert.Equal(t, registry.WASMScheme+m.Digest, m.Endpoint)
+	assert.Equal(t, len(wasm), m.Size)
+
+	again, err := r.PutModule(ctx, "did:claw:agent:other", wasm)
+	require.NoError(t, err)
+	assert.Equal(t, m.Digest, again.Digest)
+	assert.Equal(t, "did:claw:agent:test-provider", again.ProviderID, "the first uploader is kept")
+
+	got, err := r.ModuleBytes(ctx, m.Digest)
+	require.NoError(t, err)
+	assert.Equal(t, wasm, got)
+
+	digest, ok := registry.ModuleDigest(m.Endpoint)
+	assert.True(t, ok)
+	assert.Equal(t, m.Digest, digest)
+	_, ok = registry.ModuleDigest("https://example.com")
+	assert.False(t, ok)
+}
+
+func TestRegisterTool_WASMEndpoint(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	req := validRegisterReq()
+	req.Endpoint = registry.WASMScheme + "sha256:missing"
+	_, err := r.RegisterTool(ctx, req)
+	assert.ErrorIs(t, err, registry.ErrModuleNotFound)
+
+	wasm, err := os.ReadFile("../sandbox/testdata/echo.wasm")
+	require.NoError(t, err)
+	m, err := r.PutModule(ctx, req.ProviderID, wasm)
+	require.NoError(t, err)
+	req = validRegisterReq()
+	req.Endpoint = m.Endpoint
+	tool, err := r.RegisterTool(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, m.Endpoint, tool.Endpoint)
+}
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without calling the endpoint, for a call to
+// an endpoint whose circuit is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Circuit states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// BreakerConfig holds the thresholds of a Breaker.
+type BreakerConfig struct {
+	// Failures is how many consecutive failures or timeouts of an endpoint
+	// open its circuit.
+	Failures int
+	// Cooldown is how long an open circuit fails calls fast before one is
+	// let through to probe the endpoint.
+	Cooldown time.Duration
+}
+
+// DefaultBreakerConfig is used by the serve command unless overridden by
+// flags.
+var DefaultBreakerConfig = BreakerConfig{Failures: 5, Cooldown: 30 * time.Second}
+
+// Breaker tracks the consecutive failures of provider endpoints and opens
+// the circuit of one that keeps failing, so calls to a dead endpoint fail
+// fast instead of each waiting out the tool's timeout. After the cooldown
+// the circuit is half open: one call is let through, and closes it by
+// succeeding or opens it again by failing. State is kept in memory per
+// replica, and only for endpoints that failed since they last succeeded.
+type Breaker struct {
+	cfg BreakerConfig
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns a breaker with the thresholds of cfg. cfg.Failures
+// must be positive.
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	return &Breaker{cfg: cfg, circuits: map[string]*circuit{}}
+}
+
+// Circuit is the state of the circuit of an endpoint.
+type Circuit struct {
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	// Failures is the number of consecutive failed calls.
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	// RetryAt is when an open circuit lets a call through again.
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// state returns the state of c at now.
+func (b *Breaker) state(c *circuit, now time.Time) string {
+	switch {
+	case c.failures < b.cfg.Failures:
+		return CircuitClosed
+	case now.Before(c.openedAt.Add(b.cfg.Cooldown)):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// allow returns ErrCircuitOpen when a call to url must fail fast: its
+// circuit is open, or half open with a probe already in flight. A nil
+// Breaker allows every call.
+func (b *Breaker) allow(url string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[url]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	switch b.state(c, now) {
+	case CircuitOpen:
+	case CircuitHalfOpen:
+		if !c.probing {
+			c.probing = true
+			return nil
+		}
+	default:
+		return nil
+	}
+	retry := c.openedAt.Add(b.cfg.Cooldown).Sub(now).Round(time.Second)
+	return fmt.Errorf("%w: %s failed %d times in a row, retrying in %s", ErrCircuitOpen, url, c.failures, max(retry, 0))
+}
+
+// record counts the outcome of a call to url. Calls the endpoint failed, as
+// by failover, count against it; any other outcome closes its circuit,
+// except calls cancelled by the caller, which do not count.
+func (b *Breaker) record(url string, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case errors.Is(err, context.Canceled):
+		if c, ok := b.circuits[url]; ok {
+			c.probing = false
+		}
+	case err != nil && failover(err):
+		c, ok := b.circuits[url]
+		if !ok {
+			c = &circuit{}
+			b.circuits[url] = c
+		}
+		c.failures++
+		c.probing = false
+		if c.failures >= b.cfg.Failures {
+			c.openedAt = time.Now()
+		}
+	default:
+		delete(b.circuits, url)
+	}
+}
+
+// Open reports whether calls to url fail fast, its circuit being open.
+func (b *Breaker) Open(url string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[url]
+	return ok && b.state(c, time.Now()) == CircuitOpen
+}
+
+// Circuits returns the circuits of the endpoints that failed since they
+// last succeeded, by endpoint.
+func (b *Breaker) Circuits() []Circuit {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	out := make([]Circuit, 0, len(b.circuits))
+	for url, c := range b.circuits {
+		cs := Circuit{Endpoint: url, State: b.state(c, now), Failures: c.failures}
+		if cs.State != CircuitClosed {
+			opened, retry := c.openedAt.UTC(), c.openedAt.Add(b.cfg.Cooldown).UTC()
+			cs.OpenedAt, cs.RetryAt = &opened, &retry
+		}
+		out = append(out, cs)
+	}
+	slices.SortFunc(out, func(a, b Circuit) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return out
+}
@@ -0,0 +1,93 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	var (
+		healthy atomic.Bool
+		calls   atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch {
+		case r.URL.Path == "/refuse":
+			http.Error(w, "bad input", http.StatusBadRequest)
+		case healthy.Load():
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	b := router.NewBreaker(router.BreakerConfig{Failures: 2, Cooldown: 50 * time.Millisecond})
+	c := router.NewClient(srv.Client(), b)
+	invoke := func(url string) error {
+		_, err := c.Invoke(context.Background(), url, nil, json.RawMessage(`{}`))
+		return err
+	}
+
+	assert.ErrorIs(t, invoke(srv.URL), router.ErrUpstream)
+	require.Len(t, b.Circuits(), 1)
+	assert.Equal(t, router.CircuitClosed, b.Circuits()[0].State)
+	assert.ErrorIs(t, invoke(srv.URL), router.ErrUpstream)
+	assert.True(t, b.Open(srv.URL))
+
+	calls.Store(0)
+	err := invoke(srv.URL)
+	assert.ErrorIs(t, err, router.ErrCircuitOpen)
+	assert.ErrorContains(t, err, "failed 2 times in a row")
+	assert.Zero(t, calls.Load(), "an open circuit fails fast")
+	circuits := b.Circuits()
+	require.Len(t, circuits, 1)
+	assert.Equal(t, srv.URL, circuits[0].Endpoint)
+	assert.Equal(t, router.CircuitOpen, circuits[0].State)
+	assert.Equal(t, 2, circuits[0].Failures)
+	require.NotNil(t, circuits[0].RetryAt)
+	assert.Equal(t, 50*time.Millisecond, circuits[0].RetryAt.Sub(*circuits[0].OpenedAt))
+
+	assert.ErrorIs(t, invoke(srv.URL+"/refuse"), router.ErrUpstream)
+	assert.ErrorIs(t, invoke(srv.URL+"/refuse"), router.ErrUpstream)
+	assert.False(t, b.Open(srv.URL+"/refuse"), "refused inputs do not count against the endpoint")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, router.CircuitHalfOpen, b.Circuits()[0].State)
+	assert.ErrorIs(t, invoke(srv.URL), router.ErrUpstream, "a half-open circuit lets a probe through")
+	assert.True(t, b.Open(srv.URL), "a failed probe opens the circuit again")
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, invoke(srv.URL))
+	assert.Empty(t, b.Circuits(), "a successful call closes the circuit")
+}
+
+func TestBreaker_InvokeAny(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			_, _ = w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	b := router.NewBreaker(router.BreakerConfig{Failures: 1, Cooldown: time.Minute})
+	c := router.NewClient(srv.Client(), b)
+
+	_, err := c.Invoke(context.Background(), srv.URL+"/dead", nil, json.RawMessage(`{}`))
+	require.ErrorIs(t, err, router.ErrUpstream)
+	out, url, err := c.InvokeAny(context.Background(), []string{srv.URL + "/dead", srv.URL + "/ok"}, nil, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ok":true}`, string(out))
+	assert.Equal(t, srv.URL+"/ok", url, "endpoints with an open circuit are failed over")
+}
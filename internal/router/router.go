@@ -0,0 +1,299 @@
+// Package router forwards invocations to tools served over plain HTTP: the
+// input is posted to the tool's endpoint as a JSON object and the response
+// body, or the last event of a Server-Sent Events stream, is the output.
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrUpstream is returned when the endpoint answers with a non-2xx status,
+// as a *StatusError, or with a response that is too large.
+var ErrUpstream = errors.New("provider error")
+
+// StatusError is a non-2xx answer from an endpoint. It matches ErrUpstream.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// Detail is the start of the response body.
+	Detail string
+	// Code, Message and Retryable are what the endpoint said about the
+	// failure in a JSON body, {"error": {"code", "message", "retryable"}}
+	// or the same fields at the top level. Retryable is nil when it did
+	// not say.
+	Code      string
+	Message   string
+	Retryable *bool
+}
+
+// Transient reports whether the call may succeed if tried again: what the
+// endpoint said, or otherwise whether it answered with a 5xx status or 429.
+func (e *StatusError) Transient() bool {
+	if e.Retryable != nil {
+		return *e.Retryable
+	}
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *StatusError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%v: %s returned %s", ErrUpstream, e.URL, e.Status)
+	}
+	return fmt.Sprintf("%v: %s returned %s: %s", ErrUpstream, e.URL, e.Status, e.Detail)
+}
+
+func (e *StatusError) Unwrap() error { return ErrUpstream }
+
+const (
+	// maxResponse bounds the response body of one invocation.
+	maxResponse = 16 << 20
+	// maxDetail bounds how much of an error body is quoted in the error.
+	maxDetail = 512
+)
+
+// AcceptStream is the Accept header of calls to tools that stream: they may
+// answer with a text/event-stream instead of a JSON body.
+const AcceptStream = "text/event-stream, application/json"
+
+// Routable reports whether endpoint is an http:// or https:// URL the router
+// can post to.
+func Routable(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
+}
+
+// Client posts invocations to provider endpoints.
+type Client struct {
+	hc      *http.Client
+	breaker *Breaker
+}
+
+// NewClient returns a client that sends requests with hc and, unless b is
+// nil, fails calls to endpoints whose circuit b opened fast.
+func NewClient(hc *http.Client, b *Breaker) *Client {
+	return &Client{hc: hc, breaker: b}
+}
+
+// Invoke posts input to url and returns the response body. header is added
+// to the request, e.g. to carry the provider's credentials, or an Accept of
+// AcceptStream for a tool that streams; an event stream answer is read to
+// its end and its last event returned. The deadline of ctx bounds the whole
+// exchange. A call to an endpoint whose circuit is open fails with
+// ErrCircuitOpen.
+func (c *Client) Invoke(ctx context.Context, url string, header http.Header, input json.RawMessage) (json.RawMessage, error) {
+	if err := c.breaker.allow(url); err != nil {
+		return nil, err
+	}
+	out, err := c.invoke(ctx, url, header, input)
+	c.breaker.record(url, err)
+	return out, err
+}
+
+func (c *Client) invoke(ctx context.Context, url string, header http.Header, input json.RawMessage) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == "text/event-stream" && resp.StatusCode/100 == 2 {
+		out, err := lastEvent(resp.Body)
+		if err == nil {
+			captureHeader(ctx, resp)
+		}
+		return out, err
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		detail := strings.TrimSpace(string(raw[:min(len(raw), maxDetail)]))
+		se := &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status, Detail: detail}
+		se.parseBody(raw)
+		return nil, se
+	}
+	if len(raw) > maxResponse {
+		return nil, fmt.Errorf("%w: response exceeds %d bytes", ErrUpstream, maxResponse)
+	}
+	captureHeader(ctx, resp)
+	return raw, nil
+}
+
+type headerKey struct{}
+
+// WithResponseHeader returns a context in which the header and trailer of
+// the answer an invocation succeeds with are copied into the returned
+// header, for the caller to read what the provider said about the output,
+// such as its signature. Streams can only sign their output in a trailer.
+func WithResponseHeader(ctx context.Context) (context.Context, http.Header) {
+	header := http.Header{}
+	return context.WithValue(ctx, headerKey{}, header), header
+}
+
+// captureHeader copies the header and trailer of resp, whose body was read,
+// into the header of ctx, if any.
+func captureHeader(ctx context.Context, resp *http.Response) {
+	header, ok := ctx.Value(headerKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	for k, v := range resp.Header {
+		header[k] = v
+	}
+	for k, v := range resp.Trailer {
+		header[k] = v
+	}
+}
+
+// lastEvent reads a Server-Sent Events stream to its end and returns the
+// data of its last event.
+func lastEvent(body io.Reader) (json.RawMessage, error) {
+	var (
+		last, data []byte
+		inEvent    bool
+		read       int
+	)
+	sc := bufio.NewScanner(body)
+	sc.Buffer(nil, maxResponse)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if read += len(line) + 1; read > maxResponse {
+			return nil, fmt.Errorf("%w: response exceeds %d bytes", ErrUpstream, maxResponse)
+		}
+		value, isData := bytes.CutPrefix(line, []byte("data:"))
+		switch {
+		case len(line) == 0 && inEvent:
+			last, data, inEvent = data, nil, false
+		case isData:
+			if inEvent {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(value, []byte(" "))...)
+			inEvent = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if inEvent {
+		last = data
+	}
+	if len(last) == 0 {
+		return nil, fmt.Errorf("%w: event stream carried no output", ErrUpstream)
+	}
+	return last, nil
+}
+
+// errorBody is the JSON error a provider may answer a failed call with.
+type errorBody struct {
+	Code      json.RawMessage `json:"code"`
+	Message   string          `json:"message"`
+	Retryable *bool           `json:"retryable"`
+}
+
+// parseBody fills in the code, message and retryable flag of e from raw, a
+// JSON error body. Other bodies are only quoted in Detail.
+func (e *StatusError) parseBody(raw []byte) {
+	var body struct {
+		errorBody
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) != nil {
+		return
+	}
+	eb := body.errorBody
+	var nested errorBody
+	if json.Unmarshal(body.Error, &nested) == nil {
+		eb = nested
+	} else if json.Unmarshal(body.Error, &eb.Message) != nil {
+		eb.Message = body.Message
+	}
+	// Codes may be strings or numbers.
+	var code string
+	if json.Unmarshal(eb.Code, &code) != nil {
+		code = string(eb.Code)
+	}
+	e.Code, e.Message, e.Retryable = code, truncate(eb.Message, maxDetail), eb.Retryable
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// InvokeAny invokes the first of urls that serves the call, in order. It
+// fails over to the next one when an endpoint cannot be reached, answers
+// with a 5xx status or 429 or has its circuit open, and returns the output and the URL that
+// produced it, or the last error and the URL that returned it. The deadline
+// of ctx bounds all attempts together.
+func (c *Client) InvokeAny(
+	ctx context.Context, urls []string, header http.Header, input json.RawMessage,
+) (json.RawMessage, string, error) {
+	var (
+		out json.RawMessage
+		url string
+		err error
+	)
+	for _, url = range urls {
+		out, err = c.Invoke(ctx, url, header, input)
+		if err == nil || ctx.Err() != nil || !failover(err) {
+			break
+		}
+	}
+	return out, url, err
+}
+
+// failover reports whether a call that failed with err may succeed at
+// another endpoint: the endpoint was unreachable or is failing, rather than
+// refusing this input.
+func failover(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, ErrUpstream)
+}
+
+// Balancer spreads the invocations of a tool over its endpoints in turn.
+// The zero value is ready to use.
+type Balancer struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// Order returns urls rotated so that successive calls with the same key
+// start at successive URLs, the rest following as fallbacks.
+func (b *Balancer) Order(key string, urls []string) []string {
+	b.mu.Lock()
+	if b.next == nil {
+		b.next = map[string]int{}
+	}
+	i := b.next[key] % len(urls)
+	b.next[key] = i + 1
+	b.mu.Unlock()
+	return append(slices.Clone(urls[i:]), urls[:i]...)
+}
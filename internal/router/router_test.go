@@ -0,0 +1,202 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutable(t *testing.T) {
+	assert.True(t, router.Routable("https://tools.example.com/lint"))
+	assert.True(t, router.Routable("http://10.0.0.1:8080"))
+	for _, e := range []string{"grpc://localhost:50051", "jsonrpc+https://x/rpc#m", "push://c", "wasm://sha256:00"} {
+		assert.False(t, router.Routable(e), e)
+	}
+}
+
+func TestInvoke_EventStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != router.AcceptStream {
+			_, _ = w.Write([]byte(`{"done":false}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		switch r.URL.Path {
+		case "/empty":
+			_, _ = w.Write([]byte(": keep-alive\n\n"))
+		default:
+			_, _ = w.Write([]byte("event: progress\ndata: {\"done\":false}\n\n: comment\ndata: {\"done\":\ndata: true}\n"))
+		}
+	}))
+	defer srv.Close()
+
+	c := router.NewClient(srv.Client(), nil)
+	ctx := context.Background()
+	out, err := c.Invoke(ctx, srv.URL, http.Header{"Accept": {router.AcceptStream}}, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"done":true}`, string(out), "the last event, unterminated, is the output")
+
+	out, err = c.Invoke(ctx, srv.URL, nil, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"done":false}`, string(out), "tools that do not stream are asked for JSON")
+
+	_, err = c.Invoke(ctx, srv.URL+"/empty", http.Header{"Accept": {router.AcceptStream}}, json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, router.ErrUpstream)
+}
+
+func TestInvoke(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Key"))
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/echo":
+			_, _ = w.Write(body)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.Error(w, "no such tool", http.StatusTeapot)
+		}
+	}))
+	defer srv.Close()
+
+	c := router.NewClient(srv.Client(), nil)
+	header := http.Header{"X-Key": {"secret"}}
+	ctx := context.Background()
+
+	out, err := c.Invoke(ctx, srv.URL+"/echo", header, json.RawMessage(`{"n":1}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":1}`, string(out))
+
+	_, err = c.Invoke(ctx, srv.URL+"/missing", header, json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, router.ErrUpstream)
+	assert.ErrorContains(t, err, "418")
+	assert.ErrorContains(t, err, "no such tool")
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Invoke(ctx, srv.URL+"/slow", header, json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithResponseHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			w.Header().Set("Trailer", "X-Signature")
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {}\n\n"))
+			w.Header().Set("X-Signature", "stream-sig")
+		case "/fail":
+			w.Header().Set("X-Signature", "failed-sig")
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("X-Signature", "sig")
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := router.NewClient(srv.Client(), nil)
+	ctx, header := router.WithResponseHeader(context.Background())
+	_, err := c.Invoke(ctx, srv.URL+"/fail", nil, json.RawMessage(`{}`))
+	require.Error(t, err)
+	assert.Empty(t, header.Get("X-Signature"), "failed answers are not captured")
+
+	_, err = c.Invoke(ctx, srv.URL, nil, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sig", header.Get("X-Signature"))
+
+	ctx, header = router.WithResponseHeader(context.Background())
+	_, err = c.Invoke(ctx, srv.URL+"/stream", http.Header{"Accept": {router.AcceptStream}}, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "stream-sig", header.Get("X-Signature"), "streams sign in a trailer")
+}
+
+func TestInvoke_ErrorBody(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	c := router.NewClient(srv.Client(), nil)
+	statusError := func(b string) *router.StatusError {
+		body = b
+		_, err := c.Invoke(context.Background(), srv.URL, nil, json.RawMessage(`{}`))
+		var se *router.StatusError
+		require.ErrorAs(t, err, &se)
+		return se
+	}
+
+	se := statusError(`{"error": {"code": "UPSTREAM_DOWN", "message": "exchange offline", "retryable": false}}`)
+	assert.Equal(t, "UPSTREAM_DOWN", se.Code)
+	assert.Equal(t, "exchange offline", se.Message)
+	assert.False(t, se.Transient(), "the provider's word wins over the status")
+
+	se = statusError(`{"code": 42, "message": "busy"}`)
+	assert.Equal(t, "42", se.Code)
+	assert.Equal(t, "busy", se.Message)
+	assert.Nil(t, se.Retryable)
+	assert.True(t, se.Transient(), "503")
+
+	se = statusError(`{"error": "overloaded"}`)
+	assert.Equal(t, "overloaded", se.Message)
+	se = statusError("<html>bad gateway</html>")
+	assert.Empty(t, se.Code)
+	assert.Empty(t, se.Message)
+	assert.Equal(t, "<html>bad gateway</html>", se.Detail)
+}
+
+func TestInvokeAny(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/busy":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "bad input", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	c := router.NewClient(srv.Client(), nil)
+	ctx := context.Background()
+
+	out, url, err := c.InvokeAny(ctx, []string{down.URL, srv.URL + "/busy", srv.URL + "/ok"}, nil, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ok":true}`, string(out))
+	assert.Equal(t, srv.URL+"/ok", url, "unreachable and failing endpoints are skipped")
+
+	_, url, err = c.InvokeAny(ctx, []string{srv.URL + "/bad", srv.URL + "/ok"}, nil, json.RawMessage(`{}`))
+	var se *router.StatusError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, http.StatusBadRequest, se.StatusCode)
+	assert.Equal(t, srv.URL+"/bad", url, "a refused input is not retried elsewhere")
+
+	_, url, err = c.InvokeAny(ctx, []string{srv.URL + "/busy", down.URL}, nil, json.RawMessage(`{}`))
+	assert.Error(t, err)
+	assert.Equal(t, down.URL, url)
+}
+
+func TestBalancer(t *testing.T) {
+	var b router.Balancer
+	urls := []string{"a", "b", "c"}
+	assert.Equal(t, []string{"a", "b", "c"}, b.Order("t1", urls))
+	assert.Equal(t, []string{"b", "c", "a"}, b.Order("t1", urls))
+	assert.Equal(t, []string{"a", "b", "c"}, b.Order("t2", urls), "each key turns on its own")
+	assert.Equal(t, []string{"c", "a", "b"}, b.Order("t1", urls))
+	assert.Equal(t, []string{"a", "b", "c"}, b.Order("t1", urls))
+	assert.Equal(t, []string{"a", "b", "c"}, urls)
+}
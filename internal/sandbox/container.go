@@ -0,0 +1,171 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// ContainerLimits bound every container invocation.
+type ContainerLimits struct {
+	CPUs        float64
+	MemoryBytes int64
+	PIDs        int
+	// Timeout is the execution budget, including container start. A tool's
+	// own timeout_ms applies when it is shorter.
+	Timeout        time.Duration
+	MaxOutputBytes int
+	// Network gives containers the runtime's default network. Off, they
+	// have none at all.
+	Network bool
+}
+
+// DefaultContainerLimits are used by NewContainers for zero fields.
+var DefaultContainerLimits = ContainerLimits{
+	CPUs:           1,
+	MemoryBytes:    256 << 20,
+	PIDs:           64,
+	Timeout:        30 * time.Second,
+	MaxOutputBytes: 1 << 20,
+}
+
+// ContainerSpec describes one ephemeral container.
+type ContainerSpec struct {
+	Name   string
+	Image  string
+	Limits ContainerLimits
+}
+
+// ContainerRuntime starts a container from spec with stdin attached, waits
+// for it to exit and removes it. A non-zero exit status is reported as an
+// *exec.ExitError. When ctx is done the container must be killed.
+//
+// CLI implements it for Docker-compatible command lines; gVisor and
+// Firecracker plug in as the OCI runtime those CLIs start.
+type ContainerRuntime interface {
+	Run(ctx context.Context, spec ContainerSpec, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// CLI runs containers with a Docker-compatible command line: docker, podman
+// or nerdctl.
+type CLI struct {
+	// Path is the CLI binary, "docker" by default.
+	Path string
+	// OCIRuntime is passed as --runtime, e.g. "runsc" for gVisor or
+	// "kata-fc" for Kata Containers on Firecracker. Empty uses the CLI's
+	// default runtime.
+	OCIRuntime string
+}
+
+func (c CLI) path() string {
+	if c.Path == "" {
+		return "docker"
+	}
+	return c.Path
+}
+
+// Args returns the arguments of the run command for spec.
+func (c CLI) Args(spec ContainerSpec) []string {
+	l := spec.Limits
+	args := []string{"run", "--rm", "-i", "--name", spec.Name,
+		"--read-only", "--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"--cpus", strconv.FormatFloat(l.CPUs, 'f', -1, 64),
+		"--memory", strconv.FormatInt(l.MemoryBytes, 10),
+		"--pids-limit", strconv.Itoa(l.PIDs),
+	}
+	if !l.Network {
+		args = append(args, "--network", "none")
+	}
+	if c.OCIRuntime != "" {
+		args = append(args, "--runtime", c.OCIRuntime)
+	}
+	return append(args, spec.Image)
+}
+
+// Run implements ContainerRuntime.
+func (c CLI) Run(ctx context.Context, spec ContainerSpec, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, c.path(), c.Args(spec)...) //nolint:gosec // the binary is configured by the operator
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	cmd.Cancel = func() error {
+		// Killing the client leaves the container running; remove it too.
+		_ = exec.Command(c.path(), "rm", "-f", spec.Name).Run() //nolint:gosec // as above
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = 5 * time.Second
+	return cmd.Run()
+}
+
+// Containers runs tools packaged as OCI images.
+type Containers struct {
+	rt     ContainerRuntime
+	limits ContainerLimits
+}
+
+// NewContainers creates a container executor on rt.
+func NewContainers(rt ContainerRuntime, limits ContainerLimits) *Containers {
+	d := DefaultContainerLimits
+	if limits.CPUs <= 0 {
+		limits.CPUs = d.CPUs
+	}
+	if limits.MemoryBytes <= 0 {
+		limits.MemoryBytes = d.MemoryBytes
+	}
+	if limits.PIDs <= 0 {
+		limits.PIDs = d.PIDs
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = d.Timeout
+	}
+	if limits.MaxOutputBytes <= 0 {
+		limits.MaxOutputBytes = d.MaxOutputBytes
+	}
+	return &Containers{rt: rt, limits: limits}
+}
+
+// Limits returns the limits in effect.
+func (c *Containers) Limits() ContainerLimits {
+	return c.limits
+}
+
+// Run starts image in a fresh container, writes input to its stdin and
+// returns what it wrote to stdout. timeout, when positive and shorter than
+// the executor's budget, is used instead.
+func (c *Containers) Run(ctx context.Context, image string, input []byte, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 || timeout > c.limits.Timeout {
+		timeout = c.limits.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, err
+	}
+	spec := ContainerSpec{Name: "agent-tools-" + hex.EncodeToString(suffix[:]), Image: image, Limits: c.limits}
+	stdout := &cappedBuffer{max: c.limits.MaxOutputBytes}
+	stderr := &cappedBuffer{max: 4 << 10, truncate: true}
+
+	err := c.rt.Run(ctx, spec, bytes.NewReader(input), stdout, stderr)
+	switch {
+	case stdout.overflow:
+		return nil, fmt.Errorf("%w: max %d bytes", ErrOutputTooLarge, c.limits.MaxOutputBytes)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil:
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return nil, fmt.Errorf("%w: exit status %d%s", ErrExit, exit.ExitCode(), stderr.suffix())
+		}
+		return nil, fmt.Errorf("%w: %w%s", ErrExit, err, stderr.suffix())
+	}
+	return stdout.Bytes(), nil
+}
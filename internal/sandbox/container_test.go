@@ -0,0 +1,71 @@
+package sandbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCLI writes a script standing in for docker: "run" echoes stdin, except
+// for images named fail (exit 3) and slow (hangs); "rm" succeeds.
+func fakeCLI(t *testing.T) sandbox.CLI {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/sh
+[ "$1" = rm ] && exit 0
+for last; do :; done
+case "$last" in
+fail*) echo "boom" >&2; exit 3 ;;
+slow*) exec sleep 10 ;;
+esac
+exec cat
+`
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755)) //nolint:gosec // test executable
+	return sandbox.CLI{Path: path}
+}
+
+func TestCLI_Args(t *testing.T) {
+	spec := sandbox.ContainerSpec{Name: "agent-tools-1", Image: "ghcr.io/acme/lint@sha256:abc", Limits: sandbox.DefaultContainerLimits}
+
+	args := sandbox.CLI{OCIRuntime: "runsc"}.Args(spec)
+	assert.Equal(t, "run", args[0])
+	assert.Equal(t, spec.Image, args[len(args)-1])
+	assert.Subset(t, args, []string{"--rm", "-i", "--read-only", "--network", "none", "--runtime", "runsc",
+		"--memory", "268435456", "--cpus", "1", "--pids-limit", "64"})
+
+	spec.Limits.Network = true
+	args = sandbox.CLI{}.Args(spec)
+	assert.NotContains(t, args, "none")
+	assert.NotContains(t, args, "--runtime")
+}
+
+func TestContainers_Run(t *testing.T) {
+	c := sandbox.NewContainers(fakeCLI(t), sandbox.ContainerLimits{Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	out, err := c.Run(ctx, "echo@sha256:abc", []byte(`{"text":"hi"}`), 0)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"hi"}`, string(out))
+
+	_, err = c.Run(ctx, "fail@sha256:abc", nil, 0)
+	assert.True(t, errors.Is(err, sandbox.ErrExit), "got %v", err)
+	assert.ErrorContains(t, err, "exit status 3: boom")
+
+	start := time.Now()
+	_, err = c.Run(ctx, "slow@sha256:abc", nil, 100*time.Millisecond)
+	assert.True(t, errors.Is(err, sandbox.ErrTimeout), "got %v", err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestContainers_OutputLimit(t *testing.T) {
+	c := sandbox.NewContainers(fakeCLI(t), sandbox.ContainerLimits{MaxOutputBytes: 4})
+	_, err := c.Run(context.Background(), "echo@sha256:abc", []byte(`{"text":"hi"}`), 0)
+	assert.True(t, errors.Is(err, sandbox.ErrOutputTooLarge), "got %v", err)
+}
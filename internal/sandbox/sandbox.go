@@ -0,0 +1,278 @@
+// Package sandbox runs tools on the registry itself: WebAssembly modules in
+// wazero (Executor) and OCI images in ephemeral containers (Containers).
+//
+// Both follow the same contract: the tool reads the invocation input as JSON
+// on stdin, writes its output as a JSON object on stdout and exits with
+// status 0.
+//
+// A WebAssembly tool is a WASI command. It gets no filesystem, network,
+// environment or host clock; the clocks and random source it sees are
+// deterministic.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// PageSize is the size of a WebAssembly memory page.
+const PageSize = 64 << 10
+
+var (
+	// ErrInvalidModule is returned for bytes that are not a runnable WASI
+	// command within the memory limit.
+	ErrInvalidModule = errors.New("invalid wasm module")
+	// ErrTimeout is returned when a tool runs out of its time budget.
+	ErrTimeout = errors.New("wasm execution timed out")
+	// ErrOutputTooLarge is returned when a tool writes more than the
+	// MaxOutputBytes limit to stdout.
+	ErrOutputTooLarge = errors.New("wasm output too large")
+	// ErrExit is returned when a tool exits with a non-zero status or traps.
+	ErrExit = errors.New("wasm module failed")
+)
+
+// Limits bound every execution.
+type Limits struct {
+	// MemoryPages caps a module's linear memory, in 64 KiB pages. Modules
+	// declaring more are rejected on upload; memory.grow past it fails.
+	MemoryPages uint32
+	// Timeout is the execution budget. wazero has no instruction metering,
+	// so fuel is wall-clock time: a module still running when it expires is
+	// interrupted. A tool's own timeout_ms applies when it is shorter.
+	Timeout        time.Duration
+	MaxOutputBytes int
+	MaxModuleBytes int
+}
+
+// DefaultLimits are used by New for zero fields.
+var DefaultLimits = Limits{
+	MemoryPages:    256, // 16 MiB
+	Timeout:        5 * time.Second,
+	MaxOutputBytes: 1 << 20,
+	MaxModuleBytes: 8 << 20,
+}
+
+// maxCompiled bounds the compiled modules kept in memory; beyond it the
+// cache is emptied and modules are recompiled on their next invocation.
+// Emptying waits for running invocations, which are bounded by the timeout.
+const maxCompiled = 128
+
+// Loader returns the bytes of the module with the given digest. It is called
+// on the first invocation of a module after start or cache eviction.
+type Loader func(ctx context.Context, digest string) ([]byte, error)
+
+// Executor compiles and runs modules. It is safe for concurrent use; every
+// invocation gets a fresh instance.
+type Executor struct {
+	rt     wazero.Runtime
+	limits Limits
+	load   Loader
+	// check compiles uploads for Validate. wazero shares compiled code
+	// between modules of the same bytes within a runtime, so closing one
+	// compiled in rt would free the code of a cached copy.
+	check wazero.Runtime
+
+	// mu guards compiled. Invocations hold it for reading while they run so
+	// that evicted modules are not closed under them.
+	mu       sync.RWMutex
+	compiled map[string]wazero.CompiledModule
+}
+
+// New creates an Executor that fetches modules with load.
+func New(ctx context.Context, limits Limits, load Loader) (*Executor, error) {
+	if limits.MemoryPages == 0 {
+		limits.MemoryPages = DefaultLimits.MemoryPages
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultLimits.Timeout
+	}
+	if limits.MaxOutputBytes <= 0 {
+		limits.MaxOutputBytes = DefaultLimits.MaxOutputBytes
+	}
+	if limits.MaxModuleBytes <= 0 {
+		limits.MaxModuleBytes = DefaultLimits.MaxModuleBytes
+	}
+	cfg := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true)
+	rt := wazero.NewRuntimeWithConfig(ctx, cfg)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		_ = rt.Close(ctx)
+		return nil, fmt.Errorf("instantiate wasi: %w", err)
+	}
+	return &Executor{rt: rt, limits: limits, load: load, check: wazero.NewRuntimeWithConfig(ctx, cfg),
+		compiled: make(map[string]wazero.CompiledModule)}, nil
+}
+
+// Limits returns the limits in effect.
+func (e *Executor) Limits() Limits {
+	return e.limits
+}
+
+// Close releases the runtime and every compiled module.
+func (e *Executor) Close(ctx context.Context) error {
+	return errors.Join(e.rt.Close(ctx), e.check.Close(ctx))
+}
+
+// Validate checks that wasm is a WASI command this executor can run, so
+// providers learn about a bad module on upload rather than on invocation.
+func (e *Executor) Validate(ctx context.Context, wasm []byte) error {
+	if len(wasm) > e.limits.MaxModuleBytes {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrInvalidModule, len(wasm), e.limits.MaxModuleBytes)
+	}
+	cm, err := compile(ctx, e.check, wasm)
+	if err != nil {
+		return err
+	}
+	return cm.Close(ctx)
+}
+
+func compile(ctx context.Context, rt wazero.Runtime, wasm []byte) (wazero.CompiledModule, error) {
+	cm, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModule, err)
+	}
+	if _, ok := cm.ExportedFunctions()["_start"]; !ok {
+		_ = cm.Close(ctx)
+		return nil, fmt.Errorf("%w: must be a WASI command exporting _start", ErrInvalidModule)
+	}
+	return cm, nil
+}
+
+// module returns the compiled module for digest, loading and compiling it on
+// a cache miss. It returns with e.mu held for reading; the caller unlocks it
+// once the instance has finished.
+func (e *Executor) module(ctx context.Context, digest string) (wazero.CompiledModule, error) {
+	e.mu.RLock()
+	if cm, ok := e.compiled[digest]; ok {
+		return cm, nil
+	}
+	e.mu.RUnlock()
+
+	wasm, err := e.load(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := compile(ctx, e.rt, wasm)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if existing, ok := e.compiled[digest]; ok {
+		// Compiled concurrently; keep the first. Both share their code,
+		// which closing the second would free.
+		cm = existing
+	} else {
+		if len(e.compiled) >= maxCompiled {
+			for d, old := range e.compiled {
+				_ = old.Close(ctx)
+				delete(e.compiled, d)
+			}
+		}
+		e.compiled[digest] = cm
+	}
+	e.mu.Unlock()
+	e.mu.RLock()
+	// An eviction can run between the two locks; compile again if so.
+	if e.compiled[digest] != cm {
+		e.mu.RUnlock()
+		return e.module(ctx, digest)
+	}
+	return cm, nil
+}
+
+// Run executes the module with the given digest on input and returns what
+// it wrote to stdout. timeout, when positive and shorter than the executor's
+// budget, is used instead.
+func (e *Executor) Run(ctx context.Context, digest string, input []byte, timeout time.Duration) ([]byte, error) {
+	cm, err := e.module(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer e.mu.RUnlock()
+	if timeout <= 0 || timeout > e.limits.Timeout {
+		timeout = e.limits.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: e.limits.MaxOutputBytes}
+	stderr := &cappedBuffer{max: 4 << 10, truncate: true}
+	cfg := wazero.NewModuleConfig().
+		WithName(""). // anonymous, so instances of one module can run concurrently
+		WithArgs(digest).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+
+	// On error the instance is nil or already closed.
+	mod, err := e.rt.InstantiateModule(ctx, cm, cfg)
+	if err == nil {
+		defer mod.Close(ctx) //nolint:errcheck // the instance is discarded either way
+	}
+	if stdout.overflow {
+		return nil, fmt.Errorf("%w: max %d bytes", ErrOutputTooLarge, e.limits.MaxOutputBytes)
+	}
+	if err != nil {
+		var exit *sys.ExitError
+		if !errors.As(err, &exit) {
+			return nil, fmt.Errorf("%w: %w%s", ErrExit, err, stderr.suffix())
+		}
+		switch exit.ExitCode() {
+		case 0:
+		case sys.ExitCodeDeadlineExceeded:
+			return nil, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+		case sys.ExitCodeContextCanceled:
+			return nil, ctx.Err()
+		default:
+			return nil, fmt.Errorf("%w: exit status %d%s", ErrExit, exit.ExitCode(), stderr.suffix())
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+// cappedBuffer collects at most max bytes. Past that it either drops the
+// rest (truncate) or fails the write, which stops the tool. It does not
+// embed bytes.Buffer, whose ReadFrom would let io.Copy bypass the cap.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	truncate bool
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.max - b.buf.Len()
+	if len(p) <= room {
+		return b.buf.Write(p)
+	}
+	b.overflow = true
+	if b.truncate {
+		_, _ = b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return 0, ErrOutputTooLarge
+}
+
+// Bytes returns the collected bytes.
+func (b *cappedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// suffix formats collected stderr for an error message.
+func (b *cappedBuffer) suffix() string {
+	s := bytes.TrimSpace(b.buf.Bytes())
+	if len(s) == 0 {
+		return ""
+	}
+	return ": " + string(s)
+}
@@ -0,0 +1,139 @@
+package sandbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/sandbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The modules under testdata are assembled from the .wat files next to them.
+func readModule(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name+".wasm"))
+	require.NoError(t, err)
+	return b
+}
+
+// newExecutor serves modules by name from testdata and counts loads.
+func newExecutor(t *testing.T, limits sandbox.Limits) (*sandbox.Executor, *atomic.Int32) {
+	t.Helper()
+	var loads atomic.Int32
+	ex, err := sandbox.New(context.Background(), limits, func(_ context.Context, digest string) ([]byte, error) {
+		loads.Add(1)
+		return readModule(t, digest), nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ex.Close(context.Background()) })
+	return ex, &loads
+}
+
+func TestRun_Echo(t *testing.T) {
+	ex, loads := newExecutor(t, sandbox.Limits{})
+	ctx := context.Background()
+
+	out, err := ex.Run(ctx, "echo", []byte(`{"text":"hello"}`), 0)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"hello"}`, string(out))
+
+	_, err = ex.Run(ctx, "echo", []byte(`{}`), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), loads.Load(), "compiled module should be cached")
+}
+
+func TestRun_Concurrent(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ex.Run(context.Background(), "echo", []byte(`{"n":1}`), 0)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{Timeout: time.Second})
+
+	start := time.Now()
+	_, err := ex.Run(context.Background(), "spin", nil, 50*time.Millisecond)
+	assert.True(t, errors.Is(err, sandbox.ErrTimeout), "got %v", err)
+	assert.Less(t, time.Since(start), time.Second, "the tool's shorter timeout should apply")
+}
+
+func TestRun_ExitStatus(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{})
+	_, err := ex.Run(context.Background(), "fail", nil, 0)
+	assert.True(t, errors.Is(err, sandbox.ErrExit), "got %v", err)
+	assert.Contains(t, err.Error(), "exit status 3")
+}
+
+func TestRun_OutputLimit(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{MaxOutputBytes: 8})
+	_, err := ex.Run(context.Background(), "echo", []byte(`{"text":"too long"}`), 0)
+	assert.True(t, errors.Is(err, sandbox.ErrOutputTooLarge), "got %v", err)
+}
+
+func TestRun_LoadError(t *testing.T) {
+	boom := errors.New("boom")
+	ex, err := sandbox.New(context.Background(), sandbox.Limits{}, func(context.Context, string) ([]byte, error) {
+		return nil, boom
+	})
+	require.NoError(t, err)
+	defer func() { _ = ex.Close(context.Background()) }()
+
+	_, err = ex.Run(context.Background(), "echo", nil, 0)
+	assert.ErrorIs(t, err, boom)
+
+	ex, err = sandbox.New(context.Background(), sandbox.Limits{}, func(context.Context, string) ([]byte, error) {
+		return []byte("not wasm"), nil
+	})
+	require.NoError(t, err)
+	defer func() { _ = ex.Close(context.Background()) }()
+	_, err = ex.Run(context.Background(), "echo", nil, 0)
+	assert.ErrorIs(t, err, sandbox.ErrInvalidModule)
+}
+
+func TestLimits(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{Timeout: time.Second})
+	want := sandbox.DefaultLimits
+	want.Timeout = time.Second
+	assert.Equal(t, want, ex.Limits(), "zero limits take their default")
+}
+
+func TestValidate(t *testing.T) {
+	ex, _ := newExecutor(t, sandbox.Limits{MemoryPages: 16, MaxModuleBytes: 1 << 10})
+	ctx := context.Background()
+
+	_, err := ex.Run(ctx, "echo", []byte(`{}`), 0)
+	require.NoError(t, err)
+	assert.NoError(t, ex.Validate(ctx, readModule(t, "echo")))
+	_, err = ex.Run(ctx, "echo", []byte(`{}`), 0)
+	assert.NoError(t, err, "validating a module leaves a cached copy runnable")
+
+	for name, wasm := range map[string][]byte{
+		"garbage":      []byte("not wasm"),
+		"no _start":    {0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		"memory limit": readModule(t, "big"),
+		"too large":    make([]byte, 2<<10),
+	} {
+		err := ex.Validate(ctx, wasm)
+		assert.True(t, errors.Is(err, sandbox.ErrInvalidModule), "%s: got %v", name, err)
+	}
+}
@@ -0,0 +1,4 @@
+;; Asks for 32 MiB of memory up front.
+(module
+  (memory (export "memory") 512)
+  (func (export "_start")))
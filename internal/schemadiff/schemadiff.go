@@ -0,0 +1,263 @@
+// Package schemadiff compares two versions of a JSON Schema and classifies
+// each difference as breaking or not for the side of a tool call the schema
+// describes.
+package schemadiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Role is the side of a call a schema describes. A change that breaks
+// consumers of an input schema, which they write to, is often harmless in
+// an output schema, which they read, and the other way around.
+type Role int
+
+const (
+	// Input schemas validate what consumers send.
+	Input Role = iota
+	// Output schemas describe what consumers receive.
+	Output
+)
+
+// Kind is what happened at a path.
+type Kind string
+
+// Kinds of change.
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is one difference between two schemas. Path is a JSON pointer
+// into the schema: /properties/city/type. Changes within a set keyword
+// (required, enum, type) are reported per member, with the member as Old
+// or New.
+type Change struct {
+	Path     string `json:"path"`
+	Kind     Kind   `json:"kind"`
+	Old      any    `json:"old,omitempty"`
+	New      any    `json:"new,omitempty"`
+	Breaking bool   `json:"breaking"`
+}
+
+// annotations are keywords that document a schema without constraining
+// values; changing them never breaks anyone.
+var annotations = map[string]bool{
+	"title": true, "description": true, "examples": true, "default": true,
+	"$comment": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// Diff returns the changes from old to new, ordered by path. An empty
+// schema is one that accepts anything.
+func Diff(old, new json.RawMessage, role Role) ([]Change, error) {
+	a, err := decode(old)
+	if err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	b, err := decode(new)
+	if err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+	d := &differ{role: role}
+	d.schema("", a, b)
+	return d.changes, nil
+}
+
+// Breaking reports whether any of changes is breaking.
+func Breaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(raw json.RawMessage) (any, error) {
+	if len(strings.TrimSpace(string(raw))) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type differ struct {
+	role    Role
+	changes []Change
+}
+
+func (d *differ) add(path string, kind Kind, old, new any, breaking bool) {
+	d.changes = append(d.changes, Change{Path: path, Kind: kind, Old: old, New: new, Breaking: breaking})
+}
+
+// narrowing and widening are whether a schema that accepts less, or more,
+// than before breaks consumers: narrowing breaks those who send, widening
+// those who read.
+func (d *differ) narrowing() bool { return d.role == Input }
+func (d *differ) widening() bool  { return d.role == Output }
+
+func (d *differ) schema(path string, a, b any) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		// Values were unconstrained and now are not.
+		d.add(path, Added, nil, b, d.narrowing())
+		return
+	case b == nil:
+		d.add(path, Removed, a, nil, d.widening())
+		return
+	}
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	if !aok || !bok {
+		// Boolean schemas, or a keyword value that is not a schema.
+		if !reflect.DeepEqual(a, b) {
+			d.add(path, Changed, a, b, true)
+		}
+		return
+	}
+
+	for _, key := range keys(am, bm) {
+		p := path + "/" + escape(key)
+		av, bv := am[key], bm[key]
+		switch {
+		case key == "properties":
+			d.properties(p, asMap(av), asMap(bv))
+		case key == "items" || key == "additionalProperties" && (isMap(av) || isMap(bv)):
+			d.schema(p, av, bv)
+		case key == "additionalProperties":
+			d.additional(p, av, bv)
+		case key == "required":
+			// More required properties is narrowing; fewer means a
+			// property consumers read may now be missing.
+			d.set(p, av, bv, d.narrowing(), d.widening())
+		case key == "enum" || key == "type":
+			d.set(p, av, bv, d.widening(), d.narrowing())
+		case reflect.DeepEqual(av, bv):
+		case av == nil:
+			d.add(p, Added, nil, bv, !annotations[key])
+		case bv == nil:
+			d.add(p, Removed, av, nil, !annotations[key])
+		default:
+			d.add(p, Changed, av, bv, !annotations[key])
+		}
+	}
+}
+
+func (d *differ) properties(path string, a, b map[string]any) {
+	for _, name := range keys(a, b) {
+		p := path + "/" + escape(name)
+		av, aok := a[name]
+		bv, bok := b[name]
+		switch {
+		case !aok:
+			// Whether consumers must now send it is up to required.
+			d.add(p, Added, nil, bv, false)
+		case !bok:
+			// Consumers that send it may be rejected; those that read it
+			// will not find it.
+			d.add(p, Removed, av, nil, true)
+		default:
+			d.schema(p, av, bv)
+		}
+	}
+}
+
+// additional compares boolean additionalProperties; absent means true.
+func (d *differ) additional(path string, a, b any) {
+	av, bv := a != false, b != false
+	switch {
+	case av == bv:
+	case av:
+		d.add(path, Changed, a, b, d.narrowing())
+	default:
+		d.add(path, Changed, a, b, d.widening())
+	}
+}
+
+// set compares a keyword whose value is a set of members, or a single
+// member. addBreaks and removeBreaks are whether a member added to, or
+// removed from, the set breaks consumers.
+func (d *differ) set(path string, a, b any, addBreaks, removeBreaks bool) {
+	as, bs := members(a), members(b)
+	if a == nil || b == nil {
+		// An absent type or enum allows anything: going from nothing to
+		// a set narrows, the reverse widens.
+		if a == nil && b != nil {
+			d.add(path, Added, nil, b, d.narrowing())
+		} else if a != nil && b == nil {
+			d.add(path, Removed, a, nil, d.widening())
+		}
+		return
+	}
+	for _, m := range as {
+		if !contains(bs, m) {
+			d.add(path, Removed, m, nil, removeBreaks)
+		}
+	}
+	for _, m := range bs {
+		if !contains(as, m) {
+			d.add(path, Added, nil, m, addBreaks)
+		}
+	}
+}
+
+func members(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+func contains(set []any, v any) bool {
+	for _, m := range set {
+		if reflect.DeepEqual(m, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func isMap(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+// keys returns the keys of a and b, sorted.
+func keys(a, b map[string]any) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, m := range []map[string]any{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// escape escapes a key for use as a JSON pointer token.
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
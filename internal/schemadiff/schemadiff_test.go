@@ -0,0 +1,82 @@
+package schemadiff_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/schemadiff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff_Identical(t *testing.T) {
+	s := json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`)
+	changes, err := schemadiff.Diff(s, s, schemadiff.Input)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiff_Breaking(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		old, new      string
+		input, output bool
+	}{
+		{"required added", `{"required":["a"]}`, `{"required":["a","b"]}`, true, false},
+		{"required removed", `{"required":["a","b"]}`, `{"required":["a"]}`, false, true},
+		{"enum value added", `{"enum":["a"]}`, `{"enum":["a","b"]}`, false, true},
+		{"enum value removed", `{"enum":["a","b"]}`, `{"enum":["a"]}`, true, false},
+		{"type widened", `{"type":"string"}`, `{"type":["string","null"]}`, false, true},
+		{"type changed", `{"type":"string"}`, `{"type":"integer"}`, true, true},
+		{"property added", `{"properties":{}}`, `{"properties":{"a":{"type":"string"}}}`, false, false},
+		{"property removed", `{"properties":{"a":{}}}`, `{"properties":{}}`, true, true},
+		{"closed", `{}`, `{"additionalProperties":false}`, true, false},
+		{"opened", `{"additionalProperties":false}`, `{}`, false, true},
+		{"constraint changed", `{"maxLength":10}`, `{"maxLength":5}`, true, true},
+		{"constraint added", `{}`, `{"maxLength":5}`, true, true},
+		{"constraint removed", `{"maxLength":5}`, `{}`, true, true},
+		{"description changed", `{"description":"a"}`, `{"description":"b"}`, false, false},
+		{"description added", `{}`, `{"description":"a"}`, false, false},
+		{"description removed", `{"description":"a"}`, `{}`, false, false},
+		{"type added", `{}`, `{"type":"string"}`, true, false},
+		{"type removed", `{"type":"string"}`, `{}`, false, true},
+		{"items added", `{}`, `{"items":{"type":"string"}}`, true, false},
+		{"items removed", `{"items":{"type":"string"}}`, `{}`, false, true},
+		{"items closed", `{"items":true}`, `{"items":false}`, true, true},
+		{"additional schema", `{"additionalProperties":{"type":"string"}}`, `{"additionalProperties":{"type":"number"}}`, true, true},
+		{"nested", `{"items":{"properties":{"a":{"type":"string"}}}}`, `{"items":{"properties":{"a":{"type":"number"}}}}`, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := schemadiff.Diff(json.RawMessage(tc.old), json.RawMessage(tc.new), schemadiff.Input)
+			require.NoError(t, err)
+			require.NotEmpty(t, in)
+			assert.Equal(t, tc.input, schemadiff.Breaking(in), "input")
+
+			out, err := schemadiff.Diff(json.RawMessage(tc.old), json.RawMessage(tc.new), schemadiff.Output)
+			require.NoError(t, err)
+			assert.Equal(t, tc.output, schemadiff.Breaking(out), "output")
+		})
+	}
+}
+
+func TestDiff_Paths(t *testing.T) {
+	old := json.RawMessage(`{"properties":{"a/b":{"type":"string"},"c":{"enum":[1,2]}},"required":["c"]}`)
+	new := json.RawMessage(`{"properties":{"a/b":{"type":"number"},"c":{"enum":[1]}},"required":["c","a/b"]}`)
+	changes, err := schemadiff.Diff(old, new, schemadiff.Input)
+	require.NoError(t, err)
+	assert.Equal(t, []schemadiff.Change{
+		{Path: "/properties/a~1b/type", Kind: schemadiff.Removed, Old: "string", Breaking: true},
+		{Path: "/properties/a~1b/type", Kind: schemadiff.Added, New: "number", Breaking: false},
+		{Path: "/properties/c/enum", Kind: schemadiff.Removed, Old: float64(2), Breaking: true},
+		{Path: "/required", Kind: schemadiff.Added, New: "a/b", Breaking: true},
+	}, changes)
+}
+
+func TestDiff_Invalid(t *testing.T) {
+	_, err := schemadiff.Diff(json.RawMessage(`{`), json.RawMessage(`{}`), schemadiff.Input)
+	assert.Error(t, err)
+
+	changes, err := schemadiff.Diff(nil, json.RawMessage(`{"type":"object"}`), schemadiff.Input)
+	require.NoError(t, err)
+	assert.True(t, schemadiff.Breaking(changes), "constraining an empty schema narrows it")
+}
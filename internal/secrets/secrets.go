@@ -0,0 +1,94 @@
+// Package secrets encrypts provider credentials at rest with AES-256-GCM.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the length of an encryption key in bytes.
+const KeySize = 32
+
+// version prefixes sealed values so the format can change later.
+const version = "v1."
+
+// ErrDecrypt is returned when a sealed value cannot be opened: it was
+// tampered with, sealed under another key, or bound to different data.
+var ErrDecrypt = errors.New("decrypt secret")
+
+// Box seals and opens secrets with a single key.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a Box from a KeySize-byte key.
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 key, as produced by `openssl rand -base64 32`.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("secrets key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secrets key must decode to %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads a base64 key from path.
+func ReadKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // path comes from the operator's command line
+	if err != nil {
+		return nil, fmt.Errorf("read secrets key: %w", err)
+	}
+	return ParseKey(string(b))
+}
+
+// Seal encrypts plaintext. aad is authenticated but not stored; the same
+// aad must be passed to Open, which binds the secret to e.g. its owner's ID.
+func (b *Box) Seal(plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("nonce: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, aad)
+	return version + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal.
+func (b *Box) Open(sealed string, aad []byte) ([]byte, error) {
+	enc, ok := strings.CutPrefix(sealed, version)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown format", ErrDecrypt)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: malformed", ErrDecrypt)
+	}
+	n := b.aead.NonceSize()
+	plaintext, err := b.aead.Open(nil, raw[:n], raw[n:], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
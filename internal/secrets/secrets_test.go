@@ -0,0 +1,72 @@
+package secrets_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte { return bytes.Repeat([]byte{b}, secrets.KeySize) }
+
+func TestBox_RoundTrip(t *testing.T) {
+	box, err := secrets.New(testKey(1))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("sk-live-123"), []byte("tool-a"))
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "sk-live-123")
+
+	again, err := box.Seal([]byte("sk-live-123"), []byte("tool-a"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "nonces must differ")
+
+	plain, err := box.Open(sealed, []byte("tool-a"))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-live-123", string(plain))
+}
+
+func TestBox_OpenRejects(t *testing.T) {
+	box, err := secrets.New(testKey(1))
+	require.NoError(t, err)
+	other, err := secrets.New(testKey(2))
+	require.NoError(t, err)
+	sealed, err := box.Seal([]byte("secret"), []byte("tool-a"))
+	require.NoError(t, err)
+
+	_, err = box.Open(sealed, []byte("tool-b"))
+	assert.ErrorIs(t, err, secrets.ErrDecrypt)
+	_, err = other.Open(sealed, []byte("tool-a"))
+	assert.ErrorIs(t, err, secrets.ErrDecrypt)
+	_, err = box.Open("v2.abc", nil)
+	assert.ErrorIs(t, err, secrets.ErrDecrypt)
+	_, err = box.Open("v1.!!", nil)
+	assert.ErrorIs(t, err, secrets.ErrDecrypt)
+}
+
+func TestParseKey(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString(testKey(7))
+	key, err := secrets.ParseKey(enc + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, testKey(7), key)
+
+	_, err = secrets.ParseKey("not base64")
+	assert.Error(t, err)
+	_, err = secrets.ParseKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+	_, err = secrets.New([]byte("short"))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte(enc), 0o600))
+	key, err = secrets.ReadKeyFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testKey(7), key)
+	_, err = secrets.ReadKeyFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// fakeCapabilities are the building blocks of generated tools.
+var fakeCapabilities = []struct {
+	name, description string
+	tags              []string
+}{
+	{"weather-forecast", "Hourly and 10-day weather forecasts for any coordinates", []string{"weather", "geo"}},
+	{"solidity-auditor", "Audits Solidity smart contracts for common vulnerabilities", []string{"security", "solidity", "audit"}},
+	{"pdf-summarizer", "Summarizes PDF documents into key points", []string{"documents", "nlp"}},
+	{"sql-explainer", "Explains SQL query plans and suggests indexes", []string{"database", "sql"}},
+	{"image-captioner", "Generates alt text and captions for images", []string{"vision", "accessibility"}},
+	{"code-reviewer", "Reviews diffs and flags bugs and style issues", []string{"code", "review"}},
+	{"translate", "Translates text between 100+ languages", []string{"nlp", "translation"}},
+	{"price-oracle", "Spot and TWAP prices for crypto assets", []string{"defi", "oracle"}},
+	{"web-scraper", "Fetches a page and extracts readable text", []string{"web", "scraping"}},
+	{"geocoder", "Converts addresses to coordinates and back", []string{"geo", "maps"}},
+	{"speech-to-text", "Transcribes audio files with timestamps", []string{"audio", "transcription"}},
+	{"unit-converter", "Converts between physical units", []string{"math", "utility"}},
+}
+
+var fakeRegions = []string{"us-east", "eu-west", "ap-south", "edge"}
+
+// Fake generates n realistic-looking tools spread over a handful of
+// providers. The same seed always yields the same fixtures, so re-seeding a
+// development database only skips duplicates.
+func Fake(n int, seed int64) *Fixtures {
+	rnd := rand.New(rand.NewSource(seed)) //nolint:gosec // fixtures, not security
+	f := &Fixtures{}
+	for i, region := range fakeRegions {
+		key := make([]byte, 32)
+		_, _ = rnd.Read(key)
+		f.Providers = append(f.Providers, &registry.Provider{
+			ID:        fmt.Sprintf("did:claw:agent:dev-%s", region),
+			Name:      fmt.Sprintf("Dev Node %s", strings.ToUpper(region)),
+			Endpoint:  fmt.Sprintf("grpc://10.0.%d.10:50051", i),
+			PubKey:    fmt.Sprintf("ed25519:%x", key),
+			StakeCLAW: fmt.Sprintf("%d.0", 100*(rnd.Intn(20)+1)),
+		})
+	}
+	for i := 0; i < n; i++ {
+		c := fakeCapabilities[i%len(fakeCapabilities)]
+		p := f.Providers[rnd.Intn(len(f.Providers))]
+		pricing := &registry.Pricing{Model: registry.PricingFree}
+		if rnd.Intn(3) > 0 {
+			pricing = &registry.Pricing{
+				Model:      registry.PricingPerCall,
+				AmountCLAW: fmt.Sprintf("%.1f", float64(rnd.Intn(200)+1)/10),
+			}
+		}
+		f.Tools = append(f.Tools, &Tool{
+			RegisterToolRequest: registry.RegisterToolRequest{
+				Name:        c.name,
+				Version:     fmt.Sprintf("%d.%d.0", i/len(fakeCapabilities)+1, rnd.Intn(10)),
+				Description: c.description,
+				Schema: registry.ToolSchema{
+					Input:  []byte(`{"type":"object","additionalProperties":true}`),
+					Output: []byte(`{"type":"object","additionalProperties":true}`),
+				},
+				Pricing:   pricing,
+				Endpoint:  p.Endpoint,
+				TimeoutMS: int64(1000 * (rnd.Intn(30) + 1)),
+				Tags:      c.tags,
+			},
+			ProviderID: p.ID,
+		})
+	}
+	return f
+}
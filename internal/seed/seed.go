@@ -0,0 +1,75 @@
+// Package seed loads development fixtures into a registry so local agents
+// start against a populated catalog.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+)
+
+// Fixtures is the seed file format.
+type Fixtures struct {
+	Providers []*registry.Provider `json:"providers"`
+	Tools     []*Tool              `json:"tools"`
+}
+
+// Tool is a tool fixture: a registration request plus the provider that
+// registers it.
+type Tool struct {
+	registry.RegisterToolRequest
+	ProviderID string `json:"provider_id"`
+}
+
+// Summary counts what Load changed.
+type Summary struct {
+	Providers int // registered or updated
+	Tools     int // newly registered
+	Skipped   int // tools already present
+}
+
+// ReadFile parses a fixtures file.
+func ReadFile(path string) (*Fixtures, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // path comes from the operator's --seed flag
+	if err != nil {
+		return nil, fmt.Errorf("read fixtures: %w", err)
+	}
+	var f Fixtures
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse fixtures %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Load registers every provider and tool in f. It is idempotent: tools that
+// are already registered are skipped, so a seed can be applied on every
+// start.
+func Load(ctx context.Context, reg *registry.Registry, f *Fixtures) (Summary, error) {
+	var s Summary
+	for _, p := range f.Providers {
+		if _, err := reg.RegisterProvider(ctx, p); err != nil {
+			return s, fmt.Errorf("seed provider %s: %w", p.ID, err)
+		}
+		s.Providers++
+	}
+	for _, t := range f.Tools {
+		if t.ProviderID == "" {
+			return s, fmt.Errorf("seed tool %s: provider_id is required", t.Name)
+		}
+		req := t.RegisterToolRequest
+		req.ProviderID = t.ProviderID
+		if _, err := reg.RegisterTool(ctx, &req); err != nil {
+			if errors.Is(err, registry.ErrDuplicate) {
+				s.Skipped++
+				continue
+			}
+			return s, fmt.Errorf("seed tool %s@%s: %w", t.Name, t.Version, err)
+		}
+		s.Tools++
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package seed_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/seed"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newRegistry(t *testing.T) *registry.Registry {
+	t.Helper()
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return registry.New(db, zaptest.NewLogger(t))
+}
+
+func TestLoad_ExampleFixtures(t *testing.T) {
+	f, err := seed.ReadFile("../../docs/fixtures.example.json")
+	require.NoError(t, err)
+
+	reg := newRegistry(t)
+	ctx := context.Background()
+	sum, err := seed.Load(ctx, reg, f)
+	require.NoError(t, err)
+	assert.Equal(t, seed.Summary{Providers: 1, Tools: 2}, sum)
+
+	res, err := reg.SearchTools(ctx, &registry.SearchQuery{Query: "weather"})
+	require.NoError(t, err)
+	require.Len(t, res.Tools, 1)
+	assert.Equal(t, "did:claw:agent:dev-local", res.Tools[0].ProviderID)
+
+	sum, err = seed.Load(ctx, reg, f)
+	require.NoError(t, err)
+	assert.Equal(t, seed.Summary{Providers: 1, Skipped: 2}, sum, "re-seeding skips existing tools")
+}
+
+func TestLoad_ToolWithoutProvider(t *testing.T) {
+	f := &seed.Fixtures{Tools: []*seed.Tool{{
+		RegisterToolRequest: registry.RegisterToolRequest{Name: "x", Version: "1", Endpoint: "http://x"},
+	}}}
+	_, err := seed.Load(context.Background(), newRegistry(t), f)
+	assert.ErrorContains(t, err, "provider_id is required")
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	_, err := seed.ReadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte("{"), 0o600))
+	_, err = seed.ReadFile(bad)
+	assert.ErrorContains(t, err, "parse fixtures")
+}
+
+func TestFake(t *testing.T) {
+	f := seed.Fake(30, 1)
+	require.Len(t, f.Tools, 30)
+	assert.Equal(t, seed.Fake(30, 1), f, "same seed, same fixtures")
+
+	reg := newRegistry(t)
+	sum, err := seed.Load(context.Background(), reg, f)
+	require.NoError(t, err)
+	assert.Equal(t, 30, sum.Tools)
+	assert.Equal(t, len(f.Providers), sum.Providers)
+}
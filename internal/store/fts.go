@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// ftsIndexes are the full-text indexes and the content tables they index.
+// Triggers keep them in step, but manual edits to the content tables or a
+// failed migration can leave an index missing rows or holding stale ones.
+var ftsIndexes = []struct{ index, content string }{
+	{"tools_fts", "tools"},
+	{"federated_tools_fts", "federated_tools"},
+}
+
+// Reindex rebuilds every full-text index from its content table.
+func (db *DB) Reindex(ctx context.Context) error {
+	for _, f := range ftsIndexes {
+		if err := db.rebuild(ctx, f.index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepairFTS rebuilds the full-text indexes that do not index as many rows
+// as their content table holds, and returns their names. Serve runs it on
+// startup, so search does not silently miss tools.
+func (db *DB) RepairFTS(ctx context.Context) ([]string, error) {
+	var rebuilt []string
+	for _, f := range ftsIndexes {
+		var indexed, rows int
+		// The docsize shadow table has a row per indexed document; counting
+		// the index itself would read the content table.
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+f.index+"_docsize").Scan(&indexed); err != nil {
+			return rebuilt, fmt.Errorf("count %s: %w", f.index, err)
+		}
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+f.content).Scan(&rows); err != nil {
+			return rebuilt, fmt.Errorf("count %s: %w", f.content, err)
+		}
+		if indexed == rows {
+			continue
+		}
+		if err := db.rebuild(ctx, f.index); err != nil {
+			return rebuilt, err
+		}
+		rebuilt = append(rebuilt, f.index)
+	}
+	return rebuilt, nil
+}
+
+func (db *DB) rebuild(ctx context.Context, index string) error {
+	if _, err := db.ExecContext(ctx, "INSERT INTO "+index+"("+index+") VALUES ('rebuild')"); err != nil {
+		return fmt.Errorf("rebuild %s: %w", index, err)
+	}
+	return nil
+}
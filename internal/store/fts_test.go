@@ -0,0 +1,49 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairFTS(t *testing.T) {
+	db, err := store.Open(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, `INSERT INTO providers (id, endpoint, pubkey, created_at, last_seen) VALUES ('p', '', '', 0, 0)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO tools (id, name, version, description, schema_json, pricing, provider_id, endpoint, created_at, updated_at)
+		VALUES ('t', 'solidity-audit', '1.0.0', 'Audits contracts', '{}', '{}', 'p', 'https://example.com', 0, 0)
+	`)
+	require.NoError(t, err)
+	matches := func() int {
+		var n int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tools_fts WHERE tools_fts MATCH 'audits'").Scan(&n))
+		return n
+	}
+	require.Equal(t, 1, matches())
+
+	rebuilt, err := db.RepairFTS(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rebuilt, "indexes in step are left alone")
+
+	// Empty the index behind the triggers' back, as a failed migration might.
+	_, err = db.ExecContext(ctx, "INSERT INTO tools_fts(tools_fts) VALUES ('delete-all')")
+	require.NoError(t, err)
+	require.Equal(t, 0, matches())
+
+	rebuilt, err = db.RepairFTS(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tools_fts"}, rebuilt)
+	assert.Equal(t, 1, matches())
+
+	_, err = db.ExecContext(ctx, "INSERT INTO tools_fts(tools_fts) VALUES ('delete-all')")
+	require.NoError(t, err)
+	require.NoError(t, db.Reindex(ctx))
+	assert.Equal(t, 1, matches())
+}
@@ -7,16 +7,86 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/clawinfra/agent-tools/internal/metrics"
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"go.uber.org/zap"
 )
 
-// DB wraps a sql.DB with agent-tools-specific methods.
+var slowQueries = metrics.Default.Counter(
+	"agent_tools_slow_queries_total",
+	"SQL statements that exceeded the slow query threshold.",
+)
+
+// DB wraps a sql.DB with agent-tools-specific methods. Statements run
+// through ExecContext and transactions through WriteTx are serialized on a
+// single writer; queries run concurrently.
 type DB struct {
 	*sql.DB
+	log       *zap.Logger
+	slowQuery time.Duration
+
+	writes    chan write
+	quit      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// SetSlowQueryLog enables logging of statements slower than threshold.
+// A zero threshold disables slow query logging.
+func (db *DB) SetSlowQueryLog(threshold time.Duration, log *zap.Logger) {
+	db.slowQuery = threshold
+	db.log = log
+}
+
+// ExecContext executes a statement on the writer, logging it if it is
+// slow. Time spent waiting in the write queue does not count as slow.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := db.write(ctx, func(ctx context.Context) error {
+		defer db.observe(time.Now(), query)
+		var err error
+		res, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// QueryContext runs a query, logging it if it is slow.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer db.observe(time.Now(), query)
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a single-row query, logging it if it is slow.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer db.observe(time.Now(), query)
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (db *DB) observe(start time.Time, query string) {
+	if db.slowQuery <= 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed < db.slowQuery {
+		return
+	}
+	slowQueries.Inc()
+	if db.log != nil {
+		db.log.Warn("slow query",
+			zap.Duration("elapsed", elapsed),
+			zap.Duration("threshold", db.slowQuery),
+			zap.String("query", strings.Join(strings.Fields(query), " ")),
+		)
+	}
 }
 
 // Open opens (or creates) the SQLite database at path and runs migrations.
+// ":memory:" opens a throwaway database.
 func Open(path string) (*DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
@@ -27,22 +97,56 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
 
+	if path == ":memory:" {
+		// Every connection to :memory: opens a database of its own, so the
+		// pool is kept to one for all queries to see the same data.
+		db.SetMaxOpenConns(1)
+	}
 	if err := db.PingContext(context.Background()); err != nil {
 		return nil, fmt.Errorf("ping sqlite: %w", err)
 	}
 
-	wrapped := &DB{db}
+	wrapped := &DB{DB: db}
+	wrapped.startWriter()
 	if err := wrapped.migrate(); err != nil {
+		_ = wrapped.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
 
 	return wrapped, nil
 }
 
-// migrate runs all schema migrations idempotently.
+// migrate creates the base schema idempotently, then applies every entry of
+// migrations not yet recorded in PRAGMA user_version, each in its own
+// transaction.
 func (db *DB) migrate() error {
-	_, err := db.ExecContext(context.Background(), schema)
-	return err
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
 }
 
 const schema = `
@@ -108,4 +212,577 @@ CREATE TABLE IF NOT EXISTS invocations (
     completed_at    INTEGER,
     error           TEXT
 );
+
+CREATE INDEX IF NOT EXISTS invocations_started_at ON invocations(started_at);
+
+CREATE TABLE IF NOT EXISTS usage_daily (
+    day             TEXT NOT NULL,
+    tool_id         TEXT NOT NULL,
+    consumer_id     TEXT NOT NULL,
+    calls           INTEGER NOT NULL DEFAULT 0,
+    errors          INTEGER NOT NULL DEFAULT 0,
+    total_latency_ms INTEGER NOT NULL DEFAULT 0,
+    max_latency_ms  INTEGER NOT NULL DEFAULT 0,
+    spend_claw      REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, tool_id, consumer_id)
+);
 `
+
+// migrations alter the base schema. Append only: the position of each entry
+// is its schema version.
+var migrations = []string{
+	// 1: namespaces (multi-tenancy).
+	`
+ALTER TABLE providers ADD COLUMN namespace TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE tools ADD COLUMN namespace TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE invocations ADD COLUMN namespace TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS tools_namespace ON tools(namespace, is_active, created_at);
+
+CREATE TABLE IF NOT EXISTS namespace_members (
+    namespace   TEXT NOT NULL,
+    member_id   TEXT NOT NULL,
+    role        TEXT NOT NULL DEFAULT 'member',
+    created_at  INTEGER NOT NULL,
+    PRIMARY KEY (namespace, member_id)
+);
+`,
+	// 2: per-replica ownership of invocations for horizontal scaling.
+	`
+ALTER TABLE invocations ADD COLUMN instance_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS invocations_pending ON invocations(status, instance_id);
+`,
+	// 3: read-only mirrors of peer registries' catalogs.
+	`
+CREATE TABLE IF NOT EXISTS federated_tools (
+    origin      TEXT NOT NULL,
+    id          TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    version     TEXT NOT NULL,
+    description TEXT NOT NULL,
+    schema_json TEXT NOT NULL,
+    pricing     TEXT NOT NULL,
+    provider_id TEXT NOT NULL,
+    endpoint    TEXT NOT NULL,
+    timeout_ms  INTEGER NOT NULL,
+    tags        TEXT NOT NULL DEFAULT '',
+    created_at  INTEGER NOT NULL,
+    synced_at   INTEGER NOT NULL,
+    PRIMARY KEY (origin, id)
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS federated_tools_fts USING fts5(
+    name, description, tags,
+    content='federated_tools',
+    content_rowid='rowid'
+);
+
+CREATE TRIGGER IF NOT EXISTS federated_tools_fts_insert AFTER INSERT ON federated_tools BEGIN
+    INSERT INTO federated_tools_fts(rowid, name, description, tags)
+    VALUES (new.rowid, new.name, new.description, new.tags);
+END;
+
+CREATE TRIGGER IF NOT EXISTS federated_tools_fts_delete AFTER DELETE ON federated_tools BEGIN
+    INSERT INTO federated_tools_fts(federated_tools_fts, rowid, name, description, tags)
+    VALUES ('delete', old.rowid, old.name, old.description, old.tags);
+END;
+`,
+	// 4: operator allow/deny lists for providers.
+	`
+CREATE TABLE IF NOT EXISTS provider_rules (
+    id         TEXT PRIMARY KEY,
+    action     TEXT NOT NULL CHECK (action IN ('allow', 'deny')),
+    field      TEXT NOT NULL CHECK (field IN ('did', 'endpoint')),
+    pattern    TEXT NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    UNIQUE (action, field, pattern)
+);
+`,
+	// 5: consumer abuse flags awaiting admin review.
+	`
+CREATE TABLE IF NOT EXISTS consumer_flags (
+    id          TEXT PRIMARY KEY,
+    consumer_id TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    detail      TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cleared', 'confirmed')),
+    created_at  INTEGER NOT NULL,
+    reviewed_at INTEGER
+);
+CREATE UNIQUE INDEX IF NOT EXISTS consumer_flags_open ON consumer_flags(consumer_id, kind) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS consumer_flags_consumer ON consumer_flags(consumer_id, status);
+CREATE INDEX IF NOT EXISTS consumer_flags_status ON consumer_flags(status, created_at);
+`,
+	// 6: encrypted credentials the router sends to a tool's endpoint.
+	`
+ALTER TABLE tools ADD COLUMN endpoint_auth TEXT NOT NULL DEFAULT '';
+`,
+	// 7: signed manifest hashes pinning each tool version's content.
+	`
+ALTER TABLE tools ADD COLUMN manifest_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN manifest_signature TEXT NOT NULL DEFAULT '';
+`,
+	// 8: security advisories and revocation of tool versions.
+	`
+ALTER TABLE tools ADD COLUMN advisory_status TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN advisory_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN advisory_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN advisory_at INTEGER;
+`,
+	// 9: WebAssembly modules executed by the registry instead of an endpoint.
+	`
+CREATE TABLE IF NOT EXISTS wasm_modules (
+    digest      TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    size        INTEGER NOT NULL,
+    module      BLOB NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+`,
+	// 10: gRPC method bindings of tools registered through server reflection.
+	`
+CREATE TABLE IF NOT EXISTS grpc_bindings (
+    tool_id     TEXT PRIMARY KEY REFERENCES tools(id),
+    target      TEXT NOT NULL,
+    method      TEXT NOT NULL,
+    descriptors BLOB NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+`,
+	// 11: CIDs of tool manifests pinned to content-addressed storage.
+	`
+ALTER TABLE tools ADD COLUMN manifest_cid TEXT NOT NULL DEFAULT '';
+`,
+	// 12: result cache of deterministic tools.
+	`
+ALTER TABLE tools ADD COLUMN cache_policy TEXT NOT NULL DEFAULT '';
+ALTER TABLE invocations ADD COLUMN cached INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS result_cache (
+    tool_id     TEXT NOT NULL,
+    input_hash  TEXT NOT NULL,
+    output      BLOB NOT NULL,
+    created_at  INTEGER NOT NULL,
+    expires_at  INTEGER NOT NULL,
+    PRIMARY KEY (tool_id, input_hash)
+);
+CREATE INDEX IF NOT EXISTS result_cache_expires ON result_cache(expires_at);
+`,
+	// 13: keyset listing of tools. The namespace index covers the listing
+	// filter and order, so counting and seeking never touch table rows.
+	`
+DROP INDEX IF EXISTS tools_namespace;
+CREATE INDEX IF NOT EXISTS tools_namespace ON tools(namespace, is_active, created_at, id, advisory_status);
+CREATE INDEX IF NOT EXISTS tools_active_created ON tools(is_active, created_at, id);
+`,
+	// 14: tags as JSON arrays instead of comma-joined strings. Mirrored
+	// catalogs are dropped rather than converted; the next sync refills them.
+	`
+UPDATE tools SET tags = CASE tags WHEN '' THEN '[]' ELSE
+    '["' || replace(replace(replace(tags, '\', '\\'), '"', '\"'), ',', '","') || '"]' END;
+DELETE FROM federated_tools;
+`,
+	// 15: schema compatibility of a tool version with the one before it.
+	// compat_breaking is NULL for versions that were not checked.
+	`
+ALTER TABLE tools ADD COLUMN compat_against TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN compat_breaking INTEGER;
+`,
+	// 16: invocation inputs kept, with the consumer's consent, for replay.
+	`
+ALTER TABLE invocations ADD COLUMN input BLOB;
+`,
+	// 17: encrypted invocation payloads keyed to the consumer or provider,
+	// with a retention deadline. Inputs kept in plaintext are dropped.
+	`
+UPDATE invocations SET input = NULL;
+ALTER TABLE invocations ADD COLUMN output BLOB;
+ALTER TABLE invocations ADD COLUMN payload_owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE invocations ADD COLUMN payload_expires_at INTEGER;
+CREATE INDEX IF NOT EXISTS invocations_payload_expires ON invocations(payload_expires_at)
+    WHERE payload_expires_at IS NOT NULL;
+`,
+	// 18: provider SLAs, the samples they are checked against and the
+	// resulting compliance of each tool.
+	`
+ALTER TABLE tools ADD COLUMN sla TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN sla_compliance TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN sla_violated INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS sla_samples (
+    tool_id    TEXT NOT NULL,
+    at         INTEGER NOT NULL,
+    ok         INTEGER NOT NULL,
+    latency_ms INTEGER NOT NULL,
+    source     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sla_samples_tool ON sla_samples(tool_id, at);
+`,
+	// 19: per-consumer quotas of tools and the calls counted against them
+	// in the current window.
+	`
+ALTER TABLE tools ADD COLUMN quota TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS quota_usage (
+    tool_id      TEXT NOT NULL,
+    consumer_id  TEXT NOT NULL,
+    window_start INTEGER NOT NULL,
+    calls        INTEGER NOT NULL,
+    PRIMARY KEY (tool_id, consumer_id)
+);
+`,
+	// 20: the persistent job queue, which holds async invocations until a
+	// worker runs them and their results until they are purged.
+	`
+CREATE TABLE IF NOT EXISTS jobs (
+    id          TEXT PRIMARY KEY,
+    namespace   TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    payload     BLOB,
+    result      BLOB,
+    enqueued_at INTEGER NOT NULL,
+    started_at  INTEGER,
+    finished_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs(status, enqueued_at);
+`,
+	// 21: callbacks of async invocations and the state of their delivery.
+	`
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    invocation_id    TEXT PRIMARY KEY,
+    url              TEXT NOT NULL,
+    secret           TEXT NOT NULL,
+    body             BLOB,
+    status           TEXT NOT NULL,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER,
+    last_error       TEXT,
+    next_attempt_at  INTEGER,
+    delivered_at     INTEGER,
+    created_at       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+`,
+	// 22: fallback endpoints of tools and the endpoint that served each
+	// invocation.
+	`
+ALTER TABLE tools ADD COLUMN endpoints TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN routing TEXT NOT NULL DEFAULT '';
+ALTER TABLE invocations ADD COLUMN endpoint TEXT NOT NULL DEFAULT '';
+`,
+	// 23: retry policies of tools, the attempts each invocation took, and
+	// consumers' invocation histories.
+	`
+ALTER TABLE tools ADD COLUMN retry_policy TEXT NOT NULL DEFAULT '';
+ALTER TABLE invocations ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS invocations_consumer ON invocations(namespace, consumer_id, started_at);
+`,
+	// 24: pipelines of tools and their runs, with each run's aggregate
+	// receipt.
+	`
+CREATE TABLE IF NOT EXISTS pipelines (
+    id          TEXT PRIMARY KEY,
+    namespace   TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    owner_id    TEXT NOT NULL,
+    definition  TEXT NOT NULL,
+    created_at  INTEGER NOT NULL,
+    UNIQUE (namespace, owner_id, name)
+);
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+    id           TEXT PRIMARY KEY,
+    pipeline_id  TEXT NOT NULL,
+    namespace    TEXT NOT NULL,
+    consumer_id  TEXT NOT NULL,
+    status       TEXT NOT NULL,
+    error        TEXT NOT NULL DEFAULT '',
+    receipt      TEXT NOT NULL,
+    started_at   INTEGER NOT NULL,
+    completed_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pipeline_runs_pipeline ON pipeline_runs(pipeline_id, started_at);
+`,
+	// 25: versioned collections of tools.
+	`
+CREATE TABLE IF NOT EXISTS collections (
+    id          TEXT PRIMARY KEY,
+    namespace   TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    version     TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id    TEXT NOT NULL,
+    tool_ids    TEXT NOT NULL,
+    tags        TEXT NOT NULL,
+    created_at  INTEGER NOT NULL,
+    UNIQUE (namespace, name, version)
+);
+CREATE INDEX IF NOT EXISTS collections_name ON collections(namespace, name, created_at);
+`,
+	// 26: provider organizations and the member DIDs that manage their
+	// tools.
+	`
+CREATE TABLE IF NOT EXISTS orgs (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS org_members (
+    org_id     TEXT NOT NULL,
+    member_id  TEXT NOT NULL,
+    role       TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (org_id, member_id)
+);
+CREATE INDEX IF NOT EXISTS org_members_member ON org_members(member_id);
+`,
+	// 27: idempotency keys of invocations, unique per consumer while they
+	// are live, and the output replayed to retries.
+	`
+ALTER TABLE invocations ADD COLUMN idempotency_key TEXT;
+ALTER TABLE invocations ADD COLUMN idempotent_output BLOB;
+CREATE UNIQUE INDEX IF NOT EXISTS invocations_idempotency ON invocations(consumer_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+`,
+	// 28: priority classes of queued jobs, claimed highest first.
+	`
+ALTER TABLE jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+DROP INDEX IF EXISTS jobs_status;
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs(status, priority DESC, enqueued_at);
+`,
+	// 29: the structured error providers fail invocations with.
+	`
+ALTER TABLE invocations ADD COLUMN provider_error TEXT;
+`,
+	// 30: the streaming and batch features tools declare. JSON-RPC tools
+	// were always sent batches, so existing ones keep taking them.
+	`
+ALTER TABLE tools ADD COLUMN features TEXT NOT NULL DEFAULT '';
+UPDATE tools SET features = '["batch"]' WHERE endpoint LIKE 'jsonrpc+%';
+`,
+	// 31: opaque metadata consumers attach to invocations to correlate
+	// them.
+	`
+ALTER TABLE invocations ADD COLUMN metadata TEXT;
+`,
+	// 32: tool icons, stored once per digest, and the icon of each tool.
+	`
+CREATE TABLE IF NOT EXISTS icons (
+    digest       TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL,
+    size         INTEGER NOT NULL,
+    data         BLOB NOT NULL,
+    created_at   INTEGER NOT NULL
+);
+ALTER TABLE tools ADD COLUMN icon TEXT NOT NULL DEFAULT '';
+`,
+	// 33: the language of tool descriptions and their translations.
+	`
+ALTER TABLE tools ADD COLUMN language TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN descriptions TEXT NOT NULL DEFAULT '';
+`,
+	// 34: receipts of completed invocations, kept so consumers and
+	// providers can reconcile spend.
+	`
+CREATE TABLE IF NOT EXISTS receipts (
+    id            TEXT PRIMARY KEY,
+    invocation_id TEXT NOT NULL UNIQUE,
+    tool_id       TEXT NOT NULL,
+    consumer_id   TEXT NOT NULL,
+    provider_id   TEXT NOT NULL,
+    namespace     TEXT NOT NULL DEFAULT 'default',
+    input_hash    TEXT NOT NULL,
+    output_hash   TEXT NOT NULL DEFAULT '',
+    cost_claw     TEXT NOT NULL DEFAULT '',
+    executed_at   INTEGER NOT NULL,
+    provider_sig  TEXT NOT NULL DEFAULT '',
+    cached        INTEGER NOT NULL DEFAULT 0,
+    metadata      TEXT
+);
+CREATE INDEX IF NOT EXISTS receipts_consumer ON receipts(namespace, consumer_id, executed_at);
+CREATE INDEX IF NOT EXISTS receipts_provider ON receipts(namespace, provider_id, executed_at);
+CREATE INDEX IF NOT EXISTS receipts_tool ON receipts(tool_id, executed_at);
+`,
+	// 35: nonces providers sign to prove they hold the key they register.
+	`
+CREATE TABLE IF NOT EXISTS key_challenges (
+    challenge   TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    pubkey      TEXT NOT NULL DEFAULT '',
+    namespace   TEXT NOT NULL DEFAULT 'default',
+    expires_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS key_challenges_expires ON key_challenges(expires_at);
+`,
+	// 36: API keys standing in for a DID, with the scopes they may use.
+	// Only the SHA-256 of each secret is kept.
+	`
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           TEXT PRIMARY KEY,
+    owner_id     TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    prefix       TEXT NOT NULL,
+    secret_hash  TEXT NOT NULL UNIQUE,
+    scopes       TEXT NOT NULL,
+    created_at   INTEGER NOT NULL,
+    last_used_at INTEGER,
+    revoked_at   INTEGER
+);
+CREATE INDEX IF NOT EXISTS api_keys_owner ON api_keys(owner_id, created_at);
+`,
+	// 37: the performance history provider reputation is weighted from,
+	// seeded with the reputation providers have so far.
+	`
+CREATE TABLE IF NOT EXISTS reputation_events (
+    provider_id TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    at          INTEGER NOT NULL,
+    delta       REAL NOT NULL,
+    PRIMARY KEY (provider_id, reason, at)
+);
+INSERT INTO reputation_events (provider_id, reason, at, delta)
+SELECT id, 'initial', CAST(strftime('%s', 'now') AS INTEGER), reputation FROM providers WHERE reputation != 0;
+`,
+	// 38: API keys whose owner proved it holds its provider key, which
+	// alone carry the provider role.
+	`
+ALTER TABLE api_keys ADD COLUMN proven INTEGER NOT NULL DEFAULT 0;
+`,
+	// 39: the pricing of each line of tool versions over time, seeded with
+	// the versions registered so far, so consumers can spot price hikes.
+	`
+CREATE TABLE IF NOT EXISTS pricing_changes (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    tool_id     TEXT NOT NULL,
+    namespace   TEXT NOT NULL DEFAULT 'default',
+    provider_id TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    version     TEXT NOT NULL,
+    old_pricing TEXT,
+    new_pricing TEXT NOT NULL,
+    changed_by  TEXT NOT NULL,
+    changed_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pricing_changes_tool ON pricing_changes(namespace, provider_id, name, changed_at);
+INSERT INTO pricing_changes (tool_id, namespace, provider_id, name, version, old_pricing, new_pricing, changed_by, changed_at)
+SELECT id, namespace, provider_id, name, version, old_pricing, pricing, provider_id, created_at FROM (
+    SELECT id, namespace, provider_id, name, version, pricing, created_at, rowid AS seq,
+        LAG(pricing) OVER (PARTITION BY namespace, provider_id, name ORDER BY created_at, rowid) AS old_pricing
+    FROM tools
+) WHERE old_pricing IS NULL OR old_pricing != pricing
+ORDER BY created_at, seq;
+`,
+	// 40: price rises that only take effect after a notice period, during
+	// which the tool is charged the price it replaces.
+	`
+ALTER TABLE tools ADD COLUMN notice_pricing TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN pricing_effective_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE pricing_changes ADD COLUMN effective_at INTEGER NOT NULL DEFAULT 0;
+UPDATE pricing_changes SET effective_at = changed_at;
+`,
+	// 41: batches of receipts whose Merkle roots were anchored on chain,
+	// and the inclusion proof of each receipt in its batch.
+	`
+CREATE TABLE IF NOT EXISTS receipt_anchors (
+    id          TEXT PRIMARY KEY,
+    root        TEXT NOT NULL,
+    size        INTEGER NOT NULL,
+    tx_hash     TEXT NOT NULL,
+    anchored_at INTEGER NOT NULL
+);
+ALTER TABLE receipts ADD COLUMN anchor_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE receipts ADD COLUMN anchor_index INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE receipts ADD COLUMN anchor_path TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS receipts_anchor ON receipts(anchor_id, executed_at);
+`,
+	// 42: operator policies governing invocations, and the log of their
+	// decisions.
+	`
+CREATE TABLE IF NOT EXISTS policies (
+    id           TEXT PRIMARY KEY,
+    name         TEXT NOT NULL UNIQUE,
+    applies_when TEXT NOT NULL DEFAULT '',
+    require      TEXT NOT NULL,
+    reason       TEXT NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS policy_decisions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    namespace   TEXT NOT NULL,
+    consumer_id TEXT NOT NULL,
+    tool_id     TEXT NOT NULL,
+    allowed     INTEGER NOT NULL,
+    policies    TEXT NOT NULL,
+    denied_by   TEXT NOT NULL DEFAULT '',
+    reason      TEXT NOT NULL DEFAULT '',
+    decided_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS policy_decisions_decided_at ON policy_decisions(decided_at);
+CREATE INDEX IF NOT EXISTS policy_decisions_consumer ON policy_decisions(consumer_id, decided_at);
+`,
+	// 43: nonces of signed registrations and invocations, kept until their
+	// signatures are stale, so they cannot be replayed.
+	`
+CREATE TABLE IF NOT EXISTS seen_nonces (
+    signer     TEXT NOT NULL,
+    nonce      TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (signer, nonce)
+);
+CREATE INDEX IF NOT EXISTS seen_nonces_expires_at ON seen_nonces(expires_at);
+`,
+	// 44: the attestation quotes providers in trusted execution
+	// environments attach to their receipts.
+	`
+ALTER TABLE receipts ADD COLUMN attestation TEXT NOT NULL DEFAULT '';
+`,
+	// 45: the mock responders tools may be registered with, and the
+	// invocations and receipts they answered.
+	`
+ALTER TABLE tools ADD COLUMN mock TEXT NOT NULL DEFAULT '';
+ALTER TABLE invocations ADD COLUMN mock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE receipts ADD COLUMN mock INTEGER NOT NULL DEFAULT 0;
+`,
+	// 46: the conformance tests tools may be registered with, and the
+	// results of their last run.
+	`
+ALTER TABLE tools ADD COLUMN tests TEXT NOT NULL DEFAULT '';
+ALTER TABLE tools ADD COLUMN conformance TEXT NOT NULL DEFAULT '';
+`,
+	// 47: the costs of paid invocations held in their consumers' escrow
+	// accounts until they are released to providers or refunded.
+	`
+CREATE TABLE IF NOT EXISTS escrows (
+    id            TEXT PRIMARY KEY,
+    invocation_id TEXT NOT NULL UNIQUE,
+    consumer_id   TEXT NOT NULL,
+    amount_claw   TEXT NOT NULL,
+    status        TEXT NOT NULL,
+    settled_claw  TEXT NOT NULL DEFAULT '',
+    tx_hash       TEXT NOT NULL DEFAULT '',
+    created_at    INTEGER NOT NULL,
+    settled_at    INTEGER
+);
+CREATE INDEX IF NOT EXISTS escrows_consumer_status ON escrows(consumer_id, status);
+CREATE INDEX IF NOT EXISTS escrows_status_created_at ON escrows(status, created_at);
+`,
+	// 48: the failed attempts to release escrows, and withdrawals from
+	// escrow accounts, reserved while they are sent.
+	`
+ALTER TABLE escrows ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS withdrawals (
+    id          TEXT PRIMARY KEY,
+    consumer_id TEXT NOT NULL,
+    amount_claw TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    tx_hash     TEXT NOT NULL DEFAULT '',
+    created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS withdrawals_consumer_status ON withdrawals(consumer_id, status);
+`,
+	// 49: settings changed while serving that every replica sharing the
+	// database follows, such as the maintenance mode.
+	`
+CREATE TABLE IF NOT EXISTS settings (
+    name       TEXT PRIMARY KEY,
+    value      TEXT NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+`,
+}
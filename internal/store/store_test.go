@@ -1,12 +1,20 @@
 package store_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/clawinfra/agent-tools/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestOpen_InMemory(t *testing.T) {
@@ -16,6 +24,14 @@ func TestOpen_InMemory(t *testing.T) {
 	assert.NoError(t, db.Close())
 }
 
+func TestOpen_InMemorySingleConnection(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// A second connection would open an empty database without the schema.
+	assert.Equal(t, 1, db.Stats().MaxOpenConnections)
+}
+
 func TestOpen_FileDB(t *testing.T) {
 	path := t.TempDir() + "/test.db"
 	db, err := store.Open(path)
@@ -46,3 +62,139 @@ func TestOpen_IdempotentMigration(t *testing.T) {
 	require.NoError(t, err)
 	assert.NoError(t, db2.Close())
 }
+
+func TestSlowQueryLog(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	db.SetSlowQueryLog(time.Nanosecond, zap.New(core))
+
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT  1").Scan(&n))
+
+	entries := logs.FilterMessage("slow query").All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "SELECT 1", entries[1].ContextMap()["query"])
+}
+
+func TestSlowQueryLog_Disabled(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	db.SetSlowQueryLog(0, zap.New(core))
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.Zero(t, logs.Len())
+}
+
+func TestOpen_RecordsSchemaVersion(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	db, err := store.Open(path)
+	require.NoError(t, err)
+	var v1 int
+	require.NoError(t, db.QueryRowContext(context.Background(), "PRAGMA user_version").Scan(&v1))
+	assert.Positive(t, v1)
+	require.NoError(t, db.Close())
+
+	db, err = store.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	var v2 int
+	require.NoError(t, db.QueryRowContext(context.Background(), "PRAGMA user_version").Scan(&v2))
+	assert.Equal(t, v1, v2)
+}
+
+func TestWriteQueue_ConcurrentWriters(t *testing.T) {
+	// A file database, so writers share one SQLite write lock.
+	db, err := store.Open(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE counter (n INTEGER); INSERT INTO counter VALUES (0); CREATE TABLE rows (n INTEGER)")
+	require.NoError(t, err)
+
+	const writers = 500
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, err := db.ExecContext(ctx, "INSERT INTO rows VALUES (?)", i)
+				errs <- err
+				return
+			}
+			// Read-modify-write: unserialized, transactions would lose
+			// updates or fail to upgrade their read lock.
+			errs <- db.WriteTx(ctx, func(tx *sql.Tx) error {
+				var n int
+				if err := tx.QueryRowContext(ctx, "SELECT n FROM counter").Scan(&n); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "UPDATE counter SET n = ?", n+1)
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var n, rows int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n FROM counter").Scan(&n))
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rows").Scan(&rows))
+	assert.Equal(t, writers/2, n)
+	assert.Equal(t, writers/2, rows)
+}
+
+func TestWriteTx_RollsBack(t *testing.T) {
+	db, err := store.Open(t.TempDir() + "/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE rows (n INTEGER)")
+	require.NoError(t, err)
+
+	boom := errors.New("boom")
+	err = db.WriteTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO rows VALUES (1)")
+		require.NoError(t, err)
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	var rows int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rows").Scan(&rows))
+	assert.Zero(t, rows)
+}
+
+func TestWriteQueue_Closed(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = db.ExecContext(context.Background(), "CREATE TABLE rows (n INTEGER)")
+	assert.ErrorIs(t, err, store.ErrClosed)
+	assert.NoError(t, db.Close(), "closing twice is harmless")
+}
+
+func TestWriteQueue_CanceledContext(t *testing.T) {
+	db, err := store.Open(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.ExecContext(ctx, "CREATE TABLE rows (n INTEGER)")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/clawinfra/agent-tools/internal/metrics"
+)
+
+// ErrClosed is returned for writes submitted to, or still queued in, a
+// database that has been closed.
+var ErrClosed = errors.New("database closed")
+
+// writeQueue is how many writes may wait for the writer. Further writers
+// block until the queue drains or their context ends, which pushes back on
+// callers instead of letting connections pile up on the SQLite write lock.
+const writeQueue = 512
+
+var writeQueueFull = metrics.Default.Counter(
+	"agent_tools_write_queue_full_total",
+	"Writes that found the SQLite write queue full and waited for room.",
+)
+
+// write is a unit of work for the writer goroutine.
+type write struct {
+	ctx  context.Context
+	fn   func(ctx context.Context) error
+	done chan error
+}
+
+// startWriter starts the goroutine every write goes through. SQLite allows
+// one writer at a time; queueing writes here, rather than letting pooled
+// connections race for the lock, keeps concurrent registrations and
+// invocation records from failing with SQLITE_BUSY.
+func (db *DB) startWriter() {
+	db.writes = make(chan write, writeQueue)
+	db.quit = make(chan struct{})
+	db.stopped = make(chan struct{})
+	go db.runWriter()
+}
+
+func (db *DB) runWriter() {
+	defer close(db.stopped)
+	for {
+		select {
+		case <-db.quit:
+			return
+		case w := <-db.writes:
+			if err := w.ctx.Err(); err != nil {
+				w.done <- err
+				continue
+			}
+			w.done <- w.fn(w.ctx)
+		}
+	}
+}
+
+// write runs fn on the writer and returns its error. It waits for room in
+// the queue while ctx allows; once queued, fn runs with ctx.
+func (db *DB) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	w := write{ctx: ctx, fn: fn, done: make(chan error, 1)}
+	select {
+	case db.writes <- w:
+	default:
+		writeQueueFull.Inc()
+		select {
+		case db.writes <- w:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-db.stopped:
+			return ErrClosed
+		}
+	}
+	select {
+	case err := <-w.done:
+		return err
+	case <-db.stopped:
+		// The writer may have finished w just before stopping.
+		select {
+		case err := <-w.done:
+			return err
+		default:
+			return ErrClosed
+		}
+	}
+}
+
+// WriteTx runs fn in a transaction on the writer, committing if it returns
+// nil and rolling back otherwise. fn must write through tx only: calling
+// ExecContext of the database from fn would wait on the writer running it.
+func (db *DB) WriteTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return db.write(ctx, func(ctx context.Context) error {
+		tx, err := db.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin: %w", err)
+		}
+		if err := fn(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Close stops the writer, failing writes still queued with ErrClosed, and
+// closes the database.
+func (db *DB) Close() error {
+	db.closeOnce.Do(func() { close(db.quit) })
+	<-db.stopped
+	return db.DB.Close()
+}
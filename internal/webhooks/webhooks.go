@@ -0,0 +1,386 @@
+// Package webhooks delivers the outcome of async invocations to callback
+// URLs chosen by their consumers. Each delivery is signed with a secret the
+// consumer supplied and retried with exponential backoff until the callback
+// accepts it. Deliveries are kept in the registry database, so those still
+// pending survive a restart and can be sent by any replica sharing it.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned for an invocation without a callback.
+var ErrNotFound = errors.New("webhook delivery not found")
+
+// Delivery states.
+const (
+	// Waiting deliveries belong to invocations that have not finished.
+	Waiting   = "waiting"
+	Pending   = "pending"
+	Delivered = "delivered"
+	// Failed deliveries were given up on after Config.Attempts tries.
+	Failed = "failed"
+)
+
+// Headers of a delivery. The signature is "sha256=" and the hex HMAC-SHA256,
+// keyed with the consumer's secret, of the timestamp, a period and the body.
+const (
+	SignatureHeader    = "X-Webhook-Signature"
+	TimestampHeader    = "X-Webhook-Timestamp"
+	InvocationIDHeader = "X-Webhook-Invocation-Id"
+)
+
+// pollInterval is how often an idle worker looks for deliveries that became
+// due; deliveries made ready on its own replica wake it at once.
+const pollInterval = time.Second
+
+// maxErrorBytes is how much of a failed callback's response is kept.
+const maxErrorBytes = 256
+
+// Config tunes delivery.
+type Config struct {
+	// Attempts is how many times a delivery is tried before it fails.
+	Attempts int
+	// Backoff is the wait before the first retry; each further retry
+	// waits twice as long as the one before, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// Retention is how long finished deliveries are kept.
+	Retention time.Duration
+}
+
+// DefaultConfig is used by the serve command unless overridden by flags.
+var DefaultConfig = Config{
+	Attempts:   8,
+	Backoff:    10 * time.Second,
+	MaxBackoff: time.Hour,
+	Timeout:    10 * time.Second,
+	Retention:  24 * time.Hour,
+}
+
+// Delivery is the state of the callback of one invocation.
+type Delivery struct {
+	InvocationID   string     `json:"invocation_id"`
+	URL            string     `json:"url"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Dispatcher registers callbacks and delivers them.
+type Dispatcher struct {
+	db     *store.DB
+	log    *zap.Logger
+	client *http.Client
+	box    *secrets.Box
+	cfg    atomic.Pointer[Config]
+	wake   chan struct{}
+}
+
+// New returns a dispatcher keeping deliveries in db, sealing callback
+// secrets with box and sending deliveries with client. Zero fields of cfg
+// default to those of DefaultConfig.
+func New(db *store.DB, log *zap.Logger, client *http.Client, box *secrets.Box, cfg Config) *Dispatcher {
+	d := &Dispatcher{db: db, log: log, client: client, box: box, wake: make(chan struct{}, 1)}
+	d.SetConfig(cfg)
+	return d
+}
+
+// SetConfig replaces the configuration of a running dispatcher; zero fields
+// default as in New. Deliveries already scheduled keep their next attempt.
+func (d *Dispatcher) SetConfig(cfg Config) {
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = DefaultConfig.Attempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultConfig.Backoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	d.cfg.Store(&cfg)
+}
+
+// Config returns the configuration in use.
+func (d *Dispatcher) Config() Config {
+	return *d.cfg.Load()
+}
+
+// Sign returns the signature of a delivery of body at timestamp, as sent in
+// SignatureHeader. Receivers recompute it to authenticate deliveries.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Register records that the outcome of invocationID is to be POSTed to url,
+// signed with secret, once Deliver is called.
+func (d *Dispatcher) Register(ctx context.Context, invocationID, url string, secret []byte) error {
+	sealed, err := d.box.Seal(secret, []byte(invocationID))
+	if err != nil {
+		return fmt.Errorf("seal callback secret: %w", err)
+	}
+	if _, err := d.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (invocation_id, url, secret, status, created_at) VALUES (?, ?, ?, ?, ?)
+	`, invocationID, url, sealed, Waiting, time.Now().Unix()); err != nil {
+		return fmt.Errorf("register callback: %w", err)
+	}
+	return nil
+}
+
+// Deliver queues body for delivery to the callback of invocationID. It
+// does nothing for an invocation without a callback.
+func (d *Dispatcher) Deliver(ctx context.Context, invocationID string, body []byte) error {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, body = ?, next_attempt_at = ? WHERE invocation_id = ? AND status = ?
+	`, Pending, body, time.Now().Unix(), invocationID, Waiting)
+	if err != nil {
+		return fmt.Errorf("queue delivery: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Get returns the delivery of the callback of invocationID.
+func (d *Dispatcher) Get(ctx context.Context, invocationID string) (*Delivery, error) {
+	var (
+		dl              Delivery
+		code            sql.NullInt64
+		lastErr         sql.NullString
+		next, delivered sql.NullInt64
+		createdAt       int64
+	)
+	err := d.db.QueryRowContext(ctx, `
+		SELECT invocation_id, url, status, attempts, last_status_code, last_error, next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries WHERE invocation_id = ?
+	`, invocationID).Scan(&dl.InvocationID, &dl.URL, &dl.Status, &dl.Attempts, &code, &lastErr, &next, &delivered, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get delivery: %w", err)
+	}
+	dl.LastStatusCode, dl.LastError = int(code.Int64), lastErr.String
+	if dl.Status == Pending {
+		dl.NextAttemptAt = timeOf(next)
+	}
+	dl.DeliveredAt = timeOf(delivered)
+	dl.CreatedAt = time.Unix(createdAt, 0).UTC()
+	return &dl, nil
+}
+
+func timeOf(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0).UTC()
+	return &t
+}
+
+// Run sends due deliveries on workers goroutines until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil && d.sendOne(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// pending is a claimed delivery.
+type pending struct {
+	invocationID, url, secret string
+	body                      []byte
+	attempts                  int
+}
+
+// sendOne claims the delivery that has been due longest and tries it. It
+// reports whether it did, so the worker moves straight on to the next one.
+func (d *Dispatcher) sendOne(ctx context.Context) bool {
+	p, err := d.claim(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Warn("claim delivery", zap.Error(err))
+		}
+		return false
+	}
+	if p == nil {
+		return false
+	}
+	// An attempt under way is finished even when the server shuts down.
+	ctx = context.WithoutCancel(ctx)
+	code, err := d.send(ctx, p)
+	p.attempts++
+	now := time.Now()
+	if err == nil {
+		_, err = d.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = NULL,
+				body = NULL, secret = '', delivered_at = ?
+			WHERE invocation_id = ?
+		`, Delivered, p.attempts, code, now.Unix(), p.invocationID)
+		if err != nil {
+			d.log.Error("finish delivery", zap.String("invocation_id", p.invocationID), zap.Error(err))
+		}
+		return true
+	}
+	status, next := Pending, now.Add(d.backoff(p.attempts))
+	if p.attempts >= d.cfg.Load().Attempts {
+		status, next = Failed, now
+		d.log.Warn("delivery failed", zap.String("invocation_id", p.invocationID), zap.Int("attempts", p.attempts), zap.Error(err))
+	}
+	_, uerr := d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?
+		WHERE invocation_id = ?
+	`, status, p.attempts, sql.NullInt64{Int64: int64(code), Valid: code != 0}, err.Error(), next.Unix(), p.invocationID)
+	if uerr != nil {
+		d.log.Error("retry delivery", zap.String("invocation_id", p.invocationID), zap.Error(uerr))
+	}
+	return true
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	cfg := d.cfg.Load()
+	wait := cfg.Backoff
+	for i := 1; i < attempts && wait < cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, cfg.MaxBackoff)
+}
+
+// claim returns the delivery that has been due longest, or nil when none
+// is. It leases the delivery by pushing its next attempt past the attempt
+// timeout, so no other worker claims it and a worker that dies mid-attempt
+// only delays it.
+func (d *Dispatcher) claim(ctx context.Context) (*pending, error) {
+	var p *pending
+	err := d.db.WriteTx(ctx, func(tx *sql.Tx) error {
+		var c pending
+		now := time.Now()
+		err := tx.QueryRowContext(ctx, `
+			SELECT invocation_id, url, secret, body, attempts FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT 1
+		`, Pending, now.Unix()).Scan(&c.invocationID, &c.url, &c.secret, &c.body, &c.attempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lease := now.Add(2 * d.cfg.Load().Timeout).Unix()
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE invocation_id = ?", lease, c.invocationID); err != nil {
+			return err
+		}
+		p = &c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claim delivery: %w", err)
+	}
+	return p, nil
+}
+
+// send POSTs a delivery and returns the callback's status code. Any status
+// but 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, p *pending) (int, error) {
+	secret, err := d.box.Open(p.secret, []byte(p.invocationID))
+	if err != nil {
+		return 0, fmt.Errorf("open callback secret: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Load().Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, ts, p.body))
+	req.Header.Set(InvocationIDHeader, p.invocationID)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+		return resp.StatusCode, fmt.Errorf("callback answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// Purge deletes deliveries that were delivered or failed more than the
+// retention ago, and callbacks of invocations that never finished. It is
+// intended to be run periodically by the serve command.
+func (d *Dispatcher) Purge(ctx context.Context) error {
+	retention := d.cfg.Load().Retention
+	if retention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-retention).Unix()
+	res, err := d.db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status IN (?, ?, ?) AND COALESCE(delivered_at, next_attempt_at, created_at) < ?
+	`, Delivered, Failed, Waiting, cutoff)
+	if err != nil {
+		return fmt.Errorf("purge deliveries: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		d.log.Info("webhook deliveries purged", zap.Int64("count", n))
+	}
+	return nil
+}
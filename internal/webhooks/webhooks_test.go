@@ -0,0 +1,152 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"github.com/clawinfra/agent-tools/internal/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newDispatcher(t *testing.T, cfg webhooks.Config) *webhooks.Dispatcher {
+	t.Helper()
+	// Workers claim deliveries on their own connections, so the store must
+	// be one database across connections, which :memory: is not.
+	db, err := store.Open(filepath.Join(t.TempDir(), "hooks.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	box, err := secrets.New(make([]byte, secrets.KeySize))
+	require.NoError(t, err)
+	d := webhooks.New(db, zaptest.NewLogger(t), http.DefaultClient, box, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx, 2)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d
+}
+
+func waitStatus(t *testing.T, d *webhooks.Dispatcher, id, status string) *webhooks.Delivery {
+	t.Helper()
+	var dl *webhooks.Delivery
+	require.Eventually(t, func() bool {
+		var err error
+		dl, err = d.Get(context.Background(), id)
+		require.NoError(t, err)
+		return dl.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return dl
+}
+
+func TestDispatcher_DeliversSigned(t *testing.T) {
+	type delivery struct {
+		body, sig, ts, id string
+	}
+	got := make(chan delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- delivery{string(body), r.Header.Get(webhooks.SignatureHeader), r.Header.Get(webhooks.TimestampHeader),
+			r.Header.Get(webhooks.InvocationIDHeader)}
+	}))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	d := newDispatcher(t, webhooks.Config{})
+
+	require.NoError(t, d.Register(ctx, "inv_1", srv.URL, []byte("s3cret")))
+	dl, err := d.Get(ctx, "inv_1")
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.Waiting, dl.Status)
+	_, err = d.Get(ctx, "inv_2")
+	assert.ErrorIs(t, err, webhooks.ErrNotFound)
+	require.NoError(t, d.Deliver(ctx, "inv_2", []byte(`{}`)), "no callback, nothing to do")
+
+	require.NoError(t, d.Deliver(ctx, "inv_1", []byte(`{"invocation_id":"inv_1"}`)))
+	var del delivery
+	select {
+	case del = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not delivered")
+	}
+	assert.Equal(t, `{"invocation_id":"inv_1"}`, del.body)
+	assert.Equal(t, "inv_1", del.id)
+	ts, err := strconv.ParseInt(del.ts, 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.Sign([]byte("s3cret"), ts, []byte(del.body)), del.sig)
+	assert.NotEqual(t, webhooks.Sign([]byte("other"), ts, []byte(del.body)), del.sig)
+
+	dl = waitStatus(t, d, "inv_1", webhooks.Delivered)
+	assert.Equal(t, 1, dl.Attempts)
+	assert.Equal(t, http.StatusOK, dl.LastStatusCode)
+	assert.NotNil(t, dl.DeliveredAt)
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" || calls.Add(1) < 3 {
+			http.Error(w, "not yet", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	d := newDispatcher(t, webhooks.Config{Attempts: 4, Backoff: time.Millisecond})
+
+	require.NoError(t, d.Register(ctx, "inv_flaky", srv.URL+"/flaky", []byte("k")))
+	require.NoError(t, d.Register(ctx, "inv_down", srv.URL+"/down", []byte("k")))
+	require.NoError(t, d.Deliver(ctx, "inv_flaky", []byte(`{}`)))
+	require.NoError(t, d.Deliver(ctx, "inv_down", []byte(`{}`)))
+
+	dl := waitStatus(t, d, "inv_flaky", webhooks.Delivered)
+	assert.Equal(t, 3, dl.Attempts)
+	assert.Empty(t, dl.LastError)
+
+	dl = waitStatus(t, d, "inv_down", webhooks.Failed)
+	assert.Equal(t, 4, dl.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, dl.LastStatusCode)
+	assert.Contains(t, dl.LastError, "not yet")
+	assert.Nil(t, dl.NextAttemptAt)
+
+	d.SetConfig(webhooks.Config{Attempts: 2, Backoff: time.Millisecond})
+	assert.Equal(t, webhooks.DefaultConfig.Timeout, d.Config().Timeout, "zero fields default")
+	require.NoError(t, d.Register(ctx, "inv_down2", srv.URL+"/down", []byte("k")))
+	require.NoError(t, d.Deliver(ctx, "inv_down2", []byte(`{}`)))
+	dl = waitStatus(t, d, "inv_down2", webhooks.Failed)
+	assert.Equal(t, 2, dl.Attempts, "a new config applies to later deliveries")
+}
+
+func TestDispatcher_Purge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	d := newDispatcher(t, webhooks.Config{Retention: time.Millisecond})
+
+	require.NoError(t, d.Register(ctx, "inv_1", srv.URL, []byte("k")))
+	require.NoError(t, d.Deliver(ctx, "inv_1", []byte(`{}`)))
+	waitStatus(t, d, "inv_1", webhooks.Delivered)
+
+	// Times are kept in seconds, so the delivery is past the retention
+	// once the clock has ticked over.
+	require.Eventually(t, func() bool {
+		require.NoError(t, d.Purge(ctx))
+		_, err := d.Get(ctx, "inv_1")
+		return errors.Is(err, webhooks.ErrNotFound)
+	}, 5*time.Second, 50*time.Millisecond)
+}
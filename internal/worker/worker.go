@@ -0,0 +1,33 @@
+// Package worker runs periodic background jobs for the registry server.
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job is a unit of background work.
+type Job func(ctx context.Context) error
+
+// Periodic runs job every interval until ctx is cancelled. Errors are logged
+// and do not stop the loop. The first run happens immediately.
+func Periodic(ctx context.Context, log *zap.Logger, name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("background job failed", zap.String("job", name), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
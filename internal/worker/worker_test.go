@@ -0,0 +1,66 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPeriodic_RunsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		worker.Periodic(ctx, zap.NewNop(), "test", time.Millisecond, func(context.Context) error {
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return nil
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Periodic did not stop after cancel")
+	}
+	assert.GreaterOrEqual(t, runs.Load(), int32(3))
+}
+
+func TestPeriodic_LogsErrors(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	worker.Periodic(ctx, zap.New(core), "failing", time.Millisecond, func(context.Context) error {
+		cancel()
+		return errors.New("boom")
+	})
+	assert.Equal(t, 0, logs.Len(), "errors after cancellation are not logged")
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	var n atomic.Int32
+	worker.Periodic(ctx2, zap.New(core), "failing", time.Millisecond, func(context.Context) error {
+		if n.Add(1) == 2 {
+			cancel2()
+		}
+		return errors.New("boom")
+	})
+	assert.Equal(t, 1, logs.FilterMessage("background job failed").Len())
+}
+
+func TestPeriodic_ZeroIntervalDisabled(t *testing.T) {
+	called := false
+	worker.Periodic(context.Background(), zap.NewNop(), "off", 0, func(context.Context) error {
+		called = true
+		return nil
+	})
+	assert.False(t, called)
+}
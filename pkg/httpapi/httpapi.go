@@ -0,0 +1,72 @@
+// Package httpapi serves an embedded registry over the agent-tools REST API,
+// the same API as the agent-tools server.
+package httpapi
+
+import (
+	"crypto/ed25519"
+
+	"github.com/clawinfra/agent-tools/internal/api"
+	"github.com/clawinfra/agent-tools/pkg/registry"
+	"go.uber.org/zap"
+)
+
+// Handler is an http.Handler serving the REST API. Call Drain before shutting
+// down to let in-flight invocations finish.
+type Handler = api.Handler
+
+// Option configures NewHandler.
+type Option func(*options)
+
+type options struct {
+	log *zap.Logger
+	api []api.Option
+}
+
+// WithLogger sets the logger used for errors and, unless disabled, access
+// logs. The default discards all output.
+func WithLogger(log *zap.Logger) Option {
+	return func(o *options) { o.log = log }
+}
+
+// WithAccessLog enables or disables per-request access logging.
+func WithAccessLog(enabled bool) Option {
+	return func(o *options) { o.api = append(o.api, api.WithAccessLog(enabled)) }
+}
+
+// WithBasePath serves every route under prefix, e.g. when mounting the API
+// at /tools inside the agent's own router without stripping the prefix.
+func WithBasePath(prefix string) Option {
+	return func(o *options) { o.api = append(o.api, api.WithBasePath(prefix)) }
+}
+
+// AnonymousPolicy controls what callers without an Authorization header may
+// do; see the serve command's --anonymous flags.
+type AnonymousPolicy = api.AnonymousPolicy
+
+// Anonymous access modes.
+const (
+	AnonymousOff      = api.AnonymousOff
+	AnonymousReadOnly = api.AnonymousReadOnly
+	AnonymousFull     = api.AnonymousFull
+)
+
+// WithAnonymous sets the anonymous policy. The default is read-only with a
+// per-IP rate limit.
+func WithAnonymous(p AnonymousPolicy) Option {
+	return func(o *options) { o.api = append(o.api, api.WithAnonymous(p)) }
+}
+
+// WithRegistryKey signs the calls proxied to providers with key, which
+// providers fetch from GET /.well-known/registry-key to verify them.
+func WithRegistryKey(key ed25519.PrivateKey) Option {
+	return func(o *options) { o.api = append(o.api, api.WithRegistryKey(key)) }
+}
+
+// NewHandler returns a handler serving reg.
+func NewHandler(reg *registry.Registry, opts ...Option) *Handler {
+	o := &options{log: zap.NewNop()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return api.NewHandler(reg.Registry, o.log, o.api...)
+}
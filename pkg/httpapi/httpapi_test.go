@@ -0,0 +1,52 @@
+package httpapi_test
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/pkg/httpapi"
+	"github.com/clawinfra/agent-tools/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewHandler_ServesAPI(t *testing.T) {
+	reg, err := registry.Open(":memory:")
+	require.NoError(t, err)
+	defer func() { _ = reg.Close() }()
+
+	h := httpapi.NewHandler(reg, httpapi.WithAccessLog(false))
+	for _, path := range []string{"/healthz", "/v1/tools", "/v1/tools/search?q=x"} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+	}
+}
+
+func TestNewHandler_Options(t *testing.T) {
+	reg, err := registry.Open(":memory:")
+	require.NoError(t, err)
+	defer func() { _ = reg.Close() }()
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	h := httpapi.NewHandler(reg,
+		httpapi.WithLogger(zaptest.NewLogger(t)),
+		httpapi.WithBasePath("/tools"),
+		httpapi.WithAnonymous(httpapi.AnonymousPolicy{Mode: httpapi.AnonymousOff}),
+		httpapi.WithRegistryKey(key),
+	)
+	for path, want := range map[string]int{
+		"/tools/healthz":                  http.StatusOK,
+		"/tools/.well-known/registry-key": http.StatusOK,
+		"/tools/v1/tools":                 http.StatusUnauthorized,
+		"/v1/tools":                       http.StatusNotFound,
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		assert.Equal(t, want, rr.Code, path)
+	}
+}
@@ -0,0 +1,114 @@
+package receipts
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CallSignatureHeader carries the registry's CallSignature, encoded by
+// EncodeCallSignature, on the calls it proxies to providers.
+const CallSignatureHeader = "X-Registry-Signature"
+
+// ErrInvalidCallSignature is returned for a call signature that is
+// malformed, stale, does not verify or is not by the registry's key.
+var ErrInvalidCallSignature = errors.New("invalid call signature")
+
+// CallSignature is the registry's signature of a call it sends a provider:
+// which invocation, of which tool, by which consumer and with what input.
+// Providers verify it against the registry's published key to know the call
+// came through the registry, which bills the consumer for it.
+type CallSignature struct {
+	InvocationID string    `json:"invocation_id"`
+	ToolID       string    `json:"tool_id"`
+	ConsumerID   string    `json:"consumer_id"`
+	InputHash    string    `json:"input_hash"`
+	SignedAt     time.Time `json:"signed_at"`
+	// RegistryKey is the registry's key, "ed25519:<hex>".
+	RegistryKey string `json:"registry_key"`
+	// Signature is the signature of CallPayload, "ed25519:<base64>".
+	Signature string `json:"signature"`
+}
+
+// CallPayload returns what the registry signs for s: the canonical JSON
+// object of every field but the signature.
+func CallPayload(s *CallSignature) []byte {
+	b, _ := json.Marshal(struct {
+		ConsumerID   string `json:"consumer_id"`
+		InputHash    string `json:"input_hash"`
+		InvocationID string `json:"invocation_id"`
+		RegistryKey  string `json:"registry_key"`
+		SignedAt     string `json:"signed_at"`
+		ToolID       string `json:"tool_id"`
+	}{s.ConsumerID, s.InputHash, s.InvocationID, s.RegistryKey, s.SignedAt.UTC().Format(time.RFC3339), s.ToolID})
+	c, _ := Canonicalize(b)
+	return c
+}
+
+// SignCall returns the signature by the registry's key of invocation
+// invocationID of toolID by consumerID with input, which may be a decoded
+// value or json.RawMessage, made at now.
+func SignCall(invocationID, toolID, consumerID string, input any, now time.Time, key ed25519.PrivateKey) *CallSignature {
+	s := &CallSignature{
+		InvocationID: invocationID,
+		ToolID:       toolID,
+		ConsumerID:   consumerID,
+		InputHash:    Hash(input),
+		SignedAt:     now.UTC().Truncate(time.Second),
+		RegistryKey:  SigPrefix + hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	s.Signature = SigPrefix + base64.StdEncoding.EncodeToString(ed25519.Sign(key, CallPayload(s)))
+	return s
+}
+
+// VerifyCall checks that s is signed by registryKey, within maxSkew of now,
+// and that input, unless nil, is what was signed.
+func VerifyCall(s *CallSignature, input any, registryKey ed25519.PublicKey, now time.Time, maxSkew time.Duration) error {
+	key, err := ParsePubKey(s.RegistryKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCallSignature, err)
+	}
+	if !key.Equal(registryKey) {
+		return fmt.Errorf("%w: registry_key is not the registry's key", ErrInvalidCallSignature)
+	}
+	sig, ok := strings.CutPrefix(s.Signature, SigPrefix)
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if !ok || err != nil {
+		return fmt.Errorf("%w: signature must be ed25519:<base64>", ErrInvalidCallSignature)
+	}
+	if !ed25519.Verify(key, CallPayload(s), raw) {
+		return fmt.Errorf("%w: signature does not verify against registry_key", ErrInvalidCallSignature)
+	}
+	if d := now.Sub(s.SignedAt); d > maxSkew || d < -maxSkew {
+		return fmt.Errorf("%w: signed_at is more than %s away", ErrInvalidCallSignature, maxSkew)
+	}
+	if input != nil && Hash(input) != s.InputHash {
+		return fmt.Errorf("%w: input_hash is not the hash of the input", ErrInvalidCallSignature)
+	}
+	return nil
+}
+
+// EncodeCallSignature returns s in the form CallSignatureHeader carries:
+// the base64url of its JSON.
+func EncodeCallSignature(s *CallSignature) string {
+	b, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCallSignature reads a CallSignatureHeader.
+func DecodeCallSignature(h string) (*CallSignature, error) {
+	b, err := base64.RawURLEncoding.DecodeString(h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header must be base64url JSON", ErrInvalidCallSignature)
+	}
+	var s CallSignature
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCallSignature, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,63 @@
+package receipts_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignCall(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	now := time.Now()
+	input := map[string]any{"b": 2, "a": 1}
+	s := receipts.SignCall("inv_1", "tool-1", "did:key:z6Mk", input, now, key)
+	require.NoError(t, receipts.VerifyCall(s, input, pub, now, time.Minute))
+	require.NoError(t, receipts.VerifyCall(s, json.RawMessage(`{"a":1,"b":2}`), pub, now, time.Minute))
+
+	decoded, err := receipts.DecodeCallSignature(receipts.EncodeCallSignature(s))
+	require.NoError(t, err)
+	require.NoError(t, receipts.VerifyCall(decoded, nil, pub, now, time.Minute), "the header form verifies")
+
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, receipts.VerifyCall(s, nil, other, now, time.Minute), receipts.ErrInvalidCallSignature,
+		"not the registry's key")
+	assert.ErrorIs(t, receipts.VerifyCall(s, nil, pub, now.Add(time.Hour), time.Minute), receipts.ErrInvalidCallSignature,
+		"stale")
+	assert.ErrorIs(t, receipts.VerifyCall(s, map[string]any{"a": 2}, pub, now, time.Minute), receipts.ErrInvalidCallSignature)
+	tampered := *s
+	tampered.InvocationID = "inv_2"
+	assert.ErrorIs(t, receipts.VerifyCall(&tampered, nil, pub, now, time.Minute), receipts.ErrInvalidCallSignature)
+
+	_, err = receipts.DecodeCallSignature("!!")
+	assert.ErrorIs(t, err, receipts.ErrInvalidCallSignature)
+}
+
+func TestSignResponse(t *testing.T) {
+	registryPub, registryKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	providerPub, providerKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	input, output := map[string]any{"a": 1}, map[string]any{"b": 2}
+	c := receipts.SignCall("inv_1", "tool-1", "did:key:z6Mk", input, time.Now(), registryKey)
+	require.NoError(t, receipts.VerifyCall(c, input, registryPub, time.Now(), time.Minute))
+
+	r := &receipts.Receipt{
+		InvocationID: "inv_1",
+		ToolID:       "tool-1",
+		ConsumerID:   "did:key:z6Mk",
+		InputHash:    receipts.Hash(input),
+		OutputHash:   receipts.Hash(output),
+		CostCLAW:     "0.5",
+		ProviderSig:  receipts.SignResponse(c, output, "0.5", providerKey),
+	}
+	assert.True(t, receipts.Verify(r, input, output, providerPub).Valid, "the signature is that of the receipt")
+	r.CostCLAW = "5"
+	assert.False(t, receipts.Verify(r, input, output, providerPub).Valid)
+}
@@ -0,0 +1,50 @@
+package receipts_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for in, want := range map[string]string{
+		// RFC 8785 section 3.2.2.
+		`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		  "string": "€$\u000F\u000aA'B\"\\\\\"\/",
+		  "literals": [null, true, false]}`: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+			`"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		// Names sort by UTF-16 code units, so U+1F600 comes before U+FB33.
+		"{\"\uFB33\": 1, \"\U0001F600\": 2, \"a\": 3, \"\": 4}":                "{\"\":4,\"a\":3,\"\U0001F600\":2,\"\uFB33\":1}",
+		`{"html": "<a&b>", "neg": -0, "int": 100, "big": 1e21, "small": 1e-7}`: `{"big":1e+21,"html":"<a&b>","int":100,"neg":0,"small":1e-7}`,
+		` [ 1 , { "b" : [ ] , "a" : { } } ] `:                                  `[1,{"a":{},"b":[]}]`,
+	} {
+		got, err := receipts.Canonicalize([]byte(in))
+		require.NoError(t, err, in)
+		assert.Equal(t, want, string(got), in)
+	}
+
+	for _, in := range []string{``, `{`, `{} {}`, `[1e400]`} {
+		_, err := receipts.Canonicalize([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestHash_Canonical(t *testing.T) {
+	// The same value hashes the same whichever client encoded it.
+	want := receipts.Hash(map[string]any{"q": "a<b", "n": 1.0, "list": []any{true}})
+	for _, encoded := range []string{
+		`{"q":"a<b","n":1,"list":[true]}`,
+		`{"list": [true], "n": 1.0, "q": "a<b"}`,
+		"{\n  \"n\": 10e-1,\n  \"q\": \"a<b\",\n  \"list\": [ true ]\n}",
+	} {
+		assert.Equal(t, want, receipts.HashJSON([]byte(encoded)), encoded)
+	}
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 12.50, "tags": ["x"]}`), &decoded))
+	assert.Equal(t, receipts.HashJSON([]byte(`{"tags":["x"],"price":12.5}`)), receipts.Hash(decoded))
+	assert.NotEqual(t, receipts.HashJSON([]byte(`{"a":1}`)), receipts.HashJSON([]byte(`{"a":"1"}`)))
+}
@@ -0,0 +1,148 @@
+package receipts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidProof is returned for an inclusion proof that is malformed or
+// does not lead from the receipt to the root.
+var ErrInvalidProof = errors.New("invalid inclusion proof")
+
+// Domain separation of Merkle leaves and inner nodes, as in RFC 6962, so
+// that no inner node can pass for a receipt.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Proof proves that a receipt was in a batch whose Merkle root the registry
+// anchored on ClawChain: hashing Leaf with each step of Path in turn gives
+// Root, which transaction TxHash recorded on chain.
+type Proof struct {
+	ReceiptID string `json:"receipt_id"`
+	// Leaf is LeafHash of the receipt.
+	Leaf string `json:"leaf"`
+	// Index is the receipt's position in the batch.
+	Index int         `json:"index"`
+	Path  []ProofStep `json:"path"`
+	Root  string      `json:"root"`
+	// BatchID names the batch on the registry, TxHash the transaction that
+	// anchored its root.
+	BatchID    string    `json:"batch_id"`
+	TxHash     string    `json:"tx_hash"`
+	AnchoredAt time.Time `json:"anchored_at"`
+}
+
+// ProofStep is a sibling on the path from a leaf to the root, hashed on the
+// left of the running hash when Left is set and on its right otherwise.
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left,omitempty"`
+}
+
+// LeafHash returns the Merkle leaf of r: the SHA-256 of a zero byte and the
+// canonical JSON of r, as "sha256:<hex>".
+func LeafHash(r *Receipt) string {
+	b, _ := json.Marshal(r)
+	c, _ := Canonicalize(b)
+	sum := sha256.Sum256(append([]byte{leafPrefix}, c...))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// MerkleTree returns the root of the Merkle tree over leaves, in order, and
+// the path of each leaf to it. Inner nodes are the SHA-256 of a one byte
+// and their children; a node left without a sibling moves up a level as it
+// is. It returns an empty root for no leaves.
+func MerkleTree(leaves []string) (string, [][]ProofStep, error) {
+	if len(leaves) == 0 {
+		return "", nil, nil
+	}
+	level := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		h, err := decodeHash(l)
+		if err != nil {
+			return "", nil, err
+		}
+		level[i] = h
+	}
+	paths := make([][]ProofStep, len(leaves))
+	// at[i] is the index, in the current level, of the node above leaf i.
+	at := make([]int, len(leaves))
+	for i := range at {
+		at[i] = i
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashNode(level[i], level[i+1]))
+		}
+		for leaf, i := range at {
+			switch {
+			case i%2 == 0 && i+1 < len(level):
+				paths[leaf] = append(paths[leaf], ProofStep{Hash: encodeHash(level[i+1])})
+			case i%2 == 1:
+				paths[leaf] = append(paths[leaf], ProofStep{Hash: encodeHash(level[i-1]), Left: true})
+			}
+			at[leaf] = i / 2
+		}
+		level = next
+	}
+	return encodeHash(level[0]), paths, nil
+}
+
+// VerifyProof checks that p leads from the leaf of r to p.Root. r may be
+// nil to check the path from p.Leaf alone. Callers still check that the
+// root was anchored, by looking up p.TxHash on chain.
+func VerifyProof(p *Proof, r *Receipt) error {
+	if r != nil && LeafHash(r) != p.Leaf {
+		return fmt.Errorf("%w: leaf is not the hash of the receipt", ErrInvalidProof)
+	}
+	h, err := decodeHash(p.Leaf)
+	if err != nil {
+		return err
+	}
+	for _, s := range p.Path {
+		sibling, err := decodeHash(s.Hash)
+		if err != nil {
+			return err
+		}
+		if s.Left {
+			h = hashNode(sibling, h)
+		} else {
+			h = hashNode(h, sibling)
+		}
+	}
+	if encodeHash(h) != p.Root {
+		return fmt.Errorf("%w: path does not lead to the root", ErrInvalidProof)
+	}
+	return nil
+}
+
+func hashNode(left, right []byte) []byte {
+	b := make([]byte, 0, 1+len(left)+len(right))
+	b = append(append(append(b, nodePrefix), left...), right...)
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+func encodeHash(h []byte) string {
+	return "sha256:" + hex.EncodeToString(h)
+}
+
+func decodeHash(s string) ([]byte, error) {
+	h, err := hex.DecodeString(strings.TrimPrefix(s, "sha256:"))
+	if err != nil || len(h) != sha256.Size || !strings.HasPrefix(s, "sha256:") {
+		return nil, fmt.Errorf("%w: %q is not sha256:<64 hex>", ErrInvalidProof, s)
+	}
+	return h, nil
+}
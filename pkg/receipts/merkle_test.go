@@ -0,0 +1,43 @@
+package receipts_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleTree(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 13} {
+		batch := make([]*receipts.Receipt, n)
+		leaves := make([]string, n)
+		for i := range batch {
+			batch[i] = &receipts.Receipt{ID: fmt.Sprintf("rcpt_%d", i), InvocationID: fmt.Sprintf("inv_%d", i)}
+			leaves[i] = receipts.LeafHash(batch[i])
+		}
+		root, paths, err := receipts.MerkleTree(leaves)
+		require.NoError(t, err)
+		require.Len(t, paths, n)
+		for i, path := range paths {
+			p := &receipts.Proof{Leaf: leaves[i], Index: i, Path: path, Root: root}
+			assert.NoError(t, receipts.VerifyProof(p, batch[i]), "leaf %d of %d", i, n)
+			if n > 1 {
+				assert.ErrorIs(t, receipts.VerifyProof(p, batch[(i+1)%n]), receipts.ErrInvalidProof, "another receipt")
+				moved := &receipts.Proof{Leaf: leaves[(i+1)%n], Path: path, Root: root}
+				assert.ErrorIs(t, receipts.VerifyProof(moved, nil), receipts.ErrInvalidProof, "another leaf on this path")
+			}
+		}
+		if n == 1 {
+			assert.Equal(t, leaves[0], root, "a lone leaf is the root")
+		}
+	}
+
+	root, paths, err := receipts.MerkleTree(nil)
+	require.NoError(t, err)
+	assert.Empty(t, root)
+	assert.Empty(t, paths)
+	_, _, err = receipts.MerkleTree([]string{"sha256:zz"})
+	assert.ErrorIs(t, err, receipts.ErrInvalidProof)
+}
@@ -0,0 +1,188 @@
+// Package receipts verifies invocation receipts offline, without trusting
+// the registry that issued them. It depends on the standard library only,
+// so consumers can embed it anywhere:
+//
+//	key, err := receipts.ParsePubKey(provider.PubKey)
+//	if err != nil { ... }
+//	v := receipts.Verify(resp.Receipt, input, resp.Output, key)
+//	if !v.Valid { ... }
+package receipts
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SigPrefix prefixes the base64 signature in Receipt.ProviderSig, and the
+// hex key in a provider's registered pubkey.
+const SigPrefix = "ed25519:"
+
+// ErrInvalidKey is returned for a pubkey that is not ed25519:<64 hex>.
+var ErrInvalidKey = errors.New("invalid ed25519 key")
+
+// Receipt is the registry's record of a completed invocation: who called
+// which tool, the hashes of its input and output and what it cost, signed
+// by the tool's provider.
+type Receipt struct {
+	ID           string    `json:"id"`
+	InvocationID string    `json:"invocation_id"`
+	ToolID       string    `json:"tool_id"`
+	ConsumerID   string    `json:"consumer_id"`
+	ProviderID   string    `json:"provider_id"`
+	InputHash    string    `json:"input_hash"`
+	OutputHash   string    `json:"output_hash"`
+	CostCLAW     string    `json:"cost_claw,omitempty"`
+	ExecutedAt   time.Time `json:"executed_at"`
+	// ProviderSig is the provider's signature of SignedPayload, as
+	// "ed25519:<base64>", or empty when the provider did not sign.
+	ProviderSig string `json:"provider_sig"`
+	// Cached is set when the registry served the output from its result
+	// cache instead of executing the tool.
+	Cached bool `json:"cached,omitempty"`
+	// Mock is set when the tool's mock responder answered in place of
+	// its provider, free of charge.
+	Mock bool `json:"mock,omitempty"`
+	// Metadata is what the consumer attached to the invocation.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Attestation is the quote of the trusted execution environment the
+	// provider ran the tool in, if it attached one.
+	Attestation *Attestation `json:"attestation,omitempty"`
+}
+
+// Attestation is a quote of a trusted execution environment, such as an
+// SGX enclave or an SEV-SNP guest, whose report data is the ReportData of
+// the receipt it is attached to. Verifying it takes the vendor's
+// certificates, so Verify leaves it unchecked; the registry checks it with
+// a verifier for its Format.
+type Attestation struct {
+	// Format names the kind of quote, such as "sgx-dcap" or "sev-snp".
+	Format string `json:"format"`
+	// Quote is the quote as the environment produced it; base64 in JSON.
+	Quote []byte `json:"quote"`
+}
+
+// ReportData returns what the attestation of r binds it with: the SHA-256
+// of SignedPayload, which the provider puts in the report data of its
+// quote.
+func ReportData(r *Receipt) []byte {
+	sum := sha256.Sum256(SignedPayload(r))
+	return sum[:]
+}
+
+// Hash returns the hash a receipt records for an input or output: the
+// SHA-256 of its canonical JSON (see Canonicalize), as "sha256:<hex>".
+func Hash(v any) string {
+	b, _ := json.Marshal(v)
+	return HashJSON(b)
+}
+
+// SignedPayload returns what the provider signs for r: the canonical JSON
+// object of the fields binding the call to its result. The receipt ID, time
+// and metadata are the registry's and are not signed.
+func SignedPayload(r *Receipt) []byte {
+	b, _ := json.Marshal(struct {
+		ConsumerID   string `json:"consumer_id"`
+		CostCLAW     string `json:"cost_claw"`
+		InputHash    string `json:"input_hash"`
+		InvocationID string `json:"invocation_id"`
+		OutputHash   string `json:"output_hash"`
+		ToolID       string `json:"tool_id"`
+	}{r.ConsumerID, r.CostCLAW, r.InputHash, r.InvocationID, r.OutputHash, r.ToolID})
+	c, _ := Canonicalize(b)
+	return c
+}
+
+// Sign sets r.ProviderSig to the signature of r by key.
+func Sign(r *Receipt, key ed25519.PrivateKey) {
+	r.ProviderSig = SigPrefix + base64.StdEncoding.EncodeToString(ed25519.Sign(key, SignedPayload(r)))
+}
+
+// ParsePubKey decodes a provider pubkey of the form "ed25519:<hex>".
+func ParsePubKey(s string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(s, SigPrefix))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: must be ed25519:<%d hex bytes>", ErrInvalidKey, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Outcomes of the checks of a Verdict.
+const (
+	CheckValid     = "valid"
+	CheckInvalid   = "invalid"
+	CheckMissing   = "missing"
+	CheckMatch     = "match"
+	CheckMismatch  = "mismatch"
+	CheckUnchecked = "unchecked"
+)
+
+// Verdict is the outcome of verifying a receipt.
+type Verdict struct {
+	// Valid is set when the signature verifies, no hash mismatches and no
+	// attestation is invalid.
+	Valid bool `json:"valid"`
+	// Signature is valid, invalid or missing.
+	Signature string `json:"signature"`
+	// InputHash and OutputHash are match, mismatch or, when the payload
+	// was not given, unchecked.
+	InputHash  string `json:"input_hash"`
+	OutputHash string `json:"output_hash"`
+	// Attestation is valid, invalid or, without a verifier for its format,
+	// unchecked; it is omitted for receipts without one.
+	Attestation string `json:"attestation,omitempty"`
+	// Reasons say why the receipt is not valid.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Verify checks the provider signature of r against keys, any of which may
+// have signed it, and recomputes the hashes of input and output unless they
+// are nil. It leaves the attestation of r, if any, unchecked.
+func Verify(r *Receipt, input, output any, keys ...ed25519.PublicKey) *Verdict {
+	v := &Verdict{Signature: CheckInvalid, InputHash: CheckUnchecked, OutputHash: CheckUnchecked}
+	sig, ok := strings.CutPrefix(r.ProviderSig, SigPrefix)
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	switch {
+	case r.ProviderSig == "":
+		v.Signature = CheckMissing
+		v.Reasons = append(v.Reasons, "the receipt is not signed")
+	case !ok || err != nil:
+		v.Reasons = append(v.Reasons, "provider_sig must be ed25519:<base64>")
+	case len(keys) == 0:
+		v.Reasons = append(v.Reasons, "no key to verify the signature against")
+	default:
+		payload := SignedPayload(r)
+		for _, key := range keys {
+			if ed25519.Verify(key, payload, raw) {
+				v.Signature = CheckValid
+				break
+			}
+		}
+		if v.Signature != CheckValid {
+			v.Reasons = append(v.Reasons, "the signature does not verify against the provider's keys")
+		}
+	}
+	check := func(name, recorded string, payload any) string {
+		if payload == nil {
+			return CheckUnchecked
+		}
+		if Hash(payload) != recorded {
+			v.Reasons = append(v.Reasons, name+"_hash is not the hash of the "+name)
+			return CheckMismatch
+		}
+		return CheckMatch
+	}
+	v.InputHash = check("input", r.InputHash, input)
+	v.OutputHash = check("output", r.OutputHash, output)
+	if r.Attestation != nil {
+		v.Attestation = CheckUnchecked
+	}
+	v.Valid = v.Signature == CheckValid && v.InputHash != CheckMismatch && v.OutputHash != CheckMismatch
+	return v
+}
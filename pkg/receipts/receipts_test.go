@@ -0,0 +1,94 @@
+package receipts_test
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	input, output := map[string]any{"q": "audit"}, map[string]any{"severity": "low", "findings": []any{}}
+	signed := func() *receipts.Receipt {
+		r := &receipts.Receipt{
+			ID:           "rcpt_1",
+			InvocationID: "inv_1",
+			ToolID:       "did:claw:tool:abc",
+			ConsumerID:   "did:claw:agent:consumer",
+			ProviderID:   "did:claw:agent:provider",
+			InputHash:    receipts.Hash(input),
+			OutputHash:   receipts.Hash(output),
+			CostCLAW:     "5.0",
+		}
+		receipts.Sign(r, priv)
+		return r
+	}
+
+	v := receipts.Verify(signed(), input, output, other, pub)
+	assert.True(t, v.Valid, v.Reasons)
+	assert.Equal(t, receipts.CheckValid, v.Signature)
+	assert.Equal(t, receipts.CheckMatch, v.InputHash)
+	assert.Equal(t, receipts.CheckMatch, v.OutputHash)
+
+	v = receipts.Verify(signed(), nil, nil, pub)
+	assert.True(t, v.Valid)
+	assert.Equal(t, receipts.CheckUnchecked, v.InputHash)
+	assert.Equal(t, receipts.CheckUnchecked, v.OutputHash)
+
+	// The registry's own fields are not signed.
+	r := signed()
+	r.ID, r.Metadata, r.Cached = "rcpt_2", map[string]string{"task_id": "t"}, true
+	assert.True(t, receipts.Verify(r, nil, nil, pub).Valid)
+	assert.Empty(t, receipts.Verify(r, nil, nil, pub).Attestation)
+
+	// Attestations are left to verifiers of their format.
+	r.Attestation = &receipts.Attestation{Format: "sev-snp", Quote: []byte("report")}
+	v = receipts.Verify(r, nil, nil, pub)
+	assert.True(t, v.Valid)
+	assert.Equal(t, receipts.CheckUnchecked, v.Attestation)
+	assert.Len(t, receipts.ReportData(r), 32)
+	assert.Equal(t, receipts.ReportData(r), receipts.ReportData(signed()), "report data binds the signed fields")
+
+	r = signed()
+	r.CostCLAW = "0.1"
+	v = receipts.Verify(r, nil, nil, pub)
+	assert.False(t, v.Valid)
+	assert.Equal(t, receipts.CheckInvalid, v.Signature)
+
+	v = receipts.Verify(signed(), input, map[string]any{"severity": "high"}, pub)
+	assert.False(t, v.Valid)
+	assert.Equal(t, receipts.CheckValid, v.Signature)
+	assert.Equal(t, receipts.CheckMismatch, v.OutputHash)
+	assert.Equal(t, []string{"output_hash is not the hash of the output"}, v.Reasons)
+
+	assert.False(t, receipts.Verify(signed(), nil, nil, other).Valid)
+	assert.False(t, receipts.Verify(signed(), nil, nil).Valid)
+
+	r = signed()
+	r.ProviderSig = ""
+	assert.Equal(t, receipts.CheckMissing, receipts.Verify(r, nil, nil, pub).Signature)
+	r.ProviderSig = "ed25519:not base64!"
+	v = receipts.Verify(r, nil, nil, pub)
+	assert.Equal(t, receipts.CheckInvalid, v.Signature)
+	assert.Equal(t, []string{"provider_sig must be ed25519:<base64>"}, v.Reasons)
+}
+
+func TestParsePubKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key, err := receipts.ParsePubKey("ed25519:" + hex.EncodeToString(pub))
+	require.NoError(t, err)
+	assert.True(t, pub.Equal(key))
+
+	for _, s := range []string{"", "ed25519:zz", "ed25519:" + hex.EncodeToString(pub[:16])} {
+		_, err := receipts.ParsePubKey(s)
+		assert.ErrorIs(t, err, receipts.ErrInvalidKey, s)
+	}
+}
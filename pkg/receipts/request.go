@@ -0,0 +1,127 @@
+package receipts
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RequestSignatureHeader carries a consumer's RequestSignature, encoded by
+// EncodeRequestSignature, on the calls the registry proxies to providers.
+const RequestSignatureHeader = "X-Consumer-Signature"
+
+// ErrInvalidRequestSignature is returned for a request signature that is
+// malformed or does not verify.
+var ErrInvalidRequestSignature = errors.New("invalid request signature")
+
+// RequestSignature is a consumer's signature of an invocation it requests:
+// which tool, with what input and within what budget. Providers verify it
+// to know who is calling, and may bill by it, without trusting the
+// registry that relays it.
+type RequestSignature struct {
+	ToolID     string `json:"tool_id"`
+	ConsumerID string `json:"consumer_id"`
+	InputHash  string `json:"input_hash"`
+	BudgetCLAW string `json:"budget_claw,omitempty"`
+	// SignedAt bounds how long the signature can be replayed for, and
+	// Nonce, random and used once, keeps it from being replayed at all by
+	// verifiers that remember the nonces they saw. Signatures made before
+	// nonces existed have none.
+	SignedAt time.Time `json:"signed_at"`
+	Nonce    string    `json:"nonce,omitempty"`
+	// PubKey is the consumer's key, "ed25519:<hex>". Verifiers check that
+	// it belongs to ConsumerID, e.g. that the consumer's DID resolves to
+	// it.
+	PubKey string `json:"pubkey"`
+	// Signature is the signature of RequestPayload, "ed25519:<base64>".
+	Signature string `json:"signature"`
+}
+
+// RequestPayload returns what the consumer signs for s: the canonical JSON
+// object of every field but the signature, without the nonce when there is
+// none.
+func RequestPayload(s *RequestSignature) []byte {
+	b, _ := json.Marshal(struct {
+		BudgetCLAW string `json:"budget_claw"`
+		ConsumerID string `json:"consumer_id"`
+		InputHash  string `json:"input_hash"`
+		Nonce      string `json:"nonce,omitempty"`
+		PubKey     string `json:"pubkey"`
+		SignedAt   string `json:"signed_at"`
+		ToolID     string `json:"tool_id"`
+	}{s.BudgetCLAW, s.ConsumerID, s.InputHash, s.Nonce, s.PubKey, s.SignedAt.UTC().Format(time.RFC3339), s.ToolID})
+	c, _ := Canonicalize(b)
+	return c
+}
+
+// SignRequest returns the signature by key of a call of toolID by
+// consumerID with input, which may be a decoded value or json.RawMessage,
+// and budget, which may be empty, made at now with a fresh nonce.
+func SignRequest(toolID, consumerID string, input any, budget string, now time.Time, key ed25519.PrivateKey) *RequestSignature {
+	s := &RequestSignature{
+		ToolID:     toolID,
+		ConsumerID: consumerID,
+		InputHash:  Hash(input),
+		BudgetCLAW: budget,
+		SignedAt:   now.UTC().Truncate(time.Second),
+		Nonce:      NewNonce(),
+		PubKey:     SigPrefix + hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	s.Signature = SigPrefix + base64.StdEncoding.EncodeToString(ed25519.Sign(key, RequestPayload(s)))
+	return s
+}
+
+// NewNonce returns a random nonce: 16 bytes, base64url.
+func NewNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// VerifyRequest checks that s is signed by its PubKey and that input, unless
+// nil, is what was signed. Callers still check that the key belongs to the
+// consumer and that the signature is recent.
+func VerifyRequest(s *RequestSignature, input any) error {
+	key, err := ParsePubKey(s.PubKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRequestSignature, err)
+	}
+	sig, ok := strings.CutPrefix(s.Signature, SigPrefix)
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if !ok || err != nil {
+		return fmt.Errorf("%w: signature must be ed25519:<base64>", ErrInvalidRequestSignature)
+	}
+	if !ed25519.Verify(key, RequestPayload(s), raw) {
+		return fmt.Errorf("%w: signature does not verify against pubkey", ErrInvalidRequestSignature)
+	}
+	if input != nil && Hash(input) != s.InputHash {
+		return fmt.Errorf("%w: input_hash is not the hash of the input", ErrInvalidRequestSignature)
+	}
+	return nil
+}
+
+// EncodeRequestSignature returns s in the form RequestSignatureHeader
+// carries: the base64url of its JSON.
+func EncodeRequestSignature(s *RequestSignature) string {
+	b, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeRequestSignature reads a RequestSignatureHeader.
+func DecodeRequestSignature(h string) (*RequestSignature, error) {
+	b, err := base64.RawURLEncoding.DecodeString(h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header must be base64url JSON", ErrInvalidRequestSignature)
+	}
+	var s RequestSignature
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequestSignature, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,41 @@
+package receipts_test
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequest(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	input := map[string]any{"b": 2, "a": 1}
+	s := receipts.SignRequest("tool-1", "did:key:z6Mk", input, "5", time.Now(), key)
+	require.NoError(t, receipts.VerifyRequest(s, input))
+	require.NoError(t, receipts.VerifyRequest(s, json.RawMessage(`{"a":1,"b":2}`)), "the input is hashed canonically")
+	require.NoError(t, receipts.VerifyRequest(s, nil))
+
+	decoded, err := receipts.DecodeRequestSignature(receipts.EncodeRequestSignature(s))
+	require.NoError(t, err)
+	require.NoError(t, receipts.VerifyRequest(decoded, input), "the header form verifies")
+
+	assert.ErrorIs(t, receipts.VerifyRequest(s, map[string]any{"a": 2}), receipts.ErrInvalidRequestSignature)
+	tampered := *s
+	tampered.BudgetCLAW = "500"
+	assert.ErrorIs(t, receipts.VerifyRequest(&tampered, nil), receipts.ErrInvalidRequestSignature)
+	tampered = *s
+	tampered.Nonce = receipts.NewNonce()
+	assert.ErrorIs(t, receipts.VerifyRequest(&tampered, nil), receipts.ErrInvalidRequestSignature, "the nonce is signed")
+	assert.NotEqual(t, s.Nonce, receipts.SignRequest("tool-1", "did:key:z6Mk", input, "5", time.Now(), key).Nonce)
+	tampered = *s
+	tampered.Signature = "not-a-signature"
+	assert.ErrorIs(t, receipts.VerifyRequest(&tampered, nil), receipts.ErrInvalidRequestSignature)
+
+	_, err = receipts.DecodeRequestSignature("!!")
+	assert.ErrorIs(t, err, receipts.ErrInvalidRequestSignature)
+}
@@ -0,0 +1,25 @@
+package receipts
+
+import "crypto/ed25519"
+
+// ResponseSignatureHeader carries the provider's signature of the receipt
+// of a call, as Receipt.ProviderSig, on its answer to the registry.
+const ResponseSignatureHeader = "X-Provider-Signature"
+
+// SignResponse returns the signature by the provider's key of the receipt
+// of the call the registry signed with c, answered with output and charged
+// costCLAW: the value of a ResponseSignatureHeader. costCLAW is what the
+// registry charges for the call, the tool's price per call, or per token
+// times the usage.tokens the output reports, and empty for free tools.
+func SignResponse(c *CallSignature, output any, costCLAW string, key ed25519.PrivateKey) string {
+	r := &Receipt{
+		InvocationID: c.InvocationID,
+		ToolID:       c.ToolID,
+		ConsumerID:   c.ConsumerID,
+		InputHash:    c.InputHash,
+		OutputHash:   Hash(output),
+		CostCLAW:     costCLAW,
+	}
+	Sign(r, key)
+	return r.ProviderSig
+}
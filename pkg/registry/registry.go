@@ -0,0 +1,441 @@
+// Package registry is the embeddable agent-tools registry. It lets an agent
+// binary run an in-process registry backed by its own SQLite file, without
+// the agent-tools server:
+//
+//	reg, err := registry.Open("./data/tools.db")
+//	if err != nil { ... }
+//	defer reg.Close()
+//	tool, err := reg.RegisterTool(ctx, &registry.RegisterToolRequest{...})
+//
+// Serve it over HTTP with the httpapi package.
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clawinfra/agent-tools/internal/cas"
+	"github.com/clawinfra/agent-tools/internal/netguard"
+	"github.com/clawinfra/agent-tools/internal/registry"
+	"github.com/clawinfra/agent-tools/internal/secrets"
+	"github.com/clawinfra/agent-tools/internal/store"
+	"go.uber.org/zap"
+)
+
+// Types shared with the server. They are aliases, so values flow unchanged
+// between this package, httpapi and the server internals.
+type (
+	Tool                    = registry.Tool
+	ToolSchema              = registry.ToolSchema
+	Pricing                 = registry.Pricing
+	PricingModel            = registry.PricingModel
+	Provider                = registry.Provider
+	RegisterToolRequest     = registry.RegisterToolRequest
+	RegisterGRPCToolRequest = registry.RegisterGRPCToolRequest
+	SearchQuery             = registry.SearchQuery
+	SearchResult            = registry.SearchResult
+	DailyUsage              = registry.DailyUsage
+	NamespaceMember         = registry.NamespaceMember
+	Limits                  = registry.Limits
+	EndpointAuth            = registry.EndpointAuth
+	Advisory                = registry.Advisory
+	CachePolicy             = registry.CachePolicy
+	SLA                     = registry.SLA
+	SLACompliance           = registry.SLACompliance
+	Quota                   = registry.Quota
+	DryRunResponse          = registry.DryRunResponse
+	RetryPolicy             = registry.RetryPolicy
+	Mock                    = registry.Mock
+	MockResponse            = registry.MockResponse
+	ToolTest                = registry.ToolTest
+	Conformance             = registry.Conformance
+	TestFailure             = registry.TestFailure
+	Health                  = registry.Health
+	Invocation              = registry.Invocation
+	InvocationFilter        = registry.InvocationFilter
+	InvocationList          = registry.InvocationList
+	Receipt                 = registry.Receipt
+	ReceiptFilter           = registry.ReceiptFilter
+	ReceiptList             = registry.ReceiptList
+	Pipeline                = registry.Pipeline
+	PipelineStep            = registry.PipelineStep
+	PipelineRun             = registry.PipelineRun
+	PipelineReceipt         = registry.PipelineReceipt
+	StepReceipt             = registry.StepReceipt
+	Collection              = registry.Collection
+	Org                     = registry.Org
+	OrgMember               = registry.OrgMember
+	LimitWarning            = registry.LimitWarning
+	Refund                  = registry.Refund
+	PricingChange           = registry.PricingChange
+	PricingNotice           = registry.PricingNotice
+	ProviderError           = registry.ProviderError
+	RouteCandidate          = registry.RouteCandidate
+	RouteStrategy           = registry.RouteStrategy
+	Metadata                = registry.Metadata
+	Icon                    = registry.Icon
+	APIKey                  = registry.APIKey
+	ForecastQuery           = registry.ForecastQuery
+	SpendForecast           = registry.SpendForecast
+	ToolSpend               = registry.ToolSpend
+	Anchorer                = registry.Anchorer
+	Wallet                  = registry.Wallet
+	Escrow                  = registry.Escrow
+	WalletInfo              = registry.WalletInfo
+	Withdrawal              = registry.Withdrawal
+	Attestation             = registry.Attestation
+	AttestationVerifier     = registry.AttestationVerifier
+	Policy                  = registry.Policy
+	PolicyDecision          = registry.PolicyDecision
+	PolicyDecisionFilter    = registry.PolicyDecisionFilter
+	EndpointPolicy          = netguard.Policy
+	ContentStore            = cas.Store
+)
+
+// Pricing models.
+const (
+	PricingFree         = registry.PricingFree
+	PricingPerCall      = registry.PricingPerCall
+	PricingPerToken     = registry.PricingPerToken
+	PricingSubscription = registry.PricingSubscription
+)
+
+// Errors returned by Registry methods; match them with errors.Is.
+var (
+	ErrNotFound            = registry.ErrNotFound
+	ErrDuplicate           = registry.ErrDuplicate
+	ErrForbidden           = registry.ErrForbidden
+	ErrInvalidNamespace    = registry.ErrInvalidNamespace
+	ErrLimitExceeded       = registry.ErrLimitExceeded
+	ErrInvalidAuth         = registry.ErrInvalidAuth
+	ErrInvalidSignature    = registry.ErrInvalidSignature
+	ErrInvalidAdvisory     = registry.ErrInvalidAdvisory
+	ErrToolRevoked         = registry.ErrToolRevoked
+	ErrInvalidEndpoint     = registry.ErrInvalidEndpoint
+	ErrReflection          = registry.ErrReflection
+	ErrQuotaExceeded       = registry.ErrQuotaExceeded
+	ErrInvalidQuota        = registry.ErrInvalidQuota
+	ErrInvalidInput        = registry.ErrInvalidInput
+	ErrBudgetExceeded      = registry.ErrBudgetExceeded
+	ErrInvalidBudget       = registry.ErrInvalidBudget
+	ErrInvalidRetryPolicy  = registry.ErrInvalidRetryPolicy
+	ErrInvalidCursor       = registry.ErrInvalidCursor
+	ErrInvalidPipeline     = registry.ErrInvalidPipeline
+	ErrInvalidCollection   = registry.ErrInvalidCollection
+	ErrInvalidOrg          = registry.ErrInvalidOrg
+	ErrIdempotencyConflict = registry.ErrIdempotencyConflict
+	ErrInvalidFeatures     = registry.ErrInvalidFeatures
+	ErrNoRoute             = registry.ErrNoRoute
+	ErrUnknownStrategy     = registry.ErrUnknownStrategy
+	ErrInvalidMetadata     = registry.ErrInvalidMetadata
+	ErrInvalidIcon         = registry.ErrInvalidIcon
+	ErrInvalidLanguage     = registry.ErrInvalidLanguage
+	ErrInvalidScope        = registry.ErrInvalidScope
+	ErrInvalidAPIKey       = registry.ErrInvalidAPIKey
+	ErrNotAnchored         = registry.ErrNotAnchored
+	ErrInvalidForecast     = registry.ErrInvalidForecast
+	ErrPolicyDenied        = registry.ErrPolicyDenied
+	ErrInvalidPolicy       = registry.ErrInvalidPolicy
+	ErrReplayedRequest     = registry.ErrReplayedRequest
+	ErrInvalidAttestation  = registry.ErrInvalidAttestation
+	ErrReceiptSealed       = registry.ErrReceiptSealed
+	ErrInvalidMock         = registry.ErrInvalidMock
+	ErrNoMock              = registry.ErrNoMock
+	ErrInvalidTests        = registry.ErrInvalidTests
+	ErrNoWallet            = registry.ErrNoWallet
+	ErrInsufficientFunds   = registry.ErrInsufficientFunds
+	ErrInvalidAmount       = registry.ErrInvalidAmount
+	ErrNoAccount           = registry.ErrNoAccount
+	ErrChain               = registry.ErrChain
+
+	ErrInvalidResponseSignature = registry.ErrInvalidResponseSignature
+
+	ErrInvalidConsumerSignature = registry.ErrInvalidConsumerSignature
+)
+
+// Escrow statuses.
+const (
+	EscrowHeld      = registry.EscrowHeld
+	EscrowReleasing = registry.EscrowReleasing
+	EscrowReleased  = registry.EscrowReleased
+	EscrowRefunded  = registry.EscrowRefunded
+	EscrowFailed    = registry.EscrowFailed
+)
+
+// Periods of spend forecasts.
+const (
+	PeriodDay   = registry.PeriodDay
+	PeriodMonth = registry.PeriodMonth
+)
+
+// StatusDeadLetter is the status of invocations that failed on every
+// attempt of their tool's retry policy.
+const StatusDeadLetter = registry.StatusDeadLetter
+
+// ReasonAbandoned starts the error of invocations failed because the
+// replica running them died.
+const ReasonAbandoned = registry.ReasonAbandoned
+
+// Routing policies of tools with several endpoints.
+const (
+	RoutingFailover   = registry.RoutingFailover
+	RoutingRoundRobin = registry.RoutingRoundRobin
+)
+
+// Features a tool may declare in RegisterToolRequest.Features.
+const (
+	FeatureStreaming     = registry.FeatureStreaming
+	FeatureBatch         = registry.FeatureBatch
+	FeatureCacheable     = registry.FeatureCacheable
+	FeatureDeterministic = registry.FeatureDeterministic
+)
+
+// MaxIconBytes bounds the size of tool icons.
+const MaxIconBytes = registry.MaxIconBytes
+
+// DefaultLanguage is the language of descriptions of tools registered
+// without one.
+const DefaultLanguage = registry.DefaultLanguage
+
+// Strategies Route ranks the tools offering a capability by.
+const (
+	RouteCheapest          = registry.RouteCheapest
+	RouteLowestLatency     = registry.RouteLowestLatency
+	RouteHighestReputation = registry.RouteHighestReputation
+	DefaultRouteStrategy   = registry.DefaultRouteStrategy
+)
+
+// Organization member roles.
+const (
+	OrgRoleOwner      = registry.OrgRoleOwner
+	OrgRoleMaintainer = registry.OrgRoleMaintainer
+)
+
+// DefaultPriceNotice is how long a price rise is announced before it is
+// charged, unless WithPriceNotice says otherwise.
+const DefaultPriceNotice = registry.DefaultPriceNotice
+
+// DefaultWarnThreshold is the share of a quota or budget at which
+// consumers are warned, unless WithWarnThreshold says otherwise.
+const DefaultWarnThreshold = registry.DefaultWarnThreshold
+
+// Advisory states.
+const (
+	AdvisoryVulnerable = registry.AdvisoryVulnerable
+	AdvisoryRevoked    = registry.AdvisoryRevoked
+)
+
+// SLA states of a tool.
+const (
+	SLAMet              = registry.SLAMet
+	SLAViolated         = registry.SLAViolated
+	SLAInsufficientData = registry.SLAInsufficientData
+)
+
+// ManifestHash returns the hash a provider signs for a registration.
+var ManifestHash = registry.ManifestHash
+
+// ManifestDocument returns the canonical manifest ManifestHash hashes.
+var ManifestDocument = registry.ManifestDocument
+
+// InvocationCost returns what an invocation of a tool with an input and
+// output costs, per-token tools included.
+var InvocationCost = registry.InvocationCost
+
+// DefaultLimits are the payload limits applied unless WithLimits is used.
+var DefaultLimits = registry.DefaultLimits
+
+// DefaultNamespace is the namespace used when a context carries none.
+const DefaultNamespace = registry.DefaultNamespace
+
+// WithNamespace and NamespaceFrom scope registry calls to a namespace.
+var (
+	WithNamespace = registry.WithNamespace
+	NamespaceFrom = registry.NamespaceFrom
+)
+
+// Registry is an in-process tool registry. All methods of the server's
+// registry are available on it.
+type Registry struct {
+	*registry.Registry
+	db *store.DB
+}
+
+// Option configures Open.
+type Option func(*options)
+
+type options struct {
+	log        *zap.Logger
+	instanceID string
+	limits     Limits
+	secretsKey []byte
+	signed     bool
+	nonces     bool
+	respSigs   bool
+	endpoints  EndpointPolicy
+	cas        ContentStore
+	warnAt     float64
+	notice     time.Duration
+	routes     map[string]RouteStrategy
+	routeBy    string
+	anchorer   Anchorer
+	wallet     Wallet
+	escrowSeed []byte
+	attesters  map[string]AttestationVerifier
+}
+
+// WithLogger sets the logger. The default discards all output.
+func WithLogger(log *zap.Logger) Option {
+	return func(o *options) { o.log = log }
+}
+
+// WithInstanceID tags invocations recorded by this process; see the serve
+// command's --instance-id.
+func WithInstanceID(id string) Option {
+	return func(o *options) { o.instanceID = id }
+}
+
+// WithLimits bounds schema, description and input sizes; see Limits.
+func WithLimits(l Limits) Option {
+	return func(o *options) { o.limits = l }
+}
+
+// WithSecretsKey enables encrypted endpoint auth (RegisterToolRequest.Auth)
+// with a 32-byte AES key.
+func WithSecretsKey(key []byte) Option {
+	return func(o *options) { o.secretsKey = key }
+}
+
+// WithSignedManifests requires a provider signature on every registration.
+func WithSignedManifests(required bool) Option {
+	return func(o *options) { o.signed = required }
+}
+
+// WithRequiredNonces requires a nonce on every signed registration and
+// consumer signature.
+func WithRequiredNonces(required bool) Option {
+	return func(o *options) { o.nonces = required }
+}
+
+// WithRequiredResponseSignatures fails proxied invocations whose provider
+// did not sign the receipt of its answer.
+func WithRequiredResponseSignatures(required bool) Option {
+	return func(o *options) { o.respSigs = required }
+}
+
+// WithEndpointPolicy restricts the endpoints tools and providers may
+// register; see EndpointPolicy.
+func WithEndpointPolicy(p EndpointPolicy) Option {
+	return func(o *options) { o.endpoints = p }
+}
+
+// WithContentStore pins the manifest of every registered tool version to
+// store and records its CID on the tool.
+func WithContentStore(store ContentStore) Option {
+	return func(o *options) { o.cas = store }
+}
+
+// WithWarnThreshold sets the share of a tool quota or invocation budget at
+// which consumers are sent a quota.warning event; 0 disables the warnings.
+func WithWarnThreshold(share float64) Option {
+	return func(o *options) { o.warnAt = share }
+}
+
+// WithPriceNotice sets how long after a version raising its line's price
+// is registered the new price is first charged; 0 charges it at once.
+func WithPriceNotice(d time.Duration) Option {
+	return func(o *options) { o.notice = d }
+}
+
+// WithAnchorer anchors receipts with a whenever AnchorReceipts is called,
+// making their inclusion proofs available from ReceiptProof.
+func WithAnchorer(a Anchorer) Option {
+	return func(o *options) { o.anchorer = a }
+}
+
+// WithWallet settles paid invocations in CLAW with w, from escrow accounts
+// derived from seed; see HoldEscrow and SettleEscrows.
+func WithWallet(w Wallet, seed []byte) Option {
+	return func(o *options) { o.wallet, o.escrowSeed = w, seed }
+}
+
+// WithAttestationVerifier checks the receipt attestations of format with v.
+func WithAttestationVerifier(format string, v AttestationVerifier) Option {
+	return func(o *options) {
+		if o.attesters == nil {
+			o.attesters = map[string]AttestationVerifier{}
+		}
+		o.attesters[format] = v
+	}
+}
+
+// WithRouteStrategy adds a strategy Route may rank candidates by, or
+// replaces a built-in one.
+func WithRouteStrategy(name string, s RouteStrategy) Option {
+	return func(o *options) {
+		if o.routes == nil {
+			o.routes = map[string]RouteStrategy{}
+		}
+		o.routes[name] = s
+	}
+}
+
+// WithDefaultRouteStrategy sets the strategy Route uses when given none.
+func WithDefaultRouteStrategy(name string) Option {
+	return func(o *options) { o.routeBy = name }
+}
+
+// Open opens (creating and migrating if needed) the SQLite database at path
+// and returns a registry on top of it. Use ":memory:" for a throwaway
+// registry; it is served over a single connection, so that every query sees
+// the same database.
+func Open(path string, opts ...Option) (*Registry, error) {
+	o := &options{log: zap.NewNop(), limits: DefaultLimits, warnAt: DefaultWarnThreshold, routeBy: DefaultRouteStrategy,
+		notice: DefaultPriceNotice}
+	for _, opt := range opts {
+		opt(o)
+	}
+	regOpts := []registry.Option{
+		registry.WithInstanceID(o.instanceID),
+		registry.WithLimits(o.limits),
+		registry.WithSignedManifests(o.signed),
+		registry.WithRequiredNonces(o.nonces),
+		registry.WithRequiredResponseSignatures(o.respSigs),
+		registry.WithEndpointPolicy(o.endpoints),
+		registry.WithWarnThreshold(o.warnAt),
+		registry.WithPriceNotice(o.notice),
+		registry.WithDefaultRouteStrategy(o.routeBy),
+	}
+	for name, s := range o.routes {
+		regOpts = append(regOpts, registry.WithRouteStrategy(name, s))
+	}
+	for format, v := range o.attesters {
+		regOpts = append(regOpts, registry.WithAttestationVerifier(format, v))
+	}
+	if o.secretsKey != nil {
+		box, err := secrets.New(o.secretsKey)
+		if err != nil {
+			return nil, err
+		}
+		regOpts = append(regOpts, registry.WithSecrets(box))
+	}
+	if o.cas != nil {
+		regOpts = append(regOpts, registry.WithCAS(o.cas))
+	}
+	if o.anchorer != nil {
+		regOpts = append(regOpts, registry.WithAnchorer(o.anchorer))
+	}
+	if o.wallet != nil {
+		regOpts = append(regOpts, registry.WithWallet(o.wallet, o.escrowSeed))
+	}
+	db, err := store.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	return &Registry{Registry: registry.New(db, o.log, regOpts...), db: db}, nil
+}
+
+// Close closes the underlying database.
+func (r *Registry) Close() error {
+	return r.db.Close()
+}
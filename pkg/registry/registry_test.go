@@ -0,0 +1,150 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// memStore is a content store keeping documents in memory.
+type memStore map[string][]byte
+
+func (m memStore) Put(_ context.Context, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	cid := "sha256:" + hex.EncodeToString(sum[:])
+	m[cid] = data
+	return cid, nil
+}
+
+func (m memStore) Get(_ context.Context, cid string) ([]byte, error) {
+	if data, ok := m[cid]; ok {
+		return data, nil
+	}
+	return nil, registry.ErrNotFound
+}
+
+// nopWallet holds nothing and sends nothing.
+type nopWallet struct{}
+
+func (nopWallet) Address(account ed25519.PublicKey) string { return hex.EncodeToString(account) }
+
+func (nopWallet) Balance(context.Context, ed25519.PublicKey) (string, error) { return "0", nil }
+
+func (nopWallet) Transfer(context.Context, ed25519.PrivateKey, ed25519.PublicKey, string) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func (nopWallet) Reserve() (deposit, fee string) { return "0", "0" }
+
+// nopAnchorer anchors every root in the same transaction.
+type nopAnchorer struct{}
+
+func (nopAnchorer) Anchor(context.Context, string) (string, error) { return "0xanchor", nil }
+
+// trustAll accepts every attestation.
+type trustAll struct{}
+
+func (trustAll) VerifyAttestation(context.Context, []byte, []byte) error { return nil }
+
+func embeddedTool(name string) *registry.RegisterToolRequest {
+	return &registry.RegisterToolRequest{
+		Name:        name,
+		Version:     "1.0.0",
+		Description: "runs in-process",
+		Schema:      registry.ToolSchema{Input: []byte(`{"type":"object"}`)},
+		Pricing:     &registry.Pricing{Model: registry.PricingFree},
+		Endpoint:    "https://localhost/embedded",
+		ProviderID:  "did:claw:agent:self",
+	}
+}
+
+func TestOpen_RegisterAndSearch(t *testing.T) {
+	reg, err := registry.Open(filepath.Join(t.TempDir(), "tools.db"))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, reg.Close()) }()
+
+	ctx := context.Background()
+	tool, err := reg.RegisterTool(ctx, embeddedTool("embedded-tool"))
+	require.NoError(t, err)
+
+	got, err := reg.GetTool(ctx, tool.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "embedded-tool", got.Name)
+
+	_, err = reg.GetTool(ctx, "missing")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestOpen_BadPath(t *testing.T) {
+	_, err := registry.Open("/dev/null/tools.db")
+	assert.Error(t, err)
+}
+
+func TestOpen_Options(t *testing.T) {
+	ctx := context.Background()
+	store := memStore{}
+	limits := registry.DefaultLimits
+	limits.MaxDescriptionBytes = 20
+	reg, err := registry.Open(":memory:",
+		registry.WithLogger(zaptest.NewLogger(t)),
+		registry.WithInstanceID("replica-1"),
+		registry.WithLimits(limits),
+		registry.WithSecretsKey(make([]byte, 32)),
+		registry.WithRequiredNonces(false),
+		registry.WithRequiredResponseSignatures(false),
+		registry.WithEndpointPolicy(registry.EndpointPolicy{Schemes: []string{"https"}}),
+		registry.WithContentStore(store),
+		registry.WithWarnThreshold(0.5),
+		registry.WithPriceNotice(time.Hour),
+		registry.WithAnchorer(nopAnchorer{}),
+		registry.WithWallet(nopWallet{}, []byte("escrow seed")),
+		registry.WithAttestationVerifier("test", trustAll{}),
+		registry.WithRouteStrategy("newest", func([]*registry.RouteCandidate) {}),
+		registry.WithDefaultRouteStrategy("newest"),
+	)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, reg.Close()) }()
+
+	tool, err := reg.RegisterTool(ctx, embeddedTool("pinned"))
+	require.NoError(t, err)
+	require.NotEmpty(t, tool.ManifestCID, "the manifest is pinned to the content store")
+	assert.Contains(t, store, tool.ManifestCID)
+
+	long := embeddedTool("verbose")
+	long.Description = strings.Repeat("x", 21)
+	_, err = reg.RegisterTool(ctx, long)
+	assert.ErrorIs(t, err, registry.ErrLimitExceeded)
+
+	plain := embeddedTool("plain")
+	plain.Endpoint = "http://localhost/embedded"
+	_, err = reg.RegisterTool(ctx, plain)
+	assert.ErrorIs(t, err, registry.ErrInvalidEndpoint)
+
+	assert.True(t, reg.SettlesOnChain())
+	assert.Contains(t, reg.RouteStrategies(), "newest")
+	assert.NoError(t, reg.AnchorReceipts(ctx), "nothing to anchor yet")
+}
+
+func TestOpen_SignedManifests(t *testing.T) {
+	reg, err := registry.Open(":memory:", registry.WithSignedManifests(true))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, reg.Close()) }()
+
+	_, err = reg.RegisterTool(context.Background(), embeddedTool("unsigned"))
+	assert.ErrorIs(t, err, registry.ErrInvalidSignature)
+}
+
+func TestOpen_InvalidSecretsKey(t *testing.T) {
+	_, err := registry.Open(":memory:", registry.WithSecretsKey([]byte("short")))
+	assert.Error(t, err)
+}
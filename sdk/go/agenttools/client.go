@@ -1,14 +1,22 @@
 // Package agenttools provides the Go SDK for agent-tools consumers and providers.
+//
+// The types of tools, in types_gen.go, are generated from the registry's own.
 package agenttools
 
+//go:generate go run ../../../cmd/sdktypes -src ../../../internal/registry -o types_gen.go
+
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
 )
 
 // pricingFree is the free pricing model identifier.
@@ -19,6 +27,11 @@ type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	authToken  string
+	org        string
+	language   string
+	// consumerID and signingKey sign the client's invocations.
+	consumerID string
+	signingKey ed25519.PrivateKey
 }
 
 // ClientOption configures the Client.
@@ -29,6 +42,27 @@ func WithAuthToken(token string) ClientOption {
 	return func(c *Client) { c.authToken = token }
 }
 
+// WithOrg has the client act as an organization the auth token's DID is a
+// member of, so the tools it registers belong to the organization.
+func WithOrg(org string) ClientOption {
+	return func(c *Client) { c.org = org }
+}
+
+// WithLanguage sets the Accept-Language of the client's requests, e.g.
+// "de-CH, de;q=0.9", so tools come with their description in the
+// language preferred among those they have.
+func WithLanguage(acceptLanguage string) ClientOption {
+	return func(c *Client) { c.language = acceptLanguage }
+}
+
+// WithRequestSigning has the client sign its invocations of tools by ID as
+// consumerID, the DID it authenticates as, with key, one of the keys the
+// DID resolves to. The registry checks the signature and passes it on, so
+// providers can verify who is calling without trusting the registry.
+func WithRequestSigning(consumerID string, key ed25519.PrivateKey) ClientOption {
+	return func(c *Client) { c.consumerID, c.signingKey = consumerID, key }
+}
+
 // WithHTTPClient sets a custom HTTP client.
 func WithHTTPClient(hc *http.Client) ClientOption {
 	return func(c *Client) { c.httpClient = hc }
@@ -48,26 +82,6 @@ func NewClient(baseURL string, opts ...ClientOption) *Client {
 	return c
 }
 
-// Tool represents a registered tool.
-type Tool struct {
-	CreatedAt   time.Time `json:"created_at"`
-	Pricing     *Pricing  `json:"pricing"`
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Version     string    `json:"version"`
-	Description string    `json:"description"`
-	ProviderID  string    `json:"provider_id"`
-	Endpoint    string    `json:"endpoint"`
-	Tags        []string  `json:"tags"`
-	TimeoutMS   int64     `json:"timeout_ms"`
-}
-
-// Pricing describes invocation cost.
-type Pricing struct {
-	Model      string `json:"model"`
-	AmountCLAW string `json:"amount_claw,omitempty"`
-}
-
 // String returns a human-readable pricing description.
 func (p *Pricing) String() string {
 	if p == nil || p.Model == pricingFree {
@@ -84,14 +98,46 @@ type RegisterToolRequest struct {
 	Version     string         `json:"version"`
 	Description string         `json:"description"`
 	Endpoint    string         `json:"endpoint"`
-	Tags        []string       `json:"tags,omitempty"`
-	TimeoutMS   int64          `json:"timeout_ms,omitempty"`
+	Cache       *CachePolicy   `json:"cache,omitempty"`
+	// Deterministic declares that the output depends only on the input,
+	// so without a Cache policy results are cached for an hour and served
+	// free of charge.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Features declares what the tool supports: "streaming" for http(s)
+	// tools that may answer with an event stream, "batch" for JSON-RPC
+	// tools that accept batches, "cacheable" or "deterministic" as for
+	// Deterministic.
+	Features []string `json:"features,omitempty"`
+	// Language is the BCP 47 tag of the language of Description ("en" when
+	// empty), and Descriptions are its translations keyed by their tags.
+	Language     string            `json:"language,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	SLA          *SLA              `json:"sla,omitempty"`
+	Quota        *Quota            `json:"quota,omitempty"`
+	// Endpoints are fallbacks or replicas of Endpoint; Routing is
+	// "failover" (the default) or "round_robin".
+	Endpoints []string     `json:"endpoints,omitempty"`
+	Routing   string       `json:"routing,omitempty"`
+	Retry     *RetryPolicy `json:"retry,omitempty"`
+	// Mock lets consumers try the tool for free with InvokeMock.
+	Mock *Mock `json:"mock,omitempty"`
+	// Tests are run by the registry on registration and periodically;
+	// failing ones fail the tool's health.
+	Tests     []ToolTest `json:"tests,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	TimeoutMS int64      `json:"timeout_ms,omitempty"`
+	// CheckCompat records whether the new version's schemas break
+	// consumers of the previous version.
+	CheckCompat bool `json:"check_compat,omitempty"`
 }
 
 // ListToolsRequest is input for listing tools.
 type ListToolsRequest struct {
 	Page  int `json:"page,omitempty"`
 	Limit int `json:"limit,omitempty"`
+	// Cursor continues from the NextCursor of an earlier page, and takes
+	// precedence over Page.
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // ToolList is a paginated list of tools.
@@ -100,6 +146,8 @@ type ToolList struct {
 	Total int     `json:"total"`
 	Page  int     `json:"page"`
 	Limit int     `json:"limit"`
+	// NextCursor fetches the next page; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // SearchOption configures a tool search.
@@ -151,10 +199,41 @@ func (c *Client) GetTool(ctx context.Context, id string) (*Tool, error) {
 	return &tool, nil
 }
 
+// SetToolIcon uploads icon, a PNG, JPEG, GIF or WebP image of at most
+// 256 KiB of the given content type, as the icon of the provider's tool.
+func (c *Client) SetToolIcon(ctx context.Context, toolID, contentType string, icon []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+"/v1/tools/"+url.PathEscape(toolID)+"/icon",
+		bytes.NewReader(icon))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	c.setAuth(req)
+	return c.do(req, nil)
+}
+
+// IconURL returns the URL of the icon of tool, for a catalog to render, or
+// "" when it has none. The URL changes with the icon, so it can be cached.
+func (c *Client) IconURL(tool *Tool) string {
+	if tool.Icon == "" {
+		return ""
+	}
+	return c.baseURL + "/v1/tools/" + url.PathEscape(tool.ID) + "/icon?v=" + url.QueryEscape(tool.Icon)
+}
+
+// BadgeURL returns the URL of the SVG badge showing whether tool passes
+// its conformance tests, for a provider to embed in its documentation.
+func (c *Client) BadgeURL(tool *Tool) string {
+	return c.baseURL + "/v1/tools/" + url.PathEscape(tool.ID) + "/badge.svg"
+}
+
 // ListTools returns paginated tools.
 func (c *Client) ListTools(ctx context.Context, req *ListToolsRequest) (*ToolList, error) {
 	path := "/v1/tools"
-	if req != nil {
+	switch {
+	case req != nil && req.Cursor != "":
+		path += fmt.Sprintf("?cursor=%s&limit=%d", url.QueryEscape(req.Cursor), req.Limit)
+	case req != nil:
 		path += fmt.Sprintf("?page=%d&limit=%d", req.Page, req.Limit)
 	}
 	var list ToolList
@@ -186,6 +265,463 @@ func (c *Client) SearchTools(ctx context.Context, query string, opts ...SearchOp
 	return &result, nil
 }
 
+// Collection is a version of a named bundle of tools, with the tools an
+// agent installs from it.
+type Collection struct {
+	CreatedAt   time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Description string    `json:"description,omitempty"`
+	OwnerID     string    `json:"owner_id"`
+	ToolIDs     []string  `json:"tool_ids"`
+	Tags        []string  `json:"tags"`
+	// Tools are the tools of the collection that can be invoked.
+	Tools []*Tool `json:"tools"`
+	// Unavailable lists the IDs of tools that were deactivated, revoked or
+	// deleted since the version was published.
+	Unavailable []string `json:"unavailable"`
+}
+
+// GetCollection retrieves a version of a collection with its tools; an
+// empty version retrieves the latest.
+func (c *Client) GetCollection(ctx context.Context, name, version string) (*Collection, error) {
+	path := "/v1/collections/" + url.PathEscape(name)
+	if version != "" {
+		path += "?version=" + url.QueryEscape(version)
+	}
+	var coll Collection
+	if err := c.get(ctx, path, &coll); err != nil {
+		return nil, err
+	}
+	return &coll, nil
+}
+
+// InvokeResponse is the result of a tool invocation.
+type InvokeResponse struct {
+	Output       map[string]any `json:"output"`
+	InvocationID string         `json:"invocation_id"`
+	ToolID       string         `json:"tool_id"`
+	CostCLAW     string         `json:"cost_claw,omitempty"`
+	DurationMS   int64          `json:"duration_ms"`
+	Cached       bool           `json:"cached,omitempty"` // served from the registry's result cache
+	CacheHit     bool           `json:"cache_hit,omitempty"`
+	Replayed     bool           `json:"replayed,omitempty"` // the result of an earlier call with the same idempotency key
+	Mock         bool           `json:"mock,omitempty"`     // answered by the tool's mock responder
+	// Metadata is what the call was sent with by InvokeWithMetadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Receipt is the registry's record of the call, kept for ListReceipts.
+	Receipt *Receipt `json:"receipt,omitempty"`
+}
+
+// Receipt records a completed invocation: who called which tool, the
+// hashes of its input and output and what it cost. Verify it offline with
+// receipts.Verify.
+type Receipt = receipts.Receipt
+
+// HashPayload returns the hash a receipt records for an input or output:
+// the SHA-256 of its canonical JSON, as the registry computes it.
+func HashPayload(v any) string {
+	return receipts.Hash(v)
+}
+
+// Invoke calls a tool with input and returns its output.
+func (c *Client) Invoke(ctx context.Context, toolID string, input map[string]any) (*InvokeResponse, error) {
+	return c.InvokeIdempotent(ctx, toolID, "", input)
+}
+
+// InvokeIdempotent calls a tool like Invoke, with an idempotency key: for
+// 24 hours, calls with the same key and input get the result of the first
+// that succeeded instead of invoking, and paying for, the tool again.
+func (c *Client) InvokeIdempotent(ctx context.Context, toolID, key string, input map[string]any) (*InvokeResponse, error) {
+	if input == nil {
+		input = map[string]any{}
+	}
+	var resp InvokeResponse
+	body := map[string]any{"tool_id": toolID, "input": input}
+	if key != "" {
+		body["idempotency_key"] = key
+	}
+	c.sign(body, toolID, input)
+	if err := c.post(ctx, "/v1/invoke", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// InvokeWithMetadata calls a tool like Invoke, attaching metadata such as
+// a task ID, a parent trace or a session. The registry stores it with the
+// invocation, returns it in the response and filters the invocation list
+// by it (?metadata.<key>=<value>). At most 16 entries; keys are letters,
+// digits, '_', '.' and '-'.
+func (c *Client) InvokeWithMetadata(
+	ctx context.Context, toolID string, metadata map[string]string, input map[string]any,
+) (*InvokeResponse, error) {
+	if input == nil {
+		input = map[string]any{}
+	}
+	var resp InvokeResponse
+	body := map[string]any{"tool_id": toolID, "input": input, "metadata": metadata}
+	c.sign(body, toolID, input)
+	if err := c.post(ctx, "/v1/invoke", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// InvokeMock calls a tool like Invoke, but has the tool's mock responder
+// answer instead of its provider, free of charge, for integration tests.
+// The receipt is marked mock. Tools registered without a mock responder
+// fail with NO_MOCK.
+func (c *Client) InvokeMock(ctx context.Context, toolID string, input map[string]any) (*InvokeResponse, error) {
+	if input == nil {
+		input = map[string]any{}
+	}
+	var resp InvokeResponse
+	body := map[string]any{"tool_id": toolID, "input": input, "mock": true}
+	if err := c.post(ctx, "/v1/invoke", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// sign adds the consumer signature of a call of toolID with input to body,
+// when the client signs its invocations.
+func (c *Client) sign(body map[string]any, toolID string, input map[string]any) {
+	if c.signingKey != nil {
+		body["consumer_signature"] = receipts.SignRequest(toolID, c.consumerID, input, "", time.Now(), c.signingKey)
+	}
+}
+
+// InvokeCapability calls whichever tool named capability the registry's
+// routing strategy picks: cheapest, lowest_latency, highest_reputation or,
+// when empty, the registry's default. The response names the tool called.
+// Calls by capability are not signed, since the tool is only known once
+// the registry picked it.
+func (c *Client) InvokeCapability(ctx context.Context, capability, strategy string, input map[string]any) (*InvokeResponse, error) {
+	if input == nil {
+		input = map[string]any{}
+	}
+	var resp InvokeResponse
+	body := map[string]any{"capability": capability, "input": input}
+	if strategy != "" {
+		body["strategy"] = strategy
+	}
+	if err := c.post(ctx, "/v1/invoke", body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListReceiptsRequest narrows the receipts listed; zero fields are
+// ignored.
+type ListReceiptsRequest struct {
+	// Consumer lists only the receipts of calls by that agent.
+	Consumer string
+	ToolID   string
+	// From and To bound the UTC dates the calls completed on, both
+	// inclusive.
+	From, To time.Time
+	Cursor   string
+	Limit    int
+}
+
+// ReceiptList is a page of receipts, newest first.
+type ReceiptList struct {
+	Receipts []*Receipt `json:"receipts"`
+	// NextCursor fetches the next page; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListReceipts returns the receipts of the caller's invocations and of
+// invocations of its tools, to reconcile spend.
+func (c *Client) ListReceipts(ctx context.Context, req *ListReceiptsRequest) (*ReceiptList, error) {
+	q := url.Values{}
+	if req != nil {
+		if req.Consumer != "" {
+			q.Set("consumer", req.Consumer)
+		}
+		if req.ToolID != "" {
+			q.Set("tool", req.ToolID)
+		}
+		if !req.From.IsZero() {
+			q.Set("from", req.From.UTC().Format(time.DateOnly))
+		}
+		if !req.To.IsZero() {
+			q.Set("to", req.To.UTC().Format(time.DateOnly))
+		}
+		if req.Cursor != "" {
+			q.Set("cursor", req.Cursor)
+		}
+		if req.Limit > 0 {
+			q.Set("limit", strconv.Itoa(req.Limit))
+		}
+	}
+	path := "/v1/receipts"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var list ReceiptList
+	if err := c.get(ctx, path, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetReceipt retrieves a receipt by ID.
+func (c *Client) GetReceipt(ctx context.Context, id string) (*Receipt, error) {
+	var rcpt Receipt
+	if err := c.get(ctx, "/v1/receipts/"+url.PathEscape(id), &rcpt); err != nil {
+		return nil, err
+	}
+	return &rcpt, nil
+}
+
+// AttachAttestation attaches the quote of the trusted execution environment
+// the caller, as the provider of the tool, ran the invocation of receipt id
+// in. format names the kind of quote, such as "sgx-dcap" or "sev-snp"; its
+// report data must be receipts.ReportData of the receipt. A receipt takes
+// one attestation, before it is anchored.
+func (c *Client) AttachAttestation(ctx context.Context, id, format string, quote []byte) (*Receipt, error) {
+	var rcpt Receipt
+	body := receipts.Attestation{Format: format, Quote: quote}
+	if err := c.send(ctx, http.MethodPut, "/v1/receipts/"+url.PathEscape(id)+"/attestation", body, &rcpt); err != nil {
+		return nil, err
+	}
+	return &rcpt, nil
+}
+
+// InvocationRecord is the registry's record of one of the caller's
+// invocations.
+type InvocationRecord struct {
+	ID         string `json:"id"`
+	ToolID     string `json:"tool_id"`
+	ConsumerID string `json:"consumer_id"`
+	InputHash  string `json:"input_hash"`
+	OutputHash string `json:"output_hash,omitempty"`
+	// Status is queued, pending, completed, failed, dead_letter or
+	// interrupted.
+	Status string `json:"status"`
+	// CostCLAW is what a completed invocation was charged; failed ones are
+	// not charged.
+	CostCLAW    string     `json:"cost_claw,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Cached      bool       `json:"cached,omitempty"`
+	Mock        bool       `json:"mock,omitempty"`
+	Attempts    int        `json:"attempts,omitempty"`
+	// ProviderError is what the provider said about a failed invocation.
+	ProviderError *ProviderError `json:"provider_error,omitempty"`
+	// Metadata is what the call was sent with by InvokeWithMetadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ProviderError is what a provider said about an invocation that failed.
+type ProviderError struct {
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Retryable bool   `json:"retryable"`
+	Status    int    `json:"status,omitempty"`
+}
+
+// ListInvocationsRequest narrows the invocations listed; zero fields are
+// ignored.
+type ListInvocationsRequest struct {
+	ToolID string
+	Status string
+	// Metadata lists only the invocations holding all of its entries.
+	Metadata map[string]string
+	Cursor   string
+	Limit    int
+}
+
+// InvocationList is a page of invocations, newest first.
+type InvocationList struct {
+	Invocations []*InvocationRecord `json:"invocations"`
+	// NextCursor fetches the next page; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListInvocations returns a page of the caller's invocations, newest
+// first. Pass NextCursor back as Cursor until it is empty to walk the whole
+// history, e.g. to add up what the agent spent on each tool.
+func (c *Client) ListInvocations(ctx context.Context, req *ListInvocationsRequest) (*InvocationList, error) {
+	q := url.Values{}
+	if req != nil {
+		if req.ToolID != "" {
+			q.Set("tool_id", req.ToolID)
+		}
+		if req.Status != "" {
+			q.Set("status", req.Status)
+		}
+		for k, v := range req.Metadata {
+			q.Set("metadata."+k, v)
+		}
+		if req.Cursor != "" {
+			q.Set("cursor", req.Cursor)
+		}
+		if req.Limit > 0 {
+			q.Set("limit", strconv.Itoa(req.Limit))
+		}
+	}
+	path := "/v1/invocations"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var list InvocationList
+	if err := c.get(ctx, path, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// SpendForecast projects the caller's spend to the end of the current UTC
+// day or month at the pace of its last seven days.
+type SpendForecast struct {
+	ConsumerID  string    `json:"consumer_id"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	SpentCLAW   string    `json:"spent_claw"`
+	// DailyRateCLAW is the average daily spend of the last seven days.
+	DailyRateCLAW string `json:"daily_rate_claw"`
+	// Trend is how much more, as a fraction, was spent in the last seven
+	// days than in the seven before; nil without spend in the earlier week.
+	Trend         *float64 `json:"trend"`
+	ProjectedCLAW string   `json:"projected_claw"`
+	CapCLAW       string   `json:"cap_claw,omitempty"`
+	// ExhaustedAt is when the cap is, or was, reached at the daily rate, if
+	// within the period.
+	ExhaustedAt *time.Time   `json:"exhausted_at,omitempty"`
+	ByTool      []*ToolSpend `json:"by_tool"`
+}
+
+// ToolSpend is what was spent on a tool in a forecast's period.
+type ToolSpend struct {
+	ToolID    string `json:"tool_id"`
+	SpentCLAW string `json:"spent_claw"`
+	Calls     int64  `json:"calls"`
+}
+
+// ForecastSpend returns the spend forecast of consumerID, the DID the
+// client authenticates as, for period, "day" or "month" ("" is month).
+// With a capCLAW, ExhaustedAt tells the agent when to slow down to stay
+// under it.
+func (c *Client) ForecastSpend(ctx context.Context, consumerID, period, capCLAW string) (*SpendForecast, error) {
+	q := url.Values{}
+	if period != "" {
+		q.Set("period", period)
+	}
+	if capCLAW != "" {
+		q.Set("cap_claw", capCLAW)
+	}
+	path := "/v1/consumers/" + url.PathEscape(consumerID) + "/forecast"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var f SpendForecast
+	if err := c.get(ctx, path, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// WalletInfo is the caller's escrow account, which it funds by sending CLAW
+// to EscrowAddress when the registry settles payments on ClawChain, and its
+// own account, which withdrawals are sent to.
+type WalletInfo struct {
+	ConsumerID    string `json:"consumer_id"`
+	EscrowAddress string `json:"escrow_address"`
+	EscrowCLAW    string `json:"escrow_claw"`
+	// HeldCLAW is held for invocations not settled yet and withdrawals
+	// being sent; AvailableCLAW is what invocations and withdrawals may use.
+	HeldCLAW      string `json:"held_claw"`
+	AvailableCLAW string `json:"available_claw"`
+	Address       string `json:"address,omitempty"`
+	BalanceCLAW   string `json:"balance_claw,omitempty"`
+}
+
+// Withdrawal is a transfer from the caller's escrow account to its own.
+type Withdrawal struct {
+	ID         string `json:"id"`
+	ConsumerID string `json:"consumer_id"`
+	AmountCLAW string `json:"amount_claw"`
+	Address    string `json:"address"`
+	TxHash     string `json:"tx_hash"`
+}
+
+// Wallet returns the escrow account of the DID the client authenticates
+// as, which takes a proven API key. Paid invocations fail with
+// INSUFFICIENT_FUNDS when its available CLAW cannot cover their estimated
+// cost.
+func (c *Client) Wallet(ctx context.Context) (*WalletInfo, error) {
+	var info WalletInfo
+	if err := c.get(ctx, "/v1/wallet", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Withdraw sends amountCLAW, a decimal string, from the caller's escrow
+// account to its own account.
+func (c *Client) Withdraw(ctx context.Context, amountCLAW string) (*Withdrawal, error) {
+	var wd Withdrawal
+	if err := c.post(ctx, "/v1/wallet/withdraw", map[string]string{"amount_claw": amountCLAW}, &wd); err != nil {
+		return nil, err
+	}
+	return &wd, nil
+}
+
+// DIDDocument is the DID document of the registry: its DID, the keys it
+// signs calls to providers with and the endpoints it offers.
+type DIDDocument struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+	Service            []DIDService         `json:"service,omitempty"`
+}
+
+// VerificationMethod is a key of a DID document. The registry's are
+// Multikeys: base58btc multibase Ed25519 keys.
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller,omitempty"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+}
+
+// DIDService is an endpoint of a DID document. The registry lists its API
+// ("AgentToolsRegistry"), the base URL peers federate with
+// ("AgentToolsFederation"), its A2A agent card ("A2AAgentCard") and its
+// event stream ("AgentToolsEvents").
+type DIDService struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// ServiceEndpoint returns the endpoint of the first service of type typ,
+// or "" when d has none.
+func (d *DIDDocument) ServiceEndpoint(typ string) string {
+	for _, s := range d.Service {
+		if s.Type == typ {
+			return s.ServiceEndpoint
+		}
+	}
+	return ""
+}
+
+// RegistryDID returns the DID document of the registry.
+func (c *Client) RegistryDID(ctx context.Context) (*DIDDocument, error) {
+	var doc DIDDocument
+	if err := c.get(ctx, "/.well-known/did.json", &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
 // Healthz checks the registry health.
 func (c *Client) Healthz(ctx context.Context) error {
 	return c.get(ctx, "/healthz", nil)
@@ -201,11 +737,15 @@ func (c *Client) get(ctx context.Context, path string, out any) error {
 }
 
 func (c *Client) post(ctx context.Context, path string, body, out any) error {
+	return c.send(ctx, http.MethodPost, path, body, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body, out any) error {
 	b, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(b))
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
@@ -218,6 +758,12 @@ func (c *Client) setAuth(req *http.Request) {
 	if c.authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.authToken)
 	}
+	if c.org != "" {
+		req.Header.Set("X-Org", c.org)
+	}
+	if c.language != "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
 }
 
 type apiErrorResponse struct {
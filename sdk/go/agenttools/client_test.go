@@ -1,13 +1,16 @@
 package agenttools_test
 
 import (
+	"cmp"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/clawinfra/agent-tools/pkg/receipts"
 	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -56,6 +59,32 @@ func TestNewClient_WithAuthToken(t *testing.T) {
 	assert.Equal(t, "Bearer mytoken", gotAuth)
 }
 
+func TestNewClient_WithOrg(t *testing.T) {
+	var gotOrg string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOrg = r.Header.Get("X-Org")
+		writeJSON(w, 200, map[string]string{"status": "ok"})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL, agenttools.WithAuthToken("mytoken"), agenttools.WithOrg("audit-team"))
+	require.NoError(t, c.Healthz(context.Background()))
+	assert.Equal(t, "audit-team", gotOrg)
+}
+
+func TestNewClient_WithLanguage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "de-CH, de;q=0.9", r.Header.Get("Accept-Language"))
+		writeJSON(w, 200, map[string]any{"id": "tool-abc", "description": "Prüft Verträge", "language": "de"})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL, agenttools.WithLanguage("de-CH, de;q=0.9"))
+	tool, err := c.GetTool(context.Background(), "tool-abc")
+	require.NoError(t, err)
+	assert.Equal(t, "de", tool.Language)
+}
+
 func TestNewClient_WithHTTPClient(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 200, map[string]string{"status": "ok"})
@@ -107,6 +136,26 @@ func TestHealthz_HTTPError_NoBody(t *testing.T) {
 	assert.Contains(t, err.Error(), "500")
 }
 
+func TestRegistryDID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/.well-known/did.json", r.URL.Path)
+		writeJSON(w, 200, map[string]any{
+			"@context": []string{"https://www.w3.org/ns/did/v1"},
+			"id":       "did:web:registry.example.com",
+			"service": []map[string]string{
+				{"id": "did:web:registry.example.com#federation", "type": "AgentToolsFederation", "serviceEndpoint": "https://registry.example.com"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	doc, err := agenttools.NewClient(srv.URL).RegistryDID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "did:web:registry.example.com", doc.ID)
+	assert.Equal(t, "https://registry.example.com", doc.ServiceEndpoint("AgentToolsFederation"))
+	assert.Empty(t, doc.ServiceEndpoint("AgentToolsEvents"))
+}
+
 // --- RegisterTool ---
 
 func TestRegisterTool_OK(t *testing.T) {
@@ -175,6 +224,21 @@ func TestGetTool_NotFound(t *testing.T) {
 
 // --- ListTools ---
 
+func TestSetToolIcon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/v1/tools/tool-abc/icon", r.URL.Path)
+		assert.Equal(t, "image/png", r.Header.Get("Content-Type"))
+		writeJSON(w, 200, map[string]any{"digest": "sha256:00", "content_type": "image/png", "size": 4})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	require.NoError(t, c.SetToolIcon(context.Background(), "tool-abc", "image/png", []byte("\x89PNG")))
+	assert.Equal(t, srv.URL+"/v1/tools/tool-abc/icon?v=sha256%3A00", c.IconURL(&agenttools.Tool{ID: "tool-abc", Icon: "sha256:00"}))
+	assert.Empty(t, c.IconURL(&agenttools.Tool{ID: "tool-abc"}))
+}
+
 func TestListTools_OK(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "/v1/tools", r.URL.Path)
@@ -208,6 +272,23 @@ func TestListTools_WithPagination(t *testing.T) {
 	assert.Empty(t, list.Tools)
 }
 
+func TestListTools_WithCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc", r.URL.Query().Get("cursor"))
+		assert.Empty(t, r.URL.Query().Get("page"))
+		writeJSON(w, 200, map[string]any{
+			"tools": []map[string]any{toolJSON("t1", "tool-1")},
+			"total": 30, "limit": 10, "next_cursor": "def",
+		})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	list, err := c.ListTools(context.Background(), &agenttools.ListToolsRequest{Page: 2, Limit: 10, Cursor: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "def", list.NextCursor)
+}
+
 // --- SearchTools ---
 
 func TestSearchTools_OK(t *testing.T) {
@@ -252,6 +333,110 @@ func TestSearchTools_WithOptions(t *testing.T) {
 	assert.Empty(t, result.Tools)
 }
 
+// --- Invoke ---
+
+func TestInvoke_OK(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/invoke", r.URL.Path)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "tool-abc", body["tool_id"])
+		assert.Equal(t, map[string]any{"q": "hi"}, body["input"])
+		writeJSON(w, 200, map[string]any{
+			"invocation_id": "inv-1",
+			"tool_id":       "tool-abc",
+			"output":        map[string]any{"answer": "hello"},
+			"cost_claw":     "0.5",
+			"duration_ms":   12,
+		})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	resp, err := c.Invoke(context.Background(), "tool-abc", map[string]any{"q": "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "inv-1", resp.InvocationID)
+	assert.Equal(t, "hello", resp.Output["answer"])
+	assert.Equal(t, "0.5", resp.CostCLAW)
+}
+
+func TestInvokeIdempotent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "key-1", body["idempotency_key"])
+		writeJSON(w, 200, map[string]any{"invocation_id": "inv-1", "output": map[string]any{}, "replayed": true})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	resp, err := c.InvokeIdempotent(context.Background(), "tool-abc", "key-1", nil)
+	require.NoError(t, err)
+	assert.True(t, resp.Replayed)
+}
+
+func TestInvokeWithMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		writeJSON(w, 200, map[string]any{"invocation_id": "inv-1", "output": map[string]any{}, "metadata": body["metadata"]})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	resp, err := c.InvokeWithMetadata(context.Background(), "tool-abc", map[string]string{"task_id": "t-9"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"task_id": "t-9"}, resp.Metadata)
+}
+
+func TestInvokeMock(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["mock"])
+		writeJSON(w, 200, map[string]any{"invocation_id": "inv-1", "output": map[string]any{"a": "pong"}, "mock": true})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	resp, err := c.InvokeMock(context.Background(), "tool-abc", map[string]any{"q": "ping"})
+	require.NoError(t, err)
+	assert.True(t, resp.Mock)
+	assert.Equal(t, "pong", resp.Output["a"])
+}
+
+func TestInvokeCapability(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "translate", body["capability"])
+		assert.Equal(t, "lowest_latency", body["strategy"])
+		assert.NotContains(t, body, "tool_id")
+		writeJSON(w, 200, map[string]any{"invocation_id": "inv-1", "tool_id": "tool-fast", "output": map[string]any{}})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	resp, err := c.InvokeCapability(context.Background(), "translate", "lowest_latency", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "tool-fast", resp.ToolID)
+}
+
+func TestInvoke_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, 502, map[string]any{
+			"error": map[string]string{"code": "TOOL_FAILED", "message": "boom"},
+		})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	_, err := c.Invoke(context.Background(), "tool-abc", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TOOL_FAILED")
+}
+
 // --- Pricing.String ---
 
 func TestPricing_String_Free(t *testing.T) {
@@ -290,3 +475,228 @@ func TestRegisterTool_NetworkError(t *testing.T) {
 	_, err := c.RegisterTool(context.Background(), &agenttools.RegisterToolRequest{})
 	assert.Error(t, err)
 }
+
+func TestListReceipts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/receipts/rcpt-1" {
+			writeJSON(w, 200, map[string]any{"id": "rcpt-1", "invocation_id": "inv-1", "cost_claw": "5.0"})
+			return
+		}
+		assert.Equal(t, "/v1/receipts", r.URL.Path)
+		assert.Equal(t, "consumer=did%3Aclaw%3Aagent%3Aa&from=2026-01-01&limit=10&to=2026-01-31&tool=tool-abc", r.URL.RawQuery)
+		writeJSON(w, 200, map[string]any{"receipts": []any{map[string]any{"id": "rcpt-1"}}, "next_cursor": "c2"})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	list, err := c.ListReceipts(context.Background(), &agenttools.ListReceiptsRequest{
+		Consumer: "did:claw:agent:a",
+		ToolID:   "tool-abc",
+		From:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Receipts, 1)
+	assert.Equal(t, "c2", list.NextCursor)
+
+	rcpt, err := c.GetReceipt(context.Background(), "rcpt-1")
+	require.NoError(t, err)
+	assert.Equal(t, "inv-1", rcpt.InvocationID)
+	assert.Equal(t, "5.0", rcpt.CostCLAW)
+}
+
+func TestAttachAttestation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/v1/receipts/rcpt-1/attestation", r.URL.Path)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"format": "sev-snp", "quote": "cXVvdGU="}, body)
+		writeJSON(w, 200, map[string]any{"id": "rcpt-1", "attestation": body})
+	}))
+	defer srv.Close()
+
+	rcpt, err := agenttools.NewClient(srv.URL).AttachAttestation(context.Background(), "rcpt-1", "sev-snp", []byte("quote"))
+	require.NoError(t, err)
+	require.NotNil(t, rcpt.Attestation)
+	assert.Equal(t, []byte("quote"), rcpt.Attestation.Quote)
+}
+
+func TestListInvocations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/invocations", r.URL.Path)
+		if r.URL.Query().Get("cursor") == "" {
+			assert.Equal(t, "limit=2&metadata.task_id=t1&status=completed&tool_id=tool-abc", r.URL.RawQuery)
+			writeJSON(w, 200, map[string]any{"invocations": []any{
+				map[string]any{"id": "inv-2", "status": "completed", "cost_claw": "2.5", "metadata": map[string]string{"task_id": "t1"}},
+				map[string]any{"id": "inv-1", "status": "completed", "cost_claw": "1"},
+			}, "next_cursor": "c2"})
+			return
+		}
+		assert.Equal(t, "c2", r.URL.Query().Get("cursor"))
+		writeJSON(w, 200, map[string]any{"invocations": []any{map[string]any{"id": "inv-0", "status": "completed"}}})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	req := &agenttools.ListInvocationsRequest{
+		ToolID: "tool-abc", Status: "completed", Metadata: map[string]string{"task_id": "t1"}, Limit: 2,
+	}
+	var ids []string
+	for {
+		list, err := c.ListInvocations(context.Background(), req)
+		require.NoError(t, err)
+		for _, inv := range list.Invocations {
+			ids = append(ids, inv.ID)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		req.Cursor = list.NextCursor
+	}
+	assert.Equal(t, []string{"inv-2", "inv-1", "inv-0"}, ids)
+}
+
+func TestForecastSpend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/consumers/did:claw:agent:me/forecast", r.URL.Path)
+		assert.Equal(t, "cap_claw=50&period=day", r.URL.RawQuery)
+		writeJSON(w, 200, map[string]any{"period": "day", "spent_claw": "40", "projected_claw": "80",
+			"exhausted_at": "2026-10-16T18:00:00Z", "by_tool": []any{map[string]any{"tool_id": "tool-abc", "calls": 8}}})
+	}))
+	defer srv.Close()
+
+	f, err := agenttools.NewClient(srv.URL).ForecastSpend(context.Background(), "did:claw:agent:me", "day", "50")
+	require.NoError(t, err)
+	assert.Equal(t, "80", f.ProjectedCLAW)
+	require.NotNil(t, f.ExhaustedAt)
+	assert.Equal(t, 18, f.ExhaustedAt.Hour())
+	require.Len(t, f.ByTool, 1)
+	assert.Equal(t, int64(8), f.ByTool[0].Calls)
+}
+
+func TestWallet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/wallet":
+			writeJSON(w, 200, map[string]any{"escrow_address": "5Escrow", "escrow_claw": "10", "held_claw": "2.5",
+				"available_claw": "7.5"})
+		case "POST /v1/wallet/withdraw":
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "7.5", body["amount_claw"])
+			writeJSON(w, 200, map[string]any{"amount_claw": "7.5", "address": "5Own", "tx_hash": "0xfeed"})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	c := agenttools.NewClient(srv.URL)
+
+	info, err := c.Wallet(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "5Escrow", info.EscrowAddress)
+	assert.Equal(t, "7.5", info.AvailableCLAW)
+	wd, err := c.Withdraw(context.Background(), info.AvailableCLAW)
+	require.NoError(t, err)
+	assert.Equal(t, "0xfeed", wd.TxHash)
+}
+
+func TestInvoke_RequestSigning(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	input := map[string]any{"q": "audit"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Signature *receipts.RequestSignature `json:"consumer_signature"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if assert.NotNil(t, body.Signature) {
+			assert.NoError(t, receipts.VerifyRequest(body.Signature, input))
+			assert.Equal(t, "tool-abc", body.Signature.ToolID)
+			assert.Equal(t, "did:key:z6Mk", body.Signature.ConsumerID)
+			assert.Equal(t, agenttools.PubKey(key), body.Signature.PubKey)
+		}
+		writeJSON(w, 200, map[string]any{"invocation_id": "inv-1", "output": map[string]any{}})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL, agenttools.WithRequestSigning("did:key:z6Mk", key))
+	_, err = c.Invoke(context.Background(), "tool-abc", input)
+	require.NoError(t, err)
+	_, err = c.InvokeWithMetadata(context.Background(), "tool-abc", map[string]string{"task_id": "t1"}, input)
+	require.NoError(t, err)
+}
+
+// --- Collections ---
+
+func TestGetCollection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/collections/audit suite", r.URL.Path)
+		version := r.URL.Query().Get("version")
+		writeJSON(w, 200, map[string]any{
+			"name": "audit suite", "version": cmp.Or(version, "2.0.0"), "tool_ids": []string{"tool-abc"},
+			"tools": []any{toolJSON("tool-abc", "abc")},
+		})
+	}))
+	defer srv.Close()
+
+	c := agenttools.NewClient(srv.URL)
+	coll, err := c.GetCollection(context.Background(), "audit suite", "")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", coll.Version, "the latest version")
+	require.Len(t, coll.Tools, 1)
+	assert.Equal(t, srv.URL+"/v1/tools/tool-abc/badge.svg", c.BadgeURL(coll.Tools[0]))
+
+	coll, err = c.GetCollection(context.Background(), "audit suite", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", coll.Version)
+}
+
+// --- Errors ---
+
+func TestClient_RequestErrors(t *testing.T) {
+	ctx := context.Background()
+	c := agenttools.NewClient("http://\x7f")
+	calls := map[string]func() error{
+		"RegisterTool": func() error { _, err := c.RegisterTool(ctx, &agenttools.RegisterToolRequest{}); return err },
+		"GetTool":      func() error { _, err := c.GetTool(ctx, "t"); return err },
+		"SetToolIcon":  func() error { return c.SetToolIcon(ctx, "t", "image/png", nil) },
+		"ListTools":    func() error { _, err := c.ListTools(ctx, nil); return err },
+		"SearchTools":  func() error { _, err := c.SearchTools(ctx, "q"); return err },
+		"GetCollection": func() error {
+			_, err := c.GetCollection(ctx, "c", "")
+			return err
+		},
+		"Invoke":         func() error { _, err := c.Invoke(ctx, "t", nil); return err },
+		"InvokeMetadata": func() error { _, err := c.InvokeWithMetadata(ctx, "t", nil, nil); return err },
+		"InvokeMock":     func() error { _, err := c.InvokeMock(ctx, "t", nil); return err },
+		"InvokeCapability": func() error {
+			_, err := c.InvokeCapability(ctx, "weather", "", nil)
+			return err
+		},
+		"ListReceipts": func() error {
+			_, err := c.ListReceipts(ctx, &agenttools.ListReceiptsRequest{Cursor: "next"})
+			return err
+		},
+		"GetReceipt":        func() error { _, err := c.GetReceipt(ctx, "r"); return err },
+		"AttachAttestation": func() error { _, err := c.AttachAttestation(ctx, "r", "tdx", nil); return err },
+		"ListInvocations":   func() error { _, err := c.ListInvocations(ctx, nil); return err },
+		"ForecastSpend":     func() error { _, err := c.ForecastSpend(ctx, "", "", ""); return err },
+		"Wallet":            func() error { _, err := c.Wallet(ctx); return err },
+		"Withdraw":          func() error { _, err := c.Withdraw(ctx, "1"); return err },
+		"RegistryDID":       func() error { _, err := c.RegistryDID(ctx); return err },
+	}
+	for name, call := range calls {
+		assert.Error(t, call(), name)
+	}
+
+	_, err := agenttools.NewClient("http://localhost").Invoke(ctx, "t", map[string]any{"f": func() {}})
+	assert.ErrorContains(t, err, "unsupported type", "input that is not JSON")
+}
+
+func TestHashPayload(t *testing.T) {
+	assert.Equal(t, agenttools.HashPayload(map[string]any{"a": 1, "b": 2}), agenttools.HashPayload(map[string]any{"b": 2, "a": 1}),
+		"canonical JSON")
+}
@@ -0,0 +1,166 @@
+package agenttools
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+)
+
+// DefaultClockSkew is how far the clocks of a provider and the registry
+// may drift apart before a ReceiptSigner refuses to sign.
+const DefaultClockSkew = 2 * time.Minute
+
+// ErrClockSkew is returned by ReceiptSigner.Sign for an invocation
+// requested further from the provider's clock than the allowed skew.
+var ErrClockSkew = errors.New("invocation time is outside the allowed clock skew")
+
+// ReceiptSigner builds and signs the receipts of a provider's invocations,
+// so every provider hashes and formats them the way the registry and
+// receipts.Verify expect.
+type ReceiptSigner struct {
+	providerID string
+	key        ed25519.PrivateKey
+	skew       time.Duration
+	now        func() time.Time
+}
+
+// SignerOption configures a ReceiptSigner.
+type SignerOption func(*ReceiptSigner)
+
+// WithClockSkew sets how far an invocation's RequestedAt may be from the
+// provider's clock, either way. The default is DefaultClockSkew.
+func WithClockSkew(d time.Duration) SignerOption {
+	return func(s *ReceiptSigner) { s.skew = d }
+}
+
+// WithSignerClock sets the clock receipts are stamped with, for tests.
+func WithSignerClock(now func() time.Time) SignerOption {
+	return func(s *ReceiptSigner) { s.now = now }
+}
+
+// NewReceiptSigner returns a signer of receipts for the provider with the
+// given ID and key.
+func NewReceiptSigner(providerID string, key ed25519.PrivateKey, opts ...SignerOption) *ReceiptSigner {
+	s := &ReceiptSigner{providerID: providerID, key: key, skew: DefaultClockSkew, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// PubKey returns the signer's public key in the form providers register,
+// "ed25519:<hex>".
+func (s *ReceiptSigner) PubKey() string {
+	return PubKey(s.key)
+}
+
+// Invocation identifies the call a receipt is signed for, as the registry
+// sent it to the provider.
+type Invocation struct {
+	ID         string
+	ToolID     string
+	ConsumerID string
+	CostCLAW   string
+	// RequestedAt is when the registry sent the call. Zero skips the clock
+	// skew check.
+	RequestedAt time.Time
+}
+
+// Sign returns the signed receipt of inv with the hashes of input and
+// output, which may be decoded values or json.RawMessage. It returns
+// ErrClockSkew when inv.RequestedAt is too far from the provider's clock.
+func (s *ReceiptSigner) Sign(inv Invocation, input, output any) (*Receipt, error) {
+	if inv.ID == "" || inv.ToolID == "" || inv.ConsumerID == "" {
+		return nil, fmt.Errorf("invocation ID, tool ID and consumer ID are required")
+	}
+	now := s.now().UTC()
+	if !inv.RequestedAt.IsZero() {
+		if d := now.Sub(inv.RequestedAt); d > s.skew || d < -s.skew {
+			return nil, fmt.Errorf("%w: requested at %s, now %s", ErrClockSkew, inv.RequestedAt.UTC().Format(time.RFC3339), now.Format(time.RFC3339))
+		}
+		// A provider clock running behind must not date the execution
+		// before the request.
+		if now.Before(inv.RequestedAt) {
+			now = inv.RequestedAt.UTC()
+		}
+	}
+	r := &Receipt{
+		InvocationID: inv.ID,
+		ToolID:       inv.ToolID,
+		ConsumerID:   inv.ConsumerID,
+		ProviderID:   s.providerID,
+		InputHash:    receipts.Hash(input),
+		OutputHash:   receipts.Hash(output),
+		CostCLAW:     inv.CostCLAW,
+		ExecutedAt:   now.Truncate(time.Second),
+	}
+	receipts.Sign(r, s.key)
+	return r, nil
+}
+
+// PubKey returns the public key of key as "ed25519:<hex>".
+func PubKey(key ed25519.PrivateKey) string {
+	return receipts.SigPrefix + hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// EncodeSigningKey returns key as "ed25519:<hex seed>", the form
+// ParseSigningKey reads.
+func EncodeSigningKey(key ed25519.PrivateKey) string {
+	return receipts.SigPrefix + hex.EncodeToString(key.Seed())
+}
+
+// ParseSigningKey decodes an Ed25519 private key given as a PKCS #8 PEM
+// block, as written by "openssl genpkey -algorithm ed25519", or as the hex
+// or base64 of its 32-byte seed or 64-byte private key, optionally prefixed
+// with "ed25519:".
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse signing key: %w", err)
+		}
+		key, ok := k.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("parse signing key: %T is not an Ed25519 key", k)
+		}
+		return key, nil
+	}
+	s = strings.TrimPrefix(s, receipts.SigPrefix)
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		raw, err = base64.StdEncoding.DecodeString(s)
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("parse signing key: not PEM, hex or base64")
+	case len(raw) == ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case len(raw) == ed25519.PrivateKeySize:
+		key := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize])
+		if !key.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(raw[ed25519.SeedSize:])) {
+			return nil, fmt.Errorf("parse signing key: public half does not match the seed")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("parse signing key: %d bytes, want %d or %d", len(raw), ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+// LoadSigningKey reads a key file in any form ParseSigningKey accepts.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	return ParseSigningKey(string(b))
+}
@@ -0,0 +1,93 @@
+package agenttools_test
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clawinfra/agent-tools/pkg/receipts"
+	"github.com/clawinfra/agent-tools/sdk/go/agenttools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptSigner(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := agenttools.NewReceiptSigner("did:claw:agent:p", key, agenttools.WithSignerClock(func() time.Time { return now }))
+	assert.Equal(t, "ed25519:"+hex.EncodeToString(pub), s.PubKey())
+
+	inv := agenttools.Invocation{
+		ID: "inv_1", ToolID: "did:claw:tool:abc", ConsumerID: "did:claw:agent:c", CostCLAW: "5.0", RequestedAt: now.Add(-time.Second),
+	}
+	input := map[string]any{"q": "audit"}
+	r, err := s.Sign(inv, input, json.RawMessage(`{ "severity": "low" }`))
+	require.NoError(t, err)
+	assert.Equal(t, "did:claw:agent:p", r.ProviderID)
+	assert.Equal(t, now, r.ExecutedAt)
+	v := receipts.Verify(r, input, map[string]any{"severity": "low"}, pub)
+	assert.True(t, v.Valid, v.Reasons)
+
+	// A provider clock slightly behind stamps the time of the request.
+	inv.RequestedAt = now.Add(time.Minute)
+	r, err = s.Sign(inv, input, nil)
+	require.NoError(t, err)
+	assert.Equal(t, inv.RequestedAt, r.ExecutedAt)
+
+	for _, at := range []time.Time{now.Add(3 * time.Minute), now.Add(-3 * time.Minute)} {
+		inv.RequestedAt = at
+		_, err = s.Sign(inv, input, nil)
+		assert.ErrorIs(t, err, agenttools.ErrClockSkew)
+	}
+	inv.RequestedAt = now.Add(-3 * time.Minute)
+	lenient := agenttools.NewReceiptSigner("p", key, agenttools.WithClockSkew(5*time.Minute),
+		agenttools.WithSignerClock(func() time.Time { return now }))
+	_, err = lenient.Sign(inv, input, nil)
+	assert.NoError(t, err)
+
+	_, err = s.Sign(agenttools.Invocation{ID: "inv_1"}, input, nil)
+	assert.Error(t, err)
+}
+
+func TestParseSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	for name, s := range map[string]string{
+		"encoded":     agenttools.EncodeSigningKey(key),
+		"hex seed":    hex.EncodeToString(key.Seed()),
+		"hex key":     hex.EncodeToString(key) + "\n",
+		"base64 seed": base64.StdEncoding.EncodeToString(key.Seed()),
+		"pem":         string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	} {
+		got, err := agenttools.ParseSigningKey(s)
+		require.NoError(t, err, name)
+		assert.True(t, key.Equal(got), name)
+	}
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	mismatched := append(append([]byte{}, key.Seed()...), other.Public().(ed25519.PublicKey)...)
+	for _, s := range []string{"", "not a key", hex.EncodeToString([]byte("short")), hex.EncodeToString(mismatched)} {
+		_, err := agenttools.ParseSigningKey(s)
+		assert.Error(t, err, s)
+	}
+
+	path := filepath.Join(t.TempDir(), "provider.key")
+	require.NoError(t, os.WriteFile(path, []byte(agenttools.EncodeSigningKey(key)+"\n"), 0o600))
+	got, err := agenttools.LoadSigningKey(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(got))
+	_, err = agenttools.LoadSigningKey(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}